@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+)
+
+// alertDecision is what the ledger says to do with a call alert.
+type alertDecision int
+
+const (
+	alertSend alertDecision = iota
+	alertSendUpdated
+	alertSuppress
+)
+
+// decideAlert checks the notification ledger for an incident. The first alert
+// goes out as is. A repeat with the same body inside ALERT_SUPPRESSION_WINDOW_SEC
+// (a re-opened file, a forced reprocess) is dropped; any other repeat is
+// posted as an "(updated)" alert. A zero window disables the ledger.
+func (s *server) decideAlert(key, body string) alertDecision {
+	suppression := s.liveConfig().AlertSuppressionSec
+	if suppression <= 0 {
+		return alertSend
+	}
+	var hash string
+	var lastSent time.Time
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&hash, &lastSent)
+	}, `SELECT body_hash, last_sent_at FROM alert_ledger WHERE incident_key = ?`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return alertSend
+	}
+	if err != nil {
+		log.Printf("alert ledger lookup failed for %s: %v", key, err)
+		return alertSend
+	}
+	window := time.Duration(suppression) * time.Second
+	if hash == alertBodyHash(body) && time.Since(lastSent) < window {
+		return alertSuppress
+	}
+	return alertSendUpdated
+}
+
+// recordAlert notes a posted alert, or a suppressed one, in the ledger.
+func (s *server) recordAlert(key, filename, body string, decision alertDecision) {
+	if s.liveConfig().AlertSuppressionSec <= 0 {
+		return
+	}
+	now := time.Now().UTC()
+	var err error
+	if decision == alertSuppress {
+		_, err = execWithRetry(s.db, `UPDATE alert_ledger SET suppressed = suppressed + 1 WHERE incident_key = ?`, key)
+	} else {
+		_, err = execWithRetry(s.db, `INSERT INTO alert_ledger (incident_key, filename, body_hash, first_sent_at, last_sent_at, sends) VALUES (?, ?, ?, ?, ?, 1)
+ON CONFLICT(incident_key) DO UPDATE SET filename = excluded.filename, body_hash = excluded.body_hash, last_sent_at = excluded.last_sent_at, sends = sends + 1`,
+			key, filename, alertBodyHash(body), now, now)
+	}
+	if err != nil {
+		log.Printf("alert ledger update failed for %s: %v", key, err)
+	}
+}
+
+func alertBodyHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
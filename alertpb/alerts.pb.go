@@ -0,0 +1,1098 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: alerts.proto
+
+// The gRPC API for internal integrations such as the CAD display. It covers
+// the same ground as the JSON endpoints it mirrors, with the same visibility
+// rules: restricted calls and unscrubbed transcripts need the admin token,
+// sent as x-admin-token metadata.
+
+package alertpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SubmitCallRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Filename string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	// audio is the recording itself, up to 64 MB; larger recordings go
+	// through /api/uploads.
+	Audio []byte `protobuf:"bytes,2,opt,name=audio,proto3" json:"audio,omitempty"`
+	// notify sends the usual alerts once the call is transcribed.
+	Notify        bool `protobuf:"varint,3,opt,name=notify,proto3" json:"notify,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitCallRequest) Reset() {
+	*x = SubmitCallRequest{}
+	mi := &file_alerts_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitCallRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitCallRequest) ProtoMessage() {}
+
+func (x *SubmitCallRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitCallRequest.ProtoReflect.Descriptor instead.
+func (*SubmitCallRequest) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubmitCallRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *SubmitCallRequest) GetAudio() []byte {
+	if x != nil {
+		return x.Audio
+	}
+	return nil
+}
+
+func (x *SubmitCallRequest) GetNotify() bool {
+	if x != nil {
+		return x.Notify
+	}
+	return false
+}
+
+type SubmitCallResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitCallResponse) Reset() {
+	*x = SubmitCallResponse{}
+	mi := &file_alerts_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitCallResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitCallResponse) ProtoMessage() {}
+
+func (x *SubmitCallResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitCallResponse.ProtoReflect.Descriptor instead.
+func (*SubmitCallResponse) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubmitCallResponse) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *SubmitCallResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type GetTranscriptionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Filename      string                 `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTranscriptionRequest) Reset() {
+	*x = GetTranscriptionRequest{}
+	mi := &file_alerts_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTranscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTranscriptionRequest) ProtoMessage() {}
+
+func (x *GetTranscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTranscriptionRequest.ProtoReflect.Descriptor instead.
+func (*GetTranscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetTranscriptionRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type StreamCompletionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Since         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=since,proto3" json:"since,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamCompletionsRequest) Reset() {
+	*x = StreamCompletionsRequest{}
+	mi := &file_alerts_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamCompletionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamCompletionsRequest) ProtoMessage() {}
+
+func (x *StreamCompletionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamCompletionsRequest.ProtoReflect.Descriptor instead.
+func (*StreamCompletionsRequest) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamCompletionsRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+type ListRollupsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// limit defaults to 200.
+	Limit         int32                  `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	State         string                 `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=to,proto3" json:"to,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRollupsRequest) Reset() {
+	*x = ListRollupsRequest{}
+	mi := &file_alerts_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRollupsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRollupsRequest) ProtoMessage() {}
+
+func (x *ListRollupsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRollupsRequest.ProtoReflect.Descriptor instead.
+func (*ListRollupsRequest) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListRollupsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListRollupsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ListRollupsRequest) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *ListRollupsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *ListRollupsRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+type ListRollupsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rollups       []*Rollup              `protobuf:"bytes,1,rep,name=rollups,proto3" json:"rollups,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRollupsResponse) Reset() {
+	*x = ListRollupsResponse{}
+	mi := &file_alerts_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRollupsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRollupsResponse) ProtoMessage() {}
+
+func (x *ListRollupsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRollupsResponse.ProtoReflect.Descriptor instead.
+func (*ListRollupsResponse) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListRollupsResponse) GetRollups() []*Rollup {
+	if x != nil {
+		return x.Rollups
+	}
+	return nil
+}
+
+type Location struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Label         string                 `protobuf:"bytes,1,opt,name=label,proto3" json:"label,omitempty"`
+	Latitude      float64                `protobuf:"fixed64,2,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude     float64                `protobuf:"fixed64,3,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	Precision     string                 `protobuf:"bytes,4,opt,name=precision,proto3" json:"precision,omitempty"`
+	Source        string                 `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Location) Reset() {
+	*x = Location{}
+	mi := &file_alerts_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Location) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Location) ProtoMessage() {}
+
+func (x *Location) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Location.ProtoReflect.Descriptor instead.
+func (*Location) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Location) GetLabel() string {
+	if x != nil {
+		return x.Label
+	}
+	return ""
+}
+
+func (x *Location) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *Location) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *Location) GetPrecision() string {
+	if x != nil {
+		return x.Precision
+	}
+	return ""
+}
+
+func (x *Location) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+type Transcription struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Id                 int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Filename           string                 `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	Source             string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"`
+	Status             string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	Transcript         string                 `protobuf:"bytes,5,opt,name=transcript,proto3" json:"transcript,omitempty"`
+	CleanTranscript    string                 `protobuf:"bytes,6,opt,name=clean_transcript,json=cleanTranscript,proto3" json:"clean_transcript,omitempty"`
+	Translation        string                 `protobuf:"bytes,7,opt,name=translation,proto3" json:"translation,omitempty"`
+	DetectedLanguage   string                 `protobuf:"bytes,8,opt,name=detected_language,json=detectedLanguage,proto3" json:"detected_language,omitempty"`
+	CallType           string                 `protobuf:"bytes,9,opt,name=call_type,json=callType,proto3" json:"call_type,omitempty"`
+	NormalizedCallType string                 `protobuf:"bytes,10,opt,name=normalized_call_type,json=normalizedCallType,proto3" json:"normalized_call_type,omitempty"`
+	CallCategory       string                 `protobuf:"bytes,11,opt,name=call_category,json=callCategory,proto3" json:"call_category,omitempty"`
+	CallTimestamp      *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=call_timestamp,json=callTimestamp,proto3" json:"call_timestamp,omitempty"`
+	CreatedAt          *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt          *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	PrettyTitle        string                 `protobuf:"bytes,15,opt,name=pretty_title,json=prettyTitle,proto3" json:"pretty_title,omitempty"`
+	Town               string                 `protobuf:"bytes,16,opt,name=town,proto3" json:"town,omitempty"`
+	Agency             string                 `protobuf:"bytes,17,opt,name=agency,proto3" json:"agency,omitempty"`
+	AddressLine        string                 `protobuf:"bytes,18,opt,name=address_line,json=addressLine,proto3" json:"address_line,omitempty"`
+	CrossStreet        string                 `protobuf:"bytes,19,opt,name=cross_street,json=crossStreet,proto3" json:"cross_street,omitempty"`
+	CityOrTown         string                 `protobuf:"bytes,20,opt,name=city_or_town,json=cityOrTown,proto3" json:"city_or_town,omitempty"`
+	Location           *Location              `protobuf:"bytes,21,opt,name=location,proto3" json:"location,omitempty"`
+	Summary            string                 `protobuf:"bytes,22,opt,name=summary,proto3" json:"summary,omitempty"`
+	IncidentId         string                 `protobuf:"bytes,23,opt,name=incident_id,json=incidentId,proto3" json:"incident_id,omitempty"`
+	Tags               []string               `protobuf:"bytes,24,rep,name=tags,proto3" json:"tags,omitempty"`
+	Units              []string               `protobuf:"bytes,25,rep,name=units,proto3" json:"units,omitempty"`
+	Flags              []string               `protobuf:"bytes,26,rep,name=flags,proto3" json:"flags,omitempty"`
+	DurationSeconds    float64                `protobuf:"fixed64,27,opt,name=duration_seconds,json=durationSeconds,proto3" json:"duration_seconds,omitempty"`
+	AudioUrl           string                 `protobuf:"bytes,28,opt,name=audio_url,json=audioUrl,proto3" json:"audio_url,omitempty"`
+	PreviewImage       string                 `protobuf:"bytes,29,opt,name=preview_image,json=previewImage,proto3" json:"preview_image,omitempty"`
+	NeedsManualReview  bool                   `protobuf:"varint,30,opt,name=needs_manual_review,json=needsManualReview,proto3" json:"needs_manual_review,omitempty"`
+	ErrorCode          string                 `protobuf:"bytes,31,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
+	LastError          string                 `protobuf:"bytes,32,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Transcription) Reset() {
+	*x = Transcription{}
+	mi := &file_alerts_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transcription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transcription) ProtoMessage() {}
+
+func (x *Transcription) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transcription.ProtoReflect.Descriptor instead.
+func (*Transcription) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Transcription) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Transcription) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *Transcription) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Transcription) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Transcription) GetTranscript() string {
+	if x != nil {
+		return x.Transcript
+	}
+	return ""
+}
+
+func (x *Transcription) GetCleanTranscript() string {
+	if x != nil {
+		return x.CleanTranscript
+	}
+	return ""
+}
+
+func (x *Transcription) GetTranslation() string {
+	if x != nil {
+		return x.Translation
+	}
+	return ""
+}
+
+func (x *Transcription) GetDetectedLanguage() string {
+	if x != nil {
+		return x.DetectedLanguage
+	}
+	return ""
+}
+
+func (x *Transcription) GetCallType() string {
+	if x != nil {
+		return x.CallType
+	}
+	return ""
+}
+
+func (x *Transcription) GetNormalizedCallType() string {
+	if x != nil {
+		return x.NormalizedCallType
+	}
+	return ""
+}
+
+func (x *Transcription) GetCallCategory() string {
+	if x != nil {
+		return x.CallCategory
+	}
+	return ""
+}
+
+func (x *Transcription) GetCallTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CallTimestamp
+	}
+	return nil
+}
+
+func (x *Transcription) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Transcription) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Transcription) GetPrettyTitle() string {
+	if x != nil {
+		return x.PrettyTitle
+	}
+	return ""
+}
+
+func (x *Transcription) GetTown() string {
+	if x != nil {
+		return x.Town
+	}
+	return ""
+}
+
+func (x *Transcription) GetAgency() string {
+	if x != nil {
+		return x.Agency
+	}
+	return ""
+}
+
+func (x *Transcription) GetAddressLine() string {
+	if x != nil {
+		return x.AddressLine
+	}
+	return ""
+}
+
+func (x *Transcription) GetCrossStreet() string {
+	if x != nil {
+		return x.CrossStreet
+	}
+	return ""
+}
+
+func (x *Transcription) GetCityOrTown() string {
+	if x != nil {
+		return x.CityOrTown
+	}
+	return ""
+}
+
+func (x *Transcription) GetLocation() *Location {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *Transcription) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *Transcription) GetIncidentId() string {
+	if x != nil {
+		return x.IncidentId
+	}
+	return ""
+}
+
+func (x *Transcription) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Transcription) GetUnits() []string {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+func (x *Transcription) GetFlags() []string {
+	if x != nil {
+		return x.Flags
+	}
+	return nil
+}
+
+func (x *Transcription) GetDurationSeconds() float64 {
+	if x != nil {
+		return x.DurationSeconds
+	}
+	return 0
+}
+
+func (x *Transcription) GetAudioUrl() string {
+	if x != nil {
+		return x.AudioUrl
+	}
+	return ""
+}
+
+func (x *Transcription) GetPreviewImage() string {
+	if x != nil {
+		return x.PreviewImage
+	}
+	return ""
+}
+
+func (x *Transcription) GetNeedsManualReview() bool {
+	if x != nil {
+		return x.NeedsManualReview
+	}
+	return false
+}
+
+func (x *Transcription) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+func (x *Transcription) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+type Rollup struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	RollupId       int64                  `protobuf:"varint,1,opt,name=rollup_id,json=rollupId,proto3" json:"rollup_id,omitempty"`
+	StartAt        *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=start_at,json=startAt,proto3" json:"start_at,omitempty"`
+	EndAt          *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=end_at,json=endAt,proto3" json:"end_at,omitempty"`
+	Latitude       float64                `protobuf:"fixed64,4,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude      float64                `protobuf:"fixed64,5,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	Municipality   string                 `protobuf:"bytes,6,opt,name=municipality,proto3" json:"municipality,omitempty"`
+	Poi            string                 `protobuf:"bytes,7,opt,name=poi,proto3" json:"poi,omitempty"`
+	Category       string                 `protobuf:"bytes,8,opt,name=category,proto3" json:"category,omitempty"`
+	Priority       string                 `protobuf:"bytes,9,opt,name=priority,proto3" json:"priority,omitempty"`
+	Title          string                 `protobuf:"bytes,10,opt,name=title,proto3" json:"title,omitempty"`
+	Summary        string                 `protobuf:"bytes,11,opt,name=summary,proto3" json:"summary,omitempty"`
+	Evidence       []string               `protobuf:"bytes,12,rep,name=evidence,proto3" json:"evidence,omitempty"`
+	Confidence     string                 `protobuf:"bytes,13,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	Status         string                 `protobuf:"bytes,14,opt,name=status,proto3" json:"status,omitempty"`
+	State          string                 `protobuf:"bytes,15,opt,name=state,proto3" json:"state,omitempty"`
+	CallCount      int32                  `protobuf:"varint,16,opt,name=call_count,json=callCount,proto3" json:"call_count,omitempty"`
+	Curated        bool                   `protobuf:"varint,17,opt,name=curated,proto3" json:"curated,omitempty"`
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,18,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	ClosedAt       *timestamppb.Timestamp `protobuf:"bytes,19,opt,name=closed_at,json=closedAt,proto3" json:"closed_at,omitempty"`
+	ClosingSummary string                 `protobuf:"bytes,20,opt,name=closing_summary,json=closingSummary,proto3" json:"closing_summary,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Rollup) Reset() {
+	*x = Rollup{}
+	mi := &file_alerts_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rollup) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rollup) ProtoMessage() {}
+
+func (x *Rollup) ProtoReflect() protoreflect.Message {
+	mi := &file_alerts_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rollup.ProtoReflect.Descriptor instead.
+func (*Rollup) Descriptor() ([]byte, []int) {
+	return file_alerts_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Rollup) GetRollupId() int64 {
+	if x != nil {
+		return x.RollupId
+	}
+	return 0
+}
+
+func (x *Rollup) GetStartAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartAt
+	}
+	return nil
+}
+
+func (x *Rollup) GetEndAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndAt
+	}
+	return nil
+}
+
+func (x *Rollup) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *Rollup) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *Rollup) GetMunicipality() string {
+	if x != nil {
+		return x.Municipality
+	}
+	return ""
+}
+
+func (x *Rollup) GetPoi() string {
+	if x != nil {
+		return x.Poi
+	}
+	return ""
+}
+
+func (x *Rollup) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Rollup) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *Rollup) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Rollup) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *Rollup) GetEvidence() []string {
+	if x != nil {
+		return x.Evidence
+	}
+	return nil
+}
+
+func (x *Rollup) GetConfidence() string {
+	if x != nil {
+		return x.Confidence
+	}
+	return ""
+}
+
+func (x *Rollup) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Rollup) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Rollup) GetCallCount() int32 {
+	if x != nil {
+		return x.CallCount
+	}
+	return 0
+}
+
+func (x *Rollup) GetCurated() bool {
+	if x != nil {
+		return x.Curated
+	}
+	return false
+}
+
+func (x *Rollup) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Rollup) GetClosedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ClosedAt
+	}
+	return nil
+}
+
+func (x *Rollup) GetClosingSummary() string {
+	if x != nil {
+		return x.ClosingSummary
+	}
+	return ""
+}
+
+var File_alerts_proto protoreflect.FileDescriptor
+
+const file_alerts_proto_rawDesc = "" +
+	"\n" +
+	"\falerts.proto\x12\x12alert_framework.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"]\n" +
+	"\x11SubmitCallRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x14\n" +
+	"\x05audio\x18\x02 \x01(\fR\x05audio\x12\x16\n" +
+	"\x06notify\x18\x03 \x01(\bR\x06notify\"H\n" +
+	"\x12SubmitCallResponse\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\"5\n" +
+	"\x17GetTranscriptionRequest\x12\x1a\n" +
+	"\bfilename\x18\x01 \x01(\tR\bfilename\"L\n" +
+	"\x18StreamCompletionsRequest\x120\n" +
+	"\x05since\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\"\xb4\x01\n" +
+	"\x12ListRollupsRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x14\n" +
+	"\x05state\x18\x03 \x01(\tR\x05state\x12.\n" +
+	"\x04from\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\"K\n" +
+	"\x13ListRollupsResponse\x124\n" +
+	"\arollups\x18\x01 \x03(\v2\x1a.alert_framework.v1.RollupR\arollups\"\x90\x01\n" +
+	"\bLocation\x12\x14\n" +
+	"\x05label\x18\x01 \x01(\tR\x05label\x12\x1a\n" +
+	"\blatitude\x18\x02 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x03 \x01(\x01R\tlongitude\x12\x1c\n" +
+	"\tprecision\x18\x04 \x01(\tR\tprecision\x12\x16\n" +
+	"\x06source\x18\x05 \x01(\tR\x06source\"\xf9\b\n" +
+	"\rTranscription\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1a\n" +
+	"\bfilename\x18\x02 \x01(\tR\bfilename\x12\x16\n" +
+	"\x06source\x18\x03 \x01(\tR\x06source\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x1e\n" +
+	"\n" +
+	"transcript\x18\x05 \x01(\tR\n" +
+	"transcript\x12)\n" +
+	"\x10clean_transcript\x18\x06 \x01(\tR\x0fcleanTranscript\x12 \n" +
+	"\vtranslation\x18\a \x01(\tR\vtranslation\x12+\n" +
+	"\x11detected_language\x18\b \x01(\tR\x10detectedLanguage\x12\x1b\n" +
+	"\tcall_type\x18\t \x01(\tR\bcallType\x120\n" +
+	"\x14normalized_call_type\x18\n" +
+	" \x01(\tR\x12normalizedCallType\x12#\n" +
+	"\rcall_category\x18\v \x01(\tR\fcallCategory\x12A\n" +
+	"\x0ecall_timestamp\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\rcallTimestamp\x129\n" +
+	"\n" +
+	"created_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12!\n" +
+	"\fpretty_title\x18\x0f \x01(\tR\vprettyTitle\x12\x12\n" +
+	"\x04town\x18\x10 \x01(\tR\x04town\x12\x16\n" +
+	"\x06agency\x18\x11 \x01(\tR\x06agency\x12!\n" +
+	"\faddress_line\x18\x12 \x01(\tR\vaddressLine\x12!\n" +
+	"\fcross_street\x18\x13 \x01(\tR\vcrossStreet\x12 \n" +
+	"\fcity_or_town\x18\x14 \x01(\tR\n" +
+	"cityOrTown\x128\n" +
+	"\blocation\x18\x15 \x01(\v2\x1c.alert_framework.v1.LocationR\blocation\x12\x18\n" +
+	"\asummary\x18\x16 \x01(\tR\asummary\x12\x1f\n" +
+	"\vincident_id\x18\x17 \x01(\tR\n" +
+	"incidentId\x12\x12\n" +
+	"\x04tags\x18\x18 \x03(\tR\x04tags\x12\x14\n" +
+	"\x05units\x18\x19 \x03(\tR\x05units\x12\x14\n" +
+	"\x05flags\x18\x1a \x03(\tR\x05flags\x12)\n" +
+	"\x10duration_seconds\x18\x1b \x01(\x01R\x0fdurationSeconds\x12\x1b\n" +
+	"\taudio_url\x18\x1c \x01(\tR\baudioUrl\x12#\n" +
+	"\rpreview_image\x18\x1d \x01(\tR\fpreviewImage\x12.\n" +
+	"\x13needs_manual_review\x18\x1e \x01(\bR\x11needsManualReview\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\x1f \x01(\tR\terrorCode\x12\x1d\n" +
+	"\n" +
+	"last_error\x18  \x01(\tR\tlastError\"\xa7\x05\n" +
+	"\x06Rollup\x12\x1b\n" +
+	"\trollup_id\x18\x01 \x01(\x03R\brollupId\x125\n" +
+	"\bstart_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\astartAt\x121\n" +
+	"\x06end_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05endAt\x12\x1a\n" +
+	"\blatitude\x18\x04 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x05 \x01(\x01R\tlongitude\x12\"\n" +
+	"\fmunicipality\x18\x06 \x01(\tR\fmunicipality\x12\x10\n" +
+	"\x03poi\x18\a \x01(\tR\x03poi\x12\x1a\n" +
+	"\bcategory\x18\b \x01(\tR\bcategory\x12\x1a\n" +
+	"\bpriority\x18\t \x01(\tR\bpriority\x12\x14\n" +
+	"\x05title\x18\n" +
+	" \x01(\tR\x05title\x12\x18\n" +
+	"\asummary\x18\v \x01(\tR\asummary\x12\x1a\n" +
+	"\bevidence\x18\f \x03(\tR\bevidence\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\r \x01(\tR\n" +
+	"confidence\x12\x16\n" +
+	"\x06status\x18\x0e \x01(\tR\x06status\x12\x14\n" +
+	"\x05state\x18\x0f \x01(\tR\x05state\x12\x1d\n" +
+	"\n" +
+	"call_count\x18\x10 \x01(\x05R\tcallCount\x12\x18\n" +
+	"\acurated\x18\x11 \x01(\bR\acurated\x129\n" +
+	"\n" +
+	"updated_at\x18\x12 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x127\n" +
+	"\tclosed_at\x18\x13 \x01(\v2\x1a.google.protobuf.TimestampR\bclosedAt\x12'\n" +
+	"\x0fclosing_summary\x18\x14 \x01(\tR\x0eclosingSummary2\x91\x03\n" +
+	"\x06Alerts\x12[\n" +
+	"\n" +
+	"SubmitCall\x12%.alert_framework.v1.SubmitCallRequest\x1a&.alert_framework.v1.SubmitCallResponse\x12b\n" +
+	"\x10GetTranscription\x12+.alert_framework.v1.GetTranscriptionRequest\x1a!.alert_framework.v1.Transcription\x12f\n" +
+	"\x11StreamCompletions\x12,.alert_framework.v1.StreamCompletionsRequest\x1a!.alert_framework.v1.Transcription0\x01\x12^\n" +
+	"\vListRollups\x12&.alert_framework.v1.ListRollupsRequest\x1a'.alert_framework.v1.ListRollupsResponseB\x19Z\x17alert_framework/alertpbb\x06proto3"
+
+var (
+	file_alerts_proto_rawDescOnce sync.Once
+	file_alerts_proto_rawDescData []byte
+)
+
+func file_alerts_proto_rawDescGZIP() []byte {
+	file_alerts_proto_rawDescOnce.Do(func() {
+		file_alerts_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_alerts_proto_rawDesc), len(file_alerts_proto_rawDesc)))
+	})
+	return file_alerts_proto_rawDescData
+}
+
+var file_alerts_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_alerts_proto_goTypes = []any{
+	(*SubmitCallRequest)(nil),        // 0: alert_framework.v1.SubmitCallRequest
+	(*SubmitCallResponse)(nil),       // 1: alert_framework.v1.SubmitCallResponse
+	(*GetTranscriptionRequest)(nil),  // 2: alert_framework.v1.GetTranscriptionRequest
+	(*StreamCompletionsRequest)(nil), // 3: alert_framework.v1.StreamCompletionsRequest
+	(*ListRollupsRequest)(nil),       // 4: alert_framework.v1.ListRollupsRequest
+	(*ListRollupsResponse)(nil),      // 5: alert_framework.v1.ListRollupsResponse
+	(*Location)(nil),                 // 6: alert_framework.v1.Location
+	(*Transcription)(nil),            // 7: alert_framework.v1.Transcription
+	(*Rollup)(nil),                   // 8: alert_framework.v1.Rollup
+	(*timestamppb.Timestamp)(nil),    // 9: google.protobuf.Timestamp
+}
+var file_alerts_proto_depIdxs = []int32{
+	9,  // 0: alert_framework.v1.StreamCompletionsRequest.since:type_name -> google.protobuf.Timestamp
+	9,  // 1: alert_framework.v1.ListRollupsRequest.from:type_name -> google.protobuf.Timestamp
+	9,  // 2: alert_framework.v1.ListRollupsRequest.to:type_name -> google.protobuf.Timestamp
+	8,  // 3: alert_framework.v1.ListRollupsResponse.rollups:type_name -> alert_framework.v1.Rollup
+	9,  // 4: alert_framework.v1.Transcription.call_timestamp:type_name -> google.protobuf.Timestamp
+	9,  // 5: alert_framework.v1.Transcription.created_at:type_name -> google.protobuf.Timestamp
+	9,  // 6: alert_framework.v1.Transcription.updated_at:type_name -> google.protobuf.Timestamp
+	6,  // 7: alert_framework.v1.Transcription.location:type_name -> alert_framework.v1.Location
+	9,  // 8: alert_framework.v1.Rollup.start_at:type_name -> google.protobuf.Timestamp
+	9,  // 9: alert_framework.v1.Rollup.end_at:type_name -> google.protobuf.Timestamp
+	9,  // 10: alert_framework.v1.Rollup.updated_at:type_name -> google.protobuf.Timestamp
+	9,  // 11: alert_framework.v1.Rollup.closed_at:type_name -> google.protobuf.Timestamp
+	0,  // 12: alert_framework.v1.Alerts.SubmitCall:input_type -> alert_framework.v1.SubmitCallRequest
+	2,  // 13: alert_framework.v1.Alerts.GetTranscription:input_type -> alert_framework.v1.GetTranscriptionRequest
+	3,  // 14: alert_framework.v1.Alerts.StreamCompletions:input_type -> alert_framework.v1.StreamCompletionsRequest
+	4,  // 15: alert_framework.v1.Alerts.ListRollups:input_type -> alert_framework.v1.ListRollupsRequest
+	1,  // 16: alert_framework.v1.Alerts.SubmitCall:output_type -> alert_framework.v1.SubmitCallResponse
+	7,  // 17: alert_framework.v1.Alerts.GetTranscription:output_type -> alert_framework.v1.Transcription
+	7,  // 18: alert_framework.v1.Alerts.StreamCompletions:output_type -> alert_framework.v1.Transcription
+	5,  // 19: alert_framework.v1.Alerts.ListRollups:output_type -> alert_framework.v1.ListRollupsResponse
+	16, // [16:20] is the sub-list for method output_type
+	12, // [12:16] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_alerts_proto_init() }
+func file_alerts_proto_init() {
+	if File_alerts_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_alerts_proto_rawDesc), len(file_alerts_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_alerts_proto_goTypes,
+		DependencyIndexes: file_alerts_proto_depIdxs,
+		MessageInfos:      file_alerts_proto_msgTypes,
+	}.Build()
+	File_alerts_proto = out.File
+	file_alerts_proto_goTypes = nil
+	file_alerts_proto_depIdxs = nil
+}
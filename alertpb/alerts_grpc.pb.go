@@ -0,0 +1,262 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: alerts.proto
+
+// The gRPC API for internal integrations such as the CAD display. It covers
+// the same ground as the JSON endpoints it mirrors, with the same visibility
+// rules: restricted calls and unscrubbed transcripts need the admin token,
+// sent as x-admin-token metadata.
+
+package alertpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Alerts_SubmitCall_FullMethodName        = "/alert_framework.v1.Alerts/SubmitCall"
+	Alerts_GetTranscription_FullMethodName  = "/alert_framework.v1.Alerts/GetTranscription"
+	Alerts_StreamCompletions_FullMethodName = "/alert_framework.v1.Alerts/StreamCompletions"
+	Alerts_ListRollups_FullMethodName       = "/alert_framework.v1.Alerts/ListRollups"
+)
+
+// AlertsClient is the client API for Alerts service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AlertsClient interface {
+	// SubmitCall queues a recording for transcription, like POST
+	// /api/transcription. With audio the recording is validated and stored in
+	// CALLS_DIR under filename first; without it, filename must already be
+	// there and is transcribed again. Admin only.
+	SubmitCall(ctx context.Context, in *SubmitCallRequest, opts ...grpc.CallOption) (*SubmitCallResponse, error)
+	// GetTranscription returns one call, like GET /api/transcription/{filename}.
+	GetTranscription(ctx context.Context, in *GetTranscriptionRequest, opts ...grpc.CallOption) (*Transcription, error)
+	// StreamCompletions sends each call as its transcription finishes, done or
+	// failed, starting after since (default: now). A call is sent again if it
+	// is reprocessed or edited after finishing.
+	StreamCompletions(ctx context.Context, in *StreamCompletionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transcription], error)
+	// ListRollups returns rollups newest first, like GET /api/rollups.
+	ListRollups(ctx context.Context, in *ListRollupsRequest, opts ...grpc.CallOption) (*ListRollupsResponse, error)
+}
+
+type alertsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAlertsClient(cc grpc.ClientConnInterface) AlertsClient {
+	return &alertsClient{cc}
+}
+
+func (c *alertsClient) SubmitCall(ctx context.Context, in *SubmitCallRequest, opts ...grpc.CallOption) (*SubmitCallResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitCallResponse)
+	err := c.cc.Invoke(ctx, Alerts_SubmitCall_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *alertsClient) GetTranscription(ctx context.Context, in *GetTranscriptionRequest, opts ...grpc.CallOption) (*Transcription, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Transcription)
+	err := c.cc.Invoke(ctx, Alerts_GetTranscription_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *alertsClient) StreamCompletions(ctx context.Context, in *StreamCompletionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transcription], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Alerts_ServiceDesc.Streams[0], Alerts_StreamCompletions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamCompletionsRequest, Transcription]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Alerts_StreamCompletionsClient = grpc.ServerStreamingClient[Transcription]
+
+func (c *alertsClient) ListRollups(ctx context.Context, in *ListRollupsRequest, opts ...grpc.CallOption) (*ListRollupsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRollupsResponse)
+	err := c.cc.Invoke(ctx, Alerts_ListRollups_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AlertsServer is the server API for Alerts service.
+// All implementations must embed UnimplementedAlertsServer
+// for forward compatibility.
+type AlertsServer interface {
+	// SubmitCall queues a recording for transcription, like POST
+	// /api/transcription. With audio the recording is validated and stored in
+	// CALLS_DIR under filename first; without it, filename must already be
+	// there and is transcribed again. Admin only.
+	SubmitCall(context.Context, *SubmitCallRequest) (*SubmitCallResponse, error)
+	// GetTranscription returns one call, like GET /api/transcription/{filename}.
+	GetTranscription(context.Context, *GetTranscriptionRequest) (*Transcription, error)
+	// StreamCompletions sends each call as its transcription finishes, done or
+	// failed, starting after since (default: now). A call is sent again if it
+	// is reprocessed or edited after finishing.
+	StreamCompletions(*StreamCompletionsRequest, grpc.ServerStreamingServer[Transcription]) error
+	// ListRollups returns rollups newest first, like GET /api/rollups.
+	ListRollups(context.Context, *ListRollupsRequest) (*ListRollupsResponse, error)
+	mustEmbedUnimplementedAlertsServer()
+}
+
+// UnimplementedAlertsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAlertsServer struct{}
+
+func (UnimplementedAlertsServer) SubmitCall(context.Context, *SubmitCallRequest) (*SubmitCallResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitCall not implemented")
+}
+func (UnimplementedAlertsServer) GetTranscription(context.Context, *GetTranscriptionRequest) (*Transcription, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTranscription not implemented")
+}
+func (UnimplementedAlertsServer) StreamCompletions(*StreamCompletionsRequest, grpc.ServerStreamingServer[Transcription]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamCompletions not implemented")
+}
+func (UnimplementedAlertsServer) ListRollups(context.Context, *ListRollupsRequest) (*ListRollupsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRollups not implemented")
+}
+func (UnimplementedAlertsServer) mustEmbedUnimplementedAlertsServer() {}
+func (UnimplementedAlertsServer) testEmbeddedByValue()                {}
+
+// UnsafeAlertsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AlertsServer will
+// result in compilation errors.
+type UnsafeAlertsServer interface {
+	mustEmbedUnimplementedAlertsServer()
+}
+
+func RegisterAlertsServer(s grpc.ServiceRegistrar, srv AlertsServer) {
+	// If the following call pancis, it indicates UnimplementedAlertsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Alerts_ServiceDesc, srv)
+}
+
+func _Alerts_SubmitCall_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitCallRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AlertsServer).SubmitCall(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Alerts_SubmitCall_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AlertsServer).SubmitCall(ctx, req.(*SubmitCallRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Alerts_GetTranscription_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTranscriptionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AlertsServer).GetTranscription(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Alerts_GetTranscription_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AlertsServer).GetTranscription(ctx, req.(*GetTranscriptionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Alerts_StreamCompletions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamCompletionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AlertsServer).StreamCompletions(m, &grpc.GenericServerStream[StreamCompletionsRequest, Transcription]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Alerts_StreamCompletionsServer = grpc.ServerStreamingServer[Transcription]
+
+func _Alerts_ListRollups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRollupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AlertsServer).ListRollups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Alerts_ListRollups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AlertsServer).ListRollups(ctx, req.(*ListRollupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Alerts_ServiceDesc is the grpc.ServiceDesc for Alerts service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Alerts_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "alert_framework.v1.Alerts",
+	HandlerType: (*AlertsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitCall",
+			Handler:    _Alerts_SubmitCall_Handler,
+		},
+		{
+			MethodName: "GetTranscription",
+			Handler:    _Alerts_GetTranscription_Handler,
+		},
+		{
+			MethodName: "ListRollups",
+			Handler:    _Alerts_ListRollups_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamCompletions",
+			Handler:       _Alerts_StreamCompletions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "alerts.proto",
+}
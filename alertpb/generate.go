@@ -0,0 +1,5 @@
+// Package alertpb holds the messages and service of the internal gRPC API,
+// generated from alerts.proto.
+package alertpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative alerts.proto
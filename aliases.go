@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Scanner software sometimes renames a recording it already wrote, adding a
+// _1 suffix, which drops the same audio into CALLS_DIR under a new name. A
+// file whose hash matches a completed call is recorded as an alias of that
+// call rather than becoming a second one. Aliases are matched by exact hash
+// only; near-duplicates are left to FINGERPRINT_THRESHOLD.
+
+func migrateAddCallAliases(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS call_aliases (
+    alias TEXT PRIMARY KEY,
+    filename TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_call_aliases_filename ON call_aliases(filename);
+CREATE INDEX IF NOT EXISTS idx_transcriptions_hash ON transcriptions(hash);`)
+	return err
+}
+
+func downCallAliases(db *sql.DB) error {
+	if _, err := execWithRetry(db, `DROP INDEX IF EXISTS idx_transcriptions_hash`); err != nil {
+		return err
+	}
+	return dropTables("call_aliases")(db)
+}
+
+// aliasOf returns the call filename is an alias of, if it is one.
+func (s *server) aliasOf(filename string) (string, bool) {
+	var target string
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&target)
+	}, `SELECT filename FROM call_aliases WHERE alias = ?`, filename)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("alias lookup for %s failed: %v", filename, err)
+		}
+		return "", false
+	}
+	return target, true
+}
+
+// callAliases lists the other filenames a call's audio arrived under.
+func (s *server) callAliases(ctx context.Context, filename string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT alias FROM call_aliases WHERE filename = ? ORDER BY created_at, alias`, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, err
+		}
+		out = append(out, alias)
+	}
+	return out, rows.Err()
+}
+
+// aliasOnIngest hashes a recording before it is queued and, when a completed
+// call has the same audio, records filename as its alias. It returns the
+// call it was attached to, or "" when the recording should be queued. A file
+// still being written hashes differently and is caught once processing has
+// waited for it to settle.
+func (s *server) aliasOnIngest(filename string) string {
+	path := filepath.Join(s.cfg.CallsDir, filename)
+	if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+		return ""
+	}
+	hash, err := hashFile(path)
+	if err != nil {
+		return ""
+	}
+	dup := s.findDuplicate(hash, filename)
+	if dup == "" {
+		return ""
+	}
+	if err := s.addAlias(filename, dup, hash); err != nil {
+		log.Printf("alias %s -> %s failed: %v", filename, dup, err)
+		return ""
+	}
+	return dup
+}
+
+func (s *server) addAlias(alias, filename, hash string) error {
+	_, err := execWithRetry(s.db, `INSERT INTO call_aliases (alias, filename, hash) VALUES (?, ?, ?)
+ON CONFLICT(alias) DO UPDATE SET filename = excluded.filename, hash = excluded.hash`, alias, filename, hash)
+	return err
+}
+
+// replaceWithAlias turns a call created by this ingest into an alias of dup:
+// its row is removed and the alias recorded in the same transaction.
+func (s *server) replaceWithAlias(filename, dup, hash string) error {
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM transcriptions WHERE filename = ?`, filename); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO call_aliases (alias, filename, hash) VALUES (?, ?, ?)
+ON CONFLICT(alias) DO UPDATE SET filename = excluded.filename, hash = excluded.hash`, filename, dup, hash); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const archiveDateLayout = "2006-01-02"
+
+var (
+	archiveSlugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+	archivePathPattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})/(?:([a-z0-9-]+)\.(mp3|cue))?$`)
+)
+
+// archiveCall is one chapter of a daily archive.
+type archiveCall struct {
+	Title    string
+	Path     string
+	Duration float64
+}
+
+// startArchiveScheduler builds the previous day's archives once a day during
+// ARCHIVE_HOUR. Days that already have an archive directory are skipped, so
+// restarts do not rebuild them.
+func (s *server) startArchiveScheduler(ctx context.Context) {
+	if s.cfg.ArchiveHour < 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+			now := time.Now().In(s.tz)
+			if now.Hour() != s.cfg.ArchiveHour {
+				continue
+			}
+			day := now.AddDate(0, 0, -1)
+			if _, err := os.Stat(filepath.Join(s.cfg.ArchiveDir, day.Format(archiveDateLayout))); err == nil {
+				continue
+			}
+			if err := s.buildDailyArchive(ctx, day); err != nil {
+				log.Printf("daily archive for %s failed: %v", day.Format(archiveDateLayout), err)
+			}
+		}
+	}()
+}
+
+// buildDailyArchive concatenates the day's completed calls into one mp3 per
+// agency (or town, per ARCHIVE_GROUP_BY) with ID3 chapters and a CUE sheet.
+// Duplicates and restricted calls are left out. The day's directory is
+// replaced as a whole.
+func (s *server) buildDailyArchive(ctx context.Context, day time.Time) error {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, s.tz)
+	end := start.AddDate(0, 0, 1)
+	records, err := s.store.Select(ctx, `WHERE status = ? AND duplicate_of IS NULL AND call_timestamp >= ? AND call_timestamp < ? ORDER BY call_timestamp`, statusDone, start.UTC(), end.UTC())
+	if err != nil {
+		return err
+	}
+
+	groups := make(map[string][]archiveCall)
+	names := make(map[string]string)
+	included := 0
+	for _, t := range records {
+		if s.isRestricted(t) {
+			continue
+		}
+		path := s.archiveSourcePath(t)
+		if path == "" {
+			continue
+		}
+		meta, _ := s.parseCallMetadata(t.Filename, "")
+		group := meta.AgencyDisplay
+		if s.cfg.ArchiveGroupBy == "town" {
+			if towns := parseRecognizedTowns(t.RecognizedTowns); len(towns) > 0 {
+				group = towns[0]
+			} else {
+				group = meta.TownDisplay
+			}
+		}
+		slug := archiveSlug(group)
+		if slug == "" {
+			slug = "unknown"
+			group = "Unknown"
+		}
+		names[slug] = group
+		duration := 0.0
+		if t.DurationSeconds != nil {
+			duration = *t.DurationSeconds
+		}
+		if duration <= 0 {
+			duration = probeDuration(path)
+		}
+		if duration <= 0 {
+			continue
+		}
+		callTime := t.CallTimestamp.In(s.tz)
+		title := callTime.Format("15:04:05")
+		if callType := strings.TrimSpace(derefString(t.CallType, meta.CallType)); callType != "" {
+			title += " " + callType
+		}
+		groups[slug] = append(groups[slug], archiveCall{Title: title, Path: path, Duration: duration})
+		included++
+	}
+
+	date := start.Format(archiveDateLayout)
+	if err := os.MkdirAll(s.cfg.ArchiveDir, 0o755); err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(s.cfg.ArchiveDir, "."+date+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	for slug, calls := range groups {
+		title := fmt.Sprintf("%s – %s", names[slug], date)
+		if err := writeArchiveAudio(ctx, tmpDir, slug, title, calls); err != nil {
+			return fmt.Errorf("%s: %w", slug, err)
+		}
+	}
+	if err := os.Chmod(tmpDir, 0o755); err != nil {
+		return err
+	}
+	finalDir := filepath.Join(s.cfg.ArchiveDir, date)
+	if err := os.RemoveAll(finalDir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, finalDir); err != nil {
+		return err
+	}
+	log.Printf("daily archive %s built: %d calls in %d files", date, included, len(groups))
+	return nil
+}
+
+// archiveSourcePath prefers the filtered audio and falls back to the original.
+func (s *server) archiveSourcePath(t transcription) string {
+	for _, p := range []string{t.ProcessedPath, t.SourcePath} {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) && !fileExists(p) {
+			p = filepath.Join(s.cfg.CallsDir, filepath.Base(p))
+		}
+		if fileExists(p) {
+			return p
+		}
+	}
+	return ""
+}
+
+func writeArchiveAudio(ctx context.Context, dir, slug, title string, calls []archiveCall) error {
+	var list, chapters, cue strings.Builder
+	fmt.Fprintf(&chapters, ";FFMETADATA1\ntitle=%s\n", ffmetadataEscape(title))
+	fmt.Fprintf(&cue, "TITLE %q\nFILE %q MP3\n", title, slug+".mp3")
+	offset := 0.0
+	for i, c := range calls {
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(c.Path, "'", `'\''`))
+		startMs, endMs := int64(offset*1000), int64((offset+c.Duration)*1000)
+		fmt.Fprintf(&chapters, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n", startMs, endMs, ffmetadataEscape(c.Title))
+		fmt.Fprintf(&cue, "  TRACK %02d AUDIO\n    TITLE %q\n    INDEX 01 %s\n", i+1, c.Title, cueTimestamp(offset))
+		offset += c.Duration
+	}
+	listPath := filepath.Join(dir, slug+".txt")
+	metaPath := filepath.Join(dir, slug+".ffmeta")
+	if err := os.WriteFile(listPath, []byte(list.String()), 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(metaPath, []byte(chapters.String()), 0o644); err != nil {
+		return err
+	}
+	defer os.Remove(listPath)
+	defer os.Remove(metaPath)
+
+	// Re-encode rather than stream-copy: the inputs mix sample rates and
+	// formats, and the concat demuxer needs uniform streams to copy.
+	cmd := exec.CommandContext(ctx, ffmpegBinary,
+		"-y", "-v", "error",
+		"-f", "concat", "-safe", "0", "-i", listPath,
+		"-i", metaPath,
+		"-map", "0:a", "-map_metadata", "1", "-map_chapters", "1",
+		"-ac", "1", "-ar", "22050", "-b:a", "48k",
+		"-id3v2_version", "3",
+		filepath.Join(dir, slug+".mp3"),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg concat: %w (stderr: %s)", err, strings.TrimSpace(stderr.String()))
+	}
+	return os.WriteFile(filepath.Join(dir, slug+".cue"), []byte(cue.String()), 0o644)
+}
+
+func archiveSlug(name string) string {
+	return strings.Trim(archiveSlugPattern.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// ffmetadataEscape escapes the characters the ffmetadata format reserves.
+func ffmetadataEscape(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", `\`+"\n")
+	return r.Replace(v)
+}
+
+// cueTimestamp formats seconds as CUE mm:ss:ff (75 frames per second).
+func cueTimestamp(seconds float64) string {
+	frames := int64(seconds * 75)
+	return fmt.Sprintf("%02d:%02d:%02d", frames/(75*60), frames/75%60, frames%75)
+}
+
+// handleArchive serves GET /archive/{date}/{group}.mp3 (or .cue), and lists a
+// day's files at /archive/{date}/.
+func (s *server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	m := archivePathPattern.FindStringSubmatch(strings.TrimPrefix(r.URL.Path, "/archive/"))
+	if m == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := time.Parse(archiveDateLayout, m[1]); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	dayDir := filepath.Join(s.cfg.ArchiveDir, m[1])
+	if m[2] == "" {
+		entries, err := os.ReadDir(dayDir)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		files := []string{}
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".mp3") {
+				files = append(files, "/archive/"+m[1]+"/"+e.Name())
+			}
+		}
+		sort.Strings(files)
+		respondJSON(w, map[string]interface{}{"date": m[1], "files": files})
+		return
+	}
+	path := filepath.Join(dayDir, m[2]+"."+m[3])
+	if !fileExists(path) {
+		http.NotFound(w, r)
+		return
+	}
+	if m[3] == "cue" {
+		w.Header().Set("Content-Type", "application/x-cue")
+	} else {
+		w.Header().Set("Content-Type", "audio/mpeg")
+	}
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	http.ServeFile(w, r, path)
+}
+
+// handleAdminArchive rebuilds the archive for ?date=YYYY-MM-DD (default
+// yesterday) on demand.
+func (s *server) handleAdminArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	day := time.Now().In(s.tz).AddDate(0, 0, -1)
+	if raw := strings.TrimSpace(r.URL.Query().Get("date")); raw != "" {
+		parsed, err := time.ParseInLocation(archiveDateLayout, raw, s.tz)
+		if err != nil {
+			http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		day = parsed
+	}
+	if err := s.buildDailyArchive(r.Context(), day); err != nil {
+		log.Printf("archive rebuild failed: %v", err)
+		http.Error(w, "archive failed", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"date": day.Format(archiveDateLayout), "status": "built"})
+}
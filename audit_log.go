@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// auditBodyLimit is how much of a request body the audit log reads to
+	// summarize it; larger bodies are summarized from the first part.
+	auditBodyLimit    = 64 << 10
+	auditSummaryMax   = 2000
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditSensitiveKeys are JSON keys whose values never reach the audit log.
+var auditSensitiveKeys = []string{"secret", "password", "token", "api_key", "auth"}
+
+type auditEntry struct {
+	ID        int64           `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor"`
+	IP        string          `json:"ip"`
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target,omitempty"`
+	Status    int             `json:"status"`
+	Summary   string          `json:"summary,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+}
+
+// auditNote is filled in by handlers that know more about an action than the
+// request line: a readable action name, what it acted on and the state before
+// and after.
+type auditNote struct {
+	action, target string
+	before, after  interface{}
+	noted          bool
+	// summary replaces the request summary when set.
+	summary *string
+}
+
+type auditNoteKey struct{}
+
+func migrateAddAuditLog(db *sql.DB) error {
+	if _, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,
+    actor TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target TEXT,
+    status INTEGER NOT NULL,
+    summary TEXT,
+    before_json TEXT,
+    after_json TEXT
+);`); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action)`,
+	} {
+		if _, err := execWithRetry(db, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noteAudit describes the admin action being served by r. Before and after
+// are stored as JSON; pass redacted values. Reads (GET) are only audited when
+// their handler calls noteAudit.
+func noteAudit(r *http.Request, action, target string, before, after interface{}) {
+	note, ok := r.Context().Value(auditNoteKey{}).(*auditNote)
+	if !ok {
+		return
+	}
+	note.action, note.target, note.before, note.after, note.noted = action, target, before, after, true
+}
+
+// noteAuditSummary replaces the query and body summary of the audit entry
+// for r, for requests whose parameters must not be kept.
+func noteAuditSummary(r *http.Request, summary string) {
+	if note, ok := r.Context().Value(auditNoteKey{}).(*auditNote); ok {
+		note.summary = &summary
+	}
+}
+
+type auditStatusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *auditStatusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *auditStatusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditStatusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSocket handlers take over the connection of an admin
+// request.
+func (w *auditStatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection cannot be hijacked")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// withAudit records every request that carries a valid admin token and
+// changes something (any method but GET, HEAD and OPTIONS), along with the
+// response status and a redacted summary of the body. Handlers add detail
+// with noteAudit. Recording is best effort and never fails the request.
+func (s *server) withAudit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdminRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var body []byte
+		if r.Body != nil && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, auditBodyLimit))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		}
+		note := &auditNote{}
+		r = r.WithContext(context.WithValue(r.Context(), auditNoteKey{}, note))
+		sw := &auditStatusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if !note.noted {
+				return
+			}
+		}
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		entry := auditEntry{
+			CreatedAt: time.Now().UTC(),
+			Actor:     settingsActor(r),
+			IP:        clientIP(r),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Action:    note.action,
+			Target:    note.target,
+			Status:    sw.status,
+			Summary:   auditSummary(r.URL.RawQuery, body),
+			Before:    auditJSON(note.before),
+			After:     auditJSON(note.after),
+		}
+		if note.summary != nil {
+			entry.Summary = *note.summary
+		}
+		if entry.Action == "" {
+			entry.Action = r.Method + " " + r.URL.Path
+		}
+		s.writeAudit(entry)
+	})
+}
+
+func (s *server) writeAudit(e auditEntry) {
+	if _, err := execWithRetry(s.db, `INSERT INTO audit_log (created_at, actor, ip, method, path, action, target, status, summary, before_json, after_json) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.CreatedAt, e.Actor, e.IP, e.Method, e.Path, e.Action, nullableString(e.Target), e.Status, nullableString(e.Summary), nullableString(string(e.Before)), nullableString(string(e.After))); err != nil {
+		log.Printf("audit log write failed for %s %s: %v", e.Method, e.Path, err)
+	}
+}
+
+// auditSummary renders the query string and body of an admin request with
+// sensitive JSON fields masked, cut to auditSummaryMax characters.
+func auditSummary(rawQuery string, body []byte) string {
+	var parts []string
+	if rawQuery != "" {
+		parts = append(parts, "?"+rawQuery)
+	}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 {
+		var v interface{}
+		if err := json.Unmarshal(trimmed, &v); err == nil {
+			redacted, _ := json.Marshal(redactAuditValue(v))
+			parts = append(parts, string(redacted))
+		} else {
+			parts = append(parts, strconv.Itoa(len(body))+" bytes "+http.DetectContentType(body))
+		}
+	}
+	summary := strings.Join(parts, " ")
+	if len([]rune(summary)) > auditSummaryMax {
+		summary = truncateText(summary, auditSummaryMax)
+	}
+	return summary
+}
+
+func redactAuditValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, inner := range val {
+			if auditSensitive(k) {
+				if inner != nil && inner != "" {
+					val[k] = "[redacted]"
+				}
+				continue
+			}
+			val[k] = redactAuditValue(inner)
+		}
+	case []interface{}:
+		for i := range val {
+			val[i] = redactAuditValue(val[i])
+		}
+	}
+	return v
+}
+
+func auditSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasPrefix(key, "has_") {
+		return false
+	}
+	for _, s := range auditSensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func auditJSON(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	var generic interface{}
+	if json.Unmarshal(data, &generic) == nil {
+		data, _ = json.Marshal(redactAuditValue(generic))
+	}
+	return data
+}
+
+// settingsAuditState splits a settings diff into the old and new values of
+// the fields that changed.
+func settingsAuditState(prev, next AppSettings) (map[string]interface{}, map[string]interface{}) {
+	before, after := map[string]interface{}{}, map[string]interface{}{}
+	for _, c := range diffSettings(prev, next) {
+		before[c.Field] = c.Old
+		after[c.Field] = c.New
+	}
+	return before, after
+}
+
+// callAuditState is the part of a call an admin action can change, recorded
+// as its before state.
+func callAuditState(t transcription) map[string]interface{} {
+	return map[string]interface{}{
+		"status":         t.Status,
+		"error_code":     t.ErrorCode,
+		"call_type":      t.CallType,
+		"location_label": t.LocationLabel,
+		"latitude":       t.Latitude,
+		"longitude":      t.Longitude,
+	}
+}
+
+// handleAuditLog lists audited admin actions, newest first:
+//
+//	GET /api/admin/audit[?actor=][&action=][&target=][&path=][&q=][&since=][&until=][&limit=N]
+//
+// actor, action and target match exactly; path matches as a prefix; q
+// searches the action, target and summary. since and until are RFC 3339.
+func (s *server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	limit := defaultAuditLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxAuditLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	var where []string
+	var args []interface{}
+	for _, f := range []struct{ param, column string }{{"actor", "actor"}, {"action", "action"}, {"target", "target"}, {"method", "method"}} {
+		if v := strings.TrimSpace(q.Get(f.param)); v != "" {
+			where = append(where, f.column+" = ?")
+			args = append(args, v)
+		}
+	}
+	if v := strings.TrimSpace(q.Get("path")); v != "" {
+		where = append(where, "path LIKE ?")
+		args = append(args, v+"%")
+	}
+	if v := strings.TrimSpace(q.Get("q")); v != "" {
+		where = append(where, "(lower(action) LIKE ? OR lower(coalesce(target, '')) LIKE ? OR lower(coalesce(summary, '')) LIKE ?)")
+		pattern := "%" + strings.ToLower(v) + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+	for _, f := range []struct{ param, op string }{{"since", ">="}, {"until", "<"}} {
+		raw := strings.TrimSpace(q.Get(f.param))
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, f.param+" must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		where = append(where, "created_at "+f.op+" ?")
+		args = append(args, t.UTC())
+	}
+	query := `SELECT id, created_at, actor, ip, method, path, action, COALESCE(target, ''), status, COALESCE(summary, ''), COALESCE(before_json, ''), COALESCE(after_json, '') FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		log.Printf("audit log query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	entries := []auditEntry{}
+	for rows.Next() {
+		var e auditEntry
+		var before, after string
+		if err := rows.Scan(&e.ID, &e.CreatedAt, &e.Actor, &e.IP, &e.Method, &e.Path, &e.Action, &e.Target, &e.Status, &e.Summary, &before, &after); err != nil {
+			log.Printf("audit log scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if before != "" {
+			e.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			e.After = json.RawMessage(after)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("audit log query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"entries": entries})
+}
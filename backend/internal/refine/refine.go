@@ -19,6 +19,7 @@ import (
 
 	"alert_framework/config"
 	"alert_framework/formatting"
+	"alert_framework/usage"
 )
 
 const (
@@ -29,6 +30,7 @@ const (
 type Service struct {
 	client      *http.Client
 	openAIKey   string
+	llm         config.LLMStageConfig
 	mapboxToken string
 	templates   *TemplateManager
 }
@@ -38,6 +40,24 @@ type Request struct {
 	Transcript      string
 	Metadata        formatting.CallMetadata
 	RecognizedTowns []string
+	// Prompts replaces the configured templates for this request only.
+	Prompts Prompts
+}
+
+// Prompts overrides the cleanup, metadata and address prompts. Empty fields
+// keep the configured template.
+type Prompts struct {
+	Cleanup  string `json:"cleanup,omitempty"`
+	Metadata string `json:"metadata,omitempty"`
+	Address  string `json:"address,omitempty"`
+}
+
+// Stages holds the raw JSON each model pass replied with, for debugging
+// prompt edits.
+type Stages struct {
+	Cleanup  json.RawMessage `json:"cleanup,omitempty"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	Address  json.RawMessage `json:"address,omitempty"`
 }
 
 // Result bundles every structured artifact produced by the refinement pipeline.
@@ -49,6 +69,7 @@ type Result struct {
 	Address           Address
 	RecognizedTowns   []string
 	NeedsManualReview bool
+	Stages            Stages
 }
 
 // Metadata matches the JSON object stored in SQLite and served to the UI.
@@ -110,11 +131,18 @@ func NewService(client *http.Client, cfg config.Config) (*Service, error) {
 	return &Service{
 		client:      client,
 		openAIKey:   key,
+		llm:         cfg.LLM.Refine,
 		mapboxToken: strings.TrimSpace(cfg.MapboxToken),
 		templates:   tm,
 	}, nil
 }
 
+// SetTemplates replaces the NLP prompts, for a config reload. A later change
+// to the config file still replaces them again.
+func (s *Service) SetTemplates(cfg config.NLPConfig) {
+	s.templates.set(cfg)
+}
+
 // Close releases any resources a service holds.
 func (s *Service) Close() error {
 	if s.templates != nil {
@@ -123,26 +151,40 @@ func (s *Service) Close() error {
 	return nil
 }
 
-// Refine executes the full GPT + Mapbox workflow.
+// Refine executes the full GPT + Mapbox workflow. On failure the returned
+// Result carries only the Stages that completed.
 func (s *Service) Refine(ctx context.Context, req Request) (Result, error) {
 	cfg := s.templates.Current()
+	if p := strings.TrimSpace(req.Prompts.Cleanup); p != "" {
+		cfg.CleanupPrompt = p
+	}
+	if p := strings.TrimSpace(req.Prompts.Metadata); p != "" {
+		cfg.MetadataPrompt = p
+	}
+	if p := strings.TrimSpace(req.Prompts.Address); p != "" {
+		cfg.AddressPrompt = p
+	}
 	if strings.TrimSpace(req.Transcript) == "" {
 		return Result{}, errors.New("empty transcript for refinement")
 	}
 
-	cleanResp, err := s.runCleanup(ctx, cfg, req)
+	var stages Stages
+	cleanResp, raw, err := s.runCleanup(ctx, cfg, req)
+	stages.Cleanup = raw
 	if err != nil {
-		return Result{}, err
+		return Result{Stages: stages}, err
 	}
 
-	metaResp, err := s.runMetadata(ctx, cfg, req, cleanResp)
+	metaResp, raw, err := s.runMetadata(ctx, cfg, req, cleanResp)
+	stages.Metadata = raw
 	if err != nil {
-		return Result{}, err
+		return Result{Stages: stages}, err
 	}
 
-	addressResp, err := s.runAddress(ctx, cfg, req, cleanResp)
+	addressResp, raw, err := s.runAddress(ctx, cfg, req, cleanResp)
+	stages.Address = raw
 	if err != nil {
-		return Result{}, err
+		return Result{Stages: stages}, err
 	}
 
 	var recognized []string
@@ -174,42 +216,47 @@ func (s *Service) Refine(ctx context.Context, req Request) (Result, error) {
 		Address:           mergedAddress,
 		RecognizedTowns:   recognized,
 		NeedsManualReview: metadata.NeedsManualReview,
+		Stages:            stages,
 	}, nil
 }
 
-func (s *Service) runCleanup(ctx context.Context, cfg config.NLPConfig, req Request) (cleanupPayload, error) {
+func (s *Service) runCleanup(ctx context.Context, cfg config.NLPConfig, req Request) (cleanupPayload, json.RawMessage, error) {
 	system := buildCleanupPrompt(cfg)
 	user := buildCleanupUserContent(req)
 	var parsed cleanupPayload
-	if err := s.callJSON(ctx, system, user, &parsed, cfg); err != nil {
-		return cleanupPayload{}, err
+	raw, err := s.callJSON(ctx, system, user, &parsed, cfg)
+	if err != nil {
+		return cleanupPayload{}, raw, err
 	}
-	return parsed, nil
+	return parsed, raw, nil
 }
 
-func (s *Service) runMetadata(ctx context.Context, cfg config.NLPConfig, req Request, cleanup cleanupPayload) (metadataPayload, error) {
+func (s *Service) runMetadata(ctx context.Context, cfg config.NLPConfig, req Request, cleanup cleanupPayload) (metadataPayload, json.RawMessage, error) {
 	system := buildMetadataPrompt(cfg)
 	user := buildMetadataUserContent(req, cleanup)
 	var parsed metadataPayload
-	if err := s.callJSON(ctx, system, user, &parsed, cfg); err != nil {
-		return metadataPayload{}, err
+	raw, err := s.callJSON(ctx, system, user, &parsed, cfg)
+	if err != nil {
+		return metadataPayload{}, raw, err
 	}
-	return parsed, nil
+	return parsed, raw, nil
 }
 
-func (s *Service) runAddress(ctx context.Context, cfg config.NLPConfig, req Request, cleanup cleanupPayload) (addressPayload, error) {
+func (s *Service) runAddress(ctx context.Context, cfg config.NLPConfig, req Request, cleanup cleanupPayload) (addressPayload, json.RawMessage, error) {
 	system := buildAddressPrompt(cfg)
 	user := buildAddressUserContent(req, cleanup)
 	var parsed addressPayload
-	if err := s.callJSON(ctx, system, user, &parsed, cfg); err != nil {
-		return addressPayload{}, err
+	raw, err := s.callJSON(ctx, system, user, &parsed, cfg)
+	if err != nil {
+		return addressPayload{}, raw, err
 	}
-	return parsed, nil
+	return parsed, raw, nil
 }
 
-func (s *Service) callJSON(ctx context.Context, system, user string, target interface{}, cfg config.NLPConfig) error {
+func (s *Service) callJSON(ctx context.Context, system, user string, target interface{}, cfg config.NLPConfig) (json.RawMessage, error) {
+	model := s.llm.ModelOr(cleanupModel)
 	payload := map[string]interface{}{
-		"model":           cleanupModel,
+		"model":           model,
 		"temperature":     cfg.RefinementTemperature,
 		"response_format": map[string]string{"type": "json_object"},
 		"messages": []map[string]string{
@@ -218,20 +265,20 @@ func (s *Service) callJSON(ctx context.Context, system, user string, target inte
 		},
 	}
 	buf, _ := json.Marshal(payload)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/chat/completions", bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(usage.WithStage(ctx, usage.StageRefine), http.MethodPost, s.llm.URL("/v1/chat/completions"), bytes.NewReader(buf))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+s.openAIKey)
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("gpt-5.1 status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("%s status %d: %s", model, resp.StatusCode, string(body))
 	}
 	var wrapper struct {
 		Choices []struct {
@@ -241,16 +288,22 @@ func (s *Service) callJSON(ctx context.Context, system, user string, target inte
 		} `json:"choices"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&wrapper); err != nil {
-		return err
+		return nil, err
 	}
 	if len(wrapper.Choices) == 0 {
-		return errors.New("empty GPT-5.1 response")
+		return nil, errors.New("empty GPT-5.1 response")
 	}
 	content := strings.TrimSpace(wrapper.Choices[0].Message.Content)
 	if content == "" {
-		return errors.New("GPT-5.1 returned empty content")
+		return nil, errors.New("GPT-5.1 returned empty content")
 	}
-	return json.Unmarshal([]byte(content), target)
+	// The reply is returned even when it does not parse, so a bad prompt can
+	// be diagnosed from what the model actually said.
+	raw := json.RawMessage(content)
+	if !json.Valid(raw) {
+		raw, _ = json.Marshal(content)
+	}
+	return raw, json.Unmarshal([]byte(content), target)
 }
 
 func (s *Service) mergeAddressData(ctx context.Context, cfg config.NLPConfig, req Request, llm addressPayload) (Address, bool, float64) {
@@ -321,6 +374,13 @@ func (tm *TemplateManager) Current() config.NLPConfig {
 	return tm.cfg
 }
 
+func (tm *TemplateManager) set(cfg config.NLPConfig) {
+	tm.mu.Lock()
+	tm.cfg = cfg
+	tm.lastLoad = time.Now()
+	tm.mu.Unlock()
+}
+
 // Close is a placeholder for future watchers.
 func (tm *TemplateManager) Close() error { return nil }
 
@@ -2,6 +2,10 @@ package refine
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"alert_framework/config"
@@ -63,3 +67,44 @@ func TestMergeAddressDataFlagsOutOfCounty(t *testing.T) {
 		t.Fatalf("expected confidence capped for out-of-county, got %f", conf)
 	}
 }
+
+func TestRefinePromptOverridesAndStages(t *testing.T) {
+	var systems []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		system := body.Messages[0].Content
+		systems = append(systems, system)
+		reply := `{"clean_transcript":"Engine 5 respond to 12 Main Street","incident_type":"Fire"}`
+		if strings.HasPrefix(system, "ADDRESS") {
+			reply = "not json"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": reply}}},
+		})
+	}))
+	defer srv.Close()
+
+	tm, _ := NewTemplateManager("", config.DefaultNLPConfig())
+	svc := &Service{client: srv.Client(), llm: config.LLMStageConfig{BaseURL: srv.URL}, templates: tm}
+	res, err := svc.Refine(context.Background(), Request{
+		Transcript: "engine five respond twelve main",
+		Prompts:    Prompts{Cleanup: "CLEANUP", Address: "ADDRESS"},
+	})
+	if err == nil {
+		t.Fatalf("expected address stage parse error")
+	}
+	if len(systems) != 3 || !strings.HasPrefix(systems[0], "CLEANUP") || strings.HasPrefix(systems[1], "CLEANUP") {
+		t.Fatalf("unexpected system prompts: %q", systems)
+	}
+	if !strings.Contains(string(res.Stages.Cleanup), "Main Street") || res.Stages.Metadata == nil {
+		t.Fatalf("expected completed stages, got %+v", res.Stages)
+	}
+	if string(res.Stages.Address) != `"not json"` {
+		t.Fatalf("expected unparsed address reply quoted, got %s", res.Stages.Address)
+	}
+}
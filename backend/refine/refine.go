@@ -17,6 +17,12 @@ type Service = refine.Service
 // Request re-exports the internal Request type.
 type Request = refine.Request
 
+// Prompts re-exports the internal Prompts type.
+type Prompts = refine.Prompts
+
+// Stages re-exports the internal Stages type.
+type Stages = refine.Stages
+
 // NewService re-exports the internal constructor.
 func NewService(client *http.Client, cfg config.Config) (*refine.Service, error) {
 	return refine.NewService(client, cfg)
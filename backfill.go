@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"alert_framework/errcode"
+)
+
+const (
+	opsJobKindBackfill = "backfill"
+
+	// backfillIdleWait is how often a backfill waiting for the queue to
+	// empty checks again.
+	backfillIdleWait = 2 * time.Second
+)
+
+// Why a file in CALLS_DIR is picked up by a backfill.
+const (
+	backfillNew   = "new"
+	backfillError = "error"
+	backfillStale = "stale"
+)
+
+// backfillNoRetry are error codes a backfill leaves alone: retrying them
+// fails the same way.
+var backfillNoRetry = map[string]bool{
+	errcode.InvalidAudio: true,
+	errcode.FileTooLarge: true,
+}
+
+type backfillRequest struct {
+	Window      string `json:"window"`
+	RetryErrors *bool  `json:"retry_errors"`
+	Limit       int    `json:"limit"`
+	DryRun      bool   `json:"dry_run"`
+}
+
+// backfillParams is persisted with the job; a resumed run scans CALLS_DIR
+// again with them, and files it already queued no longer qualify.
+type backfillParams struct {
+	Since       *time.Time `json:"since,omitempty"`
+	RetryErrors bool       `json:"retry_errors"`
+	Limit       int        `json:"limit,omitempty"`
+}
+
+type backfillTarget struct {
+	filename string
+	reason   string
+}
+
+type backfillEstimate struct {
+	DryRun  bool           `json:"dry_run"`
+	Scanned int            `json:"scanned"`
+	Count   int            `json:"count"`
+	Reasons map[string]int `json:"reasons"`
+}
+
+// handleOpsBackfill scans CALLS_DIR for recordings that never finished, and
+// estimates (dry_run) or starts a job that queues them for transcription.
+func (s *server) handleOpsBackfill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req backfillRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Limit < 0 {
+		http.Error(w, "limit must not be negative", http.StatusBadRequest)
+		return
+	}
+	params := backfillParams{RetryErrors: req.RetryErrors == nil || *req.RetryErrors, Limit: req.Limit}
+	if _, window := normalizeWindowName(req.Window, "all"); window > 0 {
+		since := time.Now().UTC().Add(-window)
+		params.Since = &since
+	}
+	scanned, targets, err := s.scanBackfill(r.Context(), params)
+	if err != nil {
+		log.Printf("backfill scan failed: %v", err)
+		http.Error(w, "scan failed", http.StatusInternalServerError)
+		return
+	}
+	estimate := backfillEstimate{Scanned: scanned, Count: len(targets), Reasons: map[string]int{}}
+	for _, t := range targets {
+		estimate.Reasons[t.reason]++
+	}
+	if req.DryRun {
+		estimate.DryRun = true
+		respondJSON(w, estimate)
+		return
+	}
+	if s.queue == nil {
+		http.Error(w, "queue disabled", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := s.createOpsJob(opsJobKindBackfill, params, len(targets))
+	if err != nil {
+		log.Printf("backfill create failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	noteAudit(r, "calls.backfill", strconv.FormatInt(id, 10), nil, map[string]interface{}{"params": params, "count": len(targets)})
+	go s.runBackfill(s.ctx, id, params, targets)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	respondJSON(w, map[string]interface{}{
+		"job_id":     id,
+		"estimate":   estimate,
+		"status_url": fmt.Sprintf("/ops/jobs/%d", id),
+		"stream_url": fmt.Sprintf("/ops/jobs/%d/stream", id),
+	})
+}
+
+// scanBackfill lists the recordings in CALLS_DIR the watcher would have
+// queued but that never finished, oldest name first: files with no call,
+// failed calls unless their error would recur, and calls left queued or
+// processing for longer than processingStaleAfter by a worker that is gone.
+// Completed, duplicate and deleted calls are skipped, as are files modified
+// before p.Since.
+func (s *server) scanBackfill(ctx context.Context, p backfillParams) (int, []backfillTarget, error) {
+	files, err := s.callsDirAudio(p.Since)
+	if err != nil {
+		return 0, nil, err
+	}
+	staleBefore := time.Now().UTC().Add(-processingStaleAfter)
+	var targets []backfillTarget
+	for _, f := range files {
+		name := f.Name()
+		if ctx.Err() != nil {
+			return 0, nil, ctx.Err()
+		}
+		if _, busy := s.running.Load(name); busy {
+			continue
+		}
+		t, err := s.getTranscription(name)
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, alias := s.aliasOf(name); !alias {
+				targets = append(targets, backfillTarget{filename: name, reason: backfillNew})
+			}
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if t.DeletedAt != nil || (t.DuplicateOf != nil && *t.DuplicateOf != "") {
+			continue
+		}
+		switch t.Status {
+		case statusError:
+			if p.RetryErrors && !backfillNoRetry[derefString(t.ErrorCode, "")] {
+				targets = append(targets, backfillTarget{filename: name, reason: backfillError})
+			}
+		case statusQueued, statusProcessing:
+			if t.UpdatedAt.Before(staleBefore) {
+				targets = append(targets, backfillTarget{filename: name, reason: backfillStale})
+			}
+		}
+	}
+	if p.Limit > 0 && len(targets) > p.Limit {
+		targets = targets[:p.Limit]
+	}
+	return len(files), targets, nil
+}
+
+// runBackfill queues targets one at a time, and only while nothing else is
+// waiting in the queue, so calls arriving live are never stuck behind a
+// backfill. Progress is checkpointed after each file.
+func (s *server) runBackfill(ctx context.Context, jobID int64, p backfillParams, targets []backfillTarget) {
+	var processed, failed int
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&processed, &failed)
+	}, `SELECT processed, failed FROM ops_jobs WHERE id = ?`, jobID); err != nil {
+		log.Printf("ops job %d load failed: %v", jobID, err)
+		return
+	}
+	opts, _ := s.defaultOptions()
+	var lastErr string
+	for _, t := range targets {
+		if !s.waitForIdleQueue(ctx) {
+			log.Printf("ops job %d interrupted; will resume on restart", jobID)
+			return
+		}
+		// A stale call still shows as queued or processing, which only a
+		// forced enqueue gets past.
+		if enqueued, _ := s.enqueueWithBackoff(ctx, "backfill", t.filename, false, t.reason == backfillStale, opts); enqueued {
+			processed++
+		} else {
+			failed++
+			lastErr = fmt.Sprintf("%s: not queued", t.filename)
+			log.Printf("backfill job %d: %s", jobID, lastErr)
+		}
+		if _, err := execWithRetry(s.db, `UPDATE ops_jobs SET processed = ?, failed = ?, last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, processed, failed, nullableString(lastErr), jobID); err != nil {
+			log.Printf("ops job %d checkpoint failed: %v", jobID, err)
+		}
+	}
+	s.finishOpsJob(jobID, opsJobCompleted, lastErr)
+}
+
+// waitForIdleQueue blocks until the queue is running with no job waiting. It
+// returns false once the service is stopping.
+func (s *server) waitForIdleQueue(ctx context.Context) bool {
+	for {
+		if stats := s.queue.Stats(); !stats.Paused && stats.Length == 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-s.shutdown:
+			return false
+		case <-time.After(backfillIdleWait):
+		}
+	}
+}
+
+// resumeBackfill restarts a backfill left running by a previous process. Its
+// total is updated to what the new scan found on top of what is done.
+func (s *server) resumeBackfill(ctx context.Context, jobID int64, p backfillParams) {
+	_, targets, err := s.scanBackfill(ctx, p)
+	if err != nil {
+		s.finishOpsJob(jobID, opsJobFailed, err.Error())
+		return
+	}
+	if p.Limit > 0 {
+		var done int
+		if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+			return row.Scan(&done)
+		}, `SELECT processed + failed FROM ops_jobs WHERE id = ?`, jobID); err != nil {
+			log.Printf("ops job %d load failed: %v", jobID, err)
+			return
+		}
+		if remaining := p.Limit - done; remaining < len(targets) {
+			targets = targets[:max(remaining, 0)]
+		}
+	}
+	if _, err := execWithRetry(s.db, `UPDATE ops_jobs SET total = processed + failed + ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, len(targets), jobID); err != nil {
+		log.Printf("ops job %d checkpoint failed: %v", jobID, err)
+	}
+	s.runBackfill(ctx, jobID, p, targets)
+}
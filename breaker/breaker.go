@@ -0,0 +1,148 @@
+// Package breaker implements a small consecutive-failure circuit breaker used to
+// detect upstream outages (OpenAI) and pause dependent work until recovery.
+package breaker
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// State is the breaker position.
+type State string
+
+const (
+	// Closed allows calls through and counts consecutive failures.
+	Closed State = "closed"
+	// Open rejects calls until a probe succeeds.
+	Open State = "open"
+	// HalfOpen allows a single probe to decide whether to close again.
+	HalfOpen State = "half_open"
+)
+
+// Snapshot is a read-only view of the breaker.
+type Snapshot struct {
+	State               State
+	ConsecutiveFailures int
+	Threshold           int
+	OpenedAt            time.Time
+	LastError           string
+	Trips               int64
+}
+
+// Breaker trips after a run of consecutive failures and recovers via probes.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     State
+	failures  int
+	openedAt  time.Time
+	lastProbe time.Time
+	lastErr   string
+	trips     int64
+	onChange  func(from, to State)
+	now       func() time.Time
+}
+
+// New creates a closed breaker. onChange, when set, is invoked outside the lock
+// whenever the state changes.
+func New(threshold int, cooldown time.Duration, onChange func(from, to State)) *Breaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown, state: Closed, onChange: onChange, now: time.Now}
+}
+
+// Allow reports whether regular calls may proceed.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == Closed
+}
+
+// Cooldown returns the delay between recovery probes.
+func (b *Breaker) Cooldown() time.Duration {
+	return b.cooldown
+}
+
+// RecordSuccess resets the failure count and closes the breaker.
+func (b *Breaker) RecordSuccess() {
+	b.mu.Lock()
+	from := b.state
+	b.failures = 0
+	b.lastErr = ""
+	b.state = Closed
+	b.openedAt = time.Time{}
+	b.mu.Unlock()
+	b.notify(from, Closed)
+}
+
+// RecordFailure counts a failure and opens the breaker once the threshold is hit.
+// A failed half-open probe reopens the breaker immediately.
+func (b *Breaker) RecordFailure(err error) {
+	b.mu.Lock()
+	from := b.state
+	b.failures++
+	if err != nil {
+		b.lastErr = truncate(err.Error())
+	}
+	if b.state == HalfOpen || (b.state == Closed && b.failures >= b.threshold) {
+		b.state = Open
+		b.openedAt = b.now()
+		b.lastProbe = b.openedAt
+		if from == Closed {
+			b.trips++
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+// TryProbe moves an open breaker to half-open once the cooldown has elapsed.
+// It returns true when the caller should run a recovery probe.
+func (b *Breaker) TryProbe() bool {
+	b.mu.Lock()
+	if b.state != Open || b.now().Sub(b.lastProbe) < b.cooldown {
+		b.mu.Unlock()
+		return false
+	}
+	b.state = HalfOpen
+	b.lastProbe = b.now()
+	b.mu.Unlock()
+	b.notify(Open, HalfOpen)
+	return true
+}
+
+// Snapshot returns the current breaker view.
+func (b *Breaker) Snapshot() Snapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Snapshot{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Threshold:           b.threshold,
+		OpenedAt:            b.openedAt,
+		LastError:           b.lastErr,
+		Trips:               b.trips,
+	}
+}
+
+func (b *Breaker) notify(from, to State) {
+	if from == to || b.onChange == nil {
+		return
+	}
+	b.onChange(from, to)
+}
+
+func truncate(msg string) string {
+	msg = strings.TrimSpace(msg)
+	if len(msg) > 240 {
+		return msg[:240]
+	}
+	return msg
+}
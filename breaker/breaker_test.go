@@ -0,0 +1,72 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerTripsAfterThreshold(t *testing.T) {
+	var transitions []State
+	b := New(3, time.Minute, func(from, to State) { transitions = append(transitions, to) })
+
+	b.RecordFailure(errors.New("openai status 503"))
+	b.RecordFailure(errors.New("openai status 503"))
+	if !b.Allow() {
+		t.Fatalf("expected breaker to stay closed below threshold")
+	}
+	b.RecordFailure(errors.New("openai status 503"))
+	if b.Allow() {
+		t.Fatalf("expected breaker to open at threshold")
+	}
+	snap := b.Snapshot()
+	if snap.State != Open || snap.Trips != 1 || snap.LastError == "" {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+	if len(transitions) != 1 || transitions[0] != Open {
+		t.Fatalf("expected single open transition, got %v", transitions)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := New(2, time.Minute, nil)
+	b.RecordFailure(errors.New("timeout"))
+	b.RecordSuccess()
+	b.RecordFailure(errors.New("timeout"))
+	if !b.Allow() {
+		t.Fatalf("expected success to reset consecutive failures")
+	}
+}
+
+func TestBreakerProbeCycle(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := New(1, time.Minute, nil)
+	b.now = func() time.Time { return now }
+
+	b.RecordFailure(errors.New("down"))
+	if b.TryProbe() {
+		t.Fatalf("expected no probe before cooldown")
+	}
+	now = now.Add(time.Minute)
+	if !b.TryProbe() {
+		t.Fatalf("expected probe after cooldown")
+	}
+	if b.Snapshot().State != HalfOpen {
+		t.Fatalf("expected half-open during probe")
+	}
+	b.RecordFailure(errors.New("still down"))
+	if b.Snapshot().State != Open {
+		t.Fatalf("expected failed probe to reopen")
+	}
+	now = now.Add(time.Minute)
+	if !b.TryProbe() {
+		t.Fatalf("expected second probe after cooldown")
+	}
+	b.RecordSuccess()
+	if !b.Allow() {
+		t.Fatalf("expected successful probe to close breaker")
+	}
+	if trips := b.Snapshot().Trips; trips != 1 {
+		t.Fatalf("expected reopen after probe not to count as new trip, got %d", trips)
+	}
+}
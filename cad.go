@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+)
+
+const (
+	ingestSourceCAD   = "cad"
+	cadFilenamePrefix = "cad-"
+	maxCADBodyBytes   = 1 << 20
+)
+
+// statusCAD marks transcription rows created from a CAD feed that have no
+// audio yet. They are replaced by the audio row once a recording correlates.
+const statusCAD = "cad"
+
+var cadFilenameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// cadIncident is one incident pushed by a third-party CAD feed, after the
+// configured field mapping has been applied.
+type cadIncident struct {
+	ExternalID string
+	At         time.Time
+	Talkgroup  string
+	Address    string
+	Town       string
+	CallType   string
+	Units      []string
+	Latitude   *float64
+	Longitude  *float64
+	Raw        string
+}
+
+type cadIngestResult struct {
+	ID         int64  `json:"id"`
+	ExternalID string `json:"external_id"`
+	Filename   string `json:"filename"`
+	Matched    bool   `json:"matched_audio"`
+}
+
+// handleCADIngest accepts one CAD incident object or an array of them,
+// authenticated with CAD_INGEST_TOKEN. The endpoint is disabled when no token
+// is configured.
+func (s *server) handleCADIngest(w http.ResponseWriter, r *http.Request) {
+	token := s.cfg.CADIngestToken
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-CAD-Token")), []byte(token)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCADBodyBytes+1))
+	if err != nil || len(body) > maxCADBodyBytes {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	objects, err := decodeCADObjects(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	incidents := make([]cadIncident, 0, len(objects))
+	for i, obj := range objects {
+		inc, err := mapCADIncident(obj, s.cfg.CADFieldMap, s.tz)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("incident %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		incidents = append(incidents, inc)
+	}
+	results := make([]cadIngestResult, 0, len(incidents))
+	for _, inc := range incidents {
+		res, err := s.ingestCADIncident(r.Context(), inc)
+		if err != nil {
+			log.Printf("cad ingest %s failed: %v", inc.ExternalID, err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		results = append(results, res)
+	}
+	respondJSON(w, map[string]interface{}{"incidents": results})
+}
+
+func decodeCADObjects(body []byte) ([]map[string]interface{}, error) {
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		var list []map[string]interface{}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+		return list, nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	return []map[string]interface{}{obj}, nil
+}
+
+// mapCADIncident applies fields (incident field -> dotted payload path) to a
+// decoded CAD object. Only the id is required; a missing time means now.
+func mapCADIncident(obj map[string]interface{}, fields map[string]string, tz *time.Location) (cadIncident, error) {
+	get := func(name string) interface{} { return lookupPath(obj, fields[name]) }
+	inc := cadIncident{
+		ExternalID: cadString(get("id")),
+		Talkgroup:  cadString(get("talkgroup")),
+		Address:    cadString(get("address")),
+		Town:       cadString(get("town")),
+		CallType:   cadString(get("call_type")),
+		Units:      cadStrings(get("units")),
+		Latitude:   cadFloat(get("latitude")),
+		Longitude:  cadFloat(get("longitude")),
+	}
+	if inc.ExternalID == "" {
+		return inc, fmt.Errorf("missing %q", fields["id"])
+	}
+	at, err := cadTime(get("time"), tz)
+	if err != nil {
+		return inc, fmt.Errorf("invalid %q: %w", fields["time"], err)
+	}
+	inc.At = at
+	if inc.Latitude == nil || inc.Longitude == nil {
+		inc.Latitude, inc.Longitude = nil, nil
+	}
+	raw, _ := json.Marshal(obj)
+	inc.Raw = string(raw)
+	return inc, nil
+}
+
+func lookupPath(obj map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	var cur interface{} = obj
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+func cadString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strings.TrimSpace(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	return ""
+}
+
+func cadStrings(v interface{}) []string {
+	var out []string
+	switch val := v.(type) {
+	case []interface{}:
+		for _, item := range val {
+			if s := cadString(item); s != "" {
+				out = append(out, s)
+			}
+		}
+	case string:
+		for _, part := range strings.Split(val, ",") {
+			if s := strings.TrimSpace(part); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+func cadFloat(v interface{}) *float64 {
+	switch val := v.(type) {
+	case float64:
+		return &val
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			return &f
+		}
+	}
+	return nil
+}
+
+func cadTime(v interface{}, tz *time.Location) (time.Time, error) {
+	switch val := v.(type) {
+	case nil:
+		return time.Now().UTC(), nil
+	case float64:
+		return time.Unix(int64(val), 0).UTC(), nil
+	case string:
+		if val = strings.TrimSpace(val); val == "" {
+			return time.Now().UTC(), nil
+		}
+		if unix, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return time.Unix(unix, 0).UTC(), nil
+		}
+		ts, err := parseTimestampFlexible(val, tz)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return ts.UTC(), nil
+	}
+	return time.Time{}, errors.New("unsupported type")
+}
+
+// ingestCADIncident stores the incident, then enriches the audio row it is
+// linked to, a newly correlated audio row, or an audio-less placeholder row.
+func (s *server) ingestCADIncident(ctx context.Context, inc cadIncident) (cadIngestResult, error) {
+	units, _ := json.Marshal(inc.Units)
+	if len(inc.Units) == 0 {
+		units = nil
+	}
+	if _, err := execWithRetry(s.db, `INSERT INTO cad_incidents (external_id, talkgroup, incident_at, address, town, call_type, units, latitude, longitude, raw_json) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(external_id) DO UPDATE SET talkgroup=COALESCE(excluded.talkgroup, cad_incidents.talkgroup), incident_at=excluded.incident_at, address=COALESCE(excluded.address, cad_incidents.address), town=COALESCE(excluded.town, cad_incidents.town), call_type=COALESCE(excluded.call_type, cad_incidents.call_type), units=COALESCE(excluded.units, cad_incidents.units), latitude=COALESCE(excluded.latitude, cad_incidents.latitude), longitude=COALESCE(excluded.longitude, cad_incidents.longitude), raw_json=excluded.raw_json, updated_at=CURRENT_TIMESTAMP`,
+		inc.ExternalID, nullableString(inc.Talkgroup), inc.At, nullableString(inc.Address), nullableString(inc.Town), nullableString(inc.CallType), nullableString(string(units)), inc.Latitude, inc.Longitude, inc.Raw); err != nil {
+		return cadIngestResult{}, err
+	}
+	stored, linked, err := s.loadCADIncident(`external_id = ?`, inc.ExternalID)
+	if err != nil {
+		return cadIngestResult{}, err
+	}
+	res := cadIngestResult{ID: stored.id, ExternalID: inc.ExternalID, Filename: linked}
+
+	if linked == "" || strings.HasPrefix(linked, cadFilenamePrefix) {
+		if audio, err := s.findCADAudio(ctx, stored.cadIncident); err != nil {
+			return res, err
+		} else if audio != "" {
+			if err := s.linkCADAudio(stored, audio, linked); err != nil {
+				return res, err
+			}
+			res.Filename, res.Matched = audio, true
+			return res, nil
+		}
+	}
+	if linked == "" {
+		linked = cadFilenamePrefix + cadFilenameUnsafe.ReplaceAllString(inc.ExternalID, "_")
+		if _, err := execWithRetry(s.db, `INSERT INTO transcriptions (filename, source_path, processed_path, ingest_source, status, call_timestamp) VALUES (?, '', '', ?, ?, ?) ON CONFLICT(filename) DO NOTHING`, linked, ingestSourceCAD, statusCAD, stored.At); err != nil {
+			return res, err
+		}
+		if _, err := execWithRetry(s.db, `UPDATE cad_incidents SET filename = ? WHERE id = ?`, linked, stored.id); err != nil {
+			return res, err
+		}
+		res.Filename = linked
+	}
+	res.Matched = !strings.HasPrefix(linked, cadFilenamePrefix)
+	return res, s.applyCADIncident(linked, stored)
+}
+
+type storedCADIncident struct {
+	cadIncident
+	id int64
+}
+
+func (s *server) loadCADIncident(where string, args ...interface{}) (storedCADIncident, string, error) {
+	var inc storedCADIncident
+	var talkgroup, address, town, callType, units, filename sql.NullString
+	var lat, lon sql.NullFloat64
+	var at interface{}
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&inc.id, &inc.ExternalID, &talkgroup, &at, &address, &town, &callType, &units, &lat, &lon, &filename)
+	}, `SELECT id, external_id, talkgroup, incident_at, address, town, call_type, units, latitude, longitude, filename FROM cad_incidents WHERE `+where, args...)
+	if err != nil {
+		return inc, "", err
+	}
+	inc.Talkgroup, inc.Address, inc.Town, inc.CallType = talkgroup.String, address.String, town.String, callType.String
+	inc.Units = parseRecognizedTownList(&units.String)
+	if lat.Valid && lon.Valid {
+		inc.Latitude, inc.Longitude = &lat.Float64, &lon.Float64
+	}
+	switch v := at.(type) {
+	case time.Time:
+		inc.At = v.UTC()
+	case string:
+		if ts, err := parseTimestampFlexible(v, time.UTC); err == nil {
+			inc.At = ts.UTC()
+		}
+	}
+	return inc, filename.String, nil
+}
+
+// findCADAudio returns the closest audio row from the same talkgroup within
+// CAD_MATCH_WINDOW_SEC of the incident that is not linked to another incident.
+func (s *server) findCADAudio(ctx context.Context, inc cadIncident) (string, error) {
+	if cadTalkgroupKey(inc.Talkgroup) == "" {
+		return "", nil
+	}
+	window := time.Duration(s.cfg.CADMatchWindowSec) * time.Second
+	rows, err := s.store.Select(ctx, `WHERE COALESCE(ingest_source, '') != ? AND cad_incident_id IS NULL AND call_timestamp BETWEEN ? AND ?`, ingestSourceCAD, inc.At.Add(-window), inc.At.Add(window))
+	if err != nil {
+		return "", err
+	}
+	best, bestDelta := "", window+1
+	for _, t := range rows {
+		if t.CallTimestamp == nil {
+			continue
+		}
+		meta, err := s.parseCallMetadata(t.Filename, "")
+		if err != nil || !cadTalkgroupMatches(inc.Talkgroup, meta) {
+			continue
+		}
+		delta := t.CallTimestamp.Sub(inc.At)
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta < bestDelta {
+			best, bestDelta = t.Filename, delta
+		}
+	}
+	return best, nil
+}
+
+// correlateCAD links a freshly transcribed audio file to a CAD incident from
+// the same talkgroup, or re-applies an existing link so reprocessing does not
+// lose CAD data.
+func (s *server) correlateCAD(filename string, meta formatting.CallMetadata) {
+	if s.cfg.CADIngestToken == "" {
+		return
+	}
+	if inc, _, err := s.loadCADIncident(`id = (SELECT cad_incident_id FROM transcriptions WHERE filename = ?)`, filename); err == nil {
+		if err := s.applyCADIncident(filename, inc); err != nil {
+			log.Printf("cad re-apply failed for %s: %v", filename, err)
+		}
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("cad lookup failed for %s: %v", filename, err)
+		return
+	}
+	if meta.DateTime.IsZero() || cadTalkgroupKey(meta.AgencyDisplay) == "" {
+		return
+	}
+	window := time.Duration(s.cfg.CADMatchWindowSec) * time.Second
+	at := meta.DateTime.UTC()
+	rows, err := queryWithRetry(s.db, `SELECT id, talkgroup, incident_at, filename FROM cad_incidents WHERE incident_at BETWEEN ? AND ? AND (filename IS NULL OR filename LIKE ?)`, at.Add(-window), at.Add(window), cadFilenamePrefix+"%")
+	if err != nil {
+		log.Printf("cad correlate failed for %s: %v", filename, err)
+		return
+	}
+	var bestID int64
+	bestDelta := window + 1
+	for rows.Next() {
+		var id int64
+		var talkgroup, linked sql.NullString
+		var incidentAt interface{}
+		if err := rows.Scan(&id, &talkgroup, &incidentAt, &linked); err != nil {
+			continue
+		}
+		ts, ok := cadScanTime(incidentAt)
+		if !ok || !cadTalkgroupMatches(talkgroup.String, meta) {
+			continue
+		}
+		delta := ts.Sub(at)
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta < bestDelta {
+			bestID, bestDelta = id, delta
+		}
+	}
+	rows.Close()
+	if bestID == 0 {
+		return
+	}
+	inc, placeholder, err := s.loadCADIncident(`id = ?`, bestID)
+	if err != nil {
+		log.Printf("cad load failed for incident %d: %v", bestID, err)
+		return
+	}
+	if err := s.linkCADAudio(inc, filename, placeholder); err != nil {
+		log.Printf("cad link failed for %s: %v", filename, err)
+	}
+}
+
+// linkCADAudio points the incident at its audio row, drops the placeholder row
+// if there was one, and copies the CAD fields onto the audio row.
+func (s *server) linkCADAudio(inc storedCADIncident, filename, placeholder string) error {
+	if _, err := execWithRetry(s.db, `UPDATE cad_incidents SET filename = ? WHERE id = ?`, filename, inc.id); err != nil {
+		return err
+	}
+	if strings.HasPrefix(placeholder, cadFilenamePrefix) {
+		if _, err := execWithRetry(s.db, `DELETE FROM transcriptions WHERE filename = ? AND status = ?`, placeholder, statusCAD); err != nil {
+			return err
+		}
+	}
+	return s.applyCADIncident(filename, inc)
+}
+
+// applyCADIncident copies CAD fields onto a transcription row. CAD values win
+// over transcript-derived ones because dispatch data is authoritative.
+func (s *server) applyCADIncident(filename string, inc storedCADIncident) error {
+	label := strings.Join(nonEmpty(inc.Address, inc.Town), ", ")
+	var units *string
+	if len(inc.Units) > 0 {
+		data, _ := json.Marshal(inc.Units)
+		str := string(data)
+		units = &str
+	}
+	var source *string
+	if label != "" || inc.Latitude != nil {
+		source = nullableString(ingestSourceCAD)
+	}
+	_, err := execWithRetry(s.db, `UPDATE transcriptions SET cad_incident_id = ?, call_type = COALESCE(?, call_type), units = COALESCE(?, units), location_label = COALESCE(?, location_label), latitude = COALESCE(?, latitude), longitude = COALESCE(?, longitude), location_source = COALESCE(?, location_source) WHERE filename = ?`,
+		inc.id, nullableString(inc.CallType), units, nullableString(label), inc.Latitude, inc.Longitude, source, filename)
+	return err
+}
+
+func nonEmpty(values ...string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func cadScanTime(v interface{}) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val.UTC(), true
+	case string:
+		ts, err := parseTimestampFlexible(val, time.UTC)
+		return ts.UTC(), err == nil
+	}
+	return time.Time{}, false
+}
+
+// cadTalkgroupKey normalizes talkgroup names so "Andover TWP FD" from a CAD
+// feed matches the "AndoverTWP_FD" recording filename prefix.
+func cadTalkgroupKey(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func cadTalkgroupMatches(talkgroup string, meta formatting.CallMetadata) bool {
+	key := cadTalkgroupKey(talkgroup)
+	if key == "" {
+		return false
+	}
+	return key == cadTalkgroupKey(meta.AgencyDisplay) || key == cadTalkgroupKey(meta.AgencyDisplay+meta.CallType)
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func migrateAddSoftDelete(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "transcriptions", "deleted_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	_, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_transcriptions_deleted_at ON transcriptions(deleted_at)`)
+	return err
+}
+
+func downSoftDelete(db *sql.DB) error {
+	if _, err := execWithRetry(db, `DROP INDEX IF EXISTS idx_transcriptions_deleted_at`); err != nil {
+		return err
+	}
+	return dropColumnIfExists(db, "transcriptions", "deleted_at")
+}
+
+// purgeTables lists the per-call rows a purge removes along with the call,
+// by the column that holds its filename.
+var purgeTables = []struct{ table, column string }{
+	{"call_entities", "filename"},
+	{"call_errors", "filename"},
+	{"incident_escalations", "filename"},
+	{"review_items", "filename"},
+	{"shadow_results", "filename"},
+	{"twilio_deliveries", "filename"},
+	{"webhook_payloads", "filename"},
+	{"alert_ledger", "filename"},
+	{"call_aliases", "filename"},
+	{"call_type_corrections", "filename"},
+	{"geocode_corrections", "filename"},
+	{"eval_cases", "call_filename"},
+}
+
+// handleCallDelete serves DELETE /api/transcription/{filename}. By default
+// the call is soft-deleted: it drops out of lists, feeds and archives and its
+// audio is no longer served, but admins can still open and restore it.
+// ?purge=true removes the call for good: the row with its embedding, every
+// per-call row that refers to it, its audio and its cached preview.
+func (s *server) handleCallDelete(w http.ResponseWriter, r *http.Request, filename string) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	t, err := s.getTranscription(filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("fetch transcription %s failed: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if strings.EqualFold(r.URL.Query().Get("purge"), "true") {
+		s.purgeCall(w, r, *t)
+		return
+	}
+	if t.DeletedAt != nil {
+		respondJSON(w, map[string]interface{}{"filename": t.Filename, "status": "deleted", "deleted_at": t.DeletedAt})
+		return
+	}
+	now := time.Now().UTC()
+	if _, err := execWithRetry(s.db, `UPDATE transcriptions SET deleted_at = ? WHERE filename = ? AND deleted_at IS NULL`, now, t.Filename); err != nil {
+		log.Printf("soft delete failed for %s: %v", t.Filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.previews.drop(t.Filename)
+	s.speech.drop(t.Filename)
+	s.queries.invalidate()
+	s.rebuildArchiveFor(*t)
+	s.enqueueRollupJob("delete")
+	log.Printf("call %s deleted by %s", t.Filename, settingsActor(r))
+	noteAudit(r, "call.delete", t.Filename, nil, map[string]interface{}{"deleted_at": now})
+	respondJSON(w, map[string]interface{}{"filename": t.Filename, "status": "deleted", "deleted_at": now})
+}
+
+// handleCallRestore serves POST /api/transcription/{filename}/restore, which
+// undoes a soft delete.
+func (s *server) handleCallRestore(w http.ResponseWriter, r *http.Request, filename string) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	t, err := s.getTranscription(filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("fetch transcription %s failed: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if t.DeletedAt == nil {
+		http.Error(w, "call is not deleted", http.StatusConflict)
+		return
+	}
+	if _, err := execWithRetry(s.db, `UPDATE transcriptions SET deleted_at = NULL WHERE filename = ?`, t.Filename); err != nil {
+		log.Printf("restore failed for %s: %v", t.Filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.queries.invalidate()
+	s.rebuildArchiveFor(*t)
+	s.enqueueRollupJob("restore")
+	log.Printf("call %s restored by %s", t.Filename, settingsActor(r))
+	noteAudit(r, "call.restore", t.Filename, map[string]interface{}{"deleted_at": t.DeletedAt}, nil)
+	respondJSON(w, map[string]interface{}{"filename": t.Filename, "status": t.Status})
+}
+
+// purgeCall removes t and everything kept for it. A call that is queued or
+// being transcribed is refused, since the job would write it back.
+func (s *server) purgeCall(w http.ResponseWriter, r *http.Request, t transcription) {
+	if _, busy := s.running.LoadOrStore(t.Filename, struct{}{}); busy || t.Status == statusProcessing {
+		if !busy {
+			s.running.Delete(t.Filename)
+		}
+		http.Error(w, "call is queued or processing", http.StatusConflict)
+		return
+	}
+	defer s.running.Delete(t.Filename)
+
+	paths := s.callAudioPaths(t)
+	aliases, err := s.callAliases(r.Context(), t.Filename)
+	if err != nil {
+		log.Printf("purge of %s could not list aliases: %v", t.Filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	for _, alias := range aliases {
+		paths = append(paths, filepath.Join(s.cfg.CallsDir, alias))
+	}
+	evalAudio, err := s.evalCaseAudio(r.Context(), t.Filename)
+	if err != nil {
+		log.Printf("purge of %s could not list eval cases: %v", t.Filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	paths = append(paths, evalAudio...)
+	rollupIDs, err := s.callRollupIDs(r.Context(), t.ID)
+	if err != nil {
+		log.Printf("purge of %s could not list rollups: %v", t.Filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.purgeCallRows(r.Context(), t); err != nil {
+		log.Printf("purge failed for %s: %v", t.Filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.rebuildRollups(rollupIDs)
+	var removed []string
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("purge of %s could not remove %s: %v", t.Filename, path, err)
+			}
+			continue
+		}
+		removed = append(removed, path)
+	}
+	s.previews.drop(t.Filename)
+	s.speech.drop(t.Filename)
+	s.queries.invalidate()
+	if t.DeletedAt == nil {
+		s.rebuildArchiveFor(t)
+		s.recountTrendsFor(t)
+	}
+	log.Printf("call %s purged by %s (%d files removed)", t.Filename, settingsActor(r), len(removed))
+	noteAudit(r, "call.purge", t.Filename, map[string]interface{}{
+		"status":     t.Status,
+		"call_type":  t.CallType,
+		"deleted_at": t.DeletedAt,
+		"created_at": t.CreatedAt,
+	}, map[string]interface{}{"files_removed": removed})
+	respondJSON(w, map[string]interface{}{"filename": t.Filename, "status": "purged", "files_removed": len(removed)})
+}
+
+// callRollupIDs returns the rollups holding call id.
+func (s *server) callRollupIDs(ctx context.Context, id int64) ([]int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT rollup_id FROM rollup_calls WHERE call_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var rollupID int64
+		if err := rows.Scan(&rollupID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, rollupID)
+	}
+	return ids, rows.Err()
+}
+
+// rebuildRollups rebuilds, in the background, the rollups that held a
+// purged call. A process without the rollup service cannot; its open
+// rollups are redone by the worker's next recompute, but a closed one keeps
+// its old summary unless the call was soft-deleted first.
+func (s *server) rebuildRollups(ids []int64) {
+	if s.rollups == nil || len(ids) == 0 {
+		return
+	}
+	go func() {
+		for _, id := range ids {
+			if err := s.rollups.Rebuild(s.ctx, id); err != nil {
+				log.Printf("rollup %d rebuild after purge failed: %v", id, err)
+			}
+		}
+	}()
+}
+
+// purgeCallRows deletes the call's row and the rows that refer to it in one
+// transaction, so a failed purge leaves the call whole.
+func (s *server) purgeCallRows(ctx context.Context, t transcription) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, p := range purgeTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+p.table+` WHERE `+p.column+` = ?`, t.Filename); err != nil {
+			return err
+		}
+	}
+	for _, table := range []string{"acknowledgements", "incident_comments"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE target_type = ? AND target_id = ?`, noteTargetCall, t.Filename); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM rollup_calls WHERE call_id = ?`, t.ID); err != nil {
+		return err
+	}
+	// Pages and CAD incidents linked to the call stay, unlinked.
+	if _, err := tx.ExecContext(ctx, `UPDATE transcriptions SET page_for = NULL WHERE page_for = ?`, t.Filename); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE cad_incidents SET filename = NULL WHERE filename = ?`, t.Filename); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM transcriptions WHERE id = ?`, t.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// evalCaseAudio returns the audio copied for the eval cases made from call
+// filename.
+func (s *server) evalCaseAudio(ctx context.Context, filename string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT audio_path FROM eval_cases WHERE call_filename = ? AND audio_path != ''`, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		out = append(out, path)
+	}
+	return out, rows.Err()
+}
+
+// callAudioPaths lists the audio files kept for t: the recording as it
+// arrived and the filtered copy made from it.
+func (s *server) callAudioPaths(t transcription) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, p := range []string{t.ProcessedPath, t.SourcePath, t.Filename} {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) && !fileExists(p) {
+			p = filepath.Join(s.cfg.CallsDir, filepath.Base(p))
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// audioDeleted reports whether path, a file in CALLS_DIR, is the audio of a
+// deleted call, under its own name or an alias.
+func (s *server) audioDeleted(ctx context.Context, path string) bool {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM transcriptions WHERE deleted_at IS NOT NULL AND (filename = ? OR source_path = ? OR processed_path = ? OR filename IN (SELECT filename FROM call_aliases WHERE alias = ?))`,
+		filepath.Base(path), path, path, filepath.Base(path)).Scan(&n)
+	if err != nil {
+		log.Printf("deleted audio lookup failed for %s: %v", path, err)
+	}
+	return n > 0
+}
+
+// recountTrendsFor recounts, in the background, the trend day and month
+// holding t. The trend aggregator finds changed days through the rows left
+// behind, so it never sees a call purged without a soft delete first.
+func (s *server) recountTrendsFor(t transcription) {
+	ts := t.CreatedAt
+	if t.CallTimestamp != nil {
+		ts = *t.CallTimestamp
+	}
+	day := trendDay(ts, s.tz)
+	go func() {
+		if err := s.aggregateTrendDay(s.ctx, day); err != nil {
+			log.Printf("trend recount for %s failed: %v", day.Format(trendDayLayout), err)
+			return
+		}
+		if err := s.aggregateTrendMonth(day.Format(trendDayLayout)[:7]); err != nil {
+			log.Printf("trend recount for %s failed: %v", day.Format(trendDayLayout)[:7], err)
+		}
+	}()
+}
+
+// rebuildArchiveFor rebuilds the daily archive holding t, if that day has
+// been archived, so the archive matches what is deleted.
+func (s *server) rebuildArchiveFor(t transcription) {
+	if t.CallTimestamp == nil {
+		return
+	}
+	day := t.CallTimestamp.In(s.tz)
+	if _, err := os.Stat(filepath.Join(s.cfg.ArchiveDir, day.Format(archiveDateLayout))); err != nil {
+		return
+	}
+	go func() {
+		if err := s.buildDailyArchive(s.ctx, day); err != nil {
+			log.Printf("daily archive for %s failed: %v", day.Format(archiveDateLayout), err)
+		}
+	}()
+}
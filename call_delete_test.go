@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"alert_framework/config"
+)
+
+func TestPurgeRemovesEveryCopyOfTheCall(t *testing.T) {
+	t.Setenv("ENABLE_ADMIN_ACTIONS", "true")
+	t.Setenv("ADMIN_TOKEN", "secret")
+	s := newTestServer(t, config.Config{})
+	at := time.Date(2026, 9, 14, 15, 0, 0, 0, time.UTC)
+	insertTestCall(t, s.db, testCall{filename: "a.mp3", callType: "medical", transcript: "fall at 14 Birch Lane", at: at})
+	evalAudio := filepath.Join(t.TempDir(), "case.mp3")
+	if err := os.WriteFile(evalAudio, []byte("audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	for _, st := range []struct {
+		q    string
+		args []interface{}
+	}{
+		{`INSERT INTO call_type_corrections (filename, transcript, original_type, corrected_type, created_at) VALUES ('a.mp3', 'fall at 14 Birch Lane', 'medical', 'fall', ?)`, []interface{}{now}},
+		{`INSERT INTO acknowledgements (target_type, target_id, acked_at) VALUES ('call', 'a.mp3', ?)`, []interface{}{now}},
+		{`INSERT INTO geocode_corrections (phrase, phrase_key, filename, created_at, updated_at) VALUES ('14 birch lane', '14 birch lane', 'a.mp3', ?, ?)`, []interface{}{now, now}},
+		{`INSERT INTO eval_cases (name, call_filename, expected_transcript, audio_path, created_at) VALUES ('fall', 'a.mp3', 'fall at 14 Birch Lane', ?, ?)`, []interface{}{evalAudio, now}},
+		{`INSERT INTO cad_incidents (external_id, incident_at, filename) VALUES ('cad-1', ?, 'a.mp3')`, []interface{}{now}},
+	} {
+		if _, err := s.db.Exec(st.q, st.args...); err != nil {
+			t.Fatalf("%s: %v", st.q, err)
+		}
+	}
+	if err := s.refreshTrends(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, s.db, "stats_daily"); n != 1 {
+		t.Fatalf("stats_daily has %d rows before the purge, want 1", n)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/transcription/a.mp3?purge=true", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	rec := httptest.NewRecorder()
+	s.handleCallDelete(rec, req, "a.mp3")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, table := range []string{"transcriptions", "call_type_corrections", "acknowledgements", "geocode_corrections", "eval_cases"} {
+		if n := countRows(t, s.db, table); n != 0 {
+			t.Errorf("%s kept %d rows", table, n)
+		}
+	}
+	var linked int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM cad_incidents WHERE filename IS NOT NULL`).Scan(&linked); err != nil || linked != 0 {
+		t.Errorf("cad incident still linked (%d, %v)", linked, err)
+	}
+	if n := countRows(t, s.db, "cad_incidents"); n != 1 {
+		t.Errorf("cad incident removed with the call")
+	}
+	if _, err := os.Stat(evalAudio); !os.IsNotExist(err) {
+		t.Errorf("eval case audio kept: %v", err)
+	}
+	// The day is recounted in the background.
+	deadline := time.Now().Add(5 * time.Second)
+	for countRows(t, s.db, "stats_daily")+countRows(t, s.db, "stats_monthly") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("purged call still counted in the trend stats")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDeletedCallsAreNotEscalatedOrReprocessed(t *testing.T) {
+	s := newTestServer(t, config.Config{})
+	at := time.Now().UTC().Add(-time.Hour)
+	insertTestCall(t, s.db, testCall{filename: "kept.mp3", callType: "structure fire", transcript: "smoke showing", at: at})
+	deleted := insertTestCall(t, s.db, testCall{filename: "gone.mp3", callType: "structure fire", transcript: "smoke showing", at: at})
+	if _, err := s.db.Exec(`UPDATE transcriptions SET deleted_at = ? WHERE id = ?`, time.Now().UTC(), deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	est, err := s.estimateBulkReprocess(context.Background(), bulkReprocessParams{Status: statusDone, Until: time.Now().UTC(), Stage: "transcribe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.Count != 1 {
+		t.Errorf("bulk reprocess selects %d calls, want only the one not deleted", est.Count)
+	}
+
+	// With no escalation target configured, an escalated call is claimed
+	// and records the failure.
+	for _, name := range []string{"kept.mp3", "gone.mp3"} {
+		if _, err := s.db.Exec(`INSERT INTO incident_escalations (filename, call_category, call_type, alert_text, alerted_at, due_at) VALUES (?, 'fire', 'structure fire', 'alert', ?, ?)`, name, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.escalateOverdue(context.Background())
+	var escalated string
+	if err := s.db.QueryRow(`SELECT group_concat(filename) FROM incident_escalations WHERE escalated_at IS NOT NULL`).Scan(&escalated); err != nil {
+		t.Fatal(err)
+	}
+	if escalated != "kept.mp3" {
+		t.Fatalf("escalated %q, want only kept.mp3", escalated)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/errcode"
+	"alert_framework/usage"
+)
+
+const (
+	defaultErrorStatsDays = 30
+	maxErrorStatsDays     = 366
+)
+
+type errorStatsResponse struct {
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+	Code   string          `json:"code,omitempty"`
+	Totals map[string]int  `json:"totals"`
+	Daily  []errorStatsDay `json:"daily"`
+}
+
+type errorStatsDay struct {
+	Day    string         `json:"day"`
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+}
+
+// errorCodeFor picks the errcode for err: an explicit code from the chain,
+// otherwise one inferred from well-known error types.
+func errorCodeFor(err error) string {
+	if code := errcode.Of(err); code != "" {
+		return code
+	}
+	var statusErr *openAIStatusError
+	var urlErr *url.Error
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, errTranscriptionDeferred):
+		return errcode.OpenAIDown
+	case errors.Is(err, usage.ErrBudgetExceeded):
+		return errcode.OpenAIBudget
+	case errors.As(err, &statusErr):
+		if statusErr.StatusCode == http.StatusTooManyRequests {
+			return errcode.OpenAIRateLimit
+		}
+		return errcode.OpenAIError
+	case errors.As(err, &urlErr):
+		return errcode.OpenAIError
+	case errors.As(err, &pathErr):
+		return errcode.FileIO
+	}
+	return errcode.Unknown
+}
+
+// refineError tags a refinement or metadata-prompt failure. A reply that is
+// not the JSON the prompt asked for is a parse error; transport and status
+// failures keep their OpenAI code.
+func refineError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return errcode.Wrap(errcode.RefineParseError, err)
+	}
+	if code := errorCodeFor(err); code != errcode.Unknown {
+		return errcode.Wrap(code, err)
+	}
+	return errcode.Wrap(errcode.RefineFailed, err)
+}
+
+// recordCallError logs a stage failure that did not fail the call, such as a
+// geocode miss or skipped preprocessing, so it still shows up in
+// /api/stats/errors.
+func (s *server) recordCallError(filename string, err error) {
+	if _, dbErr := execWithRetry(s.db, sqlRecordCallError, filename, errorCodeFor(err), err.Error(), time.Now().UTC(), filename); dbErr != nil {
+		log.Printf("call error log failed for %s: %v", filename, dbErr)
+	}
+}
+
+// handleErrorStats serves GET /api/stats/errors: failures per code per day
+// over the last days (default 30), optionally for one code. Messages are
+// not returned; use the call detail for those.
+func (s *server) handleErrorStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	days := defaultErrorStatsDays
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxErrorStatsDays {
+			http.Error(w, "days must be between 1 and 366", http.StatusBadRequest)
+			return
+		}
+		days = v
+	}
+	code := strings.TrimSpace(r.URL.Query().Get("code"))
+
+	now := time.Now().In(s.tz)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.tz)
+	from := to.AddDate(0, 0, -(days - 1))
+	query := `SELECT code, created_at FROM call_errors WHERE created_at >= ?`
+	args := []interface{}{from.UTC()}
+	if code != "" {
+		query += ` AND code = ?`
+		args = append(args, code)
+	}
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		log.Printf("error stats query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	resp := errorStatsResponse{From: from.Format(trendDayLayout), To: to.Format(trendDayLayout), Code: code, Totals: make(map[string]int)}
+	byDay := make(map[string]map[string]int)
+	for rows.Next() {
+		var c string
+		var at time.Time
+		if err := rows.Scan(&c, &at); err != nil {
+			log.Printf("error stats scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		day := at.In(s.tz).Format(trendDayLayout)
+		if byDay[day] == nil {
+			byDay[day] = make(map[string]int)
+		}
+		byDay[day][c]++
+		resp.Totals[c]++
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("error stats query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	for t := from; !t.After(to); t = t.AddDate(0, 0, 1) {
+		day := t.Format(trendDayLayout)
+		entry := errorStatsDay{Day: day, Counts: byDay[day]}
+		if entry.Counts == nil {
+			entry.Counts = map[string]int{}
+		}
+		for _, n := range entry.Counts {
+			entry.Total += n
+		}
+		resp.Daily = append(resp.Daily, entry)
+	}
+	respondJSON(w, resp)
+}
+
+// failuresByCode counts calls that failed since cutoff by error code.
+func (s *server) failuresByCode(ctx context.Context, cutoff time.Time) map[string]int {
+	rows, err := s.db.QueryContext(ctx, `SELECT COALESCE(error_code, ?), COUNT(*) FROM transcriptions WHERE status = ? AND updated_at >= ? GROUP BY 1`, errcode.Unknown, statusError, cutoff)
+	if err != nil {
+		log.Printf("status error codes failed: %v", err)
+		return nil
+	}
+	defer rows.Close()
+	out := make(map[string]int)
+	for rows.Next() {
+		var code string
+		var n int
+		if err := rows.Scan(&code, &n); err != nil {
+			log.Printf("status error codes failed: %v", err)
+			return nil
+		}
+		out[code] = n
+	}
+	return out
+}
+
+// topErrorCodes returns codes by descending count, ties by name.
+func topErrorCodes(counts map[string]int) []string {
+	codes := make([]string, 0, len(counts))
+	for c := range counts {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if counts[codes[i]] != counts[codes[j]] {
+			return counts[codes[i]] > counts[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	return codes
+}
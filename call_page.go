@@ -0,0 +1,249 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	siteName = "Sussex County Alerts"
+	// The oEmbed player is the audio element under a one-line title.
+	oembedPlayerWidth  = 480
+	oembedPlayerHeight = 96
+	previewCardWidth   = 1200
+	previewCardHeight  = 630
+	callPageSnippet    = 200
+)
+
+// callPage is what the share page, player and oEmbed response show of a
+// call. It is always built from the public record.
+type callPage struct {
+	SiteName    string
+	Title       string
+	Description string
+	Transcript  string
+	PageURL     string
+	PlayerURL   string
+	OEmbedURL   string
+	ImageURL    string
+	AudioURL    string
+	AudioType   string
+	Width       int
+	Height      int
+}
+
+var callSharePage = template.Must(template.New("call").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} · {{.SiteName}}</title>
+<meta name="description" content="{{.Description}}">
+<link rel="canonical" href="{{.PageURL}}">
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="{{.Title}}">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:type" content="website">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.PageURL}}">
+<meta property="og:image" content="{{.ImageURL}}">
+<meta property="og:image:type" content="image/png">
+<meta property="og:image:width" content="1200">
+<meta property="og:image:height" content="630">
+{{if .AudioURL}}<meta property="og:audio" content="{{.AudioURL}}">
+<meta property="og:audio:type" content="{{.AudioType}}">
+<meta name="twitter:card" content="player">
+<meta name="twitter:player" content="{{.PlayerURL}}">
+<meta name="twitter:player:width" content="{{.Width}}">
+<meta name="twitter:player:height" content="{{.Height}}">
+{{else}}<meta name="twitter:card" content="summary_large_image">
+{{end}}<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<meta name="twitter:image" content="{{.ImageURL}}">
+<style>
+body{margin:0;font:16px/1.5 system-ui,sans-serif;color:#e8eeff;background:#0b1021}
+main{max-width:760px;margin:0 auto;padding:24px}
+img{width:100%;height:auto;border-radius:6px}
+h1{font-size:22px;margin:16px 0 8px}
+audio{width:100%;margin:8px 0}
+p{color:#a5afc5;white-space:pre-wrap}
+a{color:#7ee7ff}
+</style>
+</head>
+<body>
+<main>
+<img src="{{.ImageURL}}" alt="{{.Title}}" width="1200" height="630">
+<h1>{{.Title}}</h1>
+{{if .AudioURL}}<audio controls preload="none" src="{{.AudioURL}}"></audio>{{end}}
+{{if .Transcript}}<p>{{.Transcript}}</p>{{end}}
+<a href="/">All calls</a>
+</main>
+</body>
+</html>
+`))
+
+var callPlayerPage = template.Must(template.New("player").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{margin:0;padding:8px 12px;font:14px/1.4 system-ui,sans-serif;color:#1f2933;background:#fff}
+a{color:inherit;font-weight:600;text-decoration:none;display:block;white-space:nowrap;overflow:hidden;text-overflow:ellipsis}
+audio{width:100%;margin-top:6px}
+</style>
+</head>
+<body>
+<a href="{{.PageURL}}" target="_blank" rel="noopener">{{.Title}}</a>
+{{if .AudioURL}}<audio controls preload="none" src="{{.AudioURL}}"></audio>{{end}}
+</body>
+</html>
+`))
+
+// loadCallPage builds the share page for filename, or reports false when the
+// call does not exist or is restricted: restricted calls are never shared.
+func (s *server) loadCallPage(r *http.Request, filename string) (callPage, bool) {
+	t, err := s.getTranscription(filename)
+	if err != nil || s.isRestricted(*t) {
+		return callPage{}, false
+	}
+	base := s.resolveBaseURL(r)
+	public := s.publicRecord(*t)
+	resp := s.toResponse(public, base)
+	page := callPage{
+		SiteName:  siteName,
+		Title:     fallbackEmpty(resp.PrettyTitle, t.Filename),
+		PageURL:   s.callPageURL(base, t.Filename),
+		ImageURL:  resp.PreviewImage,
+		AudioURL:  resp.AudioURL,
+		AudioType: audioMIMEType(resp.AudioURL),
+		Width:     oembedPlayerWidth,
+		Height:    oembedPlayerHeight,
+	}
+	page.PlayerURL = page.PageURL + "/player"
+	page.OEmbedURL = base + "/oembed?format=json&url=" + url.QueryEscape(page.PageURL)
+	if text := pickTranscript(&public); text != nil {
+		page.Transcript = strings.TrimSpace(*text)
+	}
+	page.Description = "Transcript not ready yet."
+	if page.Transcript != "" {
+		page.Description = truncateText(normalizeWhitespace(page.Transcript), callPageSnippet)
+	}
+	if resp.Town != "" {
+		page.Description = resp.Town + " · " + page.Description
+	}
+	return page, true
+}
+
+func (s *server) callPageURL(base, filename string) string {
+	return strings.TrimRight(base, "/") + "/call/" + url.PathEscape(filename)
+}
+
+func audioMIMEType(audioURL string) string {
+	if audioURL == "" {
+		return ""
+	}
+	ext := path.Ext(audioURL)
+	if u, err := url.Parse(audioURL); err == nil {
+		ext = path.Ext(u.Path)
+	}
+	if typ := mime.TypeByExtension(strings.ToLower(ext)); strings.HasPrefix(typ, "audio/") {
+		return typ
+	}
+	return "audio/mpeg"
+}
+
+// handleCallPage serves /call/{filename}, the link to share for a call: an
+// HTML page whose Open Graph and Twitter Card tags unfurl to the preview card
+// and audio, and /call/{filename}/player, the bare audio player the oEmbed
+// and Twitter player embeds frame.
+func (s *server) handleCallPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/call/"), "/")
+	rest, player := strings.CutSuffix(rest, "/player")
+	filename := filepath.Base(rest)
+	if rest == "" || filename != rest {
+		http.NotFound(w, r)
+		return
+	}
+	page, ok := s.loadCallPage(r, filename)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	tmpl := callSharePage
+	if player {
+		tmpl = callPlayerPage
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; media-src *; frame-ancestors *")
+	}
+	if err := tmpl.Execute(w, page); err != nil {
+		log.Printf("call page render failed for %s: %v", filename, err)
+	}
+}
+
+// handleOEmbed serves GET /oembed?url={call page URL}[&maxwidth=][&maxheight=]
+// as a "rich" oEmbed response framing the call's audio player. Only JSON is
+// offered; format=xml gets 501 as the spec asks.
+func (s *server) handleOEmbed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	if format := q.Get("format"); format != "" && format != "json" {
+		http.Error(w, "only json is supported", http.StatusNotImplemented)
+		return
+	}
+	target, err := url.Parse(strings.TrimSpace(q.Get("url")))
+	if err != nil || !strings.HasPrefix(target.Path, "/call/") {
+		http.NotFound(w, r)
+		return
+	}
+	filename := strings.Trim(strings.TrimPrefix(target.Path, "/call/"), "/")
+	if filename == "" || filepath.Base(filename) != filename {
+		http.NotFound(w, r)
+		return
+	}
+	page, ok := s.loadCallPage(r, filename)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	width, height := oembedPlayerWidth, oembedPlayerHeight
+	if v, err := strconv.Atoi(q.Get("maxwidth")); err == nil && v > 0 && v < width {
+		width = v
+	}
+	if v, err := strconv.Atoi(q.Get("maxheight")); err == nil && v > 0 && v < height {
+		height = v
+	}
+	resp := map[string]interface{}{
+		"version":          "1.0",
+		"type":             "rich",
+		"provider_name":    siteName,
+		"provider_url":     s.resolveBaseURL(r),
+		"title":            page.Title,
+		"html":             `<iframe src="` + template.HTMLEscapeString(page.PlayerURL) + `" width="` + strconv.Itoa(width) + `" height="` + strconv.Itoa(height) + `" style="border:0" title="` + template.HTMLEscapeString(page.Title) + `"></iframe>`,
+		"width":            width,
+		"height":           height,
+		"thumbnail_url":    page.ImageURL,
+		"thumbnail_width":  previewCardWidth,
+		"thumbnail_height": previewCardHeight,
+		"cache_age":        300,
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondJSON(w, resp)
+}
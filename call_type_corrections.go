@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCallTypeExportLimit = 1000
+	maxCallTypeExportLimit     = 50000
+	// maxClassifyExampleChars caps each few-shot transcript so a handful of
+	// long calls cannot crowd out the call being classified.
+	maxClassifyExampleChars = 600
+)
+
+// callTypeCorrection is a call whose type a reviewer changed: the transcript
+// the classifier saw, the label it gave and the label it should have given.
+type callTypeCorrection struct {
+	Filename     string    `json:"filename"`
+	Transcript   string    `json:"transcript"`
+	OriginalType string    `json:"wrong_label"`
+	Corrected    string    `json:"right_label"`
+	CorrectedBy  string    `json:"corrected_by,omitempty"`
+	CorrectedAt  time.Time `json:"corrected_at"`
+}
+
+func migrateAddCallTypeCorrections(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS call_type_corrections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT NOT NULL,
+    transcript TEXT NOT NULL,
+    original_type TEXT NOT NULL DEFAULT '',
+    corrected_type TEXT NOT NULL,
+    corrected_by TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_call_type_corrections_filename ON call_type_corrections(filename);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+// recordCallTypeCorrection stores a reviewer's call type fix for t. The
+// transcript kept is the text the classifier is given: the corrected
+// transcript when there is one, else the English translation or the cleaned
+// transcript. Fixes that leave the type unchanged are not recorded.
+func (s *server) recordCallTypeCorrection(t transcription, c reviewCorrections, actor string) error {
+	if c.CallType == nil || *c.CallType == "" {
+		return nil
+	}
+	original := derefString(t.CallType, "")
+	if strings.EqualFold(original, *c.CallType) {
+		return nil
+	}
+	var transcript string
+	switch {
+	case c.Transcript != nil:
+		transcript = *c.Transcript
+	case t.Translation != nil && strings.TrimSpace(*t.Translation) != "":
+		transcript = *t.Translation
+	default:
+		transcript = derefString(pickTranscript(&t), "")
+	}
+	transcript = strings.TrimSpace(transcript)
+	if transcript == "" {
+		return nil
+	}
+	_, err := execWithRetry(s.db, `INSERT INTO call_type_corrections (filename, transcript, original_type, corrected_type, corrected_by, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		t.Filename, transcript, original, *c.CallType, actor, time.Now().UTC())
+	return err
+}
+
+// latestCallTypeCorrections returns the newest correction per call, newest
+// first. A zero since returns all of them.
+func (s *server) latestCallTypeCorrections(since time.Time, limit int) ([]callTypeCorrection, error) {
+	rows, err := queryWithRetry(s.db, `SELECT filename, transcript, original_type, corrected_type, corrected_by, created_at FROM call_type_corrections
+WHERE id IN (SELECT MAX(id) FROM call_type_corrections GROUP BY filename) AND created_at >= ?
+ORDER BY id DESC LIMIT ?`, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []callTypeCorrection{}
+	for rows.Next() {
+		var c callTypeCorrection
+		if err := rows.Scan(&c.Filename, &c.Transcript, &c.OriginalType, &c.Corrected, &c.CorrectedBy, &c.CorrectedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// classifyExamples turns the newest CLASSIFY_FEW_SHOT corrections into
+// user/assistant message pairs for the classification prompt, oldest first.
+// A lookup failure only costs the examples.
+func (s *server) classifyExamples() []map[string]string {
+	if s.cfg.ClassifyFewShot <= 0 {
+		return nil
+	}
+	corrections, err := s.latestCallTypeCorrections(time.Time{}, s.cfg.ClassifyFewShot)
+	if err != nil {
+		log.Printf("classification examples lookup failed: %v", err)
+		return nil
+	}
+	messages := make([]map[string]string, 0, 2*len(corrections))
+	for i := len(corrections) - 1; i >= 0; i-- {
+		c := corrections[i]
+		messages = append(messages,
+			map[string]string{"role": "user", "content": truncateText(c.Transcript, maxClassifyExampleChars)},
+			map[string]string{"role": "assistant", "content": c.Corrected},
+		)
+	}
+	return messages
+}
+
+// handleCallTypeCorrections exports corrected call types as JSONL (admin
+// only):
+//
+//	GET /api/admin/call-type-corrections[?since=RFC3339][&limit=N][&format=pairs|chat]
+//
+// "pairs" (the default) writes one {filename, transcript, wrong_label,
+// right_label, corrected_by, corrected_at} object per line for evaluation;
+// "chat" writes {"messages": [...]} lines in the OpenAI fine-tuning format,
+// using the production classification prompt. Only the newest correction of
+// each call is exported.
+func (s *server) handleCallTypeCorrections(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	limit := defaultCallTypeExportLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxCallTypeExportLimit {
+			http.Error(w, "limit must be between 1 and 50000", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	var since time.Time
+	if raw := strings.TrimSpace(q.Get("since")); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+	if format == "" {
+		format = "pairs"
+	}
+	if format != "pairs" && format != "chat" {
+		http.Error(w, "format must be pairs or chat", http.StatusBadRequest)
+		return
+	}
+	corrections, err := s.latestCallTypeCorrections(since, limit)
+	if err != nil {
+		log.Printf("call type correction export failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="call-type-corrections-`+format+`.jsonl"`)
+	enc := json.NewEncoder(w)
+	for _, c := range corrections {
+		var line interface{} = c
+		if format == "chat" {
+			line = map[string]interface{}{"messages": []map[string]string{
+				{"role": "system", "content": classifyCallTypePrompt},
+				{"role": "user", "content": c.Transcript},
+				{"role": "assistant", "content": c.Corrected},
+			}}
+		}
+		if err := enc.Encode(line); err != nil {
+			log.Printf("call type correction export write failed: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	opsJobKindCallsDirMigrate = "calls_dir_migrate"
+
+	// callsDirMirrorSweep is how often files that landed in the old calls
+	// directory without an event are looked for, and callsDirMirrorRecent how
+	// far back that sweep looks.
+	callsDirMirrorSweep  = time.Minute
+	callsDirMirrorRecent = time.Hour
+
+	callsDirRewriteBatch = 500
+	callsDirMissingShown = 20
+)
+
+func migrateAddCallsDirRetirements(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS calls_dir_retirements (
+    old_dir TEXT PRIMARY KEY,
+    new_dir TEXT NOT NULL,
+    files INTEGER NOT NULL DEFAULT 0,
+    retired_by TEXT NULL,
+    retired_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`)
+	return err
+}
+
+// callsDirMigration is the state of a move from CALLS_DIR_OLD to CALLS_DIR.
+// While it is on, recordings that still land in the old directory are copied
+// into the new one, where the watcher queues them, and audio missing from
+// the new directory is served from the old one.
+type callsDirMigration struct {
+	mu       sync.Mutex
+	old      string
+	stop     context.CancelFunc
+	copying  sync.Map // filename -> struct{} while being copied
+	mirrored atomic.Int64
+}
+
+// oldCallsDir returns the directory being migrated from, or "" when no
+// migration is on.
+func (s *server) oldCallsDir() string {
+	s.callsMigration.mu.Lock()
+	defer s.callsMigration.mu.Unlock()
+	return s.callsMigration.old
+}
+
+// initCallsDirMigration turns dual ingest on when CALLS_DIR_OLD is set and
+// has not been retired.
+func (s *server) initCallsDirMigration() {
+	old := s.cfg.CallsDirOld
+	if old == "" {
+		return
+	}
+	var retiredAt time.Time
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&retiredAt)
+	}, `SELECT retired_at FROM calls_dir_retirements WHERE old_dir = ?`, filepath.Clean(old))
+	switch {
+	case err == nil:
+		log.Printf("CALLS_DIR_OLD %s was retired at %s; ignoring it (unset CALLS_DIR_OLD)", old, retiredAt.Format(time.RFC3339))
+		return
+	case !errors.Is(err, sql.ErrNoRows):
+		log.Printf("calls dir retirement lookup failed: %v", err)
+	}
+	s.callsMigration.mu.Lock()
+	s.callsMigration.old = old
+	s.callsMigration.mu.Unlock()
+	log.Printf("migrating calls from %s to %s: ingesting from both", old, s.cfg.CallsDir)
+}
+
+// startCallsDirMirror copies recordings arriving in the old calls directory
+// into CALLS_DIR until the old directory is retired.
+func (s *server) startCallsDirMirror(ctx context.Context) {
+	old := s.oldCallsDir()
+	if old == "" {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.callsMigration.mu.Lock()
+	s.callsMigration.stop = cancel
+	s.callsMigration.mu.Unlock()
+	go s.watchOldCallsDir(ctx, old)
+	go func() {
+		ticker := time.NewTicker(callsDirMirrorSweep)
+		defer ticker.Stop()
+		for {
+			s.sweepOldCallsDir(ctx, old)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *server) watchOldCallsDir(ctx context.Context, old string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("old calls dir watcher: %v; relying on sweeps", err)
+		return
+	}
+	defer watcher.Close()
+	if err := watcher.Add(old); err != nil {
+		log.Printf("old calls dir watch add: %v; relying on sweeps", err)
+		return
+	}
+	log.Printf("watching %s for recordings to copy into %s", old, s.cfg.CallsDir)
+	for {
+		select {
+		case evt, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if evt.Op&(fsnotify.Create|fsnotify.Rename) != 0 && s.isWatchedRecording(evt.Name) {
+				go s.mirrorRecording(ctx, old, filepath.Base(evt.Name))
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("old calls dir watch error: %v", err)
+		case <-ctx.Done():
+			return
+		case <-s.shutdown:
+			return
+		}
+	}
+}
+
+// sweepOldCallsDir copies recent recordings the old directory's watcher
+// missed.
+func (s *server) sweepOldCallsDir(ctx context.Context, old string) {
+	since := time.Now().Add(-callsDirMirrorRecent)
+	files, err := listAudioDir(old, &since)
+	if err != nil {
+		log.Printf("old calls dir sweep failed: %v", err)
+		return
+	}
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		s.mirrorRecording(ctx, old, f.Name())
+	}
+}
+
+// mirrorRecording copies a recording from the old directory into CALLS_DIR
+// once it has stopped growing. The copy is renamed into place so the watcher
+// sees a complete file.
+func (s *server) mirrorRecording(ctx context.Context, old, name string) {
+	if _, busy := s.callsMigration.copying.LoadOrStore(name, struct{}{}); busy {
+		return
+	}
+	defer s.callsMigration.copying.Delete(name)
+	src := filepath.Join(old, name)
+	info, err := os.Stat(src)
+	if err != nil {
+		return
+	}
+	if time.Since(info.ModTime()) < time.Minute {
+		if err := waitForStableSize(ctx, src, info.Size(), time.Second, 2); err != nil {
+			log.Printf("mirror of %s abandoned: %v", name, err)
+			return
+		}
+	}
+	copied, err := s.copyIntoCallsDir(src, name)
+	if err != nil {
+		log.Printf("mirror of %s into %s failed: %v", name, s.cfg.CallsDir, err)
+		return
+	}
+	if copied {
+		s.callsMigration.mirrored.Add(1)
+		log.Printf("mirrored %s from %s", name, old)
+	}
+}
+
+// copyIntoCallsDir copies src to CALLS_DIR/name unless a file of the same
+// size is already there. It refuses to replace a different file.
+func (s *server) copyIntoCallsDir(src, name string) (bool, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	dst := filepath.Join(s.cfg.CallsDir, name)
+	if existing, err := os.Stat(dst); err == nil {
+		if existing.Size() == info.Size() {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s already exists with a different size", dst)
+	}
+	tmp := filepath.Join(s.cfg.CallsDir, "."+name+".mirror")
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	// Keep the recording's mtime so time windows over CALLS_DIR still match.
+	_ = os.Chtimes(tmp, info.ModTime(), info.ModTime())
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// listAudioDir lists the recordings in dir modified since since, skipping
+// hidden files and write probes.
+func listAudioDir(dir string, since *time.Time) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []os.FileInfo
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") || strings.Contains(name, ".writetest-") || !isAudioFilename(name) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || (since != nil && info.ModTime().Before(*since)) {
+			continue
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// oldPathPrefix is how paths under old start in the calls table: they were
+// stored as filepath.Join(CALLS_DIR, filename).
+func oldPathPrefix(old string) string {
+	return filepath.Clean(old) + string(os.PathSeparator)
+}
+
+type callsDirStatus struct {
+	OldDir        string     `json:"old_dir,omitempty"`
+	NewDir        string     `json:"new_dir"`
+	Active        bool       `json:"active"`
+	RetiredAt     *time.Time `json:"retired_at,omitempty"`
+	OldFiles      int        `json:"old_files"`
+	Copied        int        `json:"copied"`
+	Missing       []string   `json:"missing"`
+	Conflicts     []string   `json:"conflicts"`
+	RowsOnOld     int        `json:"rows_on_old"`
+	Mirrored      int64      `json:"mirrored_since_start"`
+	ReadyToRetire bool       `json:"ready_to_retire"`
+}
+
+// callsDirProgress compares the old directory with CALLS_DIR and counts the
+// calls whose paths still point into the old one.
+func (s *server) callsDirProgress(ctx context.Context, old string) (callsDirStatus, error) {
+	st := callsDirStatus{OldDir: old, NewDir: s.cfg.CallsDir, Active: true, Missing: []string{}, Conflicts: []string{}, Mirrored: s.callsMigration.mirrored.Load()}
+	files, err := listAudioDir(old, nil)
+	if err != nil {
+		return st, err
+	}
+	st.OldFiles = len(files)
+	missing := 0
+	for _, f := range files {
+		existing, err := os.Stat(filepath.Join(s.cfg.CallsDir, f.Name()))
+		switch {
+		case err != nil:
+			missing++
+			if len(st.Missing) < callsDirMissingShown {
+				st.Missing = append(st.Missing, f.Name())
+			}
+		case existing.Size() != f.Size():
+			missing++
+			if len(st.Conflicts) < callsDirMissingShown {
+				st.Conflicts = append(st.Conflicts, f.Name())
+			}
+		default:
+			st.Copied++
+		}
+	}
+	prefix := oldPathPrefix(old)
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM transcriptions WHERE substr(COALESCE(source_path, ''), 1, ?) = ? OR substr(COALESCE(processed_path, ''), 1, ?) = ?`,
+		len(prefix), prefix, len(prefix), prefix).Scan(&st.RowsOnOld)
+	if err != nil {
+		return st, err
+	}
+	st.ReadyToRetire = missing == 0 && st.RowsOnOld == 0
+	return st, nil
+}
+
+// handleCallsDirStatus serves GET /ops/calls-dir: how far the move from
+// CALLS_DIR_OLD has come and whether the old directory can be retired.
+func (s *server) handleCallsDirStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	old := s.oldCallsDir()
+	if old == "" {
+		st := callsDirStatus{OldDir: s.cfg.CallsDirOld, NewDir: s.cfg.CallsDir, Missing: []string{}, Conflicts: []string{}}
+		if st.OldDir != "" {
+			var at time.Time
+			if err := s.db.QueryRowContext(r.Context(), `SELECT retired_at FROM calls_dir_retirements WHERE old_dir = ?`, filepath.Clean(st.OldDir)).Scan(&at); err == nil {
+				st.RetiredAt = &at
+			}
+		}
+		respondJSON(w, st)
+		return
+	}
+	st, err := s.callsDirProgress(r.Context(), old)
+	if err != nil {
+		log.Printf("calls dir status failed: %v", err)
+		http.Error(w, "status failed", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, st)
+}
+
+type callsDirMigrateParams struct {
+	OldDir string `json:"old_dir"`
+	NewDir string `json:"new_dir"`
+}
+
+// handleCallsDirMigrate serves POST /ops/calls-dir/migrate, which starts an
+// ops job copying every recording still only in the old directory and
+// rewriting the paths stored for calls to point into CALLS_DIR. With
+// dry_run it only reports what is left.
+func (s *server) handleCallsDirMigrate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	old := s.oldCallsDir()
+	if old == "" {
+		http.Error(w, "no CALLS_DIR_OLD migration in progress", http.StatusConflict)
+		return
+	}
+	var req struct {
+		DryRun bool `json:"dry_run"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	st, err := s.callsDirProgress(r.Context(), old)
+	if err != nil {
+		log.Printf("calls dir status failed: %v", err)
+		http.Error(w, "status failed", http.StatusInternalServerError)
+		return
+	}
+	if req.DryRun {
+		respondJSON(w, st)
+		return
+	}
+	var running int
+	if err := s.db.QueryRowContext(r.Context(), `SELECT COUNT(*) FROM ops_jobs WHERE kind = ? AND status = ?`, opsJobKindCallsDirMigrate, opsJobRunning).Scan(&running); err != nil {
+		log.Printf("calls dir job lookup failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if running > 0 {
+		http.Error(w, "a calls dir migration job is already running", http.StatusConflict)
+		return
+	}
+	params := callsDirMigrateParams{OldDir: old, NewDir: s.cfg.CallsDir}
+	total := st.OldFiles - st.Copied + st.RowsOnOld
+	id, err := s.createOpsJob(opsJobKindCallsDirMigrate, params, total)
+	if err != nil {
+		log.Printf("calls dir job create failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	noteAudit(r, "calls_dir.migrate", strconv.FormatInt(id, 10), nil, map[string]interface{}{"params": params, "total": total})
+	go s.runCallsDirMigrate(s.ctx, id, params)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	respondJSON(w, map[string]interface{}{
+		"job_id":     id,
+		"status":     st,
+		"status_url": fmt.Sprintf("/ops/jobs/%d", id),
+		"stream_url": fmt.Sprintf("/ops/jobs/%d/stream", id),
+	})
+}
+
+// runCallsDirMigrate copies what is left in the old directory, then rewrites
+// stored paths in batches of callsDirRewriteBatch calls, one transaction per
+// batch, checkpointing the last call id so a restart picks up after it.
+// Copying again is cheap since files already in CALLS_DIR are skipped.
+func (s *server) runCallsDirMigrate(ctx context.Context, jobID int64, p callsDirMigrateParams) {
+	var cursor int64
+	var processed, failed int
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&cursor, &processed, &failed)
+	}, `SELECT cursor_id, processed, failed FROM ops_jobs WHERE id = ?`, jobID); err != nil {
+		log.Printf("ops job %d load failed: %v", jobID, err)
+		return
+	}
+	var lastErr string
+	checkpoint := func() {
+		if _, err := execWithRetry(s.db, `UPDATE ops_jobs SET cursor_id = ?, processed = ?, failed = ?, last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+			cursor, processed, failed, nullableString(lastErr), jobID); err != nil {
+			log.Printf("ops job %d checkpoint failed: %v", jobID, err)
+		}
+	}
+
+	if cursor == 0 {
+		files, err := listAudioDir(p.OldDir, nil)
+		if err != nil {
+			s.finishOpsJob(jobID, opsJobFailed, err.Error())
+			return
+		}
+		for i, f := range files {
+			if ctx.Err() != nil {
+				checkpoint()
+				log.Printf("ops job %d interrupted; will resume on restart", jobID)
+				return
+			}
+			copied, err := s.copyIntoCallsDir(filepath.Join(p.OldDir, f.Name()), f.Name())
+			switch {
+			case err != nil:
+				failed++
+				lastErr = fmt.Sprintf("%s: %v", f.Name(), err)
+				log.Printf("calls dir job %d: %s", jobID, lastErr)
+			case copied:
+				processed++
+			}
+			if i%100 == 99 {
+				checkpoint()
+			}
+		}
+		checkpoint()
+	}
+
+	prefix := oldPathPrefix(p.OldDir)
+	for {
+		if ctx.Err() != nil {
+			log.Printf("ops job %d interrupted; will resume on restart", jobID)
+			return
+		}
+		n, last, err := s.rewriteCallsDirBatch(ctx, prefix, p.NewDir, cursor)
+		if err != nil {
+			s.finishOpsJob(jobID, opsJobFailed, err.Error())
+			return
+		}
+		if last == 0 {
+			break
+		}
+		cursor = last
+		processed += n
+		checkpoint()
+	}
+	s.finishOpsJob(jobID, opsJobCompleted, lastErr)
+}
+
+// rewriteCallsDirBatch points the source and processed paths under prefix of
+// the next batch of calls after cursor into newDir. It returns how many
+// calls changed and the last id looked at, 0 once none are left.
+func (s *server) rewriteCallsDirBatch(ctx context.Context, prefix, newDir string, cursor int64) (int, int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, `SELECT id, COALESCE(source_path, ''), COALESCE(processed_path, '') FROM transcriptions
+WHERE id > ? AND (substr(COALESCE(source_path, ''), 1, ?) = ? OR substr(COALESCE(processed_path, ''), 1, ?) = ?)
+ORDER BY id LIMIT ?`, cursor, len(prefix), prefix, len(prefix), prefix, callsDirRewriteBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+	type rewrite struct {
+		id              int64
+		source, process string
+	}
+	var batch []rewrite
+	for rows.Next() {
+		var rw rewrite
+		if err := rows.Scan(&rw.id, &rw.source, &rw.process); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, rw)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if len(batch) == 0 {
+		return 0, 0, nil
+	}
+	move := func(path string) string {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			return filepath.Join(newDir, rest)
+		}
+		return path
+	}
+	for _, rw := range batch {
+		if _, err := tx.ExecContext(ctx, `UPDATE transcriptions SET source_path = ?, processed_path = ? WHERE id = ?`, move(rw.source), nullableString(move(rw.process)), rw.id); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return len(batch), batch[len(batch)-1].id, nil
+}
+
+// handleCallsDirRetire serves POST /ops/calls-dir/retire. Once every
+// recording in the old directory is in CALLS_DIR and no call points into it,
+// the old directory stops being watched and served from, and stays retired
+// across restarts. Its files are left in place.
+func (s *server) handleCallsDirRetire(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	old := s.oldCallsDir()
+	if old == "" {
+		http.Error(w, "no CALLS_DIR_OLD migration in progress", http.StatusConflict)
+		return
+	}
+	st, err := s.callsDirProgress(r.Context(), old)
+	if err != nil {
+		log.Printf("calls dir status failed: %v", err)
+		http.Error(w, "status failed", http.StatusInternalServerError)
+		return
+	}
+	if !st.ReadyToRetire {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		respondJSON(w, st)
+		return
+	}
+	if _, err := execWithRetry(s.db, `INSERT OR REPLACE INTO calls_dir_retirements (old_dir, new_dir, files, retired_by, retired_at) VALUES (?, ?, ?, ?, ?)`,
+		filepath.Clean(old), s.cfg.CallsDir, st.OldFiles, settingsActor(r), time.Now().UTC()); err != nil {
+		log.Printf("calls dir retire failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.callsMigration.mu.Lock()
+	s.callsMigration.old = ""
+	if s.callsMigration.stop != nil {
+		s.callsMigration.stop()
+		s.callsMigration.stop = nil
+	}
+	s.callsMigration.mu.Unlock()
+	log.Printf("calls dir %s retired by %s; unset CALLS_DIR_OLD", old, settingsActor(r))
+	noteAudit(r, "calls_dir.retire", old, nil, map[string]interface{}{"new_dir": s.cfg.CallsDir, "files": st.OldFiles})
+	respondJSON(w, map[string]interface{}{"old_dir": old, "new_dir": s.cfg.CallsDir, "status": "retired", "files": st.OldFiles})
+}
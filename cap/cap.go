@@ -0,0 +1,95 @@
+// Package cap builds Common Alerting Protocol 1.2 messages, the XML format
+// emergency-management tools exchange alerts in. Only the elements this
+// service fills in are modelled; field order follows the CAP 1.2 schema.
+package cap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Namespace is the CAP 1.2 XML namespace.
+const Namespace = "urn:oasis:names:tc:emergency:cap:1.2"
+
+// ContentType is the media type of a CAP message.
+const ContentType = "application/cap+xml"
+
+// Message types.
+const (
+	MsgAlert  = "Alert"
+	MsgUpdate = "Update"
+	MsgCancel = "Cancel"
+)
+
+// Alert is a CAP <alert>.
+type Alert struct {
+	XMLName    xml.Name `xml:"urn:oasis:names:tc:emergency:cap:1.2 alert"`
+	Identifier string   `xml:"identifier"`
+	Sender     string   `xml:"sender"`
+	Sent       string   `xml:"sent"`
+	Status     string   `xml:"status"`
+	MsgType    string   `xml:"msgType"`
+	Scope      string   `xml:"scope"`
+	Note       string   `xml:"note,omitempty"`
+	References string   `xml:"references,omitempty"`
+	Incidents  string   `xml:"incidents,omitempty"`
+	Info       []Info   `xml:"info"`
+}
+
+// Info is a CAP <info> block.
+type Info struct {
+	Language    string  `xml:"language,omitempty"`
+	Category    string  `xml:"category"`
+	Event       string  `xml:"event"`
+	Urgency     string  `xml:"urgency"`
+	Severity    string  `xml:"severity"`
+	Certainty   string  `xml:"certainty"`
+	Effective   string  `xml:"effective,omitempty"`
+	Expires     string  `xml:"expires,omitempty"`
+	SenderName  string  `xml:"senderName,omitempty"`
+	Headline    string  `xml:"headline,omitempty"`
+	Description string  `xml:"description,omitempty"`
+	Web         string  `xml:"web,omitempty"`
+	Parameters  []Value `xml:"parameter"`
+	Areas       []Area  `xml:"area"`
+}
+
+// Value is a CAP name/value pair, used for <parameter> and <geocode>.
+type Value struct {
+	Name  string `xml:"valueName"`
+	Value string `xml:"value"`
+}
+
+// Area is a CAP <area>.
+type Area struct {
+	Desc     string   `xml:"areaDesc"`
+	Circles  []string `xml:"circle"`
+	Geocodes []Value  `xml:"geocode"`
+}
+
+// Marshal renders the alert as an XML document.
+func (a Alert) Marshal() ([]byte, error) {
+	out, err := xml.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// Time formats t as CAP requires: seconds precision and a numeric offset,
+// never "Z".
+func Time(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05-07:00")
+}
+
+// Circle is a CAP circle around lat, lon with a radius in kilometres.
+func Circle(lat, lon, radiusKm float64) string {
+	return fmt.Sprintf("%.5f,%.5f %.3f", lat, lon, radiusKm)
+}
+
+// Reference identifies an earlier message in <references>.
+func Reference(sender, identifier string, sent time.Time) string {
+	return strings.Join([]string{sender, identifier, Time(sent)}, ",")
+}
@@ -0,0 +1,65 @@
+package cap
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalFollowsSchemaOrder(t *testing.T) {
+	est := time.FixedZone("EST", -5*3600)
+	sent := time.Date(2024, 3, 1, 14, 30, 0, 0, est)
+	a := Alert{
+		Identifier: "rollup-7-1",
+		Sender:     "alerts.example",
+		Sent:       Time(sent),
+		Status:     "Actual",
+		MsgType:    MsgUpdate,
+		Scope:      "Public",
+		References: Reference("alerts.example", "rollup-7-0", sent.Add(-time.Hour)),
+		Info: []Info{{
+			Category:  "Fire",
+			Event:     "Structure fire",
+			Urgency:   "Immediate",
+			Severity:  "Severe",
+			Certainty: "Observed",
+			Headline:  "Fire at Main & 1st",
+			Areas:     []Area{{Desc: "Newton", Circles: []string{Circle(41.05, -74.75, 0.8)}}},
+		}},
+	}
+	out, err := a.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	if !strings.HasPrefix(got, "<?xml") || !strings.Contains(got, `<alert xmlns="`+Namespace+`">`) {
+		t.Fatalf("missing header or namespace:\n%s", got)
+	}
+	if !strings.Contains(got, "<sent>2024-03-01T14:30:00-05:00</sent>") {
+		t.Fatalf("sent not in CAP time format:\n%s", got)
+	}
+	if !strings.Contains(got, "<references>alerts.example,rollup-7-0,2024-03-01T13:30:00-05:00</references>") {
+		t.Fatalf("references = \n%s", got)
+	}
+	if !strings.Contains(got, "<circle>41.05000,-74.75000 0.800</circle>") || !strings.Contains(got, "Main &amp; 1st") {
+		t.Fatalf("area or escaping wrong:\n%s", got)
+	}
+	if strings.Contains(got, "<note>") || strings.Contains(got, "<expires>") {
+		t.Fatalf("empty optional elements rendered:\n%s", got)
+	}
+	order := []string{"<identifier>", "<sender>", "<sent>", "<status>", "<msgType>", "<scope>", "<references>", "<info>", "<category>", "<event>", "<urgency>", "<severity>", "<certainty>", "<headline>", "<area>"}
+	last := -1
+	for _, tag := range order {
+		i := strings.Index(got, tag)
+		if i <= last {
+			t.Fatalf("%s out of order:\n%s", tag, got)
+		}
+		last = i
+	}
+}
+
+func TestTimeUsesNumericOffset(t *testing.T) {
+	if got := Time(time.Date(2024, 3, 1, 19, 30, 0, 0, time.UTC)); got != "2024-03-01T19:30:00+00:00" {
+		t.Fatalf("Time = %s", got)
+	}
+}
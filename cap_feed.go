@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/cap"
+	"alert_framework/config"
+	"alert_framework/rollups"
+)
+
+// maxCAPFeedEntries bounds the feed; far more high priority incidents than
+// are ever open at once.
+const maxCAPFeedEntries = 200
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Summary string      `xml:"summary,omitempty"`
+	Links   []atomLink  `xml:"link"`
+	Content atomContent `xml:"content"`
+}
+
+// atomContent carries the entry's CAP alert inline, so a consumer gets every
+// incident in one request.
+type atomContent struct {
+	Type  string    `xml:"type,attr"`
+	Alert cap.Alert `xml:"alert"`
+}
+
+// handleCAPFeed serves GET /cap/feed.xml: an Atom feed with one entry per
+// open high priority incident, each carrying the incident as a CAP 1.2 alert.
+// Entries drop out when their rollup closes or expires.
+func (s *server) handleCAPFeed(w http.ResponseWriter, r *http.Request) {
+	if !s.capFeedRequest(w, r) {
+		return
+	}
+	cfg := s.liveConfig()
+	list, err := s.capFeedRollups(r)
+	if err != nil {
+		log.Printf("cap feed query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	base := strings.TrimSpace(cfg.PublicBaseURL)
+	feed := atomFeed{
+		ID:     base + "/cap/feed.xml",
+		Title:  "High priority incidents",
+		Author: atomAuthor{Name: cfg.CAPSender},
+		Links:  []atomLink{{Rel: "self", Type: "application/atom+xml", Href: base + "/cap/feed.xml"}},
+	}
+	var updated time.Time
+	for _, rollup := range list {
+		alert := rollupFeedAlert(rollup, cfg)
+		href := fmt.Sprintf("%s/cap/alerts/%d.xml", base, rollup.RollupID)
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("urn:cap:%s:rollup-%d", cfg.CAPSender, rollup.RollupID),
+			Title:   alert.Info[0].Headline,
+			Updated: rollup.EndAt.UTC().Format(time.RFC3339),
+			Summary: alert.Info[0].Description,
+			Links:   []atomLink{{Rel: "alternate", Type: cap.ContentType, Href: href}},
+			Content: atomContent{Type: cap.ContentType, Alert: alert},
+		})
+		if rollup.EndAt.After(updated) {
+			updated = rollup.EndAt
+		}
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		http.Error(w, "encode error", http.StatusInternalServerError)
+		return
+	}
+	writeCAPXML(w, r, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
+
+// handleCAPAlert serves GET /cap/alerts/{id}.xml: one incident from the feed
+// as a standalone CAP 1.2 alert.
+func (s *server) handleCAPAlert(w http.ResponseWriter, r *http.Request) {
+	if !s.capFeedRequest(w, r) {
+		return
+	}
+	raw := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/cap/alerts/"), ".xml")
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		http.NotFound(w, r)
+		return
+	}
+	rollup, err := s.fetchRollup(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !inCAPFeed(rollup, s.liveConfig(), time.Now())) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("cap alert %d lookup failed: %v", id, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	out, err := rollupFeedAlert(rollup, s.liveConfig()).Marshal()
+	if err != nil {
+		http.Error(w, "encode error", http.StatusInternalServerError)
+		return
+	}
+	writeCAPXML(w, r, cap.ContentType, out)
+}
+
+// capFeedRequest checks that the feed is on and the method is GET or HEAD.
+// It writes the error response itself when it returns false.
+func (s *server) capFeedRequest(w http.ResponseWriter, r *http.Request) bool {
+	if !s.liveConfig().CAPFeedEnabled {
+		http.NotFound(w, r)
+		return false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func (s *server) capFeedRollups(r *http.Request) ([]rollupResponse, error) {
+	rows, err := s.db.QueryContext(r.Context(), `SELECT `+rollupColumns+` FROM rollups WHERE priority = 'high' AND COALESCE(state, 'active') != ? ORDER BY end_at DESC LIMIT ?`, rollups.StateClosed, maxCAPFeedEntries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cfg := s.liveConfig()
+	now := time.Now()
+	var out []rollupResponse
+	for rows.Next() {
+		var rollup rollupResponse
+		if err := scanRollup(rows, &rollup); err != nil {
+			return nil, err
+		}
+		if inCAPFeed(rollup, cfg, now) {
+			out = append(out, rollup)
+		}
+	}
+	return out, rows.Err()
+}
+
+// inCAPFeed reports whether rollup is an open high priority incident that
+// has not yet expired.
+func inCAPFeed(rollup rollupResponse, cfg config.Config, now time.Time) bool {
+	return rollup.Priority == "high" && rollup.State != rollups.StateClosed && now.Before(rollupExpiry(rollup, cfg))
+}
+
+// rollupExpiry is when an open rollup closes if no further calls arrive.
+func rollupExpiry(rollup rollupResponse, cfg config.Config) time.Time {
+	return rollup.EndAt.Add(time.Duration(cfg.Rollup.CloseAfterMin) * time.Minute)
+}
+
+// rollupFeedAlert is the CAP alert the feed publishes for rollup. The
+// identifier changes with the content, and the message is dated by the
+// rollup's latest call, so an unchanged incident keeps the same message.
+func rollupFeedAlert(rollup rollupResponse, cfg config.Config) cap.Alert {
+	identifier := fmt.Sprintf("rollup-%d-%s", rollup.RollupID, rollupExportFingerprint(rollup)[:8])
+	return rollupCAPAlert(rollup, cfg, cap.MsgAlert, identifier, rollup.EndAt, nil)
+}
+
+// writeCAPXML writes body with an ETag over it, so pollers whose copy is
+// current get a bodyless 304.
+func writeCAPXML(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	if notModified(w, r, `"`+hex.EncodeToString(sum[:12])+`"`, time.Time{}) {
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(body)
+}
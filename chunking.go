@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/errcode"
+	"alert_framework/queue"
+)
+
+const (
+	// maxChunkSeconds keeps each chunk well under the transcription upload
+	// limit at the 16kHz mono mp3 settings used below.
+	maxChunkSeconds = 600.0
+	// chunkOverlapSeconds is repeated at the start of each chunk so a word
+	// spoken across a cut appears whole in one of them; the duplicate text is
+	// removed by formatting.MergeChunkTranscripts.
+	chunkOverlapSeconds = 1.5
+	// silenceNoise and silenceMinSeconds tune ffmpeg silencedetect for radio
+	// traffic, where gaps between transmissions are short but quiet.
+	silenceNoise      = "-35dB"
+	silenceMinSeconds = 0.4
+)
+
+type silenceRange struct {
+	Start, End float64
+}
+
+type chunkSpan struct {
+	Start, End float64
+}
+
+var (
+	silenceStartPattern = regexp.MustCompile(`silence_start: (-?[0-9.]+)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end: (-?[0-9.]+)`)
+)
+
+// jobTimeout scales the job timeout with the length of the recording at path,
+// so a long fire-ground recording is not cut off at the default meant for a
+// short dispatch. Recordings that cannot be probed keep JOB_TIMEOUT_SEC.
+func (s *server) jobTimeout(path string) time.Duration {
+	base := time.Duration(s.cfg.JobTimeoutSec) * time.Second
+	seconds := probeDuration(path)
+	if seconds <= 0 {
+		return base
+	}
+	timeout := queue.ScaleTimeout(base,
+		time.Duration(s.cfg.JobTimeoutPerAudioMinSec)*time.Second,
+		time.Duration(s.cfg.JobTimeoutMaxSec)*time.Second,
+		time.Duration(seconds*float64(time.Second)),
+		audioStages(seconds))
+	if timeout > base {
+		log.Printf("job timeout for %s: %s (%.0fs of audio)", filepath.Base(path), timeout, seconds)
+	}
+	return timeout
+}
+
+// audioStages counts the passes whose run time grows with the recording:
+// preprocessing and transcription, plus, for recordings long enough to need
+// chunking, the two whole-file retries, the silence scan and cutting, and the
+// chunked transcription.
+func audioStages(seconds float64) int {
+	if seconds > maxChunkSeconds {
+		return 6
+	}
+	return 2
+}
+
+// chunkAudio splits path into chunks of at most maxChunkSeconds, cutting in
+// the middle of detected silences where possible. Files that fit in one chunk
+// are returned as is. The returned cleanup removes the chunk files.
+func chunkAudio(ctx context.Context, path, workDir string) ([]string, func(), error) {
+	noop := func() {}
+	duration := probeDuration(path)
+	if duration <= 0 {
+		return nil, noop, fmt.Errorf("unknown duration for %s", path)
+	}
+	if duration <= maxChunkSeconds {
+		return []string{path}, noop, nil
+	}
+	silences, err := detectSilences(ctx, path)
+	if err != nil {
+		return nil, noop, err
+	}
+	spans := planChunks(duration, silences, maxChunkSeconds, chunkOverlapSeconds)
+
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var paths []string
+	cleanup := func() {
+		for _, p := range paths {
+			os.Remove(p)
+		}
+	}
+	for i, span := range spans {
+		chunkPath := filepath.Join(workDir, fmt.Sprintf("%s.part%d.mp3", base, i))
+		cmd := exec.CommandContext(ctx, ffmpegBinary,
+			"-y", "-v", "error",
+			"-ss", strconv.FormatFloat(span.Start, 'f', 3, 64),
+			"-t", strconv.FormatFloat(span.End-span.Start, 'f', 3, 64),
+			"-i", path,
+			"-ac", "1", "-ar", "16000", "-b:a", "64k",
+			chunkPath,
+		)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			cleanup()
+			return nil, noop, errcode.Wrap(errcode.FFmpegFailed, fmt.Errorf("cut chunk %d: %w (stderr: %s)", i, err, strings.TrimSpace(stderr.String())))
+		}
+		paths = append(paths, chunkPath)
+	}
+	return paths, cleanup, nil
+}
+
+// detectSilences runs ffmpeg silencedetect over path.
+func detectSilences(ctx context.Context, path string) ([]silenceRange, error) {
+	cmd := exec.CommandContext(ctx, ffmpegBinary,
+		"-v", "info", "-nostats",
+		"-i", path,
+		"-af", fmt.Sprintf("silencedetect=noise=%s:d=%g", silenceNoise, silenceMinSeconds),
+		"-f", "null", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errcode.Wrap(errcode.FFmpegFailed, fmt.Errorf("silencedetect: %w", err))
+	}
+	return parseSilenceDetect(stderr.String()), nil
+}
+
+// parseSilenceDetect pairs silence_start/silence_end lines from ffmpeg's log.
+// A trailing start without an end (silence running to EOF) is dropped.
+func parseSilenceDetect(log string) []silenceRange {
+	var out []silenceRange
+	start := -1.0
+	for _, line := range strings.Split(log, "\n") {
+		if m := silenceStartPattern.FindStringSubmatch(line); m != nil {
+			if v, err := strconv.ParseFloat(m[1], 64); err == nil {
+				start = v
+			}
+			continue
+		}
+		if m := silenceEndPattern.FindStringSubmatch(line); m != nil && start >= 0 {
+			if v, err := strconv.ParseFloat(m[1], 64); err == nil && v > start {
+				out = append(out, silenceRange{Start: start, End: v})
+			}
+			start = -1
+		}
+	}
+	return out
+}
+
+// planChunks picks cut points no more than maxLen apart. Each cut goes in the
+// middle of the latest silence in the second half of the window; with no
+// silence there, the cut is made at maxLen. Every chunk after the first
+// starts overlap seconds before the previous cut.
+func planChunks(duration float64, silences []silenceRange, maxLen, overlap float64) []chunkSpan {
+	var spans []chunkSpan
+	start := 0.0
+	for duration-start > maxLen {
+		limit := start + maxLen
+		cut := limit
+		for _, sil := range silences {
+			mid := (sil.Start + sil.End) / 2
+			if mid > start+maxLen/2 && mid <= limit {
+				cut = mid
+			}
+		}
+		spans = append(spans, chunkSpan{Start: start, End: cut})
+		start = cut - overlap
+	}
+	return append(spans, chunkSpan{Start: start, End: duration})
+}
@@ -0,0 +1,320 @@
+// Command evaluate manages the offline transcription evaluation set on a
+// running server and compares pipeline runs over it.
+//
+//	evaluate import set.jsonl            upload labeled cases from a manifest
+//	evaluate cases                       list labeled cases
+//	evaluate run -model gpt-4o-transcribe -cleanup v2 -wait
+//	evaluate runs                        compare past runs
+//	evaluate show 12                     per-case results of run 12
+//
+// Each manifest line is a JSON object with audio (a path relative to the
+// manifest), transcript, and optionally address, name and call_filename (the
+// recorder filename the call metadata is parsed from). The server address and
+// admin token come from -server/-token or API_BASE_URL/ADMIN_TOKEN.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+type manifestEntry struct {
+	Audio        string `json:"audio"`
+	Transcript   string `json:"transcript"`
+	Address      string `json:"address"`
+	Name         string `json:"name"`
+	CallFilename string `json:"call_filename"`
+}
+
+type evalCase struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	CallFilename       string `json:"call_filename"`
+	ExpectedTranscript string `json:"expected_transcript"`
+	ExpectedAddress    string `json:"expected_address"`
+}
+
+type evalResult struct {
+	CaseID       int64   `json:"case_id"`
+	Name         string  `json:"name"`
+	Transcript   string  `json:"transcript"`
+	Address      string  `json:"address"`
+	WER          float64 `json:"wer"`
+	RawWER       float64 `json:"raw_wer"`
+	AddressMatch *bool   `json:"address_match"`
+	Error        string  `json:"error"`
+}
+
+type evalRun struct {
+	ID                    int64        `json:"id"`
+	Status                string       `json:"status"`
+	Model                 string       `json:"model"`
+	CleanupPromptVersion  string       `json:"cleanup_prompt_version"`
+	MetadataPromptVersion string       `json:"metadata_prompt_version"`
+	Cases                 int          `json:"cases"`
+	Completed             int          `json:"completed"`
+	MeanWER               *float64     `json:"mean_wer"`
+	MeanRawWER            *float64     `json:"mean_raw_wer"`
+	AddressAccuracy       *float64     `json:"address_accuracy"`
+	Error                 string       `json:"error"`
+	StartedAt             time.Time    `json:"started_at"`
+	Results               []evalResult `json:"results"`
+}
+
+func main() {
+	defaultServer := os.Getenv("API_BASE_URL")
+	if defaultServer == "" {
+		defaultServer = "http://localhost:8000"
+	}
+	server := flag.String("server", defaultServer, "alert server base URL")
+	token := flag.String("token", os.Getenv("ADMIN_TOKEN"), "admin token")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: evaluate [-server URL] [-token TOKEN] import|cases|run|runs|show ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c := &client{base: strings.TrimRight(*server, "/"), token: *token, http: &http.Client{Timeout: 5 * time.Minute}}
+
+	var err error
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "import":
+		err = c.importManifest(args)
+	case "cases":
+		err = c.listCases()
+	case "run":
+		err = c.startRun(args)
+	case "runs":
+		err = c.listRuns()
+	case "show":
+		err = c.showRun(args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "evaluate:", err)
+		os.Exit(1)
+	}
+}
+
+func (c *client) importManifest(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: evaluate import MANIFEST.jsonl")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dir := filepath.Dir(args[0])
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+		var entry manifestEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		audio := entry.Audio
+		if !filepath.IsAbs(audio) {
+			audio = filepath.Join(dir, audio)
+		}
+		var created evalCase
+		if err := c.uploadCase(audio, entry, &created); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		fmt.Printf("added case %d (%s)\n", created.ID, created.Name)
+	}
+	return scanner.Err()
+}
+
+func (c *client) uploadCase(audio string, entry manifestEntry, out *evalCase) error {
+	f, err := os.Open(audio)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fw, err := w.CreateFormFile("audio", filepath.Base(audio))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fw, f); err != nil {
+		return err
+	}
+	w.WriteField("transcript", entry.Transcript)
+	w.WriteField("address", entry.Address)
+	w.WriteField("name", entry.Name)
+	w.WriteField("call_filename", entry.CallFilename)
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.do(http.MethodPost, "/api/eval/cases", w.FormDataContentType(), &body, out)
+}
+
+func (c *client) listCases() error {
+	var resp struct {
+		Cases []evalCase `json:"cases"`
+	}
+	if err := c.do(http.MethodGet, "/api/eval/cases", "", nil, &resp); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tADDRESS\tTRANSCRIPT")
+	for _, ec := range resp.Cases {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", ec.ID, ec.Name, ec.ExpectedAddress, truncate(ec.ExpectedTranscript, 60))
+	}
+	return tw.Flush()
+}
+
+func (c *client) startRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	model := fs.String("model", "", "transcription model (default: the server's setting)")
+	format := fs.String("format", "", "transcription response format (default: the server's setting)")
+	cleanup := fs.String("cleanup", "", "cleanup prompt version (default: the settings prompt)")
+	metadata := fs.String("metadata", "", "metadata prompt version (default: the settings prompt)")
+	cases := fs.String("cases", "", "comma-separated case ids (default: all)")
+	wait := fs.Bool("wait", false, "wait for the run to finish and print its results")
+	fs.Parse(args)
+
+	req := map[string]interface{}{
+		"model":                   *model,
+		"format":                  *format,
+		"cleanup_prompt_version":  *cleanup,
+		"metadata_prompt_version": *metadata,
+	}
+	if *cases != "" {
+		var ids []int64
+		for _, part := range strings.Split(*cases, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid case id %q", part)
+			}
+			ids = append(ids, id)
+		}
+		req["case_ids"] = ids
+	}
+	buf, _ := json.Marshal(req)
+	var run evalRun
+	if err := c.do(http.MethodPost, "/api/eval/runs", "application/json", bytes.NewReader(buf), &run); err != nil {
+		return err
+	}
+	fmt.Printf("started run %d over %d cases\n", run.ID, run.Cases)
+	if !*wait {
+		return nil
+	}
+	for run.Status == "running" {
+		time.Sleep(5 * time.Second)
+		if err := c.do(http.MethodGet, fmt.Sprintf("/api/eval/runs/%d", run.ID), "", nil, &run); err != nil {
+			return err
+		}
+		fmt.Printf("\r%d/%d cases", run.Completed, run.Cases)
+	}
+	fmt.Println()
+	return c.showRun([]string{strconv.FormatInt(run.ID, 10)})
+}
+
+func (c *client) listRuns() error {
+	var resp struct {
+		Runs []evalRun `json:"runs"`
+	}
+	if err := c.do(http.MethodGet, "/api/eval/runs", "", nil, &resp); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTARTED\tSTATUS\tMODEL\tCLEANUP\tMETADATA\tCASES\tWER\tRAW WER\tADDRESS")
+	for _, run := range resp.Runs {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%d/%d\t%s\t%s\t%s\n", run.ID, run.StartedAt.Local().Format("2006-01-02 15:04"), run.Status, run.Model,
+			run.CleanupPromptVersion, run.MetadataPromptVersion, run.Completed, run.Cases, ratio(run.MeanWER), ratio(run.MeanRawWER), ratio(run.AddressAccuracy))
+	}
+	return tw.Flush()
+}
+
+func (c *client) showRun(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: evaluate show RUN_ID")
+	}
+	var run evalRun
+	if err := c.do(http.MethodGet, "/api/eval/runs/"+args[0], "", nil, &run); err != nil {
+		return err
+	}
+	fmt.Printf("run %d: %s, model %s, cleanup %s, metadata %s\n", run.ID, run.Status, run.Model, run.CleanupPromptVersion, run.MetadataPromptVersion)
+	fmt.Printf("mean WER %s (raw %s), address accuracy %s\n", ratio(run.MeanWER), ratio(run.MeanRawWER), ratio(run.AddressAccuracy))
+	if run.Error != "" {
+		fmt.Printf("error: %s\n", run.Error)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CASE\tNAME\tWER\tRAW WER\tADDRESS\tMATCH\tERROR")
+	for _, res := range run.Results {
+		match := "-"
+		if res.AddressMatch != nil {
+			match = strconv.FormatBool(*res.AddressMatch)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%.3f\t%.3f\t%s\t%s\t%s\n", res.CaseID, res.Name, res.WER, res.RawWER, res.Address, match, truncate(res.Error, 60))
+	}
+	return tw.Flush()
+}
+
+func (c *client) do(method, path, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Admin-Token", c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func ratio(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.3f", *v)
+}
+
+func truncate(s string, n int) string {
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,298 @@
+// Command import loads recordings from a legacy archive into a running
+// server, using a manifest that maps each old file to its call.
+//
+//	import -dry-run calls.csv           report what the import would do
+//	import -wait calls.csv              import and wait for every batch
+//	import -dir /mnt/legacy log.jsonl   resolve files against another directory
+//
+// The manifest is a CSV file with a header row, a JSON array, or JSON lines.
+// Columns (or keys) are file, and optionally name, timestamp, call_type,
+// transcript and transcript_file (a text file holding the transcript).
+// Entries with a transcript become done calls without being transcribed;
+// the rest are queued. Calls that already exist are skipped, so a manifest
+// can be imported again after a failure. Files are read by the server, from
+// paths relative to -dir (default: the manifest's directory). The server
+// address and admin token come from -server/-token or
+// API_BASE_URL/ADMIN_TOKEN.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type entry struct {
+	File       string `json:"file"`
+	Name       string `json:"name,omitempty"`
+	Timestamp  string `json:"timestamp,omitempty"`
+	CallType   string `json:"call_type,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+
+	TranscriptFile string `json:"transcript_file,omitempty"`
+}
+
+type importRequest struct {
+	Dir     string  `json:"dir"`
+	Entries []entry `json:"entries"`
+	DryRun  bool    `json:"dry_run"`
+}
+
+// importResponse is the server's estimate on a dry run, and the started job
+// with its estimate otherwise.
+type importResponse struct {
+	JobID    int64          `json:"job_id"`
+	Actions  map[string]int `json:"actions"`
+	Estimate struct {
+		Actions map[string]int `json:"actions"`
+	} `json:"estimate"`
+}
+
+type opsJob struct {
+	ID        int64  `json:"id"`
+	Status    string `json:"status"`
+	Total     int    `json:"total"`
+	Processed int    `json:"processed"`
+	Failed    int    `json:"failed"`
+	LastError string `json:"last_error"`
+}
+
+func main() {
+	defaultServer := os.Getenv("API_BASE_URL")
+	if defaultServer == "" {
+		defaultServer = "http://localhost:8000"
+	}
+	server := flag.String("server", defaultServer, "alert server base URL")
+	token := flag.String("token", os.Getenv("ADMIN_TOKEN"), "admin token")
+	dir := flag.String("dir", "", "directory the server resolves relative file paths against (default: the manifest's directory)")
+	batch := flag.Int("batch", 1000, "entries per import job")
+	dryRun := flag.Bool("dry-run", false, "report what would be imported without importing")
+	wait := flag.Bool("wait", false, "wait for each job to finish before sending the next batch")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: import [-server URL] [-token TOKEN] [-dir DIR] [-batch N] [-dry-run] [-wait] MANIFEST\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *batch <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c := &client{base: strings.TrimRight(*server, "/"), token: *token, http: &http.Client{Timeout: 5 * time.Minute}}
+	if err := run(c, flag.Arg(0), *dir, *batch, *dryRun, *wait); err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		os.Exit(1)
+	}
+}
+
+func run(c *client, manifest, dir string, batch int, dryRun, wait bool) error {
+	entries, err := readManifest(manifest)
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		if dir, err = filepath.Abs(filepath.Dir(manifest)); err != nil {
+			return err
+		}
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("%s: no entries", manifest)
+	}
+	totals := map[string]int{}
+	for start := 0; start < len(entries); start += batch {
+		end := min(start+batch, len(entries))
+		var resp importResponse
+		if err := c.post("/ops/import", importRequest{Dir: dir, Entries: entries[start:end], DryRun: dryRun}, &resp); err != nil {
+			return fmt.Errorf("entries %d-%d: %w", start+1, end, err)
+		}
+		actions := resp.Actions
+		if !dryRun {
+			actions = resp.Estimate.Actions
+			fmt.Printf("entries %d-%d: job %d %s\n", start+1, end, resp.JobID, formatActions(actions))
+		}
+		for k, v := range actions {
+			totals[k] += v
+		}
+		if wait && !dryRun {
+			if err := c.waitJob(resp.JobID); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("%d entries: %s\n", len(entries), formatActions(totals))
+	return nil
+}
+
+// readManifest reads a CSV, JSON array or JSON lines manifest, inlining
+// transcript files relative to the manifest.
+func readManifest(path string) ([]entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []entry
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case strings.EqualFold(filepath.Ext(path), ".csv"):
+		entries, err = readCSV(bytes.NewReader(data))
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		err = json.Unmarshal(trimmed, &entries)
+	default:
+		entries, err = readJSONLines(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	base := filepath.Dir(path)
+	for i := range entries {
+		e := &entries[i]
+		if e.TranscriptFile == "" || e.Transcript != "" {
+			continue
+		}
+		tf := e.TranscriptFile
+		if !filepath.IsAbs(tf) {
+			tf = filepath.Join(base, tf)
+		}
+		text, err := os.ReadFile(tf)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		e.Transcript = strings.TrimSpace(string(text))
+		e.TranscriptFile = ""
+	}
+	return entries, nil
+}
+
+func readCSV(r io.Reader) ([]entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := col["file"]; !ok {
+		return nil, fmt.Errorf("header has no file column")
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	var entries []entry
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{
+			File:           field(rec, "file"),
+			Name:           field(rec, "name"),
+			Timestamp:      field(rec, "timestamp"),
+			CallType:       field(rec, "call_type"),
+			Transcript:     field(rec, "transcript"),
+			TranscriptFile: field(rec, "transcript_file"),
+		})
+	}
+}
+
+func readJSONLines(r io.Reader) ([]entry, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	var entries []entry
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal([]byte(raw), &e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func formatActions(actions map[string]int) string {
+	var parts []string
+	for _, k := range []string{"done", "transcribe", "exists", "missing", "invalid"} {
+		if actions[k] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", actions[k], k))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing to do"
+	}
+	return strings.Join(parts, ", ")
+}
+
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func (c *client) waitJob(id int64) error {
+	for {
+		var job opsJob
+		if err := c.do(http.MethodGet, fmt.Sprintf("/ops/jobs/%d", id), nil, &job); err != nil {
+			return err
+		}
+		fmt.Printf("\rjob %d: %d/%d imported, %d failed", id, job.Processed, job.Total, job.Failed)
+		if job.Status != "running" {
+			fmt.Println()
+			if job.LastError != "" {
+				fmt.Printf("job %d last error: %s\n", id, job.LastError)
+			}
+			return nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (c *client) post(path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodPost, path, bytes.NewReader(body), out)
+}
+
+func (c *client) do(method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-Admin-Token", c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,119 @@
+// Command replay re-sends a call's webhook payload from a running server to
+// an endpoint of your choice, so integrations can be built against real
+// payloads without waiting for live calls.
+//
+//	replay -to http://localhost:9000/hook CALL.mp3     send the stored payload
+//	replay -regenerate -to URL -secret S CALL.mp3      rebuild from the current row and sign
+//	replay CALL.mp3 > payload.json                     print the payload only
+//
+// Without -secret, replays to a URL configured as a webhook endpoint are
+// signed with that endpoint's secret. The server address and admin token come
+// from -server/-token or API_BASE_URL/ADMIN_TOKEN.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type replayRequest struct {
+	Filename     string `json:"filename"`
+	URL          string `json:"url,omitempty"`
+	Secret       string `json:"secret,omitempty"`
+	Regenerate   bool   `json:"regenerate"`
+	IncludeAudio bool   `json:"include_audio"`
+}
+
+type replayResponse struct {
+	Source     string          `json:"source"`
+	StoredAt   *time.Time      `json:"stored_at"`
+	Sent       bool            `json:"sent"`
+	Signed     bool            `json:"signed"`
+	StatusCode int             `json:"status_code"`
+	Response   string          `json:"response"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+func main() {
+	defaultServer := os.Getenv("API_BASE_URL")
+	if defaultServer == "" {
+		defaultServer = "http://localhost:8000"
+	}
+	server := flag.String("server", defaultServer, "alert server base URL")
+	token := flag.String("token", os.Getenv("ADMIN_TOKEN"), "admin token")
+	to := flag.String("to", "", "endpoint URL to send the payload to; empty prints it")
+	secret := flag.String("secret", "", "signing secret for the endpoint")
+	regenerate := flag.Bool("regenerate", false, "rebuild the payload from current call data instead of the stored copy")
+	audio := flag.Bool("audio", false, "attach the recording as include_audio endpoints receive it")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: replay [-server URL] [-token TOKEN] [-to URL] [-secret S] [-regenerate] [-audio] FILENAME\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	req := replayRequest{Filename: flag.Arg(0), URL: *to, Secret: *secret, Regenerate: *regenerate, IncludeAudio: *audio}
+	if err := replay(strings.TrimRight(*server, "/"), *token, req); err != nil {
+		fmt.Fprintln(os.Stderr, "replay:", err)
+		os.Exit(1)
+	}
+}
+
+func replay(base, token string, req replayRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, base+"/api/admin/replay", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Admin-Token", token)
+	resp, err := (&http.Client{Timeout: time.Minute}).Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out replayResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return err
+	}
+	if !out.Sent {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, out.Payload, "", "  "); err != nil {
+			return err
+		}
+		fmt.Println(pretty.String())
+		return nil
+	}
+	source := out.Source
+	if out.StoredAt != nil {
+		source += " " + out.StoredAt.Format(time.RFC3339)
+	}
+	signed := "unsigned"
+	if out.Signed {
+		signed = "signed"
+	}
+	fmt.Printf("sent %s payload (%s) to %s: %d\n", source, signed, req.URL, out.StatusCode)
+	if reply := strings.TrimSpace(out.Response); reply != "" {
+		fmt.Println(reply)
+	}
+	if out.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %d", out.StatusCode)
+	}
+	return nil
+}
@@ -7,33 +7,205 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
+	"alert_framework/imap"
+	"alert_framework/mqtt"
+	"alert_framework/redis"
+
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds service configuration derived from environment variables.
 type Config struct {
-	HTTPPort           string
-	CallsDir           string
-	JobQueueSize       int
-	WorkerCount        int
-	JobTimeoutSec      int
-	GroupMeBotID       string
-	GroupMeToken       string
-	WorkDir            string
-	DBPath             string
-	DevUI              bool
-	MapboxToken        string
-	PublicBaseURL      string
-	AudioFilterEnabled bool
-	FFMPEGBin          string
-	NLP                NLPConfig
-	NLPConfigPath      string
-	StrictConfig       bool
-	InDocker           bool
-	Rollup             RollupConfig
+	HTTPPort                 string
+	GRPCPort                 string
+	CallsDir                 string
+	CallsDirOld              string
+	JobQueueSize             int
+	WorkerCount              int
+	JobTimeoutSec            int
+	JobTimeoutPerAudioMinSec int
+	JobTimeoutMaxSec         int
+	GroupMeBotID             string
+	GroupMeToken             string
+	WorkDir                  string
+	WorkFileGraceSec         int
+	DBPath                   string
+	DevUI                    bool
+	MapboxToken              string
+	PublicBaseURL            string
+	AudioFilterEnabled       bool
+	FFMPEGBin                string
+	NLP                      NLPConfig
+	NLPConfigPath            string
+	StrictConfig             bool
+	InDocker                 bool
+	Rollup                   RollupConfig
+	LLM                      LLMConfig
+	ClassifyFewShot          int
+	LocalWhisperBin          string
+	LocalWhisperModel        string
+	LocalWhisperEngine       string
+	DraftEngine              string
+	DraftBin                 string
+	DraftModel               string
+	TTSEngine                string
+	TTSBin                   string
+	TTSModel                 string
+	TTSVoice                 string
+	OpenAIBreakerThreshold   int
+	OpenAIBreakerCooldownSec int
+	DBCheckpointIntervalSec  int
+	DBVacuumHour             int
+	DBQueryTimeoutSec        int
+	QueryCacheTTLSec         int
+	IntegrityCheckHours      int
+	IntegrityRepair          bool
+	CADIngestToken           string
+	CADFieldMap              map[string]string
+	CADMatchWindowSec        int
+	UploadToken              string
+	UploadMaxMB              int
+	UploadExpiryHours        int
+	RedactionMode            string
+	RestrictedCallFlags      []string
+	ProfanityFilter          bool
+	FingerprintThreshold     float64
+	FingerprintWindowSec     int
+	ArchiveDir               string
+	QuarantineDir            string
+	MinAudioSec              float64
+	ArchiveHour              int
+	ArchiveGroupBy           string
+	RemoteSources            []RemoteSource
+	FilenamePatterns         []FilenamePattern
+	OpenAIMonthlyBudgetUSD   float64
+	OpenAIPricing            map[string]ModelPrice
+	MapProxyRatePerMin       int
+	MapTileCacheTTLSec       int
+	AlertSuppressionSec      int
+	EscalationCategories     []string
+	EscalationAfterMin       int
+	EscalationGroupMeBotID   string
+	EscalationWebhookURL     string
+	EscalationWebhookSecret  string
+	TalkgroupSilenceMin      int
+	TalkgroupMinDailyCalls   int
+	TalkgroupSilenceBotID    string
+	TalkgroupWebhookURL      string
+	TalkgroupWebhookSecret   string
+	WatcherCheckSec          int
+	WatcherAlertBotID        string
+	WatcherWebhookURL        string
+	WatcherWebhookSecret     string
+	SLOTarget                float64
+	SLOLatencySec            int
+	SLOBurnRateAlert         float64
+	RepeatLocationCalls      int
+	RepeatLocationDays       int
+	TwilioAccountSID         string
+	TwilioAuthToken          string
+	TwilioFromNumber         string
+	TwilioSMSTo              []string
+	TwilioSMSCategories      []string
+	TwilioCalloutTo          []string
+	Active911Enabled         bool
+	Active911URL             string
+	Active911Token           string
+	CAPExportEnabled         bool
+	CAPExportURL             string
+	CAPExportToken           string
+	CAPSender                string
+	CAPSameCode              string
+	CAPFeedEnabled           bool
+	MQTTBrokerURL            string
+	MQTTUsername             string
+	MQTTPassword             string
+	IMAPURL                  string
+	IMAPUsername             string
+	IMAPPassword             string
+	IMAPPollSec              int
+	IMAPSenders              map[string]string
+	PageMatchWindowSec       int
+	EmbedSecret              string
+	ShareSecret              string
+	ShadowPercent            int
+	ShadowModel              string
+	ShadowCleanupVersion     string
+	ShadowMetadataVersion    string
+	MileMarkersPath          string
+	LeaderElection           bool
+	LeaderLeaseSec           int
+	InstanceID               string
+	QueueBackend             string
+	RedisURL                 string
+	RedisQueueKey            string
+	StationsPath             string
+	StationFarMiles          float64
+	HydrantFarFeet           float64
+	RegionBoundaryPath       string
+}
+
+// ModelPrice is what OpenAI charges for a model, in USD. Token prices are per
+// million tokens; audio is per minute.
+type ModelPrice struct {
+	InputPerMTok   float64 `json:"input_per_mtok"`
+	OutputPerMTok  float64 `json:"output_per_mtok"`
+	AudioPerMinute float64 `json:"audio_per_minute"`
+}
+
+// RemoteSource is a remote call archive polled for new recordings. Type
+// "http" reads a web server directory index; "json" reads a JSON call list
+// such as a Broadcastify-style archive API.
+type RemoteSource struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	URL         string            `json:"url"`
+	IntervalSec int               `json:"interval_sec"`
+	MaxPerPoll  int               `json:"max_per_poll"`
+	Headers     map[string]string `json:"headers"`
+}
+
+// FilenamePattern parses the filenames of one feed. Pattern is a regular
+// expression matched against the filename without its extension; its named
+// groups give the call time, either year, month, day, hour, minute and
+// second, or epoch (Unix seconds), or timestamp read with TimeLayout, and
+// optionally agency, town and call_type. Source limits the pattern to files
+// from that ingest source, such as "watcher" or "remote:county".
+type FilenamePattern struct {
+	Name       string `json:"name"`
+	Source     string `json:"source,omitempty"`
+	Pattern    string `json:"pattern"`
+	TimeLayout string `json:"time_layout,omitempty"`
+}
+
+// Compile compiles the pattern and checks that its groups give a call time.
+func (p FilenamePattern) Compile() (*regexp.Regexp, error) {
+	re, err := regexp.Compile(p.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	groups := map[string]bool{}
+	for _, name := range re.SubexpNames() {
+		groups[name] = true
+	}
+	switch {
+	case groups["epoch"]:
+	case groups["timestamp"]:
+		if p.TimeLayout == "" {
+			return nil, errors.New("timestamp group needs time_layout")
+		}
+	default:
+		for _, g := range []string{"year", "month", "day", "hour", "minute", "second"} {
+			if !groups[g] {
+				return nil, fmt.Errorf("missing %s group (or epoch, or timestamp with time_layout)", g)
+			}
+		}
+	}
+	return re, nil
 }
 
 type fileConfig struct {
@@ -43,6 +215,20 @@ type fileConfig struct {
 	DBPath   string           `json:"db_path" yaml:"db_path"`
 	NLP      NLPConfig        `json:"nlp" yaml:"nlp"`
 	Rollup   rollupFileConfig `json:"rollup" yaml:"rollup"`
+	LLM      llmFileConfig    `json:"llm" yaml:"llm"`
+	Notify   notifyFileConfig `json:"notify" yaml:"notify"`
+}
+
+// notifyFileConfig holds the alerting settings that may also come from the
+// config file, so they can be changed with a reload. Environment variables
+// win over the file.
+type notifyFileConfig struct {
+	GroupMeBotID           string   `json:"groupme_bot_id" yaml:"groupme_bot_id"`
+	AlertSuppressionSec    *int     `json:"alert_suppression_window_sec" yaml:"alert_suppression_window_sec"`
+	EscalationCategories   []string `json:"escalation_categories" yaml:"escalation_categories"`
+	EscalationAfterMin     *int     `json:"escalation_after_min" yaml:"escalation_after_min"`
+	EscalationGroupMeBotID string   `json:"escalation_groupme_bot_id" yaml:"escalation_groupme_bot_id"`
+	EscalationWebhookURL   string   `json:"escalation_webhook_url" yaml:"escalation_webhook_url"`
 }
 
 const (
@@ -55,8 +241,63 @@ const (
 	maxQueueSize         = 1024
 	defaultWorkerCount   = 4
 	defaultJobTimeoutSec = 60
+	defaultOpenAIBaseURL = "https://api.openai.com"
+
+	defaultOpenAIBreakerThreshold   = 5
+	defaultOpenAIBreakerCooldownSec = 60
+	defaultDBCheckpointIntervalSec  = 300
+	defaultDBVacuumHour             = 3
+	defaultDBQueryTimeoutSec        = 15
+	defaultQueryCacheTTLSec         = 10
+	defaultIntegrityCheckHours      = 6
+	defaultCADMatchWindowSec        = 300
+	defaultUploadMaxMB              = 1024
+	defaultUploadExpiryHours        = 24
+	defaultTalkgroupMinDailyCalls   = 5
+	defaultWatcherCheckSec          = 60
+	defaultSLOTarget                = 0.95
+	defaultSLOLatencySec            = 120
+	defaultSLOBurnRateAlert         = 4
+	defaultIMAPPollSec              = 60
+	defaultPageMatchWindowSec       = 600
+	defaultCAPSender                = "alert_framework"
+	// defaultCAPSameCode is the SAME code for Sussex County, NJ, the area the
+	// built-in geofence covers.
+	defaultCAPSameCode              = "034037"
+	defaultRepeatLocationDays       = 30
+	defaultFingerprintThreshold     = 0.8
+	defaultFingerprintWindowSec     = 120
+	defaultMinAudioSec              = 0.5
+	defaultArchiveHour              = 1
+	defaultRemoteIntervalSec        = 60
+	defaultRemoteMaxPerPoll         = 50
+	defaultMapProxyRatePerMin       = 600
+	defaultAlertSuppressionSec      = 3600
+	defaultEscalationAfterMin       = 10
+	defaultJobTimeoutPerAudioMinSec = 10
+	defaultJobTimeoutMaxSec         = 3600
+	defaultLeaderLeaseSec           = 30
+	defaultWorkFileGraceSec         = 900
 )
 
+// defaultRestrictedCallFlags are the call taxonomy flags (see
+// formatting.CallFlags) hidden from public endpoints and notifications.
+var defaultRestrictedCallFlags = []string{"mental_health", "overdose", "juvenile"}
+
+// DefaultCADFieldMap maps CAD incident fields to payload keys. CAD_FIELD_MAP
+// overrides individual entries; dotted keys address nested objects.
+var DefaultCADFieldMap = map[string]string{
+	"id":        "id",
+	"time":      "time",
+	"talkgroup": "talkgroup",
+	"address":   "address",
+	"town":      "town",
+	"call_type": "call_type",
+	"units":     "units",
+	"latitude":  "latitude",
+	"longitude": "longitude",
+}
+
 // RollupConfig captures rollup grouping and LLM summarization settings.
 type RollupConfig struct {
 	LookbackHours      int
@@ -68,31 +309,111 @@ type RollupConfig struct {
 	PromptVersion      string
 	LLMModel           string
 	LLMBaseURL         string
+	// MonitorAfterMin and CloseAfterMin are the quiet periods (minutes since
+	// the last related call) after which a rollup moves to monitoring and then
+	// closed.
+	MonitorAfterMin int
+	CloseAfterMin   int
+	NotifyClosures  bool
+	// NotifyCallThreshold sends a growth update once this many calls land in
+	// a rollup within NotifyWindowMin (0 disables). NotifyPriority sends one
+	// when the rollup reaches that priority ("off" disables).
+	NotifyCallThreshold int
+	NotifyWindowMin     int
+	NotifyPriority      string
+	// Strategy picks how calls are grouped: "greedy" chains calls onto the
+	// nearest recent rollup, "dbscan" clusters on distance and time, and
+	// "address" groups calls at the same street and town. DBSCANMinPoints
+	// is how many calls within reach make a dbscan core point.
+	Strategy        string
+	DBSCANMinPoints int
 }
 
 type rollupFileConfig struct {
-	LookbackHours      *int     `json:"lookback_hours" yaml:"lookback_hours"`
-	ChainWindowMin     *int     `json:"chain_window_min" yaml:"chain_window_min"`
-	RadiusMeters       *float64 `json:"radius_meters" yaml:"radius_meters"`
-	MaxCalls           *int     `json:"max_calls" yaml:"max_calls"`
-	RefreshIntervalSec *int     `json:"refresh_interval_sec" yaml:"refresh_interval_sec"`
-	LLMEnabled         *bool    `json:"llm_enabled" yaml:"llm_enabled"`
-	PromptVersion      string   `json:"prompt_version" yaml:"prompt_version"`
-	LLMModel           string   `json:"llm_model" yaml:"llm_model"`
-	LLMBaseURL         string   `json:"llm_base_url" yaml:"llm_base_url"`
+	LookbackHours       *int     `json:"lookback_hours" yaml:"lookback_hours"`
+	ChainWindowMin      *int     `json:"chain_window_min" yaml:"chain_window_min"`
+	RadiusMeters        *float64 `json:"radius_meters" yaml:"radius_meters"`
+	MaxCalls            *int     `json:"max_calls" yaml:"max_calls"`
+	RefreshIntervalSec  *int     `json:"refresh_interval_sec" yaml:"refresh_interval_sec"`
+	LLMEnabled          *bool    `json:"llm_enabled" yaml:"llm_enabled"`
+	PromptVersion       string   `json:"prompt_version" yaml:"prompt_version"`
+	LLMModel            string   `json:"llm_model" yaml:"llm_model"`
+	LLMBaseURL          string   `json:"llm_base_url" yaml:"llm_base_url"`
+	MonitorAfterMin     *int     `json:"monitor_after_min" yaml:"monitor_after_min"`
+	CloseAfterMin       *int     `json:"close_after_min" yaml:"close_after_min"`
+	NotifyClosures      *bool    `json:"notify_closures" yaml:"notify_closures"`
+	NotifyCallThreshold *int     `json:"notify_call_threshold" yaml:"notify_call_threshold"`
+	NotifyWindowMin     *int     `json:"notify_window_min" yaml:"notify_window_min"`
+	NotifyPriority      string   `json:"notify_priority" yaml:"notify_priority"`
+	Strategy            string   `json:"strategy" yaml:"strategy"`
+	DBSCANMinPoints     *int     `json:"dbscan_min_points" yaml:"dbscan_min_points"`
+}
+
+// LLMConfig routes the OpenAI-compatible chat stages. BaseURL applies to every
+// OpenAI call; the per-stage entries let cleanup, classification and refinement
+// run against a local server (Ollama, vLLM) instead.
+type LLMConfig struct {
+	BaseURL  string
+	Cleanup  LLMStageConfig
+	Classify LLMStageConfig
+	Refine   LLMStageConfig
+	Redact   LLMStageConfig
+	Roles    LLMStageConfig
+}
+
+// LLMStageConfig overrides the endpoint and model for a single stage. An empty
+// Model keeps the stage's built-in default.
+type LLMStageConfig struct {
+	BaseURL string `json:"base_url" yaml:"base_url"`
+	Model   string `json:"model" yaml:"model"`
+}
+
+type llmFileConfig struct {
+	BaseURL  string         `json:"base_url" yaml:"base_url"`
+	Cleanup  LLMStageConfig `json:"cleanup" yaml:"cleanup"`
+	Classify LLMStageConfig `json:"classify" yaml:"classify"`
+	Refine   LLMStageConfig `json:"refine" yaml:"refine"`
+	Redact   LLMStageConfig `json:"redact" yaml:"redact"`
+	Roles    LLMStageConfig `json:"roles" yaml:"roles"`
+}
+
+// URL joins the configured base with an API path such as /v1/chat/completions.
+func (c LLMConfig) URL(path string) string {
+	return joinURL(c.BaseURL, path)
+}
+
+// URL joins the stage base with an API path such as /v1/chat/completions.
+func (s LLMStageConfig) URL(path string) string {
+	return joinURL(s.BaseURL, path)
+}
+
+// ModelOr returns the stage model override, or fallback when none is set.
+func (s LLMStageConfig) ModelOr(fallback string) string {
+	if m := strings.TrimSpace(s.Model); m != "" {
+		return m
+	}
+	return fallback
 }
 
 func defaultRollupConfig() RollupConfig {
 	return RollupConfig{
-		LookbackHours:      6,
-		ChainWindowMin:     30,
-		RadiusMeters:       800,
-		MaxCalls:           50,
-		RefreshIntervalSec: 60,
-		LLMEnabled:         true,
-		PromptVersion:      "v1",
-		LLMModel:           "gpt-4o-mini",
-		LLMBaseURL:         "https://api.openai.com",
+		LookbackHours:       6,
+		ChainWindowMin:      30,
+		RadiusMeters:        800,
+		MaxCalls:            50,
+		RefreshIntervalSec:  60,
+		LLMEnabled:          true,
+		PromptVersion:       "v1",
+		LLMModel:            "gpt-4o-mini",
+		LLMBaseURL:          "https://api.openai.com",
+		MonitorAfterMin:     30,
+		CloseAfterMin:       120,
+		NotifyClosures:      true,
+		NotifyCallThreshold: 4,
+		NotifyWindowMin:     30,
+		NotifyPriority:      "high",
+		Strategy:            "greedy",
+		DBSCANMinPoints:     2,
 	}
 }
 
@@ -110,11 +431,98 @@ func Load() (Config, error) {
 		AudioFilterEnabled: parseBoolEnvDefault("AUDIO_FILTER_ENABLED", true),
 		FFMPEGBin:          getEnv("FFMPEG_BIN", "ffmpeg"),
 		StrictConfig:       parseBoolEnv("STRICT_CONFIG"),
+		LeaderElection:     parseBoolEnv("LEADER_ELECTION"),
+		LeaderLeaseSec:     defaultLeaderLeaseSec,
+		InstanceID:         strings.TrimSpace(os.Getenv("INSTANCE_ID")),
+		QueueBackend:       strings.ToLower(getEnv("QUEUE_BACKEND", "memory")),
+		RedisURL:           strings.TrimSpace(os.Getenv("REDIS_URL")),
+		RedisQueueKey:      getEnv("REDIS_QUEUE_KEY", "alert_framework:jobs"),
 		InDocker:           parseBoolEnv("IN_DOCKER"),
+
+		JobTimeoutPerAudioMinSec: defaultJobTimeoutPerAudioMinSec,
+		JobTimeoutMaxSec:         defaultJobTimeoutMaxSec,
+		WorkFileGraceSec:         defaultWorkFileGraceSec,
+		OpenAIBreakerThreshold:   defaultOpenAIBreakerThreshold,
+		OpenAIBreakerCooldownSec: defaultOpenAIBreakerCooldownSec,
+		DBCheckpointIntervalSec:  defaultDBCheckpointIntervalSec,
+		DBVacuumHour:             defaultDBVacuumHour,
+		DBQueryTimeoutSec:        defaultDBQueryTimeoutSec,
+		QueryCacheTTLSec:         defaultQueryCacheTTLSec,
+		IntegrityCheckHours:      defaultIntegrityCheckHours,
+		IntegrityRepair:          parseBoolEnv("INTEGRITY_REPAIR"),
+		CADIngestToken:           strings.TrimSpace(os.Getenv("CAD_INGEST_TOKEN")),
+		CADMatchWindowSec:        defaultCADMatchWindowSec,
+		LocalWhisperBin:          strings.TrimSpace(os.Getenv("LOCAL_WHISPER_BIN")),
+		LocalWhisperModel:        strings.TrimSpace(os.Getenv("LOCAL_WHISPER_MODEL")),
+		LocalWhisperEngine:       strings.ToLower(strings.TrimSpace(getEnv("LOCAL_WHISPER_ENGINE", "whisper.cpp"))),
+		DraftEngine:              strings.ToLower(strings.TrimSpace(os.Getenv("DRAFT_ENGINE"))),
+		DraftBin:                 strings.TrimSpace(os.Getenv("DRAFT_BIN")),
+		DraftModel:               strings.TrimSpace(os.Getenv("DRAFT_MODEL")),
+		TTSEngine:                strings.ToLower(strings.TrimSpace(getEnv("TTS_ENGINE", "openai"))),
+		TTSBin:                   strings.TrimSpace(os.Getenv("TTS_BIN")),
+		TTSModel:                 strings.TrimSpace(os.Getenv("TTS_MODEL")),
+		TTSVoice:                 strings.TrimSpace(os.Getenv("TTS_VOICE")),
+		UploadToken:              strings.TrimSpace(os.Getenv("UPLOAD_TOKEN")),
+		UploadMaxMB:              defaultUploadMaxMB,
+		UploadExpiryHours:        defaultUploadExpiryHours,
+		RedactionMode:            strings.ToLower(strings.TrimSpace(getEnv("REDACTION_MODE", "llm"))),
+		RestrictedCallFlags:      parseListEnv("RESTRICTED_CALL_FLAGS", defaultRestrictedCallFlags),
+		ProfanityFilter:          parseBoolEnvDefault("PROFANITY_FILTER", true),
+		FingerprintThreshold:     defaultFingerprintThreshold,
+		FingerprintWindowSec:     defaultFingerprintWindowSec,
+		MinAudioSec:              defaultMinAudioSec,
+		ArchiveHour:              defaultArchiveHour,
+		ArchiveGroupBy:           strings.ToLower(strings.TrimSpace(getEnv("ARCHIVE_GROUP_BY", "agency"))),
+		MapProxyRatePerMin:       defaultMapProxyRatePerMin,
+		AlertSuppressionSec:      defaultAlertSuppressionSec,
+		EscalationAfterMin:       defaultEscalationAfterMin,
+		EscalationWebhookSecret:  strings.TrimSpace(os.Getenv("ESCALATION_WEBHOOK_SECRET")),
+		TalkgroupMinDailyCalls:   defaultTalkgroupMinDailyCalls,
+		TalkgroupSilenceBotID:    strings.TrimSpace(os.Getenv("TALKGROUP_SILENCE_GROUPME_BOT_ID")),
+		TalkgroupWebhookURL:      strings.TrimSpace(os.Getenv("TALKGROUP_SILENCE_WEBHOOK_URL")),
+		TalkgroupWebhookSecret:   strings.TrimSpace(os.Getenv("TALKGROUP_SILENCE_WEBHOOK_SECRET")),
+		WatcherCheckSec:          defaultWatcherCheckSec,
+		WatcherAlertBotID:        strings.TrimSpace(os.Getenv("WATCHER_ALERT_GROUPME_BOT_ID")),
+		WatcherWebhookURL:        strings.TrimSpace(os.Getenv("WATCHER_ALERT_WEBHOOK_URL")),
+		WatcherWebhookSecret:     strings.TrimSpace(os.Getenv("WATCHER_ALERT_WEBHOOK_SECRET")),
+		SLOTarget:                defaultSLOTarget,
+		SLOLatencySec:            defaultSLOLatencySec,
+		SLOBurnRateAlert:         defaultSLOBurnRateAlert,
+		RepeatLocationDays:       defaultRepeatLocationDays,
+		TwilioAccountSID:         strings.TrimSpace(os.Getenv("TWILIO_ACCOUNT_SID")),
+		TwilioAuthToken:          strings.TrimSpace(os.Getenv("TWILIO_AUTH_TOKEN")),
+		TwilioFromNumber:         strings.TrimSpace(os.Getenv("TWILIO_FROM_NUMBER")),
+		TwilioSMSTo:              parseListEnv("TWILIO_SMS_TO", nil),
+		TwilioSMSCategories:      parseListEnv("TWILIO_SMS_CATEGORIES", nil),
+		TwilioCalloutTo:          parseListEnv("TWILIO_CALLOUT_TO", nil),
+		Active911Enabled:         parseBoolEnv("ACTIVE911_ENABLED"),
+		Active911URL:             strings.TrimSpace(os.Getenv("ACTIVE911_URL")),
+		Active911Token:           strings.TrimSpace(os.Getenv("ACTIVE911_TOKEN")),
+		CAPExportEnabled:         parseBoolEnv("CAP_EXPORT_ENABLED"),
+		CAPExportURL:             strings.TrimSpace(os.Getenv("CAP_EXPORT_URL")),
+		CAPExportToken:           strings.TrimSpace(os.Getenv("CAP_EXPORT_TOKEN")),
+		CAPSender:                defaultCAPSender,
+		CAPSameCode:              defaultCAPSameCode,
+		CAPFeedEnabled:           parseBoolEnv("CAP_FEED_ENABLED"),
+		MQTTBrokerURL:            strings.TrimSpace(os.Getenv("MQTT_BROKER_URL")),
+		MQTTUsername:             strings.TrimSpace(os.Getenv("MQTT_USERNAME")),
+		MQTTPassword:             os.Getenv("MQTT_PASSWORD"),
+		IMAPURL:                  strings.TrimSpace(os.Getenv("IMAP_URL")),
+		IMAPUsername:             strings.TrimSpace(os.Getenv("IMAP_USERNAME")),
+		IMAPPassword:             os.Getenv("IMAP_PASSWORD"),
+		IMAPPollSec:              defaultIMAPPollSec,
+		PageMatchWindowSec:       defaultPageMatchWindowSec,
+		EmbedSecret:              strings.TrimSpace(os.Getenv("EMBED_SECRET")),
+		ShareSecret:              strings.TrimSpace(os.Getenv("SHARE_SECRET")),
+		ShadowModel:              strings.TrimSpace(os.Getenv("SHADOW_MODEL")),
+		ShadowCleanupVersion:     strings.TrimSpace(os.Getenv("SHADOW_CLEANUP_PROMPT_VERSION")),
+		ShadowMetadataVersion:    strings.TrimSpace(os.Getenv("SHADOW_METADATA_PROMPT_VERSION")),
+		MileMarkersPath:          strings.TrimSpace(os.Getenv("MILE_MARKERS_PATH")),
+		StationsPath:             strings.TrimSpace(os.Getenv("STATIONS_PATH")),
+		RegionBoundaryPath:       strings.TrimSpace(os.Getenv("REGION_BOUNDARY_PATH")),
 	}
 
-	configPath := getEnv("CONFIG_PATH", filepath.Join("config", "config.yaml"))
-	nlpPath := getEnv("NLP_CONFIG_PATH", configPath)
+	configPath, nlpPath := configPaths()
 	cfg.NLPConfigPath = nlpPath
 
 	fileCfg, fileErr := loadFileConfig(configPath)
@@ -127,7 +535,26 @@ func Load() (Config, error) {
 
 	cfg.Rollup = applyRollupOverrides(defaultRollupConfig(), fileCfg.Rollup)
 
+	notify := fileCfg.Notify
+	cfg.GroupMeBotID = firstNonEmpty(cfg.GroupMeBotID, strings.TrimSpace(notify.GroupMeBotID))
+	if notify.AlertSuppressionSec != nil && *notify.AlertSuppressionSec >= 0 {
+		cfg.AlertSuppressionSec = *notify.AlertSuppressionSec
+	}
+	var fileCategories []string
+	for _, c := range notify.EscalationCategories {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" {
+			fileCategories = append(fileCategories, c)
+		}
+	}
+	cfg.EscalationCategories = parseListEnv("ESCALATION_CATEGORIES", fileCategories)
+	if notify.EscalationAfterMin != nil && *notify.EscalationAfterMin > 0 {
+		cfg.EscalationAfterMin = *notify.EscalationAfterMin
+	}
+	cfg.EscalationGroupMeBotID = strings.TrimSpace(firstNonEmpty(os.Getenv("ESCALATION_GROUPME_BOT_ID"), notify.EscalationGroupMeBotID))
+	cfg.EscalationWebhookURL = strings.TrimSpace(firstNonEmpty(os.Getenv("ESCALATION_WEBHOOK_URL"), notify.EscalationWebhookURL))
+
 	cfg.CallsDir = firstNonEmpty(os.Getenv("CALLS_DIR"), fileCfg.CallsDir, defaultCallsDir)
+	cfg.CallsDirOld = strings.TrimSpace(os.Getenv("CALLS_DIR_OLD"))
 	cfg.WorkDir = firstNonEmpty(os.Getenv("WORK_DIR"), fileCfg.WorkDir, defaultWorkDir)
 	if dbPath := os.Getenv("DB_PATH"); dbPath != "" {
 		cfg.DBPath = dbPath
@@ -136,6 +563,8 @@ func Load() (Config, error) {
 	} else {
 		cfg.DBPath = filepath.Join(cfg.WorkDir, defaultDBFile)
 	}
+	cfg.ArchiveDir = firstNonEmpty(os.Getenv("ARCHIVE_DIR"), filepath.Join(cfg.WorkDir, "archive"))
+	cfg.QuarantineDir = firstNonEmpty(os.Getenv("QUARANTINE_DIR"), filepath.Join(cfg.WorkDir, "quarantine"))
 
 	cfg.HTTPPort = firstNonEmpty(os.Getenv("HTTP_PORT"), fileCfg.HTTPPort, defaultPort)
 	if legacyPort := os.Getenv("PORT"); legacyPort != "" && cfg.HTTPPort == defaultPort {
@@ -144,6 +573,9 @@ func Load() (Config, error) {
 	if !strings.HasPrefix(cfg.HTTPPort, ":") {
 		cfg.HTTPPort = ":" + cfg.HTTPPort
 	}
+	if cfg.GRPCPort = strings.TrimSpace(os.Getenv("GRPC_PORT")); cfg.GRPCPort != "" && !strings.Contains(cfg.GRPCPort, ":") {
+		cfg.GRPCPort = ":" + cfg.GRPCPort
+	}
 
 	if v := os.Getenv("WORKER_COUNT"); v != "" {
 		n, err := strconv.Atoi(v)
@@ -190,6 +622,395 @@ func Load() (Config, error) {
 		}
 		cfg.JobTimeoutSec = n
 	}
+	if v, ok, err := parseIntEnv("JOB_TIMEOUT_PER_AUDIO_MIN_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid JOB_TIMEOUT_PER_AUDIO_MIN_SEC: %w", err)
+		}
+		log.Printf("invalid JOB_TIMEOUT_PER_AUDIO_MIN_SEC: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.JobTimeoutPerAudioMinSec = v
+	}
+	if v, ok, err := parseIntEnv("JOB_TIMEOUT_MAX_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid JOB_TIMEOUT_MAX_SEC: %w", err)
+		}
+		log.Printf("invalid JOB_TIMEOUT_MAX_SEC: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.JobTimeoutMaxSec = v
+	}
+
+	if v, ok, err := parseIntEnv("OPENAI_BREAKER_THRESHOLD"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid OPENAI_BREAKER_THRESHOLD: %w", err)
+		}
+		log.Printf("invalid OPENAI_BREAKER_THRESHOLD: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.OpenAIBreakerThreshold = v
+	}
+	if v, ok, err := parseIntEnv("OPENAI_BREAKER_COOLDOWN_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid OPENAI_BREAKER_COOLDOWN_SEC: %w", err)
+		}
+		log.Printf("invalid OPENAI_BREAKER_COOLDOWN_SEC: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.OpenAIBreakerCooldownSec = v
+	}
+
+	if v, ok, err := parseIntEnv("LEADER_LEASE_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid LEADER_LEASE_SEC: %w", err)
+		}
+		log.Printf("invalid LEADER_LEASE_SEC: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.LeaderLeaseSec = v
+	}
+	if cfg.InstanceID == "" {
+		host, _ := os.Hostname()
+		cfg.InstanceID = fmt.Sprintf("%s-%d", firstNonEmpty(host, "instance"), os.Getpid())
+	}
+	if v, ok, err := parseIntEnv("DB_CHECKPOINT_INTERVAL_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid DB_CHECKPOINT_INTERVAL_SEC: %w", err)
+		}
+		log.Printf("invalid DB_CHECKPOINT_INTERVAL_SEC: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.DBCheckpointIntervalSec = v
+	}
+	if v, ok, err := parseIntEnv("DB_VACUUM_HOUR"); err != nil || (ok && (v < -1 || v > 23)) {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid DB_VACUUM_HOUR: must be -1 (disabled) or 0-23")
+		}
+		log.Printf("invalid DB_VACUUM_HOUR=%q (using default %d)", os.Getenv("DB_VACUUM_HOUR"), defaultDBVacuumHour)
+	} else if ok {
+		cfg.DBVacuumHour = v
+	}
+	if v, ok, err := parseIntEnv("ARCHIVE_HOUR"); err != nil || (ok && (v < -1 || v > 23)) {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid ARCHIVE_HOUR: must be -1 (disabled) or 0-23")
+		}
+		log.Printf("invalid ARCHIVE_HOUR=%q (using default %d)", os.Getenv("ARCHIVE_HOUR"), defaultArchiveHour)
+	} else if ok {
+		cfg.ArchiveHour = v
+	}
+	if v, ok, err := parseIntEnv("DB_QUERY_TIMEOUT_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid DB_QUERY_TIMEOUT_SEC: %w", err)
+		}
+		log.Printf("invalid DB_QUERY_TIMEOUT_SEC: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.DBQueryTimeoutSec = v
+	}
+	if v, ok, err := parseIntEnv("QUERY_CACHE_TTL_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid QUERY_CACHE_TTL_SEC: %w", err)
+		}
+		log.Printf("invalid QUERY_CACHE_TTL_SEC: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.QueryCacheTTLSec = v
+	}
+	if v, ok, err := parseIntEnv("INTEGRITY_CHECK_HOURS"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid INTEGRITY_CHECK_HOURS: %w", err)
+		}
+		log.Printf("invalid INTEGRITY_CHECK_HOURS: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.IntegrityCheckHours = v
+	}
+	if v, ok, err := parseIntEnv("CAD_MATCH_WINDOW_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid CAD_MATCH_WINDOW_SEC: %w", err)
+		}
+		log.Printf("invalid CAD_MATCH_WINDOW_SEC: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.CADMatchWindowSec = v
+	}
+	if v, ok, err := parseIntEnv("UPLOAD_MAX_MB"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid UPLOAD_MAX_MB: %w", err)
+		}
+		log.Printf("invalid UPLOAD_MAX_MB: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.UploadMaxMB = v
+	}
+	if v, ok, err := parseIntEnv("UPLOAD_EXPIRY_HOURS"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid UPLOAD_EXPIRY_HOURS: %w", err)
+		}
+		log.Printf("invalid UPLOAD_EXPIRY_HOURS: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.UploadExpiryHours = v
+	}
+	if v, ok, err := parseFloatEnv("FINGERPRINT_MATCH_THRESHOLD"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid FINGERPRINT_MATCH_THRESHOLD: %w", err)
+		}
+		log.Printf("invalid FINGERPRINT_MATCH_THRESHOLD: %v (using default)", err)
+	} else if ok && v >= 0 && v <= 1 {
+		cfg.FingerprintThreshold = v
+	}
+	if v, ok, err := parseFloatEnv("STATION_FAR_MILES"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid STATION_FAR_MILES: %w", err)
+		}
+		log.Printf("invalid STATION_FAR_MILES: %v (flag disabled)", err)
+	} else if ok && v >= 0 {
+		cfg.StationFarMiles = v
+	}
+	if v, ok, err := parseFloatEnv("HYDRANT_FAR_FEET"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid HYDRANT_FAR_FEET: %w", err)
+		}
+		log.Printf("invalid HYDRANT_FAR_FEET: %v (flag disabled)", err)
+	} else if ok && v >= 0 {
+		cfg.HydrantFarFeet = v
+	}
+	if v, ok, err := parseFloatEnv("MIN_AUDIO_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid MIN_AUDIO_SEC: %w", err)
+		}
+		log.Printf("invalid MIN_AUDIO_SEC: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.MinAudioSec = v
+	}
+	if v, ok, err := parseIntEnv("FINGERPRINT_WINDOW_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid FINGERPRINT_WINDOW_SEC: %w", err)
+		}
+		log.Printf("invalid FINGERPRINT_WINDOW_SEC: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.FingerprintWindowSec = v
+	}
+	cfg.CADFieldMap = make(map[string]string, len(DefaultCADFieldMap))
+	for k, v := range DefaultCADFieldMap {
+		cfg.CADFieldMap[k] = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("CAD_FIELD_MAP")); raw != "" {
+		var overrides map[string]string
+		if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+			if cfg.StrictConfig {
+				return cfg, fmt.Errorf("invalid CAD_FIELD_MAP: %w", err)
+			}
+			log.Printf("invalid CAD_FIELD_MAP: %v (using defaults)", err)
+		}
+		for k, v := range overrides {
+			if _, known := DefaultCADFieldMap[k]; known && strings.TrimSpace(v) != "" {
+				cfg.CADFieldMap[k] = strings.TrimSpace(v)
+			}
+		}
+	}
+	if v, ok, err := parseFloatEnv("OPENAI_MONTHLY_BUDGET_USD"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid OPENAI_MONTHLY_BUDGET_USD: %w", err)
+		}
+		log.Printf("invalid OPENAI_MONTHLY_BUDGET_USD: %v (no budget)", err)
+	} else if ok && v >= 0 {
+		cfg.OpenAIMonthlyBudgetUSD = v
+	}
+	if v, ok, err := parseIntEnv("MAP_PROXY_RATE_PER_MIN"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid MAP_PROXY_RATE_PER_MIN: %w", err)
+		}
+		log.Printf("invalid MAP_PROXY_RATE_PER_MIN: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.MapProxyRatePerMin = v
+	}
+	if v, ok, err := parseIntEnv("WORK_FILE_GRACE_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid WORK_FILE_GRACE_SEC: %w", err)
+		}
+		log.Printf("invalid WORK_FILE_GRACE_SEC: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.WorkFileGraceSec = v
+	}
+	if v, ok, err := parseIntEnv("MAP_TILE_CACHE_TTL_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid MAP_TILE_CACHE_TTL_SEC: %w", err)
+		}
+		log.Printf("invalid MAP_TILE_CACHE_TTL_SEC: %v (cache disabled)", err)
+	} else if ok && v >= 0 {
+		cfg.MapTileCacheTTLSec = v
+	}
+	if v, ok, err := parseIntEnv("ALERT_SUPPRESSION_WINDOW_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid ALERT_SUPPRESSION_WINDOW_SEC: %w", err)
+		}
+		log.Printf("invalid ALERT_SUPPRESSION_WINDOW_SEC: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.AlertSuppressionSec = v
+	}
+	if v, ok, err := parseIntEnv("ESCALATION_AFTER_MIN"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid ESCALATION_AFTER_MIN: %w", err)
+		}
+		log.Printf("invalid ESCALATION_AFTER_MIN: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.EscalationAfterMin = v
+	}
+	if v, ok, err := parseIntEnv("TALKGROUP_SILENCE_MIN"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid TALKGROUP_SILENCE_MIN: %w", err)
+		}
+		log.Printf("invalid TALKGROUP_SILENCE_MIN: %v (silence checks disabled)", err)
+	} else if ok && v >= 0 {
+		cfg.TalkgroupSilenceMin = v
+	}
+	if v, ok, err := parseIntEnv("TALKGROUP_SILENCE_MIN_DAILY_CALLS"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid TALKGROUP_SILENCE_MIN_DAILY_CALLS: %w", err)
+		}
+		log.Printf("invalid TALKGROUP_SILENCE_MIN_DAILY_CALLS: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.TalkgroupMinDailyCalls = v
+	}
+	if v, ok, err := parseIntEnv("WATCHER_CHECK_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid WATCHER_CHECK_SEC: %w", err)
+		}
+		log.Printf("invalid WATCHER_CHECK_SEC: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.WatcherCheckSec = v
+	}
+	if v, ok, err := parseFloatEnv("SLO_TARGET"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid SLO_TARGET: %w", err)
+		}
+		log.Printf("invalid SLO_TARGET: %v (using default)", err)
+	} else if ok {
+		cfg.SLOTarget = v
+	}
+	if v, ok, err := parseIntEnv("SLO_LATENCY_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid SLO_LATENCY_SEC: %w", err)
+		}
+		log.Printf("invalid SLO_LATENCY_SEC: %v (using default)", err)
+	} else if ok {
+		cfg.SLOLatencySec = v
+	}
+	if v, ok, err := parseFloatEnv("SLO_BURN_RATE_ALERT"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid SLO_BURN_RATE_ALERT: %w", err)
+		}
+		log.Printf("invalid SLO_BURN_RATE_ALERT: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.SLOBurnRateAlert = v
+	}
+	if v, ok, err := parseIntEnv("IMAP_POLL_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid IMAP_POLL_SEC: %w", err)
+		}
+		log.Printf("invalid IMAP_POLL_SEC: %v (using default)", err)
+	} else if ok {
+		cfg.IMAPPollSec = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("IMAP_SENDERS")); raw != "" {
+		var senders map[string]string
+		if err := json.Unmarshal([]byte(raw), &senders); err != nil {
+			if cfg.StrictConfig {
+				return cfg, fmt.Errorf("invalid IMAP_SENDERS: %w", err)
+			}
+			log.Printf("invalid IMAP_SENDERS: %v (accepting every sender)", err)
+		}
+		// Addresses are matched without regard to case.
+		for addr, agency := range senders {
+			if addr = strings.ToLower(strings.TrimSpace(addr)); addr != "" {
+				if cfg.IMAPSenders == nil {
+					cfg.IMAPSenders = make(map[string]string, len(senders))
+				}
+				cfg.IMAPSenders[addr] = strings.TrimSpace(agency)
+			}
+		}
+	}
+	if v, ok, err := parseIntEnv("PAGE_MATCH_WINDOW_SEC"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid PAGE_MATCH_WINDOW_SEC: %w", err)
+		}
+		log.Printf("invalid PAGE_MATCH_WINDOW_SEC: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.PageMatchWindowSec = v
+	}
+	if v := strings.TrimSpace(os.Getenv("CAP_SENDER")); v != "" {
+		cfg.CAPSender = v
+	}
+	if v, ok := os.LookupEnv("CAP_SAME_CODE"); ok {
+		cfg.CAPSameCode = strings.TrimSpace(v)
+	}
+	if v, ok, err := parseIntEnv("REPEAT_LOCATION_CALLS"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid REPEAT_LOCATION_CALLS: %w", err)
+		}
+		log.Printf("invalid REPEAT_LOCATION_CALLS: %v (repeat-location notes disabled)", err)
+	} else if ok && v > 0 {
+		cfg.RepeatLocationCalls = v
+	}
+	if v, ok, err := parseIntEnv("REPEAT_LOCATION_DAYS"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid REPEAT_LOCATION_DAYS: %w", err)
+		}
+		log.Printf("invalid REPEAT_LOCATION_DAYS: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.RepeatLocationDays = v
+	}
+	if v, ok, err := parseIntEnv("CLASSIFY_FEW_SHOT"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid CLASSIFY_FEW_SHOT: %w", err)
+		}
+		log.Printf("invalid CLASSIFY_FEW_SHOT: %v (few-shot examples disabled)", err)
+	} else if ok && v > 0 {
+		cfg.ClassifyFewShot = v
+	}
+	if v, ok, err := parseIntEnv("SHADOW_PERCENT"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid SHADOW_PERCENT: %w", err)
+		}
+		log.Printf("invalid SHADOW_PERCENT: %v (shadow mode disabled)", err)
+	} else if ok {
+		cfg.ShadowPercent = v
+	}
+	if raw := strings.TrimSpace(os.Getenv("OPENAI_PRICING")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.OpenAIPricing); err != nil {
+			if cfg.StrictConfig {
+				return cfg, fmt.Errorf("invalid OPENAI_PRICING: %w", err)
+			}
+			log.Printf("invalid OPENAI_PRICING: %v (using built-in prices)", err)
+			cfg.OpenAIPricing = nil
+		}
+	}
+	if raw := strings.TrimSpace(os.Getenv("REMOTE_SOURCES")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.RemoteSources); err != nil {
+			if cfg.StrictConfig {
+				return cfg, fmt.Errorf("invalid REMOTE_SOURCES: %w", err)
+			}
+			log.Printf("invalid REMOTE_SOURCES: %v (remote polling disabled)", err)
+			cfg.RemoteSources = nil
+		}
+		for i := range cfg.RemoteSources {
+			src := &cfg.RemoteSources[i]
+			src.Name = strings.TrimSpace(src.Name)
+			src.Type = strings.ToLower(strings.TrimSpace(src.Type))
+			src.URL = strings.TrimSpace(src.URL)
+			if src.IntervalSec <= 0 {
+				src.IntervalSec = defaultRemoteIntervalSec
+			}
+			if src.MaxPerPoll <= 0 {
+				src.MaxPerPoll = defaultRemoteMaxPerPoll
+			}
+		}
+	}
+
+	if raw := strings.TrimSpace(os.Getenv("FILENAME_PATTERNS")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.FilenamePatterns); err != nil {
+			if cfg.StrictConfig {
+				return cfg, fmt.Errorf("invalid FILENAME_PATTERNS: %w", err)
+			}
+			log.Printf("invalid FILENAME_PATTERNS: %v (using the built-in parser only)", err)
+			cfg.FilenamePatterns = nil
+		}
+		for i := range cfg.FilenamePatterns {
+			p := &cfg.FilenamePatterns[i]
+			p.Name = strings.TrimSpace(p.Name)
+			p.Source = strings.TrimSpace(p.Source)
+		}
+	}
 
 	if v, ok, err := parseIntEnv("ROLLUP_LOOKBACK_HOURS"); err != nil {
 		if cfg.StrictConfig {
@@ -231,6 +1052,53 @@ func Load() (Config, error) {
 	} else if ok && v > 0 {
 		cfg.Rollup.RefreshIntervalSec = v
 	}
+	if v, ok, err := parseIntEnv("ROLLUP_MONITOR_AFTER_MIN"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid ROLLUP_MONITOR_AFTER_MIN: %w", err)
+		}
+		log.Printf("invalid ROLLUP_MONITOR_AFTER_MIN: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.Rollup.MonitorAfterMin = v
+	}
+	if v, ok, err := parseIntEnv("ROLLUP_CLOSE_AFTER_MIN"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid ROLLUP_CLOSE_AFTER_MIN: %w", err)
+		}
+		log.Printf("invalid ROLLUP_CLOSE_AFTER_MIN: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.Rollup.CloseAfterMin = v
+	}
+	if v, ok, err := parseIntEnv("ROLLUP_NOTIFY_CALL_THRESHOLD"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid ROLLUP_NOTIFY_CALL_THRESHOLD: %w", err)
+		}
+		log.Printf("invalid ROLLUP_NOTIFY_CALL_THRESHOLD: %v (using default)", err)
+	} else if ok && v >= 0 {
+		cfg.Rollup.NotifyCallThreshold = v
+	}
+	if v, ok, err := parseIntEnv("ROLLUP_NOTIFY_WINDOW_MIN"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid ROLLUP_NOTIFY_WINDOW_MIN: %w", err)
+		}
+		log.Printf("invalid ROLLUP_NOTIFY_WINDOW_MIN: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.Rollup.NotifyWindowMin = v
+	}
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("ROLLUP_NOTIFY_PRIORITY"))); v != "" {
+		cfg.Rollup.NotifyPriority = v
+	}
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("ROLLUP_STRATEGY"))); v != "" {
+		cfg.Rollup.Strategy = v
+	}
+	if v, ok, err := parseIntEnv("ROLLUP_DBSCAN_MIN_POINTS"); err != nil {
+		if cfg.StrictConfig {
+			return cfg, fmt.Errorf("invalid ROLLUP_DBSCAN_MIN_POINTS: %w", err)
+		}
+		log.Printf("invalid ROLLUP_DBSCAN_MIN_POINTS: %v (using default)", err)
+	} else if ok && v > 0 {
+		cfg.Rollup.DBSCANMinPoints = v
+	}
+	cfg.Rollup.NotifyClosures = parseBoolEnvDefault("ROLLUP_NOTIFY_CLOSURES", cfg.Rollup.NotifyClosures)
 	if v := os.Getenv("ROLLUP_LLM_ENABLED"); strings.TrimSpace(v) != "" {
 		cfg.Rollup.LLMEnabled = parseBoolEnv("ROLLUP_LLM_ENABLED")
 	}
@@ -246,6 +1114,7 @@ func Load() (Config, error) {
 		os.Getenv("OPENAI_API_BASE"),
 		cfg.Rollup.LLMBaseURL,
 	)
+	cfg.LLM = loadLLMConfig(fileCfg.LLM)
 	if cfg.Rollup.LLMEnabled && strings.TrimSpace(os.Getenv("OPENAI_API_KEY")) == "" {
 		log.Printf("rollup LLM enabled but OPENAI_API_KEY is not set")
 	}
@@ -270,6 +1139,31 @@ func Load() (Config, error) {
 	return cfg, nil
 }
 
+// Reload is Load for a running service. A config file that exists but does
+// not parse, or settings that fail validation, are errors instead of falling
+// back to defaults, so a bad edit never replaces working settings.
+func Reload() (Config, error) {
+	configPath, nlpPath := configPaths()
+	if _, err := loadFileConfig(configPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, fmt.Errorf("config load failed (%s): %w", configPath, err)
+	}
+	if _, err := os.Stat(nlpPath); err == nil {
+		if _, err := LoadNLPConfig(nlpPath); err != nil {
+			return Config{}, fmt.Errorf("nlp config load failed (%s): %w", nlpPath, err)
+		}
+	}
+	cfg, err := Load()
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func configPaths() (string, string) {
+	configPath := getEnv("CONFIG_PATH", filepath.Join("config", "config.yaml"))
+	return configPath, getEnv("NLP_CONFIG_PATH", configPath)
+}
+
 func loadFileConfig(path string) (fileConfig, error) {
 	var cfg fileConfig
 	data, err := os.ReadFile(path)
@@ -296,13 +1190,24 @@ func loadFileConfig(path string) (fileConfig, error) {
 	return cfg, nil
 }
 
+// Validate reports the first problem with cfg, as Load does before starting.
+func (cfg Config) Validate() error {
+	return validateConfig(cfg)
+}
+
 func validateConfig(cfg Config) error {
 	if strings.TrimSpace(cfg.CallsDir) == "" {
 		return errors.New("CALLS_DIR is required")
 	}
+	if cfg.CallsDirOld != "" && filepath.Clean(cfg.CallsDirOld) == filepath.Clean(cfg.CallsDir) {
+		return fmt.Errorf("CALLS_DIR_OLD must differ from CALLS_DIR (both %q)", cfg.CallsDir)
+	}
 	if strings.TrimSpace(cfg.HTTPPort) == "" {
 		return errors.New("HTTP_PORT is required")
 	}
+	if cfg.JobTimeoutMaxSec < cfg.JobTimeoutSec {
+		return fmt.Errorf("JOB_TIMEOUT_MAX_SEC (%d) must be at least JOB_TIMEOUT_SEC (%d)", cfg.JobTimeoutMaxSec, cfg.JobTimeoutSec)
+	}
 	if len(cfg.NLP.MapboxBoundingBox) != 0 && len(cfg.NLP.MapboxBoundingBox) != 4 {
 		return fmt.Errorf("nlp.mapbox_bounding_box must have 4 floats (got %d)", len(cfg.NLP.MapboxBoundingBox))
 	}
@@ -321,6 +1226,179 @@ func validateConfig(cfg Config) error {
 	if cfg.Rollup.RefreshIntervalSec <= 0 {
 		return errors.New("rollup refresh interval must be positive")
 	}
+	if cfg.Rollup.MonitorAfterMin <= 0 || cfg.Rollup.CloseAfterMin <= cfg.Rollup.MonitorAfterMin {
+		return errors.New("rollup close window must be longer than the monitor window")
+	}
+	switch cfg.Rollup.NotifyPriority {
+	case "off", "low", "medium", "high":
+	default:
+		return fmt.Errorf("rollup notify priority must be off, low, medium, or high (got %q)", cfg.Rollup.NotifyPriority)
+	}
+	switch cfg.Rollup.Strategy {
+	case "greedy", "dbscan", "address":
+	default:
+		return fmt.Errorf("rollup strategy must be greedy, dbscan, or address (got %q)", cfg.Rollup.Strategy)
+	}
+	seenSources := make(map[string]bool, len(cfg.RemoteSources))
+	for _, src := range cfg.RemoteSources {
+		if src.Name == "" || seenSources[src.Name] {
+			return fmt.Errorf("REMOTE_SOURCES names must be unique and non-empty (got %q)", src.Name)
+		}
+		seenSources[src.Name] = true
+		if src.Type != "http" && src.Type != "json" {
+			return fmt.Errorf("REMOTE_SOURCES %s: type must be http or json (got %q)", src.Name, src.Type)
+		}
+		if !strings.HasPrefix(src.URL, "http://") && !strings.HasPrefix(src.URL, "https://") {
+			return fmt.Errorf("REMOTE_SOURCES %s: invalid url %q", src.Name, src.URL)
+		}
+	}
+	seenPatterns := make(map[string]bool, len(cfg.FilenamePatterns))
+	for _, p := range cfg.FilenamePatterns {
+		if p.Name == "" || seenPatterns[p.Name] {
+			return fmt.Errorf("FILENAME_PATTERNS names must be unique and non-empty (got %q)", p.Name)
+		}
+		seenPatterns[p.Name] = true
+		if _, err := p.Compile(); err != nil {
+			return fmt.Errorf("FILENAME_PATTERNS %s: %w", p.Name, err)
+		}
+	}
+	if len(cfg.EscalationCategories) > 0 {
+		if cfg.EscalationGroupMeBotID == "" && cfg.EscalationWebhookURL == "" {
+			return errors.New("ESCALATION_CATEGORIES needs ESCALATION_GROUPME_BOT_ID or ESCALATION_WEBHOOK_URL")
+		}
+		if cfg.EscalationWebhookURL != "" && !strings.HasPrefix(cfg.EscalationWebhookURL, "http://") && !strings.HasPrefix(cfg.EscalationWebhookURL, "https://") {
+			return fmt.Errorf("invalid ESCALATION_WEBHOOK_URL %q", cfg.EscalationWebhookURL)
+		}
+	}
+	if cfg.LocalWhisperBin != "" {
+		switch cfg.LocalWhisperEngine {
+		case "whisper.cpp":
+			if cfg.LocalWhisperModel == "" {
+				return errors.New("LOCAL_WHISPER_MODEL is required for whisper.cpp")
+			}
+		case "faster-whisper":
+		default:
+			return fmt.Errorf("LOCAL_WHISPER_ENGINE must be whisper.cpp or faster-whisper (got %q)", cfg.LocalWhisperEngine)
+		}
+	}
+	switch cfg.DraftEngine {
+	case "":
+	case "vosk", "whisper.cpp":
+		if cfg.DraftBin == "" || cfg.DraftModel == "" {
+			return fmt.Errorf("DRAFT_ENGINE %s needs DRAFT_BIN and DRAFT_MODEL", cfg.DraftEngine)
+		}
+	default:
+		return fmt.Errorf("DRAFT_ENGINE must be vosk or whisper.cpp (got %q)", cfg.DraftEngine)
+	}
+	switch cfg.TTSEngine {
+	case "", "openai":
+	case "espeak":
+		if cfg.TTSBin == "" {
+			return errors.New("TTS_ENGINE espeak needs TTS_BIN")
+		}
+	case "piper":
+		if cfg.TTSBin == "" || cfg.TTSModel == "" {
+			return errors.New("TTS_ENGINE piper needs TTS_BIN and TTS_MODEL")
+		}
+	default:
+		return fmt.Errorf("TTS_ENGINE must be openai, piper or espeak (got %q)", cfg.TTSEngine)
+	}
+	if cfg.RepeatLocationCalls == 1 {
+		return errors.New("REPEAT_LOCATION_CALLS must be at least 2")
+	}
+	if cfg.RepeatLocationDays > 365 {
+		return fmt.Errorf("REPEAT_LOCATION_DAYS must be at most 365 (got %d)", cfg.RepeatLocationDays)
+	}
+	if cfg.ClassifyFewShot > 20 {
+		return fmt.Errorf("CLASSIFY_FEW_SHOT must be at most 20 (got %d)", cfg.ClassifyFewShot)
+	}
+	if u := cfg.TalkgroupWebhookURL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return fmt.Errorf("invalid TALKGROUP_SILENCE_WEBHOOK_URL %q", u)
+	}
+	if u := cfg.WatcherWebhookURL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return fmt.Errorf("invalid WATCHER_ALERT_WEBHOOK_URL %q", u)
+	}
+	if cfg.SLOTarget <= 0 || cfg.SLOTarget >= 1 {
+		return fmt.Errorf("SLO_TARGET must be between 0 and 1 (got %v)", cfg.SLOTarget)
+	}
+	if cfg.SLOLatencySec <= 0 {
+		return fmt.Errorf("SLO_LATENCY_SEC must be positive (got %d)", cfg.SLOLatencySec)
+	}
+	if cfg.Active911Enabled && cfg.Active911URL == "" {
+		return errors.New("ACTIVE911_ENABLED needs ACTIVE911_URL")
+	}
+	if cfg.CAPExportEnabled && cfg.CAPExportURL == "" {
+		return errors.New("CAP_EXPORT_ENABLED needs CAP_EXPORT_URL")
+	}
+	for key, u := range map[string]string{"ACTIVE911_URL": cfg.Active911URL, "CAP_EXPORT_URL": cfg.CAPExportURL} {
+		if u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+			return fmt.Errorf("invalid %s %q", key, u)
+		}
+	}
+	if cfg.CAPSameCode != "" && (len(cfg.CAPSameCode) != 6 || strings.Trim(cfg.CAPSameCode, "0123456789") != "") {
+		return fmt.Errorf("CAP_SAME_CODE must be six digits (got %q)", cfg.CAPSameCode)
+	}
+	if strings.ContainsAny(cfg.CAPSender, " ,<&") {
+		return fmt.Errorf("CAP_SENDER must not contain spaces, commas, < or & (got %q)", cfg.CAPSender)
+	}
+	if len(cfg.TwilioSMSTo) > 0 || len(cfg.TwilioCalloutTo) > 0 {
+		if cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.TwilioFromNumber == "" {
+			return errors.New("TWILIO_SMS_TO and TWILIO_CALLOUT_TO need TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER")
+		}
+		if len(cfg.TwilioCalloutTo) > 0 && cfg.PublicBaseURL == "" {
+			return errors.New("TWILIO_CALLOUT_TO needs PUBLIC_BASE_URL so call status callbacks can advance the phone tree")
+		}
+	}
+	if cfg.GRPCPort != "" && cfg.GRPCPort == cfg.HTTPPort {
+		return fmt.Errorf("GRPC_PORT must differ from HTTP_PORT (both %s)", cfg.HTTPPort)
+	}
+	switch cfg.QueueBackend {
+	case "", "memory":
+	case "redis":
+		if cfg.RedisURL == "" {
+			return errors.New("REDIS_URL is required when QUEUE_BACKEND=redis")
+		}
+		if _, err := redis.ParseURL(cfg.RedisURL); err != nil {
+			return fmt.Errorf("REDIS_URL: %w", err)
+		}
+	default:
+		return fmt.Errorf("QUEUE_BACKEND must be memory or redis (got %q)", cfg.QueueBackend)
+	}
+	if cfg.MQTTBrokerURL != "" {
+		if _, _, err := mqtt.ParseURL(cfg.MQTTBrokerURL); err != nil {
+			return fmt.Errorf("MQTT_BROKER_URL: %w", err)
+		}
+	}
+	if cfg.IMAPURL != "" {
+		if _, _, _, err := imap.ParseURL(cfg.IMAPURL); err != nil {
+			return fmt.Errorf("IMAP_URL: %w", err)
+		}
+		if cfg.IMAPPollSec <= 0 {
+			return fmt.Errorf("IMAP_POLL_SEC must be positive (got %d)", cfg.IMAPPollSec)
+		}
+	}
+	if cfg.ShadowPercent < 0 || cfg.ShadowPercent > 100 {
+		return fmt.Errorf("SHADOW_PERCENT must be between 0 and 100 (got %d)", cfg.ShadowPercent)
+	}
+	if cfg.ShadowPercent > 0 && cfg.ShadowModel == "" && cfg.ShadowCleanupVersion == "" && cfg.ShadowMetadataVersion == "" {
+		return errors.New("SHADOW_PERCENT needs SHADOW_MODEL or a SHADOW_*_PROMPT_VERSION candidate")
+	}
+	if cfg.EmbedSecret != "" && len(cfg.EmbedSecret) < 16 {
+		return errors.New("EMBED_SECRET must be at least 16 characters")
+	}
+	if cfg.ShareSecret != "" && len(cfg.ShareSecret) < 16 {
+		return errors.New("SHARE_SECRET must be at least 16 characters")
+	}
+	switch cfg.ArchiveGroupBy {
+	case "agency", "town":
+	default:
+		return fmt.Errorf("ARCHIVE_GROUP_BY must be agency or town (got %q)", cfg.ArchiveGroupBy)
+	}
+	switch cfg.RedactionMode {
+	case "off", "regex", "llm":
+	default:
+		return fmt.Errorf("REDACTION_MODE must be off, regex, or llm (got %q)", cfg.RedactionMode)
+	}
 	return nil
 }
 
@@ -391,9 +1469,77 @@ func applyRollupOverrides(base RollupConfig, override rollupFileConfig) RollupCo
 	if strings.TrimSpace(override.LLMBaseURL) != "" {
 		base.LLMBaseURL = strings.TrimSpace(override.LLMBaseURL)
 	}
+	if override.MonitorAfterMin != nil && *override.MonitorAfterMin > 0 {
+		base.MonitorAfterMin = *override.MonitorAfterMin
+	}
+	if override.CloseAfterMin != nil && *override.CloseAfterMin > 0 {
+		base.CloseAfterMin = *override.CloseAfterMin
+	}
+	if override.NotifyClosures != nil {
+		base.NotifyClosures = *override.NotifyClosures
+	}
+	if override.NotifyCallThreshold != nil && *override.NotifyCallThreshold >= 0 {
+		base.NotifyCallThreshold = *override.NotifyCallThreshold
+	}
+	if override.NotifyWindowMin != nil && *override.NotifyWindowMin > 0 {
+		base.NotifyWindowMin = *override.NotifyWindowMin
+	}
+	if strings.TrimSpace(override.NotifyPriority) != "" {
+		base.NotifyPriority = strings.ToLower(strings.TrimSpace(override.NotifyPriority))
+	}
+	if strings.TrimSpace(override.Strategy) != "" {
+		base.Strategy = strings.ToLower(strings.TrimSpace(override.Strategy))
+	}
+	if override.DBSCANMinPoints != nil && *override.DBSCANMinPoints > 0 {
+		base.DBSCANMinPoints = *override.DBSCANMinPoints
+	}
 	return base
 }
 
+func loadLLMConfig(file llmFileConfig) LLMConfig {
+	base := firstNonEmpty(os.Getenv("OPENAI_BASE_URL"), os.Getenv("OPENAI_API_BASE"), file.BaseURL, defaultOpenAIBaseURL)
+	cfg := LLMConfig{BaseURL: strings.TrimRight(strings.TrimSpace(base), "/")}
+	cfg.Cleanup = resolveLLMStage("CLEANUP", file.Cleanup, cfg.BaseURL)
+	cfg.Classify = resolveLLMStage("CLASSIFY", file.Classify, cfg.BaseURL)
+	cfg.Refine = resolveLLMStage("REFINE", file.Refine, cfg.BaseURL)
+	cfg.Redact = resolveLLMStage("REDACT", file.Redact, cfg.BaseURL)
+	cfg.Roles = resolveLLMStage("ROLES", file.Roles, cfg.BaseURL)
+	return cfg
+}
+
+func resolveLLMStage(prefix string, file LLMStageConfig, base string) LLMStageConfig {
+	stageBase := firstNonEmpty(os.Getenv(prefix+"_LLM_BASE_URL"), file.BaseURL, base)
+	return LLMStageConfig{
+		BaseURL: strings.TrimRight(strings.TrimSpace(stageBase), "/"),
+		Model:   strings.TrimSpace(firstNonEmpty(os.Getenv(prefix+"_LLM_MODEL"), file.Model)),
+	}
+}
+
+func joinURL(base, path string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		base = defaultOpenAIBaseURL
+	}
+	return base + path
+}
+
+// parseListEnv splits a comma-separated variable. Unset uses fallback; "none"
+// yields an empty list.
+func parseListEnv(key string, fallback []string) []string {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return append([]string(nil), fallback...)
+	}
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part != "" && part != "none" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 func parseIntEnv(key string) (int, bool, error) {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
@@ -1,6 +1,10 @@
 package config
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestQueueSizeDefaultsRespectWorkers(t *testing.T) {
 	t.Setenv("WORKER_COUNT", "8")
@@ -39,3 +43,386 @@ func TestDBPathDefaultsToWorkDir(t *testing.T) {
 		t.Fatalf("expected DBPath %s, got %s", expected, cfg.DBPath)
 	}
 }
+
+func TestLLMStageOverridesFallBackToBaseURL(t *testing.T) {
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:11434/")
+	t.Setenv("CLASSIFY_LLM_BASE_URL", "http://vllm:8000")
+	t.Setenv("CLASSIFY_LLM_MODEL", "llama3.1:8b")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if got := cfg.LLM.Cleanup.URL("/v1/chat/completions"); got != "http://localhost:11434/v1/chat/completions" {
+		t.Fatalf("expected cleanup to inherit OPENAI_BASE_URL, got %s", got)
+	}
+	if got := cfg.LLM.Classify.URL("/v1/chat/completions"); got != "http://vllm:8000/v1/chat/completions" {
+		t.Fatalf("expected classify override, got %s", got)
+	}
+	if got := cfg.LLM.Classify.ModelOr("gpt-4.1-mini"); got != "llama3.1:8b" {
+		t.Fatalf("expected classify model override, got %s", got)
+	}
+	if got := cfg.LLM.Refine.ModelOr("gpt-5.1"); got != "gpt-5.1" {
+		t.Fatalf("expected refine default model, got %s", got)
+	}
+}
+
+func TestCADFieldMapOverridesDefaults(t *testing.T) {
+	t.Setenv("CAD_FIELD_MAP", `{"address":"location.street","units":"assigned_units","bogus":"x"}`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if got := cfg.CADFieldMap["address"]; got != "location.street" {
+		t.Fatalf("expected address override, got %s", got)
+	}
+	if got := cfg.CADFieldMap["call_type"]; got != "call_type" {
+		t.Fatalf("expected call_type default, got %s", got)
+	}
+	if _, ok := cfg.CADFieldMap["bogus"]; ok {
+		t.Fatalf("unknown fields should be ignored")
+	}
+}
+
+func TestRemoteSourcesDefaultsAndValidation(t *testing.T) {
+	t.Setenv("REMOTE_SOURCES", `[{"name":"county","type":"HTTP","url":"https://archive.example.com/calls/"}]`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(cfg.RemoteSources) != 1 {
+		t.Fatalf("expected one source, got %d", len(cfg.RemoteSources))
+	}
+	src := cfg.RemoteSources[0]
+	if src.Type != "http" || src.IntervalSec != defaultRemoteIntervalSec || src.MaxPerPoll != defaultRemoteMaxPerPoll {
+		t.Fatalf("unexpected source defaults: %+v", src)
+	}
+
+	cfg.RemoteSources[0].Type = "ftp"
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected unknown source type to fail validation")
+	}
+}
+
+func TestEscalationNeedsTarget(t *testing.T) {
+	t.Setenv("ESCALATION_CATEGORIES", "Fire, structure_fire")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(cfg.EscalationCategories) != 2 || cfg.EscalationCategories[0] != "fire" || cfg.EscalationAfterMin != defaultEscalationAfterMin {
+		t.Fatalf("unexpected escalation config: %v after %d", cfg.EscalationCategories, cfg.EscalationAfterMin)
+	}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected escalation without a target to fail validation")
+	}
+	cfg.EscalationWebhookURL = "https://duty.example.com/hook"
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected webhook target to validate: %v", err)
+	}
+}
+
+func TestEmbedSecretLength(t *testing.T) {
+	t.Setenv("EMBED_SECRET", "short")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected short EMBED_SECRET to fail validation")
+	}
+	cfg.EmbedSecret = "0123456789abcdef"
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected 16-character secret to validate: %v", err)
+	}
+}
+
+func TestShadowNeedsCandidate(t *testing.T) {
+	t.Setenv("SHADOW_PERCENT", "10")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected shadow mode without a candidate to fail validation")
+	}
+	cfg.ShadowModel = "gpt-4o-transcribe"
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected shadow model to validate: %v", err)
+	}
+	cfg.ShadowPercent = 101
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected SHADOW_PERCENT over 100 to fail validation")
+	}
+}
+
+func TestCallsDirOldMustDiffer(t *testing.T) {
+	t.Setenv("CALLS_DIR", "/data/calls")
+	t.Setenv("CALLS_DIR_OLD", "/data/calls/")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected CALLS_DIR_OLD equal to CALLS_DIR to fail validation")
+	}
+	cfg.CallsDirOld = "/mnt/old/calls"
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected a different CALLS_DIR_OLD to validate: %v", err)
+	}
+}
+
+func TestRollupStrategyValidation(t *testing.T) {
+	t.Setenv("ROLLUP_STRATEGY", "DBSCAN")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.Rollup.Strategy != "dbscan" {
+		t.Fatalf("expected strategy dbscan, got %q", cfg.Rollup.Strategy)
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected dbscan to validate: %v", err)
+	}
+	cfg.Rollup.Strategy = "kmeans"
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected an unknown strategy to fail validation")
+	}
+}
+
+func TestRollupExportNeedsURL(t *testing.T) {
+	t.Setenv("CAP_EXPORT_ENABLED", "true")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected CAP_EXPORT_ENABLED without a URL to fail validation")
+	}
+	cfg.CAPExportURL = "https://eoc.example/cap"
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected CAP export with a URL to validate: %v", err)
+	}
+}
+
+func TestCAPSameCodeFormat(t *testing.T) {
+	t.Setenv("CAP_SAME_CODE", "34037")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected a five digit CAP_SAME_CODE to fail validation")
+	}
+	t.Setenv("CAP_SAME_CODE", "")
+	if cfg, err = Load(); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.CAPSameCode != "" {
+		t.Fatalf("expected an empty CAP_SAME_CODE to drop the geocode, got %q", cfg.CAPSameCode)
+	}
+}
+
+func TestFilenamePatternsValidation(t *testing.T) {
+	t.Setenv("FILENAME_PATTERNS", `[{"name":"county","source":"remote:county","pattern":"^(?P<epoch>\\d+)_(?P<agency>\\w+)$"}]`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(cfg.FilenamePatterns) != 1 || cfg.FilenamePatterns[0].Source != "remote:county" {
+		t.Fatalf("unexpected patterns: %+v", cfg.FilenamePatterns)
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected a valid pattern, got %v", err)
+	}
+	cfg.FilenamePatterns = append(cfg.FilenamePatterns, FilenamePattern{Name: "stamp", Pattern: `^(?P<timestamp>\d{14})$`})
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected a timestamp group without time_layout to fail validation")
+	}
+	cfg.FilenamePatterns[1] = FilenamePattern{Name: "county", Pattern: `^(?P<epoch>\d+)$`}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected a duplicate pattern name to fail validation")
+	}
+}
+
+func TestSLOValidation(t *testing.T) {
+	t.Setenv("SLO_TARGET", "0.99")
+	t.Setenv("SLO_LATENCY_SEC", "90")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.SLOTarget != 0.99 || cfg.SLOLatencySec != 90 || cfg.SLOBurnRateAlert != defaultSLOBurnRateAlert {
+		t.Fatalf("unexpected SLO config: %v %d %v", cfg.SLOTarget, cfg.SLOLatencySec, cfg.SLOBurnRateAlert)
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected a valid SLO, got %v", err)
+	}
+	cfg.SLOTarget = 1
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected a 100%% target to fail validation")
+	}
+	cfg.SLOTarget = 0.95
+	cfg.SLOLatencySec = 0
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected a zero latency objective to fail validation")
+	}
+}
+
+func TestIMAPSettings(t *testing.T) {
+	t.Setenv("IMAP_URL", "imaps://mail.example.com/Pages")
+	t.Setenv("IMAP_SENDERS", `{" Dispatch@Sussex.example ":"Sussex County","":"x"}`)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(cfg.IMAPSenders) != 1 || cfg.IMAPSenders["dispatch@sussex.example"] != "Sussex County" {
+		t.Fatalf("unexpected senders: %v", cfg.IMAPSenders)
+	}
+	if cfg.IMAPPollSec != defaultIMAPPollSec || cfg.PageMatchWindowSec != defaultPageMatchWindowSec {
+		t.Fatalf("unexpected defaults: %d %d", cfg.IMAPPollSec, cfg.PageMatchWindowSec)
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected a valid mailbox, got %v", err)
+	}
+	cfg.IMAPURL = "pop3://mail.example.com"
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected a pop3 url to fail validation")
+	}
+	cfg.IMAPURL = "imap://mail.example.com"
+	cfg.IMAPPollSec = 0
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected a zero poll interval to fail validation")
+	}
+}
+
+func TestTTSValidation(t *testing.T) {
+	t.Setenv("TTS_ENGINE", " Piper ")
+	t.Setenv("TTS_BIN", "/usr/bin/piper")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.TTSEngine != "piper" {
+		t.Fatalf("unexpected engine: %q", cfg.TTSEngine)
+	}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected piper without a model to fail validation")
+	}
+	cfg.TTSModel = "/models/en_US-amy-medium.onnx"
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected a valid piper setup, got %v", err)
+	}
+	cfg.TTSEngine = "festival"
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected an unknown engine to fail validation")
+	}
+}
+
+func TestApplyReloadSplitsLiveAndRestartFields(t *testing.T) {
+	cur, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	next := cur
+	next.Rollup.LookbackHours = cur.Rollup.LookbackHours + 1
+	next.EscalationAfterMin = cur.EscalationAfterMin + 5
+	next.WorkerCount = cur.WorkerCount + 2
+	next.Rollup.LLMBaseURL = "http://localhost:11434"
+
+	applied, restart := ApplyReload(&cur, next)
+	if len(applied) != 2 || applied[0] != "EscalationAfterMin" || applied[1] != "Rollup.LookbackHours" {
+		t.Fatalf("unexpected applied fields: %v", applied)
+	}
+	if len(restart) != 2 || restart[0] != "Rollup.LLMBaseURL" || restart[1] != "WorkerCount" {
+		t.Fatalf("unexpected restart fields: %v", restart)
+	}
+	if cur.Rollup.LookbackHours != next.Rollup.LookbackHours || cur.EscalationAfterMin != next.EscalationAfterMin {
+		t.Fatalf("expected live fields to be copied")
+	}
+	if cur.WorkerCount == next.WorkerCount || cur.Rollup.LLMBaseURL == next.Rollup.LLMBaseURL {
+		t.Fatalf("expected restart fields to keep their old value")
+	}
+}
+
+func TestNotifySettingsFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "notify:\n  groupme_bot_id: file-bot\n  escalation_categories: [Fire]\n  escalation_after_min: 7\n  escalation_webhook_url: https://duty.example.com/hook\nrollup:\n  notify_closures: false\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+	t.Setenv("GROUPME_BOT_ID", "")
+	t.Setenv("ESCALATION_AFTER_MIN", "12")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.GroupMeBotID != "file-bot" || len(cfg.EscalationCategories) != 1 || cfg.EscalationCategories[0] != "fire" {
+		t.Fatalf("expected file notify settings, got bot %q categories %v", cfg.GroupMeBotID, cfg.EscalationCategories)
+	}
+	if cfg.EscalationAfterMin != 12 {
+		t.Fatalf("expected ESCALATION_AFTER_MIN to win over the file, got %d", cfg.EscalationAfterMin)
+	}
+	if cfg.Rollup.NotifyClosures {
+		t.Fatalf("expected rollup notify_closures from the file")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected file config to validate: %v", err)
+	}
+}
+
+func TestJobTimeoutCeiling(t *testing.T) {
+	t.Setenv("JOB_TIMEOUT_SEC", "120")
+	t.Setenv("JOB_TIMEOUT_MAX_SEC", "90")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.JobTimeoutPerAudioMinSec != defaultJobTimeoutPerAudioMinSec {
+		t.Fatalf("expected default per-minute allowance, got %d", cfg.JobTimeoutPerAudioMinSec)
+	}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatalf("expected a ceiling below JOB_TIMEOUT_SEC to fail validation")
+	}
+	cfg.JobTimeoutMaxSec = 1800
+	if err := validateConfig(cfg); err != nil {
+		t.Fatalf("expected ceiling above the base to validate: %v", err)
+	}
+}
+
+func TestQueryCacheTTL(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.QueryCacheTTLSec != defaultQueryCacheTTLSec {
+		t.Fatalf("expected default ttl, got %d", cfg.QueryCacheTTLSec)
+	}
+	t.Setenv("QUERY_CACHE_TTL_SEC", "0")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.QueryCacheTTLSec != 0 {
+		t.Fatalf("expected 0 to disable the cache, got %d", cfg.QueryCacheTTLSec)
+	}
+}
+
+func TestIntegritySettings(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.IntegrityCheckHours != defaultIntegrityCheckHours || cfg.IntegrityRepair {
+		t.Fatalf("unexpected defaults: %d %v", cfg.IntegrityCheckHours, cfg.IntegrityRepair)
+	}
+	t.Setenv("INTEGRITY_CHECK_HOURS", "0")
+	t.Setenv("INTEGRITY_REPAIR", "true")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.IntegrityCheckHours != 0 || !cfg.IntegrityRepair {
+		t.Fatalf("unexpected settings: %d %v", cfg.IntegrityCheckHours, cfg.IntegrityRepair)
+	}
+}
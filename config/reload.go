@@ -0,0 +1,85 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+)
+
+// liveFields are the settings a running service picks up on reload: prompts,
+// rollup tuning and alerting. Everything else is read once at startup (paths,
+// ports, workers, the database) and only reported as needing a restart.
+// Rollup.LLMBaseURL stays out because usage tracking registers it at startup.
+var liveFields = map[string]bool{
+	"NLP":                        true,
+	"Rollup.LookbackHours":       true,
+	"Rollup.ChainWindowMin":      true,
+	"Rollup.RadiusMeters":        true,
+	"Rollup.MaxCalls":            true,
+	"Rollup.RefreshIntervalSec":  true,
+	"Rollup.LLMEnabled":          true,
+	"Rollup.PromptVersion":       true,
+	"Rollup.LLMModel":            true,
+	"Rollup.MonitorAfterMin":     true,
+	"Rollup.CloseAfterMin":       true,
+	"Rollup.NotifyClosures":      true,
+	"Rollup.NotifyCallThreshold": true,
+	"Rollup.NotifyWindowMin":     true,
+	"Rollup.NotifyPriority":      true,
+	"Rollup.Strategy":            true,
+	"Rollup.DBSCANMinPoints":     true,
+	"GroupMeBotID":               true,
+	"AlertSuppressionSec":        true,
+	"EscalationCategories":       true,
+	"EscalationAfterMin":         true,
+	"EscalationGroupMeBotID":     true,
+	"EscalationWebhookURL":       true,
+	"EscalationWebhookSecret":    true,
+	"TwilioSMSTo":                true,
+	"TwilioSMSCategories":        true,
+	"TwilioCalloutTo":            true,
+	"Active911Enabled":           true,
+	"Active911URL":               true,
+	"Active911Token":             true,
+	"CAPExportEnabled":           true,
+	"CAPExportURL":               true,
+	"CAPExportToken":             true,
+	"CAPSameCode":                true,
+	"CAPFeedEnabled":             true,
+	"FilenamePatterns":           true,
+	"SLOTarget":                  true,
+	"SLOLatencySec":              true,
+	"SLOBurnRateAlert":           true,
+	"IMAPSenders":                true,
+	"QueryCacheTTLSec":           true,
+	"IntegrityRepair":            true,
+	"PageMatchWindowSec":         true,
+}
+
+// ApplyReload copies the live fields that differ in next into cur. It returns
+// the names of the fields it applied and of the changed fields that keep their
+// old value until the service restarts, both sorted.
+func ApplyReload(cur *Config, next Config) (applied, restart []string) {
+	diffFields("", reflect.ValueOf(cur).Elem(), reflect.ValueOf(next), &applied, &restart)
+	sort.Strings(applied)
+	sort.Strings(restart)
+	return applied, restart
+}
+
+func diffFields(prefix string, cur, next reflect.Value, applied, restart *[]string) {
+	for i := 0; i < cur.NumField(); i++ {
+		name := prefix + cur.Type().Field(i).Name
+		if name == "Rollup" {
+			diffFields(name+".", cur.Field(i), next.Field(i), applied, restart)
+			continue
+		}
+		if reflect.DeepEqual(cur.Field(i).Interface(), next.Field(i).Interface()) {
+			continue
+		}
+		if liveFields[name] {
+			cur.Field(i).Set(next.Field(i))
+			*applied = append(*applied, name)
+		} else {
+			*restart = append(*restart, name)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// dashboardPushPeriod is how often live dashboards are checked for
+	// changes. Snapshots are shared by every connection watching the same
+	// window, so the queries run once per period however many are open.
+	dashboardPushPeriod   = 5 * time.Second
+	dashboardHotspotLimit = 15
+	dashboardWriteTimeout = 10 * time.Second
+)
+
+// dashboardSnapshot is the dashboard summary for one window: the counters
+// and hourly buckets of /api/stats/last6h without its calls, and the
+// hotspots of /api/hotspots.
+type dashboardSnapshot struct {
+	Window           string           `json:"window"`
+	TotalIncidents   int              `json:"total_incidents"`
+	ByType           map[string]int   `json:"by_type"`
+	ByAgency         map[string]int   `json:"by_agency"`
+	ByStatus         map[string]int   `json:"by_status"`
+	TopIncidentTypes []tagCount       `json:"top_incident_types"`
+	TopAgencies      []tagCount       `json:"top_agencies"`
+	IncidentsPerHour []hourlyCount    `json:"incidents_per_hour"`
+	Hotspots         []hotspotSummary `json:"hotspots"`
+	MapEnabled       bool             `json:"map_enabled"`
+	hoursStart       time.Time
+}
+
+type dashboardSnapshotMessage struct {
+	Type  string            `json:"type"`
+	Stats dashboardSnapshot `json:"stats"`
+}
+
+// dashboardDelta carries what changed since the previous message. Counters
+// hold the new value of each changed key, with 0 for a key that is gone.
+// The hourly buckets first slide forward by hours_shift, then each entry in
+// hours replaces the bucket at its index. Hotspots are upserted by label and
+// position, and the top lists are always sent in full.
+type dashboardDelta struct {
+	Type             string                    `json:"type"`
+	Window           string                    `json:"window"`
+	TotalIncidents   int                       `json:"total_incidents"`
+	Counters         map[string]map[string]int `json:"counters,omitempty"`
+	TopIncidentTypes []tagCount                `json:"top_incident_types"`
+	TopAgencies      []tagCount                `json:"top_agencies"`
+	HoursShift       int                       `json:"hours_shift,omitempty"`
+	Hours            []hourDelta               `json:"hours,omitempty"`
+	Hotspots         []hotspotSummary          `json:"hotspots,omitempty"`
+	HotspotsRemoved  []hotspotKey              `json:"hotspots_removed,omitempty"`
+}
+
+type hourDelta struct {
+	Index int    `json:"index"`
+	Hour  string `json:"hour"`
+	Count int    `json:"count"`
+}
+
+type hotspotKey struct {
+	Label     string  `json:"label"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// dashboardSnapshot returns the snapshot for window. It is kept in the
+// query cache for half a push period, so connections watching the same
+// window share it.
+func (s *server) dashboardSnapshot(ctx context.Context, window string, admin bool) (dashboardSnapshot, error) {
+	windowName, windowDuration := normalizeWindowName(window, "6h")
+	snap, err := s.queries.load(fmt.Sprintf("live|%s|%t", windowName, admin), dashboardPushPeriod/2, func() (interface{}, error) {
+		stats, hoursStart, err := s.windowStats(ctx, admin, windowName, windowDuration, "")
+		if err != nil {
+			return nil, err
+		}
+		hotspots, err := s.loadHotspots(windowDuration, dashboardHotspotLimit)
+		if err != nil {
+			return nil, err
+		}
+		return dashboardSnapshot{
+			Window:           windowName,
+			TotalIncidents:   stats.TotalIncidents,
+			ByType:           stats.ByType,
+			ByAgency:         stats.ByAgency,
+			ByStatus:         stats.ByStatus,
+			TopIncidentTypes: stats.TopIncidentTypes,
+			TopAgencies:      stats.TopAgencies,
+			IncidentsPerHour: stats.IncidentsPerHour,
+			Hotspots:         hotspots,
+			MapEnabled:       stats.MapEnabled,
+			hoursStart:       hoursStart,
+		}, nil
+	})
+	if err != nil {
+		return dashboardSnapshot{}, err
+	}
+	return snap.(dashboardSnapshot), nil
+}
+
+// handleDashboardLive serves GET /api/stats/live[?window=6h] as a WebSocket
+// so the dashboard does not have to refetch ~500 calls to redraw its
+// summary. The first message is a full snapshot; after that a delta is sent
+// only when something changed. The client may send {"window": "24h"} to
+// switch windows, which is answered with a new snapshot. Requests carrying
+// the admin token count restricted calls like the REST endpoints do.
+func (s *server) handleDashboardLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	websocket.Server{Handler: s.serveDashboardLive}.ServeHTTP(w, r)
+}
+
+func (s *server) serveDashboardLive(ws *websocket.Conn) {
+	defer ws.Close()
+	r := ws.Request()
+	admin := isAdminRequest(r)
+	window, _ := normalizeWindowName(r.URL.Query().Get("window"), "6h")
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	windows := make(chan string, 1)
+	go func() {
+		defer cancel()
+		for {
+			var raw string
+			if err := websocket.Message.Receive(ws, &raw); err != nil {
+				return
+			}
+			var msg struct {
+				Window string `json:"window"`
+			}
+			if json.Unmarshal([]byte(raw), &msg) != nil || strings.TrimSpace(msg.Window) == "" {
+				continue
+			}
+			select {
+			case <-windows:
+			default:
+			}
+			windows <- msg.Window
+		}
+	}()
+
+	ticker := time.NewTicker(dashboardPushPeriod)
+	defer ticker.Stop()
+	var prev *dashboardSnapshot
+	for {
+		snap, err := s.dashboardSnapshot(ctx, window, admin)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("live dashboard query failed: %v", err)
+			}
+			return
+		}
+		var msg interface{}
+		if prev == nil {
+			msg = dashboardSnapshotMessage{Type: "snapshot", Stats: snap}
+		} else if delta, changed := diffDashboard(*prev, snap); changed {
+			msg = delta
+		}
+		if msg != nil {
+			ws.SetWriteDeadline(time.Now().Add(dashboardWriteTimeout))
+			if err := websocket.JSON.Send(ws, msg); err != nil {
+				return
+			}
+		}
+		prev = &snap
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.shutdown:
+			return
+		case next := <-windows:
+			window, _ = normalizeWindowName(next, window)
+			prev = nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// diffDashboard returns the delta from prev to next and whether anything
+// changed.
+func diffDashboard(prev, next dashboardSnapshot) (dashboardDelta, bool) {
+	delta := dashboardDelta{
+		Type:             "delta",
+		Window:           next.Window,
+		TotalIncidents:   next.TotalIncidents,
+		TopIncidentTypes: next.TopIncidentTypes,
+		TopAgencies:      next.TopAgencies,
+	}
+	changed := prev.TotalIncidents != next.TotalIncidents
+	for name, counts := range map[string][2]map[string]int{
+		"by_type":   {prev.ByType, next.ByType},
+		"by_agency": {prev.ByAgency, next.ByAgency},
+		"by_status": {prev.ByStatus, next.ByStatus},
+	} {
+		if diff := diffCounts(counts[0], counts[1]); len(diff) > 0 {
+			if delta.Counters == nil {
+				delta.Counters = make(map[string]map[string]int)
+			}
+			delta.Counters[name] = diff
+			changed = true
+		}
+	}
+
+	shift := int(next.hoursStart.Sub(prev.hoursStart) / time.Hour)
+	if shift < 0 || shift > len(prev.IncidentsPerHour) {
+		shift = len(prev.IncidentsPerHour)
+	}
+	delta.HoursShift = shift
+	for i, bucket := range next.IncidentsPerHour {
+		if j := i + shift; j < len(prev.IncidentsPerHour) && prev.IncidentsPerHour[j] == bucket {
+			continue
+		}
+		delta.Hours = append(delta.Hours, hourDelta{Index: i, Hour: bucket.Hour, Count: bucket.Count})
+	}
+	changed = changed || delta.HoursShift > 0 || len(delta.Hours) > 0
+
+	before := make(map[hotspotKey]hotspotSummary, len(prev.Hotspots))
+	for _, h := range prev.Hotspots {
+		before[keyOfHotspot(h)] = h
+	}
+	for _, h := range next.Hotspots {
+		key := keyOfHotspot(h)
+		old, ok := before[key]
+		delete(before, key)
+		if ok && old.Count == h.Count && sameTime(old.LastSeen, h.LastSeen) {
+			continue
+		}
+		delta.Hotspots = append(delta.Hotspots, h)
+	}
+	for key := range before {
+		delta.HotspotsRemoved = append(delta.HotspotsRemoved, key)
+	}
+	changed = changed || len(delta.Hotspots) > 0 || len(delta.HotspotsRemoved) > 0
+	return delta, changed
+}
+
+// diffCounts returns the keys whose count differs between prev and next,
+// with 0 for keys next no longer has.
+func diffCounts(prev, next map[string]int) map[string]int {
+	var out map[string]int
+	set := func(key string, n int) {
+		if out == nil {
+			out = make(map[string]int)
+		}
+		out[key] = n
+	}
+	for key, n := range next {
+		if prev[key] != n {
+			set(key, n)
+		}
+	}
+	for key := range prev {
+		if _, ok := next[key]; !ok {
+			set(key, 0)
+		}
+	}
+	return out
+}
+
+func keyOfHotspot(h hotspotSummary) hotspotKey {
+	return hotspotKey{Label: h.Label, Latitude: h.Latitude, Longitude: h.Longitude}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert_framework/backend/refine"
+	"alert_framework/errcode"
+	"alert_framework/formatting"
+	"alert_framework/prompts"
+)
+
+const maxDebugTranscriptLength = 20000
+
+type debugRefineRequest struct {
+	Transcript    string         `json:"transcript"`
+	Filename      string         `json:"filename"`
+	CleanupPrompt string         `json:"cleanup_prompt"`
+	RefinePrompts refine.Prompts `json:"refine_prompts"`
+}
+
+type debugCleanupResult struct {
+	PromptVersion        string   `json:"prompt_version"`
+	NormalizedTranscript string   `json:"normalized_transcript,omitempty"`
+	RecognizedTowns      []string `json:"recognized_towns,omitempty"`
+	Error                string   `json:"error,omitempty"`
+	ErrorCode            string   `json:"error_code,omitempty"`
+}
+
+type debugRefineResult struct {
+	Enabled           bool          `json:"enabled"`
+	CleanTranscript   string        `json:"clean_transcript,omitempty"`
+	Summary           string        `json:"summary,omitempty"`
+	Metadata          interface{}   `json:"metadata,omitempty"`
+	Address           interface{}   `json:"address,omitempty"`
+	NeedsManualReview bool          `json:"needs_manual_review"`
+	Stages            refine.Stages `json:"stages"`
+	Error             string        `json:"error,omitempty"`
+	ErrorCode         string        `json:"error_code,omitempty"`
+}
+
+type debugClassifyResult struct {
+	Input     string `json:"input"`
+	CallType  string `json:"call_type,omitempty"`
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+type debugRefineResponse struct {
+	Transcript string              `json:"transcript"`
+	Refine     debugRefineResult   `json:"refine"`
+	Cleanup    debugCleanupResult  `json:"cleanup"`
+	Classify   debugClassifyResult `json:"classify"`
+}
+
+// handleDebugRefine serves POST /api/debug/refine: it runs the text stages of
+// the pipeline (refinement, domain cleanup, classification) over a supplied
+// transcript and returns every stage's output without storing anything, so a
+// prompt edit can be previewed before it is saved. Each stage runs even when
+// an earlier one fails.
+func (s *server) handleDebugRefine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req debugRefineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	req.Transcript = strings.TrimSpace(req.Transcript)
+	if req.Transcript == "" {
+		http.Error(w, "transcript required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Transcript) > maxDebugTranscriptLength {
+		http.Error(w, fmt.Sprintf("transcript must be at most %d bytes", maxDebugTranscriptLength), http.StatusBadRequest)
+		return
+	}
+	var meta formatting.CallMetadata
+	if req.Filename != "" {
+		parsed, err := s.parseCallMetadata(req.Filename, "")
+		if err != nil {
+			http.Error(w, "invalid filename", http.StatusBadRequest)
+			return
+		}
+		meta = parsed
+	}
+
+	resp := debugRefineResponse{Transcript: req.Transcript}
+	cleaned := req.Transcript
+
+	if s.refiner != nil {
+		resp.Refine.Enabled = true
+		ctx, cancel := context.WithTimeout(r.Context(), 90*time.Second)
+		refined, err := s.refiner.Refine(ctx, refine.Request{
+			Transcript:      req.Transcript,
+			Metadata:        meta,
+			RecognizedTowns: []string{meta.TownDisplay},
+			Prompts:         req.RefinePrompts,
+		})
+		cancel()
+		resp.Refine.Stages = refined.Stages
+		if err != nil {
+			resp.Refine.Error = err.Error()
+			resp.Refine.ErrorCode = errcode.Of(refineError(err))
+		} else {
+			resp.Refine.CleanTranscript = refined.CleanTranscript
+			resp.Refine.Summary = refined.Summary
+			resp.Refine.Metadata = refined.Metadata
+			resp.Refine.Address = refined.Address
+			resp.Refine.NeedsManualReview = refined.NeedsManualReview
+			if strings.TrimSpace(refined.CleanTranscript) != "" {
+				cleaned = refined.CleanTranscript
+			}
+		}
+	}
+
+	// Without a supplied prompt the cleanup uses whatever a call with this
+	// filename would be assigned, so A/B versions can be previewed too.
+	assignment := prompts.Assignment{Kind: prompts.KindCleanup, Version: "supplied", Prompt: req.CleanupPrompt}
+	if strings.TrimSpace(req.CleanupPrompt) == "" {
+		assignment = s.assignPrompt(r.Context(), prompts.KindCleanup, req.Filename)
+	}
+	resp.Cleanup.PromptVersion = assignment.Version
+	if _, normalized, towns, err := s.domainCleanup(req.Transcript, assignment.Prompt); err != nil {
+		resp.Cleanup.Error = err.Error()
+		resp.Cleanup.ErrorCode = errcode.Of(refineError(err))
+	} else {
+		resp.Cleanup.NormalizedTranscript = normalized
+		resp.Cleanup.RecognizedTowns = towns
+	}
+
+	resp.Classify.Input = cleaned
+	if callType, err := s.classifyCallType(cleaned); err != nil {
+		resp.Classify.Error = err.Error()
+		resp.Classify.ErrorCode = errorCodeFor(err)
+	} else {
+		resp.Classify.CallType = *callType
+	}
+	respondJSON(w, resp)
+}
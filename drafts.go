@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// draftTimeout bounds a draft run; a draft slower than this is no use
+	// next to the full transcript.
+	draftTimeout = 30 * time.Second
+	// draftConcurrency is how many drafts run at once. Ingest bursts beyond
+	// it get no draft rather than a late one.
+	draftConcurrency  = 2
+	draftStreamBatch  = 100
+	draftStreamPeriod = time.Second
+)
+
+// draftEvent is sent on /api/drafts/stream when a call's draft transcript is
+// ready.
+type draftEvent struct {
+	Filename string    `json:"filename"`
+	Draft    string    `json:"draft_transcript"`
+	DraftAt  time.Time `json:"draft_at"`
+	Status   string    `json:"status"`
+	callType string
+}
+
+// draftFinal is sent once a streamed call's full transcription finishes.
+type draftFinal struct {
+	Filename string `json:"filename"`
+	Status   string `json:"status"`
+}
+
+func migrateAddDraftTranscripts(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "transcriptions", "draft_transcript", "TEXT NULL"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "transcriptions", "draft_at", "DATETIME NULL"); err != nil {
+		return err
+	}
+	_, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_transcriptions_draft_at ON transcriptions(draft_at)`)
+	return err
+}
+
+func downDraftTranscripts(db *sql.DB) error {
+	if _, err := execWithRetry(db, `DROP INDEX IF EXISTS idx_transcriptions_draft_at`); err != nil {
+		return err
+	}
+	if err := dropColumnIfExists(db, "transcriptions", "draft_at"); err != nil {
+		return err
+	}
+	return dropColumnIfExists(db, "transcriptions", "draft_transcript")
+}
+
+// startDraft transcribes a newly queued call with the DRAFT_ENGINE in the
+// background, so the dashboard has rough text while the OpenAI pass runs.
+// Drafts never hold up the job: when all draft slots are busy the call gets
+// none, and a draft that lands after the call is done is dropped.
+func (s *server) startDraft(filename, sourcePath string, opts TranscriptionOptions) {
+	if s.cfg.DraftEngine == "" {
+		return
+	}
+	select {
+	case s.drafts <- struct{}{}:
+	default:
+		log.Printf("draft transcript skipped for %s: %d drafts already running", filename, cap(s.drafts))
+		return
+	}
+	go func() {
+		defer func() { <-s.drafts }()
+		start := time.Now()
+		text, err := s.transcribeDraft(sourcePath, opts.LanguageHint)
+		if err != nil {
+			log.Printf("draft transcript failed for %s: %v", filename, err)
+			return
+		}
+		if _, err := execWithRetry(s.db, `UPDATE transcriptions SET draft_transcript = ?, draft_at = ? WHERE filename = ? AND status != ?`,
+			text, time.Now().UTC(), filename, statusDone); err != nil {
+			log.Printf("draft transcript save failed for %s: %v", filename, err)
+			return
+		}
+		log.Printf("draft transcript for %s took %s", filename, time.Since(start).Round(time.Millisecond))
+	}()
+}
+
+// transcribeDraft waits for the recording to stop growing and runs the draft
+// engine over it. Vosk is run through its vosk-transcriber CLI, which decodes
+// the audio itself; whisper.cpp gets the same 16 kHz WAV as the local
+// fallback.
+func (s *server) transcribeDraft(path, language string) (string, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, draftTimeout)
+	defer cancel()
+	if err := waitForStableSize(ctx, path, 0, 500*time.Millisecond, 2); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(s.cfg.WorkDir, "draft-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	var cmd *exec.Cmd
+	var outPath string
+	switch s.cfg.DraftEngine {
+	case "vosk":
+		outPath = filepath.Join(dir, "transcript.txt")
+		cmd = exec.CommandContext(ctx, s.cfg.DraftBin, "-m", s.cfg.DraftModel, "-i", path, "-o", outPath, "-t", "txt")
+	default:
+		cmd, outPath, err = whisperCPPCommand(ctx, s.cfg.DraftBin, s.cfg.DraftModel, path, dir, language, false)
+		if err != nil {
+			return "", err
+		}
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("%s timed out after %s", s.cfg.DraftEngine, draftTimeout)
+		}
+		return "", fmt.Errorf("%s: %v: %s", s.cfg.DraftEngine, err, truncateText(strings.TrimSpace(string(out)), 500))
+	}
+	return readWhisperOutput(s.cfg.DraftEngine, outPath)
+}
+
+// draftFor is the draft shown with a call until its full transcript is in.
+func draftFor(t transcription) *string {
+	if t.Status == statusDone {
+		return nil
+	}
+	return t.DraftTranscript
+}
+
+func (s *server) draftsSince(ctx context.Context, since time.Time, limit int) ([]draftEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT filename, draft_transcript, draft_at, status, COALESCE(call_type, '') FROM transcriptions
+WHERE draft_at > ? AND draft_transcript IS NOT NULL AND deleted_at IS NULL ORDER BY draft_at LIMIT ?`, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []draftEvent
+	for rows.Next() {
+		var d draftEvent
+		if err := rows.Scan(&d.Filename, &d.Draft, &d.DraftAt, &d.Status, &d.callType); err != nil {
+			return nil, err
+		}
+		if d.callType == "" {
+			if meta, err := s.parseCallMetadata(d.Filename, ""); err == nil {
+				d.callType = meta.CallType
+			}
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// finishedDrafts returns the calls among pending whose transcription has
+// finished, with their final status.
+func (s *server) finishedDrafts(ctx context.Context, pending map[string]struct{}) ([]draftFinal, error) {
+	args := []interface{}{statusDone, statusError}
+	for filename := range pending {
+		args = append(args, filename)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT filename, status FROM transcriptions WHERE status IN (?, ?) AND filename IN (?`+strings.Repeat(", ?", len(pending)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []draftFinal
+	for rows.Next() {
+		var f draftFinal
+		if err := rows.Scan(&f.Filename, &f.Status); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// handleDraftStream serves GET /api/drafts/stream[?since=RFC3339] as
+// server-sent events: a "draft" event for each draft transcript written after
+// since (default: now), then a "final" event when that call's transcription
+// finishes, so the dashboard knows to fetch the full record. The database is
+// polled every second, so drafts written by a separate worker process are
+// picked up too. Drafts of restricted calls are admin-only, and drafts are
+// scrubbed for public requests like other transcripts.
+func (s *server) handleDraftStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cfg.DraftEngine == "" {
+		http.Error(w, "draft transcripts disabled", http.StatusNotFound)
+		return
+	}
+	cursor := time.Now().UTC()
+	if raw := strings.TrimSpace(r.URL.Query().Get("since")); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		cursor = t
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	admin := isAdminRequest(r)
+	pending := map[string]struct{}{}
+	ticker := time.NewTicker(draftStreamPeriod)
+	defer ticker.Stop()
+	for {
+		drafts, err := s.draftsSince(r.Context(), cursor, draftStreamBatch)
+		if err != nil {
+			if r.Context().Err() == nil {
+				log.Printf("draft stream query failed: %v", err)
+			}
+			return
+		}
+		for _, d := range drafts {
+			cursor = d.DraftAt
+			if !admin {
+				if len(s.restrictedFlags(d.callType, d.Draft)) > 0 {
+					continue
+				}
+				d.Draft = s.scrubText(d.Draft)
+			}
+			data, _ := json.Marshal(d)
+			fmt.Fprintf(w, "event: draft\ndata: %s\n\n", data)
+			if d.Status != statusDone && d.Status != statusError {
+				pending[d.Filename] = struct{}{}
+			}
+		}
+		if len(pending) > 0 {
+			finished, err := s.finishedDrafts(r.Context(), pending)
+			if err != nil {
+				if r.Context().Err() == nil {
+					log.Printf("draft stream status query failed: %v", err)
+				}
+				return
+			}
+			for _, f := range finished {
+				data, _ := json.Marshal(f)
+				fmt.Fprintf(w, "event: final\ndata: %s\n\n", data)
+				delete(pending, f.Filename)
+			}
+		}
+		flusher.Flush()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
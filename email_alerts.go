@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image/png"
+	"log"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+	"alert_framework/mailer"
+	"alert_framework/rollups"
+)
+
+const (
+	defaultSMTPPort        = 587
+	emailSendTimeout       = 30 * time.Second
+	emailDigestCheckPeriod = 10 * time.Minute
+	emailPreviewCID        = "preview"
+)
+
+// EmailSettings configures the SMTP notifier. Each recipient chooses instant
+// alerts, the daily digest or both, with the same filters webhook endpoints
+// use. The digest of the previous day goes out during DigestHour (local).
+type EmailSettings struct {
+	SMTPHost    string           `json:"smtp_host"`
+	SMTPPort    int              `json:"smtp_port"`
+	Username    string           `json:"username,omitempty"`
+	Password    string           `json:"password,omitempty"`
+	HasPassword bool             `json:"has_password"`
+	From        string           `json:"from"`
+	DigestHour  int              `json:"digest_hour"`
+	Recipients  []EmailRecipient `json:"recipients"`
+}
+
+// EmailRecipient is one address and what it receives. Empty filters match
+// everything.
+type EmailRecipient struct {
+	Address     string   `json:"address"`
+	Instant     bool     `json:"instant"`
+	Digest      bool     `json:"digest"`
+	Towns       []string `json:"towns,omitempty"`
+	Categories  []string `json:"categories,omitempty"`
+	MinPriority string   `json:"min_priority,omitempty"`
+}
+
+func (r EmailRecipient) matches(ev webhookEvent) bool {
+	if len(r.Towns) > 0 && !anyEqualFold(r.Towns, ev.Towns) {
+		return false
+	}
+	if len(r.Categories) > 0 && !anyEqualFold(r.Categories, []string{ev.Category}) {
+		return false
+	}
+	return rollups.PriorityAtLeast(ev.Priority, r.MinPriority)
+}
+
+func (e EmailSettings) configured() bool {
+	return strings.TrimSpace(e.SMTPHost) != "" && strings.TrimSpace(e.From) != ""
+}
+
+func (e EmailSettings) smtpConfig() mailer.Config {
+	port := e.SMTPPort
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	return mailer.Config{Host: e.SMTPHost, Port: port, Username: e.Username, Password: e.Password, From: e.From}
+}
+
+func migrateAddEmailNotifications(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "app_settings", "email_settings", "TEXT"); err != nil {
+		return err
+	}
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS email_digests (
+    day TEXT PRIMARY KEY,
+    recipients INTEGER NOT NULL,
+    calls INTEGER NOT NULL,
+    sent_at DATETIME NOT NULL
+);`)
+	return err
+}
+
+// validateEmailSettings normalizes e in place and rejects addresses, hours
+// and priorities the notifier could not use.
+func validateEmailSettings(e *EmailSettings) error {
+	e.SMTPHost = strings.TrimSpace(e.SMTPHost)
+	e.From = strings.TrimSpace(e.From)
+	if e.SMTPPort < 0 || e.SMTPPort > 65535 {
+		return fmt.Errorf("invalid smtp_port %d", e.SMTPPort)
+	}
+	if e.DigestHour < 0 || e.DigestHour > 23 {
+		return fmt.Errorf("digest_hour must be between 0 and 23")
+	}
+	if e.SMTPHost != "" {
+		if _, err := mail.ParseAddress(e.From); err != nil {
+			return fmt.Errorf("invalid from address %q", e.From)
+		}
+	}
+	for i := range e.Recipients {
+		r := &e.Recipients[i]
+		r.Address = strings.TrimSpace(r.Address)
+		r.MinPriority = strings.ToLower(strings.TrimSpace(r.MinPriority))
+		if _, err := mail.ParseAddress(r.Address); err != nil {
+			return fmt.Errorf("invalid email recipient %q", r.Address)
+		}
+		switch r.MinPriority {
+		case "", "low", "medium", "high":
+		default:
+			return fmt.Errorf("invalid min_priority %q for %s", r.MinPriority, r.Address)
+		}
+	}
+	if e.Recipients == nil {
+		e.Recipients = []EmailRecipient{}
+	}
+	return nil
+}
+
+// redactEmailPassword hides the SMTP password from the settings response,
+// leaving only has_password.
+func redactEmailPassword(e EmailSettings) EmailSettings {
+	e.HasPassword = e.Password != ""
+	e.Password = ""
+	return e
+}
+
+// keepEmailPassword carries the saved password over when the redacted
+// settings are posted back without one.
+func keepEmailPassword(e *EmailSettings, existing EmailSettings) {
+	if e.Password == "" && e.HasPassword {
+		e.Password = existing.Password
+	}
+	e.HasPassword = false
+}
+
+// sendEmailAlerts mails an alerted call to every instant recipient whose
+// filters match, one message each so addresses are not shared. Delivery is
+// best effort.
+func (s *server) sendEmailAlerts(j processJob, incident formatting.IncidentDetails, alertBody, transcript string) {
+	settings, err := s.loadSettings()
+	if err != nil || !settings.Email.configured() {
+		return
+	}
+	ev := webhookEvent{
+		Towns:    []string{incident.CityOrTown},
+		Category: incident.CallCategory,
+		Priority: rollups.CallPriority(incident.CallType, transcript),
+	}
+	if record, err := s.getTranscription(j.filename); err == nil {
+		ev.Towns = append(ev.Towns, parseRecognizedTowns(record.RecognizedTowns)...)
+	}
+	var to []string
+	for _, r := range settings.Email.Recipients {
+		if r.Instant && r.matches(ev) {
+			to = append(to, r.Address)
+		}
+	}
+	if len(to) == 0 {
+		return
+	}
+	msg, err := s.buildAlertEmail(j.filename, incident, alertBody)
+	if err != nil {
+		log.Printf("email alert for %s failed: %v", j.filename, err)
+		return
+	}
+	for _, addr := range to {
+		msg.To = []string{addr}
+		if err := s.sendEmail(settings.Email, msg); err != nil {
+			log.Printf("email alert for %s to %s failed: %v", j.filename, addr, err)
+		}
+	}
+}
+
+func (s *server) sendEmail(settings EmailSettings, msg mailer.Message) error {
+	ctx, cancel := context.WithTimeout(s.ctx, emailSendTimeout)
+	defer cancel()
+	return mailer.Send(ctx, settings.smtpConfig(), msg)
+}
+
+// buildAlertEmail renders the instant alert: the GroupMe text as the plain
+// part and an HTML card with the preview image inline and a listen link.
+func (s *server) buildAlertEmail(filename string, incident formatting.IncidentDetails, alertBody string) (mailer.Message, error) {
+	subject := formatting.FormatIncidentHeader(incident)
+	if incident.Updated {
+		subject = "Updated: " + subject
+	}
+	msg := mailer.Message{Subject: subject, Text: alertBody}
+	data := alertEmailData{
+		Header:   subject,
+		Location: formatting.FormatIncidentLocation(incident),
+		Time:     incident.Timestamp.In(s.tz).Format("Mon Jan 2 3:04 PM"),
+		Summary:  incident.Summary,
+		Listen:   incident.ListenURL,
+		Map:      incident.MapURL(),
+	}
+	if record, err := s.getTranscription(filename); err == nil {
+		if img, err := s.renderPreviewImage(s.publicRecord(*record)); err == nil {
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err == nil {
+				msg.Inline = []mailer.Inline{{ContentID: emailPreviewCID, ContentType: "image/png", Filename: "preview.png", Data: buf.Bytes()}}
+				data.PreviewCID = emailPreviewCID
+			}
+		}
+	}
+	var html bytes.Buffer
+	if err := alertEmailPage.Execute(&html, data); err != nil {
+		return msg, err
+	}
+	msg.HTML = html.String()
+	return msg, nil
+}
+
+type alertEmailData struct {
+	Header     string
+	Location   string
+	Time       string
+	Summary    string
+	Listen     string
+	Map        string
+	PreviewCID string
+}
+
+var alertEmailPage = template.Must(template.New("alert").Parse(`<!doctype html>
+<html><body style="margin:0;padding:16px;font:14px/1.45 system-ui,sans-serif;color:#1f2933">
+<h2 style="margin:0 0 4px;font-size:18px">{{.Header}}</h2>
+<div style="color:#616e7c">{{.Time}} · {{.Location}}</div>
+{{if .PreviewCID}}<p><img src="cid:{{.PreviewCID}}" alt="" style="max-width:100%;border:1px solid #e4e7eb"></p>{{end}}
+{{if .Summary}}<p>{{.Summary}}</p>{{end}}
+{{if .Listen}}<p><a href="{{.Listen}}" style="display:inline-block;padding:8px 14px;background:#3f8bd6;color:#fff;text-decoration:none;border-radius:4px">Listen</a>{{if .Map}} <a href="{{.Map}}" style="margin-left:8px;color:#3f8bd6">Map</a>{{end}}</p>{{else if .Map}}<p><a href="{{.Map}}" style="color:#3f8bd6">Map</a></p>{{end}}
+</body></html>`))
+
+// digestCall is one line of the daily digest.
+type digestCall struct {
+	Time     string
+	CallType string
+	Location string
+	Listen   string
+	event    webhookEvent
+}
+
+// startEmailDigestScheduler sends the previous day's digest once during the
+// configured digest hour. Days already recorded in email_digests are skipped,
+// so restarts do not send it twice.
+func (s *server) startEmailDigestScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(emailDigestCheckPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+			settings, err := s.loadSettings()
+			if err != nil || !settings.Email.configured() {
+				continue
+			}
+			now := time.Now().In(s.tz)
+			if now.Hour() != settings.Email.DigestHour {
+				continue
+			}
+			day := now.AddDate(0, 0, -1).Format(archiveDateLayout)
+			var sent int
+			if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+				return row.Scan(&sent)
+			}, `SELECT COUNT(*) FROM email_digests WHERE day = ?`, day); err != nil || sent > 0 {
+				continue
+			}
+			if err := s.sendDailyDigest(ctx, settings.Email, now.AddDate(0, 0, -1), nil); err != nil {
+				log.Printf("email digest for %s failed: %v", day, err)
+			}
+		}
+	}()
+}
+
+// sendDailyDigest mails day's calls to each digest recipient, filtered per
+// recipient. With only set, it goes to that address unfiltered and is not
+// recorded, which is what the send-test endpoint uses.
+func (s *server) sendDailyDigest(ctx context.Context, settings EmailSettings, day time.Time, only []string) error {
+	calls, err := s.digestCalls(ctx, day)
+	if err != nil {
+		return err
+	}
+	type target struct {
+		addr  string
+		match func(webhookEvent) bool
+	}
+	var targets []target
+	if len(only) > 0 {
+		for _, addr := range only {
+			targets = append(targets, target{addr: addr, match: func(webhookEvent) bool { return true }})
+		}
+	} else {
+		for _, r := range settings.Recipients {
+			if r.Digest {
+				targets = append(targets, target{addr: r.Address, match: r.matches})
+			}
+		}
+	}
+	label := day.Format("Monday, January 2")
+	var failed error
+	for _, t := range targets {
+		var mine []digestCall
+		for _, c := range calls {
+			if t.match(c.event) {
+				mine = append(mine, c)
+			}
+		}
+		msg, err := buildDigestEmail(label, mine)
+		if err != nil {
+			return err
+		}
+		msg.To = []string{t.addr}
+		if err := s.sendEmail(settings, msg); err != nil {
+			log.Printf("email digest to %s failed: %v", t.addr, err)
+			failed = err
+		}
+	}
+	if len(only) > 0 {
+		return failed
+	}
+	_, err = execWithRetry(s.db, `INSERT OR REPLACE INTO email_digests (day, recipients, calls, sent_at) VALUES (?, ?, ?, ?)`,
+		day.Format(archiveDateLayout), len(targets), len(calls), time.Now().UTC())
+	return err
+}
+
+// digestCalls lists day's completed, non-duplicate calls in order, leaving
+// out restricted ones.
+func (s *server) digestCalls(ctx context.Context, day time.Time) ([]digestCall, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, s.tz)
+	end := start.AddDate(0, 0, 1)
+	records, err := s.store.Select(ctx, `WHERE status = ? AND duplicate_of IS NULL AND call_timestamp >= ? AND call_timestamp < ? ORDER BY call_timestamp`, statusDone, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]digestCall, 0, len(records))
+	for _, t := range records {
+		if s.isRestricted(t) {
+			continue
+		}
+		meta, _ := s.parseCallMetadata(t.Filename, "")
+		callType := strings.TrimSpace(derefString(t.CallType, meta.CallType))
+		towns := append([]string{meta.TownDisplay}, parseRecognizedTowns(t.RecognizedTowns)...)
+		location := strings.TrimSpace(derefString(t.LocationLabel, ""))
+		if location == "" {
+			location = meta.TownDisplay
+		}
+		calls = append(calls, digestCall{
+			Time:     t.CallTimestamp.In(s.tz).Format("3:04 PM"),
+			CallType: callType,
+			Location: location,
+			Listen:   listenURLFor(s.audioFilename(t)),
+			event: webhookEvent{
+				Towns:    towns,
+				Category: formatting.NormalizeCallCategory(callType),
+				Priority: rollups.CallPriority(callType, derefString(pickTranscript(&t), "")),
+			},
+		})
+	}
+	return calls, nil
+}
+
+type digestCount struct {
+	Name  string
+	Calls int
+}
+
+func buildDigestEmail(day string, calls []digestCall) (mailer.Message, error) {
+	counts := make(map[string]int)
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d calls on %s\n\n", len(calls), day)
+	for _, c := range calls {
+		counts[fallbackEmpty(c.event.Category, "other")]++
+		fmt.Fprintf(&text, "%s  %s  %s\n%s\n\n", c.Time, fallbackEmpty(c.CallType, "Call"), c.Location, c.Listen)
+	}
+	byCategory := make([]digestCount, 0, len(counts))
+	for name, n := range counts {
+		byCategory = append(byCategory, digestCount{Name: name, Calls: n})
+	}
+	sort.Slice(byCategory, func(i, j int) bool {
+		if byCategory[i].Calls != byCategory[j].Calls {
+			return byCategory[i].Calls > byCategory[j].Calls
+		}
+		return byCategory[i].Name < byCategory[j].Name
+	})
+	var html bytes.Buffer
+	if err := digestEmailPage.Execute(&html, map[string]interface{}{"Day": day, "Calls": calls, "Categories": byCategory}); err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{Subject: fmt.Sprintf("Daily digest for %s: %d calls", day, len(calls)), Text: text.String(), HTML: html.String()}, nil
+}
+
+var digestEmailPage = template.Must(template.New("digest").Parse(`<!doctype html>
+<html><body style="margin:0;padding:16px;font:14px/1.45 system-ui,sans-serif;color:#1f2933">
+<h2 style="margin:0 0 4px;font-size:18px">{{len .Calls}} calls on {{.Day}}</h2>
+<div style="color:#616e7c">{{range $i, $c := .Categories}}{{if $i}} · {{end}}{{$c.Name}} {{$c.Calls}}{{end}}</div>
+<table style="border-collapse:collapse;width:100%;margin-top:12px">
+{{range .Calls}}<tr><td style="padding:4px 8px;border-bottom:1px solid #f0f2f4;white-space:nowrap">{{.Time}}</td><td style="padding:4px 8px;border-bottom:1px solid #f0f2f4">{{or .CallType "Call"}}</td><td style="padding:4px 8px;border-bottom:1px solid #f0f2f4">{{.Location}}</td><td style="padding:4px 8px;border-bottom:1px solid #f0f2f4">{{if .Listen}}<a href="{{.Listen}}">Listen</a>{{end}}</td></tr>
+{{end}}</table>
+</body></html>`))
+
+type emailTestRequest struct {
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// handleEmailTest serves POST /api/admin/email/test: it sends one instant
+// alert for the newest public call, or yesterday's digest with kind=digest,
+// to the given address using the saved SMTP settings, and reports the SMTP
+// error if delivery fails.
+func (s *server) handleEmailTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req emailTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	req.To = strings.TrimSpace(req.To)
+	if _, err := mail.ParseAddress(req.To); err != nil {
+		http.Error(w, "to must be an email address", http.StatusBadRequest)
+		return
+	}
+	settings, err := s.loadSettings()
+	if err != nil {
+		log.Printf("load settings failed: %v", err)
+		http.Error(w, "settings error", http.StatusInternalServerError)
+		return
+	}
+	if !settings.Email.configured() {
+		http.Error(w, "smtp is not configured", http.StatusConflict)
+		return
+	}
+	switch req.Kind {
+	case "digest":
+		err = s.sendDailyDigest(r.Context(), settings.Email, time.Now().In(s.tz).AddDate(0, 0, -1), []string{req.To})
+	case "", "instant":
+		req.Kind = "instant"
+		err = s.sendTestAlert(r.Context(), settings.Email, req.To)
+	default:
+		http.Error(w, "kind must be instant or digest", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("email test to %s failed: %v", req.To, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		respondJSON(w, map[string]interface{}{"status": "failed", "kind": req.Kind, "error": err.Error()})
+		return
+	}
+	respondJSON(w, map[string]interface{}{"status": "sent", "kind": req.Kind, "to": req.To})
+}
+
+// sendTestAlert mails the instant alert for the newest completed public call,
+// or a placeholder when there is none yet.
+func (s *server) sendTestAlert(ctx context.Context, settings EmailSettings, to string) error {
+	records, err := s.store.Select(ctx, `WHERE status = ? AND duplicate_of IS NULL ORDER BY call_timestamp DESC LIMIT 20`, statusDone)
+	if err != nil {
+		return err
+	}
+	msg := mailer.Message{Subject: "Test alert", Text: "This is a test of the email alert settings."}
+	for _, t := range records {
+		if s.isRestricted(t) {
+			continue
+		}
+		public := s.publicRecord(t)
+		meta, _, _, _ := s.buildJobContext(t.Filename, "")
+		recognized := parseRecognizedTowns(public.RecognizedTowns)
+		callTime := meta.DateTime
+		if public.CallTimestamp != nil {
+			callTime = public.CallTimestamp.In(s.tz)
+		}
+		summary := derefString(pickTranscript(&public), "")
+		audioName := s.audioFilename(public)
+		incident := s.buildIncidentDetails(meta, public.CallType, parseRecognizedTownList(public.TagsJSON), s.locationFromRecord(public, meta), recognized, callTime, audioName, listenURLFor(audioName), summary)
+		msg, err = s.buildAlertEmail(t.Filename, incident, formatting.BuildIncidentAlert(incident))
+		if err != nil {
+			return err
+		}
+		msg.Subject = "Test: " + msg.Subject
+		break
+	}
+	msg.To = []string{to}
+	return mailer.Send(ctx, settings.smtpConfig(), msg)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/embedtoken"
+)
+
+const (
+	defaultEmbedLimit = 10
+	maxEmbedLimit     = 50
+	// embedScanLimit bounds how many recent calls are read to fill a
+	// town-filtered feed.
+	embedScanLimit = 500
+)
+
+// embedCall is the slice of a call a third-party page may show: no transcript,
+// audio or location beyond the town.
+type embedCall struct {
+	Title          string    `json:"title"`
+	CallType       string    `json:"call_type,omitempty"`
+	CallCategory   string    `json:"call_category,omitempty"`
+	Town           string    `json:"town,omitempty"`
+	CallTimestamp  time.Time `json:"call_timestamp"`
+	TimestampLocal string    `json:"timestamp_local,omitempty"`
+}
+
+type embedResponse struct {
+	Label       string      `json:"label,omitempty"`
+	Towns       []string    `json:"towns,omitempty"`
+	Calls       []embedCall `json:"calls"`
+	GeneratedAt time.Time   `json:"generated_at"`
+}
+
+type embedTokenRequest struct {
+	Label         string   `json:"label"`
+	Towns         []string `json:"towns"`
+	Limit         int      `json:"limit"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+type embedTokenResponse struct {
+	Token    string            `json:"token"`
+	Claims   embedtoken.Claims `json:"claims"`
+	EmbedURL string            `json:"embed_url"`
+	JSONURL  string            `json:"json_url"`
+	IFrame   string            `json:"iframe"`
+}
+
+var embedPage = template.Must(template.New("embed").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Label}}{{.Label}}{{else}}Recent calls{{end}}</title>
+<style>
+body{margin:0;font:14px/1.4 system-ui,sans-serif;color:#1f2933;background:#fff}
+h1{margin:0;padding:8px 12px;font-size:15px;border-bottom:1px solid #e4e7eb}
+ul{list-style:none;margin:0;padding:0}
+li{padding:8px 12px;border-bottom:1px solid #f0f2f4}
+.t{font-weight:600}
+.m{color:#616e7c;font-size:12px}
+.fire{border-left:3px solid #d64545}
+.ems{border-left:3px solid #3f8bd6}
+.other{border-left:3px solid #9aa5b1}
+.empty{padding:12px;color:#616e7c}
+</style>
+</head>
+<body>
+<h1>{{if .Label}}{{.Label}}{{else}}Recent calls{{end}}</h1>
+{{if .Calls}}<ul>
+{{range .Calls}}<li class="{{.CallCategory}}"><div class="t">{{.Title}}</div><div class="m">{{if .Town}}{{.Town}} · {{end}}<time datetime="{{.CallTimestamp.Format "2006-01-02T15:04:05Z07:00"}}">{{.CallTimestamp.Format "Jan 2, 3:04 PM"}}</time></div></li>
+{{end}}</ul>{{else}}<div class="empty">No recent calls.</div>{{end}}
+</body>
+</html>
+`))
+
+// handleEmbedTokens issues an embed token (POST, admin). Tokens are not
+// stored; the claims travel inside the token.
+func (s *server) handleEmbedTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if s.cfg.EmbedSecret == "" {
+		http.Error(w, "EMBED_SECRET is not set", http.StatusServiceUnavailable)
+		return
+	}
+	var req embedTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if req.Limit < 0 || req.Limit > maxEmbedLimit {
+		http.Error(w, fmt.Sprintf("limit must be between 0 and %d", maxEmbedLimit), http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		http.Error(w, "expires_in_days must not be negative", http.StatusBadRequest)
+		return
+	}
+	now := time.Now().UTC()
+	claims := embedtoken.Claims{Label: strings.TrimSpace(req.Label), Limit: req.Limit, Issued: now.Unix()}
+	for _, town := range req.Towns {
+		if town = strings.TrimSpace(town); town != "" {
+			claims.Towns = append(claims.Towns, town)
+		}
+	}
+	if req.ExpiresInDays > 0 {
+		claims.Expires = now.AddDate(0, 0, req.ExpiresInDays).Unix()
+	}
+	token, err := embedtoken.Sign(s.cfg.EmbedSecret, claims)
+	if err != nil {
+		http.Error(w, "sign failed", http.StatusInternalServerError)
+		return
+	}
+	base := s.resolveBaseURL(r)
+	query := "?token=" + url.QueryEscape(token)
+	resp := embedTokenResponse{
+		Token:    token,
+		Claims:   claims,
+		EmbedURL: base + "/embed/recent" + query,
+		JSONURL:  base + "/api/embed" + query,
+	}
+	resp.IFrame = fmt.Sprintf(`<iframe src="%s" width="360" height="480" style="border:0" title="Recent calls"></iframe>`, template.HTMLEscapeString(resp.EmbedURL))
+	respondJSON(w, resp)
+}
+
+// handleEmbedJSON serves /api/embed?token=: the embed feed as JSON, readable
+// from any origin.
+func (s *server) handleEmbedJSON(w http.ResponseWriter, r *http.Request) {
+	claims, calls, ok := s.embedFeed(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	respondJSON(w, embedResponse{Label: claims.Label, Towns: claims.Towns, Calls: calls, GeneratedAt: time.Now().UTC()})
+}
+
+// handleEmbedRecent serves /embed/recent?token=: a standalone page meant for
+// an iframe that reloads itself every minute.
+func (s *server) handleEmbedRecent(w http.ResponseWriter, r *http.Request) {
+	claims, calls, ok := s.embedFeed(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors *")
+	if err := embedPage.Execute(w, embedResponse{Label: claims.Label, Calls: calls}); err != nil {
+		log.Printf("embed page render failed: %v", err)
+	}
+}
+
+// embedFeed checks the request's token and loads the calls it grants. It
+// writes the error response itself when it returns false.
+func (s *server) embedFeed(w http.ResponseWriter, r *http.Request) (embedtoken.Claims, []embedCall, bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return embedtoken.Claims{}, nil, false
+	}
+	if s.cfg.EmbedSecret == "" {
+		http.NotFound(w, r)
+		return embedtoken.Claims{}, nil, false
+	}
+	claims, err := embedtoken.Parse(s.cfg.EmbedSecret, r.URL.Query().Get("token"), time.Now())
+	if err != nil {
+		msg := "invalid embed token"
+		if errors.Is(err, embedtoken.ErrExpired) {
+			msg = "embed token expired"
+		}
+		http.Error(w, msg, http.StatusForbidden)
+		return claims, nil, false
+	}
+	limit := claims.Limit
+	if limit <= 0 {
+		limit = defaultEmbedLimit
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil && v > 0 && v < limit {
+			limit = v
+		}
+	}
+	calls, err := s.embedCalls(r.Context(), claims.Towns, limit, s.resolveBaseURL(r))
+	if err != nil {
+		log.Printf("embed feed failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return claims, nil, false
+	}
+	return claims, calls, true
+}
+
+// embedCalls returns the latest completed public calls, optionally limited to
+// towns. Restricted calls never appear, whoever asks.
+func (s *server) embedCalls(ctx context.Context, towns []string, limit int, baseURL string) ([]embedCall, error) {
+	records, err := s.store.Select(ctx, `WHERE status = ? AND duplicate_of IS NULL ORDER BY effective_ts DESC LIMIT ?`, statusDone, embedScanLimit)
+	if err != nil {
+		return nil, err
+	}
+	calls := []embedCall{}
+	for _, t := range records {
+		if len(calls) == limit {
+			break
+		}
+		if s.isRestricted(t) {
+			continue
+		}
+		resp := s.toResponse(s.publicRecord(t), baseURL)
+		if len(towns) > 0 && !embedTownMatch(towns, resp.Town, resp.CityOrTown) {
+			continue
+		}
+		title := resp.PrettyTitle
+		if title == "" {
+			title = derefString(resp.CallType, "Call")
+		}
+		town := resp.Town
+		if town == "" {
+			town = resp.CityOrTown
+		}
+		calls = append(calls, embedCall{
+			Title:          title,
+			CallType:       derefString(resp.CallType, ""),
+			CallCategory:   resp.CallCategory,
+			Town:           town,
+			CallTimestamp:  resp.CallTimestamp,
+			TimestampLocal: resp.TimestampLocal,
+		})
+	}
+	return calls, nil
+}
+
+func embedTownMatch(towns []string, candidates ...string) bool {
+	for _, town := range towns {
+		for _, c := range candidates {
+			if c != "" && strings.EqualFold(strings.TrimSpace(c), town) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package embedtoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignParseRoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token, err := Sign("secret", Claims{Label: "Herald", Towns: []string{"Sparta"}, Limit: 5, Issued: now.Unix()})
+	if err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	c, err := Parse("secret", token, now)
+	if err != nil {
+		t.Fatalf("expected valid token, got %v", err)
+	}
+	if c.Label != "Herald" || len(c.Towns) != 1 || c.Towns[0] != "Sparta" || c.Limit != 5 {
+		t.Fatalf("unexpected claims: %+v", c)
+	}
+	if _, err := Parse("other", token, now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected signature mismatch for wrong secret, got %v", err)
+	}
+}
+
+func TestParseRejectsTamperedAndExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token, _ := Sign("secret", Claims{Towns: []string{"Sparta"}, Issued: now.Unix(), Expires: now.Add(time.Hour).Unix()})
+	other, _ := Sign("secret", Claims{Towns: []string{"Newton"}, Issued: now.Unix()})
+	payload, _, _ := strings.Cut(other, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	if _, err := Parse("secret", payload+"."+sig, now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected signature mismatch for swapped claims, got %v", err)
+	}
+	if _, err := Parse("secret", token, now.Add(2*time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected expired token, got %v", err)
+	}
+	if _, err := Parse("secret", "not-a-token", now); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected malformed token, got %v", err)
+	}
+}
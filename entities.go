@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+)
+
+const (
+	defaultEntityCallsLimit = 50
+	maxEntityCallsLimit     = 500
+)
+
+type entityCallsResponse struct {
+	Key   string                  `json:"key"`
+	Names []formatting.Entity     `json:"names"`
+	Calls []transcriptionResponse `json:"calls"`
+}
+
+// storeEntitiesTx replaces the entities indexed for filename with those
+// named in text. An empty text clears them, which is what duplicates get:
+// the original call already carries the location's history.
+func storeEntitiesTx(ctx context.Context, tx *sql.Tx, filename, text string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM call_entities WHERE filename = ?`, filename); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, e := range formatting.ExtractEntities(text) {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO call_entities (filename, kind, name, entity_key, created_at) VALUES (?, ?, ?, ?, ?)`, filename, e.Kind, e.Name, e.Key, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillCallEntities indexes every completed call that is not a duplicate.
+// It runs once, from the migration that adds the table.
+func backfillCallEntities(db *sql.DB) error {
+	type pending struct{ filename, text string }
+	rows, err := db.Query(`SELECT filename, COALESCE(clean_transcript_text, transcript_text, '') FROM transcriptions WHERE status = ? AND duplicate_of IS NULL`, statusDone)
+	if err != nil {
+		return err
+	}
+	var calls []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.filename, &p.text); err != nil {
+			rows.Close()
+			return err
+		}
+		calls = append(calls, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, p := range calls {
+		if err := storeEntitiesTx(ctx, tx, p.filename, p.text); err != nil {
+			return err
+		}
+	}
+	if len(calls) > 0 {
+		log.Printf("indexed entities for %d calls", len(calls))
+	}
+	return tx.Commit()
+}
+
+// handleEntityCalls serves GET /api/entities/{name}/calls: the calls that
+// mention a street, landmark or facility, newest first. The name is folded
+// the same way transcripts are, so "main st" finds "Main Street".
+func (s *server) handleEntityCalls(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/entities/"), "/"), "/")
+	if len(parts) != 2 || parts[1] != "calls" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, err := url.PathUnescape(parts[0])
+	key := formatting.AliasKey(name)
+	if err != nil || key == "" {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	limit := defaultEntityCallsLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxEntityCallsLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxEntityCallsLimit), http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	kind := strings.TrimSpace(q.Get("kind"))
+	switch kind {
+	case "", formatting.EntityStreet, formatting.EntityLandmark, formatting.EntityFacility:
+	default:
+		http.Error(w, "kind must be street, landmark or facility", http.StatusBadRequest)
+		return
+	}
+	_, window := normalizeWindowName(q.Get("window"), "all")
+
+	entityWhere := "entity_key = ?"
+	args := []interface{}{key}
+	if kind != "" {
+		entityWhere += " AND kind = ?"
+		args = append(args, kind)
+	}
+	names, err := s.entityNames(r.Context(), entityWhere, args)
+	if err != nil {
+		log.Printf("entity lookup failed for %q: %v", key, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	clause := "WHERE deleted_at IS NULL AND filename IN (SELECT filename FROM call_entities WHERE " + entityWhere + ")"
+	if window > 0 {
+		clause += " AND effective_ts >= ?"
+		args = append(args, time.Now().UTC().Add(-window))
+	}
+	clause += " ORDER BY effective_ts DESC LIMIT ?"
+	args = append(args, limit)
+	records, err := s.store.Select(r.Context(), clause, args...)
+	if err != nil {
+		log.Printf("entity calls query failed for %q: %v", key, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	baseURL := s.resolveBaseURL(r)
+	resp := entityCallsResponse{Key: key, Names: names, Calls: []transcriptionResponse{}}
+	for _, t := range records {
+		if !s.visibleTo(r, t) {
+			continue
+		}
+		resp.Calls = append(resp.Calls, s.responseFor(r, t, baseURL))
+	}
+	respondJSON(w, resp)
+}
+
+// entityNames returns each kind and spelling the key was indexed under, most
+// common first.
+func (s *server) entityNames(ctx context.Context, where string, args []interface{}) ([]formatting.Entity, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT kind, name, entity_key FROM call_entities WHERE `+where+` GROUP BY kind, name ORDER BY COUNT(*) DESC, name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := []formatting.Entity{}
+	for rows.Next() {
+		var e formatting.Entity
+		if err := rows.Scan(&e.Kind, &e.Name, &e.Key); err != nil {
+			return nil, err
+		}
+		names = append(names, e)
+	}
+	return names, rows.Err()
+}
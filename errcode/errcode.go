@@ -0,0 +1,52 @@
+// Package errcode tags pipeline errors with a stable code so failures can be
+// counted by cause. The message stays free-form for people; the code is what
+// dashboards group on.
+package errcode
+
+import "errors"
+
+// Codes stored in transcriptions.error_code and call_errors.code.
+const (
+	OpenAIRateLimit  = "openai_rate_limit"
+	OpenAIError      = "openai_error"
+	OpenAIBudget     = "openai_budget_exceeded"
+	OpenAIDown       = "openai_unavailable"
+	FFmpegFailed     = "ffmpeg_failed"
+	FileTooLarge     = "file_too_large"
+	FileIO           = "file_io"
+	InvalidAudio     = "invalid_audio"
+	GeocodeFailed    = "geocode_failed"
+	RefineParseError = "refine_parse_error"
+	RefineFailed     = "refine_failed"
+	StoreFailed      = "store_failed"
+	Interrupted      = "interrupted"
+	Unknown          = "unknown"
+)
+
+// Error is an error carrying a code.
+type Error struct {
+	Code string
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap tags err with code. A nil err stays nil.
+func Wrap(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Of returns the code of the outermost Error in err's chain, or "" when
+// err carries none.
+func Of(err error) string {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestOfFindsWrappedCode(t *testing.T) {
+	base := errors.New("ffmpeg exited 1")
+	err := fmt.Errorf("chunk 2: %w", Wrap(FFmpegFailed, base))
+	if got := Of(err); got != FFmpegFailed {
+		t.Fatalf("Of = %q, want %q", got, FFmpegFailed)
+	}
+	if !errors.Is(err, base) {
+		t.Fatal("wrapped error should still match its cause")
+	}
+	if err.Error() != "chunk 2: ffmpeg exited 1" {
+		t.Fatalf("message changed: %q", err.Error())
+	}
+}
+
+func TestOfWithoutCode(t *testing.T) {
+	if got := Of(errors.New("plain")); got != "" {
+		t.Fatalf("Of = %q, want empty", got)
+	}
+	if Wrap(Unknown, nil) != nil {
+		t.Fatal("Wrap(nil) should be nil")
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"alert_framework/webhook"
+)
+
+const escalationCheckInterval = 30 * time.Second
+
+// escalates reports whether a call of this category or type must be
+// acknowledged. ESCALATION_CATEGORIES entries match the call category (fire,
+// ems, other) or the exact call type.
+func (s *server) escalates(category, callType string) bool {
+	for _, c := range s.liveConfig().EscalationCategories {
+		if strings.EqualFold(c, category) || strings.EqualFold(c, strings.TrimSpace(callType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// armEscalation starts the acknowledgement clock for an alerted call. Only the
+// first alert arms it; updated re-posts keep the original deadline.
+func (s *server) armEscalation(filename, category, callType, alertText string) {
+	if !s.escalates(category, callType) {
+		return
+	}
+	now := time.Now().UTC()
+	due := now.Add(time.Duration(s.liveConfig().EscalationAfterMin) * time.Minute)
+	if _, err := execWithRetry(s.db, `INSERT OR IGNORE INTO incident_escalations (filename, call_category, call_type, alert_text, alerted_at, due_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		filename, category, callType, alertText, now, due); err != nil {
+		log.Printf("arm escalation for %s failed: %v", filename, err)
+	}
+}
+
+// startEscalationScheduler escalates armed calls nobody acknowledged in time.
+// It runs even with escalation off so a reload can turn it on.
+func (s *server) startEscalationScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(escalationCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+			if len(s.liveConfig().EscalationCategories) == 0 {
+				continue
+			}
+			s.escalateOverdue(ctx)
+		}
+	}()
+}
+
+type overdueIncident struct {
+	filename  string
+	category  string
+	callType  string
+	alertText string
+	alertedAt time.Time
+	dueAt     time.Time
+}
+
+func (s *server) escalateOverdue(ctx context.Context) {
+	rows, err := s.db.QueryContext(ctx, `SELECT e.filename, e.call_category, e.call_type, e.alert_text, e.alerted_at, e.due_at FROM incident_escalations e
+LEFT JOIN acknowledgements a ON a.target_type = 'call' AND a.target_id = e.filename
+WHERE a.target_id IS NULL AND e.escalated_at IS NULL AND e.due_at <= ?
+AND NOT EXISTS (SELECT 1 FROM transcriptions d WHERE d.filename = e.filename AND d.deleted_at IS NOT NULL)
+AND NOT EXISTS (SELECT 1 FROM transcriptions t
+    JOIN rollup_calls rc ON rc.call_id = t.id
+    JOIN acknowledgements ra ON ra.target_type = 'rollup' AND ra.target_id = CAST(rc.rollup_id AS TEXT)
+    WHERE t.filename = e.filename)`, time.Now().UTC())
+	if err != nil {
+		log.Printf("escalation query failed: %v", err)
+		return
+	}
+	var overdue []overdueIncident
+	for rows.Next() {
+		var inc overdueIncident
+		if err := rows.Scan(&inc.filename, &inc.category, &inc.callType, &inc.alertText, &inc.alertedAt, &inc.dueAt); err != nil {
+			log.Printf("escalation scan failed: %v", err)
+			continue
+		}
+		overdue = append(overdue, inc)
+	}
+	rows.Close()
+
+	for _, inc := range overdue {
+		// Claim the row first so a slow target cannot cause a second escalation.
+		res, err := execWithRetry(s.db, `UPDATE incident_escalations SET escalated_at = ? WHERE filename = ? AND escalated_at IS NULL`, time.Now().UTC(), inc.filename)
+		if err != nil {
+			log.Printf("escalation claim for %s failed: %v", inc.filename, err)
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		errText := s.sendEscalation(inc)
+		if errText != "" {
+			log.Printf("escalation for %s: %s", inc.filename, errText)
+			execWithRetry(s.db, `UPDATE incident_escalations SET escalation_error = ? WHERE filename = ?`, errText, inc.filename)
+		} else {
+			log.Printf("escalated unacknowledged call %s", inc.filename)
+		}
+	}
+}
+
+// sendEscalation notifies the escalation GroupMe bot and webhook. It returns
+// the failures, if any, as one string.
+func (s *server) sendEscalation(inc overdueIncident) string {
+	var failures []string
+	cfg := s.liveConfig()
+	minutes := int(inc.dueAt.Sub(inc.alertedAt).Minutes())
+	if botID := cfg.EscalationGroupMeBotID; botID != "" {
+		text := fmt.Sprintf("⏰ Not acknowledged after %d min\n\n%s", minutes, inc.alertText)
+		if err := s.postGroupMe(botID, text); err != nil {
+			failures = append(failures, "groupme: "+err.Error())
+		}
+	}
+	if endpoint := cfg.EscalationWebhookURL; endpoint != "" {
+		body, _ := json.Marshal(map[string]interface{}{
+			"event":         "incident.escalated",
+			"filename":      inc.filename,
+			"call_category": inc.category,
+			"call_type":     inc.callType,
+			"alerted_at":    inc.alertedAt.UTC().Format(time.RFC3339),
+			"due_at":        inc.dueAt.UTC().Format(time.RFC3339),
+			"alert_message": inc.alertText,
+			"ack_path":      "/api/incident/" + url.PathEscape(inc.filename) + "/ack",
+		})
+		if err := s.postEscalationWebhook(endpoint, body); err != nil {
+			failures = append(failures, "webhook: "+err.Error())
+		}
+	}
+	return strings.Join(failures, "; ")
+}
+
+func (s *server) postEscalationWebhook(endpoint string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := s.liveConfig().EscalationWebhookSecret; secret != "" {
+		webhook.SetHeaders(req.Header, secret, time.Now(), body)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
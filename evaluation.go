@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+	"alert_framework/prompts"
+)
+
+const (
+	evalRunRunning     = "running"
+	evalRunDone        = "done"
+	evalRunFailed      = "failed"
+	evalRunInterrupted = "interrupted"
+
+	maxEvalUploadBytes = 64 << 20
+	evalRunListLimit   = 50
+)
+
+// evalCase is one labeled recording: audio plus the transcript and address a
+// correct pipeline run should produce.
+type evalCase struct {
+	ID                 int64     `json:"id"`
+	Name               string    `json:"name"`
+	CallFilename       string    `json:"call_filename"`
+	ExpectedTranscript string    `json:"expected_transcript"`
+	ExpectedAddress    string    `json:"expected_address,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	audioPath          string
+}
+
+// evalRun is one pass of the pipeline over the labeled set with a fixed
+// transcription model and prompt versions.
+type evalRun struct {
+	ID                    int64        `json:"id"`
+	Status                string       `json:"status"`
+	Model                 string       `json:"model"`
+	CleanupPromptVersion  string       `json:"cleanup_prompt_version"`
+	MetadataPromptVersion string       `json:"metadata_prompt_version"`
+	Cases                 int          `json:"cases"`
+	Completed             int          `json:"completed"`
+	MeanWER               *float64     `json:"mean_wer,omitempty"`
+	MeanRawWER            *float64     `json:"mean_raw_wer,omitempty"`
+	AddressAccuracy       *float64     `json:"address_accuracy,omitempty"`
+	Error                 string       `json:"error,omitempty"`
+	StartedAt             time.Time    `json:"started_at"`
+	FinishedAt            *time.Time   `json:"finished_at,omitempty"`
+	Results               []evalResult `json:"results,omitempty"`
+}
+
+// evalResult scores one case within a run. WER compares the final cleaned
+// transcript with the label, RawWER the raw model output.
+type evalResult struct {
+	CaseID       int64   `json:"case_id"`
+	Name         string  `json:"name,omitempty"`
+	Transcript   string  `json:"transcript"`
+	Address      string  `json:"address,omitempty"`
+	WER          float64 `json:"wer"`
+	RawWER       float64 `json:"raw_wer"`
+	AddressMatch *bool   `json:"address_match,omitempty"`
+	Error        string  `json:"error,omitempty"`
+}
+
+type evalCaseRequest struct {
+	Name       string `json:"name"`
+	Filename   string `json:"filename"`
+	Transcript string `json:"transcript"`
+	Address    string `json:"address"`
+}
+
+type evalRunRequest struct {
+	Model                 string  `json:"model"`
+	Format                string  `json:"format"`
+	CleanupPromptVersion  string  `json:"cleanup_prompt_version"`
+	MetadataPromptVersion string  `json:"metadata_prompt_version"`
+	CaseIDs               []int64 `json:"case_ids"`
+}
+
+func (s *server) evalDir() string {
+	return filepath.Join(s.cfg.WorkDir, "eval")
+}
+
+// handleEvalCases lists labeled cases (GET) or adds one (POST). A case is
+// either uploaded as multipart (audio, transcript, address, name,
+// call_filename) or, as JSON, labels an existing call in CALLS_DIR by
+// filename.
+func (s *server) handleEvalCases(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		cases, err := s.loadEvalCases(r.Context(), nil)
+		if err != nil {
+			log.Printf("eval case list failed: %v", err)
+			http.Error(w, "error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"cases": cases})
+	case http.MethodPost:
+		c, status, err := s.createEvalCase(r)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		respondJSON(w, c)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleEvalCaseDetail deletes a labeled case and its audio.
+func (s *server) handleEvalCaseDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/eval/cases/"), "/"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	cases, err := s.loadEvalCases(r.Context(), []int64{id})
+	if err != nil {
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	if len(cases) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := execWithRetry(s.db, `DELETE FROM eval_cases WHERE id = ?`, id); err != nil {
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	os.Remove(cases[0].audioPath)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) createEvalCase(r *http.Request) (evalCase, int, error) {
+	var req evalCaseRequest
+	var src io.Reader
+	var srcName string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxEvalUploadBytes); err != nil {
+			return evalCase{}, http.StatusBadRequest, errors.New("invalid multipart form")
+		}
+		file, header, err := r.FormFile("audio")
+		if err != nil {
+			return evalCase{}, http.StatusBadRequest, errors.New("audio file required")
+		}
+		defer file.Close()
+		src, srcName = file, filepath.Base(header.Filename)
+		req = evalCaseRequest{
+			Name:       r.FormValue("name"),
+			Filename:   r.FormValue("call_filename"),
+			Transcript: r.FormValue("transcript"),
+			Address:    r.FormValue("address"),
+		}
+		if strings.TrimSpace(req.Filename) == "" {
+			req.Filename = srcName
+		}
+	} else {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return evalCase{}, http.StatusBadRequest, errors.New("invalid json")
+		}
+		req.Filename = filepath.Base(strings.TrimSpace(req.Filename))
+		file, err := os.Open(filepath.Join(s.cfg.CallsDir, req.Filename))
+		if err != nil {
+			return evalCase{}, http.StatusNotFound, errors.New("call not found")
+		}
+		defer file.Close()
+		src, srcName = file, req.Filename
+	}
+	req.Filename = filepath.Base(strings.TrimSpace(req.Filename))
+	req.Transcript = strings.TrimSpace(req.Transcript)
+	if req.Transcript == "" {
+		return evalCase{}, http.StatusBadRequest, errors.New("transcript required")
+	}
+	if !isAudioFilename(srcName) {
+		return evalCase{}, http.StatusBadRequest, errors.New("unsupported audio type")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		req.Name = req.Filename
+	}
+
+	res, err := execWithRetry(s.db, `INSERT INTO eval_cases (name, call_filename, expected_transcript, expected_address, audio_path, created_at) VALUES (?, ?, ?, ?, '', ?)`,
+		strings.TrimSpace(req.Name), req.Filename, req.Transcript, strings.TrimSpace(req.Address), time.Now().UTC())
+	if err != nil {
+		return evalCase{}, http.StatusInternalServerError, err
+	}
+	id, _ := res.LastInsertId()
+	audioPath := filepath.Join(s.evalDir(), fmt.Sprintf("case-%d%s", id, strings.ToLower(filepath.Ext(srcName))))
+	if err := writeEvalAudio(audioPath, src); err != nil {
+		execWithRetry(s.db, `DELETE FROM eval_cases WHERE id = ?`, id)
+		return evalCase{}, http.StatusInternalServerError, err
+	}
+	if _, err := execWithRetry(s.db, `UPDATE eval_cases SET audio_path = ? WHERE id = ?`, audioPath, id); err != nil {
+		return evalCase{}, http.StatusInternalServerError, err
+	}
+	cases, err := s.loadEvalCases(r.Context(), []int64{id})
+	if err != nil || len(cases) == 0 {
+		return evalCase{}, http.StatusInternalServerError, errors.New("case not saved")
+	}
+	return cases[0], http.StatusCreated, nil
+}
+
+func writeEvalAudio(path string, src io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(path)
+		return err
+	}
+	return out.Close()
+}
+
+// loadEvalCases returns the given cases, or all of them when ids is empty.
+func (s *server) loadEvalCases(ctx context.Context, ids []int64) ([]evalCase, error) {
+	query := `SELECT id, name, call_filename, expected_transcript, COALESCE(expected_address, ''), audio_path, created_at FROM eval_cases`
+	var args []interface{}
+	if len(ids) > 0 {
+		query += ` WHERE id IN (?` + strings.Repeat(",?", len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cases := []evalCase{}
+	for rows.Next() {
+		var c evalCase
+		if err := rows.Scan(&c.ID, &c.Name, &c.CallFilename, &c.ExpectedTranscript, &c.ExpectedAddress, &c.audioPath, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, rows.Err()
+}
+
+// handleEvalRuns lists past runs (GET) or starts a new one in the background
+// (POST). Only one run executes at a time.
+func (s *server) handleEvalRuns(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		runs, err := s.loadEvalRuns(r.Context(), 0)
+		if err != nil {
+			log.Printf("eval run list failed: %v", err)
+			http.Error(w, "error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"runs": runs})
+	case http.MethodPost:
+		s.startEvalRun(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleEvalRunDetail returns a run with its per-case results.
+func (s *server) handleEvalRunDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/eval/runs/"), "/"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	runs, err := s.loadEvalRuns(r.Context(), id)
+	if err != nil {
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	if len(runs) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	run := runs[0]
+	if run.Results, err = s.loadEvalResults(r.Context(), id); err != nil {
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, run)
+}
+
+func (s *server) startEvalRun(w http.ResponseWriter, r *http.Request) {
+	if s.queue == nil {
+		http.Error(w, "worker disabled", http.StatusServiceUnavailable)
+		return
+	}
+	var req evalRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	cases, err := s.loadEvalCases(r.Context(), req.CaseIDs)
+	if err != nil {
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	if len(cases) == 0 {
+		http.Error(w, "no eval cases", http.StatusBadRequest)
+		return
+	}
+	pins := make(map[string]prompts.Assignment)
+	for kind, version := range map[string]string{prompts.KindCleanup: req.CleanupPromptVersion, prompts.KindMetadata: req.MetadataPromptVersion} {
+		a, err := s.evalPromptPin(r.Context(), kind, strings.TrimSpace(version))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pins[kind] = a
+	}
+	opts, _ := s.defaultOptions()
+	if m := strings.TrimSpace(req.Model); m != "" {
+		opts.Model = m
+	}
+	if f := strings.TrimSpace(req.Format); f != "" {
+		opts.Format = f
+	}
+
+	if !s.evalMu.TryLock() {
+		http.Error(w, "an eval run is already in progress", http.StatusConflict)
+		return
+	}
+	res, err := execWithRetry(s.db, `INSERT INTO eval_runs (status, model, cleanup_prompt_version, metadata_prompt_version, cases, started_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		evalRunRunning, opts.Model, pins[prompts.KindCleanup].Version, pins[prompts.KindMetadata].Version, len(cases), time.Now().UTC())
+	if err != nil {
+		s.evalMu.Unlock()
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	runID, _ := res.LastInsertId()
+	go func() {
+		defer s.evalMu.Unlock()
+		s.executeEvalRun(s.ctx, runID, cases, opts, pins)
+	}()
+
+	runs, err := s.loadEvalRuns(r.Context(), runID)
+	if err != nil || len(runs) == 0 {
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	respondJSON(w, runs[0])
+}
+
+// evalPromptPin resolves the prompt an eval run uses for kind. An empty
+// version means the baseline prompt saved in settings.
+func (s *server) evalPromptPin(ctx context.Context, kind, version string) (prompts.Assignment, error) {
+	if version == "" || version == prompts.BaselineVersion || s.prompts == nil {
+		return prompts.Assignment{Kind: kind, Version: prompts.BaselineVersion}, nil
+	}
+	versions, err := s.prompts.List(ctx, kind)
+	if err != nil {
+		return prompts.Assignment{}, err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return prompts.Assignment{Kind: kind, Version: v.Version, Prompt: v.Prompt}, nil
+		}
+	}
+	return prompts.Assignment{}, fmt.Errorf("unknown %s prompt version %q", kind, version)
+}
+
+// pinnedPrompt returns the prompt an eval or shadow run fixed for this call,
+// if any.
+func (s *server) pinnedPrompt(kind, filename string) (prompts.Assignment, bool) {
+	pins, ok := s.evalPrompts.Load(filename)
+	if !ok {
+		return prompts.Assignment{}, false
+	}
+	a, ok := pins.(map[string]prompts.Assignment)[kind]
+	return a, ok
+}
+
+func (s *server) executeEvalRun(ctx context.Context, runID int64, cases []evalCase, opts TranscriptionOptions, pins map[string]prompts.Assignment) {
+	log.Printf("eval run %d started: %d cases, model %s", runID, len(cases), opts.Model)
+	var werSum, rawSum float64
+	var scored, addressed, matched int
+	for i, c := range cases {
+		if ctx.Err() != nil {
+			s.finishEvalRun(runID, evalRunInterrupted, ctx.Err().Error(), nil, nil, nil)
+			return
+		}
+		res := s.runEvalCase(ctx, runID, c, opts, pins)
+		if _, err := execWithRetry(s.db, `INSERT INTO eval_results (run_id, case_id, transcript, address, wer, raw_wer, address_match, error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			runID, c.ID, res.Transcript, res.Address, res.WER, res.RawWER, res.AddressMatch, res.Error, time.Now().UTC()); err != nil {
+			log.Printf("eval run %d: store result for case %d: %v", runID, c.ID, err)
+		}
+		execWithRetry(s.db, `UPDATE eval_runs SET completed = ? WHERE id = ?`, i+1, runID)
+		if res.Error != "" {
+			continue
+		}
+		scored++
+		werSum += res.WER
+		rawSum += res.RawWER
+		if res.AddressMatch != nil {
+			addressed++
+			if *res.AddressMatch {
+				matched++
+			}
+		}
+	}
+	if scored == 0 {
+		s.finishEvalRun(runID, evalRunFailed, "every case failed", nil, nil, nil)
+		return
+	}
+	meanWER, meanRaw := werSum/float64(scored), rawSum/float64(scored)
+	var accuracy *float64
+	if addressed > 0 {
+		v := float64(matched) / float64(addressed)
+		accuracy = &v
+	}
+	s.finishEvalRun(runID, evalRunDone, "", &meanWER, &meanRaw, accuracy)
+	log.Printf("eval run %d done: %d/%d cases scored, mean WER %.3f", runID, scored, len(cases), meanWER)
+}
+
+// runEvalCase sends one labeled recording through preprocessing,
+// transcription, enrichment and location resolution, as processFile would,
+// without touching the transcriptions table or sending alerts.
+func (s *server) runEvalCase(ctx context.Context, runID int64, c evalCase, opts TranscriptionOptions, pins map[string]prompts.Assignment) evalResult {
+	res := evalResult{CaseID: c.ID, Name: c.Name}
+	key := fmt.Sprintf("eval-%d-%d%s", runID, c.ID, filepath.Ext(c.audioPath))
+	s.evalPrompts.Store(key, pins)
+	defer s.evalPrompts.Delete(key)
+	defer s.locationCache.Delete(key)
+
+	staged := filepath.Join(s.cfg.WorkDir, key)
+	s.workFiles.acquire(staged)
+	defer s.workFiles.release(staged)
+	if err := copyFile(c.audioPath, staged); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer os.Remove(staged)
+	processed, err := ProcessAudioWithFFmpeg(ctx, staged)
+	if err != nil {
+		processed = staged
+	} else if processed != staged {
+		defer os.Remove(processed)
+	}
+
+	meta, _ := s.parseCallMetadata(c.CallFilename, "")
+	artifacts, err := s.multiPassTranscription(key, processed, opts, meta)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Transcript = artifacts.CleanTranscript
+	res.WER = formatting.WordErrorRate(c.ExpectedTranscript, artifacts.CleanTranscript)
+	res.RawWER = formatting.WordErrorRate(c.ExpectedTranscript, artifacts.RawTranscript)
+
+	normalized := artifacts.NormalizedText
+	if normalized == nil || strings.TrimSpace(*normalized) == "" {
+		fallback := s.normalizeTranscript(artifacts.CleanTranscript, meta)
+		normalized = &fallback
+	}
+	candidate := transcription{
+		Filename:             key,
+		NormalizedTranscript: normalized,
+		CleanTranscript:      &artifacts.CleanTranscript,
+		RawTranscript:        &artifacts.RawTranscript,
+		RecognizedTowns:      artifacts.RecognizedTowns,
+		CallType:             artifacts.CallType,
+	}
+	if loc, _ := s.resolveCallLocation(ctx, candidate, meta, parseRecognizedTowns(artifacts.RecognizedTowns)); loc != nil {
+		res.Address = loc.Label
+	}
+	if c.ExpectedAddress != "" {
+		match := formatting.AddressMatches(c.ExpectedAddress, res.Address)
+		res.AddressMatch = &match
+	}
+	return res
+}
+
+func (s *server) finishEvalRun(runID int64, status, errText string, meanWER, meanRaw, accuracy *float64) {
+	if _, err := execWithRetry(s.db, `UPDATE eval_runs SET status = ?, error = ?, mean_wer = ?, mean_raw_wer = ?, address_accuracy = ?, finished_at = ? WHERE id = ?`,
+		status, errText, meanWER, meanRaw, accuracy, time.Now().UTC(), runID); err != nil {
+		log.Printf("eval run %d: finish: %v", runID, err)
+	}
+}
+
+// interruptEvalRuns marks runs a previous process left running; their
+// goroutines died with it.
+func (s *server) interruptEvalRuns() {
+	if _, err := execWithRetry(s.db, `UPDATE eval_runs SET status = ?, error = 'server restarted', finished_at = ? WHERE status = ?`, evalRunInterrupted, time.Now().UTC(), evalRunRunning); err != nil {
+		log.Printf("eval run cleanup failed: %v", err)
+	}
+}
+
+// loadEvalRuns returns the run with the given id, or the most recent runs
+// when id is 0.
+func (s *server) loadEvalRuns(ctx context.Context, id int64) ([]evalRun, error) {
+	query := `SELECT id, status, model, cleanup_prompt_version, metadata_prompt_version, cases, completed, mean_wer, mean_raw_wer, address_accuracy, COALESCE(error, ''), started_at, finished_at FROM eval_runs`
+	args := []interface{}{}
+	if id > 0 {
+		query += ` WHERE id = ?`
+		args = append(args, id)
+	}
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT %d`, evalRunListLimit)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := []evalRun{}
+	for rows.Next() {
+		var run evalRun
+		var meanWER, meanRaw, accuracy sql.NullFloat64
+		var finished sql.NullTime
+		if err := rows.Scan(&run.ID, &run.Status, &run.Model, &run.CleanupPromptVersion, &run.MetadataPromptVersion, &run.Cases, &run.Completed, &meanWER, &meanRaw, &accuracy, &run.Error, &run.StartedAt, &finished); err != nil {
+			return nil, err
+		}
+		run.MeanWER = nullFloatPtr(meanWER)
+		run.MeanRawWER = nullFloatPtr(meanRaw)
+		run.AddressAccuracy = nullFloatPtr(accuracy)
+		if finished.Valid {
+			t := finished.Time
+			run.FinishedAt = &t
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func (s *server) loadEvalResults(ctx context.Context, runID int64) ([]evalResult, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT r.case_id, COALESCE(c.name, ''), r.transcript, r.address, r.wer, r.raw_wer, r.address_match, COALESCE(r.error, '') FROM eval_results r LEFT JOIN eval_cases c ON c.id = r.case_id WHERE r.run_id = ? ORDER BY r.id`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []evalResult{}
+	for rows.Next() {
+		var res evalResult
+		var match sql.NullBool
+		if err := rows.Scan(&res.CaseID, &res.Name, &res.Transcript, &res.Address, &res.WER, &res.RawWER, &match, &res.Error); err != nil {
+			return nil, err
+		}
+		if match.Valid {
+			v := match.Bool
+			res.AddressMatch = &v
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+func nullFloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	f := v.Float64
+	return &f
+}
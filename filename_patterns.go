@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert_framework/config"
+	"alert_framework/formatting"
+)
+
+// setFilenamePatterns swaps in a parser for patterns. Patterns that fail to
+// compile leave the previous parser in place.
+func (s *server) setFilenamePatterns(patterns []config.FilenamePattern) {
+	parser, err := formatting.NewFilenameParser(patterns)
+	if err != nil {
+		log.Printf("filename patterns not applied: %v", err)
+		return
+	}
+	s.filenames.Store(parser)
+}
+
+// parseCallMetadata parses a recording's filename with the configured
+// patterns for source, or the built-in parser. An empty source tries every
+// pattern.
+func (s *server) parseCallMetadata(filename, source string) (formatting.CallMetadata, error) {
+	meta, _, err := s.filenames.Load().Parse(filename, source, s.tz)
+	return meta, err
+}
+
+type filenameParseRequest struct {
+	Filename string                    `json:"filename"`
+	Source   string                    `json:"source"`
+	Patterns *[]config.FilenamePattern `json:"patterns"`
+}
+
+type filenameParseResponse struct {
+	Filename  string                    `json:"filename"`
+	Source    string                    `json:"source,omitempty"`
+	Pattern   string                    `json:"pattern,omitempty"`
+	Agency    string                    `json:"agency,omitempty"`
+	Town      string                    `json:"town,omitempty"`
+	CallType  string                    `json:"call_type,omitempty"`
+	Timestamp *time.Time                `json:"timestamp,omitempty"`
+	Talkgroup string                    `json:"talkgroup,omitempty"`
+	Error     string                    `json:"error,omitempty"`
+	Trials    []formatting.PatternTrial `json:"trials"`
+}
+
+// handleFilenameParse shows how a sample filename would be parsed: GET with
+// ?filename=&source=, or POST {"filename", "source", "patterns"} to try a
+// draft pattern list instead of FILENAME_PATTERNS. Nothing is stored.
+func (s *server) handleFilenameParse(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req filenameParseRequest
+	switch r.Method {
+	case http.MethodGet:
+		req.Filename = r.URL.Query().Get("filename")
+		req.Source = r.URL.Query().Get("source")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req.Filename = strings.TrimSpace(req.Filename)
+	if req.Filename == "" {
+		http.Error(w, "filename is required", http.StatusBadRequest)
+		return
+	}
+	parser := s.filenames.Load()
+	if req.Patterns != nil {
+		var err error
+		if parser, err = formatting.NewFilenameParser(*req.Patterns); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	meta, pattern, trials, err := parser.Explain(req.Filename, strings.TrimSpace(req.Source), s.tz)
+	resp := filenameParseResponse{Filename: req.Filename, Source: req.Source, Trials: trials}
+	if resp.Trials == nil {
+		resp.Trials = []formatting.PatternTrial{}
+	}
+	if err != nil {
+		resp.Error = err.Error()
+		respondJSON(w, resp)
+		return
+	}
+	resp.Pattern = pattern
+	resp.Agency = meta.AgencyDisplay
+	resp.Town = meta.TownDisplay
+	resp.CallType = meta.CallType
+	resp.Timestamp = &meta.DateTime
+	resp.Talkgroup = strings.TrimSpace(meta.AgencyDisplay + " " + meta.CallType)
+	respondJSON(w, resp)
+}
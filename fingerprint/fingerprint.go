@@ -0,0 +1,194 @@
+// Package fingerprint computes compact acoustic fingerprints so the same
+// transmission captured by two receivers can be matched even though the files
+// differ byte for byte. The scheme follows the Haitsma–Kalker / chromaprint
+// idea: per frame, band energies across the voice range are reduced to 32
+// bits recording whether the energy difference between adjacent bands grew or
+// shrank since the previous frame. Those bits survive gain changes, resampling
+// and moderate noise, so near-duplicates differ in only a few bits per frame.
+package fingerprint
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+const (
+	// SampleRate is the mono sample rate Compute expects.
+	SampleRate = 11025
+	// MaxSeconds caps how much audio is fingerprinted; longer calls are
+	// matched on their opening.
+	MaxSeconds = 60
+
+	frameSize = 2048
+	hopSize   = 256
+	bands     = 33
+	minFreq   = 300.0
+	maxFreq   = 3000.0
+)
+
+// FrameSeconds is the time step between fingerprint frames.
+const FrameSeconds = float64(hopSize) / SampleRate
+
+var (
+	bandEdges = bandBins()
+	window    = hannWindow()
+)
+
+// Compute fingerprints mono samples at SampleRate. It returns one 32-bit word
+// per hop, or nil when the audio is shorter than two frames.
+func Compute(samples []int16) []uint32 {
+	if limit := MaxSeconds * SampleRate; len(samples) > limit {
+		samples = samples[:limit]
+	}
+	if len(samples) < frameSize+hopSize {
+		return nil
+	}
+	frames := (len(samples)-frameSize)/hopSize + 1
+	out := make([]uint32, 0, frames-1)
+	prev := make([]float64, bands)
+	cur := make([]float64, bands)
+	buf := make([]complex128, frameSize)
+	for f := 0; f < frames; f++ {
+		start := f * hopSize
+		for i := range buf {
+			buf[i] = complex(float64(samples[start+i])*window[i], 0)
+		}
+		fft(buf)
+		for b := range cur {
+			var energy float64
+			for k := bandEdges[b]; k < bandEdges[b+1]; k++ {
+				re, im := real(buf[k]), imag(buf[k])
+				energy += re*re + im*im
+			}
+			cur[b] = math.Log1p(energy)
+		}
+		if f > 0 {
+			var word uint32
+			for b := 0; b < bands-1; b++ {
+				if (cur[b]-cur[b+1])-(prev[b]-prev[b+1]) > 0 {
+					word |= 1 << uint(b)
+				}
+			}
+			out = append(out, word)
+		}
+		prev, cur = cur, prev
+	}
+	return out
+}
+
+// Similarity returns the best fraction of matching bits between a and b over
+// alignments shifted by up to maxOffset frames either way. Unrelated audio
+// scores around 0.5; copies of the same transmission typically score 0.8 or
+// more. Alignments that overlap less than half of the shorter fingerprint are
+// ignored, so a short clip does not match any call that merely contains it.
+func Similarity(a, b []uint32, maxOffset int) float64 {
+	shorter := len(a)
+	if len(b) < shorter {
+		shorter = len(b)
+	}
+	minOverlap := shorter / 2
+	if minOverlap < 10 {
+		minOverlap = 10
+	}
+	best := 0.0
+	for offset := -maxOffset; offset <= maxOffset; offset++ {
+		var diff, overlap int
+		for i := range a {
+			j := i + offset
+			if j < 0 || j >= len(b) {
+				continue
+			}
+			diff += bits.OnesCount32(a[i] ^ b[j])
+			overlap++
+		}
+		if overlap < minOverlap {
+			continue
+		}
+		if score := 1 - float64(diff)/float64(32*overlap); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// Encode packs a fingerprint for storage.
+func Encode(fp []uint32) string {
+	buf := make([]byte, 4*len(fp))
+	for i, word := range fp {
+		binary.LittleEndian.PutUint32(buf[4*i:], word)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// Decode reverses Encode.
+func Decode(s string) ([]uint32, error) {
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)%4 != 0 {
+		return nil, errors.New("fingerprint: truncated data")
+	}
+	fp := make([]uint32, len(buf)/4)
+	for i := range fp {
+		fp[i] = binary.LittleEndian.Uint32(buf[4*i:])
+	}
+	return fp, nil
+}
+
+// bandBins returns the FFT bin boundaries of the bands, spaced
+// logarithmically across the voice range radio traffic occupies. Band b
+// covers bins [edges[b], edges[b+1]).
+func bandBins() []int {
+	edges := make([]int, bands+1)
+	ratio := math.Pow(maxFreq/minFreq, 1/float64(bands))
+	freq := minFreq
+	for b := range edges {
+		edges[b] = int(math.Round(freq * frameSize / SampleRate))
+		if b > 0 && edges[b] <= edges[b-1] {
+			edges[b] = edges[b-1] + 1
+		}
+		freq *= ratio
+	}
+	return edges
+}
+
+// fft is an in-place iterative radix-2 transform; len(buf) must be a power
+// of two.
+func fft(buf []complex128) {
+	n := len(buf)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			buf[i], buf[j] = buf[j], buf[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := buf[start+k], w*buf[start+k+size/2]
+				buf[start+k] = even + odd
+				buf[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
+
+func hannWindow() []float64 {
+	w := make([]float64, frameSize)
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameSize-1))
+	}
+	return w
+}
@@ -0,0 +1,66 @@
+package fingerprint
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// synthCall builds ten seconds of voice-like audio: a harmonic series whose
+// pitch and formant change every 100ms.
+func synthCall(seed int64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]float64, 10*SampleRate)
+	step := SampleRate / 10
+	for start := 0; start < len(out); start += step {
+		f0 := 100 + rng.Float64()*150
+		formant := 500 + rng.Float64()*2000
+		amp := 500 + rng.Float64()*1500
+		for i := start; i < start+step && i < len(out); i++ {
+			t := float64(i) / SampleRate
+			for h := f0; h < 3400; h += f0 {
+				weight := 1 / (1 + math.Abs(h-formant)/300)
+				out[i] += amp * weight * math.Sin(2*math.Pi*h*t)
+			}
+		}
+	}
+	return out
+}
+
+func toPCM(signal []float64, gain float64, shift int, noise float64, seed int64) []int16 {
+	rng := rand.New(rand.NewSource(seed))
+	out := make([]int16, 0, len(signal))
+	for i := shift; i < len(signal); i++ {
+		v := signal[i]*gain + (rng.Float64()*2-1)*noise
+		out = append(out, int16(math.Max(-32768, math.Min(32767, v))))
+	}
+	return out
+}
+
+func TestSimilarityMatchesSimulcastCopies(t *testing.T) {
+	call := synthCall(1)
+	original := Compute(toPCM(call, 1, 0, 0, 0))
+	// A second receiver: quieter, noisier, and started recording 300 samples late.
+	simulcast := Compute(toPCM(call, 0.6, 300, 800, 2))
+	other := Compute(toPCM(synthCall(3), 1, 0, 0, 0))
+
+	if got := Similarity(original, simulcast, 20); got < 0.8 {
+		t.Fatalf("expected copy to match, similarity %.3f", got)
+	}
+	if got := Similarity(original, other, 20); got > 0.65 {
+		t.Fatalf("expected unrelated call not to match, similarity %.3f", got)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	fp := []uint32{0, 1, 0xdeadbeef, math.MaxUint32}
+	got, err := Decode(Encode(fp))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for i := range fp {
+		if got[i] != fp[i] {
+			t.Fatalf("word %d: got %x want %x", i, got[i], fp[i])
+		}
+	}
+}
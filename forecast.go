@@ -0,0 +1,217 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultForecastWeeks = 12
+	maxForecastWeeks     = 104
+	// A town needs this many weeks of history and calls before its forecast is
+	// worth staffing against.
+	minForecastWeeks = 4
+	minForecastCalls = 20
+	// forecastZ gives a 90% band under a normal approximation.
+	forecastZ          = 1.645
+	forecastConfidence = 0.9
+)
+
+type forecastHour struct {
+	Weekday    string  `json:"weekday"`
+	Hour       int     `json:"hour"`
+	HourOfWeek int     `json:"hour_of_week"`
+	Expected   float64 `json:"expected"`
+	Low        float64 `json:"low"`
+	High       float64 `json:"high"`
+}
+
+type townForecast struct {
+	Town          string         `json:"town"`
+	TotalCalls    int            `json:"total_calls"`
+	WeeksObserved int            `json:"weeks_observed"`
+	Sufficient    bool           `json:"sufficient"`
+	Flags         []string       `json:"flags,omitempty"`
+	Hours         []forecastHour `json:"hours"`
+}
+
+type forecastResponse struct {
+	From           string         `json:"from"`
+	To             string         `json:"to"`
+	WeeksRequested int            `json:"weeks_requested"`
+	WeeksObserved  int            `json:"weeks_observed"`
+	Confidence     float64        `json:"confidence"`
+	Towns          []townForecast `json:"towns"`
+}
+
+// handleForecast serves /api/stats/forecast: the expected number of calls in
+// each hour of the week per town, averaged over the last complete weeks of
+// stats_hourly, with a band of forecastZ standard deviations.
+func (s *server) handleForecast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	weeks := defaultForecastWeeks
+	if raw := strings.TrimSpace(r.URL.Query().Get("weeks")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxForecastWeeks {
+			http.Error(w, "weeks must be between 1 and 104", http.StatusBadRequest)
+			return
+		}
+		weeks = v
+	}
+	townFilter := strings.TrimSpace(r.URL.Query().Get("town"))
+
+	now := time.Now().In(s.tz)
+	end := time.Date(now.Year(), now.Month(), now.Day()-int(now.Weekday()), 0, 0, 0, 0, s.tz)
+	start := end.AddDate(0, 0, -7*weeks)
+	// Weeks before the first aggregated day have no data, not zero calls.
+	var first sql.NullString
+	if err := s.db.QueryRowContext(r.Context(), `SELECT MIN(day) FROM stats_hourly`).Scan(&first); err != nil {
+		log.Printf("forecast history query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	observed := 0
+	if first.Valid {
+		if day, err := time.ParseInLocation(trendDayLayout, first.String, s.tz); err == nil {
+			if day.Weekday() != time.Sunday {
+				day = day.AddDate(0, 0, 7-int(day.Weekday()))
+			}
+			if day.After(start) {
+				start = day
+			}
+			if start.Before(end) {
+				observed = int(math.Round(end.Sub(start).Hours() / (24 * 7)))
+			}
+		}
+	}
+	resp := forecastResponse{
+		From:           start.Format(trendDayLayout),
+		To:             end.AddDate(0, 0, -1).Format(trendDayLayout),
+		WeeksRequested: weeks,
+		WeeksObserved:  observed,
+		Confidence:     forecastConfidence,
+		Towns:          []townForecast{},
+	}
+
+	// counts[town][hourOfWeek][week]
+	counts := make(map[string][][]int)
+	totals := make(map[string]int)
+	if observed > 0 {
+		query := `SELECT day, hour, town, calls FROM stats_hourly WHERE day >= ? AND day < ?`
+		args := []interface{}{start.Format(trendDayLayout), end.Format(trendDayLayout)}
+		if townFilter != "" {
+			query += ` AND town = ? COLLATE NOCASE`
+			args = append(args, townFilter)
+		}
+		rows, err := s.db.QueryContext(r.Context(), query, args...)
+		if err != nil {
+			log.Printf("forecast query failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var label, town string
+			var hour, calls int
+			if err := rows.Scan(&label, &hour, &town, &calls); err != nil {
+				log.Printf("forecast scan failed: %v", err)
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+			day, err := time.ParseInLocation(trendDayLayout, label, s.tz)
+			if err != nil || hour < 0 || hour > 23 {
+				continue
+			}
+			week := int(math.Round(day.Sub(start).Hours()/24)) / 7
+			if week < 0 || week >= observed {
+				continue
+			}
+			if counts[town] == nil {
+				counts[town] = make([][]int, 7*24)
+				for i := range counts[town] {
+					counts[town][i] = make([]int, observed)
+				}
+			}
+			counts[town][int(day.Weekday())*24+hour][week] += calls
+			totals[town] += calls
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("forecast query failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if townFilter != "" && len(counts) == 0 {
+		counts[townFilter] = nil
+	}
+
+	for town, byHour := range counts {
+		f := townForecast{Town: town, TotalCalls: totals[town], WeeksObserved: observed, Hours: make([]forecastHour, 0, 7*24)}
+		if f.Town == "" {
+			f.Town = "unknown"
+		}
+		if observed < minForecastWeeks {
+			f.Flags = append(f.Flags, "short_history")
+		}
+		if f.TotalCalls < minForecastCalls {
+			f.Flags = append(f.Flags, "sparse")
+		}
+		f.Sufficient = len(f.Flags) == 0
+		for how := 0; how < 7*24; how++ {
+			var values []int
+			if byHour != nil {
+				values = byHour[how]
+			}
+			mean, sd := meanStdDev(values)
+			f.Hours = append(f.Hours, forecastHour{
+				Weekday:    time.Weekday(how / 24).String()[:3],
+				Hour:       how % 24,
+				HourOfWeek: how,
+				Expected:   round2(mean),
+				Low:        round2(math.Max(0, mean-forecastZ*sd)),
+				High:       round2(mean + forecastZ*sd),
+			})
+		}
+		resp.Towns = append(resp.Towns, f)
+	}
+	sort.Slice(resp.Towns, func(i, j int) bool {
+		if resp.Towns[i].TotalCalls != resp.Towns[j].TotalCalls {
+			return resp.Towns[i].TotalCalls > resp.Towns[j].TotalCalls
+		}
+		return resp.Towns[i].Town < resp.Towns[j].Town
+	})
+	respondJSON(w, resp)
+}
+
+// meanStdDev returns the mean and sample standard deviation of values.
+func meanStdDev(values []int) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += float64(v)
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var sq float64
+	for _, v := range values {
+		sq += (float64(v) - mean) * (float64(v) - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)-1))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,146 @@
+package formatting
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Entity kinds recorded in the cross-call entity index.
+const (
+	EntityStreet   = "street"
+	EntityLandmark = "landmark"
+	EntityFacility = "facility"
+)
+
+// Entity is a place named in a transcript. Key is the AliasKey of Name and
+// is what calls are grouped on, so "Main St" and "main street" meet.
+type Entity struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+var facilityWords = []string{
+	"School", "High School", "Middle School", "Elementary School", "Hospital", "Medical Center", "Church", "Nursing Home",
+	"Rehab", "Center", "Apartments", "Library", "Firehouse", "Academy", "College", "Station", "Motel", "Hotel", "Inn",
+}
+
+var landmarkWords = []string{
+	"Park", "Lake", "Pond", "Plaza", "Mall", "Bridge", "Dam", "Trail", "Field", "Fields", "Mountain", "Golf Course",
+	"Marina", "Beach", "Campground", "Ski Area", "Fairgrounds",
+}
+
+var (
+	entityStreetPattern   = placeWordPattern(streetSuffixList)
+	entityRoutePattern    = regexp.MustCompile(`\b(?:County Route|Route|Interstate) \d{1,3}\b`)
+	entityFacilityPattern = placeWordPattern(facilityWords)
+	entityLandmarkPattern = placeWordPattern(landmarkWords)
+)
+
+// entityStopWords end a place name. Names are read backwards from the
+// place word until one of these, a number, a street suffix or punctuation,
+// so "respond to 12 Main Street" yields "Main Street".
+var entityStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "at": true, "by": true, "for": true, "from": true, "in": true, "into": true,
+	"near": true, "of": true, "off": true, "on": true, "or": true, "the": true, "to": true, "via": true, "is": true,
+	"respond": true, "responding": true, "route": true, "behind": true, "across": true, "up": true, "down": true,
+	"out": true, "over": true, "past": true, "toward": true, "towards": true, "with": true, "cross": true,
+	"then": true, "back": true, "stage": true, "staging": true, "this": true, "that": true, "their": true, "his": true, "her": true,
+}
+
+// placeWordPattern matches the generic words that end a place name, e.g.
+// "School" in "Sparta High School".
+func placeWordPattern(words []string) *regexp.Regexp {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// ExtractEntities returns the streets, landmarks and facilities named in
+// text, each once, in the order they first appear within their kind.
+func ExtractEntities(text string) []Entity {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	text = normalizeSuffixes(normalizeRouteNumbers(whitespacePattern.ReplaceAllString(text, " ")))
+
+	var out []Entity
+	seen := make(map[string]bool)
+	add := func(kind, name string) {
+		key := AliasKey(name)
+		if key == "" || seen[kind+"|"+key] {
+			return
+		}
+		seen[kind+"|"+key] = true
+		out = append(out, Entity{Kind: kind, Name: name, Key: key})
+	}
+	for _, m := range entityRoutePattern.FindAllString(text, -1) {
+		add(EntityStreet, m)
+	}
+	for _, loc := range entityStreetPattern.FindAllStringIndex(text, -1) {
+		// Numbered routes were taken above; "Route" alone names nothing.
+		if strings.EqualFold(text[loc[0]:loc[1]], "Route") {
+			continue
+		}
+		if name := placeName(text, loc); name != "" {
+			add(EntityStreet, normalizeStreet(name))
+		}
+	}
+	for _, loc := range entityFacilityPattern.FindAllStringIndex(text, -1) {
+		if name := placeName(text, loc); name != "" {
+			add(EntityFacility, titleWords(name))
+		}
+	}
+	for _, loc := range entityLandmarkPattern.FindAllStringIndex(text, -1) {
+		if name := placeName(text, loc); name != "" {
+			add(EntityLandmark, titleWords(name))
+		}
+	}
+	return out
+}
+
+// placeName prefixes the place word at loc with up to three words read
+// backwards from it. It returns "" when no distinguishing word precedes it,
+// so a bare "the school" is not indexed.
+func placeName(text string, loc []int) string {
+	before := strings.Fields(text[:loc[0]])
+	var words []string
+	for i := len(before) - 1; i >= 0 && len(words) < 3; i-- {
+		w := before[i]
+		if strings.ContainsAny(w, ",.;:!?") {
+			break
+		}
+		lower := strings.ToLower(strings.Trim(w, "'-"))
+		if lower == "" || entityStopWords[lower] || isDigits(lower) || streetSuffixes[lower] != "" {
+			break
+		}
+		words = append([]string{w}, words...)
+	}
+	if len(words) == 0 {
+		return ""
+	}
+	return strings.Join(append(words, text[loc[0]:loc[1]]), " ")
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func titleWords(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + strings.ToLower(w[1:])
+	}
+	return strings.Join(words, " ")
+}
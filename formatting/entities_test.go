@@ -0,0 +1,30 @@
+package formatting
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractEntities(t *testing.T) {
+	got := ExtractEntities("Engine 5 respond to 12 main st for an alarm at Sparta High School, cross street Rt. 15. Units stage at Lake Mohawk Plaza, then the school.")
+	want := []Entity{
+		{Kind: EntityStreet, Name: "Route 15", Key: "route 15"},
+		{Kind: EntityStreet, Name: "Main Street", Key: "main street"},
+		{Kind: EntityFacility, Name: "Sparta High School", Key: "sparta high school"},
+		{Kind: EntityLandmark, Name: "Lake Mohawk Plaza", Key: "lake mohawk plaza"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExtractEntities() = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractEntitiesDedupesByKey(t *testing.T) {
+	got := ExtractEntities("Main Street and Oak Rd. Back to main st.")
+	want := []Entity{
+		{Kind: EntityStreet, Name: "Main Street", Key: "main street"},
+		{Kind: EntityStreet, Name: "Oak Road", Key: "oak road"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExtractEntities() = %+v, want %+v", got, want)
+	}
+}
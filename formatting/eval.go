@@ -0,0 +1,78 @@
+package formatting
+
+import "strings"
+
+// WordErrorRate is the word-level edit distance between a reference and a
+// hypothesis transcript divided by the number of reference words. Case and
+// surrounding punctuation are ignored. It can exceed 1 when the hypothesis
+// adds many words.
+func WordErrorRate(reference, hypothesis string) float64 {
+	ref := comparableWords(reference)
+	hyp := comparableWords(hypothesis)
+	if len(ref) == 0 {
+		if len(hyp) == 0 {
+			return 0
+		}
+		return 1
+	}
+	prev := make([]int, len(hyp)+1)
+	cur := make([]int, len(hyp)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ref); i++ {
+		cur[0] = i
+		for j := 1; j <= len(hyp); j++ {
+			cost := 1
+			if ref[i-1] == hyp[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return float64(prev[len(hyp)]) / float64(len(ref))
+}
+
+// AddressMatches reports whether every word of the expected address appears
+// in the resolved one, after folding case, punctuation and street-suffix and
+// township abbreviations. A resolved label may carry more detail (town,
+// state, zip) than the labeled answer.
+func AddressMatches(expected, resolved string) bool {
+	want := addressWords(expected)
+	if len(want) == 0 {
+		return false
+	}
+	have := make(map[string]bool)
+	for _, w := range addressWords(resolved) {
+		have[w] = true
+	}
+	for _, w := range want {
+		if !have[w] {
+			return false
+		}
+	}
+	return true
+}
+
+func comparableWords(text string) []string {
+	var out []string
+	for _, w := range strings.Fields(text) {
+		if w = seamWord(w); w != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func addressWords(text string) []string {
+	words := comparableWords(text)
+	for i, w := range words {
+		if full, ok := streetSuffixes[w]; ok {
+			words[i] = strings.ToLower(full)
+		} else if full, ok := townshipVariants[w]; ok {
+			words[i] = strings.ToLower(full)
+		}
+	}
+	return words
+}
@@ -0,0 +1,151 @@
+package formatting
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/config"
+)
+
+// DefaultPattern names ParseCallMetadataFromFilename when a FilenameParser
+// reports which pattern parsed a filename.
+const DefaultPattern = "default"
+
+// FilenameParser parses call metadata from filenames with configured
+// patterns, trying them in order and falling back to
+// ParseCallMetadataFromFilename when none matches. A nil parser only uses the
+// fallback.
+type FilenameParser struct {
+	patterns []filenamePattern
+}
+
+type filenamePattern struct {
+	config.FilenamePattern
+	re *regexp.Regexp
+}
+
+// NewFilenameParser compiles patterns into a parser.
+func NewFilenameParser(patterns []config.FilenamePattern) (*FilenameParser, error) {
+	p := &FilenameParser{}
+	for _, fp := range patterns {
+		re, err := fp.Compile()
+		if err != nil {
+			return nil, fmt.Errorf("filename pattern %s: %w", fp.Name, err)
+		}
+		p.patterns = append(p.patterns, filenamePattern{FilenamePattern: fp, re: re})
+	}
+	return p, nil
+}
+
+// PatternTrial records whether one pattern matched a filename and, if it
+// did, why it was not used.
+type PatternTrial struct {
+	Name    string `json:"name"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Parse returns fileName's metadata and the name of the pattern that parsed
+// it. Patterns limited to another source are skipped; with an empty source
+// every pattern is tried.
+func (p *FilenameParser) Parse(fileName, source string, loc *time.Location) (CallMetadata, string, error) {
+	meta, name, _, err := p.Explain(fileName, source, loc)
+	return meta, name, err
+}
+
+// Explain is Parse, also reporting how each pattern fared.
+func (p *FilenameParser) Explain(fileName, source string, loc *time.Location) (CallMetadata, string, []PatternTrial, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	var trials []PatternTrial
+	if p != nil {
+		base := filepath.Base(fileName)
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+		for _, fp := range p.patterns {
+			trial := PatternTrial{Name: fp.Name}
+			if fp.Source != "" && source != "" && !strings.EqualFold(fp.Source, source) {
+				trial.Skipped = true
+				trials = append(trials, trial)
+				continue
+			}
+			match := fp.re.FindStringSubmatch(base)
+			if match == nil {
+				trials = append(trials, trial)
+				continue
+			}
+			trial.Matched = true
+			meta, err := fp.metadata(match, fileName, loc)
+			if err != nil {
+				trial.Error = err.Error()
+				trials = append(trials, trial)
+				continue
+			}
+			return meta, fp.Name, append(trials, trial), nil
+		}
+	}
+	meta, err := ParseCallMetadataFromFilename(fileName, loc)
+	return meta, DefaultPattern, trials, err
+}
+
+func (fp filenamePattern) metadata(match []string, fileName string, loc *time.Location) (CallMetadata, error) {
+	groups := map[string]string{}
+	for i, name := range fp.re.SubexpNames() {
+		if name != "" && match[i] != "" {
+			groups[name] = match[i]
+		}
+	}
+	dt, err := fp.callTime(groups, loc)
+	if err != nil {
+		return CallMetadata{RawFileName: fileName}, err
+	}
+	agency := normalizeDisplay(groups["agency"])
+	town := normalizeDisplay(groups["town"])
+	if agency == "" {
+		agency = town
+	}
+	if town == "" {
+		town = agency
+	}
+	return CallMetadata{
+		AgencyDisplay: agency,
+		TownDisplay:   town,
+		CallType:      strings.ToUpper(strings.ReplaceAll(groups["call_type"], "_", " ")),
+		DateTime:      dt,
+		RawFileName:   fileName,
+	}, nil
+}
+
+func (fp filenamePattern) callTime(groups map[string]string, loc *time.Location) (time.Time, error) {
+	if raw, ok := groups["epoch"]; ok {
+		secs, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("epoch %q: %w", raw, err)
+		}
+		return time.Unix(secs, 0).In(loc), nil
+	}
+	if raw, ok := groups["timestamp"]; ok {
+		return time.ParseInLocation(fp.TimeLayout, raw, loc)
+	}
+	var parts [6]int
+	for i, name := range []string{"year", "month", "day", "hour", "minute", "second"} {
+		v, err := strconv.Atoi(groups[name])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s %q is not a number", name, groups[name])
+		}
+		parts[i] = v
+	}
+	if parts[0] < 100 {
+		parts[0] += 2000
+	}
+	if parts[1] < 1 || parts[1] > 12 || parts[2] < 1 || parts[2] > 31 || parts[3] > 23 || parts[4] > 59 || parts[5] > 60 {
+		return time.Time{}, errors.New("date or time out of range")
+	}
+	return time.Date(parts[0], time.Month(parts[1]), parts[2], parts[3], parts[4], parts[5], 0, loc), nil
+}
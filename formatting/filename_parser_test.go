@@ -0,0 +1,55 @@
+package formatting
+
+import (
+	"testing"
+	"time"
+
+	"alert_framework/config"
+)
+
+func TestFilenameParserPatterns(t *testing.T) {
+	loc, err := time.LoadLocation("EST5EDT")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+	parser, err := NewFilenameParser([]config.FilenamePattern{
+		{Name: "sdrtrunk", Source: "remote:county", Pattern: `^(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})_(?P<hour>\d{2})(?P<minute>\d{2})(?P<second>\d{2})_(?P<agency>[A-Za-z]+)_(?P<call_type>[A-Za-z]+)$`},
+		{Name: "epoch", Pattern: `^(?P<epoch>\d{10})-(?P<town>[A-Za-z]+)$`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, name, err := parser.Parse("20250301_142500_SpartaFire_Gen.mp3", "remote:county", loc)
+	if err != nil || name != "sdrtrunk" {
+		t.Fatalf("Parse = %q, %v", name, err)
+	}
+	if meta.AgencyDisplay != "Sparta Fire" || meta.TownDisplay != "Sparta Fire" || meta.CallType != "GEN" {
+		t.Fatalf("meta = %+v", meta)
+	}
+	if want := time.Date(2025, 3, 1, 14, 25, 0, 0, loc); !meta.DateTime.Equal(want) {
+		t.Fatalf("time = %v, want %v", meta.DateTime, want)
+	}
+
+	// A pattern for another source is skipped, leaving the built-in parser.
+	if _, name, err := parser.Parse("20250301_142500_SpartaFire_Gen.mp3", "watcher", loc); name != DefaultPattern || err == nil {
+		t.Fatalf("other source parsed by %q, err %v", name, err)
+	}
+
+	meta, name, err = parser.Parse("1740857100-Newton.wav", "watcher", loc)
+	if err != nil || name != "epoch" || meta.TownDisplay != "Newton" || meta.DateTime.Unix() != 1740857100 {
+		t.Fatalf("epoch pattern: %q %+v %v", name, meta, err)
+	}
+
+	meta, name, err = parser.Parse("Glenwood-Pochuck_EMS_2025_11_27_19_58_13.mp3", "", loc)
+	if err != nil || name != DefaultPattern || meta.CallType != "EMS" {
+		t.Fatalf("fallback: %q %+v %v", name, meta, err)
+	}
+}
+
+func TestFilenameParserRejectsPatternWithoutTime(t *testing.T) {
+	_, err := NewFilenameParser([]config.FilenamePattern{{Name: "bad", Pattern: `^(?P<agency>\w+)_(?P<year>\d{4})$`}})
+	if err == nil {
+		t.Fatal("pattern without a full time accepted")
+	}
+}
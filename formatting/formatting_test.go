@@ -143,3 +143,187 @@ func TestParseCallMetadataWithEmptySegments(t *testing.T) {
 		t.Fatalf("unexpected datetime: %v", meta.DateTime)
 	}
 }
+
+func TestBuildMapURL(t *testing.T) {
+	if got := BuildMapURL(0, 0); got != "" {
+		t.Fatalf("expected empty map URL, got %s", got)
+	}
+	if got := BuildMapURL(41.05812, -74.75231); got != "https://www.google.com/maps/search/?api=1&query=41.05812,-74.75231" {
+		t.Fatalf("unexpected map URL %s", got)
+	}
+}
+
+func TestRedactPII(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"Patient John Smith, DOB 04/12/1961, chest pain", "Patient [NAME], [DOB], chest pain"},
+		{"caller is at 12 Main St, callback 973-555-0142", "caller is at 12 Main St, callback [PHONE]"},
+		{"born on March 3rd, 1950 ssn 123-45-6789", "[DOB] ssn [ID]"},
+		{"the driver Mary O'Neil is out of the vehicle", "the driver [NAME] is out of the vehicle"},
+		{"Engine 71 respond to Route 15 for a brush fire", "Engine 71 respond to Route 15 for a brush fire"},
+	}
+	for _, tc := range cases {
+		if got := RedactPII(tc.in); got != tc.want {
+			t.Errorf("RedactPII(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestCallFlags(t *testing.T) {
+	flags := CallFlags("EMS/Medical", "respond for a possible overdose, narcan administered, patient is a juvenile")
+	if len(flags) != 2 || flags[0] != FlagOverdose || flags[1] != FlagJuvenile {
+		t.Fatalf("unexpected flags: %v", flags)
+	}
+	if flags := CallFlags("Fire", "working structure fire on Main Street"); len(flags) != 0 {
+		t.Fatalf("expected no flags, got %v", flags)
+	}
+	if flags := CallFlags("EDP", ""); len(flags) != 1 || flags[0] != FlagMentalHealth {
+		t.Fatalf("expected call type to flag mental health, got %v", flags)
+	}
+}
+
+func TestMaskProfanity(t *testing.T) {
+	got := MaskProfanity("get the damn hose, this is bullshit. Shitake mushrooms")
+	want := "get the d*** hose, this is b*******. Shitake mushrooms"
+	if got != want {
+		t.Fatalf("MaskProfanity = %q, want %q", got, want)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	cases := map[string]string{
+		"Engine 41 responding to the structure fire on Main Street": LanguageEnglish,
+		"Necesito ayuda, mi esposo está herido en la calle":         LanguageSpanish,
+		"Medic 2 en route": "",
+	}
+	for in, want := range cases {
+		if got := DetectLanguage(in); got != want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSpeakerRoles(t *testing.T) {
+	roles, confident := SpeakerRoles([]SpeakerTurn{
+		{Speaker: "Speaker 0", Text: "Medic 2 copy, en route"},
+		{Speaker: "Speaker 1", Text: "Medic 2 respond to 12 Main Street for a fall, caller states patient is conscious"},
+		{Speaker: "Speaker 0", Text: "Medic 2 on scene"},
+	})
+	if !confident {
+		t.Fatalf("expected confident roles, got %v", roles)
+	}
+	if roles["Speaker 1"] != RoleDispatcher || roles["Speaker 0"] != RoleFieldUnit {
+		t.Fatalf("unexpected roles: %v", roles)
+	}
+	if _, confident := SpeakerRoles([]SpeakerTurn{{Speaker: "A", Text: "hello"}, {Speaker: "B", Text: "hello"}}); confident {
+		t.Fatalf("expected tie to be unconfident")
+	}
+}
+
+func TestMergeChunkTranscripts(t *testing.T) {
+	got := MergeChunkTranscripts([]string{
+		"Engine 41 responding to 12 Main Street,",
+		"Main Street. Engine 41 on scene",
+		"",
+		"nothing showing.",
+	})
+	want := "Engine 41 responding to 12 Main Street, Engine 41 on scene nothing showing."
+	if got != want {
+		t.Fatalf("MergeChunkTranscripts = %q, want %q", got, want)
+	}
+}
+
+func TestWordErrorRate(t *testing.T) {
+	if got := WordErrorRate("Engine 41 respond to Main Street.", "engine 41 respond to main street"); got != 0 {
+		t.Fatalf("expected case and punctuation to be ignored, got %v", got)
+	}
+	// one substitution and one deletion over five reference words
+	if got := WordErrorRate("medic 2 respond to Newton", "medic 3 respond Newton"); got != 0.4 {
+		t.Fatalf("WordErrorRate = %v, want 0.4", got)
+	}
+	if got := WordErrorRate("", "noise"); got != 1 {
+		t.Fatalf("expected empty reference with output to score 1, got %v", got)
+	}
+}
+
+func TestAddressMatches(t *testing.T) {
+	if !AddressMatches("12 Main St", "12 Main Street, Newton, NJ 07860") {
+		t.Fatalf("expected abbreviated suffix to match")
+	}
+	if !AddressMatches("5 Oak Rd, Andover Twp", "5 Oak Road, Andover Township") {
+		t.Fatalf("expected township abbreviation to match")
+	}
+	if AddressMatches("14 Main St", "12 Main Street, Newton") {
+		t.Fatalf("expected different house number not to match")
+	}
+	if AddressMatches("", "12 Main Street") {
+		t.Fatalf("expected empty expectation not to match")
+	}
+}
+
+func TestNormalizeTranscriptFoldsRouteNumbers(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"MVA on Rt. 206 northbound", "MVA on Route 206 northbound."},
+		{"tree down on US-206 at the bypass", "tree down on Route 206 at the bypass."},
+		{"brush fire off State Hwy 15", "brush fire off Route 15."},
+		{"wires down on CR 519 in Wantage", "wires down on County Route 519 in Wantage."},
+		{"disabled vehicle I-80 eastbound", "disabled vehicle Interstate 80 eastbound."},
+	}
+	for _, tc := range cases {
+		if got := NormalizeTranscript(tc.in); got != tc.want {
+			t.Errorf("NormalizeTranscript(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeTranscriptWithTerms(t *testing.T) {
+	terms := []Term{
+		{Term: "10-50", Expansion: "motor vehicle crash"},
+		{Term: "10-50 PI", Expansion: "motor vehicle crash with injuries"},
+		{Term: "BLS", Expansion: "basic life support"},
+		{Term: "PIAA", Expansion: "PIAA"},
+	}
+	got := NormalizeTranscriptWith("10-50 PI on Rt 15, bls requested, second 10-50 at 110-50 PIAA field", terms)
+	want := "motor vehicle crash with injuries on Route 15, basic life support requested, second motor vehicle crash at 110-50 PIAA field."
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if _, matched := ExpandTerms("BLS and 10-50s", terms); len(matched) != 1 || matched[0] != "BLS" {
+		t.Fatalf("matched = %v", matched)
+	}
+	if got := NormalizeTranscriptWith("Engine 41 respond", nil); got != NormalizeTranscript("Engine 41 respond") {
+		t.Fatalf("no terms should normalize as before, got %q", got)
+	}
+}
+
+func TestSpokenText(t *testing.T) {
+	terms := []Term{{Term: "10-50", Expansion: "vehicle crash"}, {Term: "FD", Expansion: "fire company"}}
+	got := SpokenText("10-50 w/ entrapment, Rt 15 N/B at Main St, 45 y/o male, Sparta FD & ALS responding", terms)
+	want := "vehicle crash with entrapment, Route 15 northbound at Main Street, 45 year old male, Sparta fire company and advanced life support responding."
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := TrimWords("Engine 41 respond. Smoke showing from the roof of a two story home", 8); got != "Engine 41 respond." {
+		t.Fatalf("TrimWords = %q", got)
+	}
+	if got := TrimWords("smoke showing from the roof, two story", 5); got != "smoke showing from the roof." {
+		t.Fatalf("TrimWords = %q", got)
+	}
+}
+
+func TestAliasKeyMatching(t *testing.T) {
+	text := AliasKey("Engine 41 respond to the A and P plaza, Rt 206 bypass.")
+	for _, alias := range []string{"the A&P Plaza", "206 bypass", "Route 206 Bypass"} {
+		if !HasAliasKey(text, AliasKey(alias)) {
+			t.Errorf("expected %q to match %q", alias, text)
+		}
+	}
+	if HasAliasKey(text, AliasKey("P Plaz")) {
+		t.Errorf("expected partial words not to match")
+	}
+}
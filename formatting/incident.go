@@ -25,6 +25,62 @@ type IncidentDetails struct {
 	ListenURL     string
 	AudioPath     string
 	AudioFilename string
+	// Updated marks a re-sent alert whose details changed since the first post.
+	Updated bool
+	// RepeatNote flags a location with many recent calls, e.g. "5th call at
+	// this address in 30 days".
+	RepeatNote string
+	// Flags are further warnings about the location, e.g. "Nearest hydrant is
+	// 1450 ft away".
+	Flags []string
+	// Latitude and Longitude place the incident when it was geocoded.
+	Latitude  float64
+	Longitude float64
+	// LocationPrecision is one of the Precision tiers, or "" when unknown.
+	// LocationSource is where the location came from, e.g. "parsed_geocode"
+	// or "correction".
+	LocationPrecision string
+	LocationSource    string
+}
+
+// Location precision tiers, from most to least exact.
+const (
+	PrecisionAddress      = "address"
+	PrecisionIntersection = "intersection"
+	PrecisionStreet       = "street"
+	PrecisionArea         = "area"
+	PrecisionTownship     = "township"
+)
+
+// LocationPrecision maps the precision recorded with a location (the level
+// the geocoder was queried at, a Mapbox place type, or how the location was
+// guessed) to a precision tier. It returns "" when raw says nothing about
+// precision.
+func LocationPrecision(raw string) string {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	switch {
+	case raw == "address", raw == "poi", raw == "provided", raw == "correction", raw == "manual", raw == "alias", raw == "milemarker":
+		return PrecisionAddress
+	case raw == "intersection":
+		return PrecisionIntersection
+	case raw == "street":
+		return PrecisionStreet
+	case raw == "neighborhood", raw == "locality", raw == "postcode",
+		strings.HasPrefix(raw, "historical_hotspot"), strings.HasPrefix(raw, "metadata_ai"):
+		return PrecisionArea
+	case raw == "municipality", raw == "place", raw == "district", raw == "region", raw == "country":
+		return PrecisionTownship
+	}
+	return ""
+}
+
+// MapURL links to the incident on a map. It is empty without coordinates
+// and for township-level locations, whose pin is only the town's center.
+func (incident IncidentDetails) MapURL() string {
+	if incident.LocationPrecision == PrecisionTownship {
+		return ""
+	}
+	return BuildMapURL(incident.Latitude, incident.Longitude)
 }
 
 var callClassSeparator = strings.NewReplacer("_", " ", "-", " ", "/", " ")
@@ -45,10 +101,16 @@ func FormatIncidentHeader(incident IncidentDetails) string {
 }
 
 // FormatIncidentLocation renders a consistent, human-friendly location string.
+// Locations known only to the street or area read "near ...", and township
+// ones "in the area of ..." with any street text dropped, so a town-center
+// fallback does not look like an exact address.
 func FormatIncidentLocation(incident IncidentDetails) string {
 	parts := []string{}
 
 	address := strings.TrimSpace(incident.AddressLine)
+	if incident.LocationPrecision == PrecisionTownship {
+		address = ""
+	}
 	if address != "" {
 		if cross := strings.TrimSpace(incident.CrossStreet); cross != "" {
 			address = fmt.Sprintf("%s (x-street %s)", address, cross)
@@ -69,7 +131,17 @@ func FormatIncidentLocation(incident IncidentDetails) string {
 	if len(parts) == 0 {
 		return "Location unavailable"
 	}
-	return strings.Join(parts, ", ")
+	location := strings.Join(parts, ", ")
+	switch incident.LocationPrecision {
+	case PrecisionStreet, PrecisionArea:
+		if address != "" {
+			return "near " + location
+		}
+		return "in the area of " + location
+	case PrecisionTownship:
+		return "in the area of " + location
+	}
+	return location
 }
 
 // BuildIncidentAlert constructs a GroupMe-friendly alert body.
@@ -99,17 +171,35 @@ func BuildIncidentAlert(incident IncidentDetails) string {
 		summary = "Transcript pending."
 	}
 
+	header := fmt.Sprintf("%s %s – %s", emoji, agency, categoryShort)
+	if incident.Updated {
+		header += " (updated)"
+	}
+
 	lines := []string{
-		fmt.Sprintf("%s %s – %s", emoji, agency, categoryShort),
+		header,
 		"",
 		fmt.Sprintf("📍 Location: %s", location),
 		fmt.Sprintf("🏷️ Type: %s – %s", primary, callClass),
 		fmt.Sprintf("🕒 Time: %s", ts.Format("2006-01-02 15:04:05")),
+	}
+	if note := strings.TrimSpace(incident.RepeatNote); note != "" {
+		lines = append(lines, "⚠ "+note)
+	}
+	for _, flag := range incident.Flags {
+		if flag = strings.TrimSpace(flag); flag != "" {
+			lines = append(lines, "⚠ "+flag)
+		}
+	}
+	lines = append(lines,
 		"",
 		"Transcript:",
 		summary,
 		"",
 		fmt.Sprintf("🎧 Audio: %s", audio),
+	)
+	if mapURL := incident.MapURL(); mapURL != "" {
+		lines = append(lines, fmt.Sprintf("🗺️ Map: %s", mapURL))
 	}
 
 	return strings.Join(lines, "\n")
@@ -180,3 +270,21 @@ func capitalizeWord(word string) string {
 	runes[0] = unicode.ToUpper(runes[0])
 	return string(runes)
 }
+
+// Ordinal renders n as "1st", "2nd", "3rd", "4th", "11th" and so on.
+func Ordinal(n int) string {
+	suffix := "th"
+	switch n % 100 {
+	case 11, 12, 13:
+	default:
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
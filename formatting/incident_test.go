@@ -1,6 +1,7 @@
 package formatting
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -30,3 +31,104 @@ func TestBuildIncidentAlert(t *testing.T) {
 		t.Fatalf("BuildIncidentAlert mismatch.\nwant:\n%s\n\ngot:\n%s", want, got)
 	}
 }
+
+func TestBuildIncidentAlertRepeatNote(t *testing.T) {
+	incident := IncidentDetails{
+		Agency:       "Newton Fire",
+		CallCategory: "fire",
+		CallType:     "alarm",
+		Timestamp:    time.Date(2025, time.December, 4, 10, 6, 13, 0, time.UTC),
+		RepeatNote:   "5th call at this address in 30 days",
+	}
+	got := BuildIncidentAlert(incident)
+	if want := "🕒 Time: 2025-12-04 10:06:13\n⚠ 5th call at this address in 30 days\n\nTranscript:"; !strings.Contains(got, want) {
+		t.Fatalf("expected repeat note after the time line, got:\n%s", got)
+	}
+}
+
+func TestBuildIncidentAlertFlags(t *testing.T) {
+	incident := IncidentDetails{
+		Agency:       "Newton Fire",
+		CallCategory: "fire",
+		CallType:     "structure fire",
+		Timestamp:    time.Date(2025, time.December, 4, 10, 6, 13, 0, time.UTC),
+		RepeatNote:   "5th call at this address in 30 days",
+		Flags:        []string{"6.2 mi by road from the nearest fire station (Sparta Fire Dept)", " ", "Nearest hydrant is 1450 ft away"},
+	}
+	got := BuildIncidentAlert(incident)
+	want := "⚠ 5th call at this address in 30 days\n⚠ 6.2 mi by road from the nearest fire station (Sparta Fire Dept)\n⚠ Nearest hydrant is 1450 ft away\n\nTranscript:"
+	if !strings.Contains(got, want) {
+		t.Fatalf("expected flags after the repeat note, got:\n%s", got)
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 102: "102nd", 111: "111th"} {
+		if got := Ordinal(n); got != want {
+			t.Errorf("Ordinal(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestBuildIncidentAlertUpdated(t *testing.T) {
+	incident := IncidentDetails{Agency: "Newton Fire", CallCategory: "fire", CallType: "structure_fire", Updated: true}
+	got := BuildIncidentAlert(incident)
+	if want := "🚒 Newton Fire – Fire/Structure Fire (updated)\n"; !strings.HasPrefix(got, want) {
+		t.Fatalf("expected updated header %q, got:\n%s", want, got)
+	}
+}
+
+func TestLocationPrecision(t *testing.T) {
+	for raw, want := range map[string]string{
+		"address":              PrecisionAddress,
+		"correction":           PrecisionAddress,
+		"manual":               PrecisionAddress,
+		"intersection":         PrecisionIntersection,
+		"street":               PrecisionStreet,
+		"historical_hotspot_4": PrecisionArea,
+		"metadata_ai_85":       PrecisionArea,
+		"municipality":         PrecisionTownship,
+		"place":                PrecisionTownship,
+		"parsed_geocode":       "",
+		"":                     "",
+	} {
+		if got := LocationPrecision(raw); got != want {
+			t.Errorf("LocationPrecision(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestFormatIncidentLocationPrecision(t *testing.T) {
+	base := IncidentDetails{AddressLine: "Main St", CityOrTown: "Sparta", County: "Sussex", State: "NJ"}
+	for precision, want := range map[string]string{
+		"":                "Main St, Sparta, Sussex County, NJ",
+		PrecisionAddress:  "Main St, Sparta, Sussex County, NJ",
+		PrecisionStreet:   "near Main St, Sparta, Sussex County, NJ",
+		PrecisionArea:     "near Main St, Sparta, Sussex County, NJ",
+		PrecisionTownship: "in the area of Sparta, Sussex County, NJ",
+	} {
+		incident := base
+		incident.LocationPrecision = precision
+		if got := FormatIncidentLocation(incident); got != want {
+			t.Errorf("precision %q: got %q, want %q", precision, got, want)
+		}
+	}
+	townOnly := IncidentDetails{CityOrTown: "Sparta", LocationPrecision: PrecisionArea}
+	if got := FormatIncidentLocation(townOnly); got != "in the area of Sparta" {
+		t.Errorf("area without a street: got %q", got)
+	}
+}
+
+func TestIncidentMapURL(t *testing.T) {
+	incident := IncidentDetails{Latitude: 41.05812, Longitude: -74.75231, LocationPrecision: PrecisionStreet}
+	if !strings.Contains(BuildIncidentAlert(incident), "🗺️ Map: https://www.google.com/maps/search/?api=1&query=41.05812,-74.75231") {
+		t.Fatalf("expected a map link, got:\n%s", BuildIncidentAlert(incident))
+	}
+	incident.LocationPrecision = PrecisionTownship
+	if got := incident.MapURL(); got != "" {
+		t.Fatalf("expected no map link at township precision, got %q", got)
+	}
+	if strings.Contains(BuildIncidentAlert(incident), "Map:") {
+		t.Fatalf("township alert should not carry a map link")
+	}
+}
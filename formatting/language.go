@@ -0,0 +1,56 @@
+package formatting
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Language codes returned by DetectLanguage.
+const (
+	LanguageEnglish = "en"
+	LanguageSpanish = "es"
+)
+
+// minLanguageEvidence is the number of stopword hits needed before a
+// transcript is assigned a language; short radio traffic is often just a unit
+// number and a street.
+const minLanguageEvidence = 3
+
+var englishStopwords = wordSet("the", "and", "of", "to", "in", "is", "on", "for", "with", "at", "that", "this", "he", "she", "it", "you", "are", "was", "be", "from", "your", "respond", "responding", "units", "engine", "ambulance", "caller", "street", "road", "copy")
+
+var spanishStopwords = wordSet("el", "la", "los", "las", "de", "del", "que", "y", "en", "un", "una", "por", "con", "para", "es", "está", "esta", "no", "se", "su", "al", "lo", "como", "pero", "hay", "muy", "señor", "señora", "calle", "ayuda", "herido", "fuego", "años", "tiene", "necesito", "mi", "casa")
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// DetectLanguage guesses the language of a transcript from stopword counts
+// and Spanish diacritics. It returns LanguageEnglish, LanguageSpanish, or ""
+// when there is too little text to tell.
+func DetectLanguage(text string) string {
+	var en, es int
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		if englishStopwords[word] {
+			en++
+		}
+		if spanishStopwords[word] {
+			es++
+		}
+		if strings.ContainsAny(word, "ñáéíóúü") {
+			es++
+		}
+	}
+	if en+es < minLanguageEvidence {
+		return ""
+	}
+	if es > en {
+		return LanguageSpanish
+	}
+	return LanguageEnglish
+}
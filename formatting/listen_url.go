@@ -40,3 +40,12 @@ func BuildListenURL(filename string) string {
 	}
 	return fmt.Sprintf("http://localhost:%s/%s", port, safeName)
 }
+
+// BuildMapURL returns a map link for a coordinate pair, or "" when the
+// coordinates are unset.
+func BuildMapURL(lat, lon float64) string {
+	if lat == 0 && lon == 0 {
+		return ""
+	}
+	return fmt.Sprintf("https://www.google.com/maps/search/?api=1&query=%.5f,%.5f", lat, lon)
+}
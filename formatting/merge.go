@@ -0,0 +1,58 @@
+package formatting
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxSeamWords bounds how far MergeChunkTranscripts looks for text repeated
+// across a chunk seam; chunk overlaps are a second or two of speech.
+const maxSeamWords = 12
+
+// MergeChunkTranscripts joins transcripts of consecutive, slightly
+// overlapping audio chunks. Where the start of a chunk repeats the end of the
+// previous one (ignoring case and punctuation), the repeated words are
+// dropped so the overlap is only transcribed once.
+func MergeChunkTranscripts(parts []string) string {
+	var merged []string
+	for _, part := range parts {
+		words := strings.Fields(part)
+		if len(words) == 0 {
+			continue
+		}
+		words = words[seamOverlap(merged, words):]
+		merged = append(merged, words...)
+	}
+	return strings.Join(merged, " ")
+}
+
+// seamOverlap returns how many leading words of next repeat the trailing
+// words of prev. Single-word matches are ignored as coincidence.
+func seamOverlap(prev, next []string) int {
+	limit := maxSeamWords
+	if len(prev) < limit {
+		limit = len(prev)
+	}
+	if len(next) < limit {
+		limit = len(next)
+	}
+	for n := limit; n >= 2; n-- {
+		match := true
+		for i := 0; i < n; i++ {
+			if seamWord(prev[len(prev)-n+i]) != seamWord(next[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return n
+		}
+	}
+	return 0
+}
+
+func seamWord(w string) string {
+	return strings.ToLower(strings.TrimFunc(w, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}))
+}
@@ -55,6 +55,15 @@ var townshipVariants = map[string]string{
 
 var suffixPattern = regexp.MustCompile(`(?i)\b([A-Za-z]+)(?:\s+)(` + streetSuffixAlternation() + `)\b`)
 
+// Numbered highways are spoken and typed many ways ("Rt. 206", "US-206",
+// "State Hwy 15", "CR 519"). They are folded to "Route N", "County Route N"
+// and "Interstate N" so parsing, geocoding and alias lookups see one form.
+var (
+	countyRoutePattern = regexp.MustCompile(`(?i)\b(?:county\s+(?:route|road|rd\.?|rte\.?|rt\.?)|c\.?r\.?)\s*-?\s*(\d{2,3})\b`)
+	interstatePattern  = regexp.MustCompile(`(?i)\b(?:interstate\s*|i-\s*)(\d{2,3})\b`)
+	routePattern       = regexp.MustCompile(`(?i)\b(?:(?:u\.?s\.?|n\.?j\.?)(?:\s+(?:route|highway|hwy\.?|rte\.?|rt\.?))?|state\s+(?:route|highway|hwy\.?|rte\.?|rt\.?)|route|highway|hwy\.?|rte\.?|rt\.?)\s*-?\s*(\d{1,3})\b`)
+)
+
 // NormalizeTranscript cleans radio transcripts for easier parsing.
 func NormalizeTranscript(raw string) string {
 	text := strings.TrimSpace(raw)
@@ -63,6 +72,7 @@ func NormalizeTranscript(raw string) string {
 	}
 
 	text = whitespacePattern.ReplaceAllString(text, " ")
+	text = normalizeRouteNumbers(text)
 	text = normalizeSuffixes(text)
 	text = normalizeTownshipTokens(text)
 	text = strings.TrimSpace(text)
@@ -75,6 +85,44 @@ func NormalizeTranscript(raw string) string {
 	return text
 }
 
+func normalizeRouteNumbers(text string) string {
+	text = countyRoutePattern.ReplaceAllString(text, "County Route $1")
+	text = interstatePattern.ReplaceAllString(text, "Interstate $1")
+	return routePattern.ReplaceAllString(text, "Route $1")
+}
+
+// AliasKey folds a place name or transcript into the form street aliases are
+// matched on: lower case, no punctuation or "the", "&" spelled "and", street
+// suffixes and township abbreviations spelled out and numbered highways
+// normalized.
+func AliasKey(text string) string {
+	text = normalizeRouteNumbers(text)
+	text = strings.ReplaceAll(text, "&", " and ")
+	var words []string
+	for _, w := range strings.Fields(text) {
+		w = seamWord(w)
+		if w == "" || w == "the" {
+			continue
+		}
+		if full, ok := streetSuffixes[w]; ok {
+			w = strings.ToLower(full)
+		} else if full, ok := townshipVariants[w]; ok {
+			w = strings.ToLower(full)
+		}
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// HasAliasKey reports whether key occurs in textKey as whole words. Both are
+// expected to come from AliasKey.
+func HasAliasKey(textKey, key string) bool {
+	if key == "" {
+		return false
+	}
+	return strings.Contains(" "+textKey+" ", " "+key+" ")
+}
+
 func normalizeSuffixes(text string) string {
 	return suffixPattern.ReplaceAllStringFunc(text, func(match string) string {
 		parts := strings.Fields(match)
@@ -0,0 +1,33 @@
+package formatting
+
+import "regexp"
+
+// Placeholders substituted for redacted spans.
+const (
+	RedactedName    = "[NAME]"
+	RedactedDOB     = "[DOB]"
+	RedactedPhone   = "[PHONE]"
+	RedactedID      = "[ID]"
+	RedactedMedical = "[MEDICAL]"
+)
+
+var (
+	dobPattern   = regexp.MustCompile(`(?i)\b(?:d\.?o\.?b\.?|date of birth|born(?: on)?)\s*[:\-]?\s*(?:\d{1,2}[/-]\d{1,2}[/-]\d{2,4}|(?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+\d{1,2}(?:st|nd|rd|th)?,?\s+\d{4})`)
+	datePattern  = regexp.MustCompile(`\b\d{1,2}/\d{1,2}/(?:19|20)\d{2}\b`)
+	ssnPattern   = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+	phonePattern = regexp.MustCompile(`(?:\(\d{3}\)\s*|\b\d{3}[-.\s])\d{3}[-.\s]\d{4}\b`)
+	namePattern  = regexp.MustCompile(`\b((?i:patient|caller|driver|victim|subject|resident|name is|named))(\s+)[A-Z][a-z]+(?:\s+[A-Z][A-Za-z'\-]*[a-z])?`)
+)
+
+// RedactPII replaces identifiers that can be spotted mechanically: dates of
+// birth, full dates, SSNs, phone numbers, and names introduced by a cue such
+// as "patient" or "name is". Free-form names and medical details need the
+// LLM pass; this is the floor that always runs.
+func RedactPII(text string) string {
+	text = dobPattern.ReplaceAllString(text, RedactedDOB)
+	text = datePattern.ReplaceAllString(text, RedactedDOB)
+	text = ssnPattern.ReplaceAllString(text, RedactedID)
+	text = phonePattern.ReplaceAllString(text, RedactedPhone)
+	text = namePattern.ReplaceAllString(text, "${1}${2}"+RedactedName)
+	return text
+}
@@ -0,0 +1,64 @@
+package formatting
+
+import "regexp"
+
+// Speaker roles assigned to diarized transcript segments.
+const (
+	RoleDispatcher = "dispatcher"
+	RoleFieldUnit  = "field_unit"
+)
+
+// SpeakerTurn is one diarized segment as seen by SpeakerRoles.
+type SpeakerTurn struct {
+	Speaker string
+	Text    string
+}
+
+var (
+	dispatcherCues = keywordPattern("respond", "dispatch", "report of", "caller states", "caller reports", "be advised", "for a", "requesting", "cross street", "box alarm", "time out")
+	fieldUnitCues  = keywordPattern("en route", "enroute", "on scene", "on location", "responding", "show us", "arrived", "copy", "10-4", "in service", "available", "transporting", "clear", "command")
+)
+
+// SpeakerRoles labels each diarized speaker as dispatcher or field unit from
+// radio phrasing. The speaker with the strongest dispatcher cues is the
+// dispatcher; everyone else is a field unit. confident is false when no
+// speaker shows dispatcher cues or two speakers tie, in which case the first
+// speaker is assumed to be dispatch and callers may want a second opinion.
+func SpeakerRoles(turns []SpeakerTurn) (roles map[string]string, confident bool) {
+	scores := make(map[string]int)
+	var order []string
+	for _, turn := range turns {
+		if turn.Speaker == "" {
+			continue
+		}
+		if _, seen := scores[turn.Speaker]; !seen {
+			order = append(order, turn.Speaker)
+			scores[turn.Speaker] = 0
+		}
+		scores[turn.Speaker] += countMatches(dispatcherCues, turn.Text) - countMatches(fieldUnitCues, turn.Text)
+	}
+	if len(order) == 0 {
+		return nil, false
+	}
+	dispatcher := order[0]
+	confident = scores[dispatcher] > 0
+	for _, speaker := range order[1:] {
+		switch {
+		case scores[speaker] > scores[dispatcher]:
+			dispatcher = speaker
+			confident = scores[speaker] > 0
+		case scores[speaker] == scores[dispatcher]:
+			confident = false
+		}
+	}
+	roles = make(map[string]string, len(order))
+	for _, speaker := range order {
+		roles[speaker] = RoleFieldUnit
+	}
+	roles[dispatcher] = RoleDispatcher
+	return roles, confident
+}
+
+func countMatches(pattern *regexp.Regexp, text string) int {
+	return len(pattern.FindAllStringIndex(text, -1))
+}
@@ -0,0 +1,49 @@
+package formatting
+
+import "strings"
+
+// spokenTerms are the abbreviations a speech engine would spell out or
+// mispronounce. They are expanded after a region's own terms, which win
+// where both define one.
+var spokenTerms = []Term{
+	{Term: "MVA", Expansion: "motor vehicle accident"},
+	{Term: "MVC", Expansion: "motor vehicle crash"},
+	{Term: "ALS", Expansion: "advanced life support"},
+	{Term: "BLS", Expansion: "basic life support"},
+	{Term: "FD", Expansion: "fire department"},
+	{Term: "PD", Expansion: "police department"},
+	{Term: "DOA", Expansion: "dead on arrival"},
+	{Term: "LZ", Expansion: "landing zone"},
+	{Term: "approx", Expansion: "approximately"},
+	{Term: "y/o", Expansion: "year old"},
+	{Term: "w/", Expansion: "with"},
+	{Term: "N/B", Expansion: "northbound"},
+	{Term: "S/B", Expansion: "southbound"},
+	{Term: "E/B", Expansion: "eastbound"},
+	{Term: "W/B", Expansion: "westbound"},
+	{Term: "&", Expansion: "and"},
+}
+
+// SpokenText prepares text to be read aloud: terms and common dispatch
+// abbreviations are written out, and the rest is normalized like
+// NormalizeTranscript, which spells out street suffixes and highways.
+func SpokenText(text string, terms []Term) string {
+	all := make([]Term, 0, len(terms)+len(spokenTerms))
+	all = append(all, terms...)
+	all = append(all, spokenTerms...)
+	return NormalizeTranscriptWith(text, all)
+}
+
+// TrimWords cuts text to at most max words, ending at the last sentence that
+// fits when there is one.
+func TrimWords(text string, max int) string {
+	words := strings.Fields(text)
+	if len(words) <= max {
+		return strings.Join(words, " ")
+	}
+	cut := strings.Join(words[:max], " ")
+	if i := strings.LastIndexAny(cut, ".!?"); i > 0 {
+		return cut[:i+1]
+	}
+	return strings.TrimRight(cut, ",;:") + "."
+}
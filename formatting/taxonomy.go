@@ -0,0 +1,59 @@
+package formatting
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Call taxonomy flags. A call carries a flag when its call type or transcript
+// mentions one of the flag's keywords; policy decides which flags restrict a
+// call from public view.
+const (
+	FlagMentalHealth     = "mental_health"
+	FlagOverdose         = "overdose"
+	FlagJuvenile         = "juvenile"
+	FlagSexualAssault    = "sexual_assault"
+	FlagDomesticViolence = "domestic_violence"
+)
+
+var callTaxonomy = []struct {
+	flag    string
+	pattern *regexp.Regexp
+}{
+	{FlagMentalHealth, keywordPattern("mental health", "psychiatric", "psych eval", "edp", "emotionally disturbed", "suicidal", "suicide", "behavioral crisis")},
+	{FlagOverdose, keywordPattern("overdose", "narcan", "naloxone", "opioid", "heroin")},
+	{FlagJuvenile, keywordPattern("juvenile", "child abuse", "missing child", "runaway", "dcpp", "dyfs")},
+	{FlagSexualAssault, keywordPattern("sexual assault", "rape", "sex offense")},
+	{FlagDomesticViolence, keywordPattern("domestic violence", "domestic dispute", "restraining order")},
+}
+
+func keywordPattern(words ...string) *regexp.Regexp {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// CallFlags returns the taxonomy flags matched by a call's type and
+// transcript, in taxonomy order.
+func CallFlags(callType, transcript string) []string {
+	text := callType + "\n" + transcript
+	var flags []string
+	for _, entry := range callTaxonomy {
+		if entry.pattern.MatchString(text) {
+			flags = append(flags, entry.flag)
+		}
+	}
+	return flags
+}
+
+var profanityPattern = regexp.MustCompile(`(?i)\b(?:fuck\w*|motherfuck\w*|shit(?:s|ty|ting|head)?|bullshit|bitch\w*|asshole\w*|bastard\w*|cunt\w*|goddamn\w*|damn\w*)\b`)
+
+// MaskProfanity keeps the first letter of each profane word and stars the
+// rest, so transcripts stay readable without printing the word.
+func MaskProfanity(text string) string {
+	return profanityPattern.ReplaceAllStringFunc(text, func(word string) string {
+		return word[:1] + strings.Repeat("*", len(word)-1)
+	})
+}
@@ -0,0 +1,88 @@
+package formatting
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Term is a dictionary entry for transcript normalization: a local radio
+// code or abbreviation ("10-50", "BLS", "PIAA") and the text it is written
+// out as.
+type Term struct {
+	Term      string
+	Expansion string
+}
+
+// NormalizeTranscriptWith expands terms in raw and then normalizes it like
+// NormalizeTranscript. Expanding first keeps the generic street and highway
+// rules from rewriting the codes.
+func NormalizeTranscriptWith(raw string, terms []Term) string {
+	expanded, _ := ExpandTerms(raw, terms)
+	return NormalizeTranscript(expanded)
+}
+
+// ExpandTerms replaces every whole-word occurrence of a term, ignoring case,
+// with its expansion and returns the text and the terms it replaced, in
+// dictionary order. Letters, digits and hyphens make up words, so "10-50"
+// does not match inside "110-50". Where terms overlap the longest wins, and
+// expansions are not searched again.
+func ExpandTerms(text string, terms []Term) (string, []string) {
+	sorted := make([]Term, 0, len(terms))
+	for _, t := range terms {
+		if t.Term = strings.TrimSpace(t.Term); t.Term != "" {
+			sorted = append(sorted, t)
+		}
+	}
+	if len(sorted) == 0 || text == "" {
+		return text, nil
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Term) > len(sorted[j].Term) })
+	used := make(map[string]bool)
+	var b strings.Builder
+	prev := rune(' ')
+	for i := 0; i < len(text); {
+		if !isTermRune(prev) {
+			if t, ok := termAt(text[i:], sorted); ok {
+				b.WriteString(t.Expansion)
+				used[t.Term] = true
+				i += len(t.Term)
+				prev, _ = utf8.DecodeLastRuneInString(t.Term)
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		b.WriteString(text[i : i+size])
+		prev = r
+		i += size
+	}
+	var matched []string
+	for _, t := range terms {
+		if term := strings.TrimSpace(t.Term); used[term] {
+			matched = append(matched, term)
+			delete(used, term)
+		}
+	}
+	return b.String(), matched
+}
+
+// termAt returns the first term text starts with that ends on a word
+// boundary.
+func termAt(text string, terms []Term) (Term, bool) {
+	for _, t := range terms {
+		n := len(t.Term)
+		if n > len(text) || !strings.EqualFold(text[:n], t.Term) {
+			continue
+		}
+		if next, _ := utf8.DecodeRuneInString(text[n:]); n < len(text) && isTermRune(next) {
+			continue
+		}
+		return t, true
+	}
+	return Term{}, false
+}
+
+func isTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-'
+}
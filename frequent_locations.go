@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alert_framework/formatting"
+)
+
+const (
+	frequentLocationRefresh  = 15 * time.Minute
+	frequentLocationCacheTTL = time.Minute
+	defaultFrequentLocLimit  = 50
+	maxFrequentLocLimit      = 1000
+)
+
+// frequentLocation is a row of the frequent_locations summary: a location
+// label with at least two calls in the last REPEAT_LOCATION_DAYS days.
+type frequentLocation struct {
+	Key         string    `json:"-"`
+	Label       string    `json:"location_label"`
+	Calls       int       `json:"calls"`
+	FirstCallAt time.Time `json:"first_call_at"`
+	LastCallAt  time.Time `json:"last_call_at"`
+}
+
+// repeatLocation is attached to API responses for calls at a location that
+// has reached REPEAT_LOCATION_CALLS.
+type repeatLocation struct {
+	Calls int    `json:"calls"`
+	Days  int    `json:"days"`
+	Note  string `json:"note"`
+}
+
+// frequentLocationCache holds the repeat locations for API responses so a call
+// list costs one query, not one per call.
+type frequentLocationCache struct {
+	mu       sync.Mutex
+	loadedAt time.Time
+	byKey    map[string]frequentLocation
+}
+
+func migrateAddFrequentLocations(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS frequent_locations (
+    location_key TEXT PRIMARY KEY,
+    location_label TEXT NOT NULL,
+    calls INTEGER NOT NULL,
+    first_call_at DATETIME NOT NULL,
+    last_call_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_frequent_locations_calls ON frequent_locations(calls);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+// frequentLocationKey is how calls at the same place are grouped. Labels come
+// from the same formatter, so case and spacing are the only differences.
+func frequentLocationKey(label string) string {
+	return strings.ToLower(strings.TrimSpace(label))
+}
+
+// countFrequentLocations groups completed, non-duplicate calls since the
+// window start by location. keyFilter limits the scan to one location when
+// set. Labels that are only the call's town say nothing about the address and
+// are not counted.
+func (s *server) countFrequentLocations(ctx context.Context, since time.Time, keyFilter string) (map[string]*frequentLocation, error) {
+	query := `SELECT filename, location_label, call_timestamp, created_at FROM transcriptions
+WHERE location_label IS NOT NULL AND trim(location_label) != '' AND status = ? AND duplicate_of IS NULL AND deleted_at IS NULL AND effective_ts >= ?`
+	args := []interface{}{statusDone, since.UTC()}
+	if keyFilter != "" {
+		query += ` AND lower(trim(location_label)) = ?`
+		args = append(args, keyFilter)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]*frequentLocation{}
+	for rows.Next() {
+		var filename, label string
+		var callTS, created sql.NullTime
+		if err := rows.Scan(&filename, &label, &callTS, &created); err != nil {
+			return nil, err
+		}
+		at := created.Time
+		if callTS.Valid {
+			at = callTS.Time
+		}
+		label = strings.TrimSpace(label)
+		if meta, err := s.parseCallMetadata(filename, ""); err == nil && strings.EqualFold(label, meta.TownDisplay) {
+			continue
+		}
+		key := frequentLocationKey(label)
+		loc, ok := out[key]
+		if !ok {
+			loc = &frequentLocation{Key: key, Label: label, FirstCallAt: at, LastCallAt: at}
+			out[key] = loc
+		}
+		loc.Calls++
+		if at.Before(loc.FirstCallAt) {
+			loc.FirstCallAt = at
+		}
+		if at.After(loc.LastCallAt) {
+			loc.LastCallAt = at
+			loc.Label = label
+		}
+	}
+	return out, rows.Err()
+}
+
+func (s *server) repeatWindowStart(now time.Time) time.Time {
+	return now.AddDate(0, 0, -s.cfg.RepeatLocationDays)
+}
+
+// rebuildFrequentLocations recounts the whole summary, which also drops calls
+// that have aged out of the window.
+func (s *server) rebuildFrequentLocations(ctx context.Context) error {
+	now := time.Now().UTC()
+	counts, err := s.countFrequentLocations(ctx, s.repeatWindowStart(now), "")
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM frequent_locations`); err != nil {
+		return err
+	}
+	for _, loc := range counts {
+		if loc.Calls < 2 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO frequent_locations (location_key, location_label, calls, first_call_at, last_call_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			loc.Key, loc.Label, loc.Calls, loc.FirstCallAt, loc.LastCallAt, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// refreshFrequentLocation recounts one location, right after a call there
+// completes, and returns its call count in the window.
+func (s *server) refreshFrequentLocation(ctx context.Context, label string) (int, error) {
+	key := frequentLocationKey(label)
+	now := time.Now().UTC()
+	counts, err := s.countFrequentLocations(ctx, s.repeatWindowStart(now), key)
+	if err != nil {
+		return 0, err
+	}
+	loc := counts[key]
+	if loc == nil || loc.Calls < 2 {
+		_, err := execWithRetry(s.db, `DELETE FROM frequent_locations WHERE location_key = ?`, key)
+		if loc == nil {
+			return 0, err
+		}
+		return loc.Calls, err
+	}
+	_, err = execWithRetry(s.db, `INSERT INTO frequent_locations (location_key, location_label, calls, first_call_at, last_call_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(location_key) DO UPDATE SET location_label = excluded.location_label, calls = excluded.calls, first_call_at = excluded.first_call_at, last_call_at = excluded.last_call_at, updated_at = excluded.updated_at`,
+		loc.Key, loc.Label, loc.Calls, loc.FirstCallAt, loc.LastCallAt, now)
+	return loc.Calls, err
+}
+
+// repeatLocationNote is the alert line for a call at a location that has
+// reached REPEAT_LOCATION_CALLS, e.g. "5th call at this address in 30 days".
+func (s *server) repeatLocationNote(location *locationGuess) string {
+	if s.cfg.RepeatLocationCalls <= 0 || location == nil || strings.TrimSpace(location.Label) == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	calls, err := s.refreshFrequentLocation(ctx, location.Label)
+	if err != nil {
+		log.Printf("frequent location refresh failed for %q: %v", location.Label, err)
+		return ""
+	}
+	if calls < s.cfg.RepeatLocationCalls {
+		return ""
+	}
+	return fmt.Sprintf("%s call at this address in %d days", formatting.Ordinal(calls), s.cfg.RepeatLocationDays)
+}
+
+// repeatLocationFor reports a call's location as a repeat location for API
+// responses, from a summary reloaded at most once a minute.
+func (s *server) repeatLocationFor(label *string) *repeatLocation {
+	if s.cfg.RepeatLocationCalls <= 0 || label == nil || strings.TrimSpace(*label) == "" {
+		return nil
+	}
+	c := &s.frequentLocs
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byKey == nil || time.Since(c.loadedAt) > frequentLocationCacheTTL {
+		locs, err := s.loadFrequentLocations(s.cfg.RepeatLocationCalls, maxFrequentLocLimit)
+		if err != nil {
+			log.Printf("frequent location lookup failed: %v", err)
+			return nil
+		}
+		c.byKey = make(map[string]frequentLocation, len(locs))
+		for _, loc := range locs {
+			c.byKey[loc.Key] = loc
+		}
+		c.loadedAt = time.Now()
+	}
+	loc, ok := c.byKey[frequentLocationKey(*label)]
+	if !ok {
+		return nil
+	}
+	return &repeatLocation{
+		Calls: loc.Calls,
+		Days:  s.cfg.RepeatLocationDays,
+		Note:  fmt.Sprintf("%d calls at this address in %d days", loc.Calls, s.cfg.RepeatLocationDays),
+	}
+}
+
+func (s *server) loadFrequentLocations(minCalls, limit int) ([]frequentLocation, error) {
+	rows, err := queryWithRetry(s.db, `SELECT location_key, location_label, calls, first_call_at, last_call_at FROM frequent_locations WHERE calls >= ? ORDER BY calls DESC, last_call_at DESC LIMIT ?`, minCalls, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []frequentLocation{}
+	for rows.Next() {
+		var loc frequentLocation
+		if err := rows.Scan(&loc.Key, &loc.Label, &loc.Calls, &loc.FirstCallAt, &loc.LastCallAt); err != nil {
+			return nil, err
+		}
+		out = append(out, loc)
+	}
+	return out, rows.Err()
+}
+
+// startFrequentLocationRefresher rebuilds frequent_locations at startup and
+// every 15 minutes, so locations whose calls age out of the window drop off.
+func (s *server) startFrequentLocationRefresher(ctx context.Context) {
+	if s.cfg.RepeatLocationCalls <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(frequentLocationRefresh)
+		defer ticker.Stop()
+		for {
+			if err := s.rebuildFrequentLocations(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("frequent location rebuild failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// handleFrequentLocations serves GET /api/stats/frequent-locations[?min_calls=N][&limit=50]:
+// the locations with the most calls in the last REPEAT_LOCATION_DAYS days.
+func (s *server) handleFrequentLocations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cfg.RepeatLocationCalls <= 0 {
+		http.Error(w, "repeat-location tracking disabled", http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	minCalls := s.cfg.RepeatLocationCalls
+	if raw := strings.TrimSpace(q.Get("min_calls")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 2 {
+			http.Error(w, "min_calls must be at least 2", http.StatusBadRequest)
+			return
+		}
+		minCalls = v
+	}
+	limit := defaultFrequentLocLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxFrequentLocLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	locs, err := s.loadFrequentLocations(minCalls, limit)
+	if err != nil {
+		log.Printf("frequent location list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"days": s.cfg.RepeatLocationDays, "min_calls": minCalls, "locations": locs})
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+)
+
+// geocodeCorrection is a location a reviewer fixed: the transcript phrase the
+// geocoder got wrong and the address and/or coordinates it should have given.
+// Later calls in the same town that mention the phrase use the correction
+// instead of geocoding it again.
+type geocodeCorrection struct {
+	ID            int64      `json:"id"`
+	Phrase        string     `json:"phrase"`
+	Town          string     `json:"town,omitempty"`
+	Address       string     `json:"address,omitempty"`
+	Latitude      *float64   `json:"latitude,omitempty"`
+	Longitude     *float64   `json:"longitude,omitempty"`
+	Filename      string     `json:"filename,omitempty"`
+	CreatedBy     string     `json:"created_by,omitempty"`
+	Hits          int        `json:"hits"`
+	LastMatchedAt *time.Time `json:"last_matched_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	key           string
+}
+
+func migrateAddGeocodeCorrections(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS geocode_corrections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    phrase TEXT NOT NULL,
+    phrase_key TEXT NOT NULL,
+    town TEXT NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    latitude REAL NULL,
+    longitude REAL NULL,
+    filename TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    hits INTEGER NOT NULL DEFAULT 0,
+    last_matched_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE(phrase_key, town)
+);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+// locationPhrase is the part of a transcript the location parser reads as an
+// address, or "" when it finds none.
+func locationPhrase(normalized string) string {
+	parsed, err := formatting.ParseLocationFromTranscript(normalized)
+	if err != nil || parsed == nil {
+		return ""
+	}
+	return strings.TrimSpace(parsed.RawText)
+}
+
+// recordGeocodeCorrection stores a reviewer's location fix for t. phrase
+// names the transcript text that was misread; when empty it is taken from
+// the call's normalized transcript. A fix for a phrase already corrected in
+// the same town replaces the earlier one. Calls with no usable phrase are
+// skipped.
+func (s *server) recordGeocodeCorrection(ctx context.Context, t transcription, phrase string, c reviewCorrections, actor string) (*geocodeCorrection, error) {
+	phrase = strings.TrimSpace(phrase)
+	if phrase == "" && t.NormalizedTranscript != nil {
+		phrase = locationPhrase(*t.NormalizedTranscript)
+	}
+	key := formatting.AliasKey(phrase)
+	if key == "" {
+		return nil, nil
+	}
+	address := derefString(c.LocationLabel, "")
+	if address == "" && c.Latitude != nil {
+		// Only the coordinates were wrong; keep the label the call had.
+		address = derefString(t.LocationLabel, "")
+	}
+	if address == "" && c.Latitude == nil {
+		return nil, nil
+	}
+	var town string
+	if meta, err := s.parseCallMetadata(t.Filename, ""); err == nil {
+		town = meta.TownDisplay
+	}
+	now := time.Now().UTC()
+	if _, err := execWithRetry(s.db, `INSERT INTO geocode_corrections (phrase, phrase_key, town, address, latitude, longitude, filename, created_by, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(phrase_key, town) DO UPDATE SET phrase = excluded.phrase, address = excluded.address, latitude = excluded.latitude, longitude = excluded.longitude,
+    filename = excluded.filename, created_by = excluded.created_by, updated_at = excluded.updated_at`,
+		phrase, key, town, address, c.Latitude, c.Longitude, t.Filename, actor, now, now); err != nil {
+		return nil, err
+	}
+	corr, err := scanGeocodeCorrection(s.db.QueryRowContext(ctx, `SELECT `+geocodeCorrectionColumns+` FROM geocode_corrections WHERE phrase_key = ? AND town = ?`, key, town))
+	if err != nil {
+		return nil, err
+	}
+	return &corr, nil
+}
+
+const geocodeCorrectionColumns = `id, phrase, phrase_key, town, address, latitude, longitude, filename, created_by, hits, last_matched_at, created_at, updated_at`
+
+func scanGeocodeCorrection(row interface{ Scan(...interface{}) error }) (geocodeCorrection, error) {
+	var c geocodeCorrection
+	var lat, lng sql.NullFloat64
+	var lastMatched sql.NullTime
+	if err := row.Scan(&c.ID, &c.Phrase, &c.key, &c.Town, &c.Address, &lat, &lng, &c.Filename, &c.CreatedBy, &c.Hits, &lastMatched, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return c, err
+	}
+	c.Latitude = nullFloatPtr(lat)
+	c.Longitude = nullFloatPtr(lng)
+	if lastMatched.Valid {
+		c.LastMatchedAt = &lastMatched.Time
+	}
+	return c, nil
+}
+
+func (s *server) loadGeocodeCorrections(ctx context.Context) ([]geocodeCorrection, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+geocodeCorrectionColumns+` FROM geocode_corrections ORDER BY phrase, town`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	corrections := []geocodeCorrection{}
+	for rows.Next() {
+		c, err := scanGeocodeCorrection(rows)
+		if err != nil {
+			return nil, err
+		}
+		corrections = append(corrections, c)
+	}
+	return corrections, rows.Err()
+}
+
+// matchGeocodeCorrection finds the correction a transcript's phrase matches.
+// Corrections are tied to the town of the call they came from, so only those
+// for the call's town (or with no town) apply; the longest phrase wins.
+func (s *server) matchGeocodeCorrection(ctx context.Context, normalized string, meta formatting.CallMetadata) *geocodeCorrection {
+	corrections, err := s.loadGeocodeCorrections(ctx)
+	if err != nil {
+		log.Printf("geocode correction lookup failed: %v", err)
+		return nil
+	}
+	if len(corrections) == 0 {
+		return nil
+	}
+	text := formatting.AliasKey(normalized)
+	town := formatting.AliasKey(meta.TownDisplay)
+	var best *geocodeCorrection
+	for i := range corrections {
+		c := &corrections[i]
+		if !formatting.HasAliasKey(text, c.key) {
+			continue
+		}
+		if c.Town != "" && formatting.AliasKey(c.Town) != town {
+			continue
+		}
+		if best == nil || len(c.key) > len(best.key) {
+			best = c
+		}
+	}
+	if best != nil {
+		if _, err := execWithRetry(s.db, `UPDATE geocode_corrections SET hits = hits + 1, last_matched_at = ? WHERE id = ?`, time.Now().UTC(), best.ID); err != nil {
+			log.Printf("geocode correction hit update failed: %v", err)
+		}
+	}
+	return best
+}
+
+// correctionLocation turns a matched correction into a finished guess when
+// it has coordinates, otherwise into the corrected address for geocoding.
+func correctionLocation(c *geocodeCorrection, meta formatting.CallMetadata) (*locationGuess, *formatting.ParsedLocation) {
+	if c.Latitude != nil {
+		label := c.Address
+		if label == "" {
+			label = c.Phrase
+		}
+		return &locationGuess{Label: label, Latitude: *c.Latitude, Longitude: *c.Longitude, Precision: "correction", Source: "correction"}, nil
+	}
+	parsed, err := formatting.ParseLocationFromTranscript(c.Address)
+	if err != nil || parsed == nil || parsed.Street == "" {
+		parsed = &formatting.ParsedLocation{Street: c.Address, RawText: c.Address}
+	}
+	if parsed.Municipality == "" {
+		parsed.Municipality = c.Town
+		if parsed.Municipality == "" {
+			parsed.Municipality = meta.TownDisplay
+		}
+	}
+	return nil, parsed
+}
+
+// handleGeocodeCorrections lists corrections (GET /api/admin/geocode-corrections)
+// or deletes one (DELETE /api/admin/geocode-corrections/{id}). Admin only.
+// Corrections are created by resolving a review with a location fix.
+func (s *server) handleGeocodeCorrections(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/geocode-corrections"), "/")
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		corrections, err := s.loadGeocodeCorrections(r.Context())
+		if err != nil {
+			log.Printf("geocode correction list failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"corrections": corrections})
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	corr, err := scanGeocodeCorrection(s.db.QueryRowContext(r.Context(), `SELECT `+geocodeCorrectionColumns+` FROM geocode_corrections WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("geocode correction load failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := execWithRetry(s.db, `DELETE FROM geocode_corrections WHERE id = ?`, id); err != nil {
+		log.Printf("geocode correction delete failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	noteAudit(r, "geocode_correction.delete", corr.Phrase, corr, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
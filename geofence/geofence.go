@@ -0,0 +1,226 @@
+// Package geofence decides whether a point lies inside the service area. The
+// area is one or more named regions, each a GeoJSON Polygon or MultiPolygon,
+// and a point is inside when any region contains it. A bounding box lets in
+// whole corners of the neighbouring counties; a polygon does not.
+package geofence
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// sussexGeoJSON is a simplified outline of Sussex County, NJ, traced to
+// within a few hundred metres of the county line. Deployments that need the
+// exact line should load the official boundary with Load.
+//
+//go:embed sussex.geojson
+var sussexGeoJSON string
+
+// ring is a closed loop of [lng, lat] points.
+type ring [][2]float64
+
+// polygon is an outer ring followed by its holes.
+type polygon []ring
+
+// Region is one named area of the service area.
+type Region struct {
+	Name     string
+	polygons []polygon
+}
+
+// Boundary is the service area.
+type Boundary struct {
+	regions                        []Region
+	minLng, minLat, maxLng, maxLat float64
+}
+
+// SussexCounty returns the built-in Sussex County boundary.
+func SussexCounty() *Boundary {
+	b, err := Parse(strings.NewReader(sussexGeoJSON))
+	if err != nil {
+		panic("geofence: built-in boundary: " + err.Error())
+	}
+	return b
+}
+
+// Load reads a boundary from the GeoJSON file at path. See Parse.
+func Load(path string) (*Boundary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+type feature struct {
+	Properties struct {
+		Name string `json:"name"`
+	} `json:"properties"`
+	Geometry *geometry `json:"geometry"`
+}
+
+type document struct {
+	Type        string          `json:"type"`
+	Features    []feature       `json:"features"`
+	Properties  json.RawMessage `json:"properties"`
+	Geometry    *geometry       `json:"geometry"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Parse reads a boundary from GeoJSON: a FeatureCollection (one region per
+// feature, named by its "name" property), a single Feature, or a bare
+// Polygon or MultiPolygon geometry.
+func Parse(r io.Reader) (*Boundary, error) {
+	var doc document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode geojson: %w", err)
+	}
+	var features []feature
+	switch doc.Type {
+	case "FeatureCollection":
+		features = doc.Features
+	case "Feature":
+		var f feature
+		f.Geometry = doc.Geometry
+		if len(doc.Properties) > 0 {
+			_ = json.Unmarshal(doc.Properties, &f.Properties)
+		}
+		features = []feature{f}
+	case "Polygon", "MultiPolygon":
+		features = []feature{{Geometry: &geometry{Type: doc.Type, Coordinates: doc.Coordinates}}}
+	default:
+		return nil, fmt.Errorf("unsupported geojson type %q", doc.Type)
+	}
+	b := &Boundary{minLng: math.Inf(1), minLat: math.Inf(1), maxLng: math.Inf(-1), maxLat: math.Inf(-1)}
+	for i, f := range features {
+		if f.Geometry == nil {
+			return nil, fmt.Errorf("feature %d: missing geometry", i)
+		}
+		polys, err := parseGeometry(*f.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+		name := strings.TrimSpace(f.Properties.Name)
+		if name == "" {
+			name = fmt.Sprintf("region %d", i+1)
+		}
+		b.regions = append(b.regions, Region{Name: name, polygons: polys})
+		for _, p := range polys {
+			for _, pt := range p[0] {
+				b.minLng, b.maxLng = math.Min(b.minLng, pt[0]), math.Max(b.maxLng, pt[0])
+				b.minLat, b.maxLat = math.Min(b.minLat, pt[1]), math.Max(b.maxLat, pt[1])
+			}
+		}
+	}
+	if len(b.regions) == 0 {
+		return nil, errors.New("no regions in geojson")
+	}
+	return b, nil
+}
+
+func parseGeometry(g geometry) ([]polygon, error) {
+	var polys [][]ring
+	switch g.Type {
+	case "Polygon":
+		var p []ring
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return nil, fmt.Errorf("polygon coordinates: %w", err)
+		}
+		polys = [][]ring{p}
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &polys); err != nil {
+			return nil, fmt.Errorf("multipolygon coordinates: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("geometry must be a Polygon or MultiPolygon, not %q", g.Type)
+	}
+	out := make([]polygon, 0, len(polys))
+	for _, p := range polys {
+		if len(p) == 0 {
+			return nil, errors.New("polygon without rings")
+		}
+		for _, rg := range p {
+			if len(rg) < 4 {
+				return nil, errors.New("ring needs at least 4 positions")
+			}
+		}
+		out = append(out, polygon(p))
+	}
+	return out, nil
+}
+
+// Contains reports whether lat, lng falls inside any region. The null island
+// point 0, 0 is never inside: it is what a missing coordinate decodes to.
+func (b *Boundary) Contains(lat, lng float64) bool {
+	return b.RegionAt(lat, lng) != ""
+}
+
+// RegionAt returns the name of the first region containing lat, lng, or "".
+func (b *Boundary) RegionAt(lat, lng float64) string {
+	if b == nil || (lat == 0 && lng == 0) {
+		return ""
+	}
+	if lng < b.minLng || lng > b.maxLng || lat < b.minLat || lat > b.maxLat {
+		return ""
+	}
+	for _, rg := range b.regions {
+		for _, p := range rg.polygons {
+			if p.contains(lng, lat) {
+				return rg.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Regions returns the region names in file order.
+func (b *Boundary) Regions() []string {
+	names := make([]string, len(b.regions))
+	for i, rg := range b.regions {
+		names[i] = rg.Name
+	}
+	return names
+}
+
+// BBox returns the bounding box of all regions as minLng, minLat, maxLng,
+// maxLat, the order geocoder bbox parameters take.
+func (b *Boundary) BBox() (minLng, minLat, maxLng, maxLat float64) {
+	return b.minLng, b.minLat, b.maxLng, b.maxLat
+}
+
+func (p polygon) contains(x, y float64) bool {
+	if !p[0].contains(x, y) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.contains(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// contains is the even-odd ray casting test.
+func (r ring) contains(x, y float64) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		xi, yi := r[i][0], r[i][1]
+		xj, yj := r[j][0], r[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
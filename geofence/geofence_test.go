@@ -0,0 +1,91 @@
+package geofence
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSussexCounty(t *testing.T) {
+	b := SussexCounty()
+	cases := []struct {
+		name     string
+		lat, lng float64
+		want     bool
+	}{
+		{"Newton", 41.058, -74.752, true},
+		{"Vernon", 41.198, -74.483, true},
+		{"Hopatcong", 40.952, -74.660, true},
+		{"Montague", 41.290, -74.760, true},
+		// Inside the old bounding box but in a neighbouring county or state.
+		{"Jefferson (Morris)", 41.020, -74.530, false},
+		{"Hackettstown (Warren)", 40.853, -74.829, false},
+		{"Milford, PA", 41.322, -74.802, false},
+		{"Warwick, NY", 41.256, -74.360, false},
+		{"null island", 0, 0, false},
+	}
+	for _, c := range cases {
+		if got := b.Contains(c.lat, c.lng); got != c.want {
+			t.Errorf("%s: Contains(%v, %v) = %v, want %v", c.name, c.lat, c.lng, got, c.want)
+		}
+	}
+}
+
+const twoRegions = `{"type":"FeatureCollection","features":[
+{"type":"Feature","properties":{"name":"North"},"geometry":{"type":"Polygon","coordinates":[
+ [[0,10],[10,10],[10,20],[0,20],[0,10]],
+ [[4,14],[6,14],[6,16],[4,16],[4,14]]]}},
+{"type":"Feature","properties":{},"geometry":{"type":"MultiPolygon","coordinates":[
+ [[[20,0],[30,0],[30,5],[20,5],[20,0]]],
+ [[[40,0],[45,0],[45,5],[40,5],[40,0]]]]}}
+]}`
+
+func TestParseRegionsAndHoles(t *testing.T) {
+	b, err := Parse(strings.NewReader(twoRegions))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := b.RegionAt(12, 2); got != "North" {
+		t.Errorf("RegionAt(12, 2) = %q, want North", got)
+	}
+	if b.Contains(15, 5) {
+		t.Error("point in a hole should be outside")
+	}
+	if got := b.RegionAt(2, 42); got != "region 2" {
+		t.Errorf("RegionAt(2, 42) = %q, want region 2", got)
+	}
+	if b.Contains(2, 35) {
+		t.Error("point between polygons should be outside")
+	}
+	minLng, minLat, maxLng, maxLat := b.BBox()
+	if minLng != 0 || minLat != 0 || maxLng != 45 || maxLat != 20 {
+		t.Errorf("BBox = %v %v %v %v", minLng, minLat, maxLng, maxLat)
+	}
+}
+
+func TestParseBareGeometryAndFeature(t *testing.T) {
+	for _, doc := range []string{
+		`{"type":"Polygon","coordinates":[[[0,0],[2,0],[2,2],[0,2],[0,0]]]}`,
+		`{"type":"Feature","properties":{"name":"Square"},"geometry":{"type":"Polygon","coordinates":[[[0,0],[2,0],[2,2],[0,2],[0,0]]]}}`,
+	} {
+		b, err := Parse(strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("Parse(%s): %v", doc, err)
+		}
+		if !b.Contains(1, 1) {
+			t.Errorf("Parse(%s): expected (1, 1) inside", doc)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, doc := range []string{
+		`{"type":"Point","coordinates":[0,0]}`,
+		`{"type":"FeatureCollection","features":[]}`,
+		`{"type":"Polygon","coordinates":[[[0,0],[1,1],[0,0]]]}`,
+		`not json`,
+	} {
+		if _, err := Parse(strings.NewReader(doc)); err == nil {
+			t.Errorf("Parse(%s) succeeded, want error", doc)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alert_framework/alertpb"
+	"alert_framework/errcode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// grpcMaxAudioBytes caps recordings sent inline to SubmitCall, which
+	// arrive as one message; larger ones go through /api/uploads.
+	grpcMaxAudioBytes   = 64 << 20
+	grpcStreamPeriod    = time.Second
+	grpcStreamBatch     = 100
+	grpcDefaultRollups  = 200
+	grpcAdminTokenField = "x-admin-token"
+)
+
+// grpcAPI serves alertpb.Alerts from the same store and queue as the JSON
+// endpoints, with the same visibility rules.
+type grpcAPI struct {
+	alertpb.UnimplementedAlertsServer
+	s *server
+}
+
+// startGRPC serves the gRPC API on GRPC_PORT until the returned server is
+// stopped.
+func (s *server) startGRPC() (*grpc.Server, error) {
+	ln, err := net.Listen("tcp", s.cfg.GRPCPort)
+	if err != nil {
+		return nil, err
+	}
+	srv := grpc.NewServer(grpc.MaxRecvMsgSize(grpcMaxAudioBytes + 1<<20))
+	alertpb.RegisterAlertsServer(srv, &grpcAPI{s: s})
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			log.Printf("grpc server stopped: %v", err)
+		}
+	}()
+	log.Printf("grpc listening on %s", ln.Addr())
+	return srv, nil
+}
+
+// grpcIsAdmin reports whether the call carries the admin token as
+// x-admin-token metadata, the gRPC counterpart of isAdminRequest.
+func grpcIsAdmin(ctx context.Context) bool {
+	token := strings.TrimSpace(os.Getenv("ADMIN_TOKEN"))
+	if !adminEnabled() || token == "" {
+		return false
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(grpcAdminTokenField)
+	return len(values) > 0 && values[0] == token
+}
+
+func (a *grpcAPI) SubmitCall(ctx context.Context, req *alertpb.SubmitCallRequest) (*alertpb.SubmitCallResponse, error) {
+	s := a.s
+	if !grpcIsAdmin(ctx) {
+		return nil, status.Error(codes.PermissionDenied, "admin token required")
+	}
+	if !s.canEnqueue() {
+		return nil, status.Error(codes.Unavailable, "queue disabled")
+	}
+	filename := strings.TrimSpace(req.GetFilename())
+	if filename == "" || remoteFilename(filename) != filename {
+		return nil, status.Error(codes.InvalidArgument, "filename must be an audio filename without a path")
+	}
+	audio := req.GetAudio()
+	if len(audio) > 0 {
+		s.remoteFiles.Store(filename, struct{}{})
+		defer s.remoteFiles.Delete(filename)
+		if err := s.storeSubmittedCall(ctx, filename, audio); err != nil {
+			return nil, err
+		}
+	} else if !fileExists(filepath.Join(s.cfg.CallsDir, filename)) {
+		return nil, status.Error(codes.NotFound, "no recording with that filename; send audio to submit a new one")
+	}
+	opts, _ := s.defaultOptions()
+	// A new recording is queued like an upload; a bare filename re-runs the
+	// call like POST /api/transcription.
+	if !s.queueJob("grpc", filename, req.GetNotify(), len(audio) == 0, opts) {
+		return nil, status.Error(codes.Unavailable, "call could not be queued")
+	}
+	return &alertpb.SubmitCallResponse{Filename: filename, Status: statusQueued}, nil
+}
+
+// storeSubmittedCall validates audio and moves it into CALLS_DIR as filename.
+func (s *server) storeSubmittedCall(ctx context.Context, filename string, audio []byte) error {
+	if len(audio) > grpcMaxAudioBytes {
+		return status.Errorf(codes.InvalidArgument, "audio is larger than %d MB; use /api/uploads", grpcMaxAudioBytes>>20)
+	}
+	dest := filepath.Join(s.cfg.CallsDir, filename)
+	if fileExists(dest) {
+		return status.Error(codes.AlreadyExists, errUploadExists.Error())
+	}
+	if err := os.MkdirAll(s.uploadDir(), 0o755); err != nil {
+		log.Printf("upload dir %s: %v", s.uploadDir(), err)
+		return status.Error(codes.Internal, "storage error")
+	}
+	path := s.uploadPath("grpc-" + newUploadID())
+	if err := os.WriteFile(path, audio, 0o644); err != nil {
+		log.Printf("grpc submit of %s failed: %v", filename, err)
+		return status.Error(codes.Internal, "storage error")
+	}
+	defer os.Remove(path)
+	if err := s.validateAudio(ctx, path, int64(len(audio))); err != nil {
+		if errcode.Of(err) == errcode.InvalidAudio {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if fileExists(dest) {
+		return status.Error(codes.AlreadyExists, errUploadExists.Error())
+	}
+	if err := moveFile(path, dest); err != nil {
+		log.Printf("grpc submit of %s failed: %v", filename, err)
+		return status.Error(codes.Internal, "storage error")
+	}
+	log.Printf("grpc submit complete: %s (%d bytes)", filename, len(audio))
+	return nil
+}
+
+func (a *grpcAPI) GetTranscription(ctx context.Context, req *alertpb.GetTranscriptionRequest) (*alertpb.Transcription, error) {
+	s := a.s
+	filename := strings.TrimSpace(req.GetFilename())
+	if filename == "" || filepath.Base(filename) != filename {
+		return nil, status.Error(codes.InvalidArgument, "filename required")
+	}
+	t, err := s.getTranscription(filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "call not found")
+	}
+	if err != nil {
+		log.Printf("grpc fetch transcription %s failed: %v", filename, err)
+		return nil, status.Error(codes.Internal, "db error")
+	}
+	admin := grpcIsAdmin(ctx)
+	if !admin && s.isRestricted(*t) {
+		return nil, status.Error(codes.NotFound, "call not found")
+	}
+	return transcriptionMessage(s.responseView(admin, *t, s.resolveBaseURL(nil))), nil
+}
+
+// StreamCompletions polls the database like /api/drafts/stream, so calls
+// finished by a separate worker process are sent too. updated_at only has
+// second precision, so each poll looks a second back and skips what it has
+// already sent.
+func (a *grpcAPI) StreamCompletions(req *alertpb.StreamCompletionsRequest, stream alertpb.Alerts_StreamCompletionsServer) error {
+	s := a.s
+	ctx := stream.Context()
+	admin := grpcIsAdmin(ctx)
+	cursor := time.Now().UTC()
+	if req.GetSince() != nil {
+		cursor = req.GetSince().AsTime()
+	}
+	sent := map[string]time.Time{}
+	ticker := time.NewTicker(grpcStreamPeriod)
+	defer ticker.Stop()
+	for {
+		finished, err := s.finishedSince(ctx, cursor.Add(-time.Second), grpcStreamBatch)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("grpc completion stream query failed: %v", err)
+			return status.Error(codes.Internal, "db error")
+		}
+		base := s.resolveBaseURL(nil)
+		for _, t := range finished {
+			if at, ok := sent[t.Filename]; ok && at.Equal(t.UpdatedAt) {
+				continue
+			}
+			sent[t.Filename] = t.UpdatedAt
+			if t.UpdatedAt.After(cursor) {
+				cursor = t.UpdatedAt
+			}
+			if !admin && s.isRestricted(t) {
+				continue
+			}
+			if err := stream.Send(transcriptionMessage(s.responseView(admin, t, base))); err != nil {
+				return err
+			}
+		}
+		for filename, at := range sent {
+			if at.Before(cursor.Add(-2 * time.Second)) {
+				delete(sent, filename)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.shutdown:
+			return status.Error(codes.Unavailable, "server shutting down")
+		case <-ticker.C:
+		}
+	}
+}
+
+// finishedSince returns calls that finished, done or failed, after since,
+// oldest first.
+func (s *server) finishedSince(ctx context.Context, since time.Time, limit int) ([]transcription, error) {
+	rows, err := s.db.QueryContext(ctx, selectTranscriptions+` WHERE status IN (?, ?) AND deleted_at IS NULL AND updated_at > ? ORDER BY updated_at LIMIT ?`,
+		statusDone, statusError, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []transcription
+	for rows.Next() {
+		var t transcription
+		if err := scanTranscription(rows, &t); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (a *grpcAPI) ListRollups(ctx context.Context, req *alertpb.ListRollupsRequest) (*alertpb.ListRollupsResponse, error) {
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = grpcDefaultRollups
+	}
+	var from, to time.Time
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime()
+	}
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime()
+	}
+	rollups, err := a.s.listRollups(from, to, strings.TrimSpace(req.GetStatus()), strings.ToLower(strings.TrimSpace(req.GetState())), limit)
+	if err != nil {
+		log.Printf("grpc rollups query failed: %v", err)
+		return nil, status.Error(codes.Internal, "db error")
+	}
+	resp := &alertpb.ListRollupsResponse{}
+	for _, r := range rollups {
+		resp.Rollups = append(resp.Rollups, rollupMessage(r))
+	}
+	return resp, nil
+}
+
+func timestampOrNil(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func transcriptionMessage(r transcriptionResponse) *alertpb.Transcription {
+	msg := &alertpb.Transcription{
+		Id:                 r.ID,
+		Filename:           r.Filename,
+		Source:             r.Source,
+		Status:             r.Status,
+		Transcript:         derefString(r.Transcript, ""),
+		CleanTranscript:    derefString(r.CleanTranscript, ""),
+		Translation:        derefString(r.Translation, ""),
+		DetectedLanguage:   derefString(r.DetectedLanguage, ""),
+		CallType:           derefString(r.CallType, ""),
+		NormalizedCallType: r.NormalizedCallType,
+		CallCategory:       r.CallCategory,
+		CallTimestamp:      timestampOrNil(r.CallTimestamp),
+		CreatedAt:          timestampOrNil(r.CreatedAt),
+		UpdatedAt:          timestampOrNil(r.UpdatedAt),
+		PrettyTitle:        r.PrettyTitle,
+		Town:               r.Town,
+		Agency:             r.Agency,
+		AddressLine:        r.AddressLine,
+		CrossStreet:        r.CrossStreet,
+		CityOrTown:         r.CityOrTown,
+		Summary:            r.Summary,
+		IncidentId:         r.IncidentID,
+		Tags:               r.Tags,
+		Units:              r.Units,
+		Flags:              r.Flags,
+		AudioUrl:           r.AudioURL,
+		PreviewImage:       r.PreviewImage,
+		NeedsManualReview:  r.NeedsManualReview,
+		ErrorCode:          derefString(r.ErrorCode, ""),
+		LastError:          derefString(r.LastError, ""),
+	}
+	if r.DurationSeconds != nil {
+		msg.DurationSeconds = *r.DurationSeconds
+	}
+	if r.Location != nil {
+		msg.Location = &alertpb.Location{
+			Label:     r.Location.Label,
+			Latitude:  r.Location.Latitude,
+			Longitude: r.Location.Longitude,
+			Precision: r.Location.Precision,
+			Source:    r.Location.Source,
+		}
+	}
+	return msg
+}
+
+func rollupMessage(r rollupResponse) *alertpb.Rollup {
+	msg := &alertpb.Rollup{
+		RollupId:       r.RollupID,
+		StartAt:        timestampOrNil(r.StartAt),
+		EndAt:          timestampOrNil(r.EndAt),
+		Latitude:       r.Latitude,
+		Longitude:      r.Longitude,
+		Municipality:   r.Municipality,
+		Poi:            r.POI,
+		Category:       r.Category,
+		Priority:       r.Priority,
+		Title:          r.Title,
+		Summary:        r.Summary,
+		Evidence:       r.Evidence,
+		Confidence:     r.Confidence,
+		Status:         r.Status,
+		State:          r.State,
+		CallCount:      int32(r.CallCount),
+		Curated:        r.Curated,
+		UpdatedAt:      timestampOrNil(r.UpdatedAt),
+		ClosingSummary: r.ClosingSummary,
+	}
+	if r.ClosedAt != nil {
+		msg.ClosedAt = timestampOrNil(*r.ClosedAt)
+	}
+	return msg
+}
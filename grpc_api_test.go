@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"alert_framework/alertpb"
+	"alert_framework/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const grpcTestToken = "test-admin-token"
+
+// newGRPCTestClient serves the gRPC API for s over an in-memory listener
+// with admin actions enabled.
+func newGRPCTestClient(t *testing.T) (*server, alertpb.AlertsClient) {
+	t.Helper()
+	t.Setenv("ENABLE_ADMIN_ACTIONS", "true")
+	t.Setenv("ADMIN_TOKEN", grpcTestToken)
+	s := newTestServer(t, config.Config{RestrictedCallFlags: []string{"mental_health"}, PublicBaseURL: "https://alerts.test"})
+	ln := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	alertpb.RegisterAlertsServer(srv, &grpcAPI{s: s})
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, alertpb.NewAlertsClient(conn)
+}
+
+func grpcAdminContext(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, grpcAdminTokenField, grpcTestToken)
+}
+
+func TestGRPCSubmitCallRequiresAdmin(t *testing.T) {
+	_, client := newGRPCTestClient(t)
+	ctx := context.Background()
+	req := &alertpb.SubmitCallRequest{Filename: "new.mp3"}
+
+	if _, err := client.SubmitCall(ctx, req); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("anonymous submit = %v, want PermissionDenied", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(ctx, grpcAdminTokenField, "nope")
+	if _, err := client.SubmitCall(wrong, req); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("submit with a wrong token = %v, want PermissionDenied", err)
+	}
+	// Past the admin check, this server has no queue to take the call.
+	if _, err := client.SubmitCall(grpcAdminContext(ctx), req); status.Code(err) != codes.Unavailable {
+		t.Fatalf("admin submit = %v, want Unavailable", err)
+	}
+}
+
+func TestGRPCGetTranscriptionHidesRestrictedCalls(t *testing.T) {
+	s, client := newGRPCTestClient(t)
+	ctx := context.Background()
+	insertTestCall(t, s.db, testCall{filename: "public.mp3", callType: "structure fire", transcript: "smoke showing"})
+	insertTestCall(t, s.db, testCall{filename: "private.mp3", callType: "medical", transcript: "subject is suicidal"})
+	deleted := insertTestCall(t, s.db, testCall{filename: "deleted.mp3", callType: "structure fire", transcript: "smoke showing"})
+	if _, err := s.db.Exec(`UPDATE transcriptions SET deleted_at = ? WHERE id = ?`, time.Now().UTC(), deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.GetTranscription(ctx, &alertpb.GetTranscriptionRequest{Filename: "public.mp3"}); err != nil {
+		t.Fatalf("public call: %v", err)
+	}
+	for _, name := range []string{"private.mp3", "deleted.mp3", "missing.mp3"} {
+		if _, err := client.GetTranscription(ctx, &alertpb.GetTranscriptionRequest{Filename: name}); status.Code(err) != codes.NotFound {
+			t.Errorf("anonymous get %s = %v, want NotFound", name, err)
+		}
+	}
+	msg, err := client.GetTranscription(grpcAdminContext(ctx), &alertpb.GetTranscriptionRequest{Filename: "private.mp3"})
+	if err != nil {
+		t.Fatalf("admin get restricted call: %v", err)
+	}
+	if msg.GetTranscript() != "subject is suicidal" {
+		t.Fatalf("admin transcript = %q", msg.GetTranscript())
+	}
+	if _, err := client.GetTranscription(grpcAdminContext(ctx), &alertpb.GetTranscriptionRequest{Filename: "deleted.mp3"}); err != nil {
+		t.Fatalf("admin get deleted call: %v", err)
+	}
+}
+
+// recvFilename reads the next streamed call, failing the test when none
+// arrives in time.
+func recvFilename(t *testing.T, stream alertpb.Alerts_StreamCompletionsClient) string {
+	t.Helper()
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	return msg.GetFilename()
+}
+
+// insertFinishedAt stores a done call whose updated_at is at, to the second
+// like CURRENT_TIMESTAMP writes it. The updated_at trigger is dropped so
+// later updates in the test keep the times they set.
+func insertFinishedAt(t *testing.T, s *server, filename, callType, transcript string, at time.Time) {
+	t.Helper()
+	id := insertTestCall(t, s.db, testCall{filename: filename, callType: callType, transcript: transcript, at: at})
+	if _, err := s.db.Exec(`DROP TRIGGER IF EXISTS transcriptions_updated_at`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec(`UPDATE transcriptions SET updated_at = ? WHERE id = ?`, at.UTC().Format("2006-01-02 15:04:05"), id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGRPCStreamCompletionsSkipsRestrictedForAnonymous(t *testing.T) {
+	s, client := newGRPCTestClient(t)
+	at := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	insertFinishedAt(t, s, "private.mp3", "medical", "subject is suicidal", at)
+	insertFinishedAt(t, s, "public.mp3", "structure fire", "smoke showing", at.Add(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	since := &alertpb.StreamCompletionsRequest{Since: timestamppb.New(at.Add(-time.Minute))}
+	anon, err := client.StreamCompletions(ctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := client.StreamCompletions(grpcAdminContext(ctx), since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recvFilename(t, anon); got != "public.mp3" {
+		t.Fatalf("anonymous stream sent %s first, want public.mp3", got)
+	}
+	if a, b := recvFilename(t, admin), recvFilename(t, admin); a != "private.mp3" || b != "public.mp3" {
+		t.Fatalf("admin stream sent %s, %s; want private.mp3, public.mp3", a, b)
+	}
+	// A later call is the next thing the anonymous stream sees.
+	insertFinishedAt(t, s, "later.mp3", "structure fire", "all units clear", at.Add(2*time.Second))
+	if got := recvFilename(t, anon); got != "later.mp3" {
+		t.Fatalf("anonymous stream sent %s, want later.mp3", got)
+	}
+}
+
+func TestGRPCStreamCompletionsResumesAcrossCursor(t *testing.T) {
+	s, client := newGRPCTestClient(t)
+	at := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	insertFinishedAt(t, s, "a.mp3", "structure fire", "smoke showing", at)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := client.StreamCompletions(ctx, &alertpb.StreamCompletionsRequest{Since: timestamppb.New(at.Add(-time.Minute))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recvFilename(t, stream); got != "a.mp3" {
+		t.Fatalf("first call = %s, want a.mp3", got)
+	}
+	// b finishes in the same second as the cursor: the next poll must send
+	// it without repeating a.
+	insertFinishedAt(t, s, "b.mp3", "structure fire", "second alarm", at)
+	if got := recvFilename(t, stream); got != "b.mp3" {
+		t.Fatalf("same-second call = %s, want b.mp3", got)
+	}
+	// Reprocessing a moves it past the cursor, so it is sent again.
+	if _, err := s.db.Exec(`UPDATE transcriptions SET updated_at = ? WHERE filename = 'a.mp3'`, at.Add(2*time.Second).Format("2006-01-02 15:04:05")); err != nil {
+		t.Fatal(err)
+	}
+	if got := recvFilename(t, stream); got != "a.mp3" {
+		t.Fatalf("reprocessed call = %s, want a.mp3 again", got)
+	}
+	insertFinishedAt(t, s, "c.mp3", "structure fire", "units clear", at.Add(3*time.Second))
+	if got := recvFilename(t, stream); got != "c.mp3" {
+		t.Fatalf("after resend got %s, want c.mp3 with nothing repeated", got)
+	}
+
+	// A new stream resuming from the last update sent skips what came
+	// before. Calls finished in that same second may come again, since
+	// updated_at cannot order them.
+	cancel()
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel2()
+	resumed, err := client.StreamCompletions(ctx2, &alertpb.StreamCompletionsRequest{Since: timestamppb.New(at.Add(3 * time.Second))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertFinishedAt(t, s, "d.mp3", "structure fire", "command terminated", at.Add(5*time.Second))
+	for {
+		got := recvFilename(t, resumed)
+		if got == "d.mp3" {
+			break
+		}
+		if got != "c.mp3" {
+			t.Fatalf("resumed stream sent %s from before its cursor", got)
+		}
+	}
+}
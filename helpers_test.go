@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"alert_framework/config"
+)
+
+// newTestServer returns a server on a freshly migrated database in a
+// temporary directory. Only the fields the handlers under test read are set.
+func newTestServer(t *testing.T, cfg config.Config) *server {
+	t.Helper()
+	db, err := openDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &server{
+		db:       db,
+		cfg:      cfg,
+		tz:       time.UTC,
+		ctx:      ctx,
+		shutdown: make(chan struct{}),
+		store:    newTranscriptionStore(db, db, 5*time.Second),
+	}
+}
+
+// testCall is a finished call for insertTestCall; zero fields are left NULL.
+type testCall struct {
+	filename   string
+	callType   string
+	transcript string
+	at         time.Time
+	lat, lng   float64
+	label      string
+}
+
+// insertTestCall stores c as a completed call and returns its id.
+func insertTestCall(t *testing.T, db *sql.DB, c testCall) int64 {
+	t.Helper()
+	if c.at.IsZero() {
+		c.at = time.Now().UTC()
+	}
+	var lat, lng, label interface{}
+	if c.lat != 0 || c.lng != 0 {
+		lat, lng = c.lat, c.lng
+	}
+	if c.label != "" {
+		label = c.label
+	}
+	res, err := db.Exec(`INSERT INTO transcriptions (filename, source_path, processed_path, ingest_source, status, call_type, transcript_text, clean_transcript_text, call_timestamp, latitude, longitude, location_label)
+VALUES (?, '', '', 'test', ?, ?, ?, ?, ?, ?, ?, ?)`, c.filename, statusDone, nullableString(c.callType), c.transcript, c.transcript, c.at, lat, lng, label)
+	if err != nil {
+		t.Fatalf("insert %s: %v", c.filename, err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"alert_framework/version"
+)
+
+// maxPreviewCacheEntries bounds the rendered preview cache; at ~60 KB a PNG
+// that is about 15 MB.
+const maxPreviewCacheEntries = 256
+
+// previewCache keeps encoded preview PNGs keyed by filename. An entry is only
+// used while the call's updated_at matches, so any change to the call
+// re-renders it.
+type previewCache struct {
+	mu      sync.Mutex
+	entries map[string]previewCacheEntry
+}
+
+type previewCacheEntry struct {
+	updatedAt time.Time
+	storedAt  time.Time
+	data      []byte
+}
+
+func (c *previewCache) get(filename string, updatedAt time.Time) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[filename]
+	if !ok || !e.updatedAt.Equal(updatedAt) {
+		return nil, false
+	}
+	return e.data, true
+}
+
+func (c *previewCache) put(filename string, updatedAt time.Time, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]previewCacheEntry)
+	}
+	if _, ok := c.entries[filename]; !ok && len(c.entries) >= maxPreviewCacheEntries {
+		var oldest string
+		var oldestAt time.Time
+		for name, e := range c.entries {
+			if oldest == "" || e.storedAt.Before(oldestAt) {
+				oldest, oldestAt = name, e.storedAt
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[filename] = previewCacheEntry{updatedAt: updatedAt, storedAt: time.Now(), data: data}
+}
+
+// drop forgets filename's preview, for a call that was deleted.
+func (c *previewCache) drop(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, filename)
+}
+
+// previewPNG returns t's encoded preview, rendering it only when the cached
+// copy is missing or older than the call. complete is false for a card
+// rendered without its map, which is not cached.
+func (s *server) previewPNG(t transcription) ([]byte, bool, error) {
+	if data, ok := s.previews.get(t.Filename, t.UpdatedAt); ok {
+		return data, true, nil
+	}
+	img, complete, err := s.renderPreview(s.publicRecord(t))
+	if err != nil {
+		return nil, false, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, false, err
+	}
+	if complete {
+		s.previews.put(t.Filename, t.UpdatedAt, buf.Bytes())
+	}
+	return buf.Bytes(), complete, nil
+}
+
+// previewETag identifies a preview by the call's last update and the build
+// that rendered it, so a deploy that changes the layout invalidates it.
+func previewETag(t transcription) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s", t.Filename, t.UpdatedAt.UnixNano(), version.GitSHA)))
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// notModified sets the ETag and, when known, Last-Modified validators on w
+// and reports whether r's conditional headers match them, in which case it
+// has already answered 304. If-None-Match takes precedence over
+// If-Modified-Since, as RFC 9110 requires.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modified.IsZero() || modified.Truncate(time.Second).After(ims) {
+			return false
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches applies the weak comparison If-None-Match calls for.
+func etagMatches(header, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// respondJSONCached writes v like respondJSON, with an ETag over the encoded
+// body so a poller whose copy is current gets a bodyless 304. The response
+// must still be built to be hashed; what is saved is the transfer and the
+// client's re-render. Clients must revalidate on every use.
+func respondJSONCached(w http.ResponseWriter, r *http.Request, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, "encode error", http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if notModified(w, r, `"`+hex.EncodeToString(sum[:12])+`"`, time.Time{}) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,243 @@
+// Package imap reads unseen messages from one IMAP4rev1 mailbox. Each Fetch
+// opens a connection, logs in, hands every unseen message to a callback,
+// flags the ones it accepted as seen and logs out, which is all page ingest
+// needs and keeps a client library out of the build.
+package imap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLiteral bounds a single message or response literal.
+const maxLiteral = 25 << 20
+
+// Mailbox is where messages are read from. URL is imap:// or
+// imaps://host[:port][/mailbox]; the mailbox defaults to INBOX, and
+// credentials in the URL are used when Username is empty.
+type Mailbox struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// Message is one fetched message.
+type Message struct {
+	UID          uint32
+	InternalDate time.Time
+	Raw          []byte
+}
+
+// ParseURL checks a mailbox URL and returns the address to dial, whether to
+// use TLS and the mailbox name.
+func ParseURL(raw string) (addr string, useTLS bool, mailbox string, err error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", false, "", fmt.Errorf("invalid imap url %q", raw)
+	}
+	port := "143"
+	switch strings.ToLower(u.Scheme) {
+	case "imap":
+	case "imaps":
+		useTLS, port = true, "993"
+	default:
+		return "", false, "", fmt.Errorf("unsupported imap scheme %q", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	mailbox = strings.Trim(u.Path, "/")
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, mailbox, nil
+}
+
+// Fetch passes up to limit unseen messages, oldest first, to handle. A
+// message is flagged \Seen when handle returns nil and left unseen for the
+// next Fetch otherwise; the first handle error stops the fetch and is
+// returned.
+func (m Mailbox) Fetch(ctx context.Context, limit int, handle func(Message) error) error {
+	addr, useTLS, mailbox, err := ParseURL(m.URL)
+	if err != nil {
+		return err
+	}
+	username, password := m.Username, m.Password
+	if u, _ := url.Parse(m.URL); username == "" && u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var nc net.Conn
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		nc, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("imap dial: %w", err)
+	}
+	defer nc.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	c := &conn{r: bufio.NewReader(nc), w: nc}
+	greeting, _, err := c.readLine()
+	if err != nil {
+		return fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return fmt.Errorf("imap greeting: %s", greeting)
+	}
+	defer c.command("LOGOUT")
+	if !strings.HasPrefix(greeting, "* PREAUTH") {
+		if _, err := c.command("LOGIN " + quote(username) + " " + quote(password)); err != nil {
+			return err
+		}
+	}
+	if _, err := c.command("SELECT " + quote(mailbox)); err != nil {
+		return err
+	}
+	lines, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return err
+	}
+	uids := searchUIDs(lines)
+	if limit > 0 && len(uids) > limit {
+		uids = uids[:limit]
+	}
+	for _, uid := range uids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		lines, err := c.command(fmt.Sprintf("UID FETCH %d (UID INTERNALDATE BODY.PEEK[])", uid))
+		if err != nil {
+			return err
+		}
+		msg, ok := fetchedMessage(lines, uid)
+		if !ok {
+			continue
+		}
+		if err := handle(msg); err != nil {
+			return err
+		}
+		if _, err := c.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// response is one untagged response line and the literals it carried.
+type response struct {
+	line     string
+	literals [][]byte
+}
+
+type conn struct {
+	r   *bufio.Reader
+	w   io.Writer
+	tag int
+}
+
+var literalSuffix = regexp.MustCompile(`\{(\d+)\}$`)
+
+// readLine reads one response line, including any literals it announces.
+func (c *conn) readLine() (string, [][]byte, error) {
+	var line strings.Builder
+	var literals [][]byte
+	for {
+		part, err := c.r.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		part = strings.TrimRight(part, "\r\n")
+		line.WriteString(part)
+		m := literalSuffix.FindStringSubmatch(part)
+		if m == nil {
+			return line.String(), literals, nil
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n > maxLiteral {
+			return "", nil, fmt.Errorf("imap: literal of %s bytes", m[1])
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return "", nil, err
+		}
+		literals = append(literals, buf)
+	}
+}
+
+// command sends cmd and returns the untagged responses once the server
+// completes it; a NO or BAD completion is an error.
+func (c *conn) command(cmd string) ([]response, error) {
+	c.tag++
+	tag := fmt.Sprintf("a%d", c.tag)
+	if _, err := fmt.Fprintf(c.w, "%s %s\r\n", tag, cmd); err != nil {
+		return nil, fmt.Errorf("imap write: %w", err)
+	}
+	var out []response
+	for {
+		line, literals, err := c.readLine()
+		if err != nil {
+			return nil, fmt.Errorf("imap read: %w", err)
+		}
+		if rest, ok := strings.CutPrefix(line, tag+" "); ok {
+			if strings.HasPrefix(strings.ToUpper(rest), "OK") {
+				return out, nil
+			}
+			verb, _, _ := strings.Cut(cmd, " ")
+			return nil, fmt.Errorf("imap %s: %s", verb, rest)
+		}
+		out = append(out, response{line: line, literals: literals})
+	}
+}
+
+func searchUIDs(lines []response) []uint32 {
+	var uids []uint32
+	for _, l := range lines {
+		rest, ok := strings.CutPrefix(l.line, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, f := range strings.Fields(rest) {
+			if n, err := strconv.ParseUint(f, 10, 32); err == nil {
+				uids = append(uids, uint32(n))
+			}
+		}
+	}
+	return uids
+}
+
+var internalDate = regexp.MustCompile(`INTERNALDATE "([^"]+)"`)
+
+func fetchedMessage(lines []response, uid uint32) (Message, bool) {
+	for _, l := range lines {
+		if !strings.Contains(l.line, " FETCH ") || len(l.literals) == 0 {
+			continue
+		}
+		msg := Message{UID: uid, Raw: l.literals[len(l.literals)-1]}
+		if m := internalDate.FindStringSubmatch(l.line); m != nil {
+			if ts, err := time.Parse("2-Jan-2006 15:04:05 -0700", strings.TrimSpace(m[1])); err == nil {
+				msg.InternalDate = ts
+			}
+		}
+		return msg, true
+	}
+	return Message{}, false
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
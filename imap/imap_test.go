@@ -0,0 +1,204 @@
+package imap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer serves one connection from a mailbox of unseen messages keyed by
+// UID and reports the commands it read.
+func fakeServer(t *testing.T, messages map[uint32]string, order []uint32) (string, <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		var commands []string
+		fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			commands = append(commands, line)
+			tag, cmd, _ := strings.Cut(line, " ")
+			switch {
+			case strings.HasPrefix(cmd, "LOGIN") && strings.Contains(cmd, `"wrong"`):
+				fmt.Fprintf(conn, "%s NO [AUTHENTICATIONFAILED] Invalid credentials\r\n", tag)
+			case strings.HasPrefix(cmd, "UID SEARCH"):
+				var uids []string
+				for _, uid := range order {
+					uids = append(uids, fmt.Sprint(uid))
+				}
+				fmt.Fprintf(conn, "* SEARCH %s\r\n%s OK SEARCH completed\r\n", strings.Join(uids, " "), tag)
+			case strings.HasPrefix(cmd, "UID FETCH"):
+				var uid uint32
+				fmt.Sscanf(cmd, "UID FETCH %d", &uid)
+				body := messages[uid]
+				fmt.Fprintf(conn, "* %d FETCH (UID %d INTERNALDATE \"14-Mar-2025 09:26:53 -0400\" BODY[] {%d}\r\n%s)\r\n%s OK FETCH completed\r\n", uid, uid, len(body), body, tag)
+			default:
+				fmt.Fprintf(conn, "%s OK done\r\n", tag)
+			}
+			if cmd == "LOGOUT" {
+				break
+			}
+		}
+		got <- commands
+	}()
+	return "imap://" + ln.Addr().String() + "/Pages", got
+}
+
+func TestFetch(t *testing.T) {
+	messages := map[uint32]string{
+		4: "Subject: first\r\n\r\nStructure fire\r\n",
+		9: "Subject: second\r\n\r\nMVA\r\n",
+	}
+	url, got := fakeServer(t, messages, []uint32{4, 9})
+	m := Mailbox{URL: url, Username: "pager@example.com", Password: `p"w`}
+	var fetched []Message
+	err := m.Fetch(context.Background(), 0, func(msg Message) error {
+		fetched = append(fetched, msg)
+		if msg.UID == 9 {
+			return errors.New("keep for later")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "keep for later" {
+		t.Fatalf("err = %v", err)
+	}
+	if len(fetched) != 2 || string(fetched[0].Raw) != messages[4] || fetched[1].UID != 9 {
+		t.Fatalf("fetched = %+v", fetched)
+	}
+	if want := time.Date(2025, 3, 14, 13, 26, 53, 0, time.UTC); !fetched[0].InternalDate.Equal(want) {
+		t.Fatalf("internal date = %v", fetched[0].InternalDate)
+	}
+	commands := strings.Join(<-got, "\n")
+	for _, want := range []string{
+		`a1 LOGIN "pager@example.com" "p\"w"`,
+		`a2 SELECT "Pages"`,
+		`a3 UID SEARCH UNSEEN`,
+		`a4 UID FETCH 4 (UID INTERNALDATE BODY.PEEK[])`,
+		`a5 UID STORE 4 +FLAGS.SILENT (\Seen)`,
+		`a6 UID FETCH 9 (UID INTERNALDATE BODY.PEEK[])`,
+		`a7 LOGOUT`,
+	} {
+		if !strings.Contains(commands, want) {
+			t.Fatalf("missing %q in\n%s", want, commands)
+		}
+	}
+	if strings.Contains(commands, "STORE 9") {
+		t.Fatalf("rejected message flagged seen:\n%s", commands)
+	}
+}
+
+func TestFetchLimitAndLoginFailure(t *testing.T) {
+	url, got := fakeServer(t, map[uint32]string{1: "a", 2: "b"}, []uint32{1, 2})
+	var n int
+	if err := (Mailbox{URL: url, Username: "u", Password: "p"}).Fetch(context.Background(), 1, func(Message) error { n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	<-got
+	if n != 1 {
+		t.Fatalf("fetched %d messages", n)
+	}
+
+	url, got = fakeServer(t, nil, nil)
+	err := (Mailbox{URL: strings.Replace(url, "imap://", "imap://u:wrong@", 1)}).Fetch(context.Background(), 0, func(Message) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "AUTHENTICATIONFAILED") {
+		t.Fatalf("err = %v", err)
+	}
+	<-got
+}
+
+func TestParseURL(t *testing.T) {
+	cases := []struct {
+		in, addr, mailbox string
+		tls               bool
+	}{
+		{"imaps://mail.example.com", "mail.example.com:993", "INBOX", true},
+		{"imap://mail.example.com:1143/Pages", "mail.example.com:1143", "Pages", false},
+	}
+	for _, c := range cases {
+		addr, useTLS, mailbox, err := ParseURL(c.in)
+		if err != nil || addr != c.addr || useTLS != c.tls || mailbox != c.mailbox {
+			t.Fatalf("ParseURL(%q) = %q %v %q %v", c.in, addr, useTLS, mailbox, err)
+		}
+	}
+	for _, bad := range []string{"pop3://mail.example.com", "mail.example.com", "imap://"} {
+		if _, _, _, err := ParseURL(bad); err == nil {
+			t.Fatalf("ParseURL(%q) accepted", bad)
+		}
+	}
+}
+
+func TestParsePlainText(t *testing.T) {
+	raw := "From: Sussex Dispatch <Dispatch@Sussex.example>\r\n" +
+		"Subject: =?UTF-8?Q?Structure_fire_=E2=80=93_Sparta?=\r\n" +
+		"Message-ID: <abc123@sussex.example>\r\n" +
+		"Date: Fri, 14 Mar 2025 09:26:53 -0400\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"  12 Main St, Sparta  \r\n\r\nSmoke showing from=\r\n the roof\r\n-- \r\nSent by CAD\r\n"
+	text, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.From != "dispatch@sussex.example" || text.Subject != "Structure fire – Sparta" || text.MessageID != "abc123@sussex.example" {
+		t.Fatalf("headers = %+v", text)
+	}
+	if want := time.Date(2025, 3, 14, 13, 26, 53, 0, time.UTC); !text.Date.Equal(want) {
+		t.Fatalf("date = %v", text.Date)
+	}
+	if text.Body != "12 Main St, Sparta\nSmoke showing from the roof" {
+		t.Fatalf("body = %q", text.Body)
+	}
+}
+
+func TestParseMultipart(t *testing.T) {
+	raw := "From: cad@example.com\r\n" +
+		"Subject: Page\r\n" +
+		"Content-Type: multipart/mixed; boundary=outer\r\n\r\n" +
+		"--outer\r\n" +
+		"Content-Type: multipart/alternative; boundary=inner\r\n\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n\r\n" +
+		"<html><style>p{}</style><p>MVA &amp; entrapment</p><br>Route 15</html>\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Disposition: attachment; filename=run.txt\r\n\r\n" +
+		"attached run card\r\n" +
+		"--outer--\r\n"
+	text, err := Parse([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.Body != "MVA & entrapment\nRoute 15" {
+		t.Fatalf("body = %q", text.Body)
+	}
+
+	raw = "Subject: x\r\nContent-Type: text/plain; charset=iso-8859-1\r\nContent-Transfer-Encoding: base64\r\n\r\nQ2Fs\r\nbGUgY2Fm6Q==\r\n"
+	if text, err := Parse([]byte(raw)); err != nil || text.Body != "Calle café" {
+		t.Fatalf("latin-1 body = %q, %v", text.Body, err)
+	}
+	if _, err := Parse([]byte("Subject: empty\r\nContent-Type: image/png\r\n\r\nxx")); !errors.Is(err, ErrNoText) {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package imap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNoText is returned by Parse for a message with no text part.
+var ErrNoText = errors.New("imap: message has no text")
+
+// Text is what Parse reads from a message: the sender's address, the
+// decoded subject, the Message-ID, the Date header (zero when missing or
+// malformed) and the plain-text body.
+type Text struct {
+	From      string
+	Subject   string
+	MessageID string
+	Date      time.Time
+	Body      string
+}
+
+// Parse reads a message's headers and body. The body is the first
+// text/plain part, or the first text/html part with its markup removed.
+func Parse(raw []byte) (Text, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return Text{}, err
+	}
+	var dec mime.WordDecoder
+	out := Text{MessageID: strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>")}
+	out.Subject = strings.TrimSpace(msg.Header.Get("Subject"))
+	if s, err := dec.DecodeHeader(out.Subject); err == nil {
+		out.Subject = strings.TrimSpace(s)
+	}
+	if from, err := msg.Header.AddressList("From"); err == nil && len(from) > 0 {
+		out.From = strings.ToLower(from[0].Address)
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		out.Date = date
+	}
+	plain, htmlBody, err := textParts(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return out, err
+	}
+	switch {
+	case strings.TrimSpace(plain) != "":
+		out.Body = cleanText(plain)
+	case strings.TrimSpace(htmlBody) != "":
+		out.Body = cleanText(stripHTML(htmlBody))
+	default:
+		return out, ErrNoText
+	}
+	return out, nil
+}
+
+// textParts returns the first text/plain and text/html bodies found in a
+// part, walking nested multiparts.
+func textParts(contentType, encoding string, body io.Reader) (plain, htmlBody string, err error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return plain, htmlBody, nil
+			}
+			if err != nil {
+				return plain, htmlBody, err
+			}
+			if strings.EqualFold(dispositionOf(part.Header.Get("Content-Disposition")), "attachment") {
+				continue
+			}
+			p, h, err := textParts(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return plain, htmlBody, err
+			}
+			if plain == "" {
+				plain = p
+			}
+			if htmlBody == "" {
+				htmlBody = h
+			}
+		}
+	}
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", "", nil
+	}
+	data, err := io.ReadAll(decodeTransfer(encoding, body))
+	if err != nil {
+		return "", "", err
+	}
+	text := decodeCharset(params["charset"], data)
+	if mediaType == "text/html" {
+		return "", text, nil
+	}
+	return text, "", nil
+}
+
+func dispositionOf(header string) string {
+	d, _, _ := mime.ParseMediaType(header)
+	return d
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, newlineStripper{r})
+	}
+	return r
+}
+
+// newlineStripper drops line breaks, which base64 bodies wrap at 76 columns.
+type newlineStripper struct{ r io.Reader }
+
+func (n newlineStripper) Read(p []byte) (int, error) {
+	c, err := n.r.Read(p)
+	out := p[:0]
+	for _, b := range p[:c] {
+		if b != '\r' && b != '\n' {
+			out = append(out, b)
+		}
+	}
+	return len(out), err
+}
+
+// decodeCharset converts Latin-1 bodies to UTF-8; pagers send little else
+// besides ASCII and UTF-8, which pass through.
+func decodeCharset(charset string, data []byte) string {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(data)
+}
+
+var (
+	htmlBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</tr>`)
+	htmlBlocks = regexp.MustCompile(`(?is)<(style|script)[^>]*>.*?</(style|script)>`)
+	htmlTags   = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+func stripHTML(s string) string {
+	s = htmlBlocks.ReplaceAllString(s, "")
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	return html.UnescapeString(htmlTags.ReplaceAllString(s, ""))
+}
+
+// cleanText trims every line and drops blank ones and the signature after a
+// "-- " line, whose trailing space quoted-printable decoding may remove.
+func cleanText(s string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		if strings.TrimRight(line, " ") == "--" {
+			break
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	opsJobKindImport = "import"
+
+	// importSource is the ingest_source of imported calls.
+	importSource = "import"
+
+	// maxImportEntries bounds one import job; larger archives are sent in
+	// several jobs, which cmd/import does.
+	maxImportEntries = 5000
+
+	// embeddingBackfillInterval is how often calls without an embedding are
+	// looked for, and embeddingBackfillBatch how many are embedded per pass.
+	embeddingBackfillInterval = time.Minute
+	embeddingBackfillBatch    = 20
+)
+
+// What an import does with a manifest entry.
+const (
+	importDone       = "done"
+	importTranscribe = "transcribe"
+	importExists     = "exists"
+	importMissing    = "missing"
+	importInvalid    = "invalid"
+)
+
+// importEntry maps one recording from a legacy archive to the call it
+// becomes. File is absolute or relative to the request's dir; Name renames
+// the call when archive filenames are not unique.
+type importEntry struct {
+	File       string `json:"file"`
+	Name       string `json:"name,omitempty"`
+	Timestamp  string `json:"timestamp,omitempty"`
+	CallType   string `json:"call_type,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+}
+
+type importRequest struct {
+	Dir     string        `json:"dir"`
+	Entries []importEntry `json:"entries"`
+	DryRun  bool          `json:"dry_run"`
+}
+
+// importParams is persisted with the job, entries included; the cursor is
+// the index of the next entry, so a resumed run picks up where it stopped.
+type importParams struct {
+	Dir     string        `json:"dir,omitempty"`
+	Entries []importEntry `json:"entries"`
+}
+
+type importEstimate struct {
+	DryRun  bool           `json:"dry_run"`
+	Count   int            `json:"count"`
+	Actions map[string]int `json:"actions"`
+}
+
+// handleOpsImport estimates (dry_run) or starts a job that imports recordings
+// from a legacy archive. Entries with a transcript become done calls without
+// being transcribed again; the rest are queued. Imported calls get their
+// embeddings later, from the embedding backfill.
+func (s *server) handleOpsImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req importRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if len(req.Entries) == 0 {
+		http.Error(w, "entries required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Entries) > maxImportEntries {
+		http.Error(w, fmt.Sprintf("at most %d entries per import", maxImportEntries), http.StatusBadRequest)
+		return
+	}
+	params := importParams{Dir: strings.TrimSpace(req.Dir), Entries: req.Entries}
+	estimate := importEstimate{Count: len(params.Entries), Actions: map[string]int{}}
+	for _, e := range params.Entries {
+		action, _, _ := s.planImport(params.Dir, e)
+		estimate.Actions[action]++
+	}
+	if req.DryRun {
+		estimate.DryRun = true
+		respondJSON(w, estimate)
+		return
+	}
+	if estimate.Actions[importTranscribe] > 0 && s.queue == nil {
+		http.Error(w, "queue disabled", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := s.createOpsJob(opsJobKindImport, params, len(params.Entries))
+	if err != nil {
+		log.Printf("import create failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	noteAudit(r, "calls.import", strconv.FormatInt(id, 10), nil, map[string]interface{}{"dir": params.Dir, "actions": estimate.Actions})
+	go s.runImport(s.ctx, id, params)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	respondJSON(w, map[string]interface{}{
+		"job_id":     id,
+		"estimate":   estimate,
+		"status_url": fmt.Sprintf("/ops/jobs/%d", id),
+		"stream_url": fmt.Sprintf("/ops/jobs/%d/stream", id),
+	})
+}
+
+// planImport resolves an entry's source path and call filename and decides
+// what importing it does. Calls that already exist are left alone, so
+// running the same manifest twice imports nothing the second time.
+func (s *server) planImport(dir string, e importEntry) (action, path, filename string) {
+	path = strings.TrimSpace(e.File)
+	if path == "" {
+		return importInvalid, "", ""
+	}
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	filename = filepath.Base(path)
+	if name := strings.TrimSpace(e.Name); name != "" {
+		filename = filepath.Base(name)
+	}
+	if !isAudioFilename(filename) {
+		return importInvalid, path, filename
+	}
+	if strings.TrimSpace(e.Timestamp) != "" {
+		if _, err := parseTimestampFlexible(e.Timestamp, s.tz); err != nil {
+			return importInvalid, path, filename
+		}
+	}
+	if _, err := s.getTranscription(filename); err == nil {
+		return importExists, path, filename
+	}
+	if _, ok := s.aliasOf(filename); ok {
+		return importExists, path, filename
+	}
+	if !fileExists(path) {
+		return importMissing, path, filename
+	}
+	if strings.TrimSpace(e.Transcript) != "" {
+		return importDone, path, filename
+	}
+	return importTranscribe, path, filename
+}
+
+// runImport works through the entries from the job's cursor, checkpointing
+// after each one. Entries that need transcribing are only queued while the
+// queue is idle, as in a backfill, so live calls are never stuck behind an
+// archive.
+func (s *server) runImport(ctx context.Context, jobID int64, p importParams) {
+	var cursor int64
+	var processed, failed int
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&cursor, &processed, &failed)
+	}, `SELECT cursor_id, processed, failed FROM ops_jobs WHERE id = ?`, jobID); err != nil {
+		log.Printf("ops job %d load failed: %v", jobID, err)
+		return
+	}
+	opts, _ := s.defaultOptions()
+	var lastErr string
+	for i := int(cursor); i < len(p.Entries); i++ {
+		if ctx.Err() != nil {
+			log.Printf("ops job %d interrupted; will resume on restart", jobID)
+			return
+		}
+		e := p.Entries[i]
+		action, path, filename := s.planImport(p.Dir, e)
+		var err error
+		switch action {
+		case importDone:
+			err = s.importTranscribed(path, filename, e, opts)
+		case importTranscribe:
+			if !s.waitForIdleQueue(ctx) {
+				log.Printf("ops job %d interrupted; will resume on restart", jobID)
+				return
+			}
+			err = s.importForTranscription(ctx, path, filename, e, opts)
+		case importMissing:
+			err = fmt.Errorf("%s: file not found", path)
+		case importInvalid:
+			err = fmt.Errorf("entry %d: invalid file, name or timestamp", i+1)
+		}
+		if err != nil {
+			failed++
+			lastErr = err.Error()
+			log.Printf("import job %d: %s", jobID, lastErr)
+		} else {
+			processed++
+		}
+		if _, err := execWithRetry(s.db, `UPDATE ops_jobs SET cursor_id = ?, processed = ?, failed = ?, last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, i+1, processed, failed, nullableString(lastErr), jobID); err != nil {
+			log.Printf("ops job %d checkpoint failed: %v", jobID, err)
+		}
+	}
+	s.finishOpsJob(jobID, opsJobCompleted, lastErr)
+}
+
+// importCallTime is the entry's timestamp, else the time in the filename,
+// else the file's modification time.
+func (s *server) importCallTime(path, filename string, e importEntry) time.Time {
+	if ts, err := parseTimestampFlexible(e.Timestamp, s.tz); err == nil {
+		return ts
+	}
+	if meta, err := s.parseCallMetadata(filename, importSource); err == nil {
+		return meta.DateTime
+	}
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime()
+	}
+	return time.Now()
+}
+
+// importDest is where an entry's recording goes in CALLS_DIR, where playback
+// serves it from. It refuses to overwrite a recording that is already there.
+func (s *server) importDest(path, filename string) (string, error) {
+	dest := filepath.Join(s.cfg.CallsDir, filename)
+	if filepath.Clean(path) != filepath.Clean(dest) && fileExists(dest) {
+		return "", fmt.Errorf("%s already exists in calls dir", filename)
+	}
+	return dest, nil
+}
+
+// copyImportAudio copies a recording to dest unless it is already there. The
+// caller writes the call's row first, so the watcher skips the new file.
+func copyImportAudio(path, dest string) error {
+	if filepath.Clean(path) == filepath.Clean(dest) {
+		return nil
+	}
+	return copyFile(path, dest)
+}
+
+// importTranscribed stores an entry whose transcript the legacy system
+// already produced as a done call, without transcribing it again.
+func (s *server) importTranscribed(path, filename string, e importEntry, opts TranscriptionOptions) error {
+	dest, err := s.importDest(path, filename)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := s.markProcessing(filename, dest, importSource, info.Size(), opts, s.importCallTime(path, filename, e)); err != nil {
+		return err
+	}
+	if err := copyImportAudio(path, dest); err != nil {
+		s.markError(filename, err)
+		return err
+	}
+	hash, err := hashFile(dest)
+	if err != nil {
+		s.markError(filename, err)
+		return err
+	}
+	if err := s.updateMetadata(filename, info.Size(), probeDuration(dest), hash); err != nil {
+		log.Printf("metadata update failed: %v", err)
+	}
+
+	text := strings.TrimSpace(e.Transcript)
+	meta, _ := s.parseCallMetadata(filename, importSource)
+	normalized := s.normalizeTranscript(text, meta)
+	var callType *string
+	if ct := strings.TrimSpace(e.CallType); ct != "" {
+		callType = &ct
+	}
+	var tags *string
+	if data, err := json.Marshal(s.buildTags(meta, nil, callType)); err == nil {
+		str := string(data)
+		tags = &str
+	}
+	if err := s.completeCall(filename, callCompletion{
+		raw:              &text,
+		clean:            &text,
+		normalized:       &normalized,
+		callType:         callType,
+		tags:             tags,
+		publicTranscript: s.redactTranscript(text),
+		engine:           importSource,
+	}); err != nil {
+		s.markError(filename, err)
+		return err
+	}
+	return nil
+}
+
+// importForTranscription copies an entry's recording into CALLS_DIR and
+// queues it. The row is written first with the archive's timestamp and call
+// type, which the pipeline keeps; no alerts go out for it.
+func (s *server) importForTranscription(ctx context.Context, path, filename string, e importEntry, opts TranscriptionOptions) error {
+	dest, err := s.importDest(path, filename)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := s.markQueued(filename, dest, importSource, info.Size(), opts, s.importCallTime(path, filename, e)); err != nil {
+		return err
+	}
+	if ct := strings.TrimSpace(e.CallType); ct != "" {
+		if _, err := execWithRetry(s.db, `UPDATE transcriptions SET call_type = ? WHERE filename = ?`, ct, filename); err != nil {
+			return err
+		}
+	}
+	if err := copyImportAudio(path, dest); err != nil {
+		s.markError(filename, err)
+		return err
+	}
+	// The row is already queued, which only a forced enqueue gets past.
+	if enqueued, _ := s.enqueueWithBackoff(ctx, importSource, filename, false, true, opts); !enqueued {
+		err := errors.New("not queued")
+		s.markError(filename, err)
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+// startEmbeddingBackfill embeds done calls stored without an embedding, such
+// as imported calls, a batch at a time and only while the queue is idle, so
+// similar-call search covers them without slowing live transcription.
+func (s *server) startEmbeddingBackfill(ctx context.Context) {
+	if os.Getenv("OPENAI_API_KEY") == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(embeddingBackfillInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+			if s.queue != nil && s.queue.Stats().Length > 0 || !s.openAIAvailable() {
+				continue
+			}
+			if err := s.backfillEmbeddings(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("embedding backfill failed: %v", err)
+			}
+		}
+	}()
+}
+
+func (s *server) backfillEmbeddings(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT filename, COALESCE(NULLIF(clean_transcript_text, ''), transcript_text) FROM transcriptions
+WHERE status = ? AND embedding IS NULL AND deleted_at IS NULL AND COALESCE(duplicate_of, '') = ''
+  AND TRIM(COALESCE(NULLIF(clean_transcript_text, ''), transcript_text, '')) != ''
+ORDER BY id DESC LIMIT ?`, statusDone, embeddingBackfillBatch)
+	if err != nil {
+		return err
+	}
+	type pending struct{ filename, text string }
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.filename, &p.text); err != nil {
+			rows.Close()
+			return err
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	for _, p := range batch {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		vec, err := s.embedTranscript(p.text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.filename, err)
+		}
+		data, err := json.Marshal(vec)
+		if err != nil {
+			return err
+		}
+		if _, err := execWithRetry(s.db, sqlStoreEmbedding, string(data), p.filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
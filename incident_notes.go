@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Acknowledgements and comments hang off either a call (keyed by filename) or
+// a rollup (keyed by its id).
+const (
+	noteTargetCall   = "call"
+	noteTargetRollup = "rollup"
+)
+
+const (
+	maxCommentLength = 4000
+	notesChunkSize   = 500
+)
+
+type acknowledgement struct {
+	AckedAt time.Time `json:"acked_at"`
+	AckedBy string    `json:"acked_by,omitempty"`
+	Note    string    `json:"note,omitempty"`
+}
+
+type incidentComment struct {
+	ID        int64     `json:"id"`
+	Author    string    `json:"author,omitempty"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type incidentAckRequest struct {
+	By   string `json:"by"`
+	Note string `json:"note"`
+}
+
+type incidentAckResponse struct {
+	Filename string `json:"filename,omitempty"`
+	RollupID int64  `json:"rollup_id,omitempty"`
+	acknowledgement
+	Escalated *time.Time `json:"escalated_at,omitempty"`
+}
+
+type incidentCommentRequest struct {
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// handleIncident serves /api/incident/{filename}/ack and
+// /api/incident/{filename}/comments for a call.
+func (s *server) handleIncident(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/incident/"), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	filename, err := url.PathUnescape(parts[0])
+	if err != nil || filename == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if _, err := s.getTranscription(filename); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveIncidentNotes(w, r, noteTargetCall, filename, parts[1])
+}
+
+// serveIncidentNotes handles the ack and comments actions for a target the
+// caller has already resolved. POST .../ack acknowledges it; repeating the ack
+// returns the first one. GET .../comments lists the thread oldest first and
+// POST .../comments adds to it.
+func (s *server) serveIncidentNotes(w http.ResponseWriter, r *http.Request, target, id, action string) {
+	switch action {
+	case "ack":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req incidentAckRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid json", http.StatusBadRequest)
+				return
+			}
+		}
+		resp, err := s.acknowledge(target, id, strings.TrimSpace(req.By), strings.TrimSpace(req.Note))
+		if err != nil {
+			log.Printf("%s ack for %s failed: %v", target, id, err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, resp)
+	case "comments":
+		switch r.Method {
+		case http.MethodGet:
+			comments, err := s.loadComments(r.Context(), target, []string{id})
+			if err != nil {
+				log.Printf("%s comments for %s failed: %v", target, id, err)
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+			thread := comments[id]
+			if thread == nil {
+				thread = []incidentComment{}
+			}
+			respondJSON(w, map[string]interface{}{"comments": thread})
+		case http.MethodPost:
+			var req incidentCommentRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid json", http.StatusBadRequest)
+				return
+			}
+			comment := incidentComment{Author: strings.TrimSpace(req.Author), Body: strings.TrimSpace(req.Body), CreatedAt: time.Now().UTC()}
+			if comment.Body == "" {
+				http.Error(w, "body required", http.StatusBadRequest)
+				return
+			}
+			if len([]rune(comment.Body)) > maxCommentLength {
+				http.Error(w, "body must be at most 4000 characters", http.StatusBadRequest)
+				return
+			}
+			res, err := execWithRetry(s.db, `INSERT INTO incident_comments (target_type, target_id, author, body, created_at) VALUES (?, ?, ?, ?, ?)`,
+				target, id, comment.Author, comment.Body, comment.CreatedAt)
+			if err != nil {
+				log.Printf("%s comment for %s failed: %v", target, id, err)
+				http.Error(w, "db error", http.StatusInternalServerError)
+				return
+			}
+			comment.ID, _ = res.LastInsertId()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			respondJSON(w, comment)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// acknowledge records the first acknowledgement of a target and returns it.
+// For a call the response also carries when it was escalated, if it was.
+func (s *server) acknowledge(target, id, by, note string) (incidentAckResponse, error) {
+	resp := incidentAckResponse{}
+	if _, err := execWithRetry(s.db, `INSERT OR IGNORE INTO acknowledgements (target_type, target_id, acked_at, acked_by, note) VALUES (?, ?, ?, ?, ?)`,
+		target, id, time.Now().UTC(), by, note); err != nil {
+		return resp, err
+	}
+	var escalated sql.NullTime
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&resp.AckedAt, &resp.AckedBy, &resp.Note, &escalated)
+	}, `SELECT a.acked_at, a.acked_by, a.note, e.escalated_at FROM acknowledgements a
+LEFT JOIN incident_escalations e ON a.target_type = 'call' AND e.filename = a.target_id
+WHERE a.target_type = ? AND a.target_id = ?`, target, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return resp, err
+	}
+	if escalated.Valid {
+		resp.Escalated = &escalated.Time
+	}
+	if target == noteTargetRollup {
+		resp.RollupID, _ = strconv.ParseInt(id, 10, 64)
+	} else {
+		resp.Filename = id
+	}
+	return resp, nil
+}
+
+// loadAcks returns the acknowledgements of the given targets keyed by id.
+func (s *server) loadAcks(ctx context.Context, target string, ids []string) (map[string]*acknowledgement, error) {
+	acks := make(map[string]*acknowledgement)
+	if len(ids) == 0 {
+		return acks, nil
+	}
+	query, args := notesQuery(`SELECT target_id, acked_at, acked_by, note FROM acknowledgements`, target, ids)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var ack acknowledgement
+		if err := rows.Scan(&id, &ack.AckedAt, &ack.AckedBy, &ack.Note); err != nil {
+			return nil, err
+		}
+		acks[id] = &ack
+	}
+	return acks, rows.Err()
+}
+
+// loadComments returns the comment threads of the given targets keyed by id,
+// oldest comment first.
+func (s *server) loadComments(ctx context.Context, target string, ids []string) (map[string][]incidentComment, error) {
+	comments := make(map[string][]incidentComment)
+	if len(ids) == 0 {
+		return comments, nil
+	}
+	query, args := notesQuery(`SELECT target_id, id, author, body, created_at FROM incident_comments`, target, ids)
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var c incidentComment
+		if err := rows.Scan(&id, &c.ID, &c.Author, &c.Body, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments[id] = append(comments[id], c)
+	}
+	return comments, rows.Err()
+}
+
+func notesQuery(base, target string, ids []string) (string, []interface{}) {
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, target)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return base + ` WHERE target_type = ? AND target_id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`, args
+}
+
+// attachCallNotes fills in the acknowledgement and comments of each call.
+// Notes are for duty officers, so only admin requests see them.
+func (s *server) attachCallNotes(r *http.Request, calls []transcriptionResponse) {
+	if len(calls) == 0 || !isAdminRequest(r) {
+		return
+	}
+	ids := make([]string, len(calls))
+	for i, call := range calls {
+		ids[i] = call.Filename
+	}
+	acks, comments, err := s.loadNotes(r.Context(), noteTargetCall, ids)
+	if err != nil {
+		log.Printf("load call notes failed: %v", err)
+		return
+	}
+	for i := range calls {
+		calls[i].Acknowledgement = acks[calls[i].Filename]
+		calls[i].Comments = comments[calls[i].Filename]
+	}
+}
+
+// attachRollupNotes is attachCallNotes for rollups.
+func (s *server) attachRollupNotes(r *http.Request, rollups []rollupResponse) {
+	if len(rollups) == 0 || !isAdminRequest(r) {
+		return
+	}
+	ids := make([]string, len(rollups))
+	for i, rollup := range rollups {
+		ids[i] = strconv.FormatInt(rollup.RollupID, 10)
+	}
+	acks, comments, err := s.loadNotes(r.Context(), noteTargetRollup, ids)
+	if err != nil {
+		log.Printf("load rollup notes failed: %v", err)
+		return
+	}
+	for i := range rollups {
+		rollups[i].Acknowledgement = acks[ids[i]]
+		rollups[i].Comments = comments[ids[i]]
+	}
+}
+
+// loadNotes loads acknowledgements and comments for many targets, in chunks
+// that stay under SQLite's bound-parameter limit.
+func (s *server) loadNotes(ctx context.Context, target string, ids []string) (map[string]*acknowledgement, map[string][]incidentComment, error) {
+	acks := make(map[string]*acknowledgement)
+	comments := make(map[string][]incidentComment)
+	for start := 0; start < len(ids); start += notesChunkSize {
+		end := start + notesChunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunkAcks, err := s.loadAcks(ctx, target, ids[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		chunkComments, err := s.loadComments(ctx, target, ids[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		for id, ack := range chunkAcks {
+			acks[id] = ack
+		}
+		for id, thread := range chunkComments {
+			comments[id] = thread
+		}
+	}
+	return acks, comments, nil
+}
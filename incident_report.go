@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert_framework/pdf"
+)
+
+const (
+	reportMargin      = 40
+	reportBottom      = pdf.PageHeight - 48
+	reportMapWidth    = 220
+	reportMapHeight   = 165
+	reportMaxSegments = 40
+)
+
+type reportRollup struct {
+	ID        int64
+	Title     string
+	Summary   string
+	State     string
+	Priority  string
+	CallCount int
+	StartAt   time.Time
+	EndAt     time.Time
+}
+
+// handleIncidentReport serves GET /api/transcription/{file}/report.pdf: a
+// one-page record of the call for filing and chief reviews. It follows the
+// same visibility and redaction rules as the JSON detail; sections that do
+// not fit are cut off with an ellipsis.
+func (s *server) handleIncidentReport(w http.ResponseWriter, r *http.Request, filename string) {
+	t, err := s.getTranscription(filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("report fetch %s failed: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if !s.visibleTo(r, *t) {
+		http.NotFound(w, r)
+		return
+	}
+	calls := []transcriptionResponse{s.responseFor(r, *t, s.resolveBaseURL(r))}
+	s.attachCallNotes(r, calls)
+	call := calls[0]
+	rollup, err := s.rollupForCall(r.Context(), t.ID)
+	if err != nil {
+		log.Printf("report rollup lookup for %s failed: %v", filename, err)
+	}
+	var snapshot []byte
+	if call.Location != nil && call.Location.Latitude != 0 {
+		snapshot = s.mapSnapshot(r, call.Location.Latitude, call.Location.Longitude)
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.renderIncidentReport(call, rollup, snapshot).WriteTo(&buf); err != nil {
+		log.Printf("report render %s failed: %v", filename, err)
+		http.Error(w, "report unavailable", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", strings.TrimSuffix(filename, ".mp3")+"-report.pdf"))
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	w.Write(buf.Bytes())
+}
+
+func (s *server) rollupForCall(ctx context.Context, callID int64) (*reportRollup, error) {
+	var ru reportRollup
+	var title, summary, state sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT r.id, r.title, COALESCE(r.closing_summary, r.summary), r.state, r.priority, r.call_count, r.start_at, r.end_at
+FROM rollups r JOIN rollup_calls rc ON rc.rollup_id = r.id
+WHERE rc.call_id = ? ORDER BY r.updated_at DESC LIMIT 1`, callID).Scan(&ru.ID, &title, &summary, &state, &ru.Priority, &ru.CallCount, &ru.StartAt, &ru.EndAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ru.Title, ru.Summary, ru.State = title.String, summary.String, state.String
+	return &ru, nil
+}
+
+// mapSnapshot is the report's static map centred on the call, subject to the
+// map rate limit when it is not cached. It returns nil when the map is
+// unavailable; the report then says so.
+func (s *server) mapSnapshot(r *http.Request, lat, lng float64) []byte {
+	overlay := fmt.Sprintf("pin-l+d33(%.5f,%.5f)", lng, lat)
+	view := fmt.Sprintf("%.5f,%.5f,14,0", lng, lat)
+	return s.staticMapJPEG(r.Context(), overlay, view, reportMapWidth, reportMapHeight, func() bool {
+		return s.mapLimiter.allow(clientIP(r), time.Now())
+	})
+}
+
+// reportLayout places text top to bottom on a single page and stops with an
+// ellipsis once the page is full.
+type reportLayout struct {
+	page *pdf.Page
+	y    float64
+	full bool
+}
+
+func (l *reportLayout) line(x float64, size float64, bold bool, text string) bool {
+	if l.full {
+		return false
+	}
+	if l.y+size > reportBottom {
+		l.page.Text(x, l.y, size, false, "…")
+		l.full = true
+		return false
+	}
+	l.y += size + 3
+	l.page.Text(x, l.y, size, bold, text)
+	return true
+}
+
+func (l *reportLayout) paragraph(x, width, size float64, text string, maxLines int) {
+	lines := pdf.Wrap(text, size, width, false)
+	for i, ln := range lines {
+		if i == maxLines-1 && len(lines) > maxLines {
+			ln += " …"
+		}
+		if !l.line(x, size, false, ln) || i == maxLines-1 {
+			return
+		}
+	}
+}
+
+func (l *reportLayout) heading(text string) {
+	if l.full {
+		return
+	}
+	l.y += 10
+	if l.line(reportMargin, 11, true, text) {
+		l.y += 3
+		l.page.Line(reportMargin, l.y, pdf.PageWidth-reportMargin, l.y, 0.5, 0.6)
+	}
+}
+
+func (s *server) renderIncidentReport(call transcriptionResponse, rollup *reportRollup, snapshot []byte) *pdf.Document {
+	const width = pdf.PageWidth - 2*reportMargin
+	title := fallbackEmpty(call.PrettyTitle, call.Filename)
+	doc := pdf.New(title + " – Incident report")
+	page := doc.AddPage()
+	l := &reportLayout{page: page, y: reportMargin}
+
+	page.FillRect(0, 0, pdf.PageWidth, 28, 0.9)
+	page.Text(reportMargin, 18, 9, true, "SUSSEX COUNTY ALERTS")
+	page.Text(pdf.PageWidth-reportMargin-pdf.Width("INCIDENT REPORT", 9, true), 18, 9, true, "INCIDENT REPORT")
+	for _, ln := range pdf.Wrap(title, 16, width, true) {
+		l.line(reportMargin, 16, true, ln)
+	}
+	l.y += 6
+
+	callTime := call.CallTimestamp.In(s.tz)
+	rows := [][2]string{
+		{"Call time", callTime.Format("Mon Jan 2, 2006 3:04:05 PM MST")},
+		{"Agency", fallbackEmpty(call.PrimaryAgency, call.Agency)},
+		{"Town", fallbackEmpty(call.CityOrTown, call.Town)},
+		{"Call type", fallbackEmpty(call.NormalizedCallType, derefString(call.CallType, ""))},
+		{"Category", call.CallCategory},
+		{"Address", joinNonEmpty(", ", call.AddressLine, prefixed("at ", call.CrossStreet))},
+		{"Units", strings.Join(call.Units, ", ")},
+		{"Status", call.Status},
+		{"Recording", call.Filename},
+	}
+	if call.IncidentID != "" && call.IncidentID != call.Filename {
+		rows = append(rows, [2]string{"Incident ID", call.IncidentID})
+	}
+	if call.Location != nil && call.Location.Latitude != 0 {
+		rows = append(rows, [2]string{"Location", fmt.Sprintf("%s (%.5f, %.5f, %s)", call.Location.Label, call.Location.Latitude, call.Location.Longitude, fallbackEmpty(call.Location.Precision, "approximate"))})
+	}
+	if call.DurationSeconds != nil {
+		rows = append(rows, [2]string{"Duration", formatSegmentTime(*call.DurationSeconds)})
+	}
+	if ack := call.Acknowledgement; ack != nil {
+		rows = append(rows, [2]string{"Acknowledged", joinNonEmpty(" – ", ack.AckedAt.In(s.tz).Format("Jan 2 3:04 PM"), ack.AckedBy, ack.Note)})
+	}
+	if len(call.Flags) > 0 {
+		rows = append(rows, [2]string{"Flags", strings.Join(call.Flags, ", ")})
+	}
+
+	// Metadata sits left of the map snapshot.
+	const labelWidth = 70
+	mapX := float64(pdf.PageWidth - reportMargin - reportMapWidth)
+	top := l.y
+	textWidth := mapX - reportMargin - labelWidth - 12
+	for _, row := range rows {
+		if strings.TrimSpace(row[1]) == "" {
+			continue
+		}
+		for i, ln := range pdf.Wrap(row[1], 9, textWidth, false) {
+			l.y += 12
+			if i == 0 {
+				page.Text(reportMargin, l.y, 9, true, row[0])
+			}
+			page.Text(reportMargin+labelWidth, l.y, 9, false, ln)
+		}
+	}
+	mapTop := top + 4
+	if snapshot == nil || page.JPEG(mapX, mapTop, reportMapWidth, reportMapHeight, snapshot) != nil {
+		page.FillRect(mapX, mapTop, reportMapWidth, reportMapHeight, 0.92)
+		page.Text(mapX+reportMapWidth/2-pdf.Width("Map unavailable", 9, false)/2, mapTop+reportMapHeight/2, 9, false, "Map unavailable")
+	}
+	if bottom := mapTop + reportMapHeight; l.y < bottom {
+		l.y = bottom
+	}
+
+	if summary := fallbackEmpty(call.CleanSummary, call.Summary); summary != "" {
+		l.heading("Summary")
+		l.paragraph(reportMargin, width, 9.5, summary, 4)
+	}
+	if rollup != nil {
+		l.heading(fmt.Sprintf("Related incident #%d", rollup.ID))
+		l.line(reportMargin, 9, true, fallbackEmpty(rollup.Title, "Untitled rollup"))
+		l.line(reportMargin, 9, false, fmt.Sprintf("%d calls, %s to %s • priority %s • %s", rollup.CallCount,
+			rollup.StartAt.In(s.tz).Format("Jan 2 3:04 PM"), rollup.EndAt.In(s.tz).Format("3:04 PM"), rollup.Priority, fallbackEmpty(rollup.State, "active")))
+		if rollup.Summary != "" {
+			l.paragraph(reportMargin, width, 9, rollup.Summary, 3)
+		}
+	}
+	if txt := pickReportTranscript(call); txt != "" {
+		l.heading("Transcript")
+		l.paragraph(reportMargin, width, 9.5, txt, 12)
+	}
+	if len(call.Segments) > 0 {
+		l.heading("Timeline")
+		const timeWidth = 60
+		for i, seg := range call.Segments {
+			if i == reportMaxSegments || l.full {
+				break
+			}
+			who := fallbackEmpty(seg.Role, seg.Speaker)
+			text := strings.TrimSpace(seg.Text)
+			if who != "" {
+				text = who + ": " + text
+			}
+			lines := pdf.Wrap(text, 8.5, width-timeWidth, false)
+			if len(lines) == 0 || !l.line(reportMargin, 8.5, true, formatSegmentTime(seg.Start)) {
+				continue
+			}
+			page.Text(reportMargin+timeWidth, l.y, 8.5, false, lines[0])
+			for _, ln := range lines[1:] {
+				if !l.line(reportMargin+timeWidth, 8.5, false, ln) {
+					break
+				}
+			}
+		}
+	}
+	if len(call.Comments) > 0 {
+		l.heading("Comments")
+		for _, c := range call.Comments {
+			l.paragraph(reportMargin, width, 9, joinNonEmpty(" – ", c.CreatedAt.In(s.tz).Format("Jan 2 3:04 PM"), c.Author, c.Body), 3)
+		}
+	}
+
+	footer := fmt.Sprintf("Generated %s", time.Now().In(s.tz).Format("Jan 2, 2006 3:04 PM MST"))
+	if call.AudioURL != "" {
+		footer += " • " + call.AudioURL
+	}
+	page.Line(reportMargin, pdf.PageHeight-34, pdf.PageWidth-reportMargin, pdf.PageHeight-34, 0.5, 0.6)
+	page.Text(reportMargin, pdf.PageHeight-22, 7.5, false, footer)
+	return doc
+}
+
+// pickReportTranscript prefers the cleaned text; responseFor has already
+// swapped in the public transcript for non-admin requests.
+func pickReportTranscript(call transcriptionResponse) string {
+	for _, p := range []*string{call.CleanTranscript, call.NormalizedTranscript, call.Transcript} {
+		if p != nil && strings.TrimSpace(*p) != "" {
+			return normalizeWhitespace(*p)
+		}
+	}
+	return ""
+}
+
+func formatSegmentTime(seconds float64) string {
+	total := int(seconds + 0.5)
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+func joinNonEmpty(sep string, parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, sep)
+}
+
+func prefixed(prefix, s string) string {
+	if strings.TrimSpace(s) == "" {
+		return ""
+	}
+	return prefix + s
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/metrics"
+)
+
+const (
+	defaultIngestStaleMinutes = 30
+	maxIngestStaleMinutes     = 7 * 24 * 60
+)
+
+type ingestLag struct {
+	Samples     int     `json:"samples"`
+	LastSeconds float64 `json:"last_seconds"`
+	AvgSeconds  float64 `json:"avg_seconds"`
+	P95Seconds  float64 `json:"p95_seconds"`
+	MaxSeconds  float64 `json:"max_seconds"`
+}
+
+type ingestSourceStats struct {
+	Source          string     `json:"source"`
+	Enqueued        int64      `json:"enqueued"`
+	Completed       int64      `json:"completed"`
+	LastModifiedAt  *time.Time `json:"last_file_modified_at"`
+	LastEnqueuedAt  *time.Time `json:"last_enqueued_at"`
+	LastCompletedAt *time.Time `json:"last_completed_at"`
+	IdleSeconds     float64    `json:"idle_seconds"`
+	Stale           bool       `json:"stale"`
+	EnqueueLag      ingestLag  `json:"enqueue_lag"`
+	ProcessLag      ingestLag  `json:"process_lag"`
+}
+
+type ingestStatsResponse struct {
+	GeneratedAt       time.Time           `json:"generated_at"`
+	StaleAfterMinutes int                 `json:"stale_after_minutes"`
+	Sources           []ingestSourceStats `json:"sources"`
+}
+
+// recordIngest feeds the per-source lag metrics for a newly queued job. Forced
+// enqueues are reprocesses or uploads whose mtime says nothing about how fast
+// the source delivered them, so only their enqueue time is counted.
+func (s *server) recordIngest(job processJob, path string) {
+	if s.metrics == nil {
+		return
+	}
+	var modified time.Time
+	if !job.force {
+		if info, err := os.Stat(path); err == nil {
+			modified = info.ModTime().UTC()
+		}
+	}
+	s.metrics.RecordEnqueue(job.source, modified, job.enqueuedAt)
+}
+
+// handleIngestStats serves GET /api/stats/ingest: per-source enqueue counts
+// and lag since the service started. A source is stale when nothing has been
+// queued from it for stale_after minutes (default 30), which points at a
+// stalled recorder or push even while the service itself is healthy.
+func (s *server) handleIngestStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	staleMinutes := defaultIngestStaleMinutes
+	if raw := strings.TrimSpace(r.URL.Query().Get("stale_after")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxIngestStaleMinutes {
+			http.Error(w, "stale_after must be between 1 and 10080", http.StatusBadRequest)
+			return
+		}
+		staleMinutes = v
+	}
+	now := time.Now().UTC()
+	resp := ingestStatsResponse{GeneratedAt: now, StaleAfterMinutes: staleMinutes, Sources: []ingestSourceStats{}}
+	if s.metrics == nil {
+		respondJSON(w, resp)
+		return
+	}
+	staleAfter := time.Duration(staleMinutes) * time.Minute
+	for _, src := range s.metrics.IngestSnapshot() {
+		idle := now.Sub(src.LastEnqueuedAt)
+		resp.Sources = append(resp.Sources, ingestSourceStats{
+			Source:          src.Source,
+			Enqueued:        src.Enqueued,
+			Completed:       src.Completed,
+			LastModifiedAt:  optionalTime(src.LastModifiedAt),
+			LastEnqueuedAt:  optionalTime(src.LastEnqueuedAt),
+			LastCompletedAt: optionalTime(src.LastCompletedAt),
+			IdleSeconds:     idle.Seconds(),
+			Stale:           idle > staleAfter,
+			EnqueueLag:      toIngestLag(src.EnqueueLag),
+			ProcessLag:      toIngestLag(src.ProcessLag),
+		})
+	}
+	respondJSON(w, resp)
+}
+
+func toIngestLag(l metrics.LagSummary) ingestLag {
+	return ingestLag{
+		Samples:     l.Samples,
+		LastSeconds: l.Last.Seconds(),
+		AvgSeconds:  l.Avg.Seconds(),
+		P95Seconds:  l.P95.Seconds(),
+		MaxSeconds:  l.Max.Seconds(),
+	}
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// integrityStartDelay lets startup work settle before the first scan.
+	integrityStartDelay = 5 * time.Minute
+	// integritySamples is how many filenames a report keeps per column.
+	integritySamples = 10
+	// integrityRepairBatch bounds the repairs per column in one run; the
+	// rest are picked up by the next.
+	integrityRepairBatch = 1000
+	integrityKeepRuns    = 100
+)
+
+var errIntegrityRunning = errors.New("integrity check already running")
+
+// integrityColumn is a transcriptions column holding JSON. Readers of these
+// columns swallow parse errors, so a malformed value quietly turns off the
+// feature that uses it (tag filters, town matching, address display,
+// similar calls) instead of failing. kind is the JSON type the column must
+// hold. salvage rewrites a malformed value into a valid one; without it, or
+// when it returns nil, the value is cleared.
+type integrityColumn struct {
+	name     string
+	kind     string
+	nonEmpty bool
+	salvage  func(raw string) *string
+}
+
+// Cleared embeddings are recomputed by the embedding backfill.
+var integrityColumns = []integrityColumn{
+	{name: "tags", kind: "array", salvage: salvageStringList},
+	{name: "recognized_towns", kind: "array", salvage: salvageStringList},
+	{name: "address_json", kind: "object"},
+	{name: "refined_metadata", kind: "object"},
+	{name: "embedding", kind: "array", nonEmpty: true},
+}
+
+type integrityReport struct {
+	ID          int64                   `json:"id,omitempty"`
+	StartedAt   time.Time               `json:"started_at"`
+	FinishedAt  time.Time               `json:"finished_at"`
+	Repair      bool                    `json:"repair"`
+	RowsChecked int                     `json:"rows_checked"`
+	Malformed   int                     `json:"malformed"`
+	Repaired    int                     `json:"repaired"`
+	Columns     []integrityColumnReport `json:"columns"`
+	Error       string                  `json:"error,omitempty"`
+}
+
+type integrityColumnReport struct {
+	Column    string   `json:"column"`
+	Malformed int      `json:"malformed"`
+	Repaired  int      `json:"repaired"`
+	Samples   []string `json:"samples,omitempty"`
+}
+
+// statusIntegrity is the last integrity scan as /api/status shows it:
+// counts only, no filenames.
+type statusIntegrity struct {
+	CheckedAt time.Time      `json:"checked_at"`
+	Malformed map[string]int `json:"malformed"`
+	Repaired  int            `json:"repaired"`
+}
+
+func migrateAddIntegrityRuns(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS integrity_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NOT NULL,
+    repair INTEGER NOT NULL DEFAULT 0,
+    rows_checked INTEGER NOT NULL DEFAULT 0,
+    malformed INTEGER NOT NULL DEFAULT 0,
+    repaired INTEGER NOT NULL DEFAULT 0,
+    columns_json TEXT NOT NULL,
+    error TEXT NULL
+);`)
+	return err
+}
+
+// startIntegrityChecker scans the JSON columns every INTEGRITY_CHECK_HOURS,
+// starting a few minutes after startup.
+func (s *server) startIntegrityChecker(ctx context.Context) {
+	if s.cfg.IntegrityCheckHours <= 0 {
+		return
+	}
+	go func() {
+		timer := time.NewTimer(integrityStartDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-timer.C:
+			}
+			if _, err := s.runIntegrityCheck(ctx, s.liveConfig().IntegrityRepair); err != nil && ctx.Err() == nil {
+				log.Printf("integrity check failed: %v", err)
+			}
+			timer.Reset(time.Duration(s.cfg.IntegrityCheckHours) * time.Hour)
+		}
+	}()
+}
+
+// runIntegrityCheck scans every integrity column, repairs what it finds when
+// repair is set, and records the run. Only one run happens at a time.
+func (s *server) runIntegrityCheck(ctx context.Context, repair bool) (integrityReport, error) {
+	if !s.integrity.TryLock() {
+		return integrityReport{}, errIntegrityRunning
+	}
+	defer s.integrity.Unlock()
+
+	report := integrityReport{StartedAt: time.Now().UTC(), Repair: repair, Columns: []integrityColumnReport{}}
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&report.RowsChecked)
+	}, `SELECT COUNT(*) FROM transcriptions`)
+	for _, col := range integrityColumns {
+		if err != nil {
+			break
+		}
+		var cr integrityColumnReport
+		cr, err = s.checkIntegrityColumn(ctx, col, repair)
+		report.Columns = append(report.Columns, cr)
+		report.Malformed += cr.Malformed
+		report.Repaired += cr.Repaired
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.FinishedAt = time.Now().UTC()
+	if report.Malformed > 0 {
+		log.Printf("integrity check found %d malformed values (%d repaired)", report.Malformed, report.Repaired)
+	}
+	if report.Repaired > 0 {
+		s.queries.invalidate()
+	}
+	if serr := s.saveIntegrityReport(&report); serr != nil && err == nil {
+		err = serr
+	}
+	return report, err
+}
+
+// checkIntegrityColumn counts the malformed values in col and repairs up to
+// integrityRepairBatch of them. A repair only lands if the value has not
+// changed since it was read.
+func (s *server) checkIntegrityColumn(ctx context.Context, col integrityColumn, repair bool) (integrityColumnReport, error) {
+	report := integrityColumnReport{Column: col.name}
+	valid := fmt.Sprintf("json_type(%s) = '%s'", col.name, col.kind)
+	if col.nonEmpty {
+		valid += fmt.Sprintf(" AND json_array_length(%s) > 0", col.name)
+	}
+	// CASE keeps json_type from seeing invalid JSON, which it rejects.
+	query := fmt.Sprintf(`SELECT filename, %[1]s FROM transcriptions WHERE %[1]s IS NOT NULL AND NOT COALESCE(CASE WHEN json_valid(%[1]s) THEN %[2]s END, 0)`, col.name, valid)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return report, err
+	}
+	type fix struct {
+		filename, old string
+		value         *string
+	}
+	var fixes []fix
+	for rows.Next() {
+		var filename, raw string
+		if err := rows.Scan(&filename, &raw); err != nil {
+			rows.Close()
+			return report, err
+		}
+		report.Malformed++
+		if len(report.Samples) < integritySamples {
+			report.Samples = append(report.Samples, filename)
+		}
+		if repair && len(fixes) < integrityRepairBatch {
+			f := fix{filename: filename, old: raw}
+			if col.salvage != nil {
+				f.value = col.salvage(raw)
+			}
+			fixes = append(fixes, f)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+	for _, f := range fixes {
+		repaired, err := s.repairIntegrityValue(col, f.filename, f.old, f.value)
+		if err != nil {
+			return report, err
+		}
+		if repaired {
+			report.Repaired++
+		}
+	}
+	return report, nil
+}
+
+// repairIntegrityValue replaces old with value in the call's col, or clears
+// it when value is nil. It reports false when the stored value is no longer
+// old, as when the pipeline rewrote the call after the scan read it.
+func (s *server) repairIntegrityValue(col integrityColumn, filename, old string, value *string) (bool, error) {
+	res, err := execWithRetry(s.db, fmt.Sprintf(`UPDATE transcriptions SET %[1]s = ? WHERE filename = ? AND %[1]s = ?`, col.name), value, filename, old)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// salvageStringList recovers a list stored as "a, b" or a broken JSON array,
+// the way parseRecognizedTownList reads it. Well-formed JSON of another
+// shape, such as an object, holds no list and is left to be cleared.
+func salvageStringList(raw string) *string {
+	if json.Valid([]byte(raw)) {
+		return nil
+	}
+	items := parseRecognizedTownList(&raw)
+	if len(items) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(items)
+	out := string(data)
+	return &out
+}
+
+func (s *server) saveIntegrityReport(report *integrityReport) error {
+	columns, _ := json.Marshal(report.Columns)
+	res, err := execWithRetry(s.db, `INSERT INTO integrity_runs (started_at, finished_at, repair, rows_checked, malformed, repaired, columns_json, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		report.StartedAt, report.FinishedAt, boolToInt(report.Repair), report.RowsChecked, report.Malformed, report.Repaired, string(columns), nullableString(report.Error))
+	if err != nil {
+		return err
+	}
+	report.ID, _ = res.LastInsertId()
+	_, err = execWithRetry(s.db, `DELETE FROM integrity_runs WHERE id <= ?`, report.ID-integrityKeepRuns)
+	return err
+}
+
+func (s *server) loadIntegrityReports(ctx context.Context, limit int) ([]integrityReport, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, started_at, finished_at, repair, rows_checked, malformed, repaired, columns_json, COALESCE(error, '') FROM integrity_runs ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reports := []integrityReport{}
+	for rows.Next() {
+		var r integrityReport
+		var repair int
+		var columns string
+		if err := rows.Scan(&r.ID, &r.StartedAt, &r.FinishedAt, &repair, &r.RowsChecked, &r.Malformed, &r.Repaired, &columns, &r.Error); err != nil {
+			return nil, err
+		}
+		r.Repair = repair != 0
+		_ = json.Unmarshal([]byte(columns), &r.Columns)
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+// integrityStatus summarizes the last integrity scan for /api/status, with
+// an issue when malformed values were left in place.
+func (s *server) integrityStatus(ctx context.Context) (*statusIntegrity, string) {
+	reports, err := s.loadIntegrityReports(ctx, 1)
+	if err != nil {
+		log.Printf("status integrity failed: %v", err)
+		return nil, ""
+	}
+	if len(reports) == 0 {
+		return nil, ""
+	}
+	last := reports[0]
+	out := &statusIntegrity{CheckedAt: last.FinishedAt, Malformed: map[string]int{}, Repaired: last.Repaired}
+	var bad []string
+	for _, c := range last.Columns {
+		out.Malformed[c.Column] = c.Malformed
+		if c.Malformed > c.Repaired {
+			bad = append(bad, c.Column)
+		}
+	}
+	if left := last.Malformed - last.Repaired; left > 0 {
+		return out, fmt.Sprintf("%d malformed values in %s", left, strings.Join(bad, ", "))
+	}
+	return out, ""
+}
+
+// handleIntegrity serves /api/admin/integrity. GET lists recent scans with
+// sample filenames; POST runs one now, repairing with ?repair=true.
+func (s *server) handleIntegrity(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		reports, err := s.loadIntegrityReports(r.Context(), 20)
+		if err != nil {
+			log.Printf("integrity report list failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"runs": reports})
+	case http.MethodPost:
+		repair := strings.EqualFold(r.URL.Query().Get("repair"), "true")
+		report, err := s.runIntegrityCheck(r.Context(), repair)
+		if err == errIntegrityRunning {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("integrity check failed: %v", err)
+			http.Error(w, "integrity check failed", http.StatusInternalServerError)
+			return
+		}
+		noteAudit(r, "integrity.run", "", nil, map[string]interface{}{"repair": repair, "malformed": report.Malformed, "repaired": report.Repaired})
+		respondJSON(w, report)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"alert_framework/config"
+)
+
+// setColumn stores value (NULL when nil) in col of the call named filename.
+func setColumn(t *testing.T, db *sql.DB, filename, col string, value interface{}) {
+	t.Helper()
+	if _, err := db.Exec(`UPDATE transcriptions SET `+col+` = ? WHERE filename = ?`, value, filename); err != nil {
+		t.Fatalf("set %s of %s: %v", col, filename, err)
+	}
+}
+
+func columnValue(t *testing.T, db *sql.DB, filename, col string) sql.NullString {
+	t.Helper()
+	var v sql.NullString
+	if err := db.QueryRow(`SELECT `+col+` FROM transcriptions WHERE filename = ?`, filename).Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func integrityColumnNamed(t *testing.T, name string) integrityColumn {
+	t.Helper()
+	for _, c := range integrityColumns {
+		if c.name == name {
+			return c
+		}
+	}
+	t.Fatalf("no integrity column %s", name)
+	return integrityColumn{}
+}
+
+func TestIntegrityCheckFindsMalformedValues(t *testing.T) {
+	s := newTestServer(t, config.Config{})
+	cases := []struct {
+		col, value string
+		malformed  bool
+	}{
+		{"tags", `["Fire","MVA"]`, false},
+		{"tags", `[]`, false},
+		{"tags", `Fire, MVA`, true},
+		{"tags", `{"tag":"Fire"}`, true},
+		{"tags", ``, true},
+		{"address_json", `{"label":"12 Main St"}`, false},
+		{"address_json", `["12 Main St"]`, true},
+		{"address_json", `{"label":`, true},
+		{"refined_metadata", `"just a string"`, true},
+		{"embedding", `[0.1,0.2]`, false},
+		{"embedding", `[]`, true},
+		{"embedding", `{}`, true},
+	}
+	want := map[string]int{}
+	for i, c := range cases {
+		name := fmt.Sprintf("call-%d.mp3", i)
+		insertTestCall(t, s.db, testCall{filename: name, transcript: "units responding"})
+		setColumn(t, s.db, name, c.col, c.value)
+		if c.malformed {
+			want[c.col]++
+		}
+	}
+	// NULL is a missing value, not a malformed one.
+	insertTestCall(t, s.db, testCall{filename: "empty.mp3", transcript: "units responding"})
+
+	report, err := s.runIntegrityCheck(context.Background(), false)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if report.RowsChecked != len(cases)+1 {
+		t.Errorf("rows checked = %d, want %d", report.RowsChecked, len(cases)+1)
+	}
+	for _, col := range report.Columns {
+		if col.Malformed != want[col.Column] {
+			t.Errorf("%s: %d malformed, want %d (samples %v)", col.Column, col.Malformed, want[col.Column], col.Samples)
+		}
+		if col.Repaired != 0 {
+			t.Errorf("%s: %d repaired without repair set", col.Column, col.Repaired)
+		}
+	}
+	if v := columnValue(t, s.db, "call-2.mp3", "tags"); v.String != "Fire, MVA" {
+		t.Errorf("scan without repair changed tags to %q", v.String)
+	}
+}
+
+func TestIntegrityRepairSalvagesLists(t *testing.T) {
+	s := newTestServer(t, config.Config{})
+	for name, value := range map[string]string{
+		"comma.mp3":  `Fire, MVA`,
+		"broken.mp3": `["Sparta", "Newton"`,
+		"object.mp3": `{}`,
+	} {
+		insertTestCall(t, s.db, testCall{filename: name, transcript: "units responding"})
+		setColumn(t, s.db, name, "tags", value)
+	}
+	insertTestCall(t, s.db, testCall{filename: "address.mp3", transcript: "units responding"})
+	setColumn(t, s.db, "address.mp3", "address_json", `{"label":`)
+
+	report, err := s.runIntegrityCheck(context.Background(), true)
+	if err != nil {
+		t.Fatalf("repair: %v", err)
+	}
+	if report.Malformed != 4 || report.Repaired != 4 {
+		t.Fatalf("malformed %d, repaired %d; want 4 and 4", report.Malformed, report.Repaired)
+	}
+	for name, want := range map[string]string{
+		"comma.mp3":  `["Fire","MVA"]`,
+		"broken.mp3": `["Sparta","Newton"]`,
+	} {
+		if got := columnValue(t, s.db, name, "tags"); got.String != want {
+			t.Errorf("%s tags = %q, want %q", name, got.String, want)
+		}
+	}
+	// Nothing to salvage from an object; columns without salvage are cleared.
+	if got := columnValue(t, s.db, "object.mp3", "tags"); got.Valid {
+		t.Errorf("object tags = %q, want NULL", got.String)
+	}
+	if got := columnValue(t, s.db, "address.mp3", "address_json"); got.Valid {
+		t.Errorf("address_json = %q, want NULL", got.String)
+	}
+	again, err := s.runIntegrityCheck(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Malformed != 0 {
+		t.Fatalf("%d malformed values left after repair", again.Malformed)
+	}
+}
+
+func TestIntegrityRepairSkipsValuesChangedSinceScan(t *testing.T) {
+	s := newTestServer(t, config.Config{})
+	insertTestCall(t, s.db, testCall{filename: "a.mp3", transcript: "units responding"})
+	setColumn(t, s.db, "a.mp3", "tags", `Fire, MVA`)
+	tags := integrityColumnNamed(t, "tags")
+
+	// The pipeline rewrote the call after the scan read "Fire, MVA".
+	setColumn(t, s.db, "a.mp3", "tags", `["Rescue"]`)
+	fixed := `["Fire","MVA"]`
+	repaired, err := s.repairIntegrityValue(tags, "a.mp3", `Fire, MVA`, &fixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repaired {
+		t.Fatal("repair overwrote a value written after the scan")
+	}
+	if got := columnValue(t, s.db, "a.mp3", "tags"); got.String != `["Rescue"]` {
+		t.Fatalf("tags = %q, want the pipeline's value", got.String)
+	}
+
+	repaired, err = s.repairIntegrityValue(tags, "a.mp3", `["Rescue"]`, nil)
+	if err != nil || !repaired {
+		t.Fatalf("repair of an unchanged value = %v, %v", repaired, err)
+	}
+	if got := columnValue(t, s.db, "a.mp3", "tags"); got.Valid {
+		t.Fatalf("tags = %q, want NULL", got.String)
+	}
+}
+
+func TestIntegrityRepairIsBatched(t *testing.T) {
+	s := newTestServer(t, config.Config{})
+	total := integrityRepairBatch + 5
+	tx, err := s.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < total; i++ {
+		if _, err := tx.Exec(`INSERT INTO transcriptions (filename, source_path, processed_path, ingest_source, status, embedding) VALUES (?, '', '', 'test', ?, '[]')`, fmt.Sprintf("call-%04d.mp3", i), statusDone); err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.checkIntegrityColumn(context.Background(), integrityColumnNamed(t, "embedding"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Malformed != total || first.Repaired != integrityRepairBatch {
+		t.Fatalf("first run: %d malformed, %d repaired; want %d and %d", first.Malformed, first.Repaired, total, integrityRepairBatch)
+	}
+	if len(first.Samples) != integritySamples {
+		t.Errorf("first run kept %d samples, want %d", len(first.Samples), integritySamples)
+	}
+	second, err := s.checkIntegrityColumn(context.Background(), integrityColumnNamed(t, "embedding"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Malformed != 5 || second.Repaired != 5 {
+		t.Fatalf("second run: %d malformed, %d repaired; want the 5 left over", second.Malformed, second.Repaired)
+	}
+}
@@ -0,0 +1,47 @@
+// Package jobstate is the lifecycle of a call's transcription row. A call is
+// queued, processed, and ends done or in error; deferral and reprocessing move
+// it back. Anything else, such as a queued call becoming done without being
+// processed, points at a lost or out-of-order write and is rejected.
+package jobstate
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Statuses stored in transcriptions.status. None is a call with no row yet.
+const (
+	None       = ""
+	Queued     = "queued"
+	Processing = "processing"
+	Done       = "done"
+	Error      = "error"
+)
+
+// ErrIllegalTransition is returned by Check for a change the lifecycle does
+// not allow.
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+var transitions = map[string][]string{
+	None: {Queued, Processing},
+	// A queued call may be requeued, picked up, or fail before it starts.
+	Queued: {Queued, Processing, Error},
+	// Processing restarts on retry and goes back to queued when deferred.
+	Processing: {Processing, Queued, Done, Error},
+	// Done calls are reprocessed either from audio or from the stored
+	// transcript, which rewrites the result in place.
+	Done: {Queued, Processing, Done},
+	// A failed call can be retried, or reprocessed from a transcript stored
+	// by an earlier successful run.
+	Error: {Queued, Processing, Done},
+}
+
+// Check returns nil if a call may move from status from to status to.
+func Check(from, to string) error {
+	for _, next := range transitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q to %q", ErrIllegalTransition, from, to)
+}
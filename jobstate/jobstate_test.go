@@ -0,0 +1,43 @@
+package jobstate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckAllowsLifecycle(t *testing.T) {
+	steps := [][2]string{
+		{None, Queued},
+		{Queued, Processing},
+		{Processing, Queued},
+		{Queued, Processing},
+		{Processing, Done},
+		{Done, Done},
+		{Done, Processing},
+		{Processing, Error},
+		{Error, Queued},
+	}
+	for _, step := range steps {
+		if err := Check(step[0], step[1]); err != nil {
+			t.Fatalf("expected %q -> %q to be allowed: %v", step[0], step[1], err)
+		}
+	}
+}
+
+func TestCheckRejectsIllegalTransitions(t *testing.T) {
+	cases := [][2]string{
+		{None, Done},
+		{None, Error},
+		{Queued, Done},
+		{Done, Error},
+		{Error, Error},
+		{"cad", Processing},
+		{Processing, "unknown"},
+	}
+	for _, c := range cases {
+		err := Check(c[0], c[1])
+		if !errors.Is(err, ErrIllegalTransition) {
+			t.Fatalf("expected %q -> %q to be rejected, got %v", c[0], c[1], err)
+		}
+	}
+}
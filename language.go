@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+
+	"alert_framework/formatting"
+)
+
+// transcriptsByLanguage keys the transcript and its English translation by
+// language code so clients can show both for non-English calls. It returns
+// nil for calls without a translation.
+func transcriptsByLanguage(t transcription) map[string]string {
+	language := derefString(t.DetectedLanguage, "")
+	translation := strings.TrimSpace(derefString(t.Translation, ""))
+	if language == "" || language == formatting.LanguageEnglish || translation == "" {
+		return nil
+	}
+	out := map[string]string{formatting.LanguageEnglish: translation}
+	if text := pickTranscript(&t); text != nil && strings.TrimSpace(*text) != "" {
+		out[language] = *text
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"alert_framework/errcode"
+)
+
+// workerLease names the lease the instance running the singleton worker
+// duties holds.
+const workerLease = "worker"
+
+func migrateAddLeaderLeases(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS leader_leases (
+	name TEXT PRIMARY KEY,
+	holder TEXT NOT NULL,
+	acquired_at DATETIME NOT NULL,
+	expires_at DATETIME NOT NULL
+)`)
+	return err
+}
+
+// leaderLease is a lease in the shared database that at most one instance
+// holds at a time. The holder renews it well before it expires; an instance
+// that stops renewing, crashed or cut off from the database, loses it once it
+// expires and another instance may take it.
+type leaderLease struct {
+	db     *sql.DB
+	name   string
+	holder string
+	ttl    time.Duration
+}
+
+// acquire takes the lease if it is free or expired, or renews it if this
+// instance already holds it, and reports whether this instance holds it now.
+func (l *leaderLease) acquire(now time.Time) (bool, error) {
+	now = now.UTC()
+	res, err := execWithRetry(l.db, `INSERT INTO leader_leases (name, holder, acquired_at, expires_at) VALUES (?, ?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+	acquired_at = CASE WHEN leader_leases.holder = excluded.holder THEN leader_leases.acquired_at ELSE excluded.acquired_at END,
+	holder = excluded.holder,
+	expires_at = excluded.expires_at
+WHERE leader_leases.holder = excluded.holder OR leader_leases.expires_at < ?`, l.name, l.holder, now, now.Add(l.ttl), now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// release gives the lease up so another instance can take over without
+// waiting for it to expire.
+func (l *leaderLease) release() {
+	if _, err := execWithRetry(l.db, `DELETE FROM leader_leases WHERE name = ? AND holder = ?`, l.name, l.holder); err != nil {
+		log.Printf("leader lease release failed: %v", err)
+	}
+}
+
+// leaderInfo is the current holder of the worker lease, for /api/status.
+type leaderInfo struct {
+	Enabled   bool       `json:"enabled"`
+	Instance  string     `json:"instance"`
+	Leading   bool       `json:"leading"`
+	Holder    string     `json:"holder,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (s *server) leaderStatus(ctx context.Context) *leaderInfo {
+	if !s.cfg.LeaderElection {
+		return nil
+	}
+	info := &leaderInfo{Enabled: true, Instance: s.cfg.InstanceID, Leading: s.leading.Load()}
+	var holder string
+	var since, expires time.Time
+	err := s.db.QueryRowContext(ctx, `SELECT holder, acquired_at, expires_at FROM leader_leases WHERE name = ?`, workerLease).Scan(&holder, &since, &expires)
+	if err == nil && expires.After(time.Now()) {
+		info.Holder, info.Since, info.ExpiresAt = holder, &since, &expires
+	} else if err != nil && err != sql.ErrNoRows {
+		log.Printf("leader status query failed: %v", err)
+	}
+	return info
+}
+
+// runAsLeader runs start, which launches the worker duties only one instance
+// may run (the CALLS_DIR watcher, schedulers and reapers), while this
+// instance is the leader. Without LEADER_ELECTION every worker instance is
+// its own leader and start runs at once. With it, start runs each time this
+// instance takes the lease, with a context that is cancelled if the lease is
+// lost, and the lease is released on shutdown.
+func (s *server) runAsLeader(ctx context.Context, start func(ctx context.Context)) {
+	if !s.cfg.LeaderElection {
+		s.leading.Store(true)
+		start(ctx)
+		return
+	}
+	ttl := time.Duration(s.cfg.LeaderLeaseSec) * time.Second
+	lease := &leaderLease{db: s.db, name: workerLease, holder: s.cfg.InstanceID, ttl: ttl}
+	log.Printf("leader election on: instance %s, lease %s", lease.holder, ttl)
+	go func() {
+		var stepDown context.CancelFunc
+		var renewedAt time.Time
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			held, err := lease.acquire(time.Now())
+			switch {
+			case err != nil:
+				log.Printf("leader lease renewal failed: %v", err)
+				// Stop before the lease can expire under us: by then another
+				// instance may already hold it.
+				if stepDown != nil && time.Since(renewedAt) > ttl/2 {
+					log.Printf("stepping down as leader: lease not renewed for %s", time.Since(renewedAt).Round(time.Second))
+					stepDown()
+					stepDown = nil
+					s.leading.Store(false)
+				}
+			case held && stepDown == nil:
+				renewedAt = time.Now()
+				var leaderCtx context.Context
+				leaderCtx, stepDown = context.WithCancel(ctx)
+				s.leading.Store(true)
+				log.Printf("instance %s is now the leader", lease.holder)
+				start(leaderCtx)
+			case held:
+				renewedAt = time.Now()
+			case stepDown != nil:
+				log.Printf("instance %s lost the leader lease", lease.holder)
+				stepDown()
+				stepDown = nil
+				s.leading.Store(false)
+			}
+			select {
+			case <-ctx.Done():
+				if stepDown != nil {
+					stepDown()
+					lease.release()
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// startStaleCallReaper replaces the startup sweep of interrupted calls when
+// several instances share the database: a call can be processing on a
+// follower when a new leader starts, so only calls left processing for longer
+// than any job may run are failed.
+func (s *server) startStaleCallReaper(ctx context.Context) {
+	maxAge := time.Duration(s.cfg.JobTimeoutMaxSec)*time.Second + time.Duration(s.cfg.LeaderLeaseSec)*time.Second
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			s.reapStaleCalls(time.Now().UTC().Add(-maxAge))
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *server) reapStaleCalls(cutoff time.Time) {
+	if _, err := execWithRetry(s.db, `INSERT INTO call_errors (filename, code, message, created_at) SELECT filename, ?, 'interrupted: worker stopped', ? FROM transcriptions WHERE status = ? AND updated_at < ?`, errcode.Interrupted, time.Now().UTC(), statusProcessing, cutoff); err != nil {
+		log.Printf("stale call error log failed: %v", err)
+	}
+	res, err := execWithRetry(s.db, `UPDATE transcriptions SET status = ?, last_error = 'interrupted: worker stopped', error_code = ? WHERE status = ? AND updated_at < ?`, statusError, errcode.Interrupted, statusProcessing, cutoff)
+	if err != nil {
+		log.Printf("stale call cleanup failed: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("marked %d stale processing calls as failed", n)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alert_framework/errcode"
+)
+
+// Transcription engines recorded in transcriptions.transcription_engine.
+const (
+	engineOpenAI        = "openai"
+	engineWhisperCPP    = "whisper.cpp"
+	engineFasterWhisper = "faster-whisper"
+)
+
+const defaultFasterWhisperModel = "small"
+
+func migrateAddTranscriptionEngine(db *sql.DB) error {
+	return addColumnIfMissing(db, "transcriptions", "transcription_engine", "TEXT NULL")
+}
+
+func downTranscriptionEngine(db *sql.DB) error {
+	return dropColumnIfExists(db, "transcriptions", "transcription_engine")
+}
+
+// transcribeAudio transcribes with OpenAI and falls back to the local Whisper
+// engine, when LOCAL_WHISPER_BIN is set, for files OpenAI will not take: too
+// large even after chunking, or rate limited. Other failures, and deferrals
+// while the OpenAI breaker is open, are returned as they are. It also
+// reports which engine produced the transcript.
+func (s *server) transcribeAudio(path string, opts TranscriptionOptions) (string, *string, *string, string, error) {
+	transcript, diarized, model, err := s.callOpenAIWithRetries(path, opts)
+	if err == nil {
+		return transcript, diarized, model, engineOpenAI, nil
+	}
+	reason := localWhisperReason(err)
+	if s.cfg.LocalWhisperBin == "" || reason == "" {
+		return "", nil, nil, "", err
+	}
+	log.Printf("transcribing %s locally with %s (%s): %v", filepath.Base(path), s.cfg.LocalWhisperEngine, reason, err)
+	start := time.Now()
+	text, localErr := s.transcribeLocal(path, opts)
+	if localErr != nil {
+		log.Printf("local transcription of %s failed: %v", filepath.Base(path), localErr)
+		return "", nil, nil, "", err
+	}
+	log.Printf("local transcription of %s took %s", filepath.Base(path), time.Since(start).Round(time.Millisecond))
+	localModel := s.localWhisperModel()
+	return text, nil, &localModel, s.cfg.LocalWhisperEngine, nil
+}
+
+// localWhisperReason says why err should be retried locally, or "" when it
+// should not.
+func localWhisperReason(err error) string {
+	if errcode.Of(err) == errcode.FileTooLarge {
+		return "file too large"
+	}
+	if errorCodeFor(err) == errcode.OpenAIRateLimit {
+		return "rate limited"
+	}
+	return ""
+}
+
+func (s *server) localWhisperModel() string {
+	if s.cfg.LocalWhisperModel != "" {
+		return s.cfg.LocalWhisperModel
+	}
+	return defaultFasterWhisperModel
+}
+
+// transcribeLocal runs the configured Whisper binary over path and returns
+// the plain-text transcript. whisper.cpp only reads 16 kHz WAV, so the audio
+// is converted first; faster-whisper (the whisper-ctranslate2 CLI) reads the
+// file as is. The run is bounded by JOB_TIMEOUT_MAX_SEC.
+func (s *server) transcribeLocal(path string, opts TranscriptionOptions) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.JobTimeoutMaxSec)*time.Second)
+	defer cancel()
+	// A subdirectory keeps the intermediate files away from the work janitor.
+	dir, err := os.MkdirTemp(s.cfg.WorkDir, "whisper-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	translate := opts.Mode == "translate"
+	var cmd *exec.Cmd
+	var outPath string
+	switch s.cfg.LocalWhisperEngine {
+	case engineFasterWhisper:
+		args := []string{path, "--model", s.localWhisperModel(), "--output_dir", dir, "--output_format", "txt"}
+		if opts.LanguageHint != "" {
+			args = append(args, "--language", opts.LanguageHint)
+		}
+		if translate {
+			args = append(args, "--task", "translate")
+		}
+		cmd = exec.CommandContext(ctx, s.cfg.LocalWhisperBin, args...)
+		outPath = filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+".txt")
+	default:
+		cmd, outPath, err = whisperCPPCommand(ctx, s.cfg.LocalWhisperBin, s.cfg.LocalWhisperModel, path, dir, opts.LanguageHint, translate)
+		if err != nil {
+			return "", err
+		}
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("%s timed out after %ds", s.cfg.LocalWhisperEngine, s.cfg.JobTimeoutMaxSec)
+		}
+		return "", fmt.Errorf("%s: %v: %s", s.cfg.LocalWhisperEngine, err, truncateText(strings.TrimSpace(string(out)), 500))
+	}
+	return readWhisperOutput(s.cfg.LocalWhisperEngine, outPath)
+}
+
+// whisperCPPCommand converts path to the 16 kHz WAV whisper.cpp reads, inside
+// dir, and returns the command that transcribes it and the path of the text
+// file the command writes. An empty language lets whisper.cpp detect it.
+func whisperCPPCommand(ctx context.Context, bin, model, path, dir, language string, translate bool) (*exec.Cmd, string, error) {
+	wav := filepath.Join(dir, "input.wav")
+	convert := exec.CommandContext(ctx, ffmpegBinary, "-y", "-v", "error", "-i", path, "-ac", "1", "-ar", "16000", "-c:a", "pcm_s16le", wav)
+	if out, err := convert.CombinedOutput(); err != nil {
+		return nil, "", errcode.Wrap(errcode.FFmpegFailed, fmt.Errorf("convert for whisper.cpp: %v: %s", err, truncateText(strings.TrimSpace(string(out)), 500)))
+	}
+	if language == "" {
+		language = "auto"
+	}
+	base := filepath.Join(dir, "transcript")
+	args := []string{"-m", model, "-f", wav, "-l", language, "-nt", "-np", "-otxt", "-of", base}
+	if translate {
+		args = append(args, "-tr")
+	}
+	return exec.CommandContext(ctx, bin, args...), base + ".txt", nil
+}
+
+// readWhisperOutput reads a transcript file written by engine, folding its
+// line breaks into single spaces.
+func readWhisperOutput(engine, outPath string) (string, error) {
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		return "", fmt.Errorf("%s output: %w", engine, err)
+	}
+	text := strings.Join(strings.Fields(string(data)), " ")
+	if text == "" {
+		return "", fmt.Errorf("empty transcript from %s", engine)
+	}
+	return text, nil
+}
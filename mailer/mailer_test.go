@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildPlainText(t *testing.T) {
+	raw, err := Build("Alerts <alerts@example.com>", Message{To: []string{"a@example.com"}, Subject: "Structure fire – Sparta", Text: "line one\nline two"}, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Structure fire – Sparta" {
+		t.Fatalf("subject = %q", subject)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Fatalf("message id = %q", msg.Header.Get("Message-ID"))
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if string(body) != "line one\r\nline two" {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestBuildHTMLWithInlineImage(t *testing.T) {
+	png := []byte("\x89PNG fake image data")
+	raw, err := Build("alerts@example.com", Message{
+		To:      []string{"a@example.com", "b@example.com"},
+		Subject: "Call",
+		Text:    "plain",
+		HTML:    `<img src="cid:preview">`,
+		Inline:  []Inline{{ContentID: "preview", ContentType: "image/png", Filename: "preview.png", Data: png}},
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("To"); got != "a@example.com, b@example.com" {
+		t.Fatalf("to = %q", got)
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/related" {
+		t.Fatalf("content type = %q", mediaType)
+	}
+	related := multipart.NewReader(msg.Body, params["boundary"])
+	alt, err := related.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(alt.Header.Get("Content-Type"), "multipart/alternative") {
+		t.Fatalf("first part = %q", alt.Header.Get("Content-Type"))
+	}
+	img, err := related.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Header.Get("Content-ID") != "<preview>" {
+		t.Fatalf("content id = %q", img.Header.Get("Content-ID"))
+	}
+	data, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, img))
+	if !bytes.Equal(data, png) {
+		t.Fatalf("inline data = %q", data)
+	}
+}
+
+func TestBuildRejectsBadRecipient(t *testing.T) {
+	if _, err := Build("alerts@example.com", Message{To: []string{"not an address"}, Text: "x"}, time.Now()); err == nil {
+		t.Fatal("expected error for invalid recipient")
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
-	"image/png"
 	"io"
 	"log"
 	"math"
@@ -29,20 +28,28 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"alert_framework/backend/refine"
+	"alert_framework/breaker"
 	"alert_framework/config"
+	"alert_framework/errcode"
 	"alert_framework/formatting"
+	"alert_framework/geofence"
+	"alert_framework/jobstate"
 	"alert_framework/metrics"
+	"alert_framework/milemarker"
+	"alert_framework/prompts"
 	"alert_framework/queue"
 	"alert_framework/rollups"
+	"alert_framework/stations"
+	"alert_framework/usage"
 	"alert_framework/version"
-	"github.com/fsnotify/fsnotify"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/basicfont"
 	"golang.org/x/image/math/fixed"
+	"google.golang.org/grpc"
 	_ "modernc.org/sqlite"
 )
 
@@ -71,13 +78,10 @@ var (
 	}
 	ffmpegBinary                 = "ffmpeg"
 	audioFilterEnabled           = true
-	sussexMinLat                 = 40.9
-	sussexMaxLat                 = 41.4
-	sussexMinLng                 = -75.2
-	sussexMaxLng                 = -74.3
 	sussexTowns                  = []string{"Andover", "Byram", "Frankford", "Franklin", "Green", "Hamburg", "Hardyston", "Hopatcong", "Lafayette", "Montague", "Newton", "Ogdensburg", "Sandyston", "Sparta", "Stanhope", "Stillwater", "Sussex", "Vernon", "Wantage", "Fredon", "Branchville"}
 	warrenTowns                  = []string{"Allamuchy", "Alpha", "Belvidere", "Blairstown", "Franklin", "Frelinghuysen", "Greenwich", "Hackettstown", "Hardwick", "Harmony", "Hope", "Independence", "Knowlton", "Liberty", "Lopatcong", "Mansfield", "Oxford", "Phillipsburg", "Pohatcong", "Washington Boro", "Washington Township", "White"}
 	defaultCleanupPrompt         = buildCleanupPrompt()
+	spanishCleanupPrompt         = buildSpanishCleanupPrompt()
 	defaultMetadataPrompt        = buildMetadataPrompt()
 	countyByTown                 = buildCountyLookup()
 	addressPattern               = regexp.MustCompile(`(?i)(\b\d{3,6}\s+[A-Za-z0-9'\.\s]+\s+(Street|St|Road|Rd|Avenue|Ave|Highway|Hwy|Route|Rt|Lane|Ln|Drive|Dr|Court|Ct|Place|Pl|Way|Pike|Circle|Cir))`)
@@ -96,6 +100,22 @@ func buildCleanupPrompt() string {
 	}, " ")
 }
 
+// buildSpanishCleanupPrompt is used instead of the cleanup prompt registry for
+// calls detected as Spanish; the English prompts tend to translate or drop
+// Spanish speech rather than correct it.
+func buildSpanishCleanupPrompt() string {
+	townList := "Sussex County towns: " + strings.Join(sussexTowns, ", ") + "."
+	warrenList := "Warren County towns: " + strings.Join(warrenTowns, ", ") + "."
+	return strings.Join([]string{
+		"You are cleaning Spanish-language emergency calls for Sussex and Warren County, NJ.",
+		"Fix transcription errors and keep the transcript in Spanish; do not translate it.",
+		"Town, street and agency names stay in English; fix misheard town names to the closest match from the lists below.",
+		townList,
+		warrenList,
+		"Return JSON with fields normalized_transcript and recognized_towns (array). Maintain the original meaning and avoid adding new details.",
+	}, " ")
+}
+
 func buildMetadataPrompt() string {
 	sussexFocus := "Prefer addresses within Sussex County, New Jersey (especially Andover Township and surrounding boroughs)."
 	output := "Return JSON with address_line, municipality, county, cross_street, confidence (0-1 float), and notes explaining the decision."
@@ -121,29 +141,33 @@ func buildCountyLookup() map[string]string {
 	return counties
 }
 
-func isWithinSussexCounty(lat, lng float64) bool {
-	if lat == 0 && lng == 0 {
-		return false
-	}
-	return lat >= sussexMinLat && lat <= sussexMaxLat && lng >= sussexMinLng && lng <= sussexMaxLng
+// regionBBox is the service area's bounding box in geocoder bbox order. It
+// only biases geocoding; results are still checked against the polygon.
+func (s *server) regionBBox() []float64 {
+	minLng, minLat, maxLng, maxLat := s.region.BBox()
+	return []float64{minLng, minLat, maxLng, maxLat}
 }
 
-// transcription statuses
+// transcription statuses; jobstate decides which changes are allowed.
 const (
-	statusQueued     = "queued"
-	statusProcessing = "processing"
-	statusDone       = "done"
-	statusError      = "error"
+	statusQueued     = jobstate.Queued
+	statusProcessing = jobstate.Processing
+	statusDone       = jobstate.Done
+	statusError      = jobstate.Error
 )
 
 const (
 	processingStaleAfter = 3 * time.Hour
 )
 
+// migration is one schema step. down reverses up for migrations that can be
+// undone without losing data the older schema still holds; it is nil for the
+// rest (see migrations.go).
 type migration struct {
 	version int
 	name    string
 	up      func(db *sql.DB) error
+	down    func(db *sql.DB) error
 }
 
 // DTOs
@@ -160,6 +184,7 @@ type transcription struct {
 	Translation          *string    `json:"translation_text"`
 	Status               string     `json:"status"`
 	LastError            *string    `json:"last_error"`
+	ErrorCode            *string    `json:"error_code"`
 	SizeBytes            *int64     `json:"size_bytes"`
 	DurationSeconds      *float64   `json:"duration_seconds"`
 	Hash                 *string    `json:"hash"`
@@ -181,14 +206,27 @@ type transcription struct {
 	RefinedMetadata      *string    `json:"refined_metadata"`
 	AddressJSON          *string    `json:"address_json"`
 	NeedsManualReview    bool       `json:"needs_manual_review"`
+	CADIncidentID        *int64     `json:"cad_incident_id"`
+	Units                *string    `json:"units"`
+	PublicTranscript     *string    `json:"public_transcript"`
+	DetectedLanguage     *string    `json:"detected_language"`
+	SpeakerRoles         *string    `json:"speaker_roles"`
+	TranscriptionEngine  *string    `json:"transcription_engine"`
+	DraftTranscript      *string    `json:"draft_transcript"`
+	StationsJSON         *string    `json:"station_distances"`
+	PageFor              *string    `json:"page_for"`
+	DeletedAt            *time.Time `json:"deleted_at"`
 	CreatedAt            time.Time  `json:"created_at"`
 	UpdatedAt            time.Time  `json:"updated_at"`
 	Similar              []similar  `json:"similar,omitempty"`
 }
 
 type similar struct {
-	Filename string  `json:"filename"`
-	Score    float64 `json:"score"`
+	Filename   string   `json:"filename"`
+	Score      float64  `json:"score"`
+	CallType   string   `json:"call_type,omitempty"`
+	Snippet    string   `json:"snippet,omitempty"`
+	SharedTags []string `json:"shared_tags,omitempty"`
 }
 
 type transcriptSegment struct {
@@ -196,6 +234,7 @@ type transcriptSegment struct {
 	End     float64 `json:"end"`
 	Text    string  `json:"text"`
 	Speaker string  `json:"speaker,omitempty"`
+	Role    string  `json:"role,omitempty"`
 }
 
 type transcriptionResponse struct {
@@ -209,10 +248,14 @@ type transcriptionResponse struct {
 	Translation          *string             `json:"translation_text,omitempty"`
 	Status               string              `json:"status"`
 	LastError            *string             `json:"last_error,omitempty"`
+	ErrorCode            *string             `json:"error_code,omitempty"`
 	SizeBytes            *int64              `json:"size_bytes,omitempty"`
 	DurationSeconds      *float64            `json:"duration_seconds,omitempty"`
 	Hash                 *string             `json:"hash,omitempty"`
 	DuplicateOf          *string             `json:"duplicate_of,omitempty"`
+	Aliases              []string            `json:"aliases,omitempty"`
+	PageFor              *string             `json:"page_for,omitempty"`
+	Pages                []string            `json:"pages,omitempty"`
 	RequestedModel       *string             `json:"requested_model,omitempty"`
 	RequestedMode        *string             `json:"requested_mode,omitempty"`
 	RequestedFormat      *string             `json:"requested_format,omitempty"`
@@ -246,9 +289,23 @@ type transcriptionResponse struct {
 	Tags                 []string            `json:"tags,omitempty"`
 	Segments             []transcriptSegment `json:"segments,omitempty"`
 	Location             *locationGuess      `json:"location,omitempty"`
+	Marker               *mapMarker          `json:"marker,omitempty"`
 	RefinedMetadata      *string             `json:"refined_metadata,omitempty"`
 	AddressJSON          *string             `json:"address_json,omitempty"`
 	NeedsManualReview    bool                `json:"needs_manual_review"`
+	CADIncidentID        *int64              `json:"cad_incident_id,omitempty"`
+	Units                []string            `json:"units,omitempty"`
+	PublicTranscript     *string             `json:"public_transcript,omitempty"`
+	DetectedLanguage     *string             `json:"detected_language,omitempty"`
+	TranscriptionEngine  *string             `json:"transcription_engine,omitempty"`
+	DraftTranscript      *string             `json:"draft_transcript,omitempty"`
+	NearestStations      []stationDistance   `json:"nearest_stations,omitempty"`
+	DeletedAt            *time.Time          `json:"deleted_at,omitempty"`
+	Transcripts          map[string]string   `json:"transcripts,omitempty"`
+	Flags                []string            `json:"flags,omitempty"`
+	Acknowledgement      *acknowledgement    `json:"acknowledgement,omitempty"`
+	Comments             []incidentComment   `json:"comments,omitempty"`
+	RepeatLocation       *repeatLocation     `json:"repeat_location,omitempty"`
 }
 
 type locationGuess struct {
@@ -297,15 +354,15 @@ type lastSixHourStatsResponse struct {
 	TopAgencies      []tagCount              `json:"top_agencies"`
 	IncidentsPerHour []hourlyCount           `json:"incidents_per_hour"`
 	Calls            []transcriptionResponse `json:"calls"`
-	MapboxToken      string                  `json:"mapbox_token,omitempty"`
+	MapEnabled       bool                    `json:"map_enabled"`
 	Window           string                  `json:"window"`
 }
 
 type callListResponse struct {
-	Window      string                  `json:"window"`
-	Calls       []transcriptionResponse `json:"calls"`
-	Stats       callStats               `json:"stats"`
-	MapboxToken string                  `json:"mapbox_token,omitempty"`
+	Window     string                  `json:"window"`
+	Calls      []transcriptionResponse `json:"calls"`
+	Stats      callStats               `json:"stats"`
+	MapEnabled bool                    `json:"map_enabled"`
 }
 
 type hotspotSummary struct {
@@ -332,6 +389,10 @@ type processJob struct {
 	prettyTitle string
 	publicURL   string
 	baseURL     string
+	// pendingAlertSent is set once the audio-only alert went out during an OpenAI outage.
+	pendingAlertSent bool
+	// enqueuedAt is when the call was first queued; deferred requeues keep it.
+	enqueuedAt time.Time
 }
 
 type TranscriptionOptions struct {
@@ -348,20 +409,29 @@ type AppSettings struct {
 	DefaultMode       string
 	DefaultFormat     string
 	AutoTranslate     bool
-	WebhookEndpoints  []string
+	WebhookEndpoints  []WebhookEndpoint
 	PreferredLanguage string
 	CleanupPrompt     string
 	MetadataPrompt    string
+	Email             EmailSettings
 }
 
 type server struct {
 	db             *sql.DB
 	queue          *queue.Queue
 	metrics        *metrics.Metrics
+	workFiles      *workJanitor
 	running        sync.Map // filename -> struct{}
+	remoteFiles    sync.Map // filename -> struct{} while a remote poller or upload enqueues it
+	uploadLocks    sync.Map // upload ID -> *sync.Mutex held by the PATCH writing to it
+	evalPrompts    sync.Map // eval or shadow call key -> map[kind]prompts.Assignment
+	evalMu         sync.Mutex
+	shadowJobs     chan shadowJob
+	shadowPins     map[string]prompts.Assignment
 	client         *http.Client
-	botID          string
+	usage          *usage.Meter
 	shutdown       chan struct{}
+	cfgMu          sync.RWMutex // guards the cfg fields a reload can change; see liveConfig
 	cfg            config.Config
 	tz             *time.Location
 	ctx            context.Context
@@ -370,15 +440,75 @@ type server struct {
 	rollups        *rollups.Service
 	rollupMu       sync.Mutex
 	rollupEnqueued bool
+	openAI         *breaker.Breaker
+	prompts        *prompts.Registry
+	store          *transcriptionStore
+	openAIReach    reachability
+	mapboxReach    reachability
+	mapLimiter     *ipRateLimiter
+	mileMarkers    *milemarker.Index
+	stationIndex   *stations.Index
+	region         *geofence.Boundary
+	frequentLocs   frequentLocationCache
+	drafts         chan struct{}
+	previews       previewCache
+	speech         previewCache
+	queries        queryCache
+	reconcile      reconciler
+	watchdog       watchdog
+	callsMigration callsDirMigration
+	leading        atomic.Bool
+	shared         *sharedQueue
+	filenames      atomic.Pointer[formatting.FilenameParser]
+	slo            sloMonitor
+	integrity      sync.Mutex
 }
 
 // QueueDebugResponse represents the payload returned from /debug/queue.
 type QueueDebugResponse struct {
-	Length        int   `json:"length"`
-	Capacity      int   `json:"capacity"`
-	Workers       int   `json:"workers"`
-	ProcessedJobs int64 `json:"processed_jobs"`
-	FailedJobs    int64 `json:"failed_jobs"`
+	Length        int                  `json:"length"`
+	Capacity      int                  `json:"capacity"`
+	Workers       int                  `json:"workers"`
+	Active        int                  `json:"active"`
+	Paused        bool                 `json:"paused"`
+	PausedBy      []string             `json:"paused_by,omitempty"`
+	ProcessedJobs int64                `json:"processed_jobs"`
+	FailedJobs    int64                `json:"failed_jobs"`
+	WorkCleanup   WorkCleanupDebug     `json:"work_cleanup"`
+	Jobs          []QueueJobDebug      `json:"jobs"`
+	Drain         QueueDrainEstimation `json:"drain"`
+	OpenAI        *OpenAIBreakerDebug  `json:"openai_breaker,omitempty"`
+	Shared        *SharedQueueDebug    `json:"shared,omitempty"`
+	Watcher       *WatcherDebug        `json:"watcher,omitempty"`
+}
+
+// WorkCleanupDebug totals what the work-directory janitor has deleted since
+// startup.
+type WorkCleanupDebug struct {
+	FilesRemoved   int64 `json:"files_removed"`
+	BytesReclaimed int64 `json:"bytes_reclaimed"`
+}
+
+// QueueJobDebug describes a single queued or running job.
+type QueueJobDebug struct {
+	ID             string     `json:"id"`
+	Filename       string     `json:"filename,omitempty"`
+	Source         string     `json:"source,omitempty"`
+	Stage          string     `json:"stage"`
+	Attempt        int        `json:"attempt,omitempty"`
+	QueuedAt       *time.Time `json:"queued_at,omitempty"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	ElapsedSeconds float64    `json:"elapsed_seconds"`
+}
+
+// QueueDrainEstimation projects when the current backlog will clear.
+type QueueDrainEstimation struct {
+	Backlog            int        `json:"backlog"`
+	RecentCompleted    int        `json:"recent_completed"`
+	ThroughputPerMin   float64    `json:"throughput_per_min"`
+	AvgJobSeconds      float64    `json:"avg_job_seconds"`
+	EstimatedSeconds   *float64   `json:"estimated_seconds"`
+	EstimatedDrainedAt *time.Time `json:"estimated_drained_at,omitempty"`
 }
 
 func (s *server) defaultOptions() (TranscriptionOptions, error) {
@@ -460,6 +590,13 @@ func adminEnabled() bool {
 	return strings.EqualFold(strings.TrimSpace(os.Getenv("ENABLE_ADMIN_ACTIONS")), "true")
 }
 
+// isAdminRequest reports whether r carries a valid admin token, without
+// writing an error response.
+func isAdminRequest(r *http.Request) bool {
+	token := strings.TrimSpace(os.Getenv("ADMIN_TOKEN"))
+	return adminEnabled() && token != "" && r.Header.Get("X-Admin-Token") == token
+}
+
 func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
 	if !adminEnabled() {
 		http.NotFound(w, r)
@@ -483,6 +620,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(cfg.DBPath, cfg.WorkDir, os.Args[2:]))
+	}
 
 	mode := parseAlertMode(os.Getenv("ALERT_MODE"))
 	enableHTTP := mode == "all" || mode == "api"
@@ -531,17 +671,51 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	meter := usage.NewMeter(db, cfg.OpenAIMonthlyBudgetUSD, cfg.OpenAIPricing)
 	s := &server{
-		db:       db,
-		client:   &http.Client{Timeout: 180 * time.Second},
-		botID:    getBotID(cfg),
-		shutdown: make(chan struct{}),
-		cfg:      cfg,
-		metrics:  m,
-		tz:       tz,
-		ctx:      ctx,
+		db:         db,
+		client:     &http.Client{Timeout: 180 * time.Second, Transport: meter.Wrap(http.DefaultTransport, openAIHosts(cfg)...)},
+		usage:      meter,
+		shutdown:   make(chan struct{}),
+		cfg:        cfg,
+		metrics:    m,
+		tz:         tz,
+		ctx:        ctx,
+		prompts:    prompts.NewRegistry(db),
+		store:      newTranscriptionStore(db, db, time.Duration(cfg.DBQueryTimeoutSec)*time.Second),
+		mapLimiter: newIPRateLimiter(cfg.MapProxyRatePerMin),
+		drafts:     make(chan struct{}, draftConcurrency),
+	}
+	defer s.store.Close()
+	s.setFilenamePatterns(cfg.FilenamePatterns)
+
+	s.region = geofence.SussexCounty()
+	if cfg.RegionBoundaryPath != "" {
+		s.region, err = geofence.Load(cfg.RegionBoundaryPath)
+		if err != nil {
+			log.Fatalf("region boundary load failed (%s): %v", cfg.RegionBoundaryPath, err)
+		}
+		log.Printf("loaded region boundary %v from %s", s.region.Regions(), cfg.RegionBoundaryPath)
+	}
+
+	if cfg.StationsPath != "" {
+		s.stationIndex, err = stations.Load(cfg.StationsPath)
+		if err != nil {
+			log.Fatalf("stations load failed (%s): %v", cfg.StationsPath, err)
+		}
+		log.Printf("loaded %d stations from %s", s.stationIndex.Len(), cfg.StationsPath)
+	}
+
+	if cfg.MileMarkersPath != "" {
+		s.mileMarkers, err = milemarker.Load(cfg.MileMarkersPath)
+		if err != nil {
+			log.Fatalf("mile marker load failed (%s): %v", cfg.MileMarkersPath, err)
+		}
+		log.Printf("loaded %d mile markers from %s", s.mileMarkers.Len(), cfg.MileMarkersPath)
 	}
 
+	s.initCallsDirMigration()
+
 	var refiner *refine.Service
 	if enableWorker {
 		refiner, err = refine.NewService(s.client, cfg)
@@ -551,18 +725,67 @@ func main() {
 		s.refiner = refiner
 		defer refiner.Close()
 		s.rollups = rollups.NewService(db, s.client, cfg.Rollup)
+		s.rollups.SetExclude(func(callType, transcript string) bool {
+			return len(s.restrictedFlags(callType, transcript)) > 0
+		})
+	}
+
+	if cfg.QueueBackend == "redis" {
+		s.shared, err = newSharedQueue(cfg)
+		if err != nil {
+			log.Fatalf("shared queue init failed: %v", err)
+		}
+		if err := s.shared.client.Ping(ctx); err != nil {
+			log.Printf("redis unreachable at startup, will keep retrying: %v", err)
+		}
+		log.Printf("transcription jobs go through redis list %s", cfg.RedisQueueKey)
 	}
 
 	if enableWorker {
 		s.queue = queue.New(cfg.JobQueueSize, cfg.WorkerCount, time.Duration(cfg.JobTimeoutSec)*time.Second, m)
+		s.workFiles = newWorkJanitor(cfg.WorkDir, time.Duration(cfg.WorkFileGraceSec)*time.Second, m)
+		s.workFiles.reconcile()
+		s.workFiles.start(ctx)
+		s.initOpenAIBreaker()
+		s.startOpenAIProbe(ctx)
 		s.queue.Start(ctx)
 		qStats := s.queue.Stats()
 		m.UpdateQueue(qStats.Length, qStats.Capacity, qStats.WorkerCount)
-		go s.watch()
-		if s.rollups != nil {
-			s.startRollupScheduler(ctx)
-		}
+		s.startShadowWorker(ctx)
+		if s.shared != nil {
+			go s.pullSharedJobs(ctx)
+		}
+		// Every worker instance processes the jobs it is handed; the duties
+		// below would double-process files or double-send alerts if two
+		// instances ran them, so only the leader does.
+		s.runAsLeader(ctx, func(ctx context.Context) {
+			go s.watch(ctx)
+			s.startCallsDirMirror(ctx)
+			if s.rollups != nil {
+				s.startRollupScheduler(ctx)
+			}
+			s.resumeOpsJobs(ctx)
+			if s.cfg.LeaderElection {
+				s.startStaleCallReaper(ctx)
+			} else {
+				s.interruptEvalRuns()
+				s.recoverInterruptedCalls()
+			}
+			s.startArchiveScheduler(ctx)
+			s.startRemotePollers(ctx)
+			s.startEscalationScheduler(ctx)
+			s.startTalkgroupSilenceWatch(ctx)
+			s.startFrequentLocationRefresher(ctx)
+			s.startTrendAggregator(ctx)
+			s.startEmbeddingBackfill(ctx)
+			s.startSLOMonitor(ctx)
+			s.startIntegrityChecker(ctx)
+			s.startIMAPPoller(ctx)
+			s.startEmailDigestScheduler(ctx)
+		})
 	}
+	s.startDBMaintenance(ctx)
+	s.startReloadSignal(ctx)
 
 	var httpServer *http.Server
 	if enableHTTP {
@@ -570,22 +793,115 @@ func main() {
 		mux.HandleFunc("/api/transcriptions", s.handleTranscriptions)
 		mux.HandleFunc("/api/transcription/", s.handleTranscription)
 		mux.HandleFunc("/api/transcription", s.handleTranscriptionIndex)
+		mux.HandleFunc("/api/alerts", s.handleMetadataAlerts)
+		mux.HandleFunc("/api/searches", s.handleSavedSearches)
+		mux.HandleFunc("/api/searches/", s.handleSavedSearchDetail)
+		mux.HandleFunc("/api/incident/", s.handleIncident)
 		mux.HandleFunc("/api/settings", s.handleSettings)
+		mux.HandleFunc("/api/settings/history", s.handleSettingsHistory)
+		mux.HandleFunc("/api/settings/rollback/", s.handleSettingsRollback)
 		mux.HandleFunc("/api/stats/last6h", s.handleLastSixHoursStats)
+		mux.HandleFunc("/api/stats/trends", s.handleTrends)
+		mux.HandleFunc("/api/stats/forecast", s.handleForecast)
+		mux.HandleFunc("/api/stats/errors", s.handleErrorStats)
+		mux.HandleFunc("/api/stats/ingest", s.handleIngestStats)
+		mux.HandleFunc("/api/stats/models", s.handleModelStats)
+		mux.HandleFunc("/api/stats/talkgroups", s.handleTalkgroupStats)
+		mux.HandleFunc("/api/stats/frequent-locations", s.handleFrequentLocations)
+		mux.HandleFunc("/api/stats/live", s.handleDashboardLive)
+		mux.HandleFunc("/api/drafts/stream", s.handleDraftStream)
+		mux.HandleFunc("/api/reports/monthly/", s.handleMonthlyReport)
 		mux.HandleFunc("/api/hotspots", s.handleHotspots)
+		mux.HandleFunc("/api/entities/", s.handleEntityCalls)
+		mux.HandleFunc("/api/geocode", s.handleGeocode)
+		mux.HandleFunc("/tiles/", s.handleTiles)
 		mux.HandleFunc("/api/rollups", s.handleRollups)
 		mux.HandleFunc("/api/rollups/", s.handleRollupDetail)
 		mux.HandleFunc("/api/rollups/recompute", s.handleRollupRecompute)
+		mux.HandleFunc("/api/rollups/runs", s.handleRollupRuns)
+		mux.HandleFunc("/api/prompts", s.handlePrompts)
+		mux.HandleFunc("/api/prompts/", s.handlePromptDetail)
+		mux.HandleFunc("/api/admin/backup", s.handleAdminBackup)
+		mux.HandleFunc("/api/admin/archive", s.handleAdminArchive)
+		mux.HandleFunc("/api/admin/sources", s.handleAdminSources)
+		mux.HandleFunc("/api/admin/filename-parse", s.handleFilenameParse)
+		mux.HandleFunc("/api/admin/aliases", s.handleStreetAliases)
+		mux.HandleFunc("/api/admin/aliases/", s.handleStreetAliasDetail)
+		mux.HandleFunc("/api/admin/terms", s.handleTranscriptTerms)
+		mux.HandleFunc("/api/admin/terms/preview", s.handleTranscriptTermPreview)
+		mux.HandleFunc("/api/admin/terms/", s.handleTranscriptTermDetail)
+		mux.HandleFunc("/api/admin/geocode-corrections", s.handleGeocodeCorrections)
+		mux.HandleFunc("/api/admin/geocode-corrections/", s.handleGeocodeCorrections)
+		mux.HandleFunc("/api/admin/call-type-corrections", s.handleCallTypeCorrections)
+		mux.HandleFunc("/api/admin/embed-tokens", s.handleEmbedTokens)
+		mux.HandleFunc("/api/admin/shadow", s.handleShadowReport)
+		mux.HandleFunc("/api/admin/shadow/results", s.handleShadowResults)
+		mux.HandleFunc("/api/admin/reload", s.handleAdminReload)
+		mux.HandleFunc("/api/admin/replay", s.handleAdminReplay)
+		mux.HandleFunc("/api/admin/email/test", s.handleEmailTest)
+		mux.HandleFunc("/api/admin/twilio/deliveries", s.handleTwilioDeliveries)
+		mux.HandleFunc("/api/admin/rollup-exports", s.handleRollupExports)
+		mux.HandleFunc("/api/admin/routing/rules", s.handleRoutingRules)
+		mux.HandleFunc("/api/admin/routing/rules/", s.handleRoutingRuleDetail)
+		mux.HandleFunc("/api/admin/routing/evaluate", s.handleRoutingEvaluate)
+		mux.HandleFunc("/api/admin/audit", s.handleAuditLog)
+		mux.HandleFunc("/api/admin/reconcile", s.handleReconcile)
+		mux.HandleFunc("/api/admin/integrity", s.handleIntegrity)
+		mux.HandleFunc("/api/admin/subject/export", s.handleSubjectExport)
+		mux.HandleFunc("/api/admin/subject/redact", s.handleSubjectRedact)
+		mux.HandleFunc("/api/admin/migrations", s.handleMigrations)
+		mux.HandleFunc("/api/admin/migrations/", s.handleMigrations)
+		mux.HandleFunc("/api/twilio/status", s.handleTwilioStatus)
+		mux.HandleFunc("/api/uploads", s.handleUploads)
+		mux.HandleFunc("/api/uploads/", s.handleUploads)
+		mux.HandleFunc("/api/quarantine", s.handleQuarantine)
+		mux.HandleFunc("/api/quarantine/", s.handleQuarantine)
+		mux.HandleFunc("/api/review", s.handleReview)
+		mux.HandleFunc("/api/review/", s.handleReview)
+		mux.HandleFunc("/api/embed", s.handleEmbedJSON)
+		mux.HandleFunc("/embed/recent", s.handleEmbedRecent)
+		mux.HandleFunc("/cap/feed.xml", s.handleCAPFeed)
+		mux.HandleFunc("/cap/alerts/", s.handleCAPAlert)
+		mux.HandleFunc("/api/usage", s.handleUsage)
+		mux.HandleFunc("/api/eval/cases", s.handleEvalCases)
+		mux.HandleFunc("/api/eval/cases/", s.handleEvalCaseDetail)
+		mux.HandleFunc("/api/eval/runs", s.handleEvalRuns)
+		mux.HandleFunc("/api/eval/runs/", s.handleEvalRunDetail)
+		mux.HandleFunc("/archive/", s.handleArchive)
+		mux.HandleFunc("/api/ingest/cad", s.handleCADIngest)
+		mux.HandleFunc("/ops/reprocess", s.handleOpsReprocess)
+		mux.HandleFunc("/ops/reprocess/bulk", s.handleOpsBulkReprocess)
+		mux.HandleFunc("/ops/backfill", s.handleOpsBackfill)
+		mux.HandleFunc("/ops/import", s.handleOpsImport)
+		mux.HandleFunc("/ops/calls-dir", s.handleCallsDirStatus)
+		mux.HandleFunc("/ops/calls-dir/migrate", s.handleCallsDirMigrate)
+		mux.HandleFunc("/ops/calls-dir/retire", s.handleCallsDirRetire)
+		mux.HandleFunc("/ops/jobs/", s.handleOpsJob)
+		mux.HandleFunc("/ops/queue/", s.handleOpsQueue)
 		mux.HandleFunc("/api/version", s.handleVersion)
 		mux.HandleFunc("/preview/", s.handlePreview)
+		mux.HandleFunc("/call/", s.handleCallPage)
+		mux.HandleFunc("/oembed", s.handleOEmbed)
+		mux.HandleFunc("/shared/", s.handleSharedCall)
 		mux.HandleFunc("/healthz", s.handleHealth)
 		mux.HandleFunc("/readyz", s.handleReady)
+		mux.HandleFunc("/status", s.handleStatusPage)
+		mux.HandleFunc("/api/status", s.handleStatus)
 		mux.HandleFunc("/debug/queue", s.handleDebugQueue)
+		mux.HandleFunc("/api/debug/refine", s.handleDebugRefine)
 		mux.HandleFunc("/", s.handleRoot)
+		s.startUploadSweeper(ctx)
 
 		httpServer = &http.Server{
 			Addr:    cfg.HTTPPort,
-			Handler: mux,
+			Handler: s.withAudit(mux),
+		}
+	}
+
+	var grpcServer *grpc.Server
+	if enableHTTP && cfg.GRPCPort != "" {
+		if grpcServer, err = s.startGRPC(); err != nil {
+			log.Fatalf("grpc listen on %s failed: %v", cfg.GRPCPort, err)
 		}
 	}
 
@@ -600,6 +916,18 @@ func main() {
 		if httpServer != nil {
 			_ = httpServer.Shutdown(ctxTimeout)
 		}
+		if grpcServer != nil {
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctxTimeout.Done():
+				grpcServer.Stop()
+			}
+		}
 	}()
 
 	if enableHTTP {
@@ -704,6 +1032,18 @@ func openDB(path string) (*sql.DB, error) {
 	if err := ensureDBFile(path); err != nil {
 		return nil, err
 	}
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := initDB(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// openSQLite opens the database without migrating it.
+func openSQLite(path string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, err
@@ -720,10 +1060,6 @@ func openDB(path string) (*sql.DB, error) {
 			log.Printf("db pragma failed (%s): %v", strings.TrimSpace(pragma), err)
 		}
 	}
-
-	if err := initDB(db); err != nil {
-		return nil, err
-	}
 	return db, nil
 }
 
@@ -731,7 +1067,12 @@ func initDB(db *sql.DB) error {
 	if err := ensureSchemaVersionTable(db); err != nil {
 		return err
 	}
-	migrations := []migration{
+	return applyMigrations(db, schemaMigrations())
+}
+
+// schemaMigrations lists every schema step in order.
+func schemaMigrations() []migration {
+	return []migration{
 		{version: 1, name: "baseline schema", up: migrateBaseline},
 		{version: 2, name: "add ingest source", up: migrateAddIngestSource},
 		{version: 3, name: "add call metadata columns", up: migrateAddCallMetadata},
@@ -739,8 +1080,60 @@ func initDB(db *sql.DB) error {
 		{version: 5, name: "add processed audio path", up: migrateAddProcessedPath},
 		{version: 6, name: "normalize call timestamps to utc", up: migrateNormalizeCallTimestampUTC},
 		{version: 7, name: "add rollup tables", up: migrateAddRollups},
+		{version: 8, name: "add prompt registry", up: migrateAddPromptRegistry},
+		{version: 9, name: "add ops jobs", up: migrateAddOpsJobs},
+		{version: 10, name: "add rollup curation flag", up: migrateAddRollupCuration},
+		{version: 11, name: "add rollup lifecycle", up: migrateAddRollupLifecycle},
+		{version: 12, name: "add rollup notification watermarks", up: migrateAddRollupNotifyWatermarks},
+		{version: 13, name: "add cad incidents", up: migrateAddCADIncidents},
+		{version: 14, name: "add public transcript", up: migrateAddPublicTranscript},
+		{version: 15, name: "add detected language", up: migrateAddDetectedLanguage},
+		{version: 16, name: "add speaker roles", up: migrateAddSpeakerRoles},
+		{version: 17, name: "add audio fingerprint", up: migrateAddFingerprint},
+		{version: 18, name: "add remote source cursors", up: migrateAddRemoteSources},
+		{version: 19, name: "add openai usage", up: migrateAddOpenAIUsage},
+		{version: 20, name: "add evaluation sets", up: migrateAddEvaluation},
+		{version: 21, name: "add street aliases", up: migrateAddStreetAliases, down: dropTables("street_aliases")},
+		{version: 22, name: "add alert ledger", up: migrateAddAlertLedger, down: dropTables("alert_ledger")},
+		{version: 23, name: "add incident escalations", up: migrateAddIncidentEscalations, down: dropTables("incident_escalations", "incident_acks")},
+		{version: 24, name: "add incident notes", up: migrateAddIncidentNotes},
+		{version: 25, name: "add trend aggregates", up: migrateAddTrendAggregates, down: downTrendAggregates},
+		{version: 26, name: "add hourly stats", up: migrateAddHourlyStats, down: dropTables("stats_hourly")},
+		{version: 27, name: "add shadow results", up: migrateAddShadowResults, down: dropTables("shadow_results")},
+		{version: 28, name: "add call entities", up: migrateAddCallEntities, down: dropTables("call_entities")},
+		{version: 29, name: "add error codes", up: migrateAddErrorCodes},
+		{version: 30, name: "add webhook payloads", up: migrateAddWebhookPayloads, down: dropTables("webhook_payloads")},
+		{version: 31, name: "add monthly reports", up: migrateAddMonthlyReports, down: downMonthlyReports},
+		{version: 32, name: "add settings history", up: migrateAddSettingsHistory, down: dropTables("settings_history")},
+		{version: 33, name: "add quarantine", up: migrateAddQuarantine, down: dropTables("quarantine")},
+		{version: 34, name: "add email notifications", up: migrateAddEmailNotifications, down: downEmailNotifications},
+		{version: 35, name: "add twilio deliveries", up: migrateAddTwilioDeliveries, down: dropTables("twilio_deliveries")},
+		{version: 36, name: "add routing rules", up: migrateAddRoutingRules, down: dropTables("routing_rules")},
+		{version: 37, name: "add audit log", up: migrateAddAuditLog, down: dropTables("audit_log")},
+		{version: 38, name: "add uploads", up: migrateAddUploads, down: dropTables("uploads")},
+		{version: 39, name: "add call talkgroups", up: migrateAddTalkgroups, down: downTalkgroups},
+		{version: 40, name: "add review queue", up: migrateAddReviewQueue, down: dropTables("review_items")},
+		{version: 41, name: "add geocode corrections", up: migrateAddGeocodeCorrections, down: dropTables("geocode_corrections")},
+		{version: 42, name: "add call type corrections", up: migrateAddCallTypeCorrections, down: dropTables("call_type_corrections")},
+		{version: 43, name: "add frequent locations", up: migrateAddFrequentLocations, down: dropTables("frequent_locations")},
+		{version: 44, name: "add transcription engine", up: migrateAddTranscriptionEngine, down: downTranscriptionEngine},
+		{version: 45, name: "add draft transcripts", up: migrateAddDraftTranscripts, down: downDraftTranscripts},
+		{version: 46, name: "add saved searches", up: migrateAddSavedSearches, down: dropTables("saved_searches")},
+		{version: 47, name: "add station distances", up: migrateAddStationDistances, down: downStationDistances},
+		{version: 48, name: "add leader leases", up: migrateAddLeaderLeases, down: dropTables("leader_leases")},
+		{version: 49, name: "add soft delete", up: migrateAddSoftDelete, down: downSoftDelete},
+		{version: 50, name: "add transcription cost", up: migrateAddTranscriptionCost, down: downTranscriptionCost},
+		{version: 51, name: "add calls dir retirements", up: migrateAddCallsDirRetirements, down: dropTables("calls_dir_retirements")},
+		{version: 52, name: "add rollup run metrics", up: migrateAddRollupRunMetrics, down: downRollupRunMetrics},
+		{version: 53, name: "add approximate rollups", up: migrateAddApproximateRollups, down: downApproximateRollups},
+		{version: 54, name: "add rollup exports", up: migrateAddRollupExports, down: dropTables("rollup_exports")},
+		{version: 55, name: "add call aliases", up: migrateAddCallAliases, down: downCallAliases},
+		{version: 56, name: "add alert latency", up: migrateAddAlertLatency, down: downAlertLatency},
+		{version: 57, name: "add page links", up: migrateAddPageLinks, down: downPageLinks},
+		{version: 58, name: "add transcript terms", up: migrateAddTranscriptTerms, down: dropTables("transcript_terms")},
+		{version: 59, name: "add query indexes", up: migrateAddQueryIndexes, down: downQueryIndexes},
+		{version: 60, name: "add integrity runs", up: migrateAddIntegrityRuns, down: dropTables("integrity_runs")},
 	}
-	return applyMigrations(db, migrations)
 }
 
 func ensureSchemaVersionTable(db *sql.DB) error {
@@ -1029,6 +1422,385 @@ CREATE TABLE IF NOT EXISTS rollup_runs (
 	return err
 }
 
+func migrateAddPromptRegistry(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS prompt_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    version TEXT NOT NULL,
+    prompt TEXT NOT NULL,
+    weight INTEGER NOT NULL DEFAULT 0,
+    active INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(kind, version)
+);`
+	if _, err := execWithRetry(db, schema); err != nil {
+		return err
+	}
+	for _, col := range []string{"cleanup_prompt_version", "metadata_prompt_version"} {
+		if err := addColumnIfMissing(db, "transcriptions", col, "TEXT"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateAddRollupCuration(db *sql.DB) error {
+	return addColumnIfMissing(db, "rollups", "curated", "INTEGER DEFAULT 0")
+}
+
+func migrateAddApproximateRollups(db *sql.DB) error {
+	return addColumnIfMissing(db, "rollups", "approximate", "INTEGER DEFAULT 0")
+}
+
+func downApproximateRollups(db *sql.DB) error {
+	return dropColumnIfExists(db, "rollups", "approximate")
+}
+
+func migrateAddRollupLifecycle(db *sql.DB) error {
+	columns := []struct{ name, colType string }{
+		{"state", "TEXT DEFAULT 'active'"},
+		{"closed_at", "DATETIME NULL"},
+		{"closing_summary", "TEXT NULL"},
+	}
+	for _, col := range columns {
+		if err := addColumnIfMissing(db, "rollups", col.name, col.colType); err != nil {
+			return err
+		}
+	}
+	_, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_rollups_state ON rollups(state)`)
+	return err
+}
+
+func migrateAddRollupNotifyWatermarks(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "rollups", "notified_call_count", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(db, "rollups", "notified_priority", "TEXT NULL")
+}
+
+func migrateAddCADIncidents(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS cad_incidents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    external_id TEXT UNIQUE NOT NULL,
+    talkgroup TEXT NULL,
+    incident_at DATETIME NOT NULL,
+    address TEXT NULL,
+    town TEXT NULL,
+    call_type TEXT NULL,
+    units TEXT NULL,
+    latitude REAL NULL,
+    longitude REAL NULL,
+    raw_json TEXT NULL,
+    filename TEXT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_cad_incidents_incident_at ON cad_incidents(incident_at);`
+	if _, err := execWithRetry(db, schema); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "transcriptions", "cad_incident_id", "INTEGER NULL"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(db, "transcriptions", "units", "TEXT NULL")
+}
+
+func migrateAddPublicTranscript(db *sql.DB) error {
+	return addColumnIfMissing(db, "transcriptions", "public_transcript", "TEXT NULL")
+}
+
+func migrateAddDetectedLanguage(db *sql.DB) error {
+	return addColumnIfMissing(db, "transcriptions", "detected_language", "TEXT NULL")
+}
+
+func migrateAddSpeakerRoles(db *sql.DB) error {
+	return addColumnIfMissing(db, "transcriptions", "speaker_roles", "TEXT NULL")
+}
+
+func migrateAddFingerprint(db *sql.DB) error {
+	return addColumnIfMissing(db, "transcriptions", "fingerprint", "TEXT NULL")
+}
+
+func migrateAddRemoteSources(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS remote_sources (
+    name TEXT PRIMARY KEY,
+    cursor TEXT NOT NULL DEFAULT '',
+    last_polled_at DATETIME NULL,
+    last_error TEXT NULL
+);`)
+	return err
+}
+
+func migrateAddOpenAIUsage(db *sql.DB) error {
+	_, err := execWithRetry(db, usage.Schema)
+	return err
+}
+
+func migrateAddEvaluation(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS eval_cases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    call_filename TEXT NOT NULL,
+    expected_transcript TEXT NOT NULL,
+    expected_address TEXT NULL,
+    audio_path TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS eval_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    status TEXT NOT NULL,
+    model TEXT NOT NULL,
+    cleanup_prompt_version TEXT NOT NULL,
+    metadata_prompt_version TEXT NOT NULL,
+    cases INTEGER NOT NULL DEFAULT 0,
+    completed INTEGER NOT NULL DEFAULT 0,
+    mean_wer REAL NULL,
+    mean_raw_wer REAL NULL,
+    address_accuracy REAL NULL,
+    error TEXT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NULL
+);
+CREATE TABLE IF NOT EXISTS eval_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL,
+    case_id INTEGER NOT NULL,
+    transcript TEXT NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    wer REAL NOT NULL DEFAULT 0,
+    raw_wer REAL NOT NULL DEFAULT 0,
+    address_match INTEGER NULL,
+    error TEXT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_eval_results_run ON eval_results(run_id);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+func migrateAddStreetAliases(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS street_aliases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    alias TEXT NOT NULL,
+    alias_key TEXT NOT NULL,
+    town TEXT NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    latitude REAL NULL,
+    longitude REAL NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    last_matched_at DATETIME NULL,
+    created_at DATETIME NOT NULL,
+    UNIQUE(alias_key, town)
+);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+func migrateAddAlertLedger(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS alert_ledger (
+    incident_key TEXT PRIMARY KEY,
+    filename TEXT NOT NULL,
+    body_hash TEXT NOT NULL,
+    first_sent_at DATETIME NOT NULL,
+    last_sent_at DATETIME NOT NULL,
+    sends INTEGER NOT NULL DEFAULT 0,
+    suppressed INTEGER NOT NULL DEFAULT 0
+);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+func migrateAddIncidentEscalations(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS incident_escalations (
+    filename TEXT PRIMARY KEY,
+    call_category TEXT NOT NULL DEFAULT '',
+    call_type TEXT NOT NULL DEFAULT '',
+    alert_text TEXT NOT NULL,
+    alerted_at DATETIME NOT NULL,
+    due_at DATETIME NOT NULL,
+    escalated_at DATETIME NULL,
+    escalation_error TEXT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_incident_escalations_due ON incident_escalations(due_at);
+CREATE TABLE IF NOT EXISTS incident_acks (
+    filename TEXT PRIMARY KEY,
+    acked_at DATETIME NOT NULL,
+    acked_by TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT ''
+);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+// migrateAddIncidentNotes generalises incident_acks to calls and rollups and
+// adds the comment thread both share.
+func migrateAddIncidentNotes(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS acknowledgements (
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    acked_at DATETIME NOT NULL,
+    acked_by TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (target_type, target_id)
+);
+INSERT OR IGNORE INTO acknowledgements (target_type, target_id, acked_at, acked_by, note)
+    SELECT 'call', filename, acked_at, acked_by, note FROM incident_acks;
+DROP TABLE IF EXISTS incident_acks;
+CREATE TABLE IF NOT EXISTS incident_comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    author TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_incident_comments_target ON incident_comments(target_type, target_id, created_at);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+func migrateAddTrendAggregates(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS stats_daily (
+    day TEXT NOT NULL,
+    town TEXT NOT NULL DEFAULT '',
+    call_type TEXT NOT NULL DEFAULT '',
+    calls INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, town, call_type)
+);
+CREATE TABLE IF NOT EXISTS stats_monthly (
+    month TEXT NOT NULL,
+    town TEXT NOT NULL DEFAULT '',
+    call_type TEXT NOT NULL DEFAULT '',
+    calls INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (month, town, call_type)
+);
+CREATE TABLE IF NOT EXISTS stats_aggregation_state (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    updated_through DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_transcriptions_updated_at ON transcriptions(updated_at);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+// migrateAddHourlyStats adds the hour-of-day aggregate behind the forecast and
+// clears the aggregation watermark so the next pass backfills it.
+func migrateAddHourlyStats(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS stats_hourly (
+    day TEXT NOT NULL,
+    hour INTEGER NOT NULL,
+    town TEXT NOT NULL DEFAULT '',
+    calls INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, hour, town)
+);
+DELETE FROM stats_aggregation_state;`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+func migrateAddShadowResults(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS shadow_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT NOT NULL,
+    candidate_model TEXT NOT NULL DEFAULT '',
+    cleanup_prompt_version TEXT NOT NULL DEFAULT '',
+    metadata_prompt_version TEXT NOT NULL DEFAULT '',
+    production_model TEXT NOT NULL DEFAULT '',
+    production_cleanup_version TEXT NOT NULL DEFAULT '',
+    production_metadata_version TEXT NOT NULL DEFAULT '',
+    production_transcript TEXT NOT NULL DEFAULT '',
+    shadow_transcript TEXT NOT NULL DEFAULT '',
+    production_address TEXT NOT NULL DEFAULT '',
+    shadow_address TEXT NOT NULL DEFAULT '',
+    production_call_type TEXT NOT NULL DEFAULT '',
+    shadow_call_type TEXT NOT NULL DEFAULT '',
+    transcript_divergence REAL NULL,
+    address_match INTEGER NULL,
+    call_type_match INTEGER NULL,
+    production_ms INTEGER NOT NULL DEFAULT 0,
+    shadow_ms INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_shadow_results_filename ON shadow_results(filename);
+CREATE INDEX IF NOT EXISTS idx_shadow_results_created ON shadow_results(created_at);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+// migrateAddCallEntities adds the cross-call entity index and fills it from
+// the calls already transcribed.
+func migrateAddCallEntities(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS call_entities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    entity_key TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    UNIQUE(filename, kind, entity_key)
+);
+CREATE INDEX IF NOT EXISTS idx_call_entities_key ON call_entities(entity_key);`
+	if _, err := execWithRetry(db, schema); err != nil {
+		return err
+	}
+	return backfillCallEntities(db)
+}
+
+// migrateAddErrorCodes adds error_code next to last_error, the call_errors
+// log behind /api/stats/errors, and codes for failures already recorded
+// where the message makes the cause clear.
+func migrateAddErrorCodes(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "transcriptions", "error_code", "TEXT"); err != nil {
+		return err
+	}
+	schema := `CREATE TABLE IF NOT EXISTS call_errors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT NOT NULL,
+    code TEXT NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_call_errors_created ON call_errors(created_at);
+UPDATE transcriptions SET error_code = CASE
+    WHEN last_error LIKE '%status 429%' THEN 'openai_rate_limit'
+    WHEN last_error LIKE 'openai status%' THEN 'openai_error'
+    WHEN last_error LIKE '%exceeds 25MB%' THEN 'file_too_large'
+    WHEN last_error = 'interrupted by restart' THEN 'interrupted'
+    ELSE 'unknown' END
+WHERE status = 'error' AND error_code IS NULL;`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+func migrateAddWebhookPayloads(db *sql.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS webhook_payloads (
+    filename TEXT PRIMARY KEY,
+    payload_json TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
+// migrateAddMonthlyReports adds the pipeline run time behind the report's
+// latency figures and the table generated reports are kept in.
+func migrateAddMonthlyReports(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "transcriptions", "processing_seconds", "REAL"); err != nil {
+		return err
+	}
+	schema := `CREATE TABLE IF NOT EXISTS monthly_reports (
+    month TEXT PRIMARY KEY,
+    job_id INTEGER NOT NULL,
+    stats_json TEXT NOT NULL,
+    html TEXT NOT NULL,
+    pdf BLOB NOT NULL,
+    generated_at DATETIME NOT NULL
+);`
+	_, err := execWithRetry(db, schema)
+	return err
+}
+
 func parseTimestampFlexible(raw string, tz *time.Location) (time.Time, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
@@ -1090,35 +1862,6 @@ func addColumnIfMissing(db *sql.DB, table, column, colType string) error {
 	return nil
 }
 
-func (s *server) watch() {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatalf("watcher: %v", err)
-	}
-	defer watcher.Close()
-
-	if err := watcher.Add(s.cfg.CallsDir); err != nil {
-		log.Fatalf("watch add: %v", err)
-	}
-
-	log.Printf("watching %s for new files", s.cfg.CallsDir)
-	for {
-		select {
-		case evt, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
-			if evt.Op&(fsnotify.Create|fsnotify.Rename) != 0 {
-				s.handleNewFile(evt.Name)
-			}
-		case err := <-watcher.Errors:
-			log.Printf("watch error: %v", err)
-		case <-s.shutdown:
-			return
-		}
-	}
-}
-
 func (s *server) handleNewFile(path string) {
 	path = strings.TrimSpace(path)
 	if path == "" {
@@ -1132,10 +1875,10 @@ func (s *server) handleNewFile(path string) {
 	if strings.HasPrefix(filename, ".") || strings.Contains(filename, ".writetest-") {
 		return
 	}
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".mp3", ".wav", ".m4a", ".aac", ".flac", ".ogg":
-	default:
+	if !isAudioFilename(filename) {
+		return
+	}
+	if _, remote := s.remoteFiles.Load(filename); remote {
 		return
 	}
 	info, err := os.Stat(path)
@@ -1147,8 +1890,16 @@ func (s *server) handleNewFile(path string) {
 	s.queueJob("watcher", filename, true, false, opts)
 }
 
+func isAudioFilename(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mp3", ".wav", ".m4a", ".aac", ".flac", ".ogg":
+		return true
+	}
+	return false
+}
+
 func (s *server) queueJob(source, filename string, sendGroupMe bool, force bool, opts TranscriptionOptions) bool {
-	if s.queue == nil {
+	if !s.canEnqueue() {
 		log.Printf("queue disabled; skipping enqueue for %s", filename)
 		return false
 	}
@@ -1171,6 +1922,9 @@ func (s *server) shouldSkipEnqueue(filename string, force bool) (bool, string) {
 		return false, ""
 	}
 	if existing == nil {
+		if target, ok := s.aliasOf(filename); ok {
+			return true, "alias of " + target
+		}
 		return false, ""
 	}
 
@@ -1195,24 +1949,69 @@ func (s *server) enqueueWithBackoff(ctx context.Context, source, filename string
 		}
 		return false, false
 	}
+	if !force {
+		if dup := s.aliasOnIngest(filename); dup != "" {
+			log.Printf("%s has the same audio as %s; recorded as an alias", filename, dup)
+			return false, false
+		}
+	}
 	if _, exists := s.running.LoadOrStore(filename, struct{}{}); exists && !force {
 		return false, false
 	}
-	meta, pretty, publicURL, baseURL := s.buildJobContext(filename)
+	meta, pretty, publicURL, baseURL := s.buildJobContext(filename, source)
 	sourcePath := filepath.Join(s.cfg.CallsDir, filename)
 	if err := s.markQueued(filename, sourcePath, source, 0, opts, meta.DateTime); err != nil {
 		log.Printf("mark queued failed for %s: %v", filename, err)
 	}
-	jobPayload := processJob{filename: filename, source: source, sendGroupMe: sendGroupMe, force: force, options: opts, meta: meta, prettyTitle: pretty, publicURL: publicURL, baseURL: baseURL}
+	s.startDraft(filename, sourcePath, opts)
+	jobPayload := processJob{filename: filename, source: source, sendGroupMe: sendGroupMe, force: force, options: opts, meta: meta, prettyTitle: pretty, publicURL: publicURL, baseURL: baseURL, enqueuedAt: time.Now().UTC()}
+	s.recordIngest(jobPayload, sourcePath)
+	s.recordArrival(jobPayload, sourcePath)
+	if sendGroupMe && !s.openAIAvailable() {
+		s.notifyTranscriptPending(jobPayload)
+		jobPayload.pendingAlertSent = true
+	}
+	return s.enqueuePayload(ctx, jobPayload)
+}
+
+// enqueuePayload submits an already-prepared job to the worker queue, or to
+// the shared queue when instances share one.
+func (s *server) enqueuePayload(ctx context.Context, jobPayload processJob) (bool, bool) {
+	if s.shared != nil {
+		// The worker that pulls the job tracks it; the database status keeps
+		// it from being queued twice meanwhile.
+		s.running.Delete(jobPayload.filename)
+		return s.pushShared(ctx, jobPayload), false
+	}
+	return s.enqueueLocal(ctx, jobPayload, nil)
+}
+
+// enqueueLocal submits a job to this instance's workers. onFinish, if set,
+// runs when the job ends, before a deferred job is requeued.
+func (s *server) enqueueLocal(ctx context.Context, jobPayload processJob, onFinish func(error)) (bool, bool) {
+	filename := jobPayload.filename
+	s.running.Store(filename, struct{}{})
 	job := queue.Job{
 		ID:       filename,
 		FileName: filename,
-		Source:   source,
+		Source:   jobPayload.source,
 		Work: func(ctx context.Context) error {
 			return s.processWithRetry(ctx, jobPayload, 2)
 		},
+		Timeout: func() time.Duration {
+			return s.jobTimeout(filepath.Join(s.cfg.CallsDir, filename))
+		},
 		OnFinish: func(err error) {
 			s.running.Delete(filename)
+			if err == nil && s.metrics != nil {
+				s.metrics.RecordDone(jobPayload.source, jobPayload.enqueuedAt, time.Now().UTC())
+			}
+			if onFinish != nil {
+				onFinish(err)
+			}
+			if errors.Is(err, errTranscriptionDeferred) {
+				s.requeueDeferred(jobPayload)
+			}
 		},
 	}
 	const backoffWindow = 5 * time.Second
@@ -1224,8 +2023,8 @@ func (s *server) enqueueWithBackoff(ctx context.Context, source, filename string
 	return enqueued, dropped
 }
 
-func (s *server) buildJobContext(filename string) (formatting.CallMetadata, string, string, string) {
-	meta, err := formatting.ParseCallMetadataFromFilename(filename, s.tz)
+func (s *server) buildJobContext(filename, source string) (formatting.CallMetadata, string, string, string) {
+	meta, err := s.parseCallMetadata(filename, source)
 	if err != nil {
 		log.Printf("metadata parse failed for %s: %v", filename, err)
 		meta = formatting.CallMetadata{RawFileName: filename, DateTime: time.Now().In(s.tz)}
@@ -1309,7 +2108,7 @@ func ProcessAudioWithFFmpeg(ctx context.Context, rawPath string) (string, error)
 	start := time.Now()
 	if err := cmd.Run(); err != nil {
 		log.Printf("ffmpeg processing failed for %s: %v (stderr: %s)", rawPath, err, strings.TrimSpace(stderr.String()))
-		return rawPath, err
+		return rawPath, errcode.Wrap(errcode.FFmpegFailed, err)
 	}
 
 	log.Printf("ffmpeg processed audio input=%s output=%s duration_ms=%d", rawPath, processedPath, time.Since(start).Milliseconds())
@@ -1322,6 +2121,7 @@ func (s *server) processWithRetry(ctx context.Context, job processJob, attempts
 	}
 	var lastErr error
 	for attempt := 0; attempt < attempts; attempt++ {
+		queue.SetAttempt(ctx, attempt+1)
 		if attempt > 0 {
 			delay := time.Duration(attempt) * time.Second
 			select {
@@ -1332,7 +2132,13 @@ func (s *server) processWithRetry(ctx context.Context, job processJob, attempts
 			log.Printf("retrying transcription for %s (attempt %d/%d)", job.filename, attempt+1, attempts)
 		}
 		if err := s.processFile(ctx, job); err != nil {
+			if errors.Is(err, errTranscriptionDeferred) {
+				return err
+			}
 			lastErr = err
+			if errcode.Of(err) == errcode.InvalidAudio {
+				break
+			}
 			continue
 		}
 		return nil
@@ -1344,6 +2150,9 @@ func (s *server) processWithRetry(ctx context.Context, job processJob, attempts
 }
 
 func (s *server) notifyTranscriptionFailure(job processJob, cause error) {
+	if len(s.restrictedFlags(job.meta.CallType, "")) > 0 {
+		return
+	}
 	message := "transcription failed"
 	if cause != nil {
 		message = strings.TrimSpace(cause.Error())
@@ -1396,6 +2205,7 @@ func (s *server) processFile(ctx context.Context, j processJob) error {
 		status = err.Error()
 		return err
 	}
+	queue.SetStage(ctx, "waiting_for_file")
 	if err := waitForStableSize(ctx, sourcePath, info.Size(), 2*time.Second, 2); err != nil {
 		s.markError(filename, err)
 		status = err.Error()
@@ -1403,9 +2213,22 @@ func (s *server) processFile(ctx context.Context, j processJob) error {
 		return err
 	}
 
+	queue.SetStage(ctx, "validate")
+	if err := s.validateAudio(ctx, sourcePath, info.Size()); err != nil {
+		if errcode.Of(err) == errcode.InvalidAudio {
+			s.quarantineFile(filename, sourcePath, info.Size(), err)
+			s.markError(filename, err)
+		}
+		status = err.Error()
+		decodeDur = time.Since(decodeStart)
+		return err
+	}
+
+	queue.SetStage(ctx, "preprocess")
 	processedPath, procErr := ProcessAudioWithFFmpeg(ctx, sourcePath)
 	if procErr != nil {
 		log.Printf("audio preprocessing skipped for %s: %v", filename, procErr)
+		s.recordCallError(filename, procErr)
 		processedPath = sourcePath
 	}
 	if err := s.updateProcessedPath(filename, processedPath); err != nil {
@@ -1426,19 +2249,43 @@ func (s *server) processFile(ctx context.Context, j processJob) error {
 	}
 
 	if dup := s.findDuplicate(hashValue, filename); dup != "" {
-		if err := s.copyFromDuplicate(filename, dup); err != nil {
-			log.Printf("failed to mirror duplicate data: %v", err)
-		}
-		note := fmt.Sprintf("duplicate of %s", dup)
-		s.markDoneWithDetails(filename, note, nil, nil, nil, &dup, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, false)
-		if j.sendGroupMe {
-			followup := fmt.Sprintf("%s transcript is duplicate of %s", filename, dup)
-			_ = s.sendGroupMe(followup)
+		// A file this ingest created is folded into the earlier call as an
+		// alias; a forced run keeps its row, as reprocessed and uploaded
+		// calls may already be linked elsewhere.
+		if !j.force && (existingEntry == nil || pickTranscript(existingEntry) == nil) {
+			err := s.replaceWithAlias(filename, dup, hashValue)
+			if err == nil {
+				log.Printf("%s has the same audio as %s; recorded as an alias", filename, dup)
+				status = "alias"
+				return nil
+			}
+			log.Printf("alias %s -> %s failed: %v", filename, dup, err)
 		}
+		s.linkDuplicate(j, dup, fmt.Sprintf("duplicate of %s", dup))
 		return nil
 	}
 
+	if s.cfg.FingerprintThreshold > 0 {
+		queue.SetStage(ctx, "fingerprint")
+		fp, err := audioFingerprint(ctx, sourcePath)
+		if err != nil {
+			log.Printf("fingerprint skipped for %s: %v", filename, err)
+		} else if len(fp) > 0 {
+			s.storeFingerprint(filename, fp)
+			callTime := j.meta.DateTime
+			if callTime.IsZero() {
+				callTime = time.Now()
+			}
+			if dup, score := s.findNearDuplicate(filename, fp, callTime.UTC()); dup != "" {
+				s.linkDuplicate(j, dup, fmt.Sprintf("near duplicate of %s (similarity %.2f)", dup, score))
+				return nil
+			}
+		}
+	}
+
 	stagedPath := filepath.Join(s.cfg.WorkDir, filepath.Base(processedPath))
+	s.workFiles.acquire(stagedPath)
+	defer s.workFiles.release(stagedPath)
 	if err := copyFile(processedPath, stagedPath); err != nil {
 		s.markError(filename, err)
 		status = err.Error()
@@ -1448,7 +2295,17 @@ func (s *server) processFile(ctx context.Context, j processJob) error {
 	decodeDur = time.Since(decodeStart)
 
 	transcribeStart := time.Now()
-	artifacts, err := s.multiPassTranscription(stagedPath, j.options, j.meta)
+	queue.SetStage(ctx, "transcribe")
+	artifacts, err := s.multiPassTranscription(filename, stagedPath, j.options, j.meta)
+	if errors.Is(err, errTranscriptionDeferred) {
+		s.markDeferred(filename, err)
+		status = "deferred"
+		transcribeDur = time.Since(transcribeStart)
+		if j.sendGroupMe && !j.pendingAlertSent {
+			s.notifyTranscriptPending(j)
+		}
+		return err
+	}
 	if err != nil {
 		s.markError(filename, err)
 		status = err.Error()
@@ -1474,7 +2331,7 @@ func (s *server) processFile(ctx context.Context, j processJob) error {
 	}
 
 	if normalized == nil || strings.TrimSpace(*normalized) == "" {
-		fallback := formatting.NormalizeTranscript(cleanedTranscript)
+		fallback := s.normalizeTranscript(cleanedTranscript, j.meta)
 		normalized = &fallback
 	}
 
@@ -1486,10 +2343,7 @@ func (s *server) processFile(ctx context.Context, j processJob) error {
 		tagsJSON = &str
 	}
 
-	var latPtr, lonPtr *float64
-	var locationLabel *string
-	var locationSource *string
-	var resolvedLocation *locationGuess
+	queue.SetStage(ctx, "geocode")
 	candidateRecord := transcription{
 		Filename:             filename,
 		NormalizedTranscript: normalized,
@@ -1499,79 +2353,65 @@ func (s *server) processFile(ctx context.Context, j processJob) error {
 		CallType:             callType,
 		TagsJSON:             tagsJSON,
 	}
-	applyLocationGuess := func(guess *locationGuess) {
-		if guess == nil {
-			return
-		}
-		resolvedLocation = guess
-		if guess.Label != "" {
-			label := guess.Label
-			locationLabel = &label
-		}
-		if guess.Source != "" {
-			source := guess.Source
-			locationSource = &source
-		}
-		if guess.Latitude != 0 || guess.Longitude != 0 {
-			lat := guess.Latitude
-			lon := guess.Longitude
-			latPtr = &lat
-			lonPtr = &lon
-		}
-		s.locationCache.Store(filename, guess)
-	}
-	if normalized != nil {
-		locCtx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
-		resolved := s.parseAndGeocodeLocation(locCtx, *normalized, j.meta)
-		cancel()
-		applyLocationGuess(resolved)
-	}
-	if resolvedLocation == nil {
-		applyLocationGuess(s.deriveLocation(candidateRecord, j.meta))
-	}
-	if resolvedLocation == nil && normalized != nil {
-		metaCtx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
-		inference, err := s.inferMetadataAddress(metaCtx, *normalized, j.meta, recognized)
-		cancel()
-		if err != nil && !errors.Is(err, errMetadataInferenceDisabled) {
-			log.Printf("metadata inference failed for %s: %v", filename, err)
-		}
-		if err == nil && inference != nil {
-			geoCtx, geoCancel := context.WithTimeout(context.Background(), 4*time.Second)
-			applyLocationGuess(s.metadataLocationGuess(geoCtx, inference, j.meta))
-			geoCancel()
-		}
-	}
-	if resolvedLocation == nil {
-		applyLocationGuess(s.historicalHotspot(j.meta, recognized))
-	}
-
-	if err := s.markDoneWithDetails(filename, "", &rawTranscript, &cleanedTranscript, translation, nil, diarized, towns, normalized, actualModel, callType, tagsJSON, latPtr, lonPtr, locationLabel, locationSource, artifacts.MetadataJSON, artifacts.AddressJSON, artifacts.NeedsManualReview); err != nil {
+	resolvedLocation, metadataPrompt := s.resolveCallLocation(ctx, candidateRecord, j.meta, recognized)
+	latPtr, lonPtr, locationLabel, locationSource := locationFields(resolvedLocation)
+
+	if err := s.completeCall(filename, callCompletion{
+		raw:               &rawTranscript,
+		clean:             &cleanedTranscript,
+		translation:       translation,
+		diarized:          diarized,
+		towns:             towns,
+		normalized:        normalized,
+		actualModel:       actualModel,
+		callType:          callType,
+		tags:              tagsJSON,
+		lat:               latPtr,
+		lon:               lonPtr,
+		label:             locationLabel,
+		source:            locationSource,
+		metadataJSON:      artifacts.MetadataJSON,
+		addressJSON:       artifacts.AddressJSON,
+		manualReview:      artifacts.NeedsManualReview,
+		publicTranscript:  artifacts.PublicTranscript,
+		detectedLanguage:  artifacts.DetectedLanguage,
+		speakerRoles:      artifacts.SpeakerRoles,
+		embedding:         embedding,
+		engine:            artifacts.Engine,
+		processingSeconds: time.Since(start).Seconds(),
+		transcribeSeconds: transcribeDur.Seconds(),
+		transcribeCost:    s.transcriptionCost(artifacts.Engine, derefString(actualModel, j.options.Model), duration),
+	}); err != nil {
+		s.markError(filename, errcode.Wrap(errcode.StoreFailed, err))
 		status = err.Error()
 		return err
 	}
+	s.recordPromptAssignments(filename, artifacts.CleanupPrompt, metadataPrompt)
+	s.correlateCAD(filename, j.meta)
+	s.correlatePages(filename, j.meta, resolvedLocation)
+	s.recordStationDistances(ctx, filename, resolvedLocation)
 	notifyStart := time.Now()
-	if len(embedding) > 0 {
-		if err := s.storeEmbedding(filename, embedding); err != nil {
-			log.Printf("store embedding: %v", err)
-		}
-	}
+	queue.SetStage(ctx, "notify")
 	if j.sendGroupMe {
-		if err := s.fireWebhooks(j); err != nil {
-			log.Printf("webhook error: %v", err)
-		}
 		audioName := s.audioFilename(transcription{ProcessedPath: processedPath, SourcePath: sourcePath, Filename: filename})
-		callTime := j.meta.DateTime
-		if callTime.IsZero() {
-			callTime = time.Now().In(s.tz)
-		}
-		incident := s.buildIncidentDetails(j.meta, callType, tagsList, resolvedLocation, recognized, callTime, audioName, formatting.BuildListenURL(audioName), cleanedTranscript)
-		alertBody := formatting.BuildIncidentAlert(incident)
-		if err := s.sendGroupMe(alertBody); err != nil {
-			log.Printf("groupme follow-up failed: %v", err)
-		}
+		s.sendCallAlert(j, audioName, callType, tagsList, resolvedLocation, recognized, artifacts.PublicTranscript)
 	}
 	notifyDur = time.Since(notifyStart)
+	s.maybeShadow(shadowJob{
+		filename:  filename,
+		audioPath: processedPath,
+		meta:      j.meta,
+		options:   j.options,
+		production: shadowOutcome{
+			Model:           derefString(actualModel, j.options.Model),
+			CleanupVersion:  promptVersion(artifacts.CleanupPrompt),
+			MetadataVersion: promptVersion(metadataPrompt),
+			Transcript:      cleanedTranscript,
+			Address:         derefString(locationLabel, ""),
+			CallType:        derefString(callType, ""),
+			DurationMS:      transcribeDur.Milliseconds(),
+		},
+	})
 	return nil
 }
 
@@ -1631,22 +2471,163 @@ type transcriptionArtifacts struct {
 	RecognizedTowns   *string
 	NormalizedText    *string
 	ActualModel       *string
+	Engine            string
 	CallType          *string
 	MetadataJSON      *string
 	AddressJSON       *string
 	NeedsManualReview bool
+	CleanupPrompt     *prompts.Assignment
+	PublicTranscript  string
+	DetectedLanguage  string
+	SpeakerRoles      *string
+}
+
+// resolveCallLocation runs the location fallback chain (mile marker or
+// transcript parse, stored record, metadata prompt, historical hotspot) and
+// caches the winner.
+func (s *server) resolveCallLocation(ctx context.Context, candidate transcription, meta formatting.CallMetadata, recognized []string) (*locationGuess, *prompts.Assignment) {
+	filename := candidate.Filename
+	normalized := candidate.NormalizedTranscript
+	var resolvedLocation *locationGuess
+	applyLocationGuess := func(guess *locationGuess) {
+		if guess == nil {
+			return
+		}
+		resolvedLocation = guess
+		s.locationCache.Store(filename, guess)
+	}
+	if normalized != nil {
+		locCtx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
+		resolved, err := s.parseAndGeocodeLocation(locCtx, *normalized, meta)
+		cancel()
+		if err != nil {
+			s.recordCallError(filename, errcode.Wrap(errcode.GeocodeFailed, err))
+		}
+		applyLocationGuess(resolved)
+	}
+	if resolvedLocation == nil {
+		applyLocationGuess(s.deriveLocation(candidate, meta))
+	}
+	var metadataPrompt *prompts.Assignment
+	if resolvedLocation == nil && normalized != nil {
+		assignment := s.assignPrompt(ctx, prompts.KindMetadata, filename)
+		metadataPrompt = &assignment
+		metaCtx, cancel := context.WithTimeout(context.Background(), 8*time.Second)
+		inference, err := s.inferMetadataAddress(metaCtx, *normalized, assignment.Prompt, meta, recognized)
+		cancel()
+		if err != nil && !errors.Is(err, errMetadataInferenceDisabled) {
+			log.Printf("metadata inference failed for %s: %v", filename, err)
+			s.recordCallError(filename, refineError(err))
+		}
+		if err == nil && inference != nil {
+			geoCtx, geoCancel := context.WithTimeout(context.Background(), 4*time.Second)
+			applyLocationGuess(s.metadataLocationGuess(geoCtx, inference, meta))
+			geoCancel()
+		}
+	}
+	if resolvedLocation == nil {
+		applyLocationGuess(s.historicalHotspot(meta, recognized))
+	}
+	return resolvedLocation, metadataPrompt
+}
+
+// locationFields splits a location guess into the nullable columns stored on a transcription.
+func locationFields(guess *locationGuess) (lat, lon *float64, label, source *string) {
+	if guess == nil {
+		return nil, nil, nil, nil
+	}
+	if guess.Label != "" {
+		l := guess.Label
+		label = &l
+	}
+	if guess.Source != "" {
+		src := guess.Source
+		source = &src
+	}
+	if guess.Latitude != 0 || guess.Longitude != 0 {
+		la, lo := guess.Latitude, guess.Longitude
+		lat, lon = &la, &lo
+	}
+	return lat, lon, label, source
 }
 
-func (s *server) multiPassTranscription(path string, opts TranscriptionOptions, meta formatting.CallMetadata) (transcriptionArtifacts, error) {
+// sendCallAlert fires webhooks and posts the full incident alert for a completed call.
+// Restricted calls (see policy.go) and calls a suppress routing rule matches
+// (see routing_rules.go) are never announced; other matching rules add
+// deliveries on top of the configured channels.
+func (s *server) sendCallAlert(j processJob, audioName string, callType *string, tags []string, location *locationGuess, recognized []string, transcript string) {
+	record, recordErr := s.getTranscription(j.filename)
+	if recordErr == nil && s.isRestricted(*record) {
+		log.Printf("alert suppressed for restricted call %s", j.filename)
+		s.excludeFromSLO(j.filename)
+		return
+	}
+	callTime := j.meta.DateTime
+	if callTime.IsZero() {
+		callTime = time.Now().In(s.tz)
+	}
+	incident := s.buildIncidentDetails(j.meta, callType, tags, location, recognized, callTime, audioName, listenURLFor(audioName), transcript)
+	incident.RepeatNote = s.repeatLocationNote(location)
+	if recordErr == nil {
+		incident.Flags = s.stationFlags(incident.CallCategory, s.nearestStationsFor(*record))
+	}
+	routes := s.evaluateRoutes(incident, derefString(callType, j.meta.CallType), recognized, transcript, callTime)
+	if routes.Suppressed {
+		log.Printf("alert for %s suppressed by routing rule %q", j.filename, routes.SuppressedBy)
+		s.excludeFromSLO(j.filename)
+		return
+	}
+	if err := s.fireWebhooks(j); err != nil {
+		log.Printf("webhook error: %v", err)
+	}
+	alertBody := formatting.BuildIncidentAlert(incident)
+	decision := s.decideAlert(incident.ID, alertBody)
+	switch decision {
+	case alertSuppress:
+		log.Printf("duplicate alert suppressed for %s", j.filename)
+		s.recordAlert(incident.ID, j.filename, alertBody, decision)
+		s.excludeFromSLO(j.filename)
+		return
+	case alertSendUpdated:
+		incident.Updated = true
+	case alertSend:
+		s.armEscalation(j.filename, incident.CallCategory, derefString(callType, j.meta.CallType), alertBody)
+		go s.sendTwilioAlerts(j.filename, incident, derefString(callType, j.meta.CallType), transcript)
+	}
+	if actions := routes.Actions(); len(actions) > 0 {
+		go s.dispatchRoutes(j, incident, formatting.BuildIncidentAlert(incident), actions)
+	}
+	go s.sendEmailAlerts(j, incident, alertBody, transcript)
+	if err := s.sendGroupMe(formatting.BuildIncidentAlert(incident)); err != nil {
+		log.Printf("groupme follow-up failed: %v", err)
+		return
+	}
+	s.recordAlert(incident.ID, j.filename, alertBody, decision)
+	s.markAlerted(j.filename)
+}
+
+func (s *server) multiPassTranscription(filename, path string, opts TranscriptionOptions, meta formatting.CallMetadata) (transcriptionArtifacts, error) {
 	result := transcriptionArtifacts{}
-	raw, diarized, actualModel, err := s.callOpenAIWithRetries(path, opts)
+	raw, diarized, actualModel, engine, err := s.transcribeAudio(path, opts)
 	if err != nil {
 		return result, err
 	}
+	result = s.enrichTranscript(filename, raw, opts, meta)
 	result.RawTranscript = raw
 	result.DiarizedJSON = diarized
+	result.SpeakerRoles = s.labelSpeakerRoles(diarized)
 	result.ActualModel = actualModel
+	result.Engine = engine
+	return result, nil
+}
+
+// enrichTranscript runs the post-transcription passes (refinement, cleanup,
+// translation, embedding, classification) over a raw transcript.
+func (s *server) enrichTranscript(filename, raw string, opts TranscriptionOptions, meta formatting.CallMetadata) transcriptionArtifacts {
+	result := transcriptionArtifacts{RawTranscript: raw}
 	cleaned := raw
+	language := formatting.DetectLanguage(raw)
+	spanish := language == formatting.LanguageSpanish
 
 	var normalized *string
 	var towns *string
@@ -1665,6 +2646,7 @@ func (s *server) multiPassTranscription(path string, opts TranscriptionOptions,
 		cancel()
 		if refineErr != nil {
 			log.Printf("refine pipeline failed: %v", refineErr)
+			s.recordCallError(filename, refineError(refineErr))
 		} else {
 			if strings.TrimSpace(refined.CleanTranscript) != "" {
 				cleaned = refined.CleanTranscript
@@ -1691,16 +2673,32 @@ func (s *server) multiPassTranscription(path string, opts TranscriptionOptions,
 	if normalized != nil && strings.TrimSpace(*normalized) != "" {
 		normalizationSource = *normalized
 	}
-	if normalizedText := formatting.NormalizeTranscript(normalizationSource); normalizedText != "" {
+	if normalizedText := s.normalizeTranscript(normalizationSource, meta); normalizedText != "" {
 		normalized = &normalizedText
 	}
 	if normalized == nil {
-		norm := formatting.NormalizeTranscript(cleaned)
+		norm := s.normalizeTranscript(cleaned, meta)
 		normalized = &norm
 	}
 
-	if towns == nil {
-		if c, n, t, err := s.domainCleanup(raw); err == nil {
+	if spanish {
+		if c, n, t, err := s.domainCleanup(raw, spanishCleanupPrompt); err == nil {
+			cleaned = c
+			if n != "" {
+				normalized = &n
+			}
+			if len(t) > 0 {
+				data, _ := json.Marshal(t)
+				townsStr := string(data)
+				towns = &townsStr
+			}
+		} else {
+			log.Printf("spanish cleanup failed for %s: %v", filename, err)
+		}
+	} else if towns == nil {
+		cleanupPrompt := s.assignPrompt(context.Background(), prompts.KindCleanup, filename)
+		result.CleanupPrompt = &cleanupPrompt
+		if c, n, t, err := s.domainCleanup(raw, cleanupPrompt.Prompt); err == nil {
 			if c != "" && strings.TrimSpace(cleaned) == "" {
 				cleaned = c
 			}
@@ -1715,16 +2713,24 @@ func (s *server) multiPassTranscription(path string, opts TranscriptionOptions,
 		}
 	}
 
+	// Spanish calls are always translated; AutoTranslate covers any other
+	// call not detected as English.
 	var translation *string
-	if opts.Mode == "translate" || opts.AutoTranslate {
+	if opts.Mode == "translate" || spanish || (opts.AutoTranslate && language != formatting.LanguageEnglish) {
 		if t, err := s.translateTranscript(cleaned); err == nil && t != "" {
 			translation = &t
 		}
 	}
 
-	emb, _ := s.embedTranscript(cleaned)
+	// Embeddings and classification are tuned for English, so use the
+	// translation when the call was not in English.
+	analysis := cleaned
+	if spanish && translation != nil {
+		analysis = *translation
+	}
+	emb, _ := s.embedTranscript(analysis)
 	if callType == nil {
-		callType, _ = s.classifyCallType(cleaned)
+		callType, _ = s.classifyCallType(analysis)
 	}
 
 	result.CleanTranscript = cleaned
@@ -1736,36 +2742,54 @@ func (s *server) multiPassTranscription(path string, opts TranscriptionOptions,
 	result.MetadataJSON = metadataJSON
 	result.AddressJSON = addressJSON
 	result.NeedsManualReview = manualReview
-	return result, nil
+	result.PublicTranscript = s.redactTranscript(cleaned)
+	result.DetectedLanguage = language
+	return result
 }
 
 func (s *server) callOpenAIWithRetries(path string, opts TranscriptionOptions) (string, *string, *string, error) {
+	if !s.openAIAvailable() {
+		return "", nil, nil, errTranscriptionDeferred
+	}
 	var lastErr error
 	for attempt := 0; attempt < 3; attempt++ {
 		transcript, diarized, model, err := s.callOpenAI(path, opts)
+		s.recordOpenAIResult(err)
 		if err == nil {
 			return transcript, diarized, model, nil
 		}
 		lastErr = err
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			return "", nil, nil, err
+		}
+		if !s.openAIAvailable() {
+			return "", nil, nil, fmt.Errorf("%w: %v", errTranscriptionDeferred, err)
+		}
 		time.Sleep(time.Duration(attempt+1) * time.Second)
 	}
 
-	// chunked fallback: split into ~15MB segments
-	chunks, err := chunkFile(path, 15*1024*1024, s.cfg.WorkDir)
+	// chunked fallback: split on silences and stitch the overlaps back together
+	chunks, cleanup, err := chunkAudio(context.Background(), path, s.cfg.WorkDir)
 	if err != nil {
+		log.Printf("chunking %s failed: %v", path, err)
 		return "", nil, nil, lastErr
 	}
+	defer cleanup()
 	var combined []string
 	for _, chunk := range chunks {
 		t, _, model, err := s.callOpenAI(chunk, opts)
+		s.recordOpenAIResult(err)
 		if err != nil {
+			if !s.openAIAvailable() {
+				return "", nil, nil, fmt.Errorf("%w: %v", errTranscriptionDeferred, err)
+			}
 			return "", nil, nil, err
 		}
 		combined = append(combined, t)
 		opts.Model = derefString(model, opts.Model)
 	}
 	finalModel := opts.Model
-	return strings.Join(combined, " "), nil, &finalModel, nil
+	return formatting.MergeChunkTranscripts(combined), nil, &finalModel, nil
 }
 
 func (s *server) callOpenAI(path string, opts TranscriptionOptions) (string, *string, *string, error) {
@@ -1779,7 +2803,7 @@ func (s *server) callOpenAI(path string, opts TranscriptionOptions) (string, *st
 		return "", nil, nil, err
 	}
 	if info.Size() > 25*1024*1024 {
-		return "", nil, nil, fmt.Errorf("file exceeds 25MB limit")
+		return "", nil, nil, errcode.Wrap(errcode.FileTooLarge, fmt.Errorf("file exceeds 25MB limit"))
 	}
 	if _, ok := allowedExtensions[strings.ToLower(filepath.Ext(path))]; !ok {
 		return "", nil, nil, fmt.Errorf("unsupported file type")
@@ -1818,11 +2842,14 @@ func (s *server) callOpenAI(path string, opts TranscriptionOptions) (string, *st
 		}
 	}()
 
-	endpoint := "https://api.openai.com/v1/audio/transcriptions"
+	endpoint := s.cfg.LLM.URL("/v1/audio/transcriptions")
 	if opts.Mode == "translate" {
-		endpoint = "https://api.openai.com/v1/audio/translations"
+		endpoint = s.cfg.LLM.URL("/v1/audio/translations")
 	}
-	req, err := http.NewRequest("POST", endpoint, bodyReader)
+	// Streamed uploads cannot be re-read, so the meter is told the model and
+	// length up front for responses that report neither.
+	ctx := usage.WithAudio(usage.WithStage(context.Background(), usage.StageTranscribe), opts.Model, probeDuration(path))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bodyReader)
 	if err != nil {
 		return "", nil, nil, err
 	}
@@ -1837,7 +2864,7 @@ func (s *server) callOpenAI(path string, opts TranscriptionOptions) (string, *st
 
 	if resp.StatusCode >= 300 {
 		b, _ := io.ReadAll(resp.Body)
-		return "", nil, nil, fmt.Errorf("openai status %d: %s", resp.StatusCode, string(b))
+		return "", nil, nil, &openAIStatusError{StatusCode: resp.StatusCode, Body: string(b)}
 	}
 
 	format := opts.Format
@@ -1882,14 +2909,14 @@ func (s *server) enhanceTranscript(raw string) (string, error) {
 	}
 	prompt := "Clean up the following transcript. Improve punctuation, remove duplicated phrases, and keep speaker-neutral text. Return only the cleaned transcript."
 	payload := map[string]interface{}{
-		"model": "gpt-4o-mini",
+		"model": s.cfg.LLM.Cleanup.ModelOr("gpt-4o-mini"),
 		"messages": []map[string]string{
 			{"role": "system", "content": prompt},
 			{"role": "user", "content": raw},
 		},
 	}
 	buf, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(usage.WithStage(context.Background(), usage.StageCleanup), "POST", s.cfg.LLM.Cleanup.URL("/v1/chat/completions"), bytes.NewReader(buf))
 	if err != nil {
 		return "", err
 	}
@@ -1934,7 +2961,7 @@ func (s *server) translateTranscript(text string) (string, error) {
 		},
 	}
 	buf, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(usage.WithStage(context.Background(), usage.StageTranslate), "POST", s.cfg.LLM.URL("/v1/chat/completions"), bytes.NewReader(buf))
 	if err != nil {
 		return "", err
 	}
@@ -1965,21 +2992,17 @@ func (s *server) translateTranscript(text string) (string, error) {
 	return strings.TrimSpace(parsed.Choices[0].Message.Content), nil
 }
 
-func (s *server) domainCleanup(text string) (string, string, []string, error) {
+func (s *server) domainCleanup(text, prompt string) (string, string, []string, error) {
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
 		return text, "", nil, errors.New("OPENAI_API_KEY not set")
 	}
-	settings, err := s.loadSettings()
-	if err != nil {
-		return text, "", nil, err
-	}
-	prompt := strings.TrimSpace(settings.CleanupPrompt)
+	prompt = strings.TrimSpace(prompt)
 	if prompt == "" {
 		prompt = defaultCleanupPrompt
 	}
 	payload := map[string]interface{}{
-		"model":           "gpt-4.1-mini",
+		"model":           s.cfg.LLM.Cleanup.ModelOr("gpt-4.1-mini"),
 		"response_format": map[string]string{"type": "json_object"},
 		"messages": []map[string]string{
 			{"role": "system", "content": prompt},
@@ -1987,7 +3010,7 @@ func (s *server) domainCleanup(text string) (string, string, []string, error) {
 		},
 	}
 	buf, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(usage.WithStage(context.Background(), usage.StageCleanup), "POST", s.cfg.LLM.Cleanup.URL("/v1/chat/completions"), bytes.NewReader(buf))
 	if err != nil {
 		return text, "", nil, err
 	}
@@ -2034,21 +3057,22 @@ func (s *server) domainCleanup(text string) (string, string, []string, error) {
 	return cleaned, normalized, result.RecognizedTowns, nil
 }
 
+const classifyCallTypePrompt = "Classify the emergency call type into one of: Fire; EMS/Medical; Motor Vehicle Accident; Rescue; Hazmat; Alarm; Other / Unknown. Reply with only the label."
+
 func (s *server) classifyCallType(text string) (*string, error) {
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
 		return nil, errors.New("OPENAI_API_KEY not set")
 	}
-	prompt := "Classify the emergency call type into one of: Fire; EMS/Medical; Motor Vehicle Accident; Rescue; Hazmat; Alarm; Other / Unknown. Reply with only the label."
+	messages := []map[string]string{{"role": "system", "content": classifyCallTypePrompt}}
+	messages = append(messages, s.classifyExamples()...)
+	messages = append(messages, map[string]string{"role": "user", "content": text})
 	payload := map[string]interface{}{
-		"model": "gpt-4.1-mini",
-		"messages": []map[string]string{
-			{"role": "system", "content": prompt},
-			{"role": "user", "content": text},
-		},
+		"model":    s.cfg.LLM.Classify.ModelOr("gpt-4.1-mini"),
+		"messages": messages,
 	}
 	buf, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(usage.WithStage(context.Background(), usage.StageClassify), "POST", s.cfg.LLM.Classify.URL("/v1/chat/completions"), bytes.NewReader(buf))
 	if err != nil {
 		return nil, err
 	}
@@ -2093,7 +3117,7 @@ func (s *server) embedTranscript(text string) ([]float64, error) {
 		"input": text,
 	}
 	buf, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/embeddings", bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(usage.WithStage(context.Background(), usage.StageEmbed), "POST", s.cfg.LLM.URL("/v1/embeddings"), bytes.NewReader(buf))
 	if err != nil {
 		return nil, err
 	}
@@ -2122,47 +3146,15 @@ func (s *server) embedTranscript(text string) ([]float64, error) {
 	return parsed.Data[0].Embedding, nil
 }
 
-func chunkFile(path string, maxBytes int64, workDir string) ([]string, error) {
-	info, err := os.Stat(path)
-	if err != nil {
-		return nil, err
-	}
-	if info.Size() <= maxBytes {
-		return []string{path}, nil
-	}
-	base := filepath.Base(path)
-	var paths []string
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	buf := make([]byte, maxBytes)
-	idx := 0
-	for {
-		n, err := io.ReadFull(f, buf)
-		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-			return nil, err
-		}
-		if n == 0 {
-			break
-		}
-		chunkPath := filepath.Join(workDir, fmt.Sprintf("%s.part%d", base, idx))
-		if err := os.WriteFile(chunkPath, buf[:n], 0644); err != nil {
-			return nil, err
-		}
-		paths = append(paths, chunkPath)
-		idx++
-		if err == io.EOF {
-			break
-		}
-	}
-	return paths, nil
+func (s *server) sendGroupMe(text string) error {
+	return s.postGroupMe(getBotID(s.liveConfig()), text)
 }
 
-func (s *server) sendGroupMe(text string) error {
+// postGroupMe posts text as the given bot, which may differ from the main
+// alert bot (see escalation.go).
+func (s *server) postGroupMe(botID, text string) error {
 	payload := map[string]string{
-		"bot_id": s.botID,
+		"bot_id": botID,
 		"text":   text,
 	}
 	buf, _ := json.Marshal(payload)
@@ -2216,7 +3208,11 @@ func (s *server) handleReady(w http.ResponseWriter, r *http.Request) {
 
 func (s *server) handleDebugQueue(w http.ResponseWriter, r *http.Request) {
 	if s.queue == nil {
-		http.Error(w, "queue disabled", http.StatusServiceUnavailable)
+		if s.shared == nil {
+			http.Error(w, "queue disabled", http.StatusServiceUnavailable)
+			return
+		}
+		respondJSON(w, QueueDebugResponse{Jobs: []QueueJobDebug{}, Shared: s.sharedQueueDebug(r.Context())})
 		return
 	}
 	stats := s.queue.Stats()
@@ -2226,8 +3222,48 @@ func (s *server) handleDebugQueue(w http.ResponseWriter, r *http.Request) {
 		Length:        stats.Length,
 		Capacity:      stats.Capacity,
 		Workers:       stats.WorkerCount,
+		Active:        stats.Active,
+		Paused:        stats.Paused,
+		PausedBy:      stats.PausedBy,
 		ProcessedJobs: snapshot.ProcessedJobs,
 		FailedJobs:    snapshot.FailedJobs,
+		WorkCleanup:   WorkCleanupDebug{FilesRemoved: snapshot.WorkFilesRemoved, BytesReclaimed: snapshot.WorkBytesReclaimed},
+		Jobs:          []QueueJobDebug{},
+		OpenAI:        s.openAIBreakerDebug(),
+		Shared:        s.sharedQueueDebug(r.Context()),
+		Watcher:       s.watchdog.debug(),
+	}
+	for _, job := range s.queue.Jobs() {
+		entry := QueueJobDebug{
+			ID:             job.ID,
+			Filename:       job.FileName,
+			Source:         job.Source,
+			Stage:          job.Stage,
+			Attempt:        job.Attempt,
+			ElapsedSeconds: math.Round(job.Elapsed.Seconds()*10) / 10,
+		}
+		if !job.QueuedAt.IsZero() {
+			ts := job.QueuedAt.UTC()
+			entry.QueuedAt = &ts
+		}
+		if !job.StartedAt.IsZero() {
+			ts := job.StartedAt.UTC()
+			entry.StartedAt = &ts
+		}
+		resp.Jobs = append(resp.Jobs, entry)
+	}
+	drain := s.queue.DrainEstimate()
+	resp.Drain = QueueDrainEstimation{
+		Backlog:          drain.Backlog,
+		RecentCompleted:  drain.RecentCompleted,
+		ThroughputPerMin: math.Round(drain.ThroughputPerMin*100) / 100,
+		AvgJobSeconds:    math.Round(drain.AvgJobDuration.Seconds()*10) / 10,
+	}
+	if drain.Known {
+		seconds := math.Round(drain.ETA.Seconds())
+		resp.Drain.EstimatedSeconds = &seconds
+		drainedAt := time.Now().UTC().Add(drain.ETA)
+		resp.Drain.EstimatedDrainedAt = &drainedAt
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -2252,32 +3288,50 @@ func (s *server) handlePreview(w http.ResponseWriter, r *http.Request) {
 	}
 
 	t, err := s.getTranscription(requested)
-	if err != nil {
+	if err != nil || !s.visibleTo(r, *t) {
 		http.NotFound(w, r)
 		return
 	}
 
-	img, err := s.renderPreviewImage(*t)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if notModified(w, r, previewETag(*t), t.UpdatedAt) {
+		return
+	}
+	data, complete, err := s.previewPNG(*t)
 	if err != nil {
 		log.Printf("preview render failed for %s: %v", requested, err)
+		w.Header().Set("Cache-Control", "no-store")
 		http.Error(w, "preview unavailable", http.StatusInternalServerError)
 		return
 	}
+	if !complete {
+		// Without validators the client refetches once the map is back.
+		w.Header().Del("ETag")
+		w.Header().Del("Last-Modified")
+		w.Header().Set("Cache-Control", "no-cache")
+	}
 
 	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Cache-Control", "public, max-age=300")
-	if err := png.Encode(w, img); err != nil {
-		log.Printf("preview encode failed for %s: %v", requested, err)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("preview write failed for %s: %v", requested, err)
 	}
 }
 
 func (s *server) renderPreviewImage(t transcription) (image.Image, error) {
+	img, _, err := s.renderPreview(t)
+	return img, err
+}
+
+// renderPreview draws t's preview card. Calls with coordinates get a map
+// thumbnail beside the text; complete is false when that map could not be
+// fetched, so the card should not be cached.
+func (s *server) renderPreview(t transcription) (image.Image, bool, error) {
 	const (
-		width      = 1200
-		height     = 630
-		padding    = 48
-		textWidth  = width - (padding * 2)
-		lineHeight = 22
+		width   = 1200
+		height  = 630
+		padding = 48
+		mapGap  = 32
+		mapTop  = padding + 30
 	)
 
 	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
@@ -2292,7 +3346,16 @@ func (s *server) renderPreviewImage(t transcription) (image.Image, error) {
 	draw.Draw(canvas, image.Rect(padding/2, padding/2, width-padding/2, height-padding/2), panel, image.Point{}, draw.Src)
 	draw.Draw(canvas, image.Rect(padding, padding, width-padding, padding+6), accent, image.Point{}, draw.Src)
 
-	meta, err := formatting.ParseCallMetadataFromFilename(t.Filename, s.tz)
+	textWidth := width - (padding * 2)
+	thumb, wantMap := s.previewMap(t)
+	if thumb != nil {
+		mapRect := image.Rect(width-padding-previewMapSize, mapTop, width-padding, mapTop+previewMapSize)
+		draw.Draw(canvas, mapRect.Inset(-2), accent, image.Point{}, draw.Src)
+		draw.Draw(canvas, mapRect, thumb, image.Point{}, draw.Src)
+		textWidth -= previewMapSize + mapGap
+	}
+
+	meta, err := s.parseCallMetadata(t.Filename, "")
 	if err != nil {
 		meta = formatting.CallMetadata{RawFileName: t.Filename, DateTime: t.CreatedAt.In(s.tz)}
 	}
@@ -2323,30 +3386,19 @@ func (s *server) renderPreviewImage(t transcription) (image.Image, error) {
 		snippet = truncateText(normalizeWhitespace(*txt), 420)
 	}
 
-	face := basicfont.Face7x13
-	mutedY := drawLines(canvas, padding, padding+40, lineHeight, wrapLines("Sussex County Alerts", textWidth, face), muted, face)
-	titleY := drawLines(canvas, padding, mutedY+8, lineHeight+4, wrapLines(title, textWidth, face), text, face)
-	subY := drawLines(canvas, padding, titleY+6, lineHeight, wrapLines(strings.Join(sublineParts, " • "), textWidth, face), muted, face)
-	drawLines(canvas, padding, subY+6, lineHeight, wrapLines(statusLine, textWidth, face), warning, face)
+	// Long titles and transcripts are set smaller before they are cut short.
+	loadPreviewFonts()
+	y := drawBlock(canvas, padding, mapTop, fitPreviewText(previewBold, "Sussex County Alerts", textWidth, 22, 22, 0), muted) + 8
+	y = drawBlock(canvas, padding, y, fitPreviewText(previewBold, title, textWidth, 46, 30, 2*previewLineHeight(46)), text) + 10
+	y = drawBlock(canvas, padding, y, fitPreviewText(previewRegular, strings.Join(sublineParts, " • "), textWidth, 24, 18, 2*previewLineHeight(24)), muted) + 6
+	y = drawBlock(canvas, padding, y, fitPreviewText(previewBold, statusLine, textWidth, 22, 22, 0), warning) + 18
 
-	captionY := subY + 40
-	draw.Draw(canvas, image.Rect(padding, captionY-8, width-padding, captionY-4), accent, image.Point{}, draw.Src)
-	drawLines(canvas, padding, captionY+12, lineHeight, wrapLines(callType+" preview", textWidth, face), text, face)
+	draw.Draw(canvas, image.Rect(padding, y, padding+textWidth, y+4), accent, image.Point{}, draw.Src)
+	y = drawBlock(canvas, padding, y+16, fitPreviewText(previewBold, callType+" preview", textWidth, 26, 20, 0), text) + 10
 
-	drawLines(canvas, padding, captionY+34, lineHeight, wrapLines(snippet, textWidth, face), text, face)
-
-	return canvas, nil
-}
+	drawBlock(canvas, padding, y, fitPreviewText(previewRegular, snippet, textWidth, 30, 18, height-padding-y), text)
 
-func drawLines(dst draw.Image, x, startY, lineHeight int, lines []string, colorSrc image.Image, face font.Face) int {
-	d := &font.Drawer{Dst: dst, Src: colorSrc, Face: face}
-	y := startY
-	for _, line := range lines {
-		d.Dot = fixed.P(x, y)
-		d.DrawString(line)
-		y += lineHeight
-	}
-	return y
+	return canvas, thumb != nil || !wantMap, nil
 }
 
 func wrapLines(text string, maxWidth int, face font.Face) []string {
@@ -2429,6 +3481,8 @@ func (s *server) handleSettings(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "settings error", http.StatusInternalServerError)
 			return
 		}
+		settings.WebhookEndpoints = redactWebhookSecrets(settings.WebhookEndpoints)
+		settings.Email = redactEmailPassword(settings.Email)
 		respondJSON(w, settings)
 	case http.MethodPost:
 		if !requireAdmin(w, r) {
@@ -2448,12 +3502,48 @@ func (s *server) handleSettings(w http.ResponseWriter, r *http.Request) {
 		if payload.DefaultFormat == "" {
 			payload.DefaultFormat = "json"
 		}
+		if err := validateWebhookEndpoints(payload.WebhookEndpoints); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateEmailSettings(&payload.Email); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		existing, err := s.loadSettings()
+		if err != nil {
+			log.Printf("load settings failed: %v", err)
+			http.Error(w, "settings error", http.StatusInternalServerError)
+			return
+		}
+		keepWebhookSecrets(payload.WebhookEndpoints, existing.WebhookEndpoints)
+		keepEmailPassword(&payload.Email, existing.Email)
+		resp := map[string]interface{}{"status": "ok"}
+		if prompt := strings.TrimSpace(payload.CleanupPrompt); prompt != "" && payload.CleanupPrompt != existing.CleanupPrompt {
+			result, err := s.testCleanupPrompt(payload.CleanupPrompt)
+			if err != nil {
+				log.Printf("cleanup prompt test failed: %v", err)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(promptTestStatus(err))
+				respondJSON(w, map[string]interface{}{"error": "cleanup prompt test failed: " + err.Error(), "prompt_test": result})
+				return
+			}
+			resp["prompt_test"] = result
+		}
 		if err := s.saveSettings(payload); err != nil {
 			log.Printf("save settings failed: %v", err)
 			http.Error(w, "save error", http.StatusInternalServerError)
 			return
 		}
-		respondJSON(w, map[string]string{"status": "ok"})
+		before, after := settingsAuditState(existing, payload)
+		noteAudit(r, "settings.update", "", before, after)
+		version, err := s.recordSettingsVersion(existing, settingsActor(r), settingsActionUpdate, 0)
+		if err != nil {
+			log.Printf("settings history write failed: %v", err)
+		} else {
+			resp["version"] = version
+		}
+		respondJSON(w, resp)
 	default:
 		http.NotFound(w, r)
 	}
@@ -2475,7 +3565,14 @@ func (s *server) handleFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	sourcePath := filepath.Join(s.cfg.CallsDir, cleaned)
-	if _, err := os.Stat(sourcePath); err != nil {
+	_, err := os.Stat(sourcePath)
+	if old := s.oldCallsDir(); os.IsNotExist(err) && old != "" {
+		// Recordings not yet copied during a CALLS_DIR move are still
+		// served from the old directory.
+		sourcePath = filepath.Join(old, cleaned)
+		_, err = os.Stat(sourcePath)
+	}
+	if err != nil {
 		if os.IsNotExist(err) {
 			http.NotFound(w, r)
 			return
@@ -2483,6 +3580,10 @@ func (s *server) handleFile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "error", http.StatusInternalServerError)
 		return
 	}
+	if s.audioDeleted(r.Context(), sourcePath) {
+		http.NotFound(w, r)
+		return
+	}
 	http.ServeFile(w, r, sourcePath)
 }
 
@@ -2496,7 +3597,7 @@ func (s *server) handleTranscriptionIndex(w http.ResponseWriter, r *http.Request
 			http.Error(w, "filename required", http.StatusBadRequest)
 			return
 		}
-		if s.queue == nil {
+		if !s.canEnqueue() {
 			http.Error(w, "queue disabled", http.StatusServiceUnavailable)
 			return
 		}
@@ -2530,6 +3631,21 @@ func (s *server) handleTranscription(w http.ResponseWriter, r *http.Request) {
 	case len(parts) == 2 && parts[1] == "similar" && r.Method == http.MethodGet:
 		s.handleSimilar(w, r, filename)
 		return
+	case len(parts) == 2 && parts[1] == "tts" && r.Method == http.MethodGet:
+		s.handleSpeech(w, r, filename)
+		return
+	case len(parts) == 2 && parts[1] == "report.pdf" && r.Method == http.MethodGet:
+		s.handleIncidentReport(w, r, filename)
+		return
+	case len(parts) == 2 && parts[1] == "share" && r.Method == http.MethodPost:
+		s.handleShareLink(w, r, filename)
+		return
+	case len(parts) == 2 && parts[1] == "restore" && r.Method == http.MethodPost:
+		s.handleCallRestore(w, r, filename)
+		return
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		s.handleCallDelete(w, r, filename)
+		return
 	}
 
 	if r.Method != http.MethodGet {
@@ -2549,36 +3665,59 @@ func (s *server) handleTranscription(w http.ResponseWriter, r *http.Request) {
 	}
 
 	existing, err := s.getTranscription(cleaned)
+	if errors.Is(err, sql.ErrNoRows) {
+		// An alias answers with the call it was folded into.
+		if target, ok := s.aliasOf(cleaned); ok {
+			existing, err = s.getTranscription(target)
+		}
+	}
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		log.Printf("fetch transcription %s failed: %v", cleaned, err)
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
 
+	if existing != nil && !s.visibleTo(r, *existing) {
+		http.NotFound(w, r)
+		return
+	}
+
 	if existing != nil {
 		base := s.resolveBaseURL(r)
+		resp := []transcriptionResponse{s.responseFor(r, *existing, base)}
+		s.attachCallNotes(r, resp)
+		if aliases, err := s.callAliases(r.Context(), existing.Filename); err != nil {
+			log.Printf("alias lookup for %s failed: %v", existing.Filename, err)
+		} else {
+			resp[0].Aliases = aliases
+		}
+		if pages, err := s.callPages(r.Context(), existing.Filename); err != nil {
+			log.Printf("page lookup for %s failed: %v", existing.Filename, err)
+		} else {
+			resp[0].Pages = pages
+		}
 		switch existing.Status {
 		case statusDone:
-			respondJSON(w, s.toResponse(*existing, base))
+			respondJSON(w, resp[0])
 			return
 		case statusProcessing:
-			respondJSON(w, s.toResponse(*existing, base))
+			respondJSON(w, resp[0])
 			return
 		case statusError:
-			if s.queue != nil && requireAdmin(w, r) {
-				s.queueJob("api", cleaned, false, true, opts)
+			if s.canEnqueue() && requireAdmin(w, r) {
+				s.queueJob("api", existing.Filename, false, true, opts)
 				respondJSON(w, map[string]interface{}{
 					"filename": existing.Filename,
 					"status":   statusQueued,
 				})
 				return
 			}
-			respondJSON(w, s.toResponse(*existing, base))
+			respondJSON(w, resp[0])
 			return
 		}
 	}
 
-	if s.queue == nil {
+	if !s.canEnqueue() {
 		http.Error(w, "queue disabled", http.StatusServiceUnavailable)
 		return
 	}
@@ -2645,50 +3784,6 @@ func (s *server) parseOptionsFromRequest(r *http.Request) (TranscriptionOptions,
 	return opts, nil
 }
 
-func (s *server) handleSimilar(w http.ResponseWriter, r *http.Request, filename string) {
-	t, err := s.getTranscription(filename)
-	if err != nil {
-		http.NotFound(w, r)
-		return
-	}
-	if t == nil || t.CleanTranscript == nil {
-		respondJSON(w, []similar{})
-		return
-	}
-	emb, err := s.loadEmbedding(filename)
-	if err != nil || len(emb) == 0 {
-		respondJSON(w, []similar{})
-		return
-	}
-
-	rows, err := queryWithRetry(s.db, `SELECT filename, embedding FROM transcriptions WHERE filename != ? AND embedding IS NOT NULL`, filename)
-	if err != nil {
-		log.Printf("similar query failed for %s: %v", filename, err)
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-	var sims []similar
-	for rows.Next() {
-		var name string
-		var embText sql.NullString
-		if err := rows.Scan(&name, &embText); err != nil {
-			continue
-		}
-		otherEmb, _ := parseEmbedding(embText.String)
-		if len(otherEmb) == 0 {
-			continue
-		}
-		score := cosineSimilarity(emb, otherEmb)
-		sims = append(sims, similar{Filename: name, Score: score})
-	}
-	sort.Slice(sims, func(i, j int) bool { return sims[i].Score > sims[j].Score })
-	if len(sims) > 5 {
-		sims = sims[:5]
-	}
-	respondJSON(w, sims)
-}
-
 func (s *server) handleLastSixHoursStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -2698,24 +3793,37 @@ func (s *server) handleLastSixHoursStats(w http.ResponseWriter, r *http.Request)
 	rawWindow := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("window")))
 	windowName, windowDuration := normalizeWindowName(rawWindow, "6h")
 
-	baseURL := s.resolveBaseURL(r)
-	query := `SELECT id, filename, source_path, processed_path, COALESCE(ingest_source,'') as ingest_source, transcript_text, raw_transcript_text, clean_transcript_text, translation_text, status, last_error, size_bytes, duration_seconds, hash, duplicate_of, requested_model, requested_mode, requested_format, actual_openai_model_used, diarized_json, recognized_towns, normalized_transcript, call_type, call_timestamp, tags, latitude, longitude, location_label, location_source, refined_metadata, address_json, needs_manual_review, created_at, updated_at FROM transcriptions`
+	admin, baseURL := isAdminRequest(r), s.resolveBaseURL(r)
+	key := fmt.Sprintf("last6h|%s|%t|%s", windowName, admin, baseURL)
+	stats, err := s.queries.load(key, s.queryCacheTTL(), func() (interface{}, error) {
+		stats, _, err := s.windowStats(r.Context(), admin, windowName, windowDuration, baseURL)
+		return stats, err
+	})
+	if err != nil {
+		log.Printf("stats query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, stats)
+}
+
+// windowStats counts the calls in a window for the dashboard summary, along
+// with the start of its first hourly bucket.
+func (s *server) windowStats(ctx context.Context, admin bool, windowName string, windowDuration time.Duration, baseURL string) (lastSixHourStatsResponse, time.Time, error) {
+	clause := "WHERE deleted_at IS NULL"
 	args := []interface{}{}
 	var cutoff time.Time
 	if windowDuration > 0 {
 		cutoff = time.Now().UTC().Add(-windowDuration)
-		query += " WHERE COALESCE(call_timestamp, created_at) >= ?"
+		clause += " AND effective_ts >= ?"
 		args = append(args, cutoff)
 	}
-	query += " ORDER BY COALESCE(call_timestamp, created_at) DESC LIMIT 500"
+	clause += " ORDER BY effective_ts DESC LIMIT 500"
 
-	rows, err := queryWithRetry(s.db, query, args...)
+	records, err := s.store.Select(ctx, clause, args...)
 	if err != nil {
-		log.Printf("stats query failed: %v", err)
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
+		return lastSixHourStatsResponse{}, time.Time{}, err
 	}
-	defer rows.Close()
 
 	bucketCount := 6
 	if windowDuration > 0 {
@@ -2749,13 +3857,11 @@ func (s *server) handleLastSixHoursStats(w http.ResponseWriter, r *http.Request)
 	}
 
 	var calls []transcriptionResponse
-	for rows.Next() {
-		var t transcription
-		if err := scanTranscription(rows, &t); err != nil {
-			http.Error(w, "db error", http.StatusInternalServerError)
-			return
+	for _, t := range records {
+		if !admin && s.isRestricted(t) {
+			continue
 		}
-		call := s.toResponse(t, baseURL)
+		call := s.responseView(admin, t, baseURL)
 		callTime := call.CallTimestamp.UTC()
 		if windowDuration > 0 && callTime.Before(cutoff) {
 			continue
@@ -2780,11 +3886,6 @@ func (s *server) handleLastSixHoursStats(w http.ResponseWriter, r *http.Request)
 			hourlyCounts[bucketKey]++
 		}
 	}
-	if err := rows.Err(); err != nil {
-		log.Printf("stats rows error: %v", err)
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
-	}
 
 	for i, bucket := range hourlyTemplate {
 		hourlyTemplate[i].Count = hourlyCounts[bucket.Hour]
@@ -2794,9 +3895,8 @@ func (s *server) handleLastSixHoursStats(w http.ResponseWriter, r *http.Request)
 	stats.TopAgencies = topCounts(stats.ByAgency, 3)
 	stats.IncidentsPerHour = hourlyTemplate
 	stats.Calls = calls
-	stats.MapboxToken = s.cfg.MapboxToken
-
-	respondJSON(w, stats)
+	stats.MapEnabled = strings.TrimSpace(s.cfg.MapboxToken) != ""
+	return stats, bucketStart, nil
 }
 
 func (s *server) handleHotspots(w http.ResponseWriter, r *http.Request) {
@@ -2815,8 +3915,23 @@ func (s *server) handleHotspots(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	hotspots, err := s.queries.load(fmt.Sprintf("hotspots|%s|%d", windowName, limit), s.queryCacheTTL(), func() (interface{}, error) {
+		return s.loadHotspots(windowDuration, limit)
+	})
+	if err != nil {
+		log.Printf("hotspot query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, hotspotListResponse{Window: windowName, Hotspots: hotspots.([]hotspotSummary)})
+}
+
+// loadHotspots returns the locations with the most finished calls in the
+// window, busiest first.
+func (s *server) loadHotspots(windowDuration time.Duration, limit int) ([]hotspotSummary, error) {
 	clauses := []string{
 		"status = 'done'",
+		"deleted_at IS NULL",
 		"location_label IS NOT NULL",
 		"TRIM(location_label) != ''",
 		"latitude IS NOT NULL",
@@ -2825,13 +3940,13 @@ func (s *server) handleHotspots(w http.ResponseWriter, r *http.Request) {
 	args := []interface{}{}
 	if windowDuration > 0 {
 		cutoff := time.Now().UTC().Add(-windowDuration)
-		clauses = append(clauses, "COALESCE(call_timestamp, created_at) >= ?")
+		clauses = append(clauses, "effective_ts >= ?")
 		args = append(args, cutoff)
 	}
 
 	query := fmt.Sprintf(`SELECT location_label, latitude, longitude, COUNT(*) AS freq,
-       MIN(COALESCE(call_timestamp, created_at)) AS first_seen,
-       MAX(COALESCE(call_timestamp, created_at)) AS last_seen
+       MIN(effective_ts) AS first_seen,
+       MAX(effective_ts) AS last_seen
 FROM transcriptions
 WHERE %s
 GROUP BY location_label, latitude, longitude
@@ -2841,9 +3956,7 @@ LIMIT ?`, strings.Join(clauses, " AND "))
 
 	rows, err := queryWithRetry(s.db, query, args...)
 	if err != nil {
-		log.Printf("hotspot query failed: %v", err)
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -2854,8 +3967,7 @@ LIMIT ?`, strings.Join(clauses, " AND "))
 		var count int
 		var firstSeen, lastSeen sql.NullTime
 		if err := rows.Scan(&label, &lat, &lon, &count, &firstSeen, &lastSeen); err != nil {
-			http.Error(w, "db error", http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 		entry := hotspotSummary{
 			Label:     label,
@@ -2873,13 +3985,7 @@ LIMIT ?`, strings.Join(clauses, " AND "))
 		}
 		hotspots = append(hotspots, entry)
 	}
-	if err := rows.Err(); err != nil {
-		log.Printf("hotspot rows error: %v", err)
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
-	}
-
-	respondJSON(w, hotspotListResponse{Window: windowName, Hotspots: hotspots})
+	return hotspots, rows.Err()
 }
 
 func (s *server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
@@ -2892,20 +3998,60 @@ func (s *server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
 	statusFilter := strings.TrimSpace(r.URL.Query().Get("status"))
 	callTypeFilter := strings.TrimSpace(r.URL.Query().Get("call_type"))
 	tagFilter := parseTagFilter(r.URL.Query().Get("tags"))
+	townFilter := cleanTownList(strings.Split(r.URL.Query().Get("towns"), ","))
 
 	rawWindow := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("window")))
 	if rawWindow == "" {
 		rawWindow = strings.ToLower(strings.TrimSpace(r.URL.Query().Get("range")))
 	}
+
+	// saved={id} loads a saved search; parameters given explicitly win.
+	if raw := strings.TrimSpace(r.URL.Query().Get("saved")); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid saved search id", http.StatusBadRequest)
+			return
+		}
+		saved, err := s.loadSavedSearch(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "saved search not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("saved search load failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if search == "" {
+			search = saved.Query
+		}
+		if callTypeFilter == "" {
+			callTypeFilter = saved.CallType
+		}
+		if len(tagFilter) == 0 {
+			tagFilter = saved.Tags
+		}
+		if len(townFilter) == 0 {
+			townFilter = saved.Towns
+		}
+		if rawWindow == "" {
+			rawWindow = saved.Window
+		}
+	}
 	windowName, windowDuration := normalizeWindowName(rawWindow, "24h")
 
-	base := `SELECT id, filename, source_path, processed_path, COALESCE(ingest_source,'') as ingest_source, transcript_text, raw_transcript_text, clean_transcript_text, translation_text, status, last_error, size_bytes, duration_seconds, hash, duplicate_of, requested_model, requested_mode, requested_format, actual_openai_model_used, diarized_json, recognized_towns, normalized_transcript, call_type, call_timestamp, tags, latitude, longitude, location_label, location_source, refined_metadata, address_json, needs_manual_review, created_at, updated_at FROM transcriptions`
-	where := []string{}
+	// Deleted calls stay out of the list; admins can ask for only them with
+	// deleted=true.
+	clause := ""
+	where := []string{"deleted_at IS NULL"}
+	if isAdminRequest(r) && r.URL.Query().Get("deleted") == "true" {
+		where[0] = "deleted_at IS NOT NULL"
+	}
 	args := []interface{}{}
 	var cutoff time.Time
 	if windowDuration > 0 {
 		cutoff = time.Now().UTC().Add(-windowDuration)
-		where = append(where, "COALESCE(call_timestamp, created_at) >= ?")
+		where = append(where, "effective_ts >= ?")
 		args = append(args, cutoff)
 	}
 	if search != "" {
@@ -2921,27 +4067,22 @@ func (s *server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
 		where = append(where, "lower(coalesce(call_type,'')) = ?")
 		args = append(args, strings.ToLower(callTypeFilter))
 	}
-	if len(where) > 0 {
-		base += " WHERE " + strings.Join(where, " AND ")
-	}
-	base += " ORDER BY COALESCE(call_timestamp, created_at) DESC LIMIT 500"
+	clause = "WHERE " + strings.Join(where, " AND ")
+	clause += " ORDER BY effective_ts DESC LIMIT 500"
 
-	rows, err := queryWithRetry(s.db, base, args...)
+	records, err := s.store.Select(r.Context(), clause, args...)
 	if err != nil {
 		log.Printf("transcriptions query failed: %v", err)
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
 	var calls []transcriptionResponse
-	for rows.Next() {
-		var t transcription
-		if err := scanTranscription(rows, &t); err != nil {
-			http.Error(w, "db error", http.StatusInternalServerError)
-			return
+	for _, t := range records {
+		if !s.visibleTo(r, t) {
+			continue
 		}
-		calls = append(calls, s.toResponse(t, baseURL))
+		calls = append(calls, s.responseFor(r, t, baseURL))
 	}
 
 	filtered := make([]transcriptionResponse, 0, len(calls))
@@ -2957,6 +4098,9 @@ func (s *server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
 		if len(tagFilter) > 0 && !hasTags(call.Tags, tagFilter) {
 			continue
 		}
+		if len(townFilter) > 0 && !inTowns(call, townFilter) {
+			continue
+		}
 		filtered = append(filtered, call)
 		stats.StatusCounts[call.Status]++
 		if call.DuplicateOf != nil && *call.DuplicateOf != "" {
@@ -2981,7 +4125,9 @@ func (s *server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	respondJSON(w, callListResponse{Window: windowName, Calls: filtered, Stats: stats, MapboxToken: s.cfg.MapboxToken})
+	assignMapMarkers(filtered)
+	s.attachCallNotes(r, filtered)
+	respondJSONCached(w, r, callListResponse{Window: windowName, Calls: filtered, Stats: stats, MapEnabled: strings.TrimSpace(s.cfg.MapboxToken) != ""})
 }
 
 func respondJSON(w http.ResponseWriter, v interface{}) {
@@ -3030,6 +4176,21 @@ func parseTagFilter(raw string) []string {
 	return tags
 }
 
+// inTowns reports whether call is in, or mentions, one of towns.
+func inTowns(call transcriptionResponse, towns []string) bool {
+	for _, town := range towns {
+		if strings.EqualFold(call.Town, town) {
+			return true
+		}
+		for _, recognized := range call.RecognizedTowns {
+			if strings.EqualFold(recognized, town) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func hasTags(values []string, required []string) bool {
 	if len(required) == 0 {
 		return true
@@ -3332,13 +4493,16 @@ func (s *server) buildSegments(t transcription) []transcriptSegment {
 	}
 	if t.DiarizedJSON != nil {
 		if segments := parseSegmentsFromDiarized(*t.DiarizedJSON); len(segments) > 0 {
-			return segments
+			return applySpeakerRoles(segments, t.SpeakerRoles)
 		}
 	}
 	return fallbackSegmentsFromTranscript(transcriptText, t.DurationSeconds)
 }
 
 func (s *server) audioFilename(t transcription) string {
+	if !hasAudio(t) {
+		return ""
+	}
 	if name := filepath.Base(strings.TrimSpace(t.ProcessedPath)); name != "" {
 		return name
 	}
@@ -3348,6 +4512,21 @@ func (s *server) audioFilename(t transcription) string {
 	return t.Filename
 }
 
+// hasAudio reports whether a call has a recording. Placeholder CAD rows and
+// metadata-only alerts (see metadata_alerts.go) have none.
+func hasAudio(t transcription) bool {
+	return strings.TrimSpace(t.SourcePath) != "" || strings.TrimSpace(t.ProcessedPath) != ""
+}
+
+// listenURLFor is the external link to a call's audio file, or "" for a call
+// without audio.
+func listenURLFor(audioName string) string {
+	if audioName == "" {
+		return ""
+	}
+	return formatting.BuildListenURL(audioName)
+}
+
 func sanitizeSummary(raw string) string {
 	trimmed := strings.TrimSpace(raw)
 	if trimmed == "" {
@@ -3430,7 +4609,7 @@ func (s *server) buildIncidentDetails(meta formatting.CallMetadata, callType *st
 		incidentID = audioFilename
 	}
 
-	return formatting.IncidentDetails{
+	incident := formatting.IncidentDetails{
 		ID:            incidentID,
 		PrettyTitle:   formatting.FormatPrettyTitle(meta.RawFileName, ts, s.tz),
 		Agency:        meta.AgencyDisplay,
@@ -3448,10 +4627,17 @@ func (s *server) buildIncidentDetails(meta formatting.CallMetadata, callType *st
 		AudioPath:     audioPath,
 		AudioFilename: audioFilename,
 	}
+	if loc != nil {
+		incident.Latitude = loc.Latitude
+		incident.Longitude = loc.Longitude
+		incident.LocationPrecision = formatting.LocationPrecision(loc.Precision)
+		incident.LocationSource = loc.Source
+	}
+	return incident
 }
 
 func (s *server) toResponse(t transcription, baseURL string) transcriptionResponse {
-	meta, err := formatting.ParseCallMetadataFromFilename(t.Filename, s.tz)
+	meta, err := s.parseCallMetadata(t.Filename, "")
 	if err != nil {
 		meta = formatting.CallMetadata{RawFileName: t.Filename, DateTime: t.UpdatedAt.In(s.tz)}
 	}
@@ -3494,6 +4680,9 @@ func (s *server) toResponse(t transcription, baseURL string) transcriptionRespon
 	normalizedText := derefString(t.NormalizedTranscript, derefString(t.CleanTranscript, derefString(t.Transcript, "")))
 	audioFilename := s.audioFilename(t)
 	listenURL := s.publicURL(baseURL, audioFilename)
+	if t.Status == statusCAD || audioFilename == "" {
+		audioFilename, listenURL = "", ""
+	}
 	incident := s.buildIncidentDetails(meta, callType, tags, location, recognized, callTime, audioFilename, listenURL, normalizedText)
 	timestampLocal := incident.Timestamp.In(s.tz).Format(time.RFC3339)
 	cleanSummary := sanitizeSummary(derefString(t.CleanTranscript, ""))
@@ -3509,6 +4698,7 @@ func (s *server) toResponse(t transcription, baseURL string) transcriptionRespon
 		Translation:          t.Translation,
 		Status:               t.Status,
 		LastError:            t.LastError,
+		ErrorCode:            t.ErrorCode,
 		SizeBytes:            t.SizeBytes,
 		DurationSeconds:      t.DurationSeconds,
 		Hash:                 t.Hash,
@@ -3549,6 +4739,17 @@ func (s *server) toResponse(t transcription, baseURL string) transcriptionRespon
 		RefinedMetadata:      t.RefinedMetadata,
 		AddressJSON:          t.AddressJSON,
 		NeedsManualReview:    t.NeedsManualReview,
+		CADIncidentID:        t.CADIncidentID,
+		Units:                parseRecognizedTownList(t.Units),
+		PublicTranscript:     t.PublicTranscript,
+		DetectedLanguage:     t.DetectedLanguage,
+		TranscriptionEngine:  t.TranscriptionEngine,
+		DraftTranscript:      draftFor(t),
+		NearestStations:      s.nearestStationsFor(t),
+		PageFor:              t.PageFor,
+		DeletedAt:            t.DeletedAt,
+		Transcripts:          transcriptsByLanguage(t),
+		RepeatLocation:       s.repeatLocationFor(t.LocationLabel),
 	}
 }
 
@@ -3558,7 +4759,7 @@ func (s *server) locationFromRecord(t transcription, meta formatting.CallMetadat
 		source := derefString(t.LocationSource, "stored")
 		lat := *t.Latitude
 		lng := *t.Longitude
-		if !isWithinSussexCounty(lat, lng) {
+		if !s.region.Contains(lat, lng) {
 			return &locationGuess{Label: label, Precision: source, Source: source}
 		}
 		return &locationGuess{Label: label, Latitude: lat, Longitude: lng, Precision: source, Source: source}
@@ -3571,43 +4772,66 @@ func (s *server) locationFromRecord(t transcription, meta formatting.CallMetadat
 	return nil
 }
 
-func (s *server) parseAndGeocodeLocation(ctx context.Context, normalized string, meta formatting.CallMetadata) *locationGuess {
+// parseAndGeocodeLocation places a call from its transcript. Reviewer
+// corrections are consulted first, then mile markers and street aliases. The
+// error is set only when Mapbox was asked and failed; the guess still carries
+// the parsed label.
+func (s *server) parseAndGeocodeLocation(ctx context.Context, normalized string, meta formatting.CallMetadata) (*locationGuess, error) {
 	normalized = strings.TrimSpace(normalized)
 	if normalized == "" {
-		return nil
+		return nil, nil
 	}
-	parsed, err := formatting.ParseLocationFromTranscript(normalized)
-	if err != nil || parsed == nil {
-		if meta.TownDisplay == "" {
-			return nil
+	var parsed *formatting.ParsedLocation
+	source := "parsed"
+	if corr := s.matchGeocodeCorrection(ctx, normalized, meta); corr != nil {
+		guess, corrParsed := correctionLocation(corr, meta)
+		if guess != nil {
+			return guess, nil
+		}
+		parsed, source = corrParsed, "correction"
+	} else if guess := s.locateMileMarker(normalized, meta); guess != nil {
+		return guess, nil
+	} else if alias := s.matchStreetAlias(ctx, normalized, meta); alias != nil {
+		guess, aliasParsed := aliasLocation(alias, meta)
+		if guess != nil {
+			return guess, nil
+		}
+		parsed, source = aliasParsed, "alias"
+	} else {
+		var err error
+		parsed, err = formatting.ParseLocationFromTranscript(normalized)
+		if err != nil || parsed == nil {
+			if meta.TownDisplay == "" {
+				return nil, nil
+			}
+			parsed = &formatting.ParsedLocation{Municipality: meta.TownDisplay, RawText: meta.TownDisplay}
+		} else if parsed.Municipality == "" && meta.TownDisplay != "" {
+			parsed.Municipality = meta.TownDisplay
 		}
-		parsed = &formatting.ParsedLocation{Municipality: meta.TownDisplay, RawText: meta.TownDisplay}
-	} else if parsed.Municipality == "" && meta.TownDisplay != "" {
-		parsed.Municipality = meta.TownDisplay
 	}
 
 	label := formatting.FormatLocationLabel(parsed)
-	guess := &locationGuess{Label: label, Precision: "parsed", Source: "parsed"}
+	guess := &locationGuess{Label: label, Precision: source, Source: source}
 
 	token := strings.TrimSpace(s.cfg.MapboxToken)
 	if token == "" {
 		guess.Source = "unconfigured"
-		return guess
+		return guess, nil
 	}
 
-	lat, lng, precision, err := formatting.GeocodeParsedLocation(ctx, s.client, formatting.GeocoderConfig{Token: token, BBox: []float64{sussexMinLng, sussexMinLat, sussexMaxLng, sussexMaxLat}}, parsed)
+	lat, lng, precision, err := formatting.GeocodeParsedLocation(ctx, s.client, formatting.GeocoderConfig{Token: token, BBox: s.regionBBox()}, parsed)
 	if err != nil {
-		return guess
+		return guess, err
 	}
-	if !isWithinSussexCounty(lat, lng) {
-		guess.Source = "parsed_out_of_county"
-		return guess
+	if !s.region.Contains(lat, lng) {
+		guess.Source = source + "_out_of_county"
+		return guess, nil
 	}
 	guess.Latitude = lat
 	guess.Longitude = lng
 	guess.Precision = precision
-	guess.Source = "parsed_geocode"
-	return guess
+	guess.Source = source + "_geocode"
+	return guess, nil
 }
 
 func (s *server) deriveLocation(t transcription, meta formatting.CallMetadata) *locationGuess {
@@ -3731,12 +4955,12 @@ func (s *server) historicalHotspot(meta formatting.CallMetadata, recognized []st
 	args = append(args, historyCutoff)
 
 	query := fmt.Sprintf(`SELECT location_label, latitude, longitude, COUNT(*) AS freq,
-       MAX(COALESCE(call_timestamp, created_at)) AS last_seen
+       MAX(effective_ts) AS last_seen
 FROM transcriptions
 WHERE status = ? AND location_label IS NOT NULL AND TRIM(location_label) != ''
   AND latitude IS NOT NULL AND longitude IS NOT NULL
   AND %s
-  AND COALESCE(call_timestamp, created_at) >= ?
+  AND effective_ts >= ?
 GROUP BY location_label, latitude, longitude
 ORDER BY freq DESC, last_seen DESC
 LIMIT 1`, subquery)
@@ -3822,6 +5046,7 @@ func (s *server) geocodeWithMapbox(ctx context.Context, token, query string) *lo
 	if fallback := buildFallbackGeocodeQuery(query); fallback != "" && fallback != queries[0] {
 		queries = append(queries, fallback)
 	}
+	bbox := s.regionBBox()
 
 	for _, search := range queries {
 		search = strings.TrimSpace(search)
@@ -3831,7 +5056,7 @@ func (s *server) geocodeWithMapbox(ctx context.Context, token, query string) *lo
 		encoded := url.PathEscape(search)
 		endpoint := fmt.Sprintf(
 			"https://api.mapbox.com/geocoding/v5/mapbox.places/%s.json?access_token=%s&autocomplete=true&limit=1&country=US&language=en&bbox=%f,%f,%f,%f",
-			encoded, token, sussexMinLng, sussexMinLat, sussexMaxLng, sussexMaxLat,
+			encoded, token, bbox[0], bbox[1], bbox[2], bbox[3],
 		)
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 		if err != nil {
@@ -3870,8 +5095,8 @@ func (s *server) geocodeWithMapbox(ctx context.Context, token, query string) *lo
 		}
 		lat := feature.Center[1]
 		lng := feature.Center[0]
-		if !isWithinSussexCounty(lat, lng) {
-			log.Printf("mapbox result outside Sussex County ignored for %s: %s (%f,%f)", search, feature.PlaceName, lat, lng)
+		if !s.region.Contains(lat, lng) {
+			log.Printf("mapbox result outside the region boundary ignored for %s: %s (%f,%f)", search, feature.PlaceName, lat, lng)
 			continue
 		}
 
@@ -3892,22 +5117,18 @@ func (s *server) geocodeWithMapbox(ctx context.Context, token, query string) *lo
 	return nil
 }
 
-func (s *server) inferMetadataAddress(ctx context.Context, transcript string, meta formatting.CallMetadata, recognized []string) (*metadataInference, error) {
+func (s *server) inferMetadataAddress(ctx context.Context, transcript, prompt string, meta formatting.CallMetadata, recognized []string) (*metadataInference, error) {
 	apiKey := strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
 	if apiKey == "" {
 		return nil, errMetadataInferenceDisabled
 	}
 	transcript = strings.TrimSpace(transcript)
-	settings, err := s.loadSettings()
-	if err != nil {
-		return nil, err
-	}
-	prompt := strings.TrimSpace(settings.MetadataPrompt)
+	prompt = strings.TrimSpace(prompt)
 	if prompt == "" {
 		return nil, errMetadataInferenceDisabled
 	}
 	payload := map[string]interface{}{
-		"model":           "gpt-4.1-mini",
+		"model":           s.cfg.LLM.Refine.ModelOr("gpt-4.1-mini"),
 		"response_format": map[string]string{"type": "json_object"},
 		"messages": []map[string]string{
 			{"role": "system", "content": prompt},
@@ -3915,7 +5136,7 @@ func (s *server) inferMetadataAddress(ctx context.Context, transcript string, me
 		},
 	}
 	buf, _ := json.Marshal(payload)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/chat/completions", bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(usage.WithStage(ctx, usage.StageRefine), http.MethodPost, s.cfg.LLM.Refine.URL("/v1/chat/completions"), bytes.NewReader(buf))
 	if err != nil {
 		return nil, err
 	}
@@ -4047,7 +5268,9 @@ func (s *server) metadataLocationGuess(ctx context.Context, inference *metadataI
 		if loc.Label == "" {
 			loc.Label = label
 		}
-		if inference.Confidence > 0 {
+		// A town or county match stays township-level however sure the
+		// model was of the address.
+		if inference.Confidence > 0 && formatting.LocationPrecision(loc.Precision) != formatting.PrecisionTownship {
 			loc.Precision = precision
 		} else if loc.Precision == "" {
 			loc.Precision = guess.Precision
@@ -4064,10 +5287,10 @@ func (s *server) loadSettings() (AppSettings, error) {
 	var auto sql.NullInt64
 	var webhooks sql.NullString
 	var defaultModel, defaultMode, defaultFormat sql.NullString
-	var preferredLanguage, cleanupPrompt, metadataPrompt sql.NullString
+	var preferredLanguage, cleanupPrompt, metadataPrompt, email sql.NullString
 	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
-		return row.Scan(&defaultModel, &defaultMode, &defaultFormat, &auto, &webhooks, &preferredLanguage, &cleanupPrompt, &metadataPrompt)
-	}, `SELECT default_model, default_mode, default_format, auto_translate, webhook_endpoints, preferred_language, cleanup_prompt, metadata_prompt FROM app_settings WHERE id=1`); err != nil {
+		return row.Scan(&defaultModel, &defaultMode, &defaultFormat, &auto, &webhooks, &preferredLanguage, &cleanupPrompt, &metadataPrompt, &email)
+	}, `SELECT default_model, default_mode, default_format, auto_translate, webhook_endpoints, preferred_language, cleanup_prompt, metadata_prompt, email_settings FROM app_settings WHERE id=1`); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			if err := s.ensureSettingsRow(); err != nil {
 				return settings, err
@@ -4087,9 +5310,19 @@ func (s *server) loadSettings() (AppSettings, error) {
 	if strings.TrimSpace(hooksJSON) == "" {
 		hooksJSON = "[]"
 	}
-	_ = json.Unmarshal([]byte(hooksJSON), &settings.WebhookEndpoints)
+	if err := json.Unmarshal([]byte(hooksJSON), &settings.WebhookEndpoints); err != nil {
+		log.Printf("webhook endpoints decode failed: %v", err)
+	}
 	if settings.WebhookEndpoints == nil {
-		settings.WebhookEndpoints = []string{}
+		settings.WebhookEndpoints = []WebhookEndpoint{}
+	}
+	if raw := strings.TrimSpace(email.String); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &settings.Email); err != nil {
+			log.Printf("email settings decode failed: %v", err)
+		}
+	}
+	if settings.Email.Recipients == nil {
+		settings.Email.Recipients = []EmailRecipient{}
 	}
 	if strings.TrimSpace(settings.CleanupPrompt) == "" {
 		settings.CleanupPrompt = defaultCleanupPrompt
@@ -4146,17 +5379,18 @@ func (s *server) saveSettings(settings AppSettings) error {
 		settings.MetadataPrompt = defaultMetadataPrompt
 	}
 	hooks, _ := json.Marshal(settings.WebhookEndpoints)
+	email, _ := json.Marshal(settings.Email)
 	auto := 0
 	if settings.AutoTranslate {
 		auto = 1
 	}
-	res, err := execWithRetry(s.db, `UPDATE app_settings SET default_model=?, default_mode=?, default_format=?, auto_translate=?, webhook_endpoints=?, preferred_language=?, cleanup_prompt=?, metadata_prompt=?, updated_at=CURRENT_TIMESTAMP WHERE id=1`, settings.DefaultModel, settings.DefaultMode, settings.DefaultFormat, auto, string(hooks), settings.PreferredLanguage, settings.CleanupPrompt, settings.MetadataPrompt)
+	res, err := execWithRetry(s.db, `UPDATE app_settings SET default_model=?, default_mode=?, default_format=?, auto_translate=?, webhook_endpoints=?, preferred_language=?, cleanup_prompt=?, metadata_prompt=?, email_settings=?, updated_at=CURRENT_TIMESTAMP WHERE id=1`, settings.DefaultModel, settings.DefaultMode, settings.DefaultFormat, auto, string(hooks), settings.PreferredLanguage, settings.CleanupPrompt, settings.MetadataPrompt, string(email))
 	if err != nil {
 		return err
 	}
 	rows, err := res.RowsAffected()
 	if err == nil && rows == 0 {
-		_, err = execWithRetry(s.db, `INSERT OR REPLACE INTO app_settings(id, default_model, default_mode, default_format, auto_translate, webhook_endpoints, preferred_language, cleanup_prompt, metadata_prompt, updated_at) VALUES(1, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`, settings.DefaultModel, settings.DefaultMode, settings.DefaultFormat, auto, string(hooks), settings.PreferredLanguage, settings.CleanupPrompt, settings.MetadataPrompt)
+		_, err = execWithRetry(s.db, `INSERT OR REPLACE INTO app_settings(id, default_model, default_mode, default_format, auto_translate, webhook_endpoints, preferred_language, cleanup_prompt, metadata_prompt, email_settings, updated_at) VALUES(1, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`, settings.DefaultModel, settings.DefaultMode, settings.DefaultFormat, auto, string(hooks), settings.PreferredLanguage, settings.CleanupPrompt, settings.MetadataPrompt, string(email))
 	}
 	return err
 }
@@ -4173,13 +5407,7 @@ func (s *server) ensureSettingsRow() error {
 }
 
 func (s *server) getTranscription(filename string) (*transcription, error) {
-	var t transcription
-	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
-		return scanTranscription(row, &t)
-	}, `SELECT id, filename, source_path, processed_path, COALESCE(ingest_source,'') as ingest_source, transcript_text, raw_transcript_text, clean_transcript_text, translation_text, status, last_error, size_bytes, duration_seconds, hash, duplicate_of, requested_model, requested_mode, requested_format, actual_openai_model_used, diarized_json, recognized_towns, normalized_transcript, call_type, call_timestamp, tags, latitude, longitude, location_label, location_source, refined_metadata, address_json, needs_manual_review, created_at, updated_at FROM transcriptions WHERE filename = ?`, filename); err != nil {
-		return nil, err
-	}
-	return &t, nil
+	return s.store.Get(s.ctx, filename)
 }
 
 func (s *server) markQueued(filename, sourcePath, source string, size int64, opts TranscriptionOptions, callTime time.Time) error {
@@ -4192,9 +5420,11 @@ func (s *server) markQueued(filename, sourcePath, source string, size int64, opt
 		callTimestamp = time.Now().In(s.tz)
 	}
 	callTimestamp = callTimestamp.UTC()
-	_, err := execWithRetry(s.db, `INSERT INTO transcriptions (filename, source_path, processed_path, ingest_source, status, size_bytes, requested_model, requested_mode, requested_format, call_timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-ON CONFLICT(filename) DO UPDATE SET status=excluded.status, source_path=excluded.source_path, processed_path=COALESCE(excluded.processed_path, transcriptions.processed_path), ingest_source=COALESCE(excluded.ingest_source, transcriptions.ingest_source), size_bytes=COALESCE(excluded.size_bytes, transcriptions.size_bytes), requested_model=COALESCE(excluded.requested_model, transcriptions.requested_model), requested_mode=COALESCE(excluded.requested_mode, transcriptions.requested_mode), requested_format=COALESCE(excluded.requested_format, transcriptions.requested_format), call_timestamp=COALESCE(transcriptions.call_timestamp, excluded.call_timestamp)`, filename, sourcePath, sourcePath, source, statusQueued, sizeVal, opts.Model, opts.Mode, opts.Format, callTimestamp)
-	return err
+	talkgroup, frequency := s.callTalkgroup(filename)
+	return s.store.transition(s.ctx, filename, statusQueued, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, sqlMarkQueued, filename, sourcePath, sourcePath, source, statusQueued, sizeVal, opts.Model, opts.Mode, opts.Format, callTimestamp, talkgroup, frequency)
+		return err
+	})
 }
 
 func (s *server) markProcessing(filename, sourcePath, source string, size int64, opts TranscriptionOptions, callTime time.Time) error {
@@ -4203,31 +5433,150 @@ func (s *server) markProcessing(filename, sourcePath, source string, size int64,
 		callTimestamp = time.Now().In(s.tz)
 	}
 	callTimestamp = callTimestamp.UTC()
-	_, err := execWithRetry(s.db, `INSERT INTO transcriptions (filename, source_path, processed_path, ingest_source, status, size_bytes, requested_model, requested_mode, requested_format, call_timestamp) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-ON CONFLICT(filename) DO UPDATE SET status=excluded.status, source_path=excluded.source_path, processed_path=COALESCE(excluded.processed_path, transcriptions.processed_path), ingest_source=COALESCE(excluded.ingest_source, transcriptions.ingest_source), size_bytes=excluded.size_bytes, requested_model=excluded.requested_model, requested_mode=excluded.requested_mode, requested_format=excluded.requested_format, call_timestamp=COALESCE(transcriptions.call_timestamp, excluded.call_timestamp)`, filename, sourcePath, sourcePath, source, statusProcessing, size, opts.Model, opts.Mode, opts.Format, callTimestamp)
-	return err
+	talkgroup, frequency := s.callTalkgroup(filename)
+	return s.store.transition(s.ctx, filename, statusProcessing, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, sqlMarkProcessing, filename, sourcePath, sourcePath, source, statusProcessing, size, opts.Model, opts.Mode, opts.Format, callTimestamp, talkgroup, frequency)
+		return err
+	})
 }
 
 func (s *server) updateProcessedPath(filename, processedPath string) error {
 	if strings.TrimSpace(processedPath) == "" {
 		return nil
 	}
-	_, err := execWithRetry(s.db, `UPDATE transcriptions SET processed_path=? WHERE filename=?`, processedPath, filename)
+	_, err := s.store.exec(s.ctx, sqlUpdateProcessedPath, processedPath, filename)
+	return err
+}
+
+// callCompletion is everything stored when a call finishes transcription.
+type callCompletion struct {
+	note             string
+	raw              *string
+	clean            *string
+	translation      *string
+	duplicateOf      *string
+	diarized         *string
+	towns            *string
+	normalized       *string
+	actualModel      *string
+	callType         *string
+	tags             *string
+	lat              *float64
+	lon              *float64
+	label            *string
+	source           *string
+	metadataJSON     *string
+	addressJSON      *string
+	manualReview     bool
+	publicTranscript string
+	detectedLanguage string
+	speakerRoles     *string
+	embedding        []float64
+	// engine is the transcription engine; empty leaves the stored value
+	// alone, as when a stage reprocess reuses the stored transcript.
+	engine string
+	// processingSeconds is the wall time of the pipeline run; zero leaves
+	// the stored value alone.
+	processingSeconds float64
+	// transcribeSeconds is the time spent in transcription, and
+	// transcribeCost its estimated price; both are stored only with a
+	// nonzero transcribeSeconds.
+	transcribeSeconds float64
+	transcribeCost    float64
+}
+
+// completeCall marks a call done and stores its results in one transaction,
+// so a crash cannot leave a call done without its public transcript,
+// embedding or entity index, or with a new transcript under the old status.
+func (s *server) completeCall(filename string, c callCompletion) error {
+	var embedding *string
+	if len(c.embedding) > 0 {
+		data, err := json.Marshal(c.embedding)
+		if err != nil {
+			return err
+		}
+		str := string(data)
+		embedding = &str
+	}
+	err := s.store.transition(s.ctx, filename, statusDone, func(ctx context.Context, tx *sql.Tx) error {
+		if err := markDoneTx(ctx, tx, filename, c); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, sqlUpdatePublicTranscript, nullableString(c.publicTranscript), filename); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, sqlUpdateDetectedLanguage, nullableString(c.detectedLanguage), filename); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, sqlUpdateSpeakerRoles, c.speakerRoles, filename); err != nil {
+			return err
+		}
+		if embedding != nil {
+			if _, err := tx.ExecContext(ctx, sqlStoreEmbedding, *embedding, filename); err != nil {
+				return err
+			}
+		}
+		if c.engine != "" {
+			if _, err := tx.ExecContext(ctx, sqlUpdateTranscriptionEngine, c.engine, filename); err != nil {
+				return err
+			}
+		}
+		if c.processingSeconds > 0 {
+			if _, err := tx.ExecContext(ctx, sqlUpdateProcessingSeconds, c.processingSeconds, filename); err != nil {
+				return err
+			}
+		}
+		if c.transcribeSeconds > 0 {
+			if _, err := tx.ExecContext(ctx, sqlUpdateTranscriptionCost, c.transcribeSeconds, c.transcribeCost, filename); err != nil {
+				return err
+			}
+		}
+		return storeEntitiesTx(ctx, tx, filename, derefString(c.clean, ""))
+	})
+	if err == nil {
+		s.queries.invalidate()
+	}
 	return err
 }
 
-func (s *server) markDoneWithDetails(filename string, note string, raw *string, clean *string, translation *string, duplicateOf *string, diarized *string, towns *string, normalized *string, actualModel *string, callType *string, tags *string, lat *float64, lon *float64, label *string, source *string, metadataJSON *string, addressJSON *string, manualReview bool) error {
-	_, err := execWithRetry(s.db, `UPDATE transcriptions SET status=?, transcript_text=?, raw_transcript_text=?, clean_transcript_text=?, translation_text=?, last_error=?, duplicate_of=?, diarized_json=?, recognized_towns=?, normalized_transcript=?, actual_openai_model_used=?, call_type=?, tags=COALESCE(?, tags), latitude=?, longitude=?, location_label=COALESCE(?, location_label), location_source=COALESCE(?, location_source), refined_metadata=COALESCE(?, refined_metadata), address_json=COALESCE(?, address_json), needs_manual_review=? WHERE filename=?`, statusDone, clean, raw, clean, translation, nullableString(note), duplicateOf, diarized, towns, normalized, actualModel, callType, tags, lat, lon, label, source, metadataJSON, addressJSON, boolToInt(manualReview), filename)
+func markDoneTx(ctx context.Context, tx *sql.Tx, filename string, c callCompletion) error {
+	_, err := tx.ExecContext(ctx, sqlMarkDone, statusDone, c.clean, c.raw, c.clean, c.translation, nullableString(c.note), c.duplicateOf, c.diarized, c.towns, c.normalized, c.actualModel, c.callType, c.tags, c.lat, c.lon, c.label, c.source, c.metadataJSON, c.addressJSON, boolToInt(c.manualReview), filename)
 	return err
 }
 
+// markError fails a call, storing the error's code next to its message and
+// logging it to call_errors.
 func (s *server) markError(filename string, cause error) {
 	msg := cause.Error()
-	if _, err := execWithRetry(s.db, `UPDATE transcriptions SET status=?, last_error=? WHERE filename=?`, statusError, msg, filename); err != nil {
+	code := errorCodeFor(cause)
+	if err := s.store.transition(s.ctx, filename, statusError, func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, sqlMarkError, statusError, msg, code, filename); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, sqlRecordCallError, filename, code, msg, time.Now().UTC(), filename)
+		return err
+	}); err != nil {
 		log.Printf("failed to mark error: %v", err)
 	}
 }
 
+// recoverInterruptedCalls fails calls a previous process left processing; the
+// transaction that would have completed them never committed. They can be
+// retried like any other failed call.
+func (s *server) recoverInterruptedCalls() {
+	if _, err := execWithRetry(s.db, `INSERT INTO call_errors (filename, code, message, created_at) SELECT filename, ?, 'interrupted by restart', ? FROM transcriptions WHERE status = ?`, errcode.Interrupted, time.Now().UTC(), statusProcessing); err != nil {
+		log.Printf("interrupted call error log failed: %v", err)
+	}
+	res, err := execWithRetry(s.db, `UPDATE transcriptions SET status = ?, last_error = 'interrupted by restart', error_code = ? WHERE status = ?`, statusError, errcode.Interrupted, statusProcessing)
+	if err != nil {
+		log.Printf("interrupted call cleanup failed: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("marked %d interrupted calls as failed", n)
+	}
+}
+
 func nullableString(s string) *string {
 	if s == "" {
 		return nil
@@ -4268,6 +5617,7 @@ func scanTranscription(row rowScanner, t *transcription) error {
 		&t.Translation,
 		&t.Status,
 		&t.LastError,
+		&t.ErrorCode,
 		&t.SizeBytes,
 		&t.DurationSeconds,
 		&t.Hash,
@@ -4289,6 +5639,16 @@ func scanTranscription(row rowScanner, t *transcription) error {
 		&t.RefinedMetadata,
 		&t.AddressJSON,
 		&manual,
+		&t.CADIncidentID,
+		&t.Units,
+		&t.PublicTranscript,
+		&t.DetectedLanguage,
+		&t.SpeakerRoles,
+		&t.TranscriptionEngine,
+		&t.DraftTranscript,
+		&t.StationsJSON,
+		&t.PageFor,
+		&t.DeletedAt,
 		&t.CreatedAt,
 		&t.UpdatedAt,
 	)
@@ -4371,12 +5731,35 @@ func (s *server) findDuplicate(hash string, filename string) string {
 	return ""
 }
 
-func (s *server) copyFromDuplicate(filename, duplicate string) error {
-	src, err := s.getTranscription(duplicate)
+// linkDuplicate completes j as a copy of dup without transcribing it again.
+func (s *server) linkDuplicate(j processJob, dup, note string) {
+	src, err := s.getTranscription(dup)
 	if err != nil {
-		return err
+		log.Printf("failed to mirror duplicate data: %v", err)
+	}
+	if err := s.store.transition(s.ctx, j.filename, statusDone, func(ctx context.Context, tx *sql.Tx) error {
+		if src != nil {
+			if err := copyFromDuplicate(ctx, tx, j.filename, src); err != nil {
+				return err
+			}
+		}
+		if err := storeEntitiesTx(ctx, tx, j.filename, ""); err != nil {
+			return err
+		}
+		return markDoneTx(ctx, tx, j.filename, callCompletion{note: note, duplicateOf: &dup})
+	}); err != nil {
+		log.Printf("failed to link duplicate %s: %v", j.filename, err)
+	} else {
+		s.queries.invalidate()
 	}
-	_, err = execWithRetry(s.db, `UPDATE transcriptions SET transcript_text=?, raw_transcript_text=?, clean_transcript_text=?, translation_text=?, status=?, last_error=NULL, diarized_json=?, recognized_towns=?, normalized_transcript=?, actual_openai_model_used=?, call_type=?, tags=COALESCE(transcriptions.tags, ?), call_timestamp=COALESCE(transcriptions.call_timestamp, ?), latitude=COALESCE(?, latitude), longitude=COALESCE(?, longitude), location_label=COALESCE(?, location_label), location_source=COALESCE(?, location_source), refined_metadata=COALESCE(?, refined_metadata), address_json=COALESCE(?, address_json), needs_manual_review=? WHERE filename=?`, src.Transcript, src.RawTranscript, src.CleanTranscript, src.Translation, statusDone, src.DiarizedJSON, src.RecognizedTowns, src.NormalizedTranscript, src.ActualModel, src.CallType, src.TagsJSON, src.CallTimestamp, src.Latitude, src.Longitude, src.LocationLabel, src.LocationSource, src.RefinedMetadata, src.AddressJSON, boolToInt(src.NeedsManualReview), filename)
+	if j.sendGroupMe {
+		followup := fmt.Sprintf("%s transcript is duplicate of %s", j.filename, dup)
+		_ = s.sendGroupMe(followup)
+	}
+}
+
+func copyFromDuplicate(ctx context.Context, tx *sql.Tx, filename string, src *transcription) error {
+	_, err := tx.ExecContext(ctx, `UPDATE transcriptions SET transcript_text=?, raw_transcript_text=?, clean_transcript_text=?, translation_text=?, status=?, last_error=NULL, error_code=NULL, diarized_json=?, recognized_towns=?, normalized_transcript=?, actual_openai_model_used=?, call_type=?, tags=COALESCE(transcriptions.tags, ?), call_timestamp=COALESCE(transcriptions.call_timestamp, ?), latitude=COALESCE(?, latitude), longitude=COALESCE(?, longitude), location_label=COALESCE(?, location_label), location_source=COALESCE(?, location_source), refined_metadata=COALESCE(?, refined_metadata), address_json=COALESCE(?, address_json), needs_manual_review=?, public_transcript=?, detected_language=?, speaker_roles=? WHERE filename=?`, src.Transcript, src.RawTranscript, src.CleanTranscript, src.Translation, statusDone, src.DiarizedJSON, src.RecognizedTowns, src.NormalizedTranscript, src.ActualModel, src.CallType, src.TagsJSON, src.CallTimestamp, src.Latitude, src.Longitude, src.LocationLabel, src.LocationSource, src.RefinedMetadata, src.AddressJSON, boolToInt(src.NeedsManualReview), src.PublicTranscript, src.DetectedLanguage, src.SpeakerRoles, filename)
 	return err
 }
 
@@ -4395,15 +5778,6 @@ func probeDuration(path string) float64 {
 	return v
 }
 
-func (s *server) storeEmbedding(filename string, embedding []float64) error {
-	data, err := json.Marshal(embedding)
-	if err != nil {
-		return err
-	}
-	_, err = execWithRetry(s.db, `UPDATE transcriptions SET embedding=? WHERE filename=?`, string(data), filename)
-	return err
-}
-
 // fireWebhooks sends a standardized, human-readable payload for watcher-triggered completions.
 // Schema (stable):
 //
@@ -4423,10 +5797,24 @@ func (s *server) fireWebhooks(j processJob) error {
 	if len(settings.WebhookEndpoints) == 0 {
 		return nil
 	}
-	t, err := s.getTranscription(j.filename)
+	payload, event, err := s.buildWebhookPayload(j)
 	if err != nil {
 		return err
 	}
+	s.storeWebhookPayload(j.filename, payload)
+	s.postWebhooks(settings.WebhookEndpoints, event, payload)
+	return nil
+}
+
+// buildWebhookPayload renders the webhook body for a call from its current
+// row, along with the attributes endpoint filters match on.
+func (s *server) buildWebhookPayload(j processJob) (map[string]interface{}, webhookEvent, error) {
+	record, err := s.getTranscription(j.filename)
+	if err != nil {
+		return nil, webhookEvent{}, err
+	}
+	public := s.publicRecord(*record)
+	t := &public
 
 	recognized := parseRecognizedTowns(t.RecognizedTowns)
 	normalized := pointerString(t.NormalizedTranscript)
@@ -4467,7 +5855,7 @@ func (s *server) fireWebhooks(j processJob) error {
 	}
 
 	audioFilename := s.audioFilename(*t)
-	listenURL := formatting.BuildListenURL(audioFilename)
+	listenURL := listenURLFor(audioFilename)
 	incidentSummary := derefString(normalized, "")
 	incident := s.buildIncidentDetails(j.meta, callTypeVal, tags, location, recognized, callTime, audioFilename, listenURL, incidentSummary)
 
@@ -4475,7 +5863,7 @@ func (s *server) fireWebhooks(j processJob) error {
 		"timestamp_utc":  alertTime.UTC().Format(time.RFC3339),
 		"local_datetime": alertTime.In(s.tz).Format("2006-01-02 15:04:05"),
 		"filename":       t.Filename,
-		"url":            listenURL,
+		"url":            nullableString(listenURL),
 		"preview_image":  s.previewURL(j.baseURL, t.Filename),
 		"pretty_title":   j.prettyTitle,
 		"alert_message":  formatting.BuildIncidentAlert(incident),
@@ -4511,20 +5899,13 @@ func (s *server) fireWebhooks(j processJob) error {
 			"call_category":    nullableString(incident.CallCategory),
 		},
 	}
-	buf, _ := json.Marshal(payload)
-	for _, endpoint := range settings.WebhookEndpoints {
-		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(buf))
-		if err != nil {
-			continue
-		}
-		req.Header.Set("Content-Type", "application/json")
-		resp, err := s.client.Do(req)
-		if err != nil {
-			continue
-		}
-		resp.Body.Close()
+	event := webhookEvent{
+		Towns:     append([]string{incident.CityOrTown}, recognized...),
+		Category:  incident.CallCategory,
+		Priority:  rollups.CallPriority(derefString(callTypeVal, j.meta.CallType), incidentSummary),
+		AudioPath: fallbackEmpty(t.ProcessedPath, t.SourcePath),
 	}
-	return nil
+	return payload, event, nil
 }
 
 func (s *server) loadEmbedding(filename string) ([]float64, error) {
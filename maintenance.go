@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// startDBMaintenance checkpoints the WAL on an interval and runs VACUUM once a
+// day during the configured quiet hour when the queue is idle.
+func (s *server) startDBMaintenance(ctx context.Context) {
+	interval := time.Duration(s.cfg.DBCheckpointIntervalSec) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var lastVacuum time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+			if err := s.checkpointWAL(ctx); err != nil {
+				log.Printf("wal checkpoint failed: %v", err)
+			}
+			now := time.Now().In(s.tz)
+			if !s.vacuumDue(now, lastVacuum) {
+				continue
+			}
+			start := time.Now()
+			if _, err := s.db.ExecContext(ctx, `VACUUM`); err != nil {
+				log.Printf("vacuum failed: %v", err)
+				continue
+			}
+			lastVacuum = now
+			log.Printf("vacuum completed in %s", time.Since(start).Round(time.Millisecond))
+		}
+	}()
+}
+
+func (s *server) checkpointWAL(ctx context.Context) error {
+	var busy, logFrames, checkpointed int
+	if err := s.db.QueryRowContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logFrames, &checkpointed); err != nil {
+		return err
+	}
+	if busy != 0 {
+		log.Printf("wal checkpoint incomplete (busy): %d/%d frames", checkpointed, logFrames)
+	}
+	return nil
+}
+
+// vacuumDue reports whether now falls in the quiet hour, no vacuum has run
+// today, and no jobs are waiting or running.
+func (s *server) vacuumDue(now, lastVacuum time.Time) bool {
+	if s.cfg.DBVacuumHour < 0 || now.Hour() != s.cfg.DBVacuumHour {
+		return false
+	}
+	if !lastVacuum.IsZero() && lastVacuum.YearDay() == now.YearDay() && lastVacuum.Year() == now.Year() {
+		return false
+	}
+	if s.queue != nil {
+		stats := s.queue.Stats()
+		if stats.Length > 0 || stats.Active > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// handleAdminBackup streams a consistent snapshot of the database produced with
+// VACUUM INTO, so backups do not require stopping the service.
+func (s *server) handleAdminBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	tmpDir, err := os.MkdirTemp(s.cfg.WorkDir, "backup-")
+	if err != nil {
+		log.Printf("backup temp dir failed: %v", err)
+		http.Error(w, "backup error", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshot := filepath.Join(tmpDir, "snapshot.db")
+	start := time.Now()
+	if err := s.snapshotDB(r.Context(), snapshot); err != nil {
+		log.Printf("backup snapshot failed: %v", err)
+		http.Error(w, "backup error", http.StatusInternalServerError)
+		return
+	}
+	f, err := os.Open(snapshot)
+	if err != nil {
+		http.Error(w, "backup error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "backup error", http.StatusInternalServerError)
+		return
+	}
+
+	name := fmt.Sprintf("transcriptions-%s.db", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
+	n, err := io.Copy(w, f)
+	if err != nil {
+		log.Printf("backup stream interrupted after %d bytes: %v", n, err)
+		return
+	}
+	log.Printf("backup streamed %d bytes in %s", n, time.Since(start).Round(time.Millisecond))
+}
+
+func (s *server) snapshotDB(ctx context.Context, dest string) error {
+	if err := s.checkpointWAL(ctx); err != nil {
+		log.Printf("pre-backup checkpoint failed: %v", err)
+	}
+	_, err := s.db.ExecContext(ctx, `VACUUM INTO ?`, dest)
+	if err != nil {
+		return err
+	}
+	return verifySnapshot(ctx, dest)
+}
+
+func verifySnapshot(ctx context.Context, path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRowContext(ctx, `PRAGMA quick_check`).Scan(&result); err != nil {
+		return err
+	}
+	if !strings.EqualFold(result, "ok") {
+		return fmt.Errorf("snapshot integrity check: %s", result)
+	}
+	return nil
+}
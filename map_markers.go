@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"alert_framework/formatting"
+)
+
+const (
+	metersPerDegreeLat = 111320.0
+	// markerGoldenAngle spaces stacked pins around a sunflower spiral, so
+	// however many share a point none land on top of each other.
+	markerGoldenAngle = 2.399963229728653
+)
+
+// markerColors are the pin colors by call category.
+var markerColors = map[string]string{
+	"ems":   "#7ce7ff",
+	"fire":  "#ff6b6b",
+	"other": "#c3a6ff",
+}
+
+// markerSpread is the spacing, in metres, between pins stacked on one point.
+// Town centroids spread widely so the pile reads as "somewhere in town"
+// rather than one exact spot; exact addresses only nudge apart enough to
+// click each pin.
+var markerSpread = map[string]float64{
+	formatting.PrecisionAddress:      12,
+	formatting.PrecisionIntersection: 12,
+	formatting.PrecisionStreet:       40,
+	formatting.PrecisionArea:         120,
+	formatting.PrecisionTownship:     300,
+	"":                               40,
+}
+
+// mapMarker tells the map how to draw a call's pin. Latitude and Longitude
+// are where to draw it: the call's location moved by OffsetLat/OffsetLng
+// when other calls in the same list share its point. Stacked counts the
+// calls on that point, itself included.
+type mapMarker struct {
+	Precision string  `json:"precision,omitempty"`
+	Color     string  `json:"color"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	OffsetLat float64 `json:"offset_lat,omitempty"`
+	OffsetLng float64 `json:"offset_lng,omitempty"`
+	Stacked   int     `json:"stacked,omitempty"`
+}
+
+// assignMapMarkers sets Marker on every call with coordinates. Calls on the
+// same point (to about a metre) are spread around it, oldest nearest the
+// center, so a new call takes the outermost spot. Offsets depend on the
+// list passed in, though: when the oldest call of a stack drops out of the
+// list the rest move inward, and a call with a coarser precision widens the
+// whole stack.
+func assignMapMarkers(calls []transcriptionResponse) {
+	stacks := map[string][]int{}
+	for i := range calls {
+		loc := calls[i].Location
+		if loc == nil || (loc.Latitude == 0 && loc.Longitude == 0) {
+			continue
+		}
+		calls[i].Marker = &mapMarker{
+			Precision: markerPrecision(loc),
+			Color:     markerColor(calls[i].CallCategory),
+			Latitude:  loc.Latitude,
+			Longitude: loc.Longitude,
+		}
+		key := fmt.Sprintf("%.5f,%.5f", loc.Latitude, loc.Longitude)
+		stacks[key] = append(stacks[key], i)
+	}
+	for _, stack := range stacks {
+		if len(stack) < 2 {
+			continue
+		}
+		sort.SliceStable(stack, func(a, b int) bool {
+			ca, cb := calls[stack[a]], calls[stack[b]]
+			if !ca.CallTimestamp.Equal(cb.CallTimestamp) {
+				return ca.CallTimestamp.Before(cb.CallTimestamp)
+			}
+			return ca.Filename < cb.Filename
+		})
+		// The coarsest precision in the stack sets the spacing, since a
+		// town centroid shared with an address is still a town centroid.
+		spread := 0.0
+		for _, i := range stack {
+			spread = math.Max(spread, markerSpread[calls[i].Marker.Precision])
+		}
+		for n, i := range stack {
+			m := calls[i].Marker
+			m.Stacked = len(stack)
+			m.OffsetLat, m.OffsetLng = markerOffset(n, spread, m.Latitude)
+			m.Latitude += m.OffsetLat
+			m.Longitude += m.OffsetLng
+		}
+	}
+}
+
+// markerOffset returns the offset in degrees of the nth pin in a stack at
+// latitude lat.
+func markerOffset(n int, spread, lat float64) (float64, float64) {
+	r := spread * math.Sqrt(float64(n)+0.5)
+	angle := float64(n) * markerGoldenAngle
+	north, east := r*math.Cos(angle), r*math.Sin(angle)
+	dLat := north / metersPerDegreeLat
+	dLng := east / (metersPerDegreeLat * math.Cos(lat*math.Pi/180))
+	return roundCoord(dLat), roundCoord(dLng)
+}
+
+func roundCoord(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// markerPrecision returns the precision tier of a stored location. Stored
+// calls keep where the location came from rather than how precise it is,
+// so a geocoded one is judged by what its label names.
+func markerPrecision(loc *locationGuess) string {
+	if tier := formatting.LocationPrecision(loc.Precision); tier != "" {
+		return tier
+	}
+	source := strings.ToLower(loc.Source)
+	if strings.HasPrefix(source, "historical_hotspot") || strings.HasPrefix(source, "metadata_prompt") {
+		return formatting.PrecisionArea
+	}
+	parsed, err := formatting.ParseLocationFromTranscript(loc.Label)
+	switch {
+	case err != nil || parsed == nil:
+		return ""
+	case parsed.HouseNumber != "" && parsed.Street != "":
+		return formatting.PrecisionAddress
+	case parsed.Street != "" && parsed.CrossStreet != "":
+		return formatting.PrecisionIntersection
+	case parsed.Street != "":
+		return formatting.PrecisionStreet
+	case parsed.Municipality != "":
+		return formatting.PrecisionTownship
+	}
+	return ""
+}
+
+func markerColor(category string) string {
+	if color, ok := markerColors[formatting.NormalizeCallCategory(category)]; ok {
+		return color
+	}
+	return markerColors["other"]
+}
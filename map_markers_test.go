@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func markerCall(filename string, at time.Time, precision string, lat, lng float64) transcriptionResponse {
+	return transcriptionResponse{
+		Filename:      filename,
+		CallTimestamp: at,
+		Location:      &locationGuess{Latitude: lat, Longitude: lng, Precision: precision},
+	}
+}
+
+func markersByFilename(calls []transcriptionResponse) map[string]*mapMarker {
+	out := map[string]*mapMarker{}
+	for _, c := range calls {
+		out[c.Filename] = c.Marker
+	}
+	return out
+}
+
+func TestMarkerOffset(t *testing.T) {
+	// The first pin sits due north at spread*sqrt(0.5).
+	dLat, dLng := markerOffset(0, 100, 41)
+	if want := roundCoord(100 * math.Sqrt(0.5) / metersPerDegreeLat); dLat != want || dLng != 0 {
+		t.Fatalf("offset 0 = %v, %v; want %v, 0", dLat, dLng, want)
+	}
+	// Longitude degrees shrink away from the equator, so the same east
+	// distance needs a larger offset further north.
+	_, low := markerOffset(1, 100, 0)
+	_, high := markerOffset(1, 100, 60)
+	if math.Abs(high/low-2) > 0.01 {
+		t.Fatalf("east offset at 60°N = %v, at the equator %v; want twice as large", high, low)
+	}
+	seen := map[[2]float64]bool{}
+	for n := 0; n < 50; n++ {
+		lat, lng := markerOffset(n, 12, 41)
+		if lat != roundCoord(lat) || lng != roundCoord(lng) {
+			t.Fatalf("offset %d = %v, %v; not rounded to 1e-6", n, lat, lng)
+		}
+		if seen[[2]float64{lat, lng}] {
+			t.Fatalf("offset %d lands on an earlier pin", n)
+		}
+		seen[[2]float64{lat, lng}] = true
+	}
+}
+
+func TestAssignMapMarkersOrdersStackOldestFirst(t *testing.T) {
+	at := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	calls := []transcriptionResponse{
+		markerCall("newest.mp3", at.Add(2*time.Minute), "address", 41.05, -74.75),
+		markerCall("b.mp3", at, "address", 41.05, -74.75),
+		markerCall("a.mp3", at, "address", 41.05, -74.75),
+		markerCall("alone.mp3", at, "address", 41.2, -74.6),
+		{Filename: "nowhere.mp3", CallTimestamp: at},
+	}
+	assignMapMarkers(calls)
+	markers := markersByFilename(calls)
+
+	if markers["nowhere.mp3"] != nil {
+		t.Fatal("call without a location got a marker")
+	}
+	if m := markers["alone.mp3"]; m.Stacked != 0 || m.OffsetLat != 0 || m.OffsetLng != 0 || m.Latitude != 41.2 {
+		t.Fatalf("lone pin moved: %+v", m)
+	}
+	// Same timestamp falls back to filename order.
+	for n, name := range []string{"a.mp3", "b.mp3", "newest.mp3"} {
+		m := markers[name]
+		if m.Stacked != 3 {
+			t.Errorf("%s stacked = %d, want 3", name, m.Stacked)
+		}
+		lat, lng := markerOffset(n, markerSpread["address"], 41.05)
+		if m.OffsetLat != lat || m.OffsetLng != lng {
+			t.Errorf("%s offset = %v, %v; want pin %d at %v, %v", name, m.OffsetLat, m.OffsetLng, n, lat, lng)
+		}
+	}
+	if m := markers["b.mp3"]; m.Latitude != 41.05+m.OffsetLat || m.Longitude != -74.75+m.OffsetLng {
+		t.Errorf("b.mp3 drawn at %v, %v; want its location plus its offset", m.Latitude, m.Longitude)
+	}
+	if loc := calls[1].Location; loc.Latitude != 41.05 || loc.Longitude != -74.75 {
+		t.Errorf("location changed to %v, %v", loc.Latitude, loc.Longitude)
+	}
+}
+
+func TestAssignMapMarkersSpreadsByCoarsestPrecision(t *testing.T) {
+	at := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	calls := []transcriptionResponse{
+		markerCall("address.mp3", at, "address", 41.05, -74.75),
+		markerCall("town.mp3", at.Add(time.Minute), "municipality", 41.05, -74.75),
+	}
+	assignMapMarkers(calls)
+	markers := markersByFilename(calls)
+	if m := markers["address.mp3"]; m.Precision != "address" {
+		t.Fatalf("precision = %q", m.Precision)
+	}
+	// The address pin is spread like the town centroid it shares a point with.
+	for n, name := range []string{"address.mp3", "town.mp3"} {
+		lat, lng := markerOffset(n, markerSpread["township"], 41.05)
+		if m := markers[name]; m.OffsetLat != lat || m.OffsetLng != lng {
+			t.Errorf("%s offset = %v, %v; want %v, %v", name, m.OffsetLat, m.OffsetLng, lat, lng)
+		}
+	}
+
+	// Dropping the town call from the list narrows the stack back down.
+	alone := []transcriptionResponse{
+		markerCall("address.mp3", at, "address", 41.05, -74.75),
+		markerCall("address2.mp3", at.Add(time.Minute), "address", 41.05, -74.75),
+	}
+	assignMapMarkers(alone)
+	lat, _ := markerOffset(0, markerSpread["address"], 41.05)
+	if m := alone[0].Marker; m.OffsetLat != lat {
+		t.Errorf("address-only stack offset = %v, want %v", m.OffsetLat, lat)
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mapboxAPIBase       = "https://api.mapbox.com"
+	mapProxyTimeout     = 15 * time.Second
+	maxMapProxyBody     = 16 << 20
+	defaultGeocodeLimit = 5
+	maxGeocodeLimit     = 10
+	staticMapStyle      = "mapbox/streets-v12"
+)
+
+// mapProxyPrefixes are the Mapbox APIs the map needs: styles, sprites and
+// glyphs, vector and raster tiles. Anything else is refused so the proxy
+// cannot be used to spend the token on other Mapbox products.
+var mapProxyPrefixes = []string{"styles/v1/", "fonts/v1/", "v4/", "raster/v1/", "rasterarrays/v1/", "models/v1/"}
+
+// handleTiles forwards /tiles/{path} to api.mapbox.com/{path} with the server's
+// token. Any access_token the browser sends is dropped, and the token is
+// scrubbed from responses (TileJSON embeds it in tile URLs) so it never
+// reaches the client.
+func (s *server) handleTiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimSpace(s.cfg.MapboxToken)
+	if token == "" {
+		http.Error(w, "map unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/tiles/")
+	if !allowedMapPath(path) {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.mapLimiter.allow(clientIP(r), time.Now()) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	query := r.URL.Query()
+	query.Del("access_token")
+	key := mapCacheKey(path, query)
+	if body, contentType, ok := s.readTileCache(key); ok {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Cache", "HIT")
+		w.Write(body)
+		return
+	}
+
+	query.Set("access_token", token)
+	status, header, body, err := s.fetchMapbox(r.Context(), path, query)
+	if err != nil {
+		log.Printf("map proxy %s failed: %v", path, err)
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		return
+	}
+	body = bytes.ReplaceAll(body, []byte(token), nil)
+	contentType := header.Get("Content-Type")
+	if status == http.StatusOK {
+		s.writeTileCache(key, contentType, body)
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if cc := header.Get("Cache-Control"); cc != "" {
+		w.Header().Set("Cache-Control", cc)
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// handleGeocode runs a forward geocode biased to the county for ?q=, so the UI
+// can search addresses without holding a Mapbox token. The Mapbox response is
+// passed through.
+func (s *server) handleGeocode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimSpace(s.cfg.MapboxToken)
+	if token == "" {
+		http.Error(w, "geocoding unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := defaultGeocodeLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxGeocodeLimit {
+			http.Error(w, "limit must be between 1 and 10", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	if !s.mapLimiter.allow(clientIP(r), time.Now()) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	query := url.Values{}
+	query.Set("access_token", token)
+	query.Set("autocomplete", "true")
+	query.Set("country", "US")
+	query.Set("language", "en")
+	query.Set("limit", strconv.Itoa(limit))
+	bbox := s.regionBBox()
+	query.Set("bbox", fmt.Sprintf("%f,%f,%f,%f", bbox[0], bbox[1], bbox[2], bbox[3]))
+	path := "geocoding/v5/mapbox.places/" + url.PathEscape(q) + ".json"
+	status, header, body, err := s.fetchMapbox(r.Context(), path, query)
+	if err != nil {
+		log.Printf("geocode proxy failed: %v", err)
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", header.Get("Content-Type"))
+	w.WriteHeader(status)
+	w.Write(bytes.ReplaceAll(body, []byte(token), nil))
+}
+
+func (s *server) fetchMapbox(ctx context.Context, path string, query url.Values) (int, http.Header, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, mapProxyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mapboxAPIBase+"/"+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxMapProxyBody))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// staticMapJPEG renders a Mapbox static map (width x height points, fetched
+// at 2x) with overlay at view, as JPEG for PDF and HTML reports. Results go
+// through the tile cache; allow is consulted only on a cache miss and may be
+// nil. It returns nil when the map is unavailable.
+func (s *server) staticMapJPEG(ctx context.Context, overlay, view string, width, height int, allow func() bool) []byte {
+	img := s.staticMapImage(ctx, overlay, view, width, height, allow)
+	if img == nil {
+		return nil
+	}
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil
+	}
+	return out.Bytes()
+}
+
+// staticMapImage is staticMapJPEG's decoded map, 2*width x 2*height pixels.
+func (s *server) staticMapImage(ctx context.Context, overlay, view string, width, height int, allow func() bool) image.Image {
+	token := strings.TrimSpace(s.cfg.MapboxToken)
+	if token == "" {
+		return nil
+	}
+	path := fmt.Sprintf("styles/v1/%s/static/%s/%s/%dx%d@2x", staticMapStyle, overlay, view, width, height)
+	query := url.Values{}
+	key := mapCacheKey(path, query)
+	body, _, ok := s.readTileCache(key)
+	if !ok {
+		if allow != nil && !allow() {
+			return nil
+		}
+		query.Set("access_token", token)
+		status, header, fetched, err := s.fetchMapbox(ctx, path, query)
+		if err != nil || status != http.StatusOK {
+			log.Printf("static map failed: status %d: %v", status, err)
+			return nil
+		}
+		body = fetched
+		s.writeTileCache(key, header.Get("Content-Type"), body)
+	}
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("static map decode failed: %v", err)
+		return nil
+	}
+	return img
+}
+
+func allowedMapPath(path string) bool {
+	if strings.Contains(path, "..") {
+		return false
+	}
+	for _, prefix := range mapProxyPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mapCacheKey identifies a tile request independent of the per-session sku
+// parameter Mapbox GL adds for billing.
+func mapCacheKey(path string, query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if k != "sku" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(path)
+	for _, k := range keys {
+		b.WriteString("&" + k + "=" + strings.Join(query[k], ","))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *server) tileCachePath(key string) string {
+	return filepath.Join(s.cfg.WorkDir, "tile-cache", key[:2], key)
+}
+
+// readTileCache returns a cached response younger than MAP_TILE_CACHE_TTL_SEC.
+// Entries are stored as the content type on one line followed by the body.
+func (s *server) readTileCache(key string) ([]byte, string, bool) {
+	if s.cfg.MapTileCacheTTLSec <= 0 {
+		return nil, "", false
+	}
+	path := s.tileCachePath(key)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > time.Duration(s.cfg.MapTileCacheTTLSec)*time.Second {
+		return nil, "", false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", false
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	contentType, err := br.ReadString('\n')
+	if err != nil {
+		return nil, "", false
+	}
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return nil, "", false
+	}
+	return body, strings.TrimSuffix(contentType, "\n"), true
+}
+
+func (s *server) writeTileCache(key, contentType string, body []byte) {
+	if s.cfg.MapTileCacheTTLSec <= 0 {
+		return
+	}
+	path := s.tileCachePath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Printf("tile cache mkdir failed: %v", err)
+		return
+	}
+	tmp := path + ".tmp"
+	data := append([]byte(strings.ReplaceAll(contentType, "\n", "")+"\n"), body...)
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("tile cache write failed: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("tile cache rename failed: %v", err)
+		os.Remove(tmp)
+	}
+}
+
+// clientIP is the address of the peer that connected to us. Forwarded headers
+// are ignored because anyone can set them to dodge the rate limit.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ipRateLimiter is a token bucket per client IP, refilled at perMin tokens a
+// minute with a burst of perMin. A zero rate disables limiting.
+type ipRateLimiter struct {
+	mu        sync.Mutex
+	perMin    int
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newIPRateLimiter(perMin int) *ipRateLimiter {
+	return &ipRateLimiter{perMin: perMin, buckets: make(map[string]*rateBucket)}
+}
+
+func (l *ipRateLimiter) allow(ip string, now time.Time) bool {
+	if l == nil || l.perMin <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	capacity := float64(l.perMin)
+	if now.Sub(l.lastSweep) > 10*time.Minute {
+		// A bucket idle for a minute is full again; dropping it is equivalent.
+		for k, b := range l.buckets {
+			if now.Sub(b.updated) > time.Minute {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &rateBucket{tokens: capacity, updated: now}
+		l.buckets[ip] = b
+	}
+	b.tokens += now.Sub(b.updated).Minutes() * capacity
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.updated = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
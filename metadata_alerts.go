@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+)
+
+// A metadata-only alert is a call created from structured input, such as a
+// text page or a CAD email, with no recording. It skips transcription and
+// runs the rest of the pipeline on the message text: enrichment and
+// classification, tagging, geocoding and notifications; rollups pick it up
+// like any other done call. Its filename follows the recorder convention
+// (Agency_TYPE_YYYY_MM_DD_HH_MM_SS) so the agency, call type and time parse
+// from it everywhere, and ends in an alert-<id> suffix and .txt, which the
+// watcher ignores. source_path stays empty, so every audio field is empty.
+
+const (
+	metadataAlertSource   = "alert"
+	metadataAlertExt      = ".txt"
+	maxMetadataAlertBytes = 64 << 10
+)
+
+type metadataAlertRequest struct {
+	// ID makes the request idempotent: a repeat returns the stored alert.
+	ID        string   `json:"id"`
+	Text      string   `json:"text"`
+	Timestamp string   `json:"timestamp"`
+	Agency    string   `json:"agency"`
+	Town      string   `json:"town"`
+	CallType  string   `json:"call_type"`
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	// Source labels where the alert came from, e.g. page or cad-email. It is
+	// stored as the ingest source alert:<source>.
+	Source string `json:"source"`
+	Notify *bool  `json:"notify"`
+}
+
+// handleMetadataAlerts serves POST /api/alerts: it creates a done call from
+// the request and returns it, 201 for a new alert and 200 when id matches an
+// existing one.
+func (s *server) handleMetadataAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req metadataAlertRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMetadataAlertBytes)).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		http.Error(w, "latitude and longitude go together", http.StatusBadRequest)
+		return
+	}
+	callTime := time.Now().In(s.tz)
+	if raw := strings.TrimSpace(req.Timestamp); raw != "" {
+		ts, err := parseTimestampFlexible(raw, s.tz)
+		if err != nil {
+			http.Error(w, "invalid timestamp", http.StatusBadRequest)
+			return
+		}
+		callTime = ts.In(s.tz)
+	}
+	if metadataAlertSuffix(req.ID) == "" {
+		http.Error(w, "id must contain letters or digits", http.StatusBadRequest)
+		return
+	}
+	base := s.resolveBaseURL(r)
+	filename, created, err := s.storeMetadataAlert(r.Context(), req, callTime, base)
+	if err != nil {
+		log.Printf("metadata alert %s failed: %v", filename, err)
+		http.Error(w, "could not create alert", http.StatusInternalServerError)
+		return
+	}
+	record, err := s.getTranscription(filename)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		respondJSON(w, s.toResponse(*record, base))
+		return
+	}
+	noteAudit(r, "alert.create", filename, nil, map[string]interface{}{"source": record.Source, "notify": req.Notify == nil || *req.Notify})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	respondJSON(w, s.toResponse(*record, base))
+}
+
+// storeMetadataAlert creates the alert for req unless its id matches one
+// created before, and returns the alert's filename and whether it is new.
+func (s *server) storeMetadataAlert(ctx context.Context, req metadataAlertRequest, callTime time.Time, baseURL string) (string, bool, error) {
+	suffix := metadataAlertSuffix(req.ID)
+	if suffix == "" {
+		return "", false, errors.New("alert id has no letters or digits")
+	}
+	if strings.TrimSpace(req.ID) != "" {
+		existing, err := s.metadataAlertByID(suffix)
+		if err != nil {
+			return "", false, err
+		}
+		if existing != nil {
+			return existing.Filename, false, nil
+		}
+	}
+	filename := metadataAlertFilename(req, callTime, suffix)
+	notify := req.Notify == nil || *req.Notify
+	if err := s.createMetadataAlert(ctx, filename, req, callTime, notify, baseURL); err != nil {
+		return filename, false, err
+	}
+	return filename, true, nil
+}
+
+// createMetadataAlert runs the pipeline after transcription for a call whose
+// transcript is the alert text.
+func (s *server) createMetadataAlert(ctx context.Context, filename string, req metadataAlertRequest, callTime time.Time, notify bool, baseURL string) error {
+	source := metadataAlertSource
+	if label := cadFilenameUnsafe.ReplaceAllString(strings.TrimSpace(req.Source), "-"); label != "" {
+		source += ":" + strings.ToLower(label)
+	}
+	err := s.store.transition(ctx, filename, statusProcessing, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO transcriptions (filename, source_path, processed_path, ingest_source, status, call_timestamp) VALUES (?, '', '', ?, ?, ?)`, filename, source, statusProcessing, callTime.UTC())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	meta := metadataAlertMeta(req, filename, callTime)
+	opts, _ := s.defaultOptions()
+	artifacts := s.enrichTranscript(filename, req.Text, opts, meta)
+	cleaned := artifacts.CleanTranscript
+	if artifacts.PublicTranscript == "" {
+		artifacts.PublicTranscript = s.redactTranscript(cleaned)
+	}
+	normalized := artifacts.NormalizedText
+	if normalized == nil || strings.TrimSpace(*normalized) == "" {
+		fallback := s.normalizeTranscript(cleaned, meta)
+		normalized = &fallback
+	}
+	callType := artifacts.CallType
+	if ct := strings.TrimSpace(req.CallType); ct != "" {
+		callType = &ct
+	}
+	recognized := parseRecognizedTowns(artifacts.RecognizedTowns)
+	tagsList := s.buildTags(meta, recognized, callType)
+	var tagsJSON *string
+	if data, err := json.Marshal(tagsList); err == nil {
+		str := string(data)
+		tagsJSON = &str
+	}
+
+	var location *locationGuess
+	if req.Latitude != nil {
+		label := strings.TrimSpace(strings.Join(nonEmpty(req.Address, req.Town), ", "))
+		location = &locationGuess{Label: label, Latitude: *req.Latitude, Longitude: *req.Longitude, Precision: "provided", Source: "provided"}
+	} else {
+		// A given address is geocoded ahead of anything the text mentions.
+		locText := *normalized
+		if addr := strings.TrimSpace(req.Address); addr != "" {
+			locText = strings.Join(nonEmpty(addr, req.Town), ", ") + ". " + locText
+		}
+		candidate := transcription{
+			Filename:             filename,
+			NormalizedTranscript: &locText,
+			CleanTranscript:      &cleaned,
+			RawTranscript:        &req.Text,
+			RecognizedTowns:      artifacts.RecognizedTowns,
+			CallType:             callType,
+			TagsJSON:             tagsJSON,
+		}
+		location, _ = s.resolveCallLocation(ctx, candidate, meta, recognized)
+	}
+	lat, lon, label, locSource := locationFields(location)
+
+	if err := s.completeCall(filename, callCompletion{
+		raw:              &req.Text,
+		clean:            &cleaned,
+		translation:      artifacts.Translation,
+		towns:            artifacts.RecognizedTowns,
+		normalized:       normalized,
+		callType:         callType,
+		tags:             tagsJSON,
+		lat:              lat,
+		lon:              lon,
+		label:            label,
+		source:           locSource,
+		metadataJSON:     artifacts.MetadataJSON,
+		addressJSON:      artifacts.AddressJSON,
+		manualReview:     artifacts.NeedsManualReview,
+		publicTranscript: artifacts.PublicTranscript,
+		detectedLanguage: artifacts.DetectedLanguage,
+		embedding:        artifacts.Embedding,
+	}); err != nil {
+		s.markError(filename, err)
+		return err
+	}
+	s.recordStationDistances(ctx, filename, location)
+	if notify {
+		j := processJob{filename: filename, source: source, sendGroupMe: true, meta: meta, prettyTitle: formatting.FormatPrettyTitle(filename, callTime, s.tz), baseURL: baseURL}
+		s.sendCallAlert(j, "", callType, tagsList, location, recognized, artifacts.PublicTranscript)
+	}
+	return nil
+}
+
+// metadataAlertByID finds the alert created earlier with the same id.
+func (s *server) metadataAlertByID(suffix string) (*transcription, error) {
+	var filename string
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&filename)
+	}, `SELECT filename FROM transcriptions WHERE filename LIKE ? ESCAPE '\' AND ingest_source LIKE ? LIMIT 1`, `%\_`+suffix+metadataAlertExt, metadataAlertSource+"%")
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.getTranscription(filename)
+}
+
+// metadataAlertSuffix is the alert-<id> filename segment, random when the
+// request has no id. Underscores separate filename fields, so none survive.
+func metadataAlertSuffix(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		buf := make([]byte, 6)
+		_, _ = rand.Read(buf)
+		return "alert-" + hex.EncodeToString(buf)
+	}
+	id = strings.Trim(cadFilenameUnsafe.ReplaceAllString(strings.ReplaceAll(id, "_", "-"), "-"), "-.")
+	if id == "" {
+		return ""
+	}
+	return "alert-" + id
+}
+
+func metadataAlertFilename(req metadataAlertRequest, callTime time.Time, suffix string) string {
+	// Agency words are run together in CamelCase, which the filename parser
+	// splits back into words; call type words keep their hyphens.
+	agency := camelWords(req.Agency)
+	if agency == "" {
+		agency = camelWords(req.Town)
+	}
+	if agency == "" {
+		agency = "Alert"
+	}
+	parts := []string{agency}
+	callType := strings.Join(strings.Fields(strings.ReplaceAll(req.CallType, "_", " ")), "-")
+	if ct := strings.ToUpper(strings.Trim(cadFilenameUnsafe.ReplaceAllString(callType, ""), "-.")); ct != "" {
+		parts = append(parts, ct)
+	}
+	parts = append(parts, callTime.Format("2006_01_02_15_04_05"), suffix)
+	return strings.Join(parts, "_") + metadataAlertExt
+}
+
+// metadataAlertMeta is the metadata parsed from the alert's filename, with
+// the request's own agency and town, which the filename can only abbreviate.
+func metadataAlertMeta(req metadataAlertRequest, filename string, callTime time.Time) formatting.CallMetadata {
+	meta := formatting.CallMetadata{RawFileName: filename}
+	if parsed, err := formatting.ParseCallMetadataFromFilename(filename, callTime.Location()); err == nil {
+		meta = parsed
+	}
+	meta.DateTime = callTime
+	if agency := strings.TrimSpace(req.Agency); agency != "" {
+		meta.AgencyDisplay = agency
+	}
+	if town := strings.TrimSpace(req.Town); town != "" {
+		meta.TownDisplay = town
+	}
+	if meta.AgencyDisplay == "" {
+		meta.AgencyDisplay = meta.TownDisplay
+	}
+	if meta.TownDisplay == "" {
+		meta.TownDisplay = meta.AgencyDisplay
+	}
+	if ct := strings.TrimSpace(req.CallType); ct != "" {
+		meta.CallType = strings.ToUpper(ct)
+	}
+	return meta
+}
+
+func camelWords(v string) string {
+	var b strings.Builder
+	for _, w := range strings.Fields(cadFilenameUnsafe.ReplaceAllString(strings.ReplaceAll(v, "_", " "), " ")) {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
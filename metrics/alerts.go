@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// AlertLatency reports how long calls took from file arrival to their alert,
+// against the latency objective in force when each alert went out.
+type AlertLatency struct {
+	WithinObjective int64
+	Late            int64
+	Latency         LagSummary
+}
+
+type alertTracker struct {
+	mu      sync.Mutex
+	within  int64
+	late    int64
+	latency lagRing
+}
+
+// RecordAlert notes an alert sent latency after its recording arrived. It
+// counts as late when latency exceeds objective.
+func (m *Metrics) RecordAlert(latency, objective time.Duration) {
+	m.alerts.mu.Lock()
+	defer m.alerts.mu.Unlock()
+	if latency > objective {
+		m.alerts.late++
+	} else {
+		m.alerts.within++
+	}
+	m.alerts.latency.add(latency)
+}
+
+// AlertSnapshot returns alert latency since the service started.
+func (m *Metrics) AlertSnapshot() AlertLatency {
+	m.alerts.mu.Lock()
+	defer m.alerts.mu.Unlock()
+	return AlertLatency{
+		WithinObjective: m.alerts.within,
+		Late:            m.alerts.late,
+		Latency:         m.alerts.latency.summary(),
+	}
+}
@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertSnapshotCountsLateAlerts(t *testing.T) {
+	m := New()
+	m.RecordAlert(30*time.Second, 2*time.Minute)
+	m.RecordAlert(2*time.Minute, 2*time.Minute)
+	m.RecordAlert(5*time.Minute, 2*time.Minute)
+
+	snap := m.AlertSnapshot()
+	if snap.WithinObjective != 2 || snap.Late != 1 {
+		t.Fatalf("within/late = %d/%d", snap.WithinObjective, snap.Late)
+	}
+	if snap.Latency.Samples != 3 || snap.Latency.Max != 5*time.Minute || snap.Latency.Last != 5*time.Minute {
+		t.Fatalf("latency = %+v", snap.Latency)
+	}
+}
@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ingestSampleSize bounds how many recent lag samples are kept per source.
+const ingestSampleSize = 200
+
+// LagSummary describes recent lag samples for one ingest stage.
+type LagSummary struct {
+	Samples int
+	Last    time.Duration
+	Avg     time.Duration
+	P95     time.Duration
+	Max     time.Duration
+}
+
+// SourceIngest reports ingest activity and lag for one job source, such as
+// the directory watcher or a remote poller.
+type SourceIngest struct {
+	Source          string
+	Enqueued        int64
+	Completed       int64
+	LastModifiedAt  time.Time
+	LastEnqueuedAt  time.Time
+	LastCompletedAt time.Time
+	// EnqueueLag is the time from the file's mtime to it being queued.
+	EnqueueLag LagSummary
+	// ProcessLag is the time from being queued to the call being done.
+	ProcessLag LagSummary
+}
+
+type sourceIngest struct {
+	enqueued        int64
+	completed       int64
+	lastModifiedAt  time.Time
+	lastEnqueuedAt  time.Time
+	lastCompletedAt time.Time
+	enqueueLag      lagRing
+	processLag      lagRing
+}
+
+// lagRing keeps the most recent ingestSampleSize durations.
+type lagRing struct {
+	samples []time.Duration
+	next    int
+}
+
+func (r *lagRing) add(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	if len(r.samples) < ingestSampleSize {
+		r.samples = append(r.samples, d)
+		return
+	}
+	r.samples[r.next] = d
+	r.next = (r.next + 1) % ingestSampleSize
+}
+
+func (r *lagRing) last() time.Duration {
+	if len(r.samples) == 0 {
+		return 0
+	}
+	if len(r.samples) < ingestSampleSize {
+		return r.samples[len(r.samples)-1]
+	}
+	return r.samples[(r.next+ingestSampleSize-1)%ingestSampleSize]
+}
+
+func (r *lagRing) summary() LagSummary {
+	n := len(r.samples)
+	if n == 0 {
+		return LagSummary{}
+	}
+	sorted := append([]time.Duration(nil), r.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	idx := (n*95+99)/100 - 1
+	return LagSummary{
+		Samples: n,
+		Last:    r.last(),
+		Avg:     total / time.Duration(n),
+		P95:     sorted[idx],
+		Max:     sorted[n-1],
+	}
+}
+
+type ingestTracker struct {
+	mu      sync.Mutex
+	sources map[string]*sourceIngest
+}
+
+func (t *ingestTracker) source(name string) *sourceIngest {
+	if t.sources == nil {
+		t.sources = make(map[string]*sourceIngest)
+	}
+	src, ok := t.sources[name]
+	if !ok {
+		src = &sourceIngest{}
+		t.sources[name] = src
+	}
+	return src
+}
+
+// RecordEnqueue notes that source queued a file at enqueuedAt. A zero
+// modified time skips the mtime-to-enqueue lag, which is meaningless for
+// reprocessed files.
+func (m *Metrics) RecordEnqueue(source string, modified, enqueuedAt time.Time) {
+	m.ingest.mu.Lock()
+	defer m.ingest.mu.Unlock()
+	src := m.ingest.source(source)
+	src.enqueued++
+	src.lastEnqueuedAt = enqueuedAt
+	if !modified.IsZero() {
+		if modified.After(src.lastModifiedAt) {
+			src.lastModifiedAt = modified
+		}
+		src.enqueueLag.add(enqueuedAt.Sub(modified))
+	}
+}
+
+// RecordDone notes that a job from source queued at enqueuedAt finished
+// successfully at doneAt.
+func (m *Metrics) RecordDone(source string, enqueuedAt, doneAt time.Time) {
+	m.ingest.mu.Lock()
+	defer m.ingest.mu.Unlock()
+	src := m.ingest.source(source)
+	src.completed++
+	src.lastCompletedAt = doneAt
+	if !enqueuedAt.IsZero() {
+		src.processLag.add(doneAt.Sub(enqueuedAt))
+	}
+}
+
+// IngestSnapshot returns per-source ingest stats ordered by source name.
+func (m *Metrics) IngestSnapshot() []SourceIngest {
+	m.ingest.mu.Lock()
+	defer m.ingest.mu.Unlock()
+	out := make([]SourceIngest, 0, len(m.ingest.sources))
+	for name, src := range m.ingest.sources {
+		out = append(out, SourceIngest{
+			Source:          name,
+			Enqueued:        src.enqueued,
+			Completed:       src.completed,
+			LastModifiedAt:  src.lastModifiedAt,
+			LastEnqueuedAt:  src.lastEnqueuedAt,
+			LastCompletedAt: src.lastCompletedAt,
+			EnqueueLag:      src.enqueueLag.summary(),
+			ProcessLag:      src.processLag.summary(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out
+}
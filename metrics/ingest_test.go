@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIngestSnapshotPerSource(t *testing.T) {
+	m := New()
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.RecordEnqueue("watcher", base, base.Add(2*time.Second))
+	m.RecordEnqueue("watcher", base.Add(time.Minute), base.Add(time.Minute+4*time.Second))
+	m.RecordDone("watcher", base.Add(2*time.Second), base.Add(32*time.Second))
+	m.RecordEnqueue("ops", time.Time{}, base)
+
+	snap := m.IngestSnapshot()
+	if len(snap) != 2 || snap[0].Source != "ops" || snap[1].Source != "watcher" {
+		t.Fatalf("unexpected sources: %+v", snap)
+	}
+	ops := snap[0]
+	if ops.Enqueued != 1 || ops.EnqueueLag.Samples != 0 || !ops.LastModifiedAt.IsZero() {
+		t.Fatalf("reprocess enqueue should not record mtime lag: %+v", ops)
+	}
+	w := snap[1]
+	if w.Enqueued != 2 || w.Completed != 1 {
+		t.Fatalf("counts = %d/%d", w.Enqueued, w.Completed)
+	}
+	if w.EnqueueLag.Last != 4*time.Second || w.EnqueueLag.Avg != 3*time.Second || w.EnqueueLag.Max != 4*time.Second {
+		t.Fatalf("enqueue lag = %+v", w.EnqueueLag)
+	}
+	if w.ProcessLag.Samples != 1 || w.ProcessLag.Last != 30*time.Second {
+		t.Fatalf("process lag = %+v", w.ProcessLag)
+	}
+	if !w.LastModifiedAt.Equal(base.Add(time.Minute)) {
+		t.Fatalf("last modified = %v", w.LastModifiedAt)
+	}
+}
+
+func TestLagRingKeepsRecentSamples(t *testing.T) {
+	var r lagRing
+	for i := 1; i <= ingestSampleSize+10; i++ {
+		r.add(time.Duration(i) * time.Second)
+	}
+	sum := r.summary()
+	if sum.Samples != ingestSampleSize {
+		t.Fatalf("samples = %d", sum.Samples)
+	}
+	if sum.Last != time.Duration(ingestSampleSize+10)*time.Second {
+		t.Fatalf("last = %v", sum.Last)
+	}
+	if sum.Max != sum.Last {
+		t.Fatalf("max = %v", sum.Max)
+	}
+}
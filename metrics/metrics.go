@@ -10,6 +10,12 @@ type Metrics struct {
 
 	processedJobs int64
 	failedJobs    int64
+
+	workFilesRemoved   int64
+	workBytesReclaimed int64
+
+	ingest ingestTracker
+	alerts alertTracker
 }
 
 // Snapshot provides a consistent view of the current metrics.
@@ -19,6 +25,9 @@ type Snapshot struct {
 	WorkerCount   int
 	ProcessedJobs int64
 	FailedJobs    int64
+
+	WorkFilesRemoved   int64
+	WorkBytesReclaimed int64
 }
 
 // New creates a zeroed Metrics instance.
@@ -41,6 +50,13 @@ func (m *Metrics) RecordJobCompletion(err error) {
 	}
 }
 
+// RecordWorkCleanup adds files deleted from the work directory and the bytes
+// they held.
+func (m *Metrics) RecordWorkCleanup(files int, bytes int64) {
+	atomic.AddInt64(&m.workFilesRemoved, int64(files))
+	atomic.AddInt64(&m.workBytesReclaimed, bytes)
+}
+
 // Snapshot returns a read-only view of metrics.
 func (m *Metrics) Snapshot() Snapshot {
 	return Snapshot{
@@ -49,5 +65,8 @@ func (m *Metrics) Snapshot() Snapshot {
 		WorkerCount:   int(atomic.LoadInt64(&m.workerCount)),
 		ProcessedJobs: atomic.LoadInt64(&m.processedJobs),
 		FailedJobs:    atomic.LoadInt64(&m.failedJobs),
+
+		WorkFilesRemoved:   atomic.LoadInt64(&m.workFilesRemoved),
+		WorkBytesReclaimed: atomic.LoadInt64(&m.workBytesReclaimed),
 	}
 }
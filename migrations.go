@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	migrationUp   = "up"
+	migrationDown = "down"
+)
+
+type migrationStep struct {
+	Version    int    `json:"version"`
+	Name       string `json:"name"`
+	Direction  string `json:"direction"`
+	Reversible bool   `json:"reversible"`
+	DurationMS *int64 `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// migrationPlan is the ordered list of steps that takes the database from
+// CurrentVersion to TargetVersion. Going down needs every step to be
+// reversible.
+type migrationPlan struct {
+	CurrentVersion int             `json:"current_version"`
+	LatestVersion  int             `json:"latest_version"`
+	TargetVersion  int             `json:"target_version"`
+	Steps          []migrationStep `json:"steps"`
+}
+
+func dropTables(tables ...string) func(db *sql.DB) error {
+	return func(db *sql.DB) error {
+		for _, t := range tables {
+			if _, err := execWithRetry(db, fmt.Sprintf("DROP TABLE IF EXISTS %s", t)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func dropColumnIfExists(db *sql.DB, table, column string) error {
+	var n int
+	if err := queryRowWithRetry(db, func(row *sql.Row) error {
+		return row.Scan(&n)
+	}, fmt.Sprintf("SELECT COUNT(*) FROM pragma_table_info('%s') WHERE name = ?", table), column); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	_, err := execWithRetry(db, fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column))
+	return err
+}
+
+func downTrendAggregates(db *sql.DB) error {
+	if _, err := execWithRetry(db, `DROP INDEX IF EXISTS idx_transcriptions_updated_at`); err != nil {
+		return err
+	}
+	return dropTables("stats_daily", "stats_monthly", "stats_aggregation_state")(db)
+}
+
+func downMonthlyReports(db *sql.DB) error {
+	if err := dropTables("monthly_reports")(db); err != nil {
+		return err
+	}
+	return dropColumnIfExists(db, "transcriptions", "processing_seconds")
+}
+
+func downEmailNotifications(db *sql.DB) error {
+	if err := dropTables("email_digests")(db); err != nil {
+		return err
+	}
+	return dropColumnIfExists(db, "app_settings", "email_settings")
+}
+
+// planMigrations works out the steps from current to target. A negative
+// target means the latest version.
+func planMigrations(current int, migrations []migration, target int) (migrationPlan, error) {
+	latest := 0
+	for _, m := range migrations {
+		if m.version > latest {
+			latest = m.version
+		}
+	}
+	if target < 0 {
+		target = latest
+	}
+	plan := migrationPlan{CurrentVersion: current, LatestVersion: latest, TargetVersion: target, Steps: []migrationStep{}}
+	if current > latest {
+		return plan, fmt.Errorf("database is at version %d, newer than this build (%d)", current, latest)
+	}
+	if target > latest {
+		return plan, fmt.Errorf("target version %d is newer than this build (%d)", target, latest)
+	}
+	if target >= current {
+		for _, m := range migrations {
+			if m.version > current && m.version <= target {
+				plan.Steps = append(plan.Steps, migrationStep{Version: m.version, Name: m.name, Direction: migrationUp, Reversible: m.down != nil})
+			}
+		}
+		return plan, nil
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.version <= target || m.version > current {
+			continue
+		}
+		plan.Steps = append(plan.Steps, migrationStep{Version: m.version, Name: m.name, Direction: migrationDown, Reversible: m.down != nil})
+		if m.down == nil {
+			return plan, fmt.Errorf("migration %d (%s) cannot be reversed; the lowest reachable version is %d", m.version, m.name, m.version)
+		}
+	}
+	return plan, nil
+}
+
+// runMigrationPlan applies the plan's steps in order, recording each step's
+// duration and stopping at the first failure.
+func runMigrationPlan(db *sql.DB, migrations []migration, plan *migrationPlan) error {
+	byVersion := make(map[int]migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.version] = m
+	}
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		m := byVersion[step.Version]
+		log.Printf("migrating %s %d: %s", step.Direction, m.version, m.name)
+		start := time.Now()
+		var err error
+		if step.Direction == migrationUp {
+			if err = m.up(db); err == nil {
+				_, err = execWithRetry(db, `INSERT OR REPLACE INTO schema_migrations (version, applied_at) VALUES (?, CURRENT_TIMESTAMP)`, m.version)
+			}
+		} else {
+			if err = m.down(db); err == nil {
+				_, err = execWithRetry(db, `DELETE FROM schema_migrations WHERE version = ?`, m.version)
+			}
+		}
+		ms := time.Since(start).Milliseconds()
+		step.DurationMS = &ms
+		if err != nil {
+			step.Error = err.Error()
+			return fmt.Errorf("migration %s %d (%s): %w", step.Direction, m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// dryRunMigrations copies db into dir with VACUUM INTO and runs the plan to
+// target against the copy, which is then removed. The returned plan carries
+// per-step timings and the failing step's error.
+func dryRunMigrations(ctx context.Context, db *sql.DB, migrations []migration, target int, dir string) (migrationPlan, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return migrationPlan{}, err
+	}
+	tmpDir, err := os.MkdirTemp(dir, "migrate-dry-run-")
+	if err != nil {
+		return migrationPlan{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+	copyPath := filepath.Join(tmpDir, "copy.db")
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, copyPath); err != nil {
+		return migrationPlan{}, fmt.Errorf("copy database: %w", err)
+	}
+	copyDB, err := openSQLite(copyPath)
+	if err != nil {
+		return migrationPlan{}, err
+	}
+	defer copyDB.Close()
+	if err := ensureSchemaVersionTable(copyDB); err != nil {
+		return migrationPlan{}, err
+	}
+	current, err := currentSchemaVersion(copyDB)
+	if err != nil {
+		return migrationPlan{}, err
+	}
+	plan, err := planMigrations(current, migrations, target)
+	if err != nil {
+		return plan, err
+	}
+	if err := runMigrationPlan(copyDB, migrations, &plan); err != nil {
+		return plan, err
+	}
+	copyDB.Close()
+	if err := verifySnapshot(ctx, copyPath); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// runMigrateCommand implements "alert_framework migrate": it opens DB_PATH
+// without migrating it and then
+//
+//	migrate plan [-to N]       lists the steps to version N (default: latest)
+//	migrate dry-run [-to N]    runs them against a copy of the database
+//	migrate apply [-to N]      dry-runs, then runs them against the database
+//
+// Stop the service before apply: a running server migrates back up to its own
+// version on the next start, so a downgrade is for rolling back to an older
+// build.
+func runMigrateCommand(dbPath, workDir string, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: alert_framework migrate plan|dry-run|apply [-to VERSION] [-skip-dry-run]")
+		return 2
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	to := fs.Int("to", -1, "target schema version (default: latest)")
+	skipDryRun := fs.Bool("skip-dry-run", false, "apply without first running against a copy")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	db, err := openSQLite(dbPath)
+	if err != nil {
+		log.Printf("open db: %v", err)
+		return 1
+	}
+	defer db.Close()
+	if err := ensureSchemaVersionTable(db); err != nil {
+		log.Printf("schema version table: %v", err)
+		return 1
+	}
+	migrations := schemaMigrations()
+	ctx := context.Background()
+	printPlan := func(plan migrationPlan) {
+		out, _ := json.MarshalIndent(plan, "", "  ")
+		fmt.Println(string(out))
+	}
+	switch action {
+	case "plan":
+		current, err := currentSchemaVersion(db)
+		if err != nil {
+			log.Printf("schema version: %v", err)
+			return 1
+		}
+		plan, err := planMigrations(current, migrations, *to)
+		printPlan(plan)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+	case "dry-run", "apply":
+		if action == "dry-run" || !*skipDryRun {
+			plan, err := dryRunMigrations(ctx, db, migrations, *to, workDir)
+			if action == "dry-run" || err != nil {
+				printPlan(plan)
+			}
+			if err != nil {
+				log.Printf("dry run failed: %v", err)
+				return 1
+			}
+			if action == "dry-run" {
+				return 0
+			}
+			log.Printf("dry run passed (%d steps)", len(plan.Steps))
+		}
+		current, err := currentSchemaVersion(db)
+		if err != nil {
+			log.Printf("schema version: %v", err)
+			return 1
+		}
+		plan, err := planMigrations(current, migrations, *to)
+		if err == nil {
+			err = runMigrationPlan(db, migrations, &plan)
+		}
+		printPlan(plan)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate action %q (want plan, dry-run or apply)\n", action)
+		return 2
+	}
+	return 0
+}
+
+// handleMigrations reports schema migration state (admin only):
+//
+//	GET  /api/admin/migrations[?target=N]           every migration and the plan to N
+//	POST /api/admin/migrations/dry-run[?target=N]   run the plan against a copy of the database
+//
+// Applying is left to the migrate command, which runs with the service
+// stopped.
+func (s *server) handleMigrations(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	target := -1
+	if raw := strings.TrimSpace(r.URL.Query().Get("target")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			http.Error(w, "target must be a schema version", http.StatusBadRequest)
+			return
+		}
+		target = v
+	}
+	migrations := schemaMigrations()
+	switch strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/migrations"), "/") {
+	case "":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		current, err := currentSchemaVersion(s.db)
+		if err != nil {
+			log.Printf("schema version lookup failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		all := make([]migrationStep, 0, len(migrations))
+		for _, m := range migrations {
+			all = append(all, migrationStep{Version: m.version, Name: m.name, Reversible: m.down != nil})
+		}
+		resp := map[string]interface{}{"migrations": all}
+		plan, err := planMigrations(current, migrations, target)
+		resp["plan"] = plan
+		if err != nil {
+			resp["error"] = err.Error()
+		}
+		respondJSON(w, resp)
+	case "dry-run":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		plan, err := dryRunMigrations(r.Context(), s.db, migrations, target, s.cfg.WorkDir)
+		resp := map[string]interface{}{"ok": err == nil, "plan": plan}
+		if err != nil {
+			resp["error"] = err.Error()
+			log.Printf("migration dry run failed: %v", err)
+		}
+		respondJSON(w, resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+
+	"alert_framework/formatting"
+	"alert_framework/milemarker"
+)
+
+// locateMileMarker places a call from a "Route 15 near mile marker 5" style
+// reference using the MILE_MARKERS_PATH dataset. Geocoders resolve those
+// references to the town centre at best, so a match skips geocoding.
+func (s *server) locateMileMarker(normalized string, meta formatting.CallMetadata) *locationGuess {
+	if s.mileMarkers == nil {
+		return nil
+	}
+	ref, ok := milemarker.ParseReference(normalized)
+	if !ok {
+		return nil
+	}
+	lat, lng, ok := s.mileMarkers.Locate(ref)
+	if !ok || !s.region.Contains(lat, lng) {
+		return nil
+	}
+	label := ref.Label()
+	if meta.TownDisplay != "" && !strings.Contains(strings.ToLower(label), strings.ToLower(meta.TownDisplay)) {
+		label += ", " + meta.TownDisplay
+	}
+	return &locationGuess{Label: label, Latitude: lat, Longitude: lng, Precision: "milemarker", Source: "milemarker"}
+}
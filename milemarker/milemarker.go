@@ -0,0 +1,242 @@
+// Package milemarker turns highway references such as "Route 15 northbound
+// near mile marker 5" into coordinates. Geocoders treat mile markers as free
+// text and usually land on the town centre; a surveyed marker dataset, with
+// linear interpolation between neighbouring markers, lands on the road.
+package milemarker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"alert_framework/formatting"
+)
+
+// MaxGapMiles is the widest stretch between two markers that Locate will
+// interpolate across. Wider gaps usually mean the dataset skips an
+// interchange or a jurisdiction, and a straight line would miss the road.
+const MaxGapMiles = 2.0
+
+// Reference is a highway position read from a transcript.
+type Reference struct {
+	// Route is the folded route name, e.g. "route 15" or "interstate 80".
+	Route string
+	// Direction is "north", "south", "east", "west" or "" when unsaid.
+	Direction string
+	Mile      float64
+}
+
+// Label renders r for display, e.g. "Route 15 northbound, mile marker 5".
+func (r Reference) Label() string {
+	words := strings.Fields(r.Route)
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	label := strings.Join(words, " ")
+	if r.Direction != "" {
+		label += " " + r.Direction + "bound"
+	}
+	return label + ", mile marker " + strconv.FormatFloat(r.Mile, 'f', -1, 64)
+}
+
+var (
+	routePattern     = regexp.MustCompile(`\b(?:county route|route|interstate) \d{1,3}\b`)
+	markerPattern    = regexp.MustCompile(`\b(?:mile ?markers?|mile ?posts?|mm|mp)\s*(\d{1,3}(?:\.\d{1,2})?)\b`)
+	directionPattern = regexp.MustCompile(`\b(?:(north|south|east|west) ?bound|([nsew])/?b)\b`)
+	shortDirections  = map[string]string{"n": "north", "s": "south", "e": "east", "w": "west"}
+)
+
+// maxRouteDistance bounds how far apart, in characters of folded text, a
+// route and a mile marker may be and still be read as one reference.
+const maxRouteDistance = 80
+
+// ParseReference finds a mile-marker reference in text and the route named
+// closest to it. ok is false when text names no marker or no route near it.
+func ParseReference(text string) (ref Reference, ok bool) {
+	key := formatting.AliasKey(text)
+	marker := markerPattern.FindStringSubmatchIndex(key)
+	if marker == nil {
+		return Reference{}, false
+	}
+	mile, err := strconv.ParseFloat(key[marker[2]:marker[3]], 64)
+	if err != nil {
+		return Reference{}, false
+	}
+	best, bestDist := "", maxRouteDistance+1
+	for _, loc := range routePattern.FindAllStringIndex(key, -1) {
+		dist := marker[0] - loc[1]
+		if loc[0] >= marker[1] {
+			dist = loc[0] - marker[1]
+		}
+		if dist < bestDist {
+			best, bestDist = key[loc[0]:loc[1]], dist
+		}
+	}
+	if best == "" {
+		return Reference{}, false
+	}
+	ref = Reference{Route: best, Mile: mile}
+	if m := directionPattern.FindStringSubmatch(key); m != nil {
+		if m[1] != "" {
+			ref.Direction = m[1]
+		} else {
+			ref.Direction = shortDirections[m[2]]
+		}
+	}
+	return ref, true
+}
+
+// Marker is one surveyed mile marker.
+type Marker struct {
+	Route     string
+	Direction string
+	Mile      float64
+	Latitude  float64
+	Longitude float64
+}
+
+// Index holds markers by route and direction, each run sorted by mile.
+type Index struct {
+	runs  map[string][]Marker
+	count int
+}
+
+// Len returns the number of markers in the index.
+func (ix *Index) Len() int {
+	if ix == nil {
+		return 0
+	}
+	return ix.count
+}
+
+// Load reads a GeoJSON FeatureCollection of Point features from path. See
+// Parse for the expected properties.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+type featureCollection struct {
+	Features []struct {
+		Geometry struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties struct {
+			Route     string   `json:"route"`
+			Direction string   `json:"direction"`
+			Milepost  *float64 `json:"milepost"`
+			Mile      *float64 `json:"mile"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// Parse reads a GeoJSON FeatureCollection of Point features. Each feature
+// needs a "route" property ("Route 15", "NJ-15", "I-80") and a "milepost"
+// (or "mile") number; "direction" ("north", "NB", ...) is optional and marks
+// markers that only apply to one carriageway.
+func Parse(r io.Reader) (*Index, error) {
+	var fc featureCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("decode geojson: %w", err)
+	}
+	ix := &Index{runs: make(map[string][]Marker)}
+	for i, f := range fc.Features {
+		if f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) < 2 {
+			return nil, fmt.Errorf("feature %d: geometry must be a Point", i)
+		}
+		mile := f.Properties.Milepost
+		if mile == nil {
+			mile = f.Properties.Mile
+		}
+		route := routePattern.FindString(formatting.AliasKey(f.Properties.Route))
+		if route == "" || mile == nil {
+			return nil, fmt.Errorf("feature %d: route and milepost are required", i)
+		}
+		m := Marker{
+			Route:     route,
+			Direction: normalizeDirection(f.Properties.Direction),
+			Mile:      *mile,
+			Latitude:  f.Geometry.Coordinates[1],
+			Longitude: f.Geometry.Coordinates[0],
+		}
+		key := runKey(m.Route, m.Direction)
+		ix.runs[key] = append(ix.runs[key], m)
+		ix.count++
+	}
+	if ix.count == 0 {
+		return nil, errors.New("no mile markers found")
+	}
+	for _, run := range ix.runs {
+		sort.Slice(run, func(i, j int) bool { return run[i].Mile < run[j].Mile })
+	}
+	return ix, nil
+}
+
+func normalizeDirection(raw string) string {
+	d := strings.ToLower(strings.TrimSpace(raw))
+	d = strings.TrimSuffix(strings.TrimSuffix(d, "bound"), "b")
+	d = strings.TrimSpace(strings.TrimSuffix(d, "/"))
+	if full, ok := shortDirections[d]; ok {
+		return full
+	}
+	switch d {
+	case "north", "south", "east", "west":
+		return d
+	}
+	return ""
+}
+
+func runKey(route, direction string) string {
+	return route + "|" + direction
+}
+
+// Locate returns the position of ref. Markers for ref's direction are used
+// when the dataset has them, otherwise markers that apply to both
+// directions. Between markers the position is interpolated, across gaps of
+// at most MaxGapMiles.
+func (ix *Index) Locate(ref Reference) (lat, lon float64, ok bool) {
+	if ix == nil {
+		return 0, 0, false
+	}
+	if ref.Direction != "" {
+		if lat, lon, ok := locateOnRun(ix.runs[runKey(ref.Route, ref.Direction)], ref.Mile); ok {
+			return lat, lon, true
+		}
+	}
+	return locateOnRun(ix.runs[runKey(ref.Route, "")], ref.Mile)
+}
+
+func locateOnRun(run []Marker, mile float64) (lat, lon float64, ok bool) {
+	i := sort.Search(len(run), func(i int) bool { return run[i].Mile >= mile })
+	if i < len(run) && run[i].Mile == mile {
+		return run[i].Latitude, run[i].Longitude, true
+	}
+	if i == 0 || i == len(run) {
+		return 0, 0, false
+	}
+	lo, hi := run[i-1], run[i]
+	gap := hi.Mile - lo.Mile
+	if gap > MaxGapMiles || gap <= 0 {
+		return 0, 0, false
+	}
+	t := (mile - lo.Mile) / gap
+	lat = lo.Latitude + t*(hi.Latitude-lo.Latitude)
+	lon = lo.Longitude + t*(hi.Longitude-lo.Longitude)
+	return round6(lat), round6(lon), true
+}
+
+func round6(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
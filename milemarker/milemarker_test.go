@@ -0,0 +1,68 @@
+package milemarker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	cases := []struct {
+		text string
+		want Reference
+		ok   bool
+	}{
+		{"Engine 5 respond Route 15 northbound near mile marker 5 for an MVA", Reference{Route: "route 15", Direction: "north", Mile: 5}, true},
+		{"MVA I-80 W/B, MM 25.3, Byram", Reference{Route: "interstate 80", Direction: "west", Mile: 25.3}, true},
+		{"Mile post 3 on County Route 519.", Reference{Route: "county route 519", Mile: 3}, true},
+		{"Route 15 and Main Street", Reference{}, false},
+		{"mile marker 7", Reference{}, false},
+	}
+	for _, c := range cases {
+		got, ok := ParseReference(c.text)
+		if ok != c.ok || got != c.want {
+			t.Errorf("ParseReference(%q) = %+v, %v; want %+v, %v", c.text, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+const testMarkers = `{"type":"FeatureCollection","features":[
+{"type":"Feature","geometry":{"type":"Point","coordinates":[-74.70,41.00]},"properties":{"route":"NJ-15","milepost":4}},
+{"type":"Feature","geometry":{"type":"Point","coordinates":[-74.68,41.02]},"properties":{"route":"NJ-15","milepost":6}},
+{"type":"Feature","geometry":{"type":"Point","coordinates":[-74.60,41.10]},"properties":{"route":"NJ-15","milepost":10}},
+{"type":"Feature","geometry":{"type":"Point","coordinates":[-74.71,41.01]},"properties":{"route":"Route 15","mile":5,"direction":"NB"}}
+]}`
+
+func TestLocate(t *testing.T) {
+	ix, err := Parse(strings.NewReader(testMarkers))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if ix.Len() != 4 {
+		t.Fatalf("Len = %d, want 4", ix.Len())
+	}
+	cases := []struct {
+		ref      Reference
+		lat, lon float64
+		ok       bool
+	}{
+		{Reference{Route: "route 15", Mile: 5}, 41.01, -74.69, true},
+		{Reference{Route: "route 15", Direction: "north", Mile: 5}, 41.01, -74.71, true},
+		{Reference{Route: "route 15", Direction: "south", Mile: 4}, 41.00, -74.70, true},
+		{Reference{Route: "route 15", Mile: 8}, 0, 0, false},
+		{Reference{Route: "route 15", Mile: 12}, 0, 0, false},
+		{Reference{Route: "route 206", Mile: 5}, 0, 0, false},
+	}
+	for _, c := range cases {
+		lat, lon, ok := ix.Locate(c.ref)
+		if ok != c.ok || lat != c.lat || lon != c.lon {
+			t.Errorf("Locate(%+v) = %v, %v, %v; want %v, %v, %v", c.ref, lat, lon, ok, c.lat, c.lon, c.ok)
+		}
+	}
+}
+
+func TestParseRejectsMissingMilepost(t *testing.T) {
+	_, err := Parse(strings.NewReader(`{"features":[{"geometry":{"type":"Point","coordinates":[-74.7,41]},"properties":{"route":"Route 15"}}]}`))
+	if err == nil {
+		t.Fatal("expected error for feature without milepost")
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultModelStatsDays = 30
+	maxModelStatsDays     = 366
+)
+
+func migrateAddTranscriptionCost(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "transcriptions", "transcribe_seconds", "REAL NULL"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(db, "transcriptions", "transcribe_cost_usd", "REAL NULL")
+}
+
+func downTranscriptionCost(db *sql.DB) error {
+	if err := dropColumnIfExists(db, "transcriptions", "transcribe_cost_usd"); err != nil {
+		return err
+	}
+	return dropColumnIfExists(db, "transcriptions", "transcribe_seconds")
+}
+
+// transcriptionCost estimates what transcribing seconds of audio with model
+// cost, at the prices the usage meter uses. Local engines cost nothing.
+func (s *server) transcriptionCost(engine, model string, seconds float64) float64 {
+	if engine != engineOpenAI || s.usage == nil {
+		return 0
+	}
+	return s.usage.Cost(model, 0, 0, seconds)
+}
+
+type latencySummary struct {
+	Samples int     `json:"samples"`
+	Avg     float64 `json:"avg_seconds"`
+	P50     float64 `json:"p50_seconds"`
+	P90     float64 `json:"p90_seconds"`
+}
+
+type modelStats struct {
+	Model              string         `json:"model"`
+	Engine             string         `json:"engine,omitempty"`
+	Calls              int            `json:"calls"`
+	Errors             int            `json:"errors"`
+	ErrorRate          float64        `json:"error_rate"`
+	ManualReviewRate   float64        `json:"manual_review_rate"`
+	AudioMinutes       float64        `json:"audio_minutes"`
+	CostUSD            float64        `json:"cost_usd"`
+	CostPerCall        float64        `json:"cost_per_call_usd"`
+	CostPerAudioMinute float64        `json:"cost_per_audio_minute_usd"`
+	Processing         latencySummary `json:"processing"`
+	Transcribe         latencySummary `json:"transcribe"`
+	// RealtimeFactor is transcription time over audio length: 0.1 means a
+	// minute of audio took six seconds.
+	RealtimeFactor float64 `json:"realtime_factor"`
+
+	processing   []float64
+	transcribe   []float64
+	timedAudio   float64
+	timedSeconds float64
+	reviewed     int
+	audioSeconds float64
+}
+
+type modelStatsResponse struct {
+	From   string        `json:"from"`
+	To     string        `json:"to"`
+	Models []*modelStats `json:"models"`
+}
+
+// handleModelStats serves GET /api/stats/models[?days=30]: per transcription
+// model, how many calls it handled and how many failed, what it cost, and
+// how long calls took, so models can be weighed against their price. Calls
+// processed before costs were recorded are priced from their duration.
+func (s *server) handleModelStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	days := defaultModelStatsDays
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxModelStatsDays {
+			http.Error(w, "days must be between 1 and 366", http.StatusBadRequest)
+			return
+		}
+		days = v
+	}
+	now := time.Now().In(s.tz)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.tz)
+	from := to.AddDate(0, 0, -(days - 1))
+
+	// Failed calls never record the model that answered, so they count
+	// against the model that was asked for.
+	rows, err := s.db.QueryContext(r.Context(), `SELECT COALESCE(actual_openai_model_used, requested_model, ''), COALESCE(transcription_engine, ''), status,
+    duration_seconds, processing_seconds, transcribe_seconds, transcribe_cost_usd, needs_manual_review
+FROM transcriptions
+WHERE status IN (?, ?) AND duplicate_of IS NULL AND deleted_at IS NULL AND effective_ts >= ?`, statusDone, statusError, from.UTC())
+	if err != nil {
+		log.Printf("model stats query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	byModel := make(map[string]*modelStats)
+	for rows.Next() {
+		var (
+			model, engine, status                 string
+			duration, processing, transcribe, fee sql.NullFloat64
+			review                                sql.NullInt64
+		)
+		if err := rows.Scan(&model, &engine, &status, &duration, &processing, &transcribe, &fee, &review); err != nil {
+			log.Printf("model stats scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if model == "" {
+			model = "unknown"
+		}
+		m := byModel[model]
+		if m == nil {
+			m = &modelStats{Model: model}
+			byModel[model] = m
+		}
+		m.Calls++
+		if engine != "" {
+			m.Engine = engine
+		}
+		if status == statusError {
+			m.Errors++
+			continue
+		}
+		if review.Int64 == 1 {
+			m.reviewed++
+		}
+		m.audioSeconds += duration.Float64
+		switch {
+		case fee.Valid:
+			m.CostUSD += fee.Float64
+		case engine == "" || engine == engineOpenAI:
+			m.CostUSD += s.transcriptionCost(engineOpenAI, model, duration.Float64)
+		}
+		if processing.Valid && processing.Float64 > 0 {
+			m.processing = append(m.processing, processing.Float64)
+		}
+		if transcribe.Valid && transcribe.Float64 > 0 {
+			m.transcribe = append(m.transcribe, transcribe.Float64)
+			if duration.Float64 > 0 {
+				m.timedAudio += duration.Float64
+				m.timedSeconds += transcribe.Float64
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("model stats query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := modelStatsResponse{From: from.Format(trendDayLayout), To: to.Format(trendDayLayout), Models: []*modelStats{}}
+	for _, m := range byModel {
+		m.finish()
+		resp.Models = append(resp.Models, m)
+	}
+	sort.Slice(resp.Models, func(i, j int) bool {
+		if resp.Models[i].Calls != resp.Models[j].Calls {
+			return resp.Models[i].Calls > resp.Models[j].Calls
+		}
+		return resp.Models[i].Model < resp.Models[j].Model
+	})
+	respondJSON(w, resp)
+}
+
+// finish turns the collected samples into the reported rates and summaries.
+func (m *modelStats) finish() {
+	done := m.Calls - m.Errors
+	m.ErrorRate = roundTo(float64(m.Errors)/float64(m.Calls), 4)
+	m.AudioMinutes = roundTo(m.audioSeconds/60, 1)
+	if done > 0 {
+		m.ManualReviewRate = roundTo(float64(m.reviewed)/float64(done), 4)
+		m.CostPerCall = roundTo(m.CostUSD/float64(done), 5)
+	}
+	if m.audioSeconds > 0 {
+		m.CostPerAudioMinute = roundTo(m.CostUSD/(m.audioSeconds/60), 5)
+	}
+	m.CostUSD = roundTo(m.CostUSD, 4)
+	m.Processing = summarizeLatency(m.processing)
+	m.Transcribe = summarizeLatency(m.transcribe)
+	if m.timedAudio > 0 {
+		m.RealtimeFactor = roundTo(m.timedSeconds/m.timedAudio, 3)
+	}
+}
+
+func summarizeLatency(samples []float64) latencySummary {
+	if len(samples) == 0 {
+		return latencySummary{}
+	}
+	sort.Float64s(samples)
+	var sum float64
+	for _, v := range samples {
+		sum += v
+	}
+	at := func(q float64) float64 {
+		return roundTo(samples[int(math.Ceil(q*float64(len(samples))))-1], 1)
+	}
+	return latencySummary{Samples: len(samples), Avg: roundTo(sum/float64(len(samples)), 1), P50: at(0.5), P90: at(0.9)}
+}
+
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
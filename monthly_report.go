@@ -0,0 +1,601 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"alert_framework/pdf"
+)
+
+const (
+	monthlyReportTopN      = 10
+	monthlyReportMapWidth  = 532
+	monthlyReportMapHeight = 240
+)
+
+type monthlyReportParams struct {
+	Month string `json:"month"`
+}
+
+type monthlyCount struct {
+	Name  string `json:"name"`
+	Calls int    `json:"calls"`
+}
+
+type monthlyDay struct {
+	Day   string `json:"day"`
+	Calls int    `json:"calls"`
+}
+
+// monthlyReport is the compiled statistics for one local calendar month.
+// Counts cover completed, non-duplicate calls and leave restricted calls
+// out, matching /api/stats/trends.
+type monthlyReport struct {
+	Month                string           `json:"month"`
+	From                 time.Time        `json:"from"`
+	To                   time.Time        `json:"to"`
+	Partial              bool             `json:"partial"`
+	GeneratedAt          time.Time        `json:"generated_at"`
+	Calls                int              `json:"calls"`
+	PreviousMonthCalls   int              `json:"previous_month_calls"`
+	ChangePct            *float64         `json:"change_pct,omitempty"`
+	Duplicates           int              `json:"duplicates"`
+	Errors               int              `json:"errors"`
+	AudioMinutes         float64          `json:"audio_minutes"`
+	AvgProcessingSeconds *float64         `json:"avg_processing_seconds,omitempty"`
+	P90ProcessingSeconds *float64         `json:"p90_processing_seconds,omitempty"`
+	Daily                []monthlyDay     `json:"daily"`
+	Agencies             []monthlyCount   `json:"agencies"`
+	Towns                []monthlyCount   `json:"towns"`
+	CallTypes            []monthlyCount   `json:"call_types"`
+	Hotspots             []hotspotSummary `json:"hotspots"`
+}
+
+type monthlyReportResponse struct {
+	Month       string         `json:"month"`
+	JobID       int64          `json:"job_id"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	PDFURL      string         `json:"pdf_url"`
+	HTMLURL     string         `json:"html_url"`
+	Stats       *monthlyReport `json:"stats"`
+}
+
+// handleMonthlyReport serves /api/reports/monthly/{YYYY-MM}. POST (admin)
+// starts generating the report as an ops job; GET returns the last generated
+// report as JSON, or as a document with ?format=pdf or ?format=html.
+func (s *server) handleMonthlyReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	month := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/reports/monthly/"), "/")
+	start, err := time.ParseInLocation(trendMonthLayout, month, s.tz)
+	if err != nil {
+		http.Error(w, "month must be formatted as YYYY-MM", http.StatusBadRequest)
+		return
+	}
+	if start.After(time.Now()) {
+		http.Error(w, "month is in the future", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.startMonthlyReport(w, r, month)
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format != "" && format != "json" && format != "pdf" && format != "html" {
+		http.Error(w, "format must be json, pdf or html", http.StatusBadRequest)
+		return
+	}
+	var jobID int64
+	var statsJSON, html string
+	var doc []byte
+	var generatedAt time.Time
+	err = queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&jobID, &statsJSON, &html, &doc, &generatedAt)
+	}, `SELECT job_id, stats_json, html, pdf, generated_at FROM monthly_reports WHERE month = ?`, month)
+	if errors.Is(err, sql.ErrNoRows) {
+		if running, err := s.runningMonthlyReport(r.Context(), month); err == nil && running > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			respondJSON(w, map[string]interface{}{"month": month, "job_id": running, "status_url": fmt.Sprintf("/ops/jobs/%d", running)})
+			return
+		}
+		http.Error(w, "report not generated", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("monthly report %s load failed: %v", month, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	switch format {
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "county-report-"+month+".pdf"))
+		w.Write(doc)
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(html))
+	default:
+		var stats monthlyReport
+		if err := json.Unmarshal([]byte(statsJSON), &stats); err != nil {
+			http.Error(w, "stored report is invalid", http.StatusInternalServerError)
+			return
+		}
+		base := "/api/reports/monthly/" + month
+		respondJSON(w, monthlyReportResponse{Month: month, JobID: jobID, GeneratedAt: generatedAt, PDFURL: base + "?format=pdf", HTMLURL: base + "?format=html", Stats: &stats})
+	}
+}
+
+// startMonthlyReport queues generation of month unless a job for it is
+// already running, in which case that job is returned.
+func (s *server) startMonthlyReport(w http.ResponseWriter, r *http.Request, month string) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := s.runningMonthlyReport(r.Context(), month)
+	if err != nil {
+		log.Printf("monthly report job lookup failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if id == 0 {
+		if id, err = s.createOpsJob(opsJobKindMonthlyReport, monthlyReportParams{Month: month}, 1); err != nil {
+			log.Printf("monthly report create failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		go s.runMonthlyReport(s.ctx, id, month)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	respondJSON(w, map[string]interface{}{
+		"month":      month,
+		"job_id":     id,
+		"status_url": fmt.Sprintf("/ops/jobs/%d", id),
+		"stream_url": fmt.Sprintf("/ops/jobs/%d/stream", id),
+		"report_url": "/api/reports/monthly/" + month,
+	})
+}
+
+func (s *server) runningMonthlyReport(ctx context.Context, month string) (int64, error) {
+	params, _ := json.Marshal(monthlyReportParams{Month: month})
+	var id int64
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM ops_jobs WHERE kind = ? AND status = ? AND params_json = ? ORDER BY id DESC LIMIT 1`, opsJobKindMonthlyReport, opsJobRunning, string(params)).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// resumeMonthlyReports restarts report jobs a previous process left running.
+// Generation has no checkpoints, so they start over.
+func (s *server) resumeMonthlyReports(ctx context.Context) {
+	rows, err := queryWithRetry(s.db, `SELECT id, params_json FROM ops_jobs WHERE status = ? AND kind = ?`, opsJobRunning, opsJobKindMonthlyReport)
+	if err != nil {
+		log.Printf("monthly report resume query failed: %v", err)
+		return
+	}
+	pending := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var raw string
+		var p monthlyReportParams
+		if rows.Scan(&id, &raw) == nil && json.Unmarshal([]byte(raw), &p) == nil {
+			pending[id] = p.Month
+		}
+	}
+	rows.Close()
+	for id, month := range pending {
+		log.Printf("resuming monthly report job %d for %s", id, month)
+		go s.runMonthlyReport(ctx, id, month)
+	}
+}
+
+func (s *server) runMonthlyReport(ctx context.Context, jobID int64, month string) {
+	if err := s.generateMonthlyReport(ctx, jobID, month); err != nil {
+		log.Printf("monthly report job %d for %s failed: %v", jobID, month, err)
+		if ctx.Err() == nil {
+			s.finishOpsJob(jobID, opsJobFailed, err.Error())
+		}
+		return
+	}
+	if _, err := execWithRetry(s.db, `UPDATE ops_jobs SET processed = 1, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, jobID); err != nil {
+		log.Printf("ops job %d checkpoint failed: %v", jobID, err)
+	}
+	s.finishOpsJob(jobID, opsJobCompleted, "")
+}
+
+func (s *server) generateMonthlyReport(ctx context.Context, jobID int64, month string) error {
+	from, err := time.ParseInLocation(trendMonthLayout, month, s.tz)
+	if err != nil {
+		return err
+	}
+	report, err := s.compileMonthlyReport(ctx, from)
+	if err != nil {
+		return err
+	}
+	hotspotMap := s.hotspotMap(ctx, report.Hotspots)
+	var html bytes.Buffer
+	if err := renderMonthlyReportHTML(&html, report, hotspotMap); err != nil {
+		return fmt.Errorf("html: %w", err)
+	}
+	var doc bytes.Buffer
+	if _, err := s.renderMonthlyReportPDF(report, hotspotMap).WriteTo(&doc); err != nil {
+		return fmt.Errorf("pdf: %w", err)
+	}
+	stats, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = execWithRetry(s.db, `INSERT INTO monthly_reports (month, job_id, stats_json, html, pdf, generated_at) VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(month) DO UPDATE SET job_id = excluded.job_id, stats_json = excluded.stats_json, html = excluded.html, pdf = excluded.pdf, generated_at = excluded.generated_at`,
+		month, jobID, string(stats), html.String(), doc.Bytes(), report.GeneratedAt)
+	return err
+}
+
+func (s *server) compileMonthlyReport(ctx context.Context, from time.Time) (*monthlyReport, error) {
+	to := from.AddDate(0, 1, 0)
+	now := time.Now()
+	report := &monthlyReport{Month: from.Format(trendMonthLayout), From: from, To: to, Partial: to.After(now), GeneratedAt: now.UTC()}
+
+	records, err := s.store.Select(ctx, `WHERE status = ? AND duplicate_of IS NULL AND effective_ts >= ? AND effective_ts < ?`, statusDone, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	daily := make(map[string]int)
+	agencies := make(map[string]int)
+	towns := make(map[string]int)
+	callTypes := make(map[string]int)
+	hotspots := make(map[string]*hotspotSummary)
+	var audioSeconds float64
+	for _, t := range records {
+		if s.isRestricted(t) {
+			continue
+		}
+		report.Calls++
+		meta, _ := s.parseCallMetadata(t.Filename, "")
+		callTime := t.CreatedAt
+		if t.CallTimestamp != nil {
+			callTime = *t.CallTimestamp
+		}
+		daily[callTime.In(s.tz).Format(trendDayLayout)]++
+		agencies[fallbackEmpty(strings.TrimSpace(meta.AgencyDisplay), "Unknown")]++
+		towns[fallbackEmpty(strings.TrimSpace(meta.TownDisplay), "Unknown")]++
+		callTypes[fallbackEmpty(strings.ToLower(strings.TrimSpace(derefString(t.CallType, meta.CallType))), "unknown")]++
+		if t.DurationSeconds != nil {
+			audioSeconds += *t.DurationSeconds
+		}
+		if label := strings.TrimSpace(derefString(t.LocationLabel, "")); label != "" && t.Latitude != nil && t.Longitude != nil {
+			h := hotspots[label]
+			if h == nil {
+				h = &hotspotSummary{Label: label, Latitude: *t.Latitude, Longitude: *t.Longitude}
+				hotspots[label] = h
+			}
+			h.Count++
+		}
+	}
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		day := d.Format(trendDayLayout)
+		report.Daily = append(report.Daily, monthlyDay{Day: day, Calls: daily[day]})
+	}
+	report.Agencies = topMonthlyCounts(agencies)
+	report.Towns = topMonthlyCounts(towns)
+	report.CallTypes = topMonthlyCounts(callTypes)
+	for _, h := range hotspots {
+		report.Hotspots = append(report.Hotspots, *h)
+	}
+	sort.Slice(report.Hotspots, func(i, j int) bool {
+		if report.Hotspots[i].Count != report.Hotspots[j].Count {
+			return report.Hotspots[i].Count > report.Hotspots[j].Count
+		}
+		return report.Hotspots[i].Label < report.Hotspots[j].Label
+	})
+	if len(report.Hotspots) > monthlyReportTopN {
+		report.Hotspots = report.Hotspots[:monthlyReportTopN]
+	}
+	report.AudioMinutes = math.Round(audioSeconds/60*10) / 10
+
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(calls), 0) FROM stats_monthly WHERE month = ?`, from.AddDate(0, -1, 0).Format(trendMonthLayout)).Scan(&report.PreviousMonthCalls); err != nil {
+		return nil, err
+	}
+	if report.PreviousMonthCalls > 0 {
+		pct := math.Round(float64(report.Calls-report.PreviousMonthCalls)/float64(report.PreviousMonthCalls)*1000) / 10
+		report.ChangePct = &pct
+	}
+	if err := s.db.QueryRowContext(ctx, `SELECT
+    COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+    COALESCE(SUM(CASE WHEN status = ? AND duplicate_of IS NOT NULL THEN 1 ELSE 0 END), 0)
+FROM transcriptions WHERE effective_ts >= ? AND effective_ts < ?`, statusError, statusDone, from.UTC(), to.UTC()).Scan(&report.Errors, &report.Duplicates); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT processing_seconds FROM transcriptions WHERE status = ? AND duplicate_of IS NULL AND processing_seconds IS NOT NULL AND effective_ts >= ? AND effective_ts < ? ORDER BY processing_seconds`, statusDone, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var latencies []float64
+	for rows.Next() {
+		var v float64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(latencies) > 0 {
+		var sum float64
+		for _, v := range latencies {
+			sum += v
+		}
+		avg := math.Round(sum/float64(len(latencies))*10) / 10
+		p90 := math.Round(latencies[int(math.Ceil(0.9*float64(len(latencies))))-1]*10) / 10
+		report.AvgProcessingSeconds, report.P90ProcessingSeconds = &avg, &p90
+	}
+	return report, nil
+}
+
+// topMonthlyCounts returns the largest counts, ties by name.
+func topMonthlyCounts(counts map[string]int) []monthlyCount {
+	out := make([]monthlyCount, 0, len(counts))
+	for name, n := range counts {
+		out = append(out, monthlyCount{Name: name, Calls: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Calls != out[j].Calls {
+			return out[i].Calls > out[j].Calls
+		}
+		return out[i].Name < out[j].Name
+	})
+	if len(out) > monthlyReportTopN {
+		out = out[:monthlyReportTopN]
+	}
+	return out
+}
+
+// hotspotMap is a static map with a numbered pin per hotspot, or nil without
+// hotspots or a Mapbox token.
+func (s *server) hotspotMap(ctx context.Context, hotspots []hotspotSummary) []byte {
+	if len(hotspots) == 0 {
+		return nil
+	}
+	pins := make([]string, len(hotspots))
+	for i, h := range hotspots {
+		pins[i] = fmt.Sprintf("pin-s-%d+d33(%.5f,%.5f)", i+1, h.Longitude, h.Latitude)
+	}
+	view := "auto"
+	if len(hotspots) == 1 {
+		view = fmt.Sprintf("%.5f,%.5f,12,0", hotspots[0].Longitude, hotspots[0].Latitude)
+	}
+	return s.staticMapJPEG(ctx, strings.Join(pins, ","), view, monthlyReportMapWidth, monthlyReportMapHeight, nil)
+}
+
+func (r *monthlyReport) Title() string {
+	return "Sussex County call report – " + r.From.Format("January 2006")
+}
+
+func (r *monthlyReport) BusiestDay() monthlyDay {
+	var best monthlyDay
+	for _, d := range r.Daily {
+		if d.Calls > best.Calls {
+			best = d
+		}
+	}
+	return best
+}
+
+func (r *monthlyReport) MaxDaily() int {
+	return r.BusiestDay().Calls
+}
+
+func formatSeconds(v *float64) string {
+	if v == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1fs", *v)
+}
+
+var monthlyReportPage = template.Must(template.New("monthly").Funcs(template.FuncMap{
+	"seconds": formatSeconds,
+	"pct": func(v *float64) string {
+		if v == nil {
+			return "n/a"
+		}
+		return fmt.Sprintf("%+.1f%%", *v)
+	},
+	"barHeight": func(n, max int) int {
+		if max == 0 {
+			return 0
+		}
+		return n * 120 / max
+	},
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Report.Title}}</title>
+<style>
+body{margin:24px auto;max-width:860px;font:14px/1.45 system-ui,sans-serif;color:#1f2933}
+h1{font-size:22px;margin:0 0 4px}
+h2{font-size:16px;margin:24px 0 8px;border-bottom:1px solid #e4e7eb;padding-bottom:4px}
+.m{color:#616e7c}
+.kpis{display:grid;grid-template-columns:repeat(4,1fr);gap:12px;margin-top:16px}
+.kpi{border:1px solid #e4e7eb;border-radius:6px;padding:10px}
+.kpi b{display:block;font-size:20px}
+.bars{display:flex;align-items:flex-end;gap:2px;height:124px;border-bottom:1px solid #9aa5b1}
+.bars div{flex:1;background:#3f8bd6;min-height:1px}
+table{border-collapse:collapse;width:100%}
+td,th{text-align:left;padding:4px 8px;border-bottom:1px solid #f0f2f4}
+td.n,th.n{text-align:right}
+.cols{display:grid;grid-template-columns:repeat(3,1fr);gap:16px}
+img{max-width:100%;border:1px solid #e4e7eb}
+</style>
+</head>
+<body>
+<h1>{{.Report.Title}}</h1>
+<div class="m">{{.Report.From.Format "Jan 2, 2006"}} – {{(.Report.To.AddDate 0 0 -1).Format "Jan 2, 2006"}}{{if .Report.Partial}} (month in progress){{end}} · generated {{.Generated}}</div>
+<div class="kpis">
+<div class="kpi"><b>{{.Report.Calls}}</b>calls ({{pct .Report.ChangePct}} vs. previous month)</div>
+<div class="kpi"><b>{{.Report.AudioMinutes}}</b>minutes of audio</div>
+<div class="kpi"><b>{{seconds .Report.AvgProcessingSeconds}}</b>average processing (p90 {{seconds .Report.P90ProcessingSeconds}})</div>
+<div class="kpi"><b>{{.Report.Errors}}</b>failed, {{.Report.Duplicates}} duplicates</div>
+</div>
+<h2>Daily volume</h2>
+<div class="bars">{{$max := .Report.MaxDaily}}{{range .Report.Daily}}<div title="{{.Day}}: {{.Calls}}" style="height:{{barHeight .Calls $max}}px"></div>{{end}}</div>
+<div class="m">Busiest day: {{.Busiest.Day}} ({{.Busiest.Calls}} calls)</div>
+<div class="cols">
+<div><h2>Busiest agencies</h2><table>{{range .Report.Agencies}}<tr><td>{{.Name}}</td><td class="n">{{.Calls}}</td></tr>{{end}}</table></div>
+<div><h2>Towns</h2><table>{{range .Report.Towns}}<tr><td>{{.Name}}</td><td class="n">{{.Calls}}</td></tr>{{end}}</table></div>
+<div><h2>Call types</h2><table>{{range .Report.CallTypes}}<tr><td>{{.Name}}</td><td class="n">{{.Calls}}</td></tr>{{end}}</table></div>
+</div>
+<h2>Hotspots</h2>
+{{if .Map}}<img src="{{.Map}}" alt="Hotspot map">{{end}}
+{{if .Report.Hotspots}}<table><tr><th>#</th><th>Location</th><th class="n">Calls</th></tr>{{range $i, $h := .Report.Hotspots}}<tr><td>{{inc $i}}</td><td>{{$h.Label}}</td><td class="n">{{$h.Count}}</td></tr>{{end}}</table>{{else}}<p class="m">No geocoded calls this month.</p>{{end}}
+</body>
+</html>
+`))
+
+func renderMonthlyReportHTML(w *bytes.Buffer, report *monthlyReport, hotspotMap []byte) error {
+	data := struct {
+		Report    *monthlyReport
+		Busiest   monthlyDay
+		Generated string
+		Map       template.URL
+	}{
+		Report:    report,
+		Busiest:   report.BusiestDay(),
+		Generated: report.GeneratedAt.In(report.From.Location()).Format("Jan 2, 2006 3:04 PM MST"),
+	}
+	if hotspotMap != nil {
+		data.Map = template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(hotspotMap))
+	}
+	return monthlyReportPage.Execute(w, data)
+}
+
+func (s *server) renderMonthlyReportPDF(report *monthlyReport, hotspotMap []byte) *pdf.Document {
+	const (
+		margin = 40
+		width  = float64(pdf.PageWidth - 2*margin)
+	)
+	doc := pdf.New(report.Title())
+	page := doc.AddPage()
+	page.FillRect(0, 0, pdf.PageWidth, 28, 0.9)
+	page.Text(margin, 18, 9, true, "SUSSEX COUNTY ALERTS")
+	page.Text(pdf.PageWidth-margin-pdf.Width("MONTHLY REPORT", 9, true), 18, 9, true, "MONTHLY REPORT")
+	page.Text(margin, 62, 18, true, report.Title())
+	period := report.From.Format("Jan 2, 2006") + " – " + report.To.AddDate(0, 0, -1).Format("Jan 2, 2006")
+	if report.Partial {
+		period += " (month in progress)"
+	}
+	page.Text(margin, 78, 9, false, period+" • generated "+report.GeneratedAt.In(s.tz).Format("Jan 2, 2006 3:04 PM MST"))
+
+	change := "n/a"
+	if report.ChangePct != nil {
+		change = fmt.Sprintf("%+.1f%%", *report.ChangePct)
+	}
+	kpis := [][2]string{
+		{fmt.Sprintf("%d", report.Calls), "calls (" + change + " vs. prior month)"},
+		{fmt.Sprintf("%.1f", report.AudioMinutes), "minutes of audio"},
+		{formatSeconds(report.AvgProcessingSeconds), "avg processing (p90 " + formatSeconds(report.P90ProcessingSeconds) + ")"},
+		{fmt.Sprintf("%d", report.Errors), fmt.Sprintf("failed, %d duplicates", report.Duplicates)},
+	}
+	boxWidth := (width - 3*8) / 4
+	for i, k := range kpis {
+		x := margin + float64(i)*(boxWidth+8)
+		page.FillRect(x, 92, boxWidth, 44, 0.95)
+		page.Text(x+8, 114, 15, true, k[0])
+		page.Text(x+8, 128, 7, false, k[1])
+	}
+
+	y := 160.0
+	page.Text(margin, y, 11, true, "Daily volume")
+	const chartHeight = 80
+	chartTop := y + 8
+	maxDaily := report.MaxDaily()
+	if n := len(report.Daily); n > 0 && maxDaily > 0 {
+		barWidth := float64(width) / float64(n)
+		for i, d := range report.Daily {
+			h := float64(d.Calls) / float64(maxDaily) * chartHeight
+			page.FillRect(margin+float64(i)*barWidth+0.5, chartTop+chartHeight-h, barWidth-1, h, 0.45)
+		}
+	}
+	page.Line(margin, chartTop+chartHeight, margin+width, chartTop+chartHeight, 0.5, 0.4)
+	busiest := report.BusiestDay()
+	page.Text(margin, chartTop+chartHeight+12, 8, false, fmt.Sprintf("Busiest day: %s (%d calls)", fallbackEmpty(busiest.Day, "none"), busiest.Calls))
+
+	y = chartTop + chartHeight + 36
+	colWidth := (width - 2*16) / 3
+	tables := []struct {
+		title string
+		rows  []monthlyCount
+	}{{"Busiest agencies", report.Agencies}, {"Towns", report.Towns}, {"Call types", report.CallTypes}}
+	tableBottom := y
+	for i, t := range tables {
+		x := margin + float64(i)*(colWidth+16)
+		page.Text(x, y, 11, true, t.title)
+		page.Line(x, y+4, x+colWidth, y+4, 0.5, 0.6)
+		ty := y + 4
+		for _, row := range t.rows {
+			ty += 12
+			count := fmt.Sprintf("%d", row.Calls)
+			page.Text(x, ty, 8.5, false, fitText(row.Name, 8.5, colWidth-pdf.Width(count, 8.5, false)-8))
+			page.Text(x+colWidth-pdf.Width(count, 8.5, false), ty, 8.5, false, count)
+		}
+		if ty > tableBottom {
+			tableBottom = ty
+		}
+	}
+
+	y = tableBottom + 28
+	page.Text(margin, y, 11, true, "Hotspots")
+	y += 8
+	if hotspotMap != nil && page.JPEG(margin, y, monthlyReportMapWidth, monthlyReportMapHeight, hotspotMap) == nil {
+		y += monthlyReportMapHeight + 4
+	}
+	if len(report.Hotspots) == 0 {
+		page.Text(margin, y+12, 8.5, false, "No geocoded calls this month.")
+	}
+	for i, h := range report.Hotspots {
+		y += 12
+		if y > pdf.PageHeight-48 {
+			break
+		}
+		count := fmt.Sprintf("%d", h.Count)
+		page.Text(margin, y, 8.5, true, fmt.Sprintf("%d.", i+1))
+		page.Text(margin+18, y, 8.5, false, fitText(h.Label, 8.5, width-60))
+		page.Text(margin+width-pdf.Width(count, 8.5, false), y, 8.5, false, count)
+	}
+	page.Line(margin, pdf.PageHeight-34, pdf.PageWidth-margin, pdf.PageHeight-34, 0.5, 0.6)
+	page.Text(margin, pdf.PageHeight-22, 7.5, false, "Completed, non-duplicate calls; restricted calls are excluded. Processing time covers transcription through geocoding.")
+	return doc
+}
+
+// fitText shortens s with an ellipsis to fit width points.
+func fitText(s string, size, width float64) string {
+	if pdf.Width(s, size, false) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdf.Width(string(runes)+"…", size, false) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,195 @@
+// Package mqtt publishes single messages to an MQTT 3.1.1 broker. Each
+// Publish opens a connection, sends one QoS 0 message and disconnects, which
+// is all alert routing needs and keeps a client library out of the build.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	packetConnect    = 0x10
+	packetConnAck    = 0x20
+	packetPublish    = 0x30
+	packetDisconnect = 0xe0
+
+	keepAliveSec = 30
+)
+
+// Broker is where messages are published. URL is tcp://, mqtt://, ssl://,
+// tls:// or mqtts://host[:port]; credentials in the URL are used when
+// Username is empty.
+type Broker struct {
+	URL      string
+	Username string
+	Password string
+	ClientID string
+}
+
+// ParseURL checks a broker URL and returns the address to dial and whether
+// to use TLS.
+func ParseURL(raw string) (addr string, useTLS bool, err error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", false, fmt.Errorf("invalid mqtt broker url %q", raw)
+	}
+	port := "1883"
+	switch strings.ToLower(u.Scheme) {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS, port = true, "8883"
+	default:
+		return "", false, fmt.Errorf("unsupported mqtt scheme %q", u.Scheme)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// Publish sends payload to topic with QoS 0.
+func (b Broker) Publish(ctx context.Context, topic string, payload []byte, retain bool) error {
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return fmt.Errorf("invalid mqtt topic %q", topic)
+	}
+	addr, useTLS, err := ParseURL(b.URL)
+	if err != nil {
+		return err
+	}
+	username, password := b.Username, b.Password
+	if u, _ := url.Parse(b.URL); username == "" && u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("mqtt dial: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	clientID := b.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("alert-framework-%d", time.Now().UnixNano()%1e9)
+	}
+	if _, err := conn.Write(connectPacket(clientID, username, password)); err != nil {
+		return fmt.Errorf("mqtt connect: %w", err)
+	}
+	if err := readConnAck(bufio.NewReader(conn)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(publishPacket(topic, payload, retain)); err != nil {
+		return fmt.Errorf("mqtt publish: %w", err)
+	}
+	conn.Write([]byte{packetDisconnect, 0})
+	return nil
+}
+
+func connectPacket(clientID, username, password string) []byte {
+	flags := byte(0x02) // clean session
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, flags, 0, keepAliveSec)
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+		if password != "" {
+			body = appendString(body, password)
+		}
+	}
+	return packet(packetConnect, body)
+}
+
+func publishPacket(topic string, payload []byte, retain bool) []byte {
+	header := byte(packetPublish)
+	if retain {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	return packet(header, append(body, payload...))
+}
+
+func readConnAck(r *bufio.Reader) error {
+	header, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("mqtt connack: %w", err)
+	}
+	if header&0xf0 != packetConnAck {
+		return fmt.Errorf("mqtt connack: unexpected packet 0x%02x", header)
+	}
+	length, err := readLength(r)
+	if err != nil || length != 2 {
+		return errors.New("mqtt connack: malformed packet")
+	}
+	var ack [2]byte
+	if _, err := io.ReadFull(r, ack[:]); err != nil {
+		return fmt.Errorf("mqtt connack: %w", err)
+	}
+	if ack[1] != 0 {
+		return fmt.Errorf("mqtt connection refused (code %d)", ack[1])
+	}
+	return nil
+}
+
+func packet(header byte, body []byte) []byte {
+	out := []byte{header}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		out = append(out, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func readLength(r *bufio.Reader) (int, error) {
+	n, mult := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(b&0x7f) * mult
+		if b&0x80 == 0 {
+			return n, nil
+		}
+		mult *= 128
+	}
+	return 0, errors.New("mqtt: malformed remaining length")
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,118 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBroker accepts one connection, answers CONNECT with returnCode and
+// reports the packets it read.
+func fakeBroker(t *testing.T, returnCode byte) (string, <-chan [][]byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan [][]byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		var packets [][]byte
+		for {
+			header, err := r.ReadByte()
+			if err != nil {
+				break
+			}
+			n, err := readLength(r)
+			if err != nil {
+				break
+			}
+			body := make([]byte, n)
+			if _, err := io.ReadFull(r, body); err != nil {
+				break
+			}
+			packets = append(packets, append([]byte{header}, body...))
+			if header == packetConnect {
+				conn.Write([]byte{packetConnAck, 2, 0, returnCode})
+			}
+			if header == packetDisconnect || returnCode != 0 {
+				break
+			}
+		}
+		got <- packets
+	}()
+	return "tcp://" + ln.Addr().String(), got
+}
+
+func TestPublish(t *testing.T) {
+	url, got := fakeBroker(t, 0)
+	b := Broker{URL: url, Username: "alerts", Password: "pw", ClientID: "test"}
+	if err := b.Publish(context.Background(), "alerts/fire", []byte(`{"id":1}`), true); err != nil {
+		t.Fatal(err)
+	}
+	packets := <-got
+	if len(packets) != 3 {
+		t.Fatalf("got %d packets", len(packets))
+	}
+	connect := string(packets[0])
+	if packets[0][0] != packetConnect || !strings.Contains(connect, "MQTT") || !strings.Contains(connect, "test") || !strings.Contains(connect, "alerts") || !strings.Contains(connect, "pw") {
+		t.Fatalf("connect = %q", connect)
+	}
+	if packets[1][0] != packetPublish|0x01 || string(packets[1][1:]) != "\x00\x0balerts/fire"+`{"id":1}` {
+		t.Fatalf("publish = %q", packets[1])
+	}
+	if packets[2][0] != packetDisconnect {
+		t.Fatalf("last packet = %q", packets[2])
+	}
+}
+
+func TestPublishRefused(t *testing.T) {
+	url, _ := fakeBroker(t, 5)
+	err := Broker{URL: url}.Publish(context.Background(), "alerts", nil, false)
+	if err == nil || !strings.Contains(err.Error(), "refused (code 5)") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	cases := map[string]struct {
+		addr string
+		tls  bool
+	}{
+		"tcp://broker.local":       {"broker.local:1883", false},
+		"mqtts://broker.local":     {"broker.local:8883", true},
+		"ssl://u:p@broker.local:9": {"broker.local:9", true},
+	}
+	for raw, want := range cases {
+		addr, useTLS, err := ParseURL(raw)
+		if err != nil || addr != want.addr || useTLS != want.tls {
+			t.Errorf("%s: got %s %v %v", raw, addr, useTLS, err)
+		}
+	}
+	for _, raw := range []string{"", "http://broker", "broker:1883"} {
+		if _, _, err := ParseURL(raw); err == nil {
+			t.Errorf("%s: expected error", raw)
+		}
+	}
+}
+
+func TestRemainingLength(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097151} {
+		p := packet(packetPublish, make([]byte, n))
+		got, err := readLength(bufio.NewReader(strings.NewReader(string(p[1:]))))
+		if err != nil || got != n {
+			t.Errorf("length %d: got %d %v", n, got, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/fingerprint"
+)
+
+// maxFingerprintOffset is how far apart two receivers may have started
+// recording the same transmission.
+const maxFingerprintOffset = 3 * time.Second
+
+// audioFingerprint decodes path to mono PCM with ffmpeg and fingerprints it.
+func audioFingerprint(ctx context.Context, path string) ([]uint32, error) {
+	ffmpegBin := strings.TrimSpace(ffmpegBinary)
+	if ffmpegBin == "" {
+		ffmpegBin = "ffmpeg"
+	}
+	cmd := exec.CommandContext(ctx, ffmpegBin,
+		"-v", "error",
+		"-i", path,
+		"-t", strconv.Itoa(fingerprint.MaxSeconds),
+		"-ac", "1",
+		"-ar", strconv.Itoa(fingerprint.SampleRate),
+		"-f", "s16le", "-",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("decode for fingerprint: %w (stderr: %s)", err, strings.TrimSpace(stderr.String()))
+	}
+	pcm := stdout.Bytes()
+	samples := make([]int16, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(pcm[2*i:]))
+	}
+	return fingerprint.Compute(samples), nil
+}
+
+func (s *server) storeFingerprint(filename string, fp []uint32) {
+	if _, err := execWithRetry(s.db, `UPDATE transcriptions SET fingerprint=? WHERE filename=?`, fingerprint.Encode(fp), filename); err != nil {
+		log.Printf("store fingerprint for %s: %v", filename, err)
+	}
+}
+
+// findNearDuplicate returns the completed call within FINGERPRINT_WINDOW_SEC
+// of callTime whose fingerprint best matches fp, if it clears
+// FINGERPRINT_MATCH_THRESHOLD. Calls that are themselves duplicates are
+// skipped so every copy links to the same original.
+func (s *server) findNearDuplicate(filename string, fp []uint32, callTime time.Time) (string, float64) {
+	if len(fp) == 0 || s.cfg.FingerprintThreshold <= 0 {
+		return "", 0
+	}
+	window := time.Duration(s.cfg.FingerprintWindowSec) * time.Second
+	rows, err := queryWithRetry(s.db, `SELECT filename, fingerprint FROM transcriptions WHERE filename != ? AND status = ? AND duplicate_of IS NULL AND fingerprint IS NOT NULL AND call_timestamp BETWEEN ? AND ?`, filename, statusDone, callTime.Add(-window), callTime.Add(window))
+	if err != nil {
+		log.Printf("near-duplicate query failed for %s: %v", filename, err)
+		return "", 0
+	}
+	defer rows.Close()
+	maxOffset := int(maxFingerprintOffset.Seconds() / fingerprint.FrameSeconds)
+	var best string
+	var bestScore float64
+	for rows.Next() {
+		var name string
+		var encoded sql.NullString
+		if err := rows.Scan(&name, &encoded); err != nil {
+			continue
+		}
+		other, err := fingerprint.Decode(encoded.String)
+		if err != nil {
+			continue
+		}
+		if score := fingerprint.Similarity(fp, other, maxOffset); score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+	if bestScore < s.cfg.FingerprintThreshold {
+		return "", bestScore
+	}
+	return best, bestScore
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"alert_framework/breaker"
+	"alert_framework/formatting"
+	"alert_framework/usage"
+)
+
+// errTranscriptionDeferred marks a job that was put back on the queue because
+// OpenAI is unavailable. It is not a transcription failure.
+var errTranscriptionDeferred = errors.New("transcription deferred: openai unavailable")
+
+// openAIStatusError is returned for non-2xx OpenAI responses so callers can tell
+// outages (5xx/429) apart from request errors.
+type openAIStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *openAIStatusError) Error() string {
+	return fmt.Sprintf("openai status %d: %s", e.StatusCode, e.Body)
+}
+
+// OpenAIBreakerDebug is the breaker view surfaced on /debug/queue.
+type OpenAIBreakerDebug struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Threshold           int        `json:"threshold"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	Trips               int64      `json:"trips"`
+}
+
+// isOpenAIOutage reports whether err looks like OpenAI being down rather than a
+// problem with the request itself. A spent budget is not an outage.
+func isOpenAIOutage(err error) bool {
+	if err == nil || errors.Is(err, usage.ErrBudgetExceeded) {
+		return false
+	}
+	var statusErr *openAIStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func (s *server) initOpenAIBreaker() {
+	cooldown := time.Duration(s.cfg.OpenAIBreakerCooldownSec) * time.Second
+	s.openAI = breaker.New(s.cfg.OpenAIBreakerThreshold, cooldown, func(from, to breaker.State) {
+		switch to {
+		case breaker.Open:
+			if from == breaker.Closed {
+				log.Printf("openai circuit open after %d consecutive failures; pausing transcription queue", s.cfg.OpenAIBreakerThreshold)
+			}
+			if s.queue != nil {
+				s.queue.Pause(queuePauseOpenAI)
+			}
+		case breaker.Closed:
+			log.Printf("openai circuit closed; resuming transcription queue")
+			if s.queue != nil {
+				s.queue.Resume(queuePauseOpenAI)
+			}
+		}
+	})
+}
+
+// recordOpenAIResult feeds a transcription outcome into the breaker. Errors that
+// prove the API answered (bad request, empty transcript) count as reachability.
+func (s *server) recordOpenAIResult(err error) {
+	if s.openAI == nil {
+		return
+	}
+	if isOpenAIOutage(err) {
+		s.openAI.RecordFailure(err)
+		return
+	}
+	s.openAI.RecordSuccess()
+}
+
+func (s *server) openAIAvailable() bool {
+	return s.openAI == nil || s.openAI.Allow()
+}
+
+// startOpenAIProbe periodically checks an open breaker and closes it once the API answers again.
+func (s *server) startOpenAIProbe(ctx context.Context) {
+	if s.openAI == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+				if !s.openAI.TryProbe() {
+					continue
+				}
+				probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				err := s.probeOpenAI(probeCtx)
+				cancel()
+				if isOpenAIOutage(err) {
+					log.Printf("openai probe failed: %v", err)
+					s.openAI.RecordFailure(err)
+					continue
+				}
+				s.openAI.RecordSuccess()
+			}
+		}
+	}()
+}
+
+func (s *server) probeOpenAI(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.LLM.URL("/v1/models"), nil)
+	if err != nil {
+		return err
+	}
+	if apiKey := strings.TrimSpace(os.Getenv("OPENAI_API_KEY")); apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return &openAIStatusError{StatusCode: resp.StatusCode, Body: resp.Status}
+	}
+	return nil
+}
+
+func (s *server) openAIBreakerDebug() *OpenAIBreakerDebug {
+	if s.openAI == nil {
+		return nil
+	}
+	snap := s.openAI.Snapshot()
+	out := &OpenAIBreakerDebug{
+		State:               string(snap.State),
+		ConsecutiveFailures: snap.ConsecutiveFailures,
+		Threshold:           snap.Threshold,
+		LastError:           snap.LastError,
+		Trips:               snap.Trips,
+	}
+	if !snap.OpenedAt.IsZero() {
+		ts := snap.OpenedAt.UTC()
+		out.OpenedAt = &ts
+	}
+	return out
+}
+
+// requeueDeferred puts a deferred job back on the (paused) queue so it runs once
+// OpenAI recovers.
+func (s *server) requeueDeferred(job processJob) {
+	job.force = true
+	if job.sendGroupMe {
+		job.pendingAlertSent = true
+	}
+	go func() {
+		enqueued, _ := s.enqueuePayload(context.Background(), job)
+		if !enqueued {
+			log.Printf("failed to requeue deferred job %s; leaving status queued", job.filename)
+		}
+	}()
+}
+
+func (s *server) markDeferred(filename string, cause error) {
+	if err := s.store.transition(s.ctx, filename, statusQueued, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE transcriptions SET status=?, last_error=?, error_code=? WHERE filename=?`, statusQueued, cause.Error(), errorCodeFor(cause), filename)
+		return err
+	}); err != nil {
+		log.Printf("failed to mark deferred: %v", err)
+	}
+}
+
+// notifyTranscriptPending sends the minimal filename-based alert used while
+// transcription is paused.
+func (s *server) notifyTranscriptPending(job processJob) {
+	if len(s.restrictedFlags(job.meta.CallType, "")) > 0 {
+		return
+	}
+	listenURL := strings.TrimSpace(job.publicURL)
+	if listenURL == "" {
+		listenURL = formatting.BuildListenURL(job.filename)
+	}
+	callTime := job.meta.DateTime
+	if callTime.IsZero() {
+		callTime = time.Now().In(s.tz)
+	}
+	incident := s.buildIncidentDetails(job.meta, nil, nil, nil, nil, callTime, job.filename, listenURL, "")
+	header := formatting.FormatIncidentHeader(incident)
+	location := formatting.FormatIncidentLocation(incident)
+	body := fmt.Sprintf("%s\n%s\n🎧 Audio only – transcript pending\nListen: %s", strings.TrimSpace(header), strings.TrimSpace(location), listenURL)
+	if err := s.sendGroupMe(body); err != nil {
+		log.Printf("groupme pending alert failed: %v", err)
+		return
+	}
+	s.markAlerted(job.filename)
+}
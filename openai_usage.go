@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/config"
+)
+
+const (
+	defaultUsageDays = 30
+	maxUsageDays     = 366
+)
+
+// openAIHosts lists the hosts the configured OpenAI-compatible endpoints live
+// on, so the usage meter can leave other traffic (GroupMe, Mapbox, webhooks)
+// alone.
+func openAIHosts(cfg config.Config) []string {
+	bases := []string{
+		cfg.LLM.URL(""),
+		cfg.LLM.Cleanup.URL(""),
+		cfg.LLM.Classify.URL(""),
+		cfg.LLM.Refine.URL(""),
+		cfg.LLM.Redact.URL(""),
+		cfg.LLM.Roles.URL(""),
+		cfg.Rollup.LLMBaseURL,
+	}
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, base := range bases {
+		u, err := url.Parse(strings.TrimSpace(base))
+		if err != nil || u.Host == "" || seen[u.Host] {
+			continue
+		}
+		seen[u.Host] = true
+		hosts = append(hosts, u.Host)
+	}
+	return hosts
+}
+
+// handleUsage reports OpenAI usage per day and stage for the last ?days=
+// (default 30) days, with the month-to-date cost and the monthly budget.
+func (s *server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	days := defaultUsageDays
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxUsageDays {
+			http.Error(w, "days must be between 1 and 366", http.StatusBadRequest)
+			return
+		}
+		days = v
+	}
+	now := time.Now().In(s.tz)
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.tz).AddDate(0, 0, -(days - 1))
+	daily, err := s.usage.Daily(r.Context(), since, s.tz)
+	if err != nil {
+		log.Printf("usage query failed: %v", err)
+		http.Error(w, "usage unavailable", http.StatusInternalServerError)
+		return
+	}
+	monthToDate, err := s.usage.MonthToDate(r.Context())
+	if err != nil {
+		log.Printf("usage month total failed: %v", err)
+		http.Error(w, "usage unavailable", http.StatusInternalServerError)
+		return
+	}
+	var total float64
+	for _, d := range daily {
+		total += d.CostUSD
+	}
+	respondJSON(w, map[string]interface{}{
+		"since":              since.Format(archiveDateLayout),
+		"days":               daily,
+		"total_usd":          total,
+		"month_to_date_usd":  monthToDate,
+		"monthly_budget_usd": s.usage.Budget(),
+		"budget_exceeded":    s.usage.Budget() > 0 && monthToDate >= s.usage.Budget(),
+	})
+}
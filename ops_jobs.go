@@ -0,0 +1,501 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	opsJobKindBulkReprocess = "reprocess_bulk"
+	opsJobKindMonthlyReport = "monthly_report"
+
+	opsJobRunning   = "running"
+	opsJobCompleted = "completed"
+	opsJobFailed    = "failed"
+
+	// Rough OpenAI pricing used for dry-run estimates only.
+	audioCostPerMinute  = 0.006
+	chatCostPer1KTokens = 0.0006
+	promptTokensPerPass = 600
+	charsPerToken       = 4
+)
+
+// llmPassesByStage approximates how many chat completions each reprocess stage triggers per call.
+var llmPassesByStage = map[string]int{
+	reprocessStageTranscribe: 5,
+	reprocessStageRefine:     5,
+	reprocessStageClassify:   1,
+	reprocessStageGeocode:    1,
+	reprocessStageNotify:     0,
+}
+
+type bulkReprocessRequest struct {
+	Window   string `json:"window"`
+	CallType string `json:"call_type"`
+	Status   string `json:"status"`
+	Stage    string `json:"stage"`
+	Limit    int    `json:"limit"`
+	DryRun   bool   `json:"dry_run"`
+}
+
+// bulkReprocessParams is persisted with the job so a resumed run selects the same calls.
+type bulkReprocessParams struct {
+	Since    *time.Time `json:"since,omitempty"`
+	Until    time.Time  `json:"until"`
+	CallType string     `json:"call_type,omitempty"`
+	Status   string     `json:"status"`
+	Stage    string     `json:"stage"`
+	Limit    int        `json:"limit,omitempty"`
+}
+
+type bulkReprocessEstimate struct {
+	DryRun           bool    `json:"dry_run"`
+	Stage            string  `json:"stage"`
+	Count            int     `json:"count"`
+	AudioMinutes     float64 `json:"audio_minutes"`
+	EstimatedTokens  int64   `json:"estimated_tokens"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+}
+
+type opsJobResponse struct {
+	ID         int64           `json:"id"`
+	Kind       string          `json:"kind"`
+	Status     string          `json:"status"`
+	Total      int             `json:"total"`
+	Processed  int             `json:"processed"`
+	Failed     int             `json:"failed"`
+	LastError  string          `json:"last_error,omitempty"`
+	Params     json.RawMessage `json:"params"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+func migrateAddOpsJobs(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS ops_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    params_json TEXT NOT NULL,
+    status TEXT NOT NULL,
+    total INTEGER DEFAULT 0,
+    processed INTEGER DEFAULT 0,
+    failed INTEGER DEFAULT 0,
+    cursor_id INTEGER DEFAULT 0,
+    last_error TEXT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS idx_ops_jobs_status ON ops_jobs(status);`)
+	return err
+}
+
+// handleOpsBulkReprocess estimates (dry_run) or starts a bulk reprocess over
+// calls matching the window/call type/status filters.
+func (s *server) handleOpsBulkReprocess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req bulkReprocessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	req.Stage = strings.ToLower(strings.TrimSpace(req.Stage))
+	if req.Stage == "" {
+		req.Stage = reprocessStageTranscribe
+	}
+	if !validReprocessStage(req.Stage) {
+		http.Error(w, "unknown stage", http.StatusBadRequest)
+		return
+	}
+	params := bulkReprocessParams{
+		Until:    time.Now().UTC(),
+		CallType: strings.TrimSpace(req.CallType),
+		Status:   strings.TrimSpace(req.Status),
+		Stage:    req.Stage,
+		Limit:    req.Limit,
+	}
+	if params.Status == "" {
+		params.Status = statusDone
+	}
+	if _, window := normalizeWindowName(req.Window, "24h"); window > 0 {
+		since := params.Until.Add(-window)
+		params.Since = &since
+	}
+
+	estimate, err := s.estimateBulkReprocess(r.Context(), params)
+	if err != nil {
+		log.Printf("bulk reprocess estimate failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if req.DryRun {
+		estimate.DryRun = true
+		respondJSON(w, estimate)
+		return
+	}
+	if s.queue == nil {
+		http.Error(w, "queue disabled", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := s.createOpsJob(opsJobKindBulkReprocess, params, estimate.Count)
+	if err != nil {
+		log.Printf("bulk reprocess create failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	noteAudit(r, "calls.reprocess_bulk", strconv.FormatInt(id, 10), nil, map[string]interface{}{"params": params, "count": estimate.Count})
+	go s.runBulkReprocess(s.ctx, id, params)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	respondJSON(w, map[string]interface{}{
+		"job_id":     id,
+		"estimate":   estimate,
+		"status_url": fmt.Sprintf("/ops/jobs/%d", id),
+		"stream_url": fmt.Sprintf("/ops/jobs/%d/stream", id),
+	})
+}
+
+// handleOpsJob returns an ops job (GET /ops/jobs/{id}) or streams its progress
+// as server-sent events (GET /ops/jobs/{id}/stream) until it finishes.
+func (s *server) handleOpsJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/ops/jobs/"), "/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	job, err := s.fetchOpsJob(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if len(parts) < 2 || parts[1] != "stream" {
+		respondJSON(w, job)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		data, _ := json.Marshal(job)
+		fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+		flusher.Flush()
+		if job.Status != opsJobRunning {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+		if job, err = s.fetchOpsJob(r.Context(), id); err != nil {
+			return
+		}
+	}
+}
+
+func (p bulkReprocessParams) where() (string, []interface{}) {
+	clauses := []string{"status = ?", "effective_ts <= ?", "deleted_at IS NULL"}
+	args := []interface{}{p.Status, p.Until}
+	if p.Since != nil {
+		clauses = append(clauses, "effective_ts >= ?")
+		args = append(args, *p.Since)
+	}
+	if p.CallType != "" {
+		clauses = append(clauses, "LOWER(COALESCE(call_type, '')) = LOWER(?)")
+		args = append(args, p.CallType)
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+func (s *server) estimateBulkReprocess(ctx context.Context, p bulkReprocessParams) (bulkReprocessEstimate, error) {
+	where, args := p.where()
+	inner := `SELECT duration_seconds, raw_transcript_text, transcript_text FROM transcriptions WHERE ` + where + ` ORDER BY id`
+	if p.Limit > 0 {
+		inner += ` LIMIT ?`
+		args = append(args, p.Limit)
+	}
+	query := `SELECT COUNT(*), COALESCE(SUM(duration_seconds), 0), COALESCE(SUM(LENGTH(COALESCE(raw_transcript_text, transcript_text, ''))), 0) FROM (` + inner + `)`
+	est := bulkReprocessEstimate{Stage: p.Stage}
+	var seconds float64
+	var chars int64
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&est.Count, &seconds, &chars); err != nil {
+		return est, err
+	}
+	passes := int64(llmPassesByStage[p.Stage])
+	est.EstimatedTokens = passes * (chars/charsPerToken + int64(est.Count)*promptTokensPerPass)
+	cost := float64(est.EstimatedTokens) / 1000 * chatCostPer1KTokens
+	if p.Stage == reprocessStageTranscribe {
+		est.AudioMinutes = math.Round(seconds/60*10) / 10
+		cost += seconds / 60 * audioCostPerMinute
+	}
+	est.EstimatedCostUSD = math.Round(cost*100) / 100
+	return est, nil
+}
+
+func (s *server) createOpsJob(kind string, params interface{}, total int) (int64, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return 0, err
+	}
+	res, err := execWithRetry(s.db, `INSERT INTO ops_jobs (kind, params_json, status, total) VALUES (?, ?, ?, ?)`, kind, string(data), opsJobRunning, total)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *server) fetchOpsJob(ctx context.Context, id int64) (opsJobResponse, error) {
+	var job opsJobResponse
+	var params string
+	var lastError sql.NullString
+	var finished sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT id, kind, params_json, status, total, processed, failed, last_error, created_at, updated_at, finished_at FROM ops_jobs WHERE id = ?`, id).
+		Scan(&job.ID, &job.Kind, &params, &job.Status, &job.Total, &job.Processed, &job.Failed, &lastError, &job.CreatedAt, &job.UpdatedAt, &finished)
+	if err != nil {
+		return job, err
+	}
+	job.Params = json.RawMessage(params)
+	job.LastError = lastError.String
+	if finished.Valid {
+		job.FinishedAt = &finished.Time
+	}
+	return job, nil
+}
+
+// resumeOpsJobs restarts bulk jobs, backfills, imports, calls dir migrations
+// and report jobs left running by a previous process.
+func (s *server) resumeOpsJobs(ctx context.Context) {
+	s.resumeMonthlyReports(ctx)
+	rows, err := queryWithRetry(s.db, `SELECT id, kind, params_json FROM ops_jobs WHERE status = ? AND kind IN (?, ?, ?, ?)`, opsJobRunning, opsJobKindBulkReprocess, opsJobKindBackfill, opsJobKindImport, opsJobKindCallsDirMigrate)
+	if err != nil {
+		log.Printf("ops job resume query failed: %v", err)
+		return
+	}
+	type pending struct {
+		id   int64
+		kind string
+		raw  string
+	}
+	var jobs []pending
+	for rows.Next() {
+		var job pending
+		if err := rows.Scan(&job.id, &job.kind, &job.raw); err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+	for _, job := range jobs {
+		if job.kind == opsJobKindCallsDirMigrate {
+			var p callsDirMigrateParams
+			if err := json.Unmarshal([]byte(job.raw), &p); err != nil {
+				log.Printf("ops job %d has invalid params: %v", job.id, err)
+				continue
+			}
+			log.Printf("resuming calls dir migration job %d", job.id)
+			go s.runCallsDirMigrate(ctx, job.id, p)
+			continue
+		}
+		if job.kind == opsJobKindBackfill {
+			var p backfillParams
+			if err := json.Unmarshal([]byte(job.raw), &p); err != nil {
+				log.Printf("ops job %d has invalid params: %v", job.id, err)
+				continue
+			}
+			log.Printf("resuming backfill job %d", job.id)
+			go s.resumeBackfill(ctx, job.id, p)
+			continue
+		}
+		if job.kind == opsJobKindImport {
+			var p importParams
+			if err := json.Unmarshal([]byte(job.raw), &p); err != nil {
+				log.Printf("ops job %d has invalid params: %v", job.id, err)
+				continue
+			}
+			log.Printf("resuming import job %d", job.id)
+			go s.runImport(ctx, job.id, p)
+			continue
+		}
+		var p bulkReprocessParams
+		if err := json.Unmarshal([]byte(job.raw), &p); err != nil {
+			log.Printf("ops job %d has invalid params: %v", job.id, err)
+			continue
+		}
+		log.Printf("resuming bulk reprocess job %d", job.id)
+		go s.runBulkReprocess(ctx, job.id, p)
+	}
+}
+
+// runBulkReprocess walks matching calls in id order, checkpointing the cursor
+// after each call so an interrupted job resumes where it stopped.
+func (s *server) runBulkReprocess(ctx context.Context, jobID int64, p bulkReprocessParams) {
+	var cursor int64
+	var processed, failed int
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&cursor, &processed, &failed)
+	}, `SELECT cursor_id, processed, failed FROM ops_jobs WHERE id = ?`, jobID); err != nil {
+		log.Printf("ops job %d load failed: %v", jobID, err)
+		return
+	}
+
+	where, args := p.where()
+	query := `SELECT id, filename FROM transcriptions WHERE ` + where + ` AND id > ? ORDER BY id`
+	args = append(args, cursor)
+	if p.Limit > 0 {
+		remaining := p.Limit - processed - failed
+		if remaining <= 0 {
+			s.finishOpsJob(jobID, opsJobCompleted, "")
+			return
+		}
+		query += ` LIMIT ?`
+		args = append(args, remaining)
+	}
+	rows, err := queryWithRetry(s.db, query, args...)
+	if err != nil {
+		s.finishOpsJob(jobID, opsJobFailed, err.Error())
+		return
+	}
+	type target struct {
+		id       int64
+		filename string
+	}
+	var targets []target
+	for rows.Next() {
+		var t target
+		if err := rows.Scan(&t.id, &t.filename); err == nil {
+			targets = append(targets, t)
+		}
+	}
+	rows.Close()
+
+	var lastErr string
+	for _, t := range targets {
+		select {
+		case <-ctx.Done():
+			log.Printf("ops job %d interrupted at id %d; will resume on restart", jobID, cursor)
+			return
+		case <-s.shutdown:
+			return
+		default:
+		}
+		if p.Stage == reprocessStageTranscribe && !s.waitForOpenAI(ctx, jobID) {
+			return
+		}
+		err := s.reprocessOne(ctx, t.filename, p.Stage)
+		switch {
+		case ctx.Err() != nil || errors.Is(err, context.Canceled):
+			// The call stays unchecked and is redone when the job resumes.
+			log.Printf("ops job %d interrupted at id %d; will resume on restart", jobID, cursor)
+			return
+		case errors.Is(err, errTranscriptionDeferred):
+			// The queue requeued the call to run once OpenAI recovers.
+			processed++
+			log.Printf("bulk reprocess job %d: %s deferred by OpenAI outage; requeued", jobID, t.filename)
+		case err != nil:
+			failed++
+			lastErr = fmt.Sprintf("%s: %v", t.filename, err)
+			log.Printf("bulk reprocess job %d: %s", jobID, lastErr)
+		default:
+			processed++
+		}
+		cursor = t.id
+		if _, err := execWithRetry(s.db, `UPDATE ops_jobs SET processed = ?, failed = ?, cursor_id = ?, last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, processed, failed, cursor, nullableString(lastErr), jobID); err != nil {
+			log.Printf("ops job %d checkpoint failed: %v", jobID, err)
+		}
+	}
+	s.finishOpsJob(jobID, opsJobCompleted, lastErr)
+}
+
+// reprocessOne reruns one call of a bulk job from stage. Transcription goes
+// through the worker queue and waits for it, so the call gets the queue's
+// timeout, waits out a pause or drain, and is requeued rather than stranded
+// when OpenAI is down; later stages run in place.
+func (s *server) reprocessOne(ctx context.Context, filename, stage string) error {
+	if stage != reprocessStageTranscribe {
+		if _, busy := s.running.LoadOrStore(filename, struct{}{}); busy {
+			return errors.New("already in progress")
+		}
+		defer s.running.Delete(filename)
+		return s.reprocessFromStage(ctx, filename, stage, false)
+	}
+	if _, busy := s.running.Load(filename); busy {
+		return errors.New("already in progress")
+	}
+	opts, _ := s.defaultOptions()
+	meta, pretty, publicURL, baseURL := s.buildJobContext(filename, "")
+	job := processJob{filename: filename, source: "ops", force: true, options: opts, meta: meta, prettyTitle: pretty, publicURL: publicURL, baseURL: baseURL, enqueuedAt: time.Now().UTC()}
+	done := make(chan error, 1)
+	if enqueued, _ := s.enqueueLocal(ctx, job, func(err error) { done <- err }); !enqueued {
+		return errors.New("queue full")
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.shutdown:
+		return context.Canceled
+	}
+}
+
+// waitForOpenAI holds a transcribing bulk job while the OpenAI breaker is
+// open, so it does not fill the queue with calls that can only be deferred.
+// It reports false when ctx ends or the server shuts down first.
+func (s *server) waitForOpenAI(ctx context.Context, jobID int64) bool {
+	if s.openAIAvailable() {
+		return true
+	}
+	log.Printf("bulk reprocess job %d waiting for OpenAI to recover", jobID)
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for !s.openAIAvailable() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-s.shutdown:
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+func (s *server) finishOpsJob(jobID int64, status, lastErr string) {
+	if _, err := execWithRetry(s.db, `UPDATE ops_jobs SET status = ?, last_error = COALESCE(?, last_error), finished_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, status, nullableString(lastErr), jobID); err != nil {
+		log.Printf("ops job %d finish failed: %v", jobID, err)
+	}
+}
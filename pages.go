@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+	"alert_framework/imap"
+	"alert_framework/stations"
+)
+
+// Pages are the text pages agencies email out for an incident. The IMAP poller
+// turns each unseen message in IMAP_URL into a metadata-only alert, and the
+// recording of the same incident that arrives later is linked back to it:
+// the page row's page_for names the audio call. A page matches the closest
+// audio call within PAGE_MATCH_WINDOW_SEC from the same agency, in a town the
+// page names, or within pageMatchRadiusMeters of the page's location.
+
+const (
+	pageSource = "email"
+	// imapFetchLimit caps how many messages one poll takes, so a backlog in
+	// the mailbox drains over several polls.
+	imapFetchLimit        = 50
+	imapFetchTimeout      = 2 * time.Minute
+	pageMatchRadiusMeters = 500
+)
+
+func migrateAddPageLinks(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "transcriptions", "page_for", "TEXT NULL"); err != nil {
+		return err
+	}
+	_, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_transcriptions_page_for ON transcriptions(page_for)`)
+	return err
+}
+
+func downPageLinks(db *sql.DB) error {
+	if _, err := execWithRetry(db, `DROP INDEX IF EXISTS idx_transcriptions_page_for`); err != nil {
+		return err
+	}
+	return dropColumnIfExists(db, "transcriptions", "page_for")
+}
+
+// startIMAPPoller reads IMAP_URL every IMAP_POLL_SEC when it is set.
+func (s *server) startIMAPPoller(ctx context.Context) {
+	if s.cfg.IMAPURL == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(s.cfg.IMAPPollSec) * time.Second)
+		defer ticker.Stop()
+		for {
+			s.pollIMAP(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// pollIMAP creates an alert for every unseen message. A message that cannot
+// be stored stays unseen and is tried again on the next poll.
+func (s *server) pollIMAP(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, imapFetchTimeout)
+	defer cancel()
+	mailbox := imap.Mailbox{URL: s.cfg.IMAPURL, Username: s.cfg.IMAPUsername, Password: s.cfg.IMAPPassword}
+	err := mailbox.Fetch(ctx, imapFetchLimit, func(msg imap.Message) error {
+		return s.ingestPage(ctx, msg)
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("imap poll failed: %v", err)
+	}
+}
+
+// ingestPage stores one message as an alert. Messages from senders missing
+// from IMAP_SENDERS and messages with no text are skipped, and so flagged
+// seen like the rest.
+func (s *server) ingestPage(ctx context.Context, msg imap.Message) error {
+	text, err := imap.Parse(msg.Raw)
+	if err != nil {
+		log.Printf("imap message %d skipped: %v", msg.UID, err)
+		return nil
+	}
+	senders := s.liveConfig().IMAPSenders
+	agency, known := senders[text.From]
+	if len(senders) > 0 && !known {
+		log.Printf("imap message %d from %s skipped: sender not in IMAP_SENDERS", msg.UID, text.From)
+		return nil
+	}
+	now := time.Now()
+	callTime := text.Date
+	if callTime.IsZero() {
+		callTime = msg.InternalDate
+	}
+	if callTime.IsZero() || callTime.After(now) {
+		callTime = now
+	}
+	req := metadataAlertRequest{
+		ID:     pageID(text, msg.Raw),
+		Text:   pageText(text),
+		Agency: agency,
+		Source: pageSource,
+	}
+	filename, created, err := s.storeMetadataAlert(ctx, req, callTime.In(s.tz), s.cfg.PublicBaseURL)
+	if err != nil {
+		return err
+	}
+	if created {
+		log.Printf("page from %s stored as %s", text.From, filename)
+		if s.metrics != nil {
+			s.metrics.RecordEnqueue(metadataAlertSource+":"+pageSource, callTime.UTC(), now.UTC())
+		}
+	}
+	return nil
+}
+
+// pageID derives the alert id from the Message-ID, or from the message itself
+// when it has none, so a message seen twice is stored once.
+func pageID(text imap.Text, raw []byte) string {
+	key := []byte(text.MessageID)
+	if len(key) == 0 {
+		key = raw
+	}
+	sum := sha256.Sum256(key)
+	return pageSource + "-" + hex.EncodeToString(sum[:8])
+}
+
+// pageText is the subject followed by the body; pagers often put the whole
+// page in the subject, or repeat it as the body's first line.
+func pageText(text imap.Text) string {
+	body := strings.TrimSpace(text.Body)
+	if text.Subject == "" || strings.HasPrefix(body, text.Subject) {
+		return body
+	}
+	if body == "" {
+		return text.Subject
+	}
+	return text.Subject + "\n" + body
+}
+
+// correlatePages links a freshly transcribed audio call to the closest
+// unlinked page for the same incident. A call that already has a page keeps
+// it when reprocessed.
+func (s *server) correlatePages(filename string, meta formatting.CallMetadata, location *locationGuess) {
+	window := time.Duration(s.liveConfig().PageMatchWindowSec) * time.Second
+	if window <= 0 || meta.DateTime.IsZero() {
+		return
+	}
+	var linked int
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&linked)
+	}, `SELECT COUNT(*) FROM transcriptions WHERE page_for = ?`, filename)
+	if err != nil || linked > 0 {
+		if err != nil {
+			log.Printf("page lookup failed for %s: %v", filename, err)
+		}
+		return
+	}
+	at := meta.DateTime.UTC()
+	pages, err := s.store.Select(context.Background(), `WHERE (ingest_source = ? OR ingest_source LIKE ?) AND page_for IS NULL AND status = ? AND deleted_at IS NULL AND call_timestamp BETWEEN ? AND ?`,
+		metadataAlertSource, metadataAlertSource+":%", statusDone, at.Add(-window), at.Add(window))
+	if err != nil {
+		log.Printf("page correlate failed for %s: %v", filename, err)
+		return
+	}
+	best, bestDelta := "", window+1
+	for _, page := range pages {
+		if page.CallTimestamp == nil || !s.pageMatches(page, meta, location) {
+			continue
+		}
+		delta := page.CallTimestamp.Sub(at)
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta < bestDelta {
+			best, bestDelta = page.Filename, delta
+		}
+	}
+	if best == "" {
+		return
+	}
+	if _, err := execWithRetry(s.db, `UPDATE transcriptions SET page_for = ? WHERE filename = ? AND page_for IS NULL`, filename, best); err != nil {
+		log.Printf("page link failed for %s: %v", filename, err)
+	}
+}
+
+// pageMatches reports whether a page and an audio call describe the same
+// incident: the same agency, a town the page names, or nearby locations.
+func (s *server) pageMatches(page transcription, meta formatting.CallMetadata, location *locationGuess) bool {
+	if pageMeta, err := s.parseCallMetadata(page.Filename, ""); err == nil {
+		if key := cadTalkgroupKey(pageMeta.AgencyDisplay); key != "" && key != strings.ToLower(metadataAlertSource) && key == cadTalkgroupKey(meta.AgencyDisplay) {
+			return true
+		}
+	}
+	if town := cadTalkgroupKey(meta.TownDisplay); town != "" {
+		for _, t := range parseRecognizedTownList(page.RecognizedTowns) {
+			if cadTalkgroupKey(t) == town {
+				return true
+			}
+		}
+	}
+	if location != nil && page.Latitude != nil && page.Longitude != nil {
+		return stations.DistanceMeters(location.Latitude, location.Longitude, *page.Latitude, *page.Longitude) <= pageMatchRadiusMeters
+	}
+	return false
+}
+
+// callPages lists the pages linked to an audio call, oldest first.
+func (s *server) callPages(ctx context.Context, filename string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT filename FROM transcriptions WHERE page_for = ? AND deleted_at IS NULL ORDER BY call_timestamp, filename`, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		out = append(out, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,189 @@
+// Package pdf writes simple PDF documents: text in the standard Helvetica
+// faces, lines, filled boxes and JPEG images on US Letter pages. It exists for
+// server-side reports and deliberately supports nothing else.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"strings"
+)
+
+// Letter page size in points.
+const (
+	PageWidth  = 612
+	PageHeight = 792
+)
+
+// Document is a PDF under construction. Build pages with AddPage, then call
+// WriteTo once.
+type Document struct {
+	Title  string
+	pages  []*Page
+	images [][]byte
+	dims   []imageInfo
+}
+
+type imageInfo struct {
+	width, height int
+	colorSpace    string
+}
+
+// Page collects drawing operations. Coordinates are in points from the top
+// left corner; y grows downwards.
+type Page struct {
+	doc    *Document
+	ops    bytes.Buffer
+	images []int
+}
+
+// New returns an empty document.
+func New(title string) *Document {
+	return &Document{Title: title}
+}
+
+// AddPage appends a blank page.
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Text draws s with its baseline at (x, y). Characters outside WinAnsi are
+// replaced with "?".
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.ops, "BT /%s %s Tf %s %s Td (%s) Tj ET\n", font, num(size), num(x), num(PageHeight-y), escape(encode(s)))
+}
+
+// Line draws a line of the given width and gray level (0 black, 1 white).
+func (p *Page) Line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(&p.ops, "%s G %s w %s %s m %s %s l S\n", num(gray), num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// FillRect fills the box with top left (x, y) in the given gray level.
+func (p *Page) FillRect(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.ops, "%s g %s %s %s %s re f 0 g\n", num(gray), num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// JPEG draws a JPEG image scaled into the box with top left (x, y).
+func (p *Page) JPEG(x, y, w, h float64, data []byte) error {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	var space string
+	switch cfg.ColorModel {
+	case color.GrayModel:
+		space = "DeviceGray"
+	case color.YCbCrModel:
+		space = "DeviceRGB"
+	default:
+		return errors.New("pdf: only RGB and grayscale JPEGs are supported")
+	}
+	d := p.doc
+	d.images = append(d.images, data)
+	d.dims = append(d.dims, imageInfo{width: cfg.Width, height: cfg.Height, colorSpace: space})
+	idx := len(d.images) - 1
+	p.images = append(p.images, idx)
+	fmt.Fprintf(&p.ops, "q %s 0 0 %s %s %s cm /Im%d Do Q\n", num(w), num(h), num(x), num(PageHeight-y-h), idx)
+	return nil
+}
+
+// WriteTo serializes the document.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) int {
+		offsets = append(offsets, out.Len())
+		id := len(offsets)
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", id, body)
+		return id
+	}
+	stream := func(dict string, data []byte) int {
+		offsets = append(offsets, out.Len())
+		id := len(offsets)
+		fmt.Fprintf(&out, "%d 0 obj\n<< %s /Length %d >>\nstream\n", id, dict, len(data))
+		out.Write(data)
+		out.WriteString("\nendstream\nendobj\n")
+		return id
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Object numbers 1 and 2 are the catalog and page tree; the tree's kids
+	// are only known once the pages are written, so it is patched in last.
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	offsets = append(offsets, 0)
+	pagesAt := len(offsets) - 1
+	regular := obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	bold := obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	imageIDs := make([]int, len(d.images))
+	for i, data := range d.images {
+		info := d.dims[i]
+		imageIDs[i] = stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /DCTDecode", info.width, info.height, info.colorSpace), data)
+	}
+	var kids []string
+	for _, p := range d.pages {
+		content, err := deflate(p.ops.Bytes())
+		if err != nil {
+			return 0, err
+		}
+		contentID := stream("/Filter /FlateDecode", content)
+		var xobjects strings.Builder
+		for _, idx := range p.images {
+			fmt.Fprintf(&xobjects, " /Im%d %d 0 R", idx, imageIDs[idx])
+		}
+		resources := fmt.Sprintf("<< /Font << /F1 %d 0 R /F2 %d 0 R >> /XObject <<%s >> >>", regular, bold, xobjects.String())
+		pageID := obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources %s /Contents %d 0 R >>", PageWidth, PageHeight, resources, contentID))
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+	}
+	offsets[pagesAt] = out.Len()
+	fmt.Fprintf(&out, "2 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(kids))
+	info := obj(fmt.Sprintf("<< /Title (%s) /Producer (alert_framework) >>", escape(encode(d.Title))))
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+	n, err := w.Write(out.Bytes())
+	return int64(n), err
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" {
+		return "0"
+	}
+	return s
+}
+
+func escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", " ", "\n", " ")
+	return r.Replace(s)
+}
@@ -0,0 +1,89 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteToProducesValidXref(t *testing.T) {
+	doc := New("Report (draft)")
+	page := doc.AddPage()
+	page.Text(40, 60, 14, true, "Engine 5 – respond (Main St)")
+	page.Line(40, 70, 572, 70, 1, 0.5)
+	page.FillRect(40, 80, 100, 20, 0.9)
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 4, 3)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := page.JPEG(40, 120, 200, 150, img.Bytes()); err != nil {
+		t.Fatalf("JPEG: %v", err)
+	}
+	doc.AddPage().Text(40, 60, 10, false, "page two")
+
+	var out bytes.Buffer
+	if _, err := doc.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	data := out.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("missing header or trailer")
+	}
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	if len(entries) != 10 {
+		t.Fatalf("xref has %d objects, want 10", len(entries))
+	}
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		want := strconv.Itoa(i+1) + " 0 obj"
+		if !bytes.HasPrefix(data[off:], []byte(want)) {
+			t.Errorf("object %d offset %d points at %q", i+1, off, data[off:off+10])
+		}
+	}
+	if !bytes.Contains(data, []byte("/Count 2")) || !bytes.Contains(data, []byte(`/Title (Report \(draft\))`)) {
+		t.Error("page tree or escaped title missing")
+	}
+}
+
+func TestJPEGRejectsOtherData(t *testing.T) {
+	if err := New("").AddPage().JPEG(0, 0, 10, 10, []byte("not a jpeg")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := Wrap("Engine 5 respond to 12 Main Street for a report of smoke", 10, 100, false)
+	if len(lines) < 2 {
+		t.Fatalf("expected wrapping, got %q", lines)
+	}
+	for _, l := range lines {
+		if Width(l, 10, false) > 100 {
+			t.Errorf("line %q is %.1fpt wide", l, Width(l, 10, false))
+		}
+	}
+	if got := strings.Join(lines, " "); got != "Engine 5 respond to 12 Main Street for a report of smoke" {
+		t.Errorf("wrap lost words: %q", got)
+	}
+	long := Wrap(strings.Repeat("W", 40), 10, 50, true)
+	if len(long) < 2 || strings.Join(long, "") != strings.Repeat("W", 40) {
+		t.Errorf("long word split wrong: %q", long)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	if got := encode("café – “ok” 🚒"); got != "caf\xe9 \x96 \x93ok\x94 ?" {
+		t.Errorf("encode = %q", got)
+	}
+}
@@ -0,0 +1,137 @@
+package pdf
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Glyph widths in 1/1000 em for WinAnsi codes 32–126 of the standard
+// Helvetica and Helvetica-Bold fonts.
+var (
+	helvetica = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBold = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// winAnsi maps the punctuation transcripts and titles use outside Latin-1 to
+// their WinAnsi codes, with the glyph width shared by both faces.
+var winAnsi = map[rune]struct {
+	code  byte
+	width int
+}{
+	'€': {0x80, 556},
+	'‘': {0x91, 222},
+	'’': {0x92, 222},
+	'“': {0x93, 333},
+	'”': {0x94, 333},
+	'•': {0x95, 350},
+	'–': {0x96, 556},
+	'—': {0x97, 1000},
+	'…': {0x85, 1000},
+}
+
+// encode converts s to WinAnsi bytes.
+func encode(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 32 && r <= 126:
+			b.WriteByte(byte(r))
+		case r >= 0xA0 && r <= 0xFF:
+			b.WriteByte(byte(r))
+		case r == '\t' || r == '\n' || r == '\r':
+			b.WriteByte(' ')
+		default:
+			if m, ok := winAnsi[r]; ok {
+				b.WriteByte(m.code)
+			} else {
+				b.WriteByte('?')
+			}
+		}
+	}
+	return b.String()
+}
+
+// Width returns the width of s in points at size.
+func Width(s string, size float64, bold bool) float64 {
+	table := &helvetica
+	if bold {
+		table = &helveticaBold
+	}
+	total := 0
+	for _, r := range s {
+		switch {
+		case r >= 32 && r <= 126:
+			total += table[r-32]
+		default:
+			if m, ok := winAnsi[r]; ok {
+				total += m.width
+			} else {
+				total += 556
+			}
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Wrap breaks s into lines no wider than width points. Words longer than a
+// line are split.
+func Wrap(s string, size, width float64, bold bool) []string {
+	var lines []string
+	var current string
+	for _, word := range strings.Fields(s) {
+		for Width(word, size, bold) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			cut := fitRunes(word, size, width, bold)
+			lines = append(lines, word[:cut])
+			word = word[cut:]
+		}
+		if word == "" {
+			continue
+		}
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if Width(candidate, size, bold) <= width {
+			current = candidate
+			continue
+		}
+		lines = append(lines, current)
+		current = word
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// fitRunes returns the byte length of the longest prefix of word that fits,
+// at least one rune.
+func fitRunes(word string, size, width float64, bold bool) int {
+	cut := 0
+	for cut < len(word) {
+		_, n := utf8.DecodeRuneInString(word[cut:])
+		if cut > 0 && Width(word[:cut+n], size, bold) > width {
+			break
+		}
+		cut += n
+	}
+	return cut
+}
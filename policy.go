@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+
+	"alert_framework/formatting"
+)
+
+// restrictedFlags returns the taxonomy flags of a call that appear in
+// RESTRICTED_CALL_FLAGS. Flags are derived from the call type and the
+// unredacted transcript, so placeholders in the public text cannot hide them.
+func (s *server) restrictedFlags(callType, transcript string) []string {
+	if len(s.cfg.RestrictedCallFlags) == 0 {
+		return nil
+	}
+	var out []string
+	for _, flag := range formatting.CallFlags(callType, transcript) {
+		for _, restricted := range s.cfg.RestrictedCallFlags {
+			if flag == restricted {
+				out = append(out, flag)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// isRestricted reports whether t must be kept out of public lists, previews
+// and notifications. Deleted calls are restricted too, so only admins can
+// still open them.
+func (s *server) isRestricted(t transcription) bool {
+	return t.DeletedAt != nil || len(s.restrictedFlags(derefString(t.CallType, ""), derefString(pickTranscript(&t), ""))) > 0
+}
+
+// visibleTo reports whether r may see t: restricted calls are admin-only.
+func (s *server) visibleTo(r *http.Request, t transcription) bool {
+	return isAdminRequest(r) || !s.isRestricted(t)
+}
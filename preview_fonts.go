@@ -0,0 +1,116 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+	"log"
+	"math"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Preview cards are set in Go Regular and Go Bold, which ship inside
+// golang.org/x/image under its BSD licence, so no font file is vendored.
+var (
+	previewFontsOnce sync.Once
+	previewRegular   *opentype.Font
+	previewBold      *opentype.Font
+)
+
+func loadPreviewFonts() {
+	previewFontsOnce.Do(func() {
+		var err error
+		if previewRegular, err = opentype.Parse(goregular.TTF); err != nil {
+			log.Printf("preview font load failed: %v", err)
+			return
+		}
+		if previewBold, err = opentype.Parse(gobold.TTF); err != nil {
+			log.Printf("preview font load failed: %v", err)
+			previewRegular = nil
+		}
+	})
+}
+
+// previewFace returns f at size pixels, or the old bitmap face if the fonts
+// could not be loaded so cards still render.
+func previewFace(f *opentype.Font, size float64) font.Face {
+	if f == nil {
+		return basicfont.Face7x13
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return basicfont.Face7x13
+	}
+	return face
+}
+
+func previewLineHeight(size float64) int {
+	return int(math.Ceil(size * 1.25))
+}
+
+// previewText is a block of text wrapped at the size fitPreviewText chose.
+type previewText struct {
+	face       font.Face
+	lines      []string
+	lineHeight int
+}
+
+// fitPreviewText wraps text to width at the largest size from maxSize down to
+// minSize, in 2px steps, whose lines fit in maxHeight. Text that does not fit
+// even at minSize is cut short with an ellipsis.
+func fitPreviewText(f *opentype.Font, text string, width int, maxSize, minSize float64, maxHeight int) previewText {
+	size := maxSize
+	for {
+		face := previewFace(f, size)
+		lineHeight := previewLineHeight(size)
+		if f == nil {
+			lineHeight = 22
+		}
+		maxLines := maxHeight / lineHeight
+		if maxLines < 1 {
+			maxLines = 1
+		}
+		lines := wrapLines(text, width, face)
+		if len(lines) <= maxLines || size-2 < minSize || f == nil {
+			if len(lines) > maxLines {
+				lines = lines[:maxLines]
+				lines[maxLines-1] = ellipsize(lines[maxLines-1], width, face)
+			}
+			return previewText{face: face, lines: lines, lineHeight: lineHeight}
+		}
+		size -= 2
+	}
+}
+
+// ellipsize drops words from the end of line until it fits width with a
+// trailing ellipsis.
+func ellipsize(line string, width int, face font.Face) string {
+	words := strings.Fields(line)
+	for len(words) > 0 {
+		candidate := strings.Join(words, " ") + "…"
+		if font.MeasureString(face, candidate).Ceil() <= width {
+			return candidate
+		}
+		words = words[:len(words)-1]
+	}
+	return "…"
+}
+
+// drawBlock draws p with its first line's top at top and returns the y just
+// below its last line.
+func drawBlock(dst draw.Image, x, top int, p previewText, src image.Image) int {
+	d := &font.Drawer{Dst: dst, Src: src, Face: p.face}
+	ascent := p.face.Metrics().Ascent.Ceil()
+	for i, line := range p.lines {
+		d.Dot = fixed.P(x, top+ascent+i*p.lineHeight)
+		d.DrawString(line)
+	}
+	return top + len(p.lines)*p.lineHeight
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// previewMapSize is the side of the square map thumbnail on preview cards.
+const previewMapSize = 420
+
+// previewMap is the map thumbnail for t's preview card: the incident pin on a
+// street map, fetched at 2x and scaled down to previewMapSize. wanted reports
+// whether t should have one at all (it has coordinates and a Mapbox token is
+// set), so a card whose map could not be fetched is not kept.
+func (s *server) previewMap(t transcription) (thumb image.Image, wanted bool) {
+	if t.Latitude == nil || t.Longitude == nil || strings.TrimSpace(s.cfg.MapboxToken) == "" {
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, mapProxyTimeout)
+	defer cancel()
+	overlay := fmt.Sprintf("pin-l+d33(%.5f,%.5f)", *t.Longitude, *t.Latitude)
+	view := fmt.Sprintf("%.5f,%.5f,14,0", *t.Longitude, *t.Latitude)
+	img := s.staticMapImage(ctx, overlay, view, previewMapSize, previewMapSize, nil)
+	if img == nil {
+		return nil, true
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, previewMapSize, previewMapSize))
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return scaled, true
+}
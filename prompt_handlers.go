@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/prompts"
+)
+
+type promptVersionResponse struct {
+	ID        int64                 `json:"id,omitempty"`
+	Kind      string                `json:"kind"`
+	Version   string                `json:"version"`
+	Prompt    string                `json:"prompt,omitempty"`
+	Weight    int                   `json:"weight"`
+	Active    bool                  `json:"active"`
+	Baseline  bool                  `json:"baseline,omitempty"`
+	CreatedAt *time.Time            `json:"created_at,omitempty"`
+	Metrics   promptMetricsResponse `json:"metrics"`
+}
+
+type promptMetricsResponse struct {
+	Calls              int     `json:"calls"`
+	ManualReview       int     `json:"manual_review"`
+	Geocoded           int     `json:"geocoded"`
+	ManualReviewRate   float64 `json:"manual_review_rate"`
+	GeocodeSuccessRate float64 `json:"geocode_success_rate"`
+}
+
+type promptListResponse struct {
+	Prompts []promptVersionResponse `json:"prompts"`
+}
+
+type promptCreateRequest struct {
+	Kind    string `json:"kind"`
+	Version string `json:"version"`
+	Prompt  string `json:"prompt"`
+	Weight  int    `json:"weight"`
+	Active  *bool  `json:"active"`
+}
+
+type promptUpdateRequest struct {
+	Weight *int  `json:"weight"`
+	Active *bool `json:"active"`
+}
+
+// handlePrompts lists prompt versions with quality metrics (GET) or registers a
+// new version (POST, admin only).
+func (s *server) handlePrompts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listPrompts(w, r)
+	case http.MethodPost:
+		if !requireAdmin(w, r) {
+			return
+		}
+		var req promptCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		active := true
+		if req.Active != nil {
+			active = *req.Active
+		}
+		created, err := s.prompts.Create(r.Context(), prompts.Version{Kind: req.Kind, Version: req.Version, Prompt: req.Prompt, Weight: req.Weight, Active: active})
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE") {
+				http.Error(w, "version already exists", http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		respondJSON(w, toPromptVersionResponse(created, prompts.Metrics{}))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePromptDetail adjusts the weight or active flag of a version (admin only).
+func (s *server) handlePromptDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch && r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/prompts/"), "/"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var req promptUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	updated, err := s.prompts.Update(r.Context(), id, req.Weight, req.Active)
+	if err != nil {
+		switch {
+		case errors.Is(err, prompts.ErrNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, prompts.ErrWeightExceeded):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	respondJSON(w, toPromptVersionResponse(updated, prompts.Metrics{}))
+}
+
+func (s *server) listPrompts(w http.ResponseWriter, r *http.Request) {
+	kinds := []string{prompts.KindCleanup, prompts.KindMetadata}
+	if kind := strings.TrimSpace(r.URL.Query().Get("kind")); kind != "" {
+		if !prompts.ValidKind(kind) {
+			http.Error(w, "unknown kind", http.StatusBadRequest)
+			return
+		}
+		kinds = []string{kind}
+	}
+	resp := promptListResponse{Prompts: []promptVersionResponse{}}
+	for _, kind := range kinds {
+		versions, err := s.prompts.List(r.Context(), kind)
+		if err != nil {
+			log.Printf("prompt list failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		metrics, err := s.prompts.Metrics(r.Context(), kind)
+		if err != nil {
+			log.Printf("prompt metrics failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		byVersion := map[string]prompts.Metrics{}
+		for _, m := range metrics {
+			byVersion[m.Version] = m
+		}
+		remaining := 100
+		for _, v := range versions {
+			if v.Active {
+				remaining -= v.Weight
+			}
+			resp.Prompts = append(resp.Prompts, toPromptVersionResponse(v, byVersion[v.Version]))
+		}
+		baseline := toPromptVersionResponse(prompts.Version{Kind: kind, Version: prompts.BaselineVersion, Weight: remaining, Active: remaining > 0}, byVersion[prompts.BaselineVersion])
+		baseline.Baseline = true
+		resp.Prompts = append(resp.Prompts, baseline)
+	}
+	respondJSON(w, resp)
+}
+
+func toPromptVersionResponse(v prompts.Version, m prompts.Metrics) promptVersionResponse {
+	out := promptVersionResponse{
+		ID:      v.ID,
+		Kind:    v.Kind,
+		Version: v.Version,
+		Prompt:  v.Prompt,
+		Weight:  v.Weight,
+		Active:  v.Active,
+		Metrics: promptMetricsResponse{
+			Calls:              m.Calls,
+			ManualReview:       m.ManualReview,
+			Geocoded:           m.Geocoded,
+			ManualReviewRate:   math.Round(m.ManualReviewRate*1000) / 1000,
+			GeocodeSuccessRate: math.Round(m.GeocodeSuccessRate*1000) / 1000,
+		},
+	}
+	if !v.CreatedAt.IsZero() {
+		ts := v.CreatedAt.UTC()
+		out.CreatedAt = &ts
+	}
+	return out
+}
+
+// assignPrompt chooses the prompt version for a call, falling back to the
+// prompt saved in app settings.
+func (s *server) assignPrompt(ctx context.Context, kind, filename string) prompts.Assignment {
+	fallback := ""
+	if settings, err := s.loadSettings(); err == nil {
+		if kind == prompts.KindCleanup {
+			fallback = settings.CleanupPrompt
+		} else {
+			fallback = settings.MetadataPrompt
+		}
+	}
+	if kind == prompts.KindCleanup && strings.TrimSpace(fallback) == "" {
+		fallback = defaultCleanupPrompt
+	}
+	if pinned, ok := s.pinnedPrompt(kind, filename); ok {
+		if pinned.Prompt == "" {
+			pinned.Prompt = fallback
+		}
+		return pinned
+	}
+	if s.prompts == nil {
+		return prompts.Assignment{Kind: kind, Version: prompts.BaselineVersion, Prompt: fallback}
+	}
+	assignment, err := s.prompts.Assign(ctx, kind, filename, fallback)
+	if err != nil {
+		log.Printf("prompt assignment failed for %s (%s): %v", filename, kind, err)
+	}
+	return assignment
+}
+
+func (s *server) recordPromptAssignments(filename string, assignments ...*prompts.Assignment) {
+	if s.prompts == nil {
+		return
+	}
+	for _, a := range assignments {
+		if a == nil {
+			continue
+		}
+		if err := s.prompts.Record(context.Background(), filename, *a); err != nil {
+			log.Printf("record prompt version failed for %s: %v", filename, err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Package prompts keeps a versioned registry of cleanup/metadata prompts and
+// splits traffic between them so prompt edits can be compared on real calls.
+package prompts
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// KindCleanup is the transcript cleanup prompt.
+	KindCleanup = "cleanup"
+	// KindMetadata is the metadata/address inference prompt.
+	KindMetadata = "metadata"
+
+	// BaselineVersion labels calls that used the prompt saved in app settings.
+	// It receives whatever share of traffic the registered versions leave over.
+	BaselineVersion = "settings"
+
+	totalWeight = 100
+)
+
+var (
+	// ErrUnknownKind is returned for prompt kinds other than cleanup/metadata.
+	ErrUnknownKind = errors.New("unknown prompt kind")
+	// ErrWeightExceeded is returned when active weights for a kind would exceed 100%.
+	ErrWeightExceeded = errors.New("active prompt weights exceed 100")
+	// ErrNotFound is returned when a prompt version id does not exist.
+	ErrNotFound = errors.New("prompt version not found")
+)
+
+// Version is a registered prompt revision.
+type Version struct {
+	ID        int64
+	Kind      string
+	Version   string
+	Prompt    string
+	Weight    int
+	Active    bool
+	CreatedAt time.Time
+}
+
+// Assignment is the prompt chosen for a single call.
+type Assignment struct {
+	Kind    string
+	Version string
+	Prompt  string
+}
+
+// Metrics summarizes call outcomes for one prompt version.
+type Metrics struct {
+	Version            string
+	Calls              int
+	ManualReview       int
+	Geocoded           int
+	ManualReviewRate   float64
+	GeocodeSuccessRate float64
+}
+
+// Registry reads and writes the prompt_versions table.
+type Registry struct {
+	db *sql.DB
+}
+
+// NewRegistry wraps an open database handle.
+func NewRegistry(db *sql.DB) *Registry {
+	return &Registry{db: db}
+}
+
+// ValidKind reports whether kind is a supported prompt kind.
+func ValidKind(kind string) bool {
+	return kind == KindCleanup || kind == KindMetadata
+}
+
+// List returns registered versions, optionally filtered by kind.
+func (r *Registry) List(ctx context.Context, kind string) ([]Version, error) {
+	query := `SELECT id, kind, version, prompt, weight, active, created_at FROM prompt_versions`
+	args := []interface{}{}
+	if kind != "" {
+		query += ` WHERE kind = ?`
+		args = append(args, kind)
+	}
+	query += ` ORDER BY kind, created_at, id`
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Version
+	for rows.Next() {
+		var v Version
+		var active int
+		if err := rows.Scan(&v.ID, &v.Kind, &v.Version, &v.Prompt, &v.Weight, &active, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		v.Active = active == 1
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// Create registers a new prompt version.
+func (r *Registry) Create(ctx context.Context, v Version) (Version, error) {
+	v.Kind = strings.TrimSpace(v.Kind)
+	v.Version = strings.TrimSpace(v.Version)
+	v.Prompt = strings.TrimSpace(v.Prompt)
+	if !ValidKind(v.Kind) {
+		return Version{}, ErrUnknownKind
+	}
+	if v.Version == "" || v.Prompt == "" {
+		return Version{}, errors.New("version and prompt are required")
+	}
+	if v.Version == BaselineVersion {
+		return Version{}, fmt.Errorf("version %q is reserved", BaselineVersion)
+	}
+	if v.Weight < 0 || v.Weight > totalWeight {
+		return Version{}, fmt.Errorf("weight must be between 0 and %d", totalWeight)
+	}
+	existing, err := r.List(ctx, v.Kind)
+	if err != nil {
+		return Version{}, err
+	}
+	if v.Active {
+		if err := checkWeights(append(existing, v)); err != nil {
+			return Version{}, err
+		}
+	}
+	res, err := r.db.ExecContext(ctx, `INSERT INTO prompt_versions (kind, version, prompt, weight, active) VALUES (?, ?, ?, ?, ?)`, v.Kind, v.Version, v.Prompt, v.Weight, boolToInt(v.Active))
+	if err != nil {
+		return Version{}, err
+	}
+	v.ID, _ = res.LastInsertId()
+	v.CreatedAt = time.Now().UTC()
+	return v, nil
+}
+
+// Update changes the traffic weight and/or active flag of a version.
+func (r *Registry) Update(ctx context.Context, id int64, weight *int, active *bool) (Version, error) {
+	all, err := r.List(ctx, "")
+	if err != nil {
+		return Version{}, err
+	}
+	idx := -1
+	for i, v := range all {
+		if v.ID == id {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return Version{}, ErrNotFound
+	}
+	target := all[idx]
+	if weight != nil {
+		if *weight < 0 || *weight > totalWeight {
+			return Version{}, fmt.Errorf("weight must be between 0 and %d", totalWeight)
+		}
+		target.Weight = *weight
+	}
+	if active != nil {
+		target.Active = *active
+	}
+	sameKind := []Version{}
+	for i, v := range all {
+		if v.Kind != target.Kind {
+			continue
+		}
+		if i == idx {
+			v = target
+		}
+		sameKind = append(sameKind, v)
+	}
+	if err := checkWeights(sameKind); err != nil {
+		return Version{}, err
+	}
+	if _, err := r.db.ExecContext(ctx, `UPDATE prompt_versions SET weight = ?, active = ? WHERE id = ?`, target.Weight, boolToInt(target.Active), id); err != nil {
+		return Version{}, err
+	}
+	return target, nil
+}
+
+// Assign picks a prompt version for key (typically the call filename). The same
+// key always lands on the same version while weights are unchanged. When the
+// draw falls outside the registered weights, fallback is returned as the
+// settings baseline.
+func (r *Registry) Assign(ctx context.Context, kind, key, fallback string) (Assignment, error) {
+	baseline := Assignment{Kind: kind, Version: BaselineVersion, Prompt: fallback}
+	versions, err := r.List(ctx, kind)
+	if err != nil {
+		return baseline, err
+	}
+	if v, ok := pick(versions, kind, key); ok {
+		return Assignment{Kind: kind, Version: v.Version, Prompt: v.Prompt}, nil
+	}
+	return baseline, nil
+}
+
+// Metrics reports per-version manual-review and geocode success rates for
+// completed calls of the given kind. Deleted calls are left out, since
+// takedowns and stray recordings say nothing about the prompt.
+func (r *Registry) Metrics(ctx context.Context, kind string) ([]Metrics, error) {
+	column, err := versionColumn(kind)
+	if err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf(`SELECT %s,
+       COUNT(*),
+       SUM(CASE WHEN needs_manual_review = 1 THEN 1 ELSE 0 END),
+       SUM(CASE WHEN latitude IS NOT NULL AND longitude IS NOT NULL AND latitude != 0 AND longitude != 0 THEN 1 ELSE 0 END)
+FROM transcriptions
+WHERE status = 'done' AND deleted_at IS NULL AND %s IS NOT NULL AND %s != ''
+GROUP BY %s`, column, column, column, column)
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Metrics
+	for rows.Next() {
+		var m Metrics
+		if err := rows.Scan(&m.Version, &m.Calls, &m.ManualReview, &m.Geocoded); err != nil {
+			return nil, err
+		}
+		if m.Calls > 0 {
+			m.ManualReviewRate = float64(m.ManualReview) / float64(m.Calls)
+			m.GeocodeSuccessRate = float64(m.Geocoded) / float64(m.Calls)
+		}
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, rows.Err()
+}
+
+// Record stores which prompt version a call used.
+func (r *Registry) Record(ctx context.Context, filename string, a Assignment) error {
+	column, err := versionColumn(a.Kind)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, fmt.Sprintf(`UPDATE transcriptions SET %s = ? WHERE filename = ?`, column), a.Version, filename)
+	return err
+}
+
+func versionColumn(kind string) (string, error) {
+	switch kind {
+	case KindCleanup:
+		return "cleanup_prompt_version", nil
+	case KindMetadata:
+		return "metadata_prompt_version", nil
+	}
+	return "", ErrUnknownKind
+}
+
+// pick maps key onto a 0-99 bucket and walks the active versions' cumulative weights.
+func pick(versions []Version, kind, key string) (Version, bool) {
+	h := fnv.New32a()
+	h.Write([]byte(kind + ":" + key))
+	bucket := int(h.Sum32() % totalWeight)
+	cumulative := 0
+	for _, v := range versions {
+		if !v.Active || v.Weight <= 0 || v.Kind != kind {
+			continue
+		}
+		cumulative += v.Weight
+		if bucket < cumulative {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+func checkWeights(versions []Version) error {
+	sum := 0
+	for _, v := range versions {
+		if v.Active {
+			sum += v.Weight
+		}
+	}
+	if sum > totalWeight {
+		return ErrWeightExceeded
+	}
+	return nil
+}
+
+func boolToInt(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
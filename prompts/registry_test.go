@@ -0,0 +1,102 @@
+package prompts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestPickSplitsByWeight(t *testing.T) {
+	versions := []Version{
+		{Kind: KindCleanup, Version: "v2", Weight: 30, Active: true},
+		{Kind: KindCleanup, Version: "v3", Weight: 20, Active: true},
+		{Kind: KindCleanup, Version: "v4", Weight: 50, Active: false},
+	}
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		v, ok := pick(versions, KindCleanup, fmt.Sprintf("call-%d.mp3", i))
+		if !ok {
+			counts[BaselineVersion]++
+			continue
+		}
+		counts[v.Version]++
+	}
+	if counts["v4"] != 0 {
+		t.Fatalf("inactive version should never be picked, got %d", counts["v4"])
+	}
+	within := func(name string, want int) {
+		got := counts[name]
+		if got < want-300 || got > want+300 {
+			t.Fatalf("expected ~%d calls for %s, got %d", want, name, got)
+		}
+	}
+	within("v2", 3000)
+	within("v3", 2000)
+	within(BaselineVersion, 5000)
+}
+
+func TestPickIsStablePerKey(t *testing.T) {
+	versions := []Version{
+		{Kind: KindMetadata, Version: "a", Weight: 50, Active: true},
+		{Kind: KindMetadata, Version: "b", Weight: 50, Active: true},
+	}
+	first, _ := pick(versions, KindMetadata, "same.mp3")
+	for i := 0; i < 5; i++ {
+		again, _ := pick(versions, KindMetadata, "same.mp3")
+		if again.Version != first.Version {
+			t.Fatalf("expected stable assignment, got %s then %s", first.Version, again.Version)
+		}
+	}
+}
+
+func TestCheckWeightsRejectsOverAllocation(t *testing.T) {
+	versions := []Version{
+		{Kind: KindCleanup, Version: "v2", Weight: 60, Active: true},
+		{Kind: KindCleanup, Version: "v3", Weight: 50, Active: true},
+	}
+	if err := checkWeights(versions); err != ErrWeightExceeded {
+		t.Fatalf("expected ErrWeightExceeded, got %v", err)
+	}
+	versions[1].Active = false
+	if err := checkWeights(versions); err != nil {
+		t.Fatalf("inactive weights should not count, got %v", err)
+	}
+}
+
+func TestMetricsSkipDeletedCalls(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE transcriptions (
+    filename TEXT PRIMARY KEY,
+    status TEXT NOT NULL,
+    cleanup_prompt_version TEXT NULL,
+    needs_manual_review INTEGER NOT NULL DEFAULT 0,
+    latitude REAL NULL,
+    longitude REAL NULL,
+    deleted_at DATETIME NULL
+);
+INSERT INTO transcriptions VALUES
+    ('a.mp3', 'done', 'v2', 0, 41.05, -74.75, NULL),
+    ('b.mp3', 'done', 'v2', 1, NULL, NULL, CURRENT_TIMESTAMP),
+    ('c.mp3', 'done', 'v2', 1, NULL, NULL, CURRENT_TIMESTAMP),
+    ('d.mp3', 'error', 'v2', 1, NULL, NULL, NULL);`); err != nil {
+		t.Fatal(err)
+	}
+	metrics, err := NewRegistry(db).Metrics(context.Background(), KindCleanup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("metrics = %+v, want one version", metrics)
+	}
+	if m := metrics[0]; m.Calls != 1 || m.ManualReviewRate != 0 || m.GeocodeSuccessRate != 1 {
+		t.Fatalf("v2 metrics = %+v, want only the call that is not deleted", m)
+	}
+}
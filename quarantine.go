@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/errcode"
+)
+
+const (
+	// silentMaxVolumeDB is the loudest peak a recording may have and still
+	// count as silent. Radio hiss alone sits well above it.
+	silentMaxVolumeDB = -60.0
+
+	defaultQuarantineLimit = 100
+	maxQuarantineLimit     = 1000
+)
+
+var maxVolumePattern = regexp.MustCompile(`max_volume: (-?[0-9.]+|-inf) dB`)
+
+type quarantinedFile struct {
+	Filename      string    `json:"filename"`
+	OriginalPath  string    `json:"original_path"`
+	Path          string    `json:"path"`
+	Reason        string    `json:"reason"`
+	SizeBytes     int64     `json:"size_bytes"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+func migrateAddQuarantine(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS quarantine (
+    filename TEXT PRIMARY KEY,
+    original_path TEXT NOT NULL,
+    path TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    size_bytes INTEGER NOT NULL DEFAULT 0,
+    quarantined_at DATETIME NOT NULL
+);`)
+	return err
+}
+
+// validateAudio rejects recordings that would otherwise fail deep in the
+// pipeline: empty files, files ffprobe cannot read, recordings shorter than
+// MIN_AUDIO_SEC and recordings with no sound above silentMaxVolumeDB. The
+// returned error carries errcode.InvalidAudio. Without ffprobe only the empty
+// file check runs, and a cancelled ctx is returned as is.
+func (s *server) validateAudio(ctx context.Context, path string, size int64) error {
+	if size == 0 {
+		return errcode.Wrap(errcode.InvalidAudio, errors.New("invalid audio: empty file"))
+	}
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return nil
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(probeCtx, "ffprobe", "-v", "error", "-select_streams", "a", "-show_entries", "stream=codec_type:format=duration", "-of", "json", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return errcode.Wrap(errcode.InvalidAudio, fmt.Errorf("invalid audio: unreadable container (%s)", firstLine(stderr.String(), err)))
+	}
+	var probe struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return errcode.Wrap(errcode.InvalidAudio, fmt.Errorf("invalid audio: unreadable probe output: %w", err))
+	}
+	if len(probe.Streams) == 0 {
+		return errcode.Wrap(errcode.InvalidAudio, errors.New("invalid audio: no audio stream"))
+	}
+	duration, _ := strconv.ParseFloat(strings.TrimSpace(probe.Format.Duration), 64)
+	if minSec := s.cfg.MinAudioSec; minSec > 0 && duration < minSec {
+		return errcode.Wrap(errcode.InvalidAudio, fmt.Errorf("invalid audio: %.2fs is shorter than %.2fs", duration, minSec))
+	}
+
+	cmd = exec.CommandContext(ctx, ffmpegBinary, "-v", "info", "-nostats", "-i", path, "-af", "volumedetect", "-f", "null", "-")
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errcode.Wrap(errcode.InvalidAudio, fmt.Errorf("invalid audio: decode failed (%s)", firstLine(stderr.String(), err)))
+	}
+	if peak, ok := parseMaxVolume(stderr.String()); ok && peak <= silentMaxVolumeDB {
+		return errcode.Wrap(errcode.InvalidAudio, fmt.Errorf("invalid audio: silent (peak %s dB)", formatDB(peak)))
+	}
+	return nil
+}
+
+// parseMaxVolume reads the peak level from ffmpeg volumedetect output.
+func parseMaxVolume(log string) (float64, bool) {
+	m := maxVolumePattern.FindStringSubmatch(log)
+	if m == nil {
+		return 0, false
+	}
+	if m[1] == "-inf" {
+		return math.Inf(-1), true
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+func formatDB(v float64) string {
+	if math.IsInf(v, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+// firstLine returns the first line of a tool's stderr, or err when it wrote
+// nothing.
+func firstLine(stderr string, err error) string {
+	if line, _, _ := strings.Cut(strings.TrimSpace(stderr), "\n"); line != "" {
+		return line
+	}
+	return err.Error()
+}
+
+// quarantineFile moves a recording that failed validation out of CALLS_DIR
+// and records why. The call itself is marked failed by the caller.
+func (s *server) quarantineFile(filename, path string, size int64, cause error) {
+	if err := os.MkdirAll(s.cfg.QuarantineDir, 0o755); err != nil {
+		log.Printf("quarantine dir %s: %v", s.cfg.QuarantineDir, err)
+		return
+	}
+	dest := filepath.Join(s.cfg.QuarantineDir, filename)
+	if err := moveFile(path, dest); err != nil {
+		log.Printf("quarantine move failed for %s: %v", filename, err)
+		return
+	}
+	if _, err := execWithRetry(s.db, `INSERT INTO quarantine (filename, original_path, path, reason, size_bytes, quarantined_at) VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(filename) DO UPDATE SET original_path = excluded.original_path, path = excluded.path, reason = excluded.reason, size_bytes = excluded.size_bytes, quarantined_at = excluded.quarantined_at`,
+		filename, path, dest, cause.Error(), size, time.Now().UTC()); err != nil {
+		log.Printf("quarantine record failed for %s: %v", filename, err)
+	}
+	log.Printf("quarantined %s: %v", filename, cause)
+}
+
+// moveFile renames src to dst, copying across filesystems when it must.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// handleQuarantine serves the quarantine admin API:
+//
+//	GET  /api/quarantine[?limit=N]              files that failed validation, newest first
+//	POST /api/quarantine/{filename}/requeue     move a file back and transcribe it again
+func (s *server) handleQuarantine(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/quarantine"), "/")
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.listQuarantine(w, r)
+		return
+	}
+	filename, action, _ := strings.Cut(rest, "/")
+	if action != "requeue" || filename == "" || filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.requeueQuarantined(w, r, filename)
+}
+
+func (s *server) listQuarantine(w http.ResponseWriter, r *http.Request) {
+	limit := defaultQuarantineLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxQuarantineLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	rows, err := s.db.QueryContext(r.Context(), `SELECT filename, original_path, path, reason, size_bytes, quarantined_at FROM quarantine ORDER BY quarantined_at DESC LIMIT ?`, limit)
+	if err != nil {
+		log.Printf("quarantine list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	files := []quarantinedFile{}
+	for rows.Next() {
+		var f quarantinedFile
+		if err := rows.Scan(&f.Filename, &f.OriginalPath, &f.Path, &f.Reason, &f.SizeBytes, &f.QuarantinedAt); err != nil {
+			log.Printf("quarantine scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		files = append(files, f)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("quarantine list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"files": files})
+}
+
+// requeueQuarantined moves a quarantined file back to where it came from and
+// queues a forced transcription. The file is claimed in s.running first so the
+// watcher does not queue it a second time when it reappears in CALLS_DIR.
+func (s *server) requeueQuarantined(w http.ResponseWriter, r *http.Request, filename string) {
+	if s.queue == nil {
+		http.Error(w, "queue disabled", http.StatusServiceUnavailable)
+		return
+	}
+	var path string
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&path)
+	}, `SELECT path FROM quarantine WHERE filename = ?`, filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("quarantine lookup failed for %s: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if _, busy := s.running.LoadOrStore(filename, struct{}{}); busy {
+		http.Error(w, "call is already queued", http.StatusConflict)
+		return
+	}
+	dest := filepath.Join(s.cfg.CallsDir, filename)
+	if err := moveFile(path, dest); err != nil {
+		s.running.Delete(filename)
+		log.Printf("quarantine restore failed for %s: %v", filename, err)
+		http.Error(w, "restore failed", http.StatusInternalServerError)
+		return
+	}
+	if _, err := execWithRetry(s.db, `DELETE FROM quarantine WHERE filename = ?`, filename); err != nil {
+		log.Printf("quarantine delete failed for %s: %v", filename, err)
+	}
+	log.Printf("quarantined %s requeued by %s", filename, clientIP(r))
+	noteAudit(r, "quarantine.requeue", filename, map[string]string{"path": path}, map[string]string{"path": dest})
+	opts, _ := s.defaultOptions()
+	notify := strings.EqualFold(r.URL.Query().Get("notify"), "true")
+	enqueued := s.queueJob("ops", filename, notify, true, opts)
+	respondJSON(w, map[string]interface{}{"status": statusQueued, "filename": filename, "enqueued": enqueued})
+}
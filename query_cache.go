@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// maxQueryCacheEntries bounds the query cache. Keys are made of a window, an
+// audience and a few small parameters, so this is rarely reached.
+const maxQueryCacheEntries = 256
+
+// queryCache reuses the results of the dashboard's heavier queries for
+// QUERY_CACHE_TTL_SEC, keyed by endpoint and parameters, so a room full of
+// dashboards does not rescan the transcriptions table on every poll. The
+// whole cache is dropped when a call finishes or is deleted, so new calls
+// show up without waiting out the TTL. Calls finished by a separate worker
+// process still wait for it.
+type queryCache struct {
+	mu      sync.Mutex
+	gen     uint64
+	entries map[string]queryCacheEntry
+}
+
+type queryCacheEntry struct {
+	storedAt time.Time
+	value    interface{}
+}
+
+// load returns the cached value for key if it is younger than ttl, and
+// otherwise computes and stores it. A value computed while the cache was
+// invalidated is returned but not stored, since it may predate the write.
+func (c *queryCache) load(key string, ttl time.Duration, compute func() (interface{}, error)) (interface{}, error) {
+	if ttl <= 0 {
+		return compute()
+	}
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && time.Since(e.storedAt) < ttl {
+		c.mu.Unlock()
+		return e.value, nil
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	value, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return value, nil
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]queryCacheEntry)
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxQueryCacheEntries {
+		for k, e := range c.entries {
+			if time.Since(e.storedAt) >= ttl {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxQueryCacheEntries {
+			c.entries = make(map[string]queryCacheEntry)
+		}
+	}
+	c.entries[key] = queryCacheEntry{storedAt: time.Now(), value: value}
+	return value, nil
+}
+
+// invalidate drops every cached result.
+func (c *queryCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = nil
+}
+
+func (s *server) queryCacheTTL() time.Duration {
+	return time.Duration(s.liveConfig().QueryCacheTTLSec) * time.Second
+}
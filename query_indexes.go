@@ -0,0 +1,45 @@
+package main
+
+import "database/sql"
+
+// migrateAddQueryIndexes adds effective_ts, the time a call is listed and
+// counted under (its call timestamp, or when it arrived for calls without
+// one), and the indexes the list, stats and hotspot queries filter on. It is
+// a virtual generated column, so existing rows need no backfill and writers
+// never set it. hash is already indexed for duplicate lookups.
+func migrateAddQueryIndexes(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "transcriptions", "effective_ts", "DATETIME GENERATED ALWAYS AS (COALESCE(call_timestamp, created_at)) VIRTUAL"); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_transcriptions_status_effective_ts ON transcriptions(status, effective_ts)`,
+		`CREATE INDEX IF NOT EXISTS idx_transcriptions_deleted_effective_ts ON transcriptions(deleted_at, effective_ts)`,
+		`CREATE INDEX IF NOT EXISTS idx_transcriptions_call_type ON transcriptions(lower(coalesce(call_type, '')))`,
+	} {
+		if _, err := execWithRetry(db, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func downQueryIndexes(db *sql.DB) error {
+	for _, index := range []string{"idx_transcriptions_status_effective_ts", "idx_transcriptions_deleted_effective_ts", "idx_transcriptions_call_type"} {
+		if _, err := execWithRetry(db, `DROP INDEX IF EXISTS `+index); err != nil {
+			return err
+		}
+	}
+	// Generated columns are hidden from pragma_table_info, which
+	// dropColumnIfExists reads.
+	var n int
+	if err := queryRowWithRetry(db, func(row *sql.Row) error {
+		return row.Scan(&n)
+	}, `SELECT COUNT(*) FROM pragma_table_xinfo('transcriptions') WHERE name = 'effective_ts'`); err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	_, err := execWithRetry(db, `ALTER TABLE transcriptions DROP COLUMN effective_ts`)
+	return err
+}
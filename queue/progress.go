@@ -0,0 +1,195 @@
+package queue
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// StageQueued is reported for jobs waiting for a free worker.
+	StageQueued = "queued"
+	// StageRunning is the default stage for jobs that never report progress.
+	StageRunning = "running"
+
+	historySize       = 100
+	throughputWindow  = 15 * time.Minute
+	minThroughputJobs = 2
+)
+
+// JobState describes a queued or running job for operational dashboards.
+type JobState struct {
+	ID        string
+	FileName  string
+	Source    string
+	Stage     string
+	Attempt   int
+	QueuedAt  time.Time
+	StartedAt time.Time
+	Elapsed   time.Duration
+}
+
+// DrainEstimate projects how long the current backlog will take to clear.
+type DrainEstimate struct {
+	Backlog          int
+	RecentCompleted  int
+	ThroughputPerMin float64
+	AvgJobDuration   time.Duration
+	ETA              time.Duration
+	Known            bool
+}
+
+// jobProgress is the mutable state a running job reports through its context.
+type jobProgress struct {
+	mu      sync.Mutex
+	state   JobState
+	started time.Time
+}
+
+type completion struct {
+	finishedAt time.Time
+	duration   time.Duration
+}
+
+type progressKey struct{}
+
+// SetStage records the current pipeline stage for the job running under ctx.
+// It is a no-op when ctx was not created by the queue.
+func SetStage(ctx context.Context, stage string) {
+	p, ok := ctx.Value(progressKey{}).(*jobProgress)
+	if !ok || p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.Stage = stage
+	p.mu.Unlock()
+}
+
+// SetAttempt records the 1-based attempt number for the job running under ctx.
+func SetAttempt(ctx context.Context, attempt int) {
+	p, ok := ctx.Value(progressKey{}).(*jobProgress)
+	if !ok || p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.state.Attempt = attempt
+	p.mu.Unlock()
+}
+
+func (p *jobProgress) snapshot(now time.Time) JobState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state := p.state
+	state.StartedAt = p.started
+	state.Elapsed = now.Sub(p.started)
+	return state
+}
+
+// Jobs returns running jobs followed by pending jobs, oldest first within each group.
+func (q *Queue) Jobs() []JobState {
+	now := time.Now()
+	q.mu.RLock()
+	running := make([]JobState, 0, len(q.active))
+	for _, p := range q.active {
+		running = append(running, p.snapshot(now))
+	}
+	pending := make([]JobState, 0, len(q.enqueued))
+	for _, state := range q.enqueued {
+		state.Elapsed = now.Sub(state.QueuedAt)
+		pending = append(pending, state)
+	}
+	q.mu.RUnlock()
+
+	sort.Slice(running, func(i, j int) bool { return running[i].StartedAt.Before(running[j].StartedAt) })
+	sort.Slice(pending, func(i, j int) bool { return pending[i].QueuedAt.Before(pending[j].QueuedAt) })
+	return append(running, pending...)
+}
+
+// DrainEstimate estimates time-to-drain from recent completion throughput, falling
+// back to average job duration spread across the worker pool.
+func (q *Queue) DrainEstimate() DrainEstimate {
+	now := time.Now()
+	q.mu.RLock()
+	backlog := len(q.enqueued) + len(q.active)
+	history := append([]completion(nil), q.history...)
+	workers := q.workerCount
+	q.mu.RUnlock()
+
+	est := DrainEstimate{Backlog: backlog}
+	if len(history) == 0 {
+		est.Known = backlog == 0
+		return est
+	}
+
+	var total time.Duration
+	var oldest time.Time
+	for _, c := range history {
+		total += c.duration
+		if now.Sub(c.finishedAt) > throughputWindow {
+			continue
+		}
+		est.RecentCompleted++
+		if oldest.IsZero() || c.finishedAt.Before(oldest) {
+			oldest = c.finishedAt
+		}
+	}
+	est.AvgJobDuration = total / time.Duration(len(history))
+
+	if est.RecentCompleted >= minThroughputJobs {
+		span := now.Sub(oldest)
+		if span < time.Minute {
+			span = time.Minute
+		}
+		est.ThroughputPerMin = float64(est.RecentCompleted) / span.Minutes()
+	}
+
+	switch {
+	case backlog == 0:
+		est.Known = true
+	case est.ThroughputPerMin > 0:
+		est.ETA = time.Duration(float64(backlog) / est.ThroughputPerMin * float64(time.Minute))
+		est.Known = true
+	case est.AvgJobDuration > 0 && workers > 0:
+		rounds := math.Ceil(float64(backlog) / float64(workers))
+		est.ETA = time.Duration(rounds) * est.AvgJobDuration
+		est.Known = true
+	}
+	return est
+}
+
+// beginJob marks j running. It refuses while the queue is paused, checked
+// under the same lock, so a drain never sees an idle queue that a worker is
+// about to start a job on.
+func (q *Queue) beginJob(ctx context.Context, j Job) (context.Context, *jobProgress, bool) {
+	q.mu.Lock()
+	if len(q.paused) > 0 {
+		q.mu.Unlock()
+		return nil, nil, false
+	}
+	state, ok := q.enqueued[j.ID]
+	delete(q.enqueued, j.ID)
+	if !ok {
+		state = JobState{ID: j.ID, FileName: j.FileName, Source: j.Source}
+	}
+	state.Stage = StageRunning
+	state.Attempt = 1
+	p := &jobProgress{state: state, started: time.Now()}
+	q.active[j.ID] = p
+	q.mu.Unlock()
+	return context.WithValue(ctx, progressKey{}, p), p, true
+}
+
+func (q *Queue) finishJob(j Job, p *jobProgress) {
+	now := time.Now()
+	q.mu.Lock()
+	if q.active[j.ID] == p {
+		delete(q.active, j.ID)
+	}
+	q.history = append(q.history, completion{finishedAt: now, duration: now.Sub(p.started)})
+	if len(q.history) > historySize {
+		q.history = q.history[len(q.history)-historySize:]
+	}
+	q.mu.Unlock()
+}
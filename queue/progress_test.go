@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"alert_framework/metrics"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestJobsReportStageAndAttempt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := New(4, 1, 5*time.Second, metrics.New())
+	q.Start(ctx)
+
+	reported := make(chan struct{})
+	release := make(chan struct{})
+	q.Enqueue(Job{ID: "call.mp3", FileName: "call.mp3", Source: "watcher", Work: func(ctx context.Context) error {
+		SetStage(ctx, "transcribe")
+		SetAttempt(ctx, 2)
+		close(reported)
+		<-release
+		return nil
+	}})
+	q.Enqueue(Job{ID: "next.mp3", FileName: "next.mp3", Source: "watcher", Work: func(context.Context) error { return nil }})
+
+	select {
+	case <-reported:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("job did not start")
+	}
+
+	jobs := q.Jobs()
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	if jobs[0].ID != "call.mp3" || jobs[0].Stage != "transcribe" || jobs[0].Attempt != 2 {
+		t.Fatalf("unexpected running job state: %+v", jobs[0])
+	}
+	if jobs[0].StartedAt.IsZero() {
+		t.Fatalf("expected started_at for running job")
+	}
+	if jobs[1].ID != "next.mp3" || jobs[1].Stage != StageQueued {
+		t.Fatalf("unexpected pending job state: %+v", jobs[1])
+	}
+	if est := q.DrainEstimate(); est.Backlog != 2 || est.Known {
+		t.Fatalf("expected unknown estimate with backlog 2, got %+v", est)
+	}
+	close(release)
+}
+
+func TestDrainEstimateUsesRecentThroughput(t *testing.T) {
+	q := New(4, 2, time.Second, nil)
+	now := time.Now()
+	q.history = []completion{
+		{finishedAt: now.Add(-2 * time.Minute), duration: 30 * time.Second},
+		{finishedAt: now.Add(-1 * time.Minute), duration: 30 * time.Second},
+		{finishedAt: now, duration: 30 * time.Second},
+		{finishedAt: now.Add(-2 * time.Hour), duration: 30 * time.Second},
+	}
+	q.enqueued["a"] = JobState{ID: "a"}
+	q.enqueued["b"] = JobState{ID: "b"}
+	q.enqueued["c"] = JobState{ID: "c"}
+
+	est := q.DrainEstimate()
+	if !est.Known {
+		t.Fatalf("expected a known estimate")
+	}
+	if est.RecentCompleted != 3 {
+		t.Fatalf("expected 3 recent completions, got %d", est.RecentCompleted)
+	}
+	if est.ThroughputPerMin < 1.4 || est.ThroughputPerMin > 1.6 {
+		t.Fatalf("expected ~1.5 jobs/min, got %f", est.ThroughputPerMin)
+	}
+	if est.ETA < 110*time.Second || est.ETA > 130*time.Second {
+		t.Fatalf("expected ~2m ETA, got %s", est.ETA)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"alert_framework/metrics"
 	"context"
 	"log"
+	"sort"
 	"sync"
 	"time"
 )
@@ -15,6 +16,9 @@ type Job struct {
 	Source   string
 	Work     func(context.Context) error
 	OnFinish func(error)
+	// Timeout, when set, is called as a worker picks the job up and replaces
+	// the queue's per-job timeout if it returns a positive duration.
+	Timeout func() time.Duration
 }
 
 // Stats exposes current queue metrics.
@@ -22,6 +26,9 @@ type Stats struct {
 	Length      int
 	Capacity    int
 	WorkerCount int
+	Active      int
+	Paused      bool
+	PausedBy    []string
 }
 
 // Queue represents a bounded job queue with a fixed worker pool.
@@ -33,7 +40,11 @@ type Queue struct {
 	mu          sync.RWMutex
 	wg          sync.WaitGroup
 	metrics     *metrics.Metrics
-	enqueued    map[string]struct{}
+	enqueued    map[string]JobState
+	active      map[string]*jobProgress
+	history     []completion
+	paused      map[string]bool
+	resume      chan struct{}
 }
 
 // New creates a new Queue with the provided capacity, worker count, and per-job timeout.
@@ -43,7 +54,8 @@ func New(capacity, workerCount int, timeout time.Duration, m *metrics.Metrics) *
 		workerCount: workerCount,
 		timeout:     timeout,
 		metrics:     m,
-		enqueued:    make(map[string]struct{}),
+		enqueued:    make(map[string]JobState),
+		active:      make(map[string]*jobProgress),
 	}
 }
 
@@ -106,7 +118,7 @@ func (q *Queue) tryEnqueue(j Job, logDrop bool) bool {
 		}
 		return false
 	}
-	q.enqueued[j.ID] = struct{}{}
+	q.enqueued[j.ID] = JobState{ID: j.ID, FileName: j.FileName, Source: j.Source, Stage: StageQueued, QueuedAt: time.Now()}
 	q.mu.Unlock()
 	select {
 	case q.jobs <- j:
@@ -158,12 +170,108 @@ func (q *Queue) Stats() Stats {
 		Length:      length,
 		Capacity:    cap(q.jobs),
 		WorkerCount: q.workerCount,
+		Active:      len(q.active),
+		Paused:      len(q.paused) > 0,
+		PausedBy:    q.pauseReasons(),
+	}
+}
+
+// Pause stops workers from starting new jobs. Queued jobs stay in the queue and
+// running jobs finish normally. Each caller pauses with its own reason, and the
+// queue stays paused until every reason has been resumed, so one caller cannot
+// undo another's pause.
+func (q *Queue) Pause(reason string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.paused[reason] {
+		return
+	}
+	if len(q.paused) == 0 {
+		q.resume = make(chan struct{})
+	}
+	if q.paused == nil {
+		q.paused = make(map[string]bool)
+	}
+	q.paused[reason] = true
+}
+
+// Resume clears the pause held for reason. Workers pick up queued jobs again
+// once no reason is left.
+func (q *Queue) Resume(reason string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.paused[reason] {
+		return
+	}
+	delete(q.paused, reason)
+	if len(q.paused) == 0 {
+		close(q.resume)
+	}
+}
+
+// Paused reports whether the queue is currently paused for any reason.
+func (q *Queue) Paused() bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.paused) > 0
+}
+
+func (q *Queue) pauseReasons() []string {
+	if len(q.paused) == 0 {
+		return nil
+	}
+	reasons := make([]string, 0, len(q.paused))
+	for reason := range q.paused {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// WaitIdle blocks until no job is running, polling every interval. It returns
+// false if ctx ends first. Together with Pause it drains the queue: in-flight
+// jobs finish and queued ones stay put.
+func (q *Queue) WaitIdle(ctx context.Context, interval time.Duration) bool {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		q.mu.RLock()
+		active := len(q.active)
+		q.mu.RUnlock()
+		if active == 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitWhilePaused blocks until the queue is resumed. It returns false if ctx ends first.
+func (q *Queue) waitWhilePaused(ctx context.Context) bool {
+	for {
+		q.mu.RLock()
+		paused, resume := len(q.paused) > 0, q.resume
+		q.mu.RUnlock()
+		if !paused {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-resume:
+		}
 	}
 }
 
 func (q *Queue) worker(ctx context.Context) {
 	defer q.wg.Done()
 	for {
+		if !q.waitWhilePaused(ctx) {
+			return
+		}
 		select {
 		case <-ctx.Done():
 			return
@@ -171,23 +279,35 @@ func (q *Queue) worker(ctx context.Context) {
 			if !ok {
 				return
 			}
-			q.mu.Lock()
-			delete(q.enqueued, j.ID)
-			q.mu.Unlock()
-			q.handleJob(ctx, j)
+			for {
+				if !q.waitWhilePaused(ctx) {
+					return
+				}
+				if progressCtx, progress, ok := q.beginJob(ctx, j); ok {
+					q.handleJob(progressCtx, j, progress)
+					break
+				}
+			}
 		}
 	}
 }
 
-func (q *Queue) handleJob(ctx context.Context, j Job) {
+func (q *Queue) handleJob(progressCtx context.Context, j Job, progress *jobProgress) {
 	start := time.Now()
+	defer q.finishJob(j, progress)
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("job %s panic recovered: %v", j.ID, r)
 		}
 	}()
 
-	jobCtx, cancel := context.WithTimeout(ctx, q.timeout)
+	timeout := q.timeout
+	if j.Timeout != nil {
+		if t := j.Timeout(); t > 0 {
+			timeout = t
+		}
+	}
+	jobCtx, cancel := context.WithTimeout(progressCtx, timeout)
 	err := j.Work(jobCtx)
 	cancel()
 	if j.OnFinish != nil {
@@ -207,6 +327,23 @@ func (q *Queue) handleJob(ctx context.Context, j Job) {
 	log.Printf("job_source=%s file=%s status=%s err=%v duration_ms=%d", j.Source, file, status, err, time.Since(start).Milliseconds())
 }
 
+// ScaleTimeout returns base plus perMinute for every minute of audio in each
+// of stages passes over it, capped at ceiling. base is also the floor, so
+// short or unprobed recordings keep the default timeout.
+func ScaleTimeout(base, perMinute, ceiling, audio time.Duration, stages int) time.Duration {
+	if audio <= 0 || perMinute <= 0 || stages <= 0 {
+		return base
+	}
+	timeout := base + time.Duration(float64(perMinute)*audio.Minutes()*float64(stages))
+	if ceiling > 0 && timeout > ceiling {
+		timeout = ceiling
+	}
+	if timeout < base {
+		return base
+	}
+	return timeout
+}
+
 // Healthy returns true if the queue has been started.
 func (q *Queue) Healthy() bool {
 	q.mu.RLock()
@@ -60,3 +60,132 @@ func TestQueueRejectsDuplicateJobs(t *testing.T) {
 		t.Fatalf("expected duplicate enqueue to be rejected")
 	}
 }
+
+func TestPausedQueueHoldsJobsUntilResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := New(2, 1, time.Second, metrics.New())
+	q.Start(ctx)
+	q.Pause("test")
+
+	ran := make(chan struct{})
+	if !q.Enqueue(Job{ID: "held", Work: func(context.Context) error { close(ran); return nil }}) {
+		t.Fatalf("expected enqueue to succeed while paused")
+	}
+
+	select {
+	case <-ran:
+		t.Fatalf("job ran while queue was paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if !q.Stats().Paused {
+		t.Fatalf("expected stats to report paused queue")
+	}
+	if jobs := q.Jobs(); len(jobs) != 1 || jobs[0].Stage != StageQueued {
+		t.Fatalf("expected one queued job while paused, got %+v", jobs)
+	}
+
+	q.Resume("test")
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("job did not run after resume")
+	}
+}
+
+func TestPauseReasonsAreIndependent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := New(2, 1, time.Second, metrics.New())
+	q.Start(ctx)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	q.Enqueue(Job{ID: "running", Work: func(context.Context) error { close(started); <-release; return nil }})
+	<-started
+
+	q.Pause("admin")
+	q.Pause("outage")
+	ran := make(chan struct{})
+	q.Enqueue(Job{ID: "held", Work: func(context.Context) error { close(ran); return nil }})
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	idle := q.WaitIdle(waitCtx, 5*time.Millisecond)
+	waitCancel()
+	if idle {
+		t.Fatalf("expected WaitIdle to time out while a job is running")
+	}
+	close(release)
+	if !q.WaitIdle(ctx, 5*time.Millisecond) {
+		t.Fatalf("expected queue to go idle once the running job finished")
+	}
+
+	q.Resume("admin")
+	if got := q.Stats().PausedBy; len(got) != 1 || got[0] != "outage" {
+		t.Fatalf("expected outage pause to remain, got %v", got)
+	}
+	select {
+	case <-ran:
+		t.Fatalf("job ran while another pause reason was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	q.Resume("outage")
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("job did not run after every pause was resumed")
+	}
+}
+
+func TestJobTimeoutOverridesQueueTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q := New(2, 1, 50*time.Millisecond, metrics.New())
+	q.Start(ctx)
+
+	result := make(chan error, 1)
+	q.Enqueue(Job{
+		ID:      "long",
+		Timeout: func() time.Duration { return 2 * time.Second },
+		Work: func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				result <- ctx.Err()
+			case <-time.After(200 * time.Millisecond):
+				result <- nil
+			}
+			return nil
+		},
+	})
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected job timeout to outlast the queue timeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("job did not finish")
+	}
+}
+
+func TestScaleTimeout(t *testing.T) {
+	base, perMinute, ceiling := time.Minute, 10*time.Second, time.Hour
+	if got := ScaleTimeout(base, perMinute, ceiling, 0, 2); got != base {
+		t.Fatalf("expected unprobed audio to keep the base timeout, got %s", got)
+	}
+	if got := ScaleTimeout(base, perMinute, ceiling, 30*time.Second, 2); got != 70*time.Second {
+		t.Fatalf("expected 70s for 30s of audio over 2 stages, got %s", got)
+	}
+	if got := ScaleTimeout(base, perMinute, ceiling, 45*time.Minute, 6); got != 46*time.Minute {
+		t.Fatalf("expected 46m for 45m of audio over 6 stages, got %s", got)
+	}
+	if got := ScaleTimeout(base, perMinute, ceiling, 3*time.Hour, 6); got != ceiling {
+		t.Fatalf("expected the ceiling, got %s", got)
+	}
+	if got := ScaleTimeout(base, 0, ceiling, time.Hour, 6); got != base {
+		t.Fatalf("expected no scaling without a per-minute allowance, got %s", got)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reasons the transcription queue is paused. Each is resumed independently, so
+// an admin pause survives the OpenAI breaker closing and vice versa.
+const (
+	queuePauseOpenAI = "openai_outage"
+	queuePauseAdmin  = "admin"
+	queuePauseDrain  = "drain"
+)
+
+const (
+	defaultDrainTimeout = 60 * time.Second
+	maxDrainTimeout     = 10 * time.Minute
+)
+
+type queueControlResponse struct {
+	State    string   `json:"state"`
+	PausedBy []string `json:"paused_by,omitempty"`
+	Queued   int      `json:"queued"`
+	Active   int      `json:"active"`
+}
+
+// handleOpsQueue serves the admin queue controls:
+//
+//	POST /ops/queue/pause   stop workers starting jobs; enqueues still succeed
+//	POST /ops/queue/resume  undo pause and drain
+//	POST /ops/queue/drain   pause, then wait for in-flight jobs to finish
+func (s *server) handleOpsQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if s.queue == nil {
+		http.Error(w, "queue disabled", http.StatusServiceUnavailable)
+		return
+	}
+	switch action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/ops/queue/"), "/"); action {
+	case "pause":
+		s.queue.Pause(queuePauseAdmin)
+		log.Printf("transcription queue paused by %s", clientIP(r))
+	case "resume":
+		s.queue.Resume(queuePauseAdmin)
+		s.queue.Resume(queuePauseDrain)
+		log.Printf("transcription queue resumed by %s", clientIP(r))
+	case "drain":
+		timeout := defaultDrainTimeout
+		if raw := strings.TrimSpace(r.URL.Query().Get("timeout")); raw != "" {
+			secs, err := strconv.Atoi(raw)
+			if err != nil || secs < 0 || time.Duration(secs)*time.Second > maxDrainTimeout {
+				http.Error(w, fmt.Sprintf("timeout must be between 0 and %d", int(maxDrainTimeout.Seconds())), http.StatusBadRequest)
+				return
+			}
+			timeout = time.Duration(secs) * time.Second
+		}
+		s.queue.Pause(queuePauseDrain)
+		log.Printf("transcription queue draining for %s", clientIP(r))
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		drained := s.queue.WaitIdle(ctx, 250*time.Millisecond)
+		cancel()
+		if !drained {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			respondJSON(w, s.queueControlState())
+			return
+		}
+		log.Printf("transcription queue drained")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	respondJSON(w, s.queueControlState())
+}
+
+func (s *server) queueControlState() queueControlResponse {
+	stats := s.queue.Stats()
+	resp := queueControlResponse{State: "running", PausedBy: stats.PausedBy, Queued: stats.Length, Active: stats.Active}
+	if !stats.Paused {
+		return resp
+	}
+	resp.State = "paused"
+	for _, reason := range stats.PausedBy {
+		if reason != queuePauseDrain {
+			continue
+		}
+		resp.State = "draining"
+		if stats.Active == 0 {
+			resp.State = "drained"
+		}
+	}
+	return resp
+}
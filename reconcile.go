@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alert_framework/errcode"
+)
+
+// reconciler holds the reconciliation run in progress, if any, and the
+// report of the last one to finish.
+type reconciler struct {
+	mu      sync.Mutex
+	running bool
+	last    *reconcileReport
+}
+
+type reconcileRequest struct {
+	Window         string `json:"window"`
+	Hashes         *bool  `json:"hashes"`
+	EnqueueMissing bool   `json:"enqueue_missing"`
+}
+
+type reconcileReport struct {
+	StartedAt      time.Time           `json:"started_at"`
+	FinishedAt     time.Time           `json:"finished_at"`
+	Window         string              `json:"window"`
+	RequestedBy    string              `json:"requested_by,omitempty"`
+	FilesScanned   int                 `json:"files_scanned"`
+	RowsChecked    int                 `json:"rows_checked"`
+	HashesChecked  int                 `json:"hashes_checked"`
+	MissingRows    []reconcileFile     `json:"missing_rows"`
+	MissingAudio   []reconcileRow      `json:"missing_audio"`
+	HashMismatches []reconcileMismatch `json:"hash_mismatches"`
+	Enqueued       int                 `json:"enqueued"`
+	Error          string              `json:"error,omitempty"`
+}
+
+// reconcileFile is a recording in CALLS_DIR with no call.
+type reconcileFile struct {
+	Filename   string    `json:"filename"`
+	SizeBytes  int64     `json:"size_bytes"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Queued     bool      `json:"queued,omitempty"`
+}
+
+// reconcileRow is a call whose audio is gone from CALLS_DIR.
+type reconcileRow struct {
+	Filename  string    `json:"filename"`
+	Status    string    `json:"status"`
+	CallTime  time.Time `json:"call_time"`
+	Expected  string    `json:"expected_path"`
+	Processed string    `json:"processed_path,omitempty"`
+}
+
+// reconcileMismatch is a call whose recording no longer hashes to what was
+// stored when it was transcribed.
+type reconcileMismatch struct {
+	Filename string `json:"filename"`
+	Stored   string `json:"stored_hash"`
+	Actual   string `json:"actual_hash"`
+}
+
+// handleReconcile serves /api/admin/reconcile. GET returns the report of the
+// last reconciliation and whether one is running; POST starts one, which
+// compares CALLS_DIR with the calls table and, with enqueue_missing, queues
+// the recordings that have no call.
+func (s *server) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.reconcile.mu.Lock()
+		resp := map[string]interface{}{"running": s.reconcile.running, "report": s.reconcile.last}
+		s.reconcile.mu.Unlock()
+		respondJSON(w, resp)
+	case http.MethodPost:
+		var req reconcileRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid json", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.EnqueueMissing && !s.canEnqueue() {
+			http.Error(w, "queue disabled", http.StatusServiceUnavailable)
+			return
+		}
+		s.reconcile.mu.Lock()
+		if s.reconcile.running {
+			s.reconcile.mu.Unlock()
+			http.Error(w, "reconciliation already running", http.StatusConflict)
+			return
+		}
+		s.reconcile.running = true
+		s.reconcile.mu.Unlock()
+
+		name, window := normalizeWindowName(req.Window, "all")
+		report := &reconcileReport{StartedAt: time.Now().UTC(), Window: name, RequestedBy: settingsActor(r)}
+		noteAudit(r, "calls.reconcile", name, nil, map[string]interface{}{"window": name, "enqueue_missing": req.EnqueueMissing})
+		go s.runReconcile(s.ctx, report, window, req.Hashes == nil || *req.Hashes, req.EnqueueMissing)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		respondJSON(w, map[string]interface{}{"status": "running", "started_at": report.StartedAt, "status_url": "/api/admin/reconcile"})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// runReconcile fills report and stores it as the last one.
+func (s *server) runReconcile(ctx context.Context, report *reconcileReport, window time.Duration, hashes, enqueue bool) {
+	var since *time.Time
+	if window > 0 {
+		t := report.StartedAt.Add(-window)
+		since = &t
+	}
+	if err := s.reconcileCallsDir(ctx, report, since, hashes); err != nil {
+		log.Printf("reconciliation failed: %v", err)
+		report.Error = err.Error()
+	} else if enqueue {
+		opts, _ := s.defaultOptions()
+		for i := range report.MissingRows {
+			if s.queueJob("reconcile", report.MissingRows[i].Filename, false, false, opts) {
+				report.MissingRows[i].Queued = true
+				report.Enqueued++
+			}
+		}
+	}
+	report.FinishedAt = time.Now().UTC()
+	log.Printf("reconciliation of %s: %d files, %d calls; %d without a call, %d without audio, %d hash mismatches, %d queued",
+		s.cfg.CallsDir, report.FilesScanned, report.RowsChecked, len(report.MissingRows), len(report.MissingAudio), len(report.HashMismatches), report.Enqueued)
+
+	s.reconcile.mu.Lock()
+	s.reconcile.running = false
+	s.reconcile.last = report
+	s.reconcile.mu.Unlock()
+}
+
+// reconcileCallsDir compares the recordings in CALLS_DIR modified since
+// since with the calls made since then. CAD placeholders have no audio, and
+// deleted or quarantined calls have had theirs removed on purpose, so none of
+// them count as missing audio.
+func (s *server) reconcileCallsDir(ctx context.Context, report *reconcileReport, since *time.Time, hashes bool) error {
+	report.MissingRows = []reconcileFile{}
+	report.MissingAudio = []reconcileRow{}
+	report.HashMismatches = []reconcileMismatch{}
+
+	files, err := s.callsDirAudio(since)
+	if err != nil {
+		return err
+	}
+	report.FilesScanned = len(files)
+
+	// Every path a call refers to counts as known, so filtered copies kept
+	// beside their recording and aliases of a call are not reported as
+	// strays.
+	known := map[string]bool{}
+	rows, err := s.db.QueryContext(ctx, `SELECT filename, COALESCE(source_path, ''), COALESCE(processed_path, '') FROM transcriptions
+UNION ALL SELECT alias, '', '' FROM call_aliases`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var filename, source, processed string
+		if err := rows.Scan(&filename, &source, &processed); err != nil {
+			rows.Close()
+			return err
+		}
+		known[filename] = true
+		for _, p := range []string{source, processed} {
+			if p != "" {
+				known[filepath.Base(p)] = true
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if known[f.Name()] {
+			continue
+		}
+		if _, busy := s.running.Load(f.Name()); busy {
+			continue
+		}
+		report.MissingRows = append(report.MissingRows, reconcileFile{Filename: f.Name(), SizeBytes: f.Size(), ModifiedAt: f.ModTime().UTC()})
+	}
+
+	clause := `WHERE status != ? AND deleted_at IS NULL AND COALESCE(error_code, '') != ? AND COALESCE(source_path, '') != ''`
+	args := []interface{}{statusCAD, errcode.InvalidAudio}
+	if since != nil {
+		clause += ` AND effective_ts >= ?`
+		args = append(args, *since)
+	}
+	calls, err := s.store.Select(ctx, clause+` ORDER BY filename`, args...)
+	if err != nil {
+		return err
+	}
+	report.RowsChecked = len(calls)
+	for _, t := range calls {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		path := filepath.Join(s.cfg.CallsDir, t.Filename)
+		if !fileExists(path) {
+			if s.archiveSourcePath(t) == "" {
+				report.MissingAudio = append(report.MissingAudio, reconcileRow{
+					Filename:  t.Filename,
+					Status:    t.Status,
+					CallTime:  derefTime(t.CallTimestamp, t.CreatedAt),
+					Expected:  path,
+					Processed: t.ProcessedPath,
+				})
+			}
+			continue
+		}
+		stored := strings.TrimSpace(derefString(t.Hash, ""))
+		if !hashes || stored == "" {
+			continue
+		}
+		actual, err := hashFile(path)
+		if err != nil {
+			log.Printf("reconcile could not hash %s: %v", path, err)
+			continue
+		}
+		report.HashesChecked++
+		if actual != stored {
+			report.HashMismatches = append(report.HashMismatches, reconcileMismatch{Filename: t.Filename, Stored: stored, Actual: actual})
+		}
+	}
+	return nil
+}
+
+// callsDirAudio lists the recordings in CALLS_DIR the watcher would pick up,
+// by name: regular audio files that are neither hidden, nor write probes, nor
+// still being written by a remote poller. Files modified before since are
+// left out.
+func (s *server) callsDirAudio(since *time.Time) ([]os.FileInfo, error) {
+	files, err := listAudioDir(s.cfg.CallsDir, since)
+	if err != nil {
+		return nil, err
+	}
+	out := files[:0]
+	for _, f := range files {
+		if _, remote := s.remoteFiles.Load(f.Name()); !remote {
+			out = append(out, f)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out, nil
+}
+
+// derefTime returns *t, or fallback when t is nil.
+func derefTime(t *time.Time, fallback time.Time) time.Time {
+	if t == nil {
+		return fallback
+	}
+	return *t
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"alert_framework/formatting"
+	"alert_framework/usage"
+)
+
+const redactPrompt = `You redact emergency radio transcripts before they are published.
+Return the transcript unchanged except:
+- replace names of people with [NAME]
+- replace dates of birth with [DOB]
+- replace phone numbers and ID numbers with [PHONE] or [ID]
+- replace medical history, medications and diagnoses with [MEDICAL]
+Keep addresses, unit names, agencies, ages, sex and the nature of the call (e.g. "difficulty breathing", "fall with injury").
+Reply with only the redacted transcript.`
+
+// redactTranscript produces the public transcript used for notifications and
+// non-admin API responses. The regex pass always runs (unless redaction is
+// off); the LLM pass runs in llm mode and falls back to the regex result.
+// Profanity is masked last when PROFANITY_FILTER is on.
+func (s *server) redactTranscript(text string) string {
+	if strings.TrimSpace(text) == "" || s.cfg.RedactionMode != "llm" {
+		return s.scrubText(text)
+	}
+	redacted := formatting.RedactPII(text)
+	out, err := s.redactWithLLM(redacted)
+	if err != nil {
+		log.Printf("llm redaction failed, using regex result: %v", err)
+		out = redacted
+	}
+	if s.cfg.ProfanityFilter {
+		out = formatting.MaskProfanity(out)
+	}
+	return out
+}
+
+// scrubText applies the cheap public-text passes: regex redaction unless
+// redaction is off, then profanity masking when enabled.
+func (s *server) scrubText(text string) string {
+	if s.cfg.RedactionMode != "off" {
+		text = formatting.RedactPII(text)
+	}
+	if s.cfg.ProfanityFilter {
+		text = formatting.MaskProfanity(text)
+	}
+	return text
+}
+
+func (s *server) redactWithLLM(text string) (string, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return "", errors.New("OPENAI_API_KEY not set")
+	}
+	payload := map[string]interface{}{
+		"model":       s.cfg.LLM.Redact.ModelOr("gpt-4.1-mini"),
+		"temperature": 0,
+		"messages": []map[string]string{
+			{"role": "system", "content": redactPrompt},
+			{"role": "user", "content": text},
+		},
+	}
+	buf, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(usage.WithStage(context.Background(), usage.StageRedact), "POST", s.cfg.LLM.Redact.URL("/v1/chat/completions"), bytes.NewReader(buf))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("redaction status %d: %s", resp.StatusCode, string(b))
+	}
+	var parsed struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return "", err
+	}
+	if len(parsed.Choices) == 0 {
+		return "", errors.New("empty redaction")
+	}
+	out := strings.TrimSpace(parsed.Choices[0].Message.Content)
+	// A redaction only ever shortens or swaps spans for placeholders; a much
+	// longer reply means the model answered instead of redacting.
+	if out == "" || len(out) > len(text)+len(text)/5+40 {
+		return "", errors.New("redaction output rejected")
+	}
+	// The regex floor must survive the LLM pass.
+	return formatting.RedactPII(out), nil
+}
+
+// publicRecord returns t with every transcript-bearing field replaced by the
+// redacted public transcript, so anything derived from it (summaries,
+// segments, alert text) is safe to publish.
+func (s *server) publicRecord(t transcription) transcription {
+	if s.cfg.RedactionMode == "off" && !s.cfg.ProfanityFilter {
+		return t
+	}
+	public := derefString(t.PublicTranscript, "")
+	if public == "" {
+		if text := pickTranscript(&t); text != nil && strings.TrimSpace(*text) != "" {
+			public = s.scrubText(*text)
+		}
+	}
+	publicPtr := nullableString(public)
+	t.PublicTranscript = publicPtr
+	t.Transcript = publicPtr
+	t.RawTranscript = publicPtr
+	t.CleanTranscript = publicPtr
+	t.NormalizedTranscript = publicPtr
+	t.DiarizedJSON = nil
+	if t.Translation != nil {
+		t.Translation = nullableString(s.scrubText(*t.Translation))
+	}
+	if t.DraftTranscript != nil {
+		t.DraftTranscript = nullableString(s.scrubText(*t.DraftTranscript))
+	}
+	return t
+}
+
+// responseFor renders t for r, redacting transcripts unless the request
+// carries the admin token.
+func (s *server) responseFor(r *http.Request, t transcription, baseURL string) transcriptionResponse {
+	return s.responseView(isAdminRequest(r), t, baseURL)
+}
+
+// responseView is responseFor for callers that authenticate admins some
+// other way than X-Admin-Token.
+func (s *server) responseView(admin bool, t transcription, baseURL string) transcriptionResponse {
+	if admin {
+		resp := s.toResponse(t, baseURL)
+		resp.Flags = formatting.CallFlags(derefString(t.CallType, ""), derefString(pickTranscript(&t), ""))
+		return resp
+	}
+	return s.toResponse(s.publicRecord(t), baseURL)
+}
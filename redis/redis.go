@@ -0,0 +1,330 @@
+// Package redis speaks just enough RESP to use a Redis list as a shared job
+// queue: push, a reliable blocking pop into a processing list, removal and
+// length. A Client holds one connection and sends one command at a time,
+// which is all the queue needs and keeps a client library out of the build.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// ErrNil is returned when Redis answers with a nil reply, such as a pop from
+// an empty list.
+var ErrNil = errors.New("redis: nil reply")
+
+// Error is an error reply from the server.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// Options is what a redis:// or rediss:// URL describes.
+type Options struct {
+	Addr     string
+	TLS      bool
+	Username string
+	Password string
+	DB       int
+}
+
+// ParseURL checks a Redis URL of the form
+// redis[s]://[[user]:password@]host[:port][/db].
+func ParseURL(raw string) (Options, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return Options{}, fmt.Errorf("invalid redis url %q", raw)
+	}
+	var opts Options
+	switch strings.ToLower(u.Scheme) {
+	case "redis":
+	case "rediss":
+		opts.TLS = true
+	default:
+		return Options{}, fmt.Errorf("unsupported redis scheme %q", u.Scheme)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "6379"
+	}
+	opts.Addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		opts.Username = u.User.Username()
+		opts.Password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 {
+			return Options{}, fmt.Errorf("invalid redis database %q", db)
+		}
+		opts.DB = n
+	}
+	return opts, nil
+}
+
+// Client is a single Redis connection, dialled on first use and redialled
+// after a network error. It is safe for concurrent use, but commands are
+// serialised, so a blocking pop holds it until it returns.
+type Client struct {
+	opts Options
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// New returns a client for the server at rawURL without connecting.
+func New(rawURL string) (*Client, error) {
+	opts, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{opts: opts}, nil
+}
+
+// Close drops the connection. The client dials again if used afterwards.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeLocked()
+}
+
+func (c *Client) closeLocked() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.r = nil, nil
+	return err
+}
+
+// Do sends one command and returns its reply: a string for simple and bulk
+// strings, an int64 for integers and a []interface{} for arrays. A nil reply
+// is ErrNil and an error reply is an Error. Without a deadline on ctx the
+// command gets ten seconds.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.dialLocked(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(ctx, args)
+	var serverErr Error
+	if err != nil && !errors.Is(err, ErrNil) && !errors.As(err, &serverErr) {
+		c.closeLocked()
+	}
+	return reply, err
+}
+
+func (c *Client) dialLocked(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: defaultTimeout}
+	var conn net.Conn
+	var err error
+	if c.opts.TLS {
+		host, _, _ := net.SplitHostPort(c.opts.Addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", c.opts.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	}
+	if err != nil {
+		return fmt.Errorf("redis dial: %w", err)
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	if c.opts.Password != "" {
+		auth := []string{"AUTH", c.opts.Password}
+		if c.opts.Username != "" {
+			auth = []string{"AUTH", c.opts.Username, c.opts.Password}
+		}
+		if _, err := c.roundTrip(ctx, auth); err != nil {
+			c.closeLocked()
+			return fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := c.roundTrip(ctx, []string{"SELECT", strconv.Itoa(c.opts.DB)}); err != nil {
+			c.closeLocked()
+			return fmt.Errorf("redis select: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+	conn := c.conn
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if _, err := c.conn.Write(encodeCommand(args)); err != nil {
+		return nil, fmt.Errorf("redis write: %w", err)
+	}
+	reply, err := readReply(c.r)
+	if err != nil && ctx.Err() != nil && !errors.Is(err, ErrNil) {
+		return nil, ctx.Err()
+	}
+	return reply, err
+}
+
+// Ping checks the connection.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// LPush adds values to the head of the list at key and returns its new
+// length.
+func (c *Client) LPush(ctx context.Context, key string, values ...string) (int64, error) {
+	return c.integer(ctx, append([]string{"LPUSH", key}, values...)...)
+}
+
+// RPush adds values to the tail of the list at key and returns its new
+// length.
+func (c *Client) RPush(ctx context.Context, key string, values ...string) (int64, error) {
+	return c.integer(ctx, append([]string{"RPUSH", key}, values...)...)
+}
+
+// LLen returns the length of the list at key.
+func (c *Client) LLen(ctx context.Context, key string) (int64, error) {
+	return c.integer(ctx, "LLEN", key)
+}
+
+// LRem removes up to count occurrences of value from the list at key (all
+// of them when count is 0) and returns how many went.
+func (c *Client) LRem(ctx context.Context, key string, count int, value string) (int64, error) {
+	return c.integer(ctx, "LREM", key, strconv.Itoa(count), value)
+}
+
+// RPopLPush moves the tail of src to the head of dst and returns it, or
+// ErrNil when src is empty.
+func (c *Client) RPopLPush(ctx context.Context, src, dst string) (string, error) {
+	return c.str(ctx, "RPOPLPUSH", src, dst)
+}
+
+// BRPopLPush is RPopLPush that waits up to timeout, in whole seconds, for src
+// to have an item. It returns ErrNil when none arrived in time.
+func (c *Client) BRPopLPush(ctx context.Context, src, dst string, timeout time.Duration) (string, error) {
+	secs := int(timeout / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(secs)*time.Second+defaultTimeout)
+	defer cancel()
+	return c.str(ctx, "BRPOPLPUSH", src, dst, strconv.Itoa(secs))
+}
+
+func (c *Client) integer(ctx context.Context, args ...string) (int64, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis %s: unexpected reply %T", args[0], reply)
+	}
+	return n, nil
+}
+
+func (c *Client) str(ctx context.Context, args ...string) (string, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return "", err
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return "", fmt.Errorf("redis %s: unexpected reply %T", args[0], reply)
+	}
+	return s, nil
+}
+
+func encodeCommand(args []string) []byte {
+	out := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		out = append(out, '$')
+		out = strconv.AppendInt(out, int64(len(arg)), 10)
+		out = append(out, "\r\n"...)
+		out = append(out, arg...)
+		out = append(out, "\r\n"...)
+	}
+	return out
+}
+
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	body := line[1:]
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n == -1 {
+			return nil, ErrNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("redis read: %w", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n == -1 {
+			return nil, ErrNil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readReply(r)
+			if err != nil && !errors.Is(err, ErrNil) {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", line[0])
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("redis read: %w", err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
@@ -0,0 +1,191 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers the list commands the queue uses from an in-memory
+// store, and records every command it was sent. BRPOPLPUSH never blocks.
+type fakeServer struct {
+	mu       sync.Mutex
+	lists    map[string][]string
+	commands []string
+}
+
+func startFakeServer(t *testing.T) (*fakeServer, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeServer{lists: map[string][]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, "redis://" + ln.Addr().String()
+}
+
+func (f *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		cmd, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items := cmd.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = item.(string)
+		}
+		conn.Write([]byte(f.exec(args)))
+	}
+}
+
+func (f *fakeServer) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, strings.Join(args, " "))
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "LPUSH":
+		f.lists[args[1]] = append(append([]string(nil), args[2:]...), f.lists[args[1]]...)
+		return ":" + strconv.Itoa(len(f.lists[args[1]])) + "\r\n"
+	case "RPUSH":
+		f.lists[args[1]] = append(f.lists[args[1]], args[2:]...)
+		return ":" + strconv.Itoa(len(f.lists[args[1]])) + "\r\n"
+	case "LLEN":
+		return ":" + strconv.Itoa(len(f.lists[args[1]])) + "\r\n"
+	case "LREM":
+		var kept []string
+		removed := 0
+		for _, v := range f.lists[args[1]] {
+			if v == args[3] {
+				removed++
+				continue
+			}
+			kept = append(kept, v)
+		}
+		f.lists[args[1]] = kept
+		return ":" + strconv.Itoa(removed) + "\r\n"
+	case "RPOPLPUSH", "BRPOPLPUSH":
+		src := f.lists[args[1]]
+		if len(src) == 0 {
+			return "$-1\r\n"
+		}
+		v := src[len(src)-1]
+		f.lists[args[1]] = src[:len(src)-1]
+		f.lists[args[2]] = append([]string{v}, f.lists[args[2]]...)
+		return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func TestQueueRoundTrip(t *testing.T) {
+	f, url := startFakeServer(t)
+	c, err := New(strings.Replace(url, "redis://", "redis://worker:s3cret@", 1) + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+	for _, v := range []string{"a", "b c\r\n"} {
+		if _, err := c.LPush(ctx, "jobs", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := c.BRPopLPush(ctx, "jobs", "jobs:processing", time.Second)
+	if err != nil || got != "a" {
+		t.Fatalf("first pop = %q, %v", got, err)
+	}
+	got, err = c.RPopLPush(ctx, "jobs", "jobs:processing")
+	if err != nil || got != "b c\r\n" {
+		t.Fatalf("second pop = %q, %v", got, err)
+	}
+	if _, err := c.BRPopLPush(ctx, "jobs", "jobs:processing", time.Second); !errors.Is(err, ErrNil) {
+		t.Fatalf("empty pop err = %v", err)
+	}
+	if n, err := c.LRem(ctx, "jobs:processing", 1, "a"); err != nil || n != 1 {
+		t.Fatalf("lrem = %d, %v", n, err)
+	}
+	if n, err := c.LLen(ctx, "jobs:processing"); err != nil || n != 1 {
+		t.Fatalf("llen = %d, %v", n, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.commands[0] != "AUTH worker s3cret" || f.commands[1] != "SELECT 2" {
+		t.Fatalf("handshake = %q", f.commands[:2])
+	}
+}
+
+func TestErrorReply(t *testing.T) {
+	_, url := startFakeServer(t)
+	c, _ := New(url)
+	defer c.Close()
+	_, err := c.Do(context.Background(), "FLUSHALL")
+	var serverErr Error
+	if !errors.As(err, &serverErr) || !strings.Contains(err.Error(), "unknown command") {
+		t.Fatalf("err = %v", err)
+	}
+	// An error reply leaves the connection usable.
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedialAfterDisconnect(t *testing.T) {
+	_, url := startFakeServer(t)
+	c, _ := New(url)
+	defer c.Close()
+	ctx := context.Background()
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	c.conn.Close()
+	c.mu.Unlock()
+	if err := c.Ping(ctx); err == nil {
+		t.Fatal("expected an error on the closed connection")
+	}
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("redial: %v", err)
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	cases := map[string]Options{
+		"redis://cache.local":             {Addr: "cache.local:6379"},
+		"rediss://:pw@cache.local:6380/3": {Addr: "cache.local:6380", TLS: true, Password: "pw", DB: 3},
+		"redis://u:p@10.0.0.5":            {Addr: "10.0.0.5:6379", Username: "u", Password: "p"},
+	}
+	for raw, want := range cases {
+		got, err := ParseURL(raw)
+		if err != nil || got != want {
+			t.Errorf("%s: got %+v %v", raw, got, err)
+		}
+	}
+	for _, raw := range []string{"", "http://cache", "cache:6379", "redis://cache/x"} {
+		if _, err := ParseURL(raw); err == nil {
+			t.Errorf("%s: expected error", raw)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"alert_framework/config"
+)
+
+type reloadResponse struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// liveConfig returns the configuration with the latest reloaded values. Code
+// reading a field config.ApplyReload can change must go through it; other
+// fields are fixed at startup and can be read from s.cfg directly.
+func (s *server) liveConfig() config.Config {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.cfg
+}
+
+// reloadConfig re-reads the environment and config file and applies the
+// settings that are safe to change while running. Changed settings that need
+// a restart are reported and left alone; a config that fails to load or
+// validate changes nothing.
+func (s *server) reloadConfig() (reloadResponse, error) {
+	next, err := config.Reload()
+	if err != nil {
+		return reloadResponse{}, err
+	}
+	s.cfgMu.Lock()
+	applied, restart := config.ApplyReload(&s.cfg, next)
+	live := s.cfg
+	s.cfgMu.Unlock()
+
+	if s.rollups != nil {
+		s.rollups.SetConfig(live.Rollup)
+	}
+	if s.refiner != nil {
+		s.refiner.SetTemplates(live.NLP)
+	}
+	s.setFilenamePatterns(live.FilenamePatterns)
+	log.Printf("config reloaded: applied=%v restart_required=%v", applied, restart)
+	resp := reloadResponse{Applied: applied, RestartRequired: restart}
+	if resp.Applied == nil {
+		resp.Applied = []string{}
+	}
+	if resp.RestartRequired == nil {
+		resp.RestartRequired = []string{}
+	}
+	return resp, nil
+}
+
+// startReloadSignal reloads the configuration on SIGHUP.
+func (s *server) startReloadSignal(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if _, err := s.reloadConfig(); err != nil {
+					log.Printf("config reload failed: %v (keeping current settings)", err)
+				}
+			}
+		}
+	}()
+}
+
+// handleAdminReload serves POST /api/admin/reload: the SIGHUP reload over
+// HTTP, reporting which changed settings were applied and which need a
+// restart.
+func (s *server) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	resp, err := s.reloadConfig()
+	if err != nil {
+		log.Printf("config reload failed: %v (keeping current settings)", err)
+		http.Error(w, "reload rejected: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	respondJSON(w, resp)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"alert_framework/config"
+)
+
+// maxRemoteIndexBytes bounds how much of a listing is read per poll.
+const maxRemoteIndexBytes = 8 << 20
+
+var hrefPattern = regexp.MustCompile(`(?i)href\s*=\s*["']([^"']+)["']`)
+
+// remoteCall is one recording offered by a remote source. Key orders calls
+// from oldest to newest and is what the source cursor stores.
+type remoteCall struct {
+	Key      string
+	URL      string
+	Filename string
+}
+
+// remoteSourceState is the persisted progress of one remote source.
+type remoteSourceState struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	URL          string     `json:"url"`
+	Cursor       string     `json:"cursor"`
+	LastPolledAt *time.Time `json:"last_polled_at,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// startRemotePollers starts one poller per REMOTE_SOURCES entry.
+func (s *server) startRemotePollers(ctx context.Context) {
+	for _, src := range s.cfg.RemoteSources {
+		src := src
+		go func() {
+			log.Printf("polling remote source %s (%s) every %ds", src.Name, src.URL, src.IntervalSec)
+			ticker := time.NewTicker(time.Duration(src.IntervalSec) * time.Second)
+			defer ticker.Stop()
+			for {
+				if err := s.pollRemoteSource(ctx, src); err != nil {
+					log.Printf("remote source %s poll failed: %v", src.Name, err)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-s.shutdown:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// pollRemoteSource lists src, downloads calls newer than its cursor (oldest
+// first, at most MaxPerPoll) and enqueues them. The cursor is saved after
+// every file so a restart resumes where the last poll stopped. On the first
+// poll of a new source only the newest MaxPerPoll calls are fetched rather
+// than the whole archive.
+func (s *server) pollRemoteSource(ctx context.Context, src config.RemoteSource) error {
+	cursor, err := s.remoteCursor(src.Name)
+	if err != nil {
+		return err
+	}
+	calls, err := s.listRemoteSource(ctx, src)
+	if err == nil {
+		fresh := calls[:0]
+		for _, c := range calls {
+			if c.Key > cursor {
+				fresh = append(fresh, c)
+			}
+		}
+		sort.Slice(fresh, func(i, j int) bool { return fresh[i].Key < fresh[j].Key })
+		if cursor == "" && len(fresh) > src.MaxPerPoll {
+			fresh = fresh[len(fresh)-src.MaxPerPoll:]
+		}
+		if len(fresh) > src.MaxPerPoll {
+			fresh = fresh[:src.MaxPerPoll]
+		}
+		for _, c := range fresh {
+			if err = s.fetchRemoteCall(ctx, src, c); err != nil {
+				err = fmt.Errorf("%s: %w", c.Filename, err)
+				break
+			}
+			cursor = c.Key
+			if err = s.saveRemoteCursor(src.Name, cursor); err != nil {
+				break
+			}
+		}
+	}
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+	}
+	if _, dbErr := execWithRetry(s.db, `UPDATE remote_sources SET last_polled_at=?, last_error=? WHERE name=?`, time.Now().UTC(), lastError, src.Name); dbErr != nil {
+		log.Printf("remote source %s: record poll: %v", src.Name, dbErr)
+	}
+	return err
+}
+
+// fetchRemoteCall downloads c into CALLS_DIR and enqueues it. Files that
+// already exist locally are not downloaded again.
+func (s *server) fetchRemoteCall(ctx context.Context, src config.RemoteSource, c remoteCall) error {
+	dest := filepath.Join(s.cfg.CallsDir, c.Filename)
+	if fileExists(dest) {
+		return nil
+	}
+	resp, err := s.remoteGet(ctx, src, c.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	tmp, err := os.CreateTemp(s.cfg.CallsDir, "."+c.Filename+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	// Keep the watcher from also picking up the rename; the poller enqueues
+	// the call itself so it is recorded with the remote ingest source.
+	s.remoteFiles.Store(c.Filename, struct{}{})
+	defer s.remoteFiles.Delete(c.Filename)
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return err
+	}
+	log.Printf("remote source %s: downloaded %s", src.Name, c.Filename)
+	opts, _ := s.defaultOptions()
+	s.queueJob("remote:"+src.Name, c.Filename, true, false, opts)
+	return nil
+}
+
+func (s *server) remoteGet(ctx context.Context, src config.RemoteSource, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range src.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return resp, nil
+}
+
+func (s *server) listRemoteSource(ctx context.Context, src config.RemoteSource) ([]remoteCall, error) {
+	resp, err := s.remoteGet(ctx, src, src.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteIndexBytes))
+	if err != nil {
+		return nil, err
+	}
+	base, err := url.Parse(src.URL)
+	if err != nil {
+		return nil, err
+	}
+	if src.Type == "json" {
+		return parseRemoteJSONIndex(base, body)
+	}
+	return parseRemoteHTMLIndex(base, body), nil
+}
+
+// parseRemoteHTMLIndex reads audio links from a directory index page. Calls
+// are keyed by filename, which for recorder output starts with the call time.
+func parseRemoteHTMLIndex(base *url.URL, body []byte) []remoteCall {
+	seen := make(map[string]bool)
+	var calls []remoteCall
+	for _, m := range hrefPattern.FindAllSubmatch(body, -1) {
+		ref, err := url.Parse(string(m[1]))
+		if err != nil {
+			continue
+		}
+		abs := base.ResolveReference(ref)
+		name := remoteFilename(abs.Path)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		calls = append(calls, remoteCall{Key: name, URL: abs.String(), Filename: name})
+	}
+	return calls
+}
+
+// parseRemoteJSONIndex reads a call list, either a bare array or an object
+// with a "calls" (or Broadcastify-style "data") array. Each entry needs a
+// url; ts (unix seconds) or id orders the calls, and filename overrides the
+// name taken from the url.
+func parseRemoteJSONIndex(base *url.URL, body []byte) ([]remoteCall, error) {
+	type entry struct {
+		URL      string          `json:"url"`
+		Filename string          `json:"filename"`
+		TS       int64           `json:"ts"`
+		ID       json.RawMessage `json:"id"`
+	}
+	var entries []entry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		var wrapped struct {
+			Calls []entry `json:"calls"`
+			Data  []entry `json:"data"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, fmt.Errorf("decode call list: %w", err)
+		}
+		entries = append(wrapped.Calls, wrapped.Data...)
+	}
+	var calls []remoteCall
+	for _, e := range entries {
+		ref, err := url.Parse(strings.TrimSpace(e.URL))
+		if err != nil || e.URL == "" {
+			continue
+		}
+		abs := base.ResolveReference(ref)
+		name := remoteFilename(e.Filename)
+		if name == "" {
+			name = remoteFilename(abs.Path)
+		}
+		if name == "" {
+			continue
+		}
+		// Zero-pad numeric keys so they compare correctly as strings; the
+		// filename breaks ties between calls in the same second.
+		var key string
+		switch {
+		case e.TS > 0:
+			key = fmt.Sprintf("%020d/%s", e.TS, name)
+		case len(e.ID) > 0:
+			id := strings.Trim(string(e.ID), `"`)
+			if pad := 20 - len(id); pad > 0 {
+				id = strings.Repeat("0", pad) + id
+			}
+			key = id + "/" + name
+		default:
+			key = name
+		}
+		calls = append(calls, remoteCall{Key: key, URL: abs.String(), Filename: name})
+	}
+	return calls, nil
+}
+
+// remoteFilename returns the base name of p if it is a usable audio filename.
+func remoteFilename(p string) string {
+	name, err := url.PathUnescape(path.Base(strings.TrimSpace(p)))
+	if err != nil {
+		return ""
+	}
+	if name == "" || name == "." || name == "/" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return ""
+	}
+	if !isAudioFilename(name) {
+		return ""
+	}
+	return name
+}
+
+func (s *server) remoteCursor(name string) (string, error) {
+	if _, err := execWithRetry(s.db, `INSERT OR IGNORE INTO remote_sources (name) VALUES (?)`, name); err != nil {
+		return "", err
+	}
+	var cursor string
+	err := s.db.QueryRow(`SELECT cursor FROM remote_sources WHERE name=?`, name).Scan(&cursor)
+	return cursor, err
+}
+
+func (s *server) saveRemoteCursor(name, cursor string) error {
+	_, err := execWithRetry(s.db, `UPDATE remote_sources SET cursor=? WHERE name=?`, cursor, name)
+	return err
+}
+
+// handleAdminSources reports each configured remote source with its cursor
+// and last poll result.
+func (s *server) handleAdminSources(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	out := make([]remoteSourceState, 0, len(s.cfg.RemoteSources))
+	for _, src := range s.cfg.RemoteSources {
+		state := remoteSourceState{Name: src.Name, Type: src.Type, URL: src.URL}
+		var cursor, lastError sql.NullString
+		var polled sql.NullTime
+		err := s.db.QueryRow(`SELECT cursor, last_polled_at, last_error FROM remote_sources WHERE name=?`, src.Name).Scan(&cursor, &polled, &lastError)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("remote source %s state: %v", src.Name, err)
+		}
+		state.Cursor = cursor.String
+		state.LastError = lastError.String
+		if polled.Valid {
+			t := polled.Time
+			state.LastPolledAt = &t
+		}
+		out = append(out, state)
+	}
+	respondJSON(w, map[string]interface{}{"sources": out})
+}
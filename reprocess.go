@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"alert_framework/formatting"
+	"alert_framework/prompts"
+	"alert_framework/queue"
+)
+
+// Reprocess stages, in pipeline order. "transcribe" (or an empty stage) reruns
+// the full audio pipeline; later stages reuse the stored raw transcript.
+const (
+	reprocessStageTranscribe = "transcribe"
+	reprocessStageRefine     = "refine"
+	reprocessStageClassify   = "classify"
+	reprocessStageGeocode    = "geocode"
+	reprocessStageNotify     = "notify"
+)
+
+var errNoStoredTranscript = errors.New("no stored transcript; run a full reprocess")
+
+type reprocessRequest struct {
+	Filename string `json:"filename"`
+	Stage    string `json:"stage"`
+	Notify   bool   `json:"notify"`
+}
+
+func validReprocessStage(stage string) bool {
+	switch stage {
+	case "", reprocessStageTranscribe, reprocessStageRefine, reprocessStageClassify, reprocessStageGeocode, reprocessStageNotify:
+		return true
+	}
+	return false
+}
+
+// handleOpsReprocess queues a reprocess of a single call. The optional stage
+// selects where the pipeline restarts; alerts are only re-sent for the notify
+// stage or when notify=true.
+func (s *server) handleOpsReprocess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	req := reprocessRequest{
+		Filename: r.URL.Query().Get("filename"),
+		Stage:    r.URL.Query().Get("stage"),
+		Notify:   strings.EqualFold(r.URL.Query().Get("notify"), "true"),
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	req.Filename = filepath.Clean(strings.TrimSpace(req.Filename))
+	req.Stage = strings.ToLower(strings.TrimSpace(req.Stage))
+	if req.Filename == "" || req.Filename == "." || strings.Contains(req.Filename, "..") {
+		http.Error(w, "filename required", http.StatusBadRequest)
+		return
+	}
+	if !validReprocessStage(req.Stage) {
+		http.Error(w, "unknown stage", http.StatusBadRequest)
+		return
+	}
+	if s.queue == nil {
+		http.Error(w, "queue disabled", http.StatusServiceUnavailable)
+		return
+	}
+	if req.Stage == "" || req.Stage == reprocessStageTranscribe {
+		var before interface{}
+		if existing, err := s.getTranscription(req.Filename); err == nil {
+			if !hasAudio(*existing) {
+				http.Error(w, "call has no audio; reprocess from refine or a later stage", http.StatusConflict)
+				return
+			}
+			before = callAuditState(*existing)
+		}
+		opts, _ := s.defaultOptions()
+		enqueued := s.queueJob("ops", req.Filename, req.Notify, true, opts)
+		noteAudit(r, "call.reprocess", req.Filename, before, map[string]interface{}{"stage": reprocessStageTranscribe, "notify": req.Notify, "enqueued": enqueued})
+		respondJSON(w, map[string]interface{}{"status": statusQueued, "filename": req.Filename, "stage": reprocessStageTranscribe, "enqueued": enqueued})
+		return
+	}
+	existing, err := s.getTranscription(req.Filename)
+	if err != nil || existing == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if storedRawTranscript(existing) == "" {
+		http.Error(w, errNoStoredTranscript.Error(), http.StatusConflict)
+		return
+	}
+	enqueued := s.enqueueStageReprocess("ops", req.Filename, req.Stage, req.Notify)
+	noteAudit(r, "call.reprocess", req.Filename, callAuditState(*existing), map[string]interface{}{"stage": req.Stage, "notify": req.Notify, "enqueued": enqueued})
+	respondJSON(w, map[string]interface{}{"status": statusQueued, "filename": req.Filename, "stage": req.Stage, "enqueued": enqueued})
+}
+
+func (s *server) enqueueStageReprocess(source, filename, stage string, notify bool) bool {
+	if s.queue == nil {
+		return false
+	}
+	if _, exists := s.running.LoadOrStore(filename, struct{}{}); exists {
+		return false
+	}
+	job := queue.Job{
+		ID:       filename,
+		FileName: filename,
+		Source:   source,
+		Work: func(ctx context.Context) error {
+			return s.reprocessFromStage(ctx, filename, stage, notify)
+		},
+		OnFinish: func(err error) {
+			s.running.Delete(filename)
+			if err != nil {
+				log.Printf("reprocess %s from %s failed: %v", filename, stage, err)
+			}
+		},
+	}
+	enqueued := s.queue.Enqueue(job)
+	if !enqueued {
+		s.running.Delete(filename)
+	}
+	return enqueued
+}
+
+// reprocessFromStage reruns enrichment starting at stage using the stored raw
+// transcript, so prompt iterations do not pay for audio transcription again.
+func (s *server) reprocessFromStage(ctx context.Context, filename, stage string, notify bool) error {
+	existing, err := s.getTranscription(filename)
+	if err != nil {
+		return err
+	}
+	raw := storedRawTranscript(existing)
+	if raw == "" {
+		return errNoStoredTranscript
+	}
+	meta, pretty, publicURL, baseURL := s.buildJobContext(filename, "")
+	opts, _ := s.defaultOptions()
+	j := processJob{filename: filename, source: "reprocess", sendGroupMe: notify || stage == reprocessStageNotify, options: opts, meta: meta, prettyTitle: pretty, publicURL: publicURL, baseURL: baseURL}
+
+	artifacts := artifactsFromRecord(existing, raw)
+	switch stage {
+	case reprocessStageRefine:
+		queue.SetStage(ctx, reprocessStageRefine)
+		enriched := s.enrichTranscript(filename, raw, opts, meta)
+		enriched.DiarizedJSON = existing.DiarizedJSON
+		enriched.SpeakerRoles = s.labelSpeakerRoles(existing.DiarizedJSON)
+		enriched.ActualModel = existing.ActualModel
+		artifacts = enriched
+	case reprocessStageClassify:
+		queue.SetStage(ctx, reprocessStageClassify)
+		callType, err := s.classifyCallType(artifacts.CleanTranscript)
+		if err != nil {
+			return fmt.Errorf("classify: %w", err)
+		}
+		artifacts.CallType = callType
+	}
+
+	cleaned := artifacts.CleanTranscript
+	if artifacts.PublicTranscript == "" {
+		artifacts.PublicTranscript = s.redactTranscript(cleaned)
+	}
+	if artifacts.DetectedLanguage == "" {
+		artifacts.DetectedLanguage = formatting.DetectLanguage(raw)
+	}
+	normalized := artifacts.NormalizedText
+	if normalized == nil || strings.TrimSpace(*normalized) == "" {
+		fallback := s.normalizeTranscript(cleaned, meta)
+		normalized = &fallback
+	}
+	callType := artifacts.CallType
+	if callType == nil && meta.CallType != "" {
+		ct := meta.CallType
+		callType = &ct
+	}
+	recognized := parseRecognizedTowns(artifacts.RecognizedTowns)
+	tagsList := s.buildTags(meta, recognized, callType)
+	var tagsJSON *string
+	if data, err := json.Marshal(tagsList); err == nil {
+		str := string(data)
+		tagsJSON = &str
+	}
+
+	var location *locationGuess
+	if stage == reprocessStageNotify {
+		location = storedLocation(existing)
+	} else {
+		queue.SetStage(ctx, reprocessStageGeocode)
+		candidate := transcription{
+			Filename:             filename,
+			NormalizedTranscript: normalized,
+			CleanTranscript:      &cleaned,
+			RawTranscript:        &raw,
+			RecognizedTowns:      artifacts.RecognizedTowns,
+			CallType:             callType,
+			TagsJSON:             tagsJSON,
+		}
+		var metadataPrompt *prompts.Assignment
+		location, metadataPrompt = s.resolveCallLocation(ctx, candidate, meta, recognized)
+		lat, lon, label, source := locationFields(location)
+		if err := s.completeCall(filename, callCompletion{
+			raw:              &raw,
+			clean:            &cleaned,
+			translation:      artifacts.Translation,
+			duplicateOf:      existing.DuplicateOf,
+			diarized:         artifacts.DiarizedJSON,
+			towns:            artifacts.RecognizedTowns,
+			normalized:       normalized,
+			actualModel:      artifacts.ActualModel,
+			callType:         callType,
+			tags:             tagsJSON,
+			lat:              lat,
+			lon:              lon,
+			label:            label,
+			source:           source,
+			metadataJSON:     artifacts.MetadataJSON,
+			addressJSON:      artifacts.AddressJSON,
+			manualReview:     artifacts.NeedsManualReview,
+			publicTranscript: artifacts.PublicTranscript,
+			detectedLanguage: artifacts.DetectedLanguage,
+			speakerRoles:     artifacts.SpeakerRoles,
+			embedding:        artifacts.Embedding,
+		}); err != nil {
+			return err
+		}
+		s.recordPromptAssignments(filename, artifacts.CleanupPrompt, metadataPrompt)
+	}
+
+	if j.sendGroupMe {
+		queue.SetStage(ctx, reprocessStageNotify)
+		s.sendCallAlert(j, s.audioFilename(*existing), callType, tagsList, location, recognized, artifacts.PublicTranscript)
+	}
+	log.Printf("reprocessed %s from stage=%s notify=%v", filename, stage, j.sendGroupMe)
+	return nil
+}
+
+func storedRawTranscript(t *transcription) string {
+	if t == nil {
+		return ""
+	}
+	for _, v := range []*string{t.RawTranscript, t.Transcript, t.CleanTranscript} {
+		if v != nil && strings.TrimSpace(*v) != "" {
+			return *v
+		}
+	}
+	return ""
+}
+
+// artifactsFromRecord rebuilds pipeline artifacts from a stored transcription.
+func artifactsFromRecord(t *transcription, raw string) transcriptionArtifacts {
+	cleaned := raw
+	if t.CleanTranscript != nil && strings.TrimSpace(*t.CleanTranscript) != "" {
+		cleaned = *t.CleanTranscript
+	}
+	return transcriptionArtifacts{
+		RawTranscript:     raw,
+		CleanTranscript:   cleaned,
+		Translation:       t.Translation,
+		DiarizedJSON:      t.DiarizedJSON,
+		RecognizedTowns:   t.RecognizedTowns,
+		NormalizedText:    t.NormalizedTranscript,
+		ActualModel:       t.ActualModel,
+		CallType:          t.CallType,
+		PublicTranscript:  derefString(t.PublicTranscript, ""),
+		DetectedLanguage:  derefString(t.DetectedLanguage, ""),
+		SpeakerRoles:      t.SpeakerRoles,
+		MetadataJSON:      t.RefinedMetadata,
+		AddressJSON:       t.AddressJSON,
+		NeedsManualReview: t.NeedsManualReview,
+	}
+}
+
+func storedLocation(t *transcription) *locationGuess {
+	guess := &locationGuess{}
+	if t.LocationLabel != nil {
+		guess.Label = *t.LocationLabel
+	}
+	if t.LocationSource != nil {
+		guess.Source = *t.LocationSource
+	}
+	if t.Latitude != nil && t.Longitude != nil {
+		guess.Latitude = *t.Latitude
+		guess.Longitude = *t.Longitude
+	}
+	if guess.Label == "" && guess.Latitude == 0 && guess.Longitude == 0 {
+		return nil
+	}
+	return guess
+}
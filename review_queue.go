@@ -0,0 +1,637 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReviewLimit     = 100
+	maxReviewLimit         = 1000
+	defaultReviewStatsDays = 30
+	maxReviewStatsDays     = 365
+)
+
+// Review resolutions. "cleared" is recorded when a call stops needing review
+// without a reviewer, e.g. after a reprocess.
+const (
+	reviewApproved  = "approved"
+	reviewCorrected = "corrected"
+	reviewCleared   = "cleared"
+)
+
+var errReviewClaimed = errors.New("claimed by another reviewer")
+
+type reviewItem struct {
+	ID             int64           `json:"id"`
+	Filename       string          `json:"filename"`
+	FlaggedAt      time.Time       `json:"flagged_at"`
+	Reviewer       *string         `json:"reviewer,omitempty"`
+	ClaimedAt      *time.Time      `json:"claimed_at,omitempty"`
+	ResolvedAt     *time.Time      `json:"resolved_at,omitempty"`
+	ResolvedBy     *string         `json:"resolved_by,omitempty"`
+	Resolution     *string         `json:"resolution,omitempty"`
+	Corrections    json.RawMessage `json:"corrections,omitempty"`
+	Note           *string         `json:"note,omitempty"`
+	CallType       *string         `json:"call_type"`
+	CallTimestamp  *time.Time      `json:"call_timestamp"`
+	LocationLabel  *string         `json:"location_label"`
+	Talkgroup      *string         `json:"talkgroup"`
+	Transcript     *string         `json:"transcript"`
+	PendingMinutes int             `json:"pending_minutes"`
+}
+
+// reviewCorrections are the fields a reviewer may fix. Unset fields keep the
+// call's current value.
+type reviewCorrections struct {
+	CallType      *string  `json:"call_type,omitempty"`
+	LocationLabel *string  `json:"location_label,omitempty"`
+	Latitude      *float64 `json:"latitude,omitempty"`
+	Longitude     *float64 `json:"longitude,omitempty"`
+	Transcript    *string  `json:"transcript,omitempty"`
+}
+
+func (c reviewCorrections) empty() bool {
+	return c.CallType == nil && c.LocationLabel == nil && c.Latitude == nil && c.Longitude == nil && c.Transcript == nil
+}
+
+type reviewResolveRequest struct {
+	Action      string            `json:"action"`
+	Corrections reviewCorrections `json:"corrections"`
+	Note        string            `json:"note"`
+	Force       bool              `json:"force"`
+	// LocationPhrase is the transcript text a location fix applies to. It
+	// defaults to the address the location parser finds in the transcript.
+	LocationPhrase string `json:"location_phrase"`
+}
+
+type reviewDurations struct {
+	Count      int      `json:"count"`
+	AvgMinutes *float64 `json:"avg_minutes,omitempty"`
+	P50Minutes *float64 `json:"p50_minutes,omitempty"`
+	P90Minutes *float64 `json:"p90_minutes,omitempty"`
+}
+
+type reviewerStats struct {
+	Reviewer string `json:"reviewer"`
+	Claimed  int    `json:"claimed"`
+	Resolved int    `json:"resolved"`
+}
+
+type reviewStatsResponse struct {
+	GeneratedAt       time.Time       `json:"generated_at"`
+	Since             time.Time       `json:"since"`
+	Days              int             `json:"days"`
+	Pending           int             `json:"pending"`
+	Unclaimed         int             `json:"unclaimed"`
+	Claimed           int             `json:"claimed"`
+	OldestPendingAt   *time.Time      `json:"oldest_pending_at,omitempty"`
+	OldestPendingMins *int            `json:"oldest_pending_minutes,omitempty"`
+	Resolved          int             `json:"resolved"`
+	Resolutions       map[string]int  `json:"resolutions"`
+	Turnaround        reviewDurations `json:"turnaround"`
+	ClaimWait         reviewDurations `json:"claim_wait"`
+	Reviewers         []reviewerStats `json:"reviewers"`
+}
+
+func migrateAddReviewQueue(db *sql.DB) error {
+	if _, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS review_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT NOT NULL,
+    flagged_at DATETIME NOT NULL,
+    reviewer TEXT,
+    claimed_at DATETIME,
+    resolved_at DATETIME,
+    resolved_by TEXT,
+    resolution TEXT,
+    corrections_json TEXT,
+    note TEXT
+);`); err != nil {
+		return err
+	}
+	// A call has at most one open review; resolved ones are kept as history.
+	if _, err := execWithRetry(db, `CREATE UNIQUE INDEX IF NOT EXISTS idx_review_items_open ON review_items(filename) WHERE resolved_at IS NULL`); err != nil {
+		return err
+	}
+	_, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_review_items_resolved_at ON review_items(resolved_at)`)
+	return err
+}
+
+// syncReviewQueue opens a review for every call flagged needs_manual_review
+// without one, and clears open reviews for calls no longer flagged. The
+// pipeline only sets the flag, so the queue catches up whenever it is read.
+func (s *server) syncReviewQueue(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO review_items (filename, flagged_at)
+SELECT t.filename, COALESCE(t.updated_at, t.created_at) FROM transcriptions t
+WHERE t.needs_manual_review = 1
+  AND NOT EXISTS (SELECT 1 FROM review_items r WHERE r.filename = t.filename AND r.resolved_at IS NULL)`); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `UPDATE review_items SET resolved_at = ?, resolution = ?
+WHERE resolved_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM transcriptions t WHERE t.filename = review_items.filename AND t.needs_manual_review = 1)`, time.Now().UTC(), reviewCleared)
+	return err
+}
+
+// handleReview serves the manual review queue (admin only):
+//
+//	GET  /api/review[?status=pending|unclaimed|claimed|resolved][&reviewer=][&call_type=][&since=][&limit=N]
+//	GET  /api/review/stats[?days=30]
+//	POST /api/review/{filename}/claim     {"reviewer": "...", "force": false}
+//	POST /api/review/{filename}/release
+//	POST /api/review/{filename}/resolve   {"action": "approve|correct", "corrections": {...}, "note": "..."}
+//
+// The reviewer defaults to the caller (X-Admin-User, else the client IP).
+// Claiming or resolving an item someone else holds needs force.
+func (s *server) handleReview(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/review"), "/")
+	if rest == "" || rest == "stats" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := s.syncReviewQueue(r.Context()); err != nil {
+			log.Printf("review queue sync failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if rest == "stats" {
+			s.reviewStats(w, r)
+		} else {
+			s.listReview(w, r)
+		}
+		return
+	}
+	filename, action, _ := strings.Cut(rest, "/")
+	if filename == "" || filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") {
+		http.NotFound(w, r)
+		return
+	}
+	switch action {
+	case "claim", "release", "resolve":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.syncReviewQueue(r.Context()); err != nil {
+		log.Printf("review queue sync failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	switch action {
+	case "claim":
+		s.claimReview(w, r, filename)
+	case "release":
+		s.releaseReview(w, r, filename)
+	case "resolve":
+		s.resolveReview(w, r, filename)
+	}
+}
+
+const reviewItemColumns = `r.id, r.filename, r.flagged_at, r.reviewer, r.claimed_at, r.resolved_at, r.resolved_by, r.resolution, r.corrections_json, r.note,
+t.call_type, t.call_timestamp, t.location_label, t.talkgroup, COALESCE(t.clean_transcript_text, t.raw_transcript_text, t.transcript_text)`
+
+func scanReviewItem(scan func(dest ...interface{}) error, now time.Time) (reviewItem, error) {
+	var item reviewItem
+	var claimedAt, resolvedAt, callTS sql.NullTime
+	var corrections sql.NullString
+	if err := scan(&item.ID, &item.Filename, &item.FlaggedAt, &item.Reviewer, &claimedAt, &resolvedAt, &item.ResolvedBy, &item.Resolution, &corrections, &item.Note,
+		&item.CallType, &callTS, &item.LocationLabel, &item.Talkgroup, &item.Transcript); err != nil {
+		return item, err
+	}
+	if claimedAt.Valid {
+		item.ClaimedAt = &claimedAt.Time
+	}
+	if resolvedAt.Valid {
+		item.ResolvedAt = &resolvedAt.Time
+	}
+	if callTS.Valid {
+		item.CallTimestamp = &callTS.Time
+	}
+	if corrections.Valid && corrections.String != "" {
+		item.Corrections = json.RawMessage(corrections.String)
+	}
+	end := now
+	if item.ResolvedAt != nil {
+		end = *item.ResolvedAt
+	}
+	item.PendingMinutes = int(end.Sub(item.FlaggedAt).Minutes())
+	return item, nil
+}
+
+func (s *server) listReview(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := defaultReviewLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxReviewLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	var where []string
+	var args []interface{}
+	order := "r.flagged_at ASC"
+	switch strings.ToLower(strings.TrimSpace(q.Get("status"))) {
+	case "", "pending":
+		where = append(where, "r.resolved_at IS NULL")
+	case "unclaimed":
+		where = append(where, "r.resolved_at IS NULL", "r.reviewer IS NULL")
+	case "claimed":
+		where = append(where, "r.resolved_at IS NULL", "r.reviewer IS NOT NULL")
+	case "resolved":
+		where = append(where, "r.resolved_at IS NOT NULL")
+		order = "r.resolved_at DESC"
+	default:
+		http.Error(w, "status must be pending, unclaimed, claimed or resolved", http.StatusBadRequest)
+		return
+	}
+	if v := strings.TrimSpace(q.Get("reviewer")); v != "" {
+		where = append(where, "(r.reviewer = ? OR r.resolved_by = ?)")
+		args = append(args, v, v)
+	}
+	if v := strings.TrimSpace(q.Get("call_type")); v != "" {
+		where = append(where, "lower(coalesce(t.call_type, '')) = ?")
+		args = append(args, strings.ToLower(v))
+	}
+	if raw := strings.TrimSpace(q.Get("since")); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		where = append(where, "r.flagged_at >= ?")
+		args = append(args, since.UTC())
+	}
+	query := `SELECT ` + reviewItemColumns + ` FROM review_items r JOIN transcriptions t ON t.filename = r.filename WHERE ` + strings.Join(where, " AND ") + ` ORDER BY ` + order + ` LIMIT ?`
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		log.Printf("review list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	now := time.Now()
+	items := []reviewItem{}
+	for rows.Next() {
+		item, err := scanReviewItem(rows.Scan, now)
+		if err != nil {
+			log.Printf("review scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("review list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"items": items})
+}
+
+func (s *server) openReviewItem(ctx context.Context, filename string) (reviewItem, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+reviewItemColumns+` FROM review_items r JOIN transcriptions t ON t.filename = r.filename WHERE r.filename = ? AND r.resolved_at IS NULL`, filename)
+	return scanReviewItem(row.Scan, time.Now())
+}
+
+// reviewLookup loads the open review for filename, writing the error response
+// and returning false when there is none.
+func (s *server) reviewLookup(w http.ResponseWriter, r *http.Request, filename string) (reviewItem, bool) {
+	item, err := s.openReviewItem(r.Context(), filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "no open review for this call", http.StatusNotFound)
+		return item, false
+	}
+	if err != nil {
+		log.Printf("review lookup failed for %s: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return item, false
+	}
+	return item, true
+}
+
+func (s *server) claimReview(w http.ResponseWriter, r *http.Request, filename string) {
+	var req struct {
+		Reviewer string `json:"reviewer"`
+		Force    bool   `json:"force"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+	}
+	reviewer := strings.TrimSpace(req.Reviewer)
+	if reviewer == "" {
+		reviewer = settingsActor(r)
+	}
+	item, ok := s.reviewLookup(w, r, filename)
+	if !ok {
+		return
+	}
+	if item.Reviewer != nil && *item.Reviewer != reviewer && !req.Force {
+		http.Error(w, errReviewClaimed.Error()+": "+*item.Reviewer, http.StatusConflict)
+		return
+	}
+	if item.Reviewer == nil || *item.Reviewer != reviewer {
+		if _, err := execWithRetry(s.db, `UPDATE review_items SET reviewer = ?, claimed_at = ? WHERE id = ?`, reviewer, time.Now().UTC(), item.ID); err != nil {
+			log.Printf("review claim failed for %s: %v", filename, err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		noteAudit(r, "review.claim", filename, map[string]interface{}{"reviewer": item.Reviewer}, map[string]interface{}{"reviewer": reviewer})
+	}
+	s.respondReviewItem(w, r, filename)
+}
+
+func (s *server) releaseReview(w http.ResponseWriter, r *http.Request, filename string) {
+	item, ok := s.reviewLookup(w, r, filename)
+	if !ok {
+		return
+	}
+	if item.Reviewer != nil {
+		if _, err := execWithRetry(s.db, `UPDATE review_items SET reviewer = NULL, claimed_at = NULL WHERE id = ?`, item.ID); err != nil {
+			log.Printf("review release failed for %s: %v", filename, err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		noteAudit(r, "review.release", filename, map[string]interface{}{"reviewer": item.Reviewer}, nil)
+	}
+	s.respondReviewItem(w, r, filename)
+}
+
+// resolveReview closes the open review for filename. "approve" accepts the
+// call as is; "correct" first writes the reviewer's corrections to the call.
+// Either way the call's needs_manual_review flag is cleared.
+func (s *server) resolveReview(w http.ResponseWriter, r *http.Request, filename string) {
+	var req reviewResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	c := req.Corrections
+	resolution := ""
+	switch strings.ToLower(strings.TrimSpace(req.Action)) {
+	case "approve":
+		if !c.empty() {
+			http.Error(w, "approve takes no corrections; use correct", http.StatusBadRequest)
+			return
+		}
+		resolution = reviewApproved
+	case "correct":
+		if c.empty() {
+			http.Error(w, "corrections required", http.StatusBadRequest)
+			return
+		}
+		resolution = reviewCorrected
+	default:
+		http.Error(w, "action must be approve or correct", http.StatusBadRequest)
+		return
+	}
+	if (c.Latitude == nil) != (c.Longitude == nil) {
+		http.Error(w, "latitude and longitude must be corrected together", http.StatusBadRequest)
+		return
+	}
+	if c.Latitude != nil && (math.IsNaN(*c.Latitude) || math.IsNaN(*c.Longitude) || math.Abs(*c.Latitude) > 90 || math.Abs(*c.Longitude) > 180) {
+		http.Error(w, "latitude or longitude out of range", http.StatusBadRequest)
+		return
+	}
+	if c.Transcript != nil && strings.TrimSpace(*c.Transcript) == "" {
+		http.Error(w, "transcript cannot be empty", http.StatusBadRequest)
+		return
+	}
+	for _, p := range []**string{&c.CallType, &c.LocationLabel} {
+		if *p != nil {
+			v := strings.TrimSpace(**p)
+			*p = &v
+		}
+	}
+	item, ok := s.reviewLookup(w, r, filename)
+	if !ok {
+		return
+	}
+	reviewer := settingsActor(r)
+	if item.Reviewer != nil && *item.Reviewer != reviewer && !req.Force {
+		http.Error(w, errReviewClaimed.Error()+": "+*item.Reviewer, http.StatusConflict)
+		return
+	}
+	existing, err := s.getTranscription(filename)
+	if err != nil || existing == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var publicTranscript *string
+	if c.Transcript != nil {
+		v := s.redactTranscript(*c.Transcript)
+		publicTranscript = &v
+	}
+	var correctionsJSON interface{}
+	if resolution == reviewCorrected {
+		raw, _ := json.Marshal(c)
+		correctionsJSON = string(raw)
+	}
+	now := time.Now().UTC()
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("review resolve failed for %s: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(r.Context(), `UPDATE transcriptions SET
+call_type = COALESCE(?, call_type),
+location_label = COALESCE(?, location_label),
+latitude = COALESCE(?, latitude),
+longitude = COALESCE(?, longitude),
+location_source = CASE WHEN ? OR ? IS NOT NULL THEN 'manual' ELSE location_source END,
+clean_transcript_text = COALESCE(?, clean_transcript_text),
+public_transcript = COALESCE(?, public_transcript),
+needs_manual_review = 0
+WHERE filename = ?`, c.CallType, c.LocationLabel, c.Latitude, c.Longitude, c.Latitude != nil, c.LocationLabel, c.Transcript, publicTranscript, filename); err != nil {
+		log.Printf("review resolve failed for %s: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	// A resolver who never claimed the item is recorded as its reviewer, so
+	// claim wait and per-reviewer counts still add up.
+	if _, err := tx.ExecContext(r.Context(), `UPDATE review_items SET
+reviewer = COALESCE(CASE WHEN ? THEN ? END, reviewer, ?),
+claimed_at = COALESCE(claimed_at, ?),
+resolved_at = ?, resolved_by = ?, resolution = ?, corrections_json = ?, note = ?
+WHERE id = ?`, req.Force, reviewer, reviewer, now, now, reviewer, resolution, correctionsJSON, nullableString(strings.TrimSpace(req.Note)), item.ID); err != nil {
+		log.Printf("review resolve failed for %s: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("review resolve failed for %s: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	after := map[string]interface{}{"resolution": resolution, "note": req.Note}
+	if resolution == reviewCorrected {
+		after["corrections"] = c
+	}
+	if err := s.recordCallTypeCorrection(*existing, c, reviewer); err != nil {
+		log.Printf("call type correction failed for %s: %v", filename, err)
+	}
+	if resolution == reviewCorrected && (c.LocationLabel != nil || c.Latitude != nil) {
+		corr, err := s.recordGeocodeCorrection(r.Context(), *existing, req.LocationPhrase, c, reviewer)
+		if err != nil {
+			log.Printf("geocode correction failed for %s: %v", filename, err)
+		} else if corr != nil {
+			after["geocode_correction"] = corr.ID
+		}
+	}
+	noteAudit(r, "review.resolve", filename, callAuditState(*existing), after)
+	row := s.db.QueryRowContext(r.Context(), `SELECT `+reviewItemColumns+` FROM review_items r JOIN transcriptions t ON t.filename = r.filename WHERE r.id = ?`, item.ID)
+	resolved, err := scanReviewItem(row.Scan, time.Now())
+	if err != nil {
+		log.Printf("review lookup failed for %s: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, resolved)
+}
+
+func (s *server) respondReviewItem(w http.ResponseWriter, r *http.Request, filename string) {
+	if item, ok := s.reviewLookup(w, r, filename); ok {
+		respondJSON(w, item)
+	}
+}
+
+// reviewStats reports the current backlog and, for reviews resolved in the
+// last days, how long they took: turnaround runs from flagging to
+// resolution, claim wait from flagging to the first claim.
+func (s *server) reviewStats(w http.ResponseWriter, r *http.Request) {
+	days := defaultReviewStatsDays
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxReviewStatsDays {
+			http.Error(w, "days must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+		days = v
+	}
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -days)
+	resp := reviewStatsResponse{
+		GeneratedAt: now,
+		Since:       since,
+		Days:        days,
+		Resolutions: map[string]int{},
+		Reviewers:   []reviewerStats{},
+	}
+	reviewers := map[string]*reviewerStats{}
+	reviewerFor := func(name string) *reviewerStats {
+		rs, ok := reviewers[name]
+		if !ok {
+			rs = &reviewerStats{Reviewer: name}
+			reviewers[name] = rs
+		}
+		return rs
+	}
+	rows, err := s.db.QueryContext(r.Context(), `SELECT flagged_at, reviewer, claimed_at, resolved_at, resolved_by, resolution FROM review_items WHERE resolved_at IS NULL OR resolved_at >= ?`, since)
+	if err != nil {
+		log.Printf("review stats failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	var turnaround, claimWait []float64
+	for rows.Next() {
+		var flagged time.Time
+		var reviewer, resolvedBy, resolution sql.NullString
+		var claimedAt, resolvedAt sql.NullTime
+		if err := rows.Scan(&flagged, &reviewer, &claimedAt, &resolvedAt, &resolvedBy, &resolution); err != nil {
+			log.Printf("review stats scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		if !resolvedAt.Valid {
+			resp.Pending++
+			if reviewer.Valid {
+				resp.Claimed++
+				reviewerFor(reviewer.String).Claimed++
+			} else {
+				resp.Unclaimed++
+			}
+			if resp.OldestPendingAt == nil || flagged.Before(*resp.OldestPendingAt) {
+				at := flagged
+				resp.OldestPendingAt = &at
+			}
+			continue
+		}
+		resp.Resolved++
+		resp.Resolutions[resolution.String]++
+		if resolution.String == reviewCleared {
+			continue
+		}
+		if resolvedBy.Valid {
+			reviewerFor(resolvedBy.String).Resolved++
+		}
+		turnaround = append(turnaround, resolvedAt.Time.Sub(flagged).Minutes())
+		if claimedAt.Valid {
+			claimWait = append(claimWait, claimedAt.Time.Sub(flagged).Minutes())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("review stats failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if resp.OldestPendingAt != nil {
+		mins := int(now.Sub(*resp.OldestPendingAt).Minutes())
+		resp.OldestPendingMins = &mins
+	}
+	resp.Turnaround = summarizeReviewDurations(turnaround)
+	resp.ClaimWait = summarizeReviewDurations(claimWait)
+	for _, rs := range reviewers {
+		resp.Reviewers = append(resp.Reviewers, *rs)
+	}
+	sort.Slice(resp.Reviewers, func(i, j int) bool { return resp.Reviewers[i].Reviewer < resp.Reviewers[j].Reviewer })
+	respondJSON(w, resp)
+}
+
+func summarizeReviewDurations(minutes []float64) reviewDurations {
+	out := reviewDurations{Count: len(minutes)}
+	if len(minutes) == 0 {
+		return out
+	}
+	sort.Float64s(minutes)
+	var sum float64
+	for _, m := range minutes {
+		sum += m
+	}
+	round := func(v float64) *float64 {
+		v = math.Round(v*10) / 10
+		return &v
+	}
+	pick := func(p float64) *float64 {
+		return round(minutes[int(math.Ceil(p*float64(len(minutes))))-1])
+	}
+	out.AvgMinutes = round(sum / float64(len(minutes)))
+	out.P50Minutes = pick(0.5)
+	out.P90Minutes = pick(0.9)
+	return out
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/cap"
+	"alert_framework/config"
+	"alert_framework/rollups"
+)
+
+const (
+	rollupExportActive911 = "active911"
+	rollupExportCAP       = "cap"
+
+	rollupExportSent   = "sent"
+	rollupExportFailed = "failed"
+
+	rollupExportTimeout = 20 * time.Second
+
+	// rollupExportRetry is how long a failed export waits before a recompute
+	// tries the same rollup again.
+	rollupExportRetry = 5 * time.Minute
+
+	defaultRollupExportListLimit = 100
+	maxRollupExportListLimit     = 1000
+)
+
+// capCategories maps rollup categories to CAP event categories.
+var capCategories = map[string]string{"fire": "Fire", "ems": "Health"}
+
+// capSeverities maps rollup priorities to CAP severities.
+var capSeverities = map[string]string{"high": "Severe", "medium": "Moderate", "low": "Minor"}
+
+type rollupExport struct {
+	ID          int64     `json:"id"`
+	RollupID    int64     `json:"rollup_id"`
+	Destination string    `json:"destination"`
+	MsgType     string    `json:"msg_type"`
+	Identifier  string    `json:"identifier"`
+	Status      string    `json:"status"`
+	HTTPStatus  int       `json:"http_status,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	fingerprint string
+}
+
+func migrateAddRollupExports(db *sql.DB) error {
+	if _, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS rollup_exports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rollup_id INTEGER NOT NULL,
+    destination TEXT NOT NULL,
+    msg_type TEXT NOT NULL,
+    identifier TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status TEXT NOT NULL,
+    http_status INTEGER NOT NULL DEFAULT 0,
+    error TEXT NULL,
+    created_at DATETIME NOT NULL
+);`); err != nil {
+		return err
+	}
+	_, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_rollup_exports_rollup ON rollup_exports(rollup_id, destination)`)
+	return err
+}
+
+// rollupExportDestination is an external incident system rollups are pushed
+// to. build renders one message for it.
+type rollupExportDestination struct {
+	name        string
+	url         string
+	token       string
+	contentType string
+	build       func(r rollupResponse, msgType, identifier string, sent time.Time, prev *rollupExport) ([]byte, error)
+}
+
+func (s *server) rollupExportDestinations(cfg config.Config) []rollupExportDestination {
+	var out []rollupExportDestination
+	if cfg.Active911Enabled && cfg.Active911URL != "" {
+		out = append(out, rollupExportDestination{
+			name:        rollupExportActive911,
+			url:         cfg.Active911URL,
+			token:       cfg.Active911Token,
+			contentType: "application/json",
+			build: func(r rollupResponse, msgType, _ string, _ time.Time, _ *rollupExport) ([]byte, error) {
+				return json.Marshal(active911Payload(r, msgType))
+			},
+		})
+	}
+	if cfg.CAPExportEnabled && cfg.CAPExportURL != "" {
+		out = append(out, rollupExportDestination{
+			name:        rollupExportCAP,
+			url:         cfg.CAPExportURL,
+			token:       cfg.CAPExportToken,
+			contentType: cap.ContentType,
+			build: func(r rollupResponse, msgType, identifier string, sent time.Time, prev *rollupExport) ([]byte, error) {
+				return rollupCAPAlert(r, cfg, msgType, identifier, sent, prev).Marshal()
+			},
+		})
+	}
+	return out
+}
+
+// exportRollups pushes the rollups in the lookback window that are new or
+// changed since their last successful export to each enabled destination.
+// A rollup that closes before it was ever exported is not sent at all.
+func (s *server) exportRollups(ctx context.Context) {
+	cfg := s.liveConfig()
+	dests := s.rollupExportDestinations(cfg)
+	if len(dests) == 0 {
+		return
+	}
+	cutoff := time.Now().UTC().Add(-time.Duration(cfg.Rollup.LookbackHours) * time.Hour)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+rollupColumns+` FROM rollups WHERE end_at >= ? OR closed_at >= ? ORDER BY id`, cutoff, cutoff)
+	if err != nil {
+		log.Printf("rollup export query failed: %v", err)
+		return
+	}
+	var list []rollupResponse
+	for rows.Next() {
+		var r rollupResponse
+		if err := scanRollup(rows, &r); err != nil {
+			log.Printf("rollup export scan failed: %v", err)
+			rows.Close()
+			return
+		}
+		list = append(list, r)
+	}
+	rows.Close()
+	for _, r := range list {
+		for _, dest := range dests {
+			if ctx.Err() != nil {
+				return
+			}
+			s.exportRollup(ctx, dest, r)
+		}
+	}
+}
+
+func (s *server) exportRollup(ctx context.Context, dest rollupExportDestination, r rollupResponse) {
+	fingerprint := rollupExportFingerprint(r)
+	lastSent, err := s.latestRollupExport(ctx, r.RollupID, dest.name, true)
+	if err != nil {
+		log.Printf("rollup %d %s export lookup failed: %v", r.RollupID, dest.name, err)
+		return
+	}
+	if lastSent != nil && lastSent.fingerprint == fingerprint {
+		return
+	}
+	if lastSent == nil && r.State == rollups.StateClosed {
+		return
+	}
+	last, err := s.latestRollupExport(ctx, r.RollupID, dest.name, false)
+	if err != nil {
+		log.Printf("rollup %d %s export lookup failed: %v", r.RollupID, dest.name, err)
+		return
+	}
+	if last != nil && last.Status == rollupExportFailed && last.fingerprint == fingerprint && time.Since(last.CreatedAt) < rollupExportRetry {
+		return
+	}
+
+	msgType := cap.MsgUpdate
+	switch {
+	case lastSent == nil:
+		msgType = cap.MsgAlert
+	case r.State == rollups.StateClosed:
+		msgType = cap.MsgCancel
+	}
+	sent := time.Now().UTC().Truncate(time.Second)
+	d := rollupExport{
+		RollupID:    r.RollupID,
+		Destination: dest.name,
+		MsgType:     msgType,
+		Identifier:  fmt.Sprintf("rollup-%d-%d", r.RollupID, sent.Unix()),
+		Status:      rollupExportSent,
+		CreatedAt:   sent,
+		fingerprint: fingerprint,
+	}
+	body, err := dest.build(r, msgType, d.Identifier, sent, lastSent)
+	if err == nil {
+		d.HTTPStatus, err = s.postRollupExport(ctx, dest, body)
+	}
+	if err != nil {
+		d.Status, d.Error = rollupExportFailed, err.Error()
+		log.Printf("rollup %d %s export failed: %v", r.RollupID, dest.name, err)
+	}
+	if _, err := execWithRetry(s.db, `INSERT INTO rollup_exports (rollup_id, destination, msg_type, identifier, fingerprint, status, http_status, error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.RollupID, d.Destination, d.MsgType, d.Identifier, d.fingerprint, d.Status, d.HTTPStatus, nullableString(d.Error), d.CreatedAt); err != nil {
+		log.Printf("rollup %d %s export not recorded: %v", r.RollupID, dest.name, err)
+	}
+}
+
+func (s *server) postRollupExport(ctx context.Context, dest rollupExportDestination, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, rollupExportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dest.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", dest.contentType)
+	if dest.token != "" {
+		req.Header.Set("Authorization", "Bearer "+dest.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// latestRollupExport returns the newest export of a rollup to dest, or the
+// newest successful one with sentOnly; nil when there is none.
+func (s *server) latestRollupExport(ctx context.Context, rollupID int64, dest string, sentOnly bool) (*rollupExport, error) {
+	query := `SELECT id, msg_type, identifier, fingerprint, status, created_at FROM rollup_exports WHERE rollup_id = ? AND destination = ?`
+	args := []interface{}{rollupID, dest}
+	if sentOnly {
+		query += ` AND status = ?`
+		args = append(args, rollupExportSent)
+	}
+	var d rollupExport
+	err := s.db.QueryRowContext(ctx, query+` ORDER BY id DESC LIMIT 1`, args...).Scan(&d.ID, &d.MsgType, &d.Identifier, &d.fingerprint, &d.Status, &d.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// rollupExportFingerprint covers the fields destinations receive, so a
+// recompute that changes nothing they see sends nothing.
+func rollupExportFingerprint(r rollupResponse) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		r.Title, r.Summary, r.ClosingSummary, r.Category, r.Priority, r.Municipality, r.POI, r.State,
+		strconv.FormatFloat(r.Latitude, 'f', 5, 64), strconv.FormatFloat(r.Longitude, 'f', 5, 64),
+		strconv.Itoa(r.CallCount), strconv.FormatBool(r.Approximate), r.EndAt.UTC().Format(time.RFC3339),
+	}, "\x1f")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func rollupResponseTitle(r rollupResponse) string {
+	return rollupTitle(rollups.Rollup{Title: r.Title, Category: r.Category, Municipality: r.Municipality})
+}
+
+// active911Payload is the JSON body for an Active911-style incident API.
+// external_id stays the same for every message about one rollup.
+func active911Payload(r rollupResponse, msgType string) map[string]interface{} {
+	description := r.Summary
+	if r.State == rollups.StateClosed && r.ClosingSummary != "" {
+		description = r.ClosingSummary
+	}
+	payload := map[string]interface{}{
+		"external_id":  fmt.Sprintf("rollup-%d", r.RollupID),
+		"message_type": strings.ToLower(msgType),
+		"title":        rollupResponseTitle(r),
+		"description":  description,
+		"category":     r.Category,
+		"priority":     r.Priority,
+		"address":      r.POI,
+		"city":         r.Municipality,
+		"state":        r.State,
+		"call_count":   r.CallCount,
+		"start_at":     r.StartAt.UTC().Format(time.RFC3339),
+		"end_at":       r.EndAt.UTC().Format(time.RFC3339),
+		"approximate":  r.Approximate,
+	}
+	if !r.Approximate {
+		payload["latitude"] = r.Latitude
+		payload["longitude"] = r.Longitude
+	}
+	return payload
+}
+
+// rollupCAPAlert renders a rollup as a CAP 1.2 alert. Updates and the
+// Cancel sent when the rollup closes reference the previous message. An
+// open rollup expires when it would close if no further calls arrive.
+func rollupCAPAlert(r rollupResponse, cfg config.Config, msgType, identifier string, sent time.Time, prev *rollupExport) cap.Alert {
+	alert := cap.Alert{
+		Identifier: identifier,
+		Sender:     cfg.CAPSender,
+		Sent:       cap.Time(sent.UTC()),
+		Status:     "Actual",
+		MsgType:    msgType,
+		Scope:      "Public",
+		Incidents:  fmt.Sprintf("rollup-%d", r.RollupID),
+	}
+	if prev != nil {
+		alert.References = cap.Reference(cfg.CAPSender, prev.Identifier, prev.CreatedAt.UTC())
+	}
+	category := capCategories[r.Category]
+	if category == "" {
+		category = "Other"
+	}
+	severity := capSeverities[r.Priority]
+	if severity == "" {
+		severity = "Unknown"
+	}
+	info := cap.Info{
+		Language:    "en-US",
+		Category:    category,
+		Event:       rollupResponseTitle(r),
+		Urgency:     "Immediate",
+		Severity:    severity,
+		Certainty:   "Observed",
+		Effective:   cap.Time(r.StartAt.UTC()),
+		Expires:     cap.Time(rollupExpiry(r, cfg).UTC()),
+		SenderName:  cfg.CAPSender,
+		Headline:    rollupResponseTitle(r),
+		Description: r.Summary,
+		Parameters: []cap.Value{
+			{Name: "call_count", Value: strconv.Itoa(r.CallCount)},
+			{Name: "priority", Value: r.Priority},
+		},
+	}
+	if r.State == rollups.StateClosed {
+		info.Urgency = "Past"
+		if r.ClosedAt != nil {
+			info.Expires = cap.Time(r.ClosedAt.UTC())
+		}
+		if r.ClosingSummary != "" {
+			info.Description = r.ClosingSummary
+		}
+	}
+	var place []string
+	for _, p := range []string{r.POI, r.Municipality} {
+		if p != "" {
+			place = append(place, p)
+		}
+	}
+	area := cap.Area{Desc: strings.Join(place, ", ")}
+	if area.Desc == "" {
+		area.Desc = "Unknown location"
+	}
+	if cfg.CAPSameCode != "" {
+		area.Geocodes = []cap.Value{{Name: "SAME", Value: cfg.CAPSameCode}}
+	}
+	if r.Approximate {
+		info.Parameters = append(info.Parameters, cap.Value{Name: "location", Value: "approximate"})
+	} else {
+		area.Circles = []string{cap.Circle(r.Latitude, r.Longitude, cfg.Rollup.RadiusMeters/1000)}
+	}
+	info.Areas = []cap.Area{area}
+	alert.Info = []cap.Info{info}
+	return alert
+}
+
+// handleRollupExports lists rollup exports, newest first:
+//
+//	GET /api/admin/rollup-exports[?rollup_id=N][&destination=active911|cap][&limit=N]
+func (s *server) handleRollupExports(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	limit := defaultRollupExportListLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxRollupExportListLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	var where []string
+	var args []interface{}
+	if raw := strings.TrimSpace(q.Get("rollup_id")); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid rollup_id", http.StatusBadRequest)
+			return
+		}
+		where = append(where, "rollup_id = ?")
+		args = append(args, id)
+	}
+	if dest := strings.TrimSpace(q.Get("destination")); dest != "" {
+		where = append(where, "destination = ?")
+		args = append(args, dest)
+	}
+	query := `SELECT id, rollup_id, destination, msg_type, identifier, status, http_status, COALESCE(error, ''), created_at FROM rollup_exports`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		log.Printf("rollup exports list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	exports := []rollupExport{}
+	for rows.Next() {
+		var d rollupExport
+		if err := rows.Scan(&d.ID, &d.RollupID, &d.Destination, &d.MsgType, &d.Identifier, &d.Status, &d.HTTPStatus, &d.Error, &d.CreatedAt); err != nil {
+			log.Printf("rollup exports scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		exports = append(exports, d)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("rollup exports list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"exports": exports})
+}
@@ -13,30 +13,38 @@ import (
 	"time"
 
 	"alert_framework/queue"
+	"alert_framework/rollups"
 )
 
 type rollupResponse struct {
-	RollupID        int64     `json:"rollup_id"`
-	StartAt         time.Time `json:"start_at"`
-	EndAt           time.Time `json:"end_at"`
-	Latitude        float64   `json:"latitude"`
-	Longitude       float64   `json:"longitude"`
-	Municipality    string    `json:"municipality,omitempty"`
-	POI             string    `json:"poi,omitempty"`
-	Category        string    `json:"category"`
-	Priority        string    `json:"priority"`
-	Title           string    `json:"title,omitempty"`
-	Summary         string    `json:"summary,omitempty"`
-	Evidence        []string  `json:"evidence,omitempty"`
-	Confidence      string    `json:"confidence,omitempty"`
-	Status          string    `json:"status"`
-	MergeSuggestion string    `json:"merge_suggestion,omitempty"`
-	ModelName       string    `json:"model_name,omitempty"`
-	ModelBaseURL    string    `json:"model_base_url,omitempty"`
-	PromptVersion   string    `json:"prompt_version,omitempty"`
-	CallCount       int       `json:"call_count"`
-	LastError       *string   `json:"last_error,omitempty"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	RollupID        int64             `json:"rollup_id"`
+	StartAt         time.Time         `json:"start_at"`
+	EndAt           time.Time         `json:"end_at"`
+	Latitude        float64           `json:"latitude"`
+	Longitude       float64           `json:"longitude"`
+	Approximate     bool              `json:"approximate"`
+	Municipality    string            `json:"municipality,omitempty"`
+	POI             string            `json:"poi,omitempty"`
+	Category        string            `json:"category"`
+	Priority        string            `json:"priority"`
+	Title           string            `json:"title,omitempty"`
+	Summary         string            `json:"summary,omitempty"`
+	Evidence        []string          `json:"evidence,omitempty"`
+	Confidence      string            `json:"confidence,omitempty"`
+	Status          string            `json:"status"`
+	MergeSuggestion string            `json:"merge_suggestion,omitempty"`
+	ModelName       string            `json:"model_name,omitempty"`
+	ModelBaseURL    string            `json:"model_base_url,omitempty"`
+	PromptVersion   string            `json:"prompt_version,omitempty"`
+	CallCount       int               `json:"call_count"`
+	LastError       *string           `json:"last_error,omitempty"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	Curated         bool              `json:"curated"`
+	State           string            `json:"state"`
+	ClosedAt        *time.Time        `json:"closed_at,omitempty"`
+	ClosingSummary  string            `json:"closing_summary,omitempty"`
+	Acknowledgement *acknowledgement  `json:"acknowledgement,omitempty"`
+	Comments        []incidentComment `json:"comments,omitempty"`
 }
 
 type rollupDetailResponse struct {
@@ -58,10 +66,24 @@ func (s *server) handleRollups(w http.ResponseWriter, r *http.Request) {
 		limit = 200
 	}
 	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	state := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("state")))
 	from, _ := parseTimeParam(r.URL.Query().Get("from"))
 	to, _ := parseTimeParam(r.URL.Query().Get("to"))
 
-	query := `SELECT id, start_at, end_at, latitude, longitude, municipality, poi, category, priority, title, summary, evidence_json, confidence, status, merge_suggestion, model_name, model_base_url, prompt_version, call_count, last_error, updated_at FROM rollups`
+	rollups, err := s.listRollups(from, to, status, state, limit)
+	if err != nil {
+		log.Printf("rollups query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.attachRollupNotes(r, rollups)
+	respondJSON(w, rollupListResponse{Rollups: rollups})
+}
+
+// listRollups returns up to limit rollups, most recently updated first,
+// filtered by whichever of from, to, status and state are set.
+func (s *server) listRollups(from, to time.Time, status, state string, limit int) ([]rollupResponse, error) {
+	query := `SELECT ` + rollupColumns + ` FROM rollups`
 	clauses := []string{}
 	args := []interface{}{}
 	if !from.IsZero() {
@@ -76,6 +98,10 @@ func (s *server) handleRollups(w http.ResponseWriter, r *http.Request) {
 		clauses = append(clauses, "status = ?")
 		args = append(args, status)
 	}
+	if state != "" {
+		clauses = append(clauses, "COALESCE(state, 'active') = ?")
+		args = append(args, state)
+	}
 	if len(clauses) > 0 {
 		query += " WHERE " + strings.Join(clauses, " AND ")
 	}
@@ -84,64 +110,30 @@ func (s *server) handleRollups(w http.ResponseWriter, r *http.Request) {
 
 	rows, err := queryWithRetry(s.db, query, args...)
 	if err != nil {
-		log.Printf("rollups query failed: %v", err)
-		http.Error(w, "db error", http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
 	var rollups []rollupResponse
 	for rows.Next() {
 		var resp rollupResponse
-		var evidenceJSON sql.NullString
-		var municipality, poi, title, summary, confidence, mergeSuggestion, modelName, modelBaseURL, promptVersion sql.NullString
-		var lastError sql.NullString
-		if err := rows.Scan(
-			&resp.RollupID,
-			&resp.StartAt,
-			&resp.EndAt,
-			&resp.Latitude,
-			&resp.Longitude,
-			&municipality,
-			&poi,
-			&resp.Category,
-			&resp.Priority,
-			&title,
-			&summary,
-			&evidenceJSON,
-			&confidence,
-			&resp.Status,
-			&mergeSuggestion,
-			&modelName,
-			&modelBaseURL,
-			&promptVersion,
-			&resp.CallCount,
-			&lastError,
-			&resp.UpdatedAt,
-		); err != nil {
-			http.Error(w, "db error", http.StatusInternalServerError)
-			return
-		}
-		resp.Municipality = municipality.String
-		resp.POI = poi.String
-		resp.Title = title.String
-		resp.Summary = summary.String
-		resp.Confidence = confidence.String
-		resp.MergeSuggestion = mergeSuggestion.String
-		resp.ModelName = modelName.String
-		resp.ModelBaseURL = modelBaseURL.String
-		resp.PromptVersion = promptVersion.String
-		if lastError.Valid {
-			resp.LastError = &lastError.String
+		if err := scanRollup(rows, &resp); err != nil {
+			return nil, err
 		}
-		resp.Evidence = decodeEvidence(evidenceJSON.String)
 		rollups = append(rollups, resp)
 	}
-
-	respondJSON(w, rollupListResponse{Rollups: rollups})
+	return rollups, rows.Err()
 }
 
 func (s *server) handleRollupDetail(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(strings.TrimRight(r.URL.Path, "/"), "/merge") || strings.HasSuffix(strings.TrimRight(r.URL.Path, "/"), "/split") {
+		s.handleRollupCurate(w, r)
+		return
+	}
+	if parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/rollups/"), "/"), "/"); len(parts) == 2 && (parts[1] == "ack" || parts[1] == "comments") {
+		s.handleRollupNotes(w, r, parts[0], parts[1])
+		return
+	}
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -175,7 +167,31 @@ func (s *server) handleRollupDetail(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
-	respondJSON(w, rollupDetailResponse{Rollup: rollup, CallIDs: callIDs})
+	notes := []rollupResponse{rollup}
+	s.attachRollupNotes(r, notes)
+	respondJSON(w, rollupDetailResponse{Rollup: notes[0], CallIDs: callIDs})
+}
+
+// handleRollupNotes serves /api/rollups/{id}/ack and /api/rollups/{id}/comments.
+// Acknowledging a rollup also stops escalation of the calls in it.
+func (s *server) handleRollupNotes(w http.ResponseWriter, r *http.Request, rawID, action string) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if _, err := s.fetchRollup(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	s.serveIncidentNotes(w, r, noteTargetRollup, strconv.FormatInt(id, 10), action)
 }
 
 func (s *server) handleRollupCalls(w http.ResponseWriter, r *http.Request) {
@@ -208,35 +224,125 @@ func (s *server) handleRollupCalls(w http.ResponseWriter, r *http.Request) {
 		respondJSON(w, map[string]interface{}{"calls": []transcriptionResponse{}})
 		return
 	}
-	placeholders := strings.Repeat("?,", len(callIDs))
-	placeholders = strings.TrimSuffix(placeholders, ",")
-	query := fmt.Sprintf(`SELECT id, filename, source_path, processed_path, COALESCE(ingest_source,'') as ingest_source, transcript_text, raw_transcript_text, clean_transcript_text, translation_text, status, last_error, size_bytes, duration_seconds, hash, duplicate_of, requested_model, requested_mode, requested_format, actual_openai_model_used, diarized_json, recognized_towns, normalized_transcript, call_type, call_timestamp, tags, latitude, longitude, location_label, location_source, refined_metadata, address_json, needs_manual_review, created_at, updated_at FROM transcriptions WHERE id IN (%s)`, placeholders)
-
-	args := make([]interface{}, 0, len(callIDs))
-	for _, id := range callIDs {
-		args = append(args, id)
-	}
-	rows, err := queryWithRetry(s.db, query, args...)
+	records, err := s.store.ListByIDs(r.Context(), callIDs)
 	if err != nil {
 		http.Error(w, "db error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
 	baseURL := s.resolveBaseURL(r)
 	var calls []transcriptionResponse
-	for rows.Next() {
-		var t transcription
-		if err := scanTranscription(rows, &t); err != nil {
-			http.Error(w, "db error", http.StatusInternalServerError)
-			return
+	for _, t := range records {
+		if !s.visibleTo(r, t) {
+			continue
 		}
-		calls = append(calls, s.toResponse(t, baseURL))
+		calls = append(calls, s.responseFor(r, t, baseURL))
 	}
 
 	respondJSON(w, map[string]interface{}{"calls": calls})
 }
 
+type rollupMergeRequest struct {
+	RollupIDs []int64 `json:"rollup_ids"`
+}
+
+type rollupSplitRequest struct {
+	CallIDs []int64 `json:"call_ids"`
+}
+
+type rollupSplitResponse struct {
+	Rollup rollupDetailResponse `json:"rollup"`
+	Split  rollupDetailResponse `json:"split"`
+}
+
+// handleRollupCurate serves POST /api/rollups/{id}/merge and /split. Curated
+// rollups are left alone by the scheduler's recompute.
+func (s *server) handleRollupCurate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if s.rollups == nil {
+		http.Error(w, "rollup workers disabled", http.StatusServiceUnavailable)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/rollups/"), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var splitID int64
+	switch parts[1] {
+	case "merge":
+		var req rollupMergeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		err = s.rollups.Merge(r.Context(), id, req.RollupIDs)
+	case "split":
+		var req rollupSplitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		splitID, err = s.rollups.Split(r.Context(), id, req.CallIDs)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, rollups.ErrNotFound):
+			http.NotFound(w, r)
+		case errors.Is(err, rollups.ErrInvalidMerge), errors.Is(err, rollups.ErrInvalidSplit):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("rollup %s %d failed: %v", parts[1], id, err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+		}
+		return
+	}
+	log.Printf("rollup %d %s by operator", id, parts[1])
+
+	detail, err := s.rollupDetail(r.Context(), id)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if splitID == 0 {
+		respondJSON(w, detail)
+		return
+	}
+	split, err := s.rollupDetail(r.Context(), splitID)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, rollupSplitResponse{Rollup: detail, Split: split})
+}
+
+func (s *server) rollupDetail(ctx context.Context, id int64) (rollupDetailResponse, error) {
+	rollup, err := s.fetchRollup(ctx, id)
+	if err != nil {
+		return rollupDetailResponse{}, err
+	}
+	callIDs, err := s.fetchRollupCallIDs(ctx, id)
+	if err != nil {
+		return rollupDetailResponse{}, err
+	}
+	return rollupDetailResponse{Rollup: rollup, CallIDs: callIDs}, nil
+}
+
 func (s *server) handleRollupRecompute(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -255,10 +361,22 @@ func (s *server) handleRollupRecompute(w http.ResponseWriter, r *http.Request) {
 
 func (s *server) fetchRollup(ctx context.Context, id int64) (rollupResponse, error) {
 	var resp rollupResponse
+	row := s.db.QueryRowContext(ctx, `SELECT `+rollupColumns+` FROM rollups WHERE id = ?`, id)
+	err := scanRollup(row, &resp)
+	return resp, err
+}
+
+// rollupColumns is the column list scanRollup expects.
+const rollupColumns = `id, start_at, end_at, latitude, longitude, municipality, poi, category, priority, title, summary, evidence_json, confidence, status, merge_suggestion, model_name, model_base_url, prompt_version, call_count, last_error, updated_at, curated, COALESCE(state, 'active'), closed_at, closing_summary, COALESCE(approximate, 0)`
+
+func scanRollup(row rowScanner, resp *rollupResponse) error {
 	var evidenceJSON sql.NullString
 	var municipality, poi, title, summary, confidence, mergeSuggestion, modelName, modelBaseURL, promptVersion sql.NullString
 	var lastError sql.NullString
-	row := s.db.QueryRowContext(ctx, `SELECT id, start_at, end_at, latitude, longitude, municipality, poi, category, priority, title, summary, evidence_json, confidence, status, merge_suggestion, model_name, model_base_url, prompt_version, call_count, last_error, updated_at FROM rollups WHERE id = ?`, id)
+	var curated sql.NullInt64
+	var closedAt sql.NullTime
+	var closingSummary sql.NullString
+	var approximate int
 	if err := row.Scan(
 		&resp.RollupID,
 		&resp.StartAt,
@@ -281,8 +399,13 @@ func (s *server) fetchRollup(ctx context.Context, id int64) (rollupResponse, err
 		&resp.CallCount,
 		&lastError,
 		&resp.UpdatedAt,
+		&curated,
+		&resp.State,
+		&closedAt,
+		&closingSummary,
+		&approximate,
 	); err != nil {
-		return resp, err
+		return err
 	}
 	resp.Municipality = municipality.String
 	resp.POI = poi.String
@@ -294,10 +417,17 @@ func (s *server) fetchRollup(ctx context.Context, id int64) (rollupResponse, err
 	resp.ModelBaseURL = modelBaseURL.String
 	resp.PromptVersion = promptVersion.String
 	resp.Evidence = decodeEvidence(evidenceJSON.String)
+	resp.Curated = curated.Int64 == 1
+	resp.Approximate = approximate == 1
+	resp.ClosingSummary = closingSummary.String
+	if closedAt.Valid {
+		ts := closedAt.Time.UTC()
+		resp.ClosedAt = &ts
+	}
 	if lastError.Valid {
 		resp.LastError = &lastError.String
 	}
-	return resp, nil
+	return nil
 }
 
 func (s *server) fetchRollupCallIDs(ctx context.Context, id int64) ([]int64, error) {
@@ -355,10 +485,7 @@ func parseIntDefault(value string, fallback int) int {
 }
 
 func (s *server) startRollupScheduler(ctx context.Context) {
-	interval := time.Duration(s.cfg.Rollup.RefreshIntervalSec) * time.Second
-	if interval <= 0 {
-		interval = 60 * time.Second
-	}
+	interval := s.rollupInterval()
 	go func() {
 		_ = s.enqueueRollupJob("startup")
 		ticker := time.NewTicker(interval)
@@ -371,11 +498,24 @@ func (s *server) startRollupScheduler(ctx context.Context) {
 				return
 			case <-ticker.C:
 				_ = s.enqueueRollupJob("interval")
+				// A reload may have changed the interval.
+				if next := s.rollupInterval(); next != interval {
+					interval = next
+					ticker.Reset(interval)
+				}
 			}
 		}
 	}()
 }
 
+func (s *server) rollupInterval() time.Duration {
+	interval := time.Duration(s.liveConfig().Rollup.RefreshIntervalSec) * time.Second
+	if interval <= 0 {
+		interval = 60 * time.Second
+	}
+	return interval
+}
+
 func (s *server) enqueueRollupJob(source string) bool {
 	if s.queue == nil || s.rollups == nil {
 		return false
@@ -393,8 +533,15 @@ func (s *server) enqueueRollupJob(source string) bool {
 		Source:   source,
 		FileName: "rollup-recompute",
 		Work: func(ctx context.Context) error {
-			_, err := s.rollups.Recompute(ctx)
-			return err
+			queue.SetStage(ctx, "recompute")
+			result, err := s.rollups.Recompute(ctx)
+			if err != nil {
+				return err
+			}
+			s.notifyRollupGrowth(result.Grown)
+			s.notifyRollupClosures(result.Closed)
+			s.exportRollups(ctx)
+			return nil
 		},
 		OnFinish: func(err error) {
 			s.rollupMu.Lock()
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"alert_framework/config"
+	"alert_framework/rollups"
+)
+
+func testRollupConfig() config.RollupConfig {
+	return config.RollupConfig{
+		LookbackHours:      6,
+		ChainWindowMin:     30,
+		RadiusMeters:       800,
+		MaxCalls:           50,
+		RefreshIntervalSec: 60,
+		MonitorAfterMin:    30,
+		CloseAfterMin:      120,
+		NotifyPriority:     "off",
+		Strategy:           "greedy",
+		DBSCANMinPoints:    2,
+	}
+}
+
+func newRollupTestServer(t *testing.T) *server {
+	t.Helper()
+	s := newTestServer(t, config.Config{RestrictedCallFlags: []string{"mental_health"}, Rollup: testRollupConfig()})
+	s.rollups = rollups.NewService(s.db, http.DefaultClient, s.cfg.Rollup)
+	s.rollups.SetExclude(func(callType, transcript string) bool {
+		return len(s.restrictedFlags(callType, transcript)) > 0
+	})
+	return s
+}
+
+// rollupCallSet returns every call id held by a stored rollup.
+func rollupCallSet(t *testing.T, s *server) map[int64]bool {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT call_id FROM rollup_calls`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	out := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		out[id] = true
+	}
+	return out
+}
+
+func TestRollupsLeaveOutRestrictedCalls(t *testing.T) {
+	s := newRollupTestServer(t)
+	now := time.Now().UTC().Add(-10 * time.Minute)
+	fire := insertTestCall(t, s.db, testCall{filename: "a.mp3", callType: "structure fire", transcript: "smoke showing at 12 Main St", at: now, lat: 41.05, lng: -74.75})
+	fire2 := insertTestCall(t, s.db, testCall{filename: "b.mp3", callType: "structure fire", transcript: "second alarm at 12 Main St", at: now.Add(time.Minute), lat: 41.05, lng: -74.75})
+	private := insertTestCall(t, s.db, testCall{filename: "c.mp3", callType: "medical", transcript: "subject is suicidal at 12 Main St", at: now.Add(2 * time.Minute), lat: 41.05, lng: -74.75})
+
+	if _, err := s.rollups.Recompute(context.Background()); err != nil {
+		t.Fatalf("recompute: %v", err)
+	}
+	calls := rollupCallSet(t, s)
+	if !calls[fire] || !calls[fire2] {
+		t.Fatalf("expected both fire calls rolled up, got %v", calls)
+	}
+	if calls[private] {
+		t.Fatalf("restricted call %d was rolled up", private)
+	}
+}
+
+func TestRollupsPurgeStoredRestrictedRollups(t *testing.T) {
+	s := newRollupTestServer(t)
+	at := time.Now().UTC().Add(-48 * time.Hour)
+	private := insertTestCall(t, s.db, testCall{filename: "old.mp3", callType: "psych eval", transcript: "units en route", at: at, lat: 41.05, lng: -74.75})
+	res, err := s.db.Exec(`INSERT INTO rollups (rollup_key, start_at, end_at, latitude, longitude, category, priority, title, evidence_json, status, call_count, state, curated)
+VALUES ('legacy', ?, ?, 41.05, -74.75, 'ems', 'normal', 'Psych eval on Main St', '[]', 'llm_skipped', 1, 'closed', 1)`, at, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollupID, _ := res.LastInsertId()
+	if _, err := s.db.Exec(`INSERT INTO rollup_calls (rollup_id, call_id) VALUES (?, ?)`, rollupID, private); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.rollups.Recompute(context.Background()); err != nil {
+		t.Fatalf("recompute: %v", err)
+	}
+	list, err := s.listRollups(time.Time{}, time.Time{}, "", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range list {
+		if r.RollupID == rollupID {
+			t.Fatalf("closed curated rollup holding a restricted call is still listed: %+v", r)
+		}
+	}
+}
+
+func TestRollupsRebuiltWithoutDeletedCalls(t *testing.T) {
+	s := newRollupTestServer(t)
+	now := time.Now().UTC().Add(-10 * time.Minute)
+	first := insertTestCall(t, s.db, testCall{filename: "a.mp3", callType: "structure fire", transcript: "smoke showing at 12 Main St", at: now, lat: 41.05, lng: -74.75})
+	second := insertTestCall(t, s.db, testCall{filename: "b.mp3", callType: "structure fire", transcript: "second alarm at 12 Main St", at: now.Add(time.Minute), lat: 41.05, lng: -74.75})
+	if _, err := s.rollups.Recompute(context.Background()); err != nil {
+		t.Fatalf("recompute: %v", err)
+	}
+	var rollupID int64
+	if err := s.db.QueryRow(`SELECT rollup_id FROM rollup_calls WHERE call_id = ?`, second).Scan(&rollupID); err != nil {
+		t.Fatalf("second call not rolled up: %v", err)
+	}
+	// Curated and closed rollups are kept out of clustering, so only the
+	// rebuild can shrink this one.
+	if _, err := s.db.Exec(`UPDATE rollups SET curated = 1, state = 'closed', closed_at = ? WHERE id = ?`, now, rollupID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec(`UPDATE transcriptions SET deleted_at = ? WHERE id = ?`, time.Now().UTC(), second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.rollups.Recompute(context.Background()); err != nil {
+		t.Fatalf("recompute: %v", err)
+	}
+	calls := rollupCallSet(t, s)
+	if calls[second] || !calls[first] {
+		t.Fatalf("rollup calls after delete = %v, want only %d", calls, first)
+	}
+	var count, curated int
+	var state string
+	if err := s.db.QueryRow(`SELECT call_count, curated, state FROM rollups WHERE id = ?`, rollupID).Scan(&count, &curated, &state); err != nil {
+		t.Fatalf("rollup %d gone: %v", rollupID, err)
+	}
+	if count != 1 || curated != 1 || state != "closed" {
+		t.Fatalf("rebuilt rollup: call_count %d, curated %d, state %s", count, curated, state)
+	}
+
+	if _, err := s.db.Exec(`UPDATE transcriptions SET deleted_at = ? WHERE id = ?`, time.Now().UTC(), first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.rollups.Recompute(context.Background()); err != nil {
+		t.Fatalf("recompute: %v", err)
+	}
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM rollups WHERE id = ?`, rollupID).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("rollup with every call deleted still stored (%d, %v)", n, err)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+	"alert_framework/rollups"
+)
+
+// notifyRollupClosures announces rollups that closed during a recompute to
+// GroupMe and the configured webhooks.
+func (s *server) notifyRollupClosures(closures []rollups.Closure) {
+	if len(closures) == 0 || !s.liveConfig().Rollup.NotifyClosures {
+		return
+	}
+	settings, err := s.loadSettings()
+	if err != nil {
+		log.Printf("rollup closure settings load failed: %v", err)
+	}
+	for _, c := range closures {
+		if err := s.sendGroupMe(formatRollupClosure(c, s.tz)); err != nil {
+			log.Printf("groupme rollup closure failed for %d: %v", c.Rollup.ID, err)
+		}
+		if len(settings.WebhookEndpoints) > 0 {
+			s.postWebhooks(settings.WebhookEndpoints, rollupWebhookEvent(c.Rollup), rollupClosurePayload(c))
+		}
+	}
+}
+
+func formatRollupClosure(c rollups.Closure, tz *time.Location) string {
+	title := rollupTitle(c.Rollup)
+	window := fmt.Sprintf("%s–%s", c.Rollup.StartAt.In(tz).Format("15:04"), c.Rollup.EndAt.In(tz).Format("15:04"))
+	return fmt.Sprintf("✅ Incident concluded: %s\n%s\n%d calls, %s", title, strings.TrimSpace(c.Summary), c.Rollup.CallCount, window)
+}
+
+func rollupClosurePayload(c rollups.Closure) map[string]interface{} {
+	return map[string]interface{}{
+		"event":           "rollup.closed",
+		"rollup_id":       c.Rollup.ID,
+		"title":           nullableString(c.Rollup.Title),
+		"summary":         nullableString(c.Rollup.Summary),
+		"closing_summary": c.Summary,
+		"municipality":    nullableString(c.Rollup.Municipality),
+		"category":        c.Rollup.Category,
+		"priority":        c.Rollup.Priority,
+		"start_at":        c.Rollup.StartAt.UTC().Format(time.RFC3339),
+		"end_at":          c.Rollup.EndAt.UTC().Format(time.RFC3339),
+		"closed_at":       c.Closed.UTC().Format(time.RFC3339),
+		"call_count":      c.Rollup.CallCount,
+		"call_ids":        c.Rollup.CallIDs,
+	}
+}
+
+// notifyRollupGrowth posts an update for rollups that crossed a call-count or
+// priority threshold.
+func (s *server) notifyRollupGrowth(grown []rollups.Growth) {
+	if len(grown) == 0 {
+		return
+	}
+	settings, err := s.loadSettings()
+	if err != nil {
+		log.Printf("rollup growth settings load failed: %v", err)
+	}
+	for _, g := range grown {
+		if err := s.sendGroupMe(formatRollupGrowth(g, s.tz)); err != nil {
+			log.Printf("groupme rollup growth failed for %d: %v", g.Rollup.ID, err)
+		}
+		if len(settings.WebhookEndpoints) > 0 {
+			s.postWebhooks(settings.WebhookEndpoints, rollupWebhookEvent(g.Rollup), rollupGrowthPayload(g))
+		}
+	}
+}
+
+func rollupWebhookEvent(r rollups.Rollup) webhookEvent {
+	return webhookEvent{Towns: []string{r.Municipality}, Category: r.Category, Priority: r.Priority}
+}
+
+func rollupTitle(r rollups.Rollup) string {
+	title := strings.TrimSpace(r.Title)
+	if title == "" {
+		title = strings.TrimSpace(r.Category)
+		if r.Municipality != "" {
+			title += " – " + r.Municipality
+		}
+	}
+	return title
+}
+
+func formatRollupGrowth(g rollups.Growth, tz *time.Location) string {
+	lines := []string{
+		fmt.Sprintf("📈 Incident update: %s (%s)", rollupTitle(g.Rollup), g.Reason),
+		fmt.Sprintf("%d calls since %s, %s priority", g.Rollup.CallCount, g.Rollup.StartAt.In(tz).Format("15:04"), g.Rollup.Priority),
+	}
+	if summary := strings.TrimSpace(g.Rollup.Summary); summary != "" {
+		lines = append(lines, summary)
+	}
+	if mapURL := formatting.BuildMapURL(g.Rollup.Latitude, g.Rollup.Longitude); mapURL != "" {
+		lines = append(lines, "Map: "+mapURL)
+	} else if g.Rollup.Approximate {
+		lines = append(lines, "Location approximate: calls were not geocoded")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func rollupGrowthPayload(g rollups.Growth) map[string]interface{} {
+	return map[string]interface{}{
+		"event":        "rollup.growth",
+		"reason":       g.Reason,
+		"rollup_id":    g.Rollup.ID,
+		"title":        nullableString(g.Rollup.Title),
+		"summary":      nullableString(g.Rollup.Summary),
+		"municipality": nullableString(g.Rollup.Municipality),
+		"category":     g.Rollup.Category,
+		"priority":     g.Rollup.Priority,
+		"state":        g.Rollup.State,
+		"latitude":     g.Rollup.Latitude,
+		"longitude":    g.Rollup.Longitude,
+		"approximate":  g.Rollup.Approximate,
+		"map_url":      nullableString(formatting.BuildMapURL(g.Rollup.Latitude, g.Rollup.Longitude)),
+		"start_at":     g.Rollup.StartAt.UTC().Format(time.RFC3339),
+		"end_at":       g.Rollup.EndAt.UTC().Format(time.RFC3339),
+		"call_count":   g.Rollup.CallCount,
+		"call_ids":     g.Rollup.CallIDs,
+	}
+}
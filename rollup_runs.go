@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"alert_framework/rollups"
+)
+
+const (
+	defaultRollupRunLimit = 50
+	maxRollupRunLimit     = 500
+
+	// rollupBehindIntervals is how many refresh intervals may pass without a
+	// successful recompute before clustering counts as falling behind.
+	rollupBehindIntervals = 3
+)
+
+// Statuses a rollup run is reported with, beyond the "ok" and "failed" the
+// rollup service records.
+const (
+	rollupRunRunning     = "running"
+	rollupRunInterrupted = "interrupted"
+)
+
+func migrateAddRollupRunMetrics(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "rollup_runs", "strategy", "TEXT NULL"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(db, "rollup_runs", "metrics_json", "TEXT NULL")
+}
+
+func downRollupRunMetrics(db *sql.DB) error {
+	if err := dropColumnIfExists(db, "rollup_runs", "metrics_json"); err != nil {
+		return err
+	}
+	return dropColumnIfExists(db, "rollup_runs", "strategy")
+}
+
+type rollupRun struct {
+	ID              int64      `json:"id"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	Status          string     `json:"status"`
+	DurationSeconds *float64   `json:"duration_seconds,omitempty"`
+	RollupCount     int        `json:"rollup_count"`
+	Error           string     `json:"error,omitempty"`
+	Strategy        string     `json:"strategy,omitempty"`
+
+	// Metrics holds every strategy's clustering of the run's calls, keyed by
+	// strategy name.
+	Metrics map[string]rollups.ClusterMetrics `json:"metrics,omitempty"`
+}
+
+// rollupRunProgress is the recompute running on this instance.
+type rollupRunProgress struct {
+	RunID          int64   `json:"run_id,omitempty"`
+	StartedAt      string  `json:"started_at"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	Calls          int     `json:"calls"`
+	Clusters       int     `json:"clusters"`
+	Done           int     `json:"clusters_done"`
+}
+
+type rollupRunsResponse struct {
+	Running         *rollupRunProgress `json:"running"`
+	Queued          bool               `json:"queued"`
+	IntervalSeconds int                `json:"interval_seconds"`
+	LastSuccessAt   *time.Time         `json:"last_success_at,omitempty"`
+	Behind          bool               `json:"behind"`
+	Runs            []rollupRun        `json:"runs"`
+
+	// Comparison averages each strategy's metrics over the listed runs.
+	Comparison map[string]rollupStrategySummary `json:"comparison"`
+}
+
+type rollupStrategySummary struct {
+	Runs               int     `json:"runs"`
+	Clusters           float64 `json:"avg_clusters"`
+	Singletons         float64 `json:"avg_singletons"`
+	MaxSize            int     `json:"max_size"`
+	AvgRadiusMeters    float64 `json:"avg_radius_m"`
+	MaxRadiusMeters    float64 `json:"max_radius_m"`
+	MunicipalityPurity float64 `json:"municipality_purity"`
+	CallTypePurity     float64 `json:"call_type_purity"`
+}
+
+// handleRollupRuns serves GET /api/rollups/runs[?limit=50]: past rollup
+// recomputes, newest first, with the one in progress on this instance and
+// whether recomputes are keeping up with the refresh interval.
+func (s *server) handleRollupRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	limit := parseIntDefault(r.URL.Query().Get("limit"), defaultRollupRunLimit)
+	if limit <= 0 || limit > maxRollupRunLimit {
+		limit = defaultRollupRunLimit
+	}
+	runs, err := s.listRollupRuns(r.Context(), limit)
+	if err != nil {
+		log.Printf("rollup runs query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	resp := rollupRunsResponse{Running: s.rollupProgress(), Queued: s.rollupQueued(), Runs: runs, Comparison: compareRollupStrategies(runs)}
+	resp.IntervalSeconds = int(s.rollupInterval().Seconds())
+	resp.LastSuccessAt, resp.Behind = s.rollupLag(r.Context())
+	respondJSON(w, resp)
+}
+
+// listRollupRuns returns the latest runs. A run still marked running that
+// this instance is not running and that has outlived the job timeout was cut
+// off by a crash or restart.
+func (s *server) listRollupRuns(ctx context.Context, limit int) ([]rollupRun, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, started_at, finished_at, COALESCE(status, ''), COALESCE(error, ''), COALESCE(rollup_count, 0), COALESCE(strategy, ''), COALESCE(metrics_json, '') FROM rollup_runs ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var current int64
+	if p := s.rollupProgress(); p != nil {
+		current = p.RunID
+	}
+	timeout := time.Duration(s.cfg.JobTimeoutSec) * time.Second
+	runs := []rollupRun{}
+	for rows.Next() {
+		var run rollupRun
+		var finished sql.NullTime
+		var metrics string
+		if err := rows.Scan(&run.ID, &run.StartedAt, &finished, &run.Status, &run.Error, &run.RollupCount, &run.Strategy, &metrics); err != nil {
+			return nil, err
+		}
+		if metrics != "" {
+			if err := json.Unmarshal([]byte(metrics), &run.Metrics); err != nil {
+				log.Printf("rollup run %d metrics unreadable: %v", run.ID, err)
+			}
+		}
+		if finished.Valid {
+			run.FinishedAt = &finished.Time
+			d := math.Round(finished.Time.Sub(run.StartedAt).Seconds()*10) / 10
+			run.DurationSeconds = &d
+		} else if run.Status == rollupRunRunning && run.ID != current && time.Since(run.StartedAt) > timeout {
+			run.Status = rollupRunInterrupted
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// compareRollupStrategies averages each strategy's metrics over the runs
+// that recorded them.
+func compareRollupStrategies(runs []rollupRun) map[string]rollupStrategySummary {
+	out := map[string]rollupStrategySummary{}
+	for _, run := range runs {
+		for name, m := range run.Metrics {
+			sum := out[name]
+			sum.Runs++
+			sum.Clusters += float64(m.Clusters)
+			sum.Singletons += float64(m.Singletons)
+			sum.AvgRadiusMeters += m.AvgRadiusMeters
+			sum.MunicipalityPurity += m.MunicipalityPurity
+			sum.CallTypePurity += m.CallTypePurity
+			if m.MaxSize > sum.MaxSize {
+				sum.MaxSize = m.MaxSize
+			}
+			sum.MaxRadiusMeters = math.Max(sum.MaxRadiusMeters, m.MaxRadiusMeters)
+			out[name] = sum
+		}
+	}
+	for name, sum := range out {
+		n := float64(sum.Runs)
+		sum.Clusters = math.Round(sum.Clusters/n*10) / 10
+		sum.Singletons = math.Round(sum.Singletons/n*10) / 10
+		sum.AvgRadiusMeters = math.Round(sum.AvgRadiusMeters / n)
+		sum.MunicipalityPurity = math.Round(sum.MunicipalityPurity/n*1000) / 1000
+		sum.CallTypePurity = math.Round(sum.CallTypePurity/n*1000) / 1000
+		out[name] = sum
+	}
+	return out
+}
+
+// rollupProgress returns the recompute running on this instance, or nil.
+func (s *server) rollupProgress() *rollupRunProgress {
+	if s.rollups == nil {
+		return nil
+	}
+	p := s.rollups.Progress()
+	if !p.Running {
+		return nil
+	}
+	return &rollupRunProgress{
+		RunID:          p.RunID,
+		StartedAt:      p.StartedAt.Format(time.RFC3339),
+		ElapsedSeconds: math.Round(time.Since(p.StartedAt).Seconds()*10) / 10,
+		Calls:          p.Calls,
+		Clusters:       p.Clusters,
+		Done:           p.Done,
+	}
+}
+
+// rollupQueued reports whether a recompute is waiting in this instance's
+// queue or running.
+func (s *server) rollupQueued() bool {
+	s.rollupMu.Lock()
+	defer s.rollupMu.Unlock()
+	return s.rollupEnqueued
+}
+
+// rollupLag returns when the last successful recompute finished and whether
+// that is more than rollupBehindIntervals refresh intervals ago, or since
+// the first run when none has succeeded. Without any runs there is nothing
+// to be behind on.
+func (s *server) rollupLag(ctx context.Context) (*time.Time, bool) {
+	var lastOK, first sql.NullString
+	if err := s.db.QueryRowContext(ctx, `SELECT (SELECT MAX(finished_at) FROM rollup_runs WHERE status = 'ok'), (SELECT MIN(started_at) FROM rollup_runs)`).Scan(&lastOK, &first); err != nil {
+		log.Printf("rollup lag query failed: %v", err)
+		return nil, false
+	}
+	since := parseStatusTime(first.String)
+	if since == nil {
+		return nil, false
+	}
+	last := parseStatusTime(lastOK.String)
+	if last != nil {
+		since = last
+	}
+	return last, time.Since(*since) > rollupBehindIntervals*s.rollupInterval()
+}
+
+// statusRollups is the rollup summary on /api/status. Errors are left out
+// since the status page is public.
+type statusRollups struct {
+	LastRun       *rollupRun `json:"last_run,omitempty"`
+	Running       bool       `json:"running"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	Behind        bool       `json:"behind"`
+}
+
+func (s *server) rollupStatus(ctx context.Context) *statusRollups {
+	runs, err := s.listRollupRuns(ctx, 1)
+	if err != nil {
+		log.Printf("rollup status query failed: %v", err)
+		return nil
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+	out := &statusRollups{LastRun: &runs[0], Running: runs[0].Status == rollupRunRunning}
+	out.LastRun.Error = ""
+	out.LastRun.Metrics = nil
+	out.LastSuccessAt, out.Behind = s.rollupLag(ctx)
+	return out
+}
@@ -95,19 +95,26 @@ func groupCalls(calls []CallRecord, chainWindow time.Duration, radiusMeters floa
 	return out
 }
 
+// averageCoordinate averages the calls that have coordinates; it is 0 when
+// none do.
 func averageCoordinate(calls []CallRecord, useLat bool) float64 {
-	if len(calls) == 0 {
-		return 0
-	}
 	var sum float64
+	n := 0
 	for _, c := range calls {
+		if !hasLocation(c) {
+			continue
+		}
+		n++
 		if useLat {
 			sum += c.Latitude
 		} else {
 			sum += c.Longitude
 		}
 	}
-	return sum / float64(len(calls))
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
 }
 
 func majorityString(calls []CallRecord, fn func(CallRecord) string) string {
@@ -217,6 +224,22 @@ func derivePriority(calls []CallRecord) string {
 	return priority
 }
 
+// CallPriority classifies a single call as low, medium or high priority using
+// the same keyword rules as rollups.
+func CallPriority(callType, transcript string) string {
+	return priorityForCall(CallRecord{CallType: callType, CleanTranscript: transcript})
+}
+
+// PriorityAtLeast reports whether priority meets min. An empty or unknown min
+// always matches.
+func PriorityAtLeast(priority, min string) bool {
+	minRank, ok := priorityRank[strings.ToLower(strings.TrimSpace(min))]
+	if !ok {
+		return true
+	}
+	return priorityRank[strings.ToLower(strings.TrimSpace(priority))] >= minRank
+}
+
 func priorityForCall(call CallRecord) string {
 	text := strings.ToLower(strings.TrimSpace(call.CleanTranscript))
 	if text == "" {
@@ -0,0 +1,207 @@
+package rollups
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("rollup not found")
+	ErrInvalidMerge = errors.New("merge requires other existing rollups")
+	ErrInvalidSplit = errors.New("split requires a non-empty subset of the rollup's calls")
+)
+
+// curatedKeyPrefix keeps operator-built rollups out of the key space used by
+// Recompute so an automatic cluster can never claim them.
+const curatedKeyPrefix = "curated:"
+
+// Merge folds sourceIDs into targetID. The target keeps its id, is rebuilt from
+// the combined calls and marked curated; the sources are deleted.
+func (s *Service) Merge(ctx context.Context, targetID int64, sourceIDs []int64) error {
+	sources := uniqueIDs(sourceIDs, targetID)
+	if len(sources) == 0 {
+		return ErrInvalidMerge
+	}
+	now := time.Now().UTC()
+	callIDs, err := s.rollupCallIDs(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	for _, id := range sources {
+		ids, err := s.rollupCallIDs(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return ErrInvalidMerge
+			}
+			return err
+		}
+		callIDs = append(callIDs, ids...)
+	}
+	rollup, err := s.buildCurated(ctx, uniqueIDs(callIDs, 0), now)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := updateCurated(ctx, tx, targetID, rollup, now); err != nil {
+		return err
+	}
+	for _, id := range sources {
+		if err := deleteRollup(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Split moves callIDs out of rollup id into a new curated rollup and returns
+// its id. Both halves are rebuilt and marked curated.
+func (s *Service) Split(ctx context.Context, id int64, callIDs []int64) (int64, error) {
+	now := time.Now().UTC()
+	existing, err := s.rollupCallIDs(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	members := make(map[int64]bool, len(existing))
+	for _, callID := range existing {
+		members[callID] = true
+	}
+	moving := uniqueIDs(callIDs, 0)
+	moved := make(map[int64]bool, len(moving))
+	for _, callID := range moving {
+		if !members[callID] {
+			return 0, ErrInvalidSplit
+		}
+		moved[callID] = true
+	}
+	var remaining []int64
+	for _, callID := range existing {
+		if !moved[callID] {
+			remaining = append(remaining, callID)
+		}
+	}
+	if len(moving) == 0 || len(remaining) == 0 {
+		return 0, ErrInvalidSplit
+	}
+
+	kept, err := s.buildCurated(ctx, remaining, now)
+	if err != nil {
+		return 0, err
+	}
+	split, err := s.buildCurated(ctx, moving, now)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if err := updateCurated(ctx, tx, id, kept, now); err != nil {
+		return 0, err
+	}
+	newID, err := insertCurated(ctx, tx, split, now)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return newID, nil
+}
+
+func (s *Service) rollupCallIDs(ctx context.Context, id int64) ([]int64, error) {
+	var exists int
+	if err := s.db.QueryRowContext(ctx, `SELECT 1 FROM rollups WHERE id = ?`, id).Scan(&exists); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT call_id FROM rollup_calls WHERE rollup_id = ? ORDER BY call_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var callID int64
+		if err := rows.Scan(&callID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, callID)
+	}
+	return ids, rows.Err()
+}
+
+func (s *Service) loadCallsByID(ctx context.Context, ids []int64) ([]CallRecord, error) {
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	rows, err := s.db.QueryContext(ctx, `SELECT `+callRecordColumns+` FROM transcriptions WHERE id IN (`+placeholders+`) AND deleted_at IS NULL ORDER BY call_ts ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return s.scanCallRecords(rows)
+}
+
+func (s *Service) buildCurated(ctx context.Context, callIDs []int64, now time.Time) (Rollup, error) {
+	calls, err := s.loadCallsByID(ctx, callIDs)
+	if err != nil {
+		return Rollup{}, err
+	}
+	rollup, err := s.buildRollup(calls)
+	if err != nil {
+		return Rollup{}, err
+	}
+	rollup.Key = curatedKeyPrefix + rollup.Key
+	rollup.State = s.stateFor(rollup.EndAt, now)
+	s.summarize(ctx, &rollup, calls)
+	return rollup, nil
+}
+
+func updateCurated(ctx context.Context, tx *sql.Tx, id int64, rollup Rollup, now time.Time) error {
+	if err := writeRollup(ctx, tx, id, rollup, now); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `UPDATE rollups SET curated = 1 WHERE id = ?`, id)
+	return err
+}
+
+func insertCurated(ctx context.Context, tx *sql.Tx, rollup Rollup, now time.Time) (int64, error) {
+	args := append(rollupFieldArgs(rollup), rollup.State, closedAt(rollup.State, now))
+	res, err := tx.ExecContext(ctx, `INSERT INTO rollups (rollup_key, start_at, end_at, latitude, longitude, municipality, poi, category, priority, title, summary, evidence_json, confidence, status, merge_suggestion, model_name, model_base_url, prompt_version, call_count, last_error, approximate, state, closed_at, curated) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, replaceRollupCalls(ctx, tx, id, rollup.CallIDs)
+}
+
+// uniqueIDs drops duplicates, non-positive ids and skip.
+func uniqueIDs(ids []int64, skip int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if id <= 0 || id == skip || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
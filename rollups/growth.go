@@ -0,0 +1,84 @@
+package rollups
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Growth describes a rollup that crossed a notification threshold during a
+// recompute.
+type Growth struct {
+	Rollup Rollup
+	Reason string
+}
+
+var priorityRank = map[string]int{"low": 1, "medium": 2, "high": 3}
+
+// maxCallsWithin returns the largest number of calls that fall inside any
+// window-long span.
+func maxCallsWithin(calls []CallRecord, window time.Duration) int {
+	times := make([]time.Time, 0, len(calls))
+	for _, c := range calls {
+		times = append(times, c.Timestamp)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	best, start := 0, 0
+	for end := range times {
+		for times[end].Sub(times[start]) > window {
+			start++
+		}
+		if n := end - start + 1; n > best {
+			best = n
+		}
+	}
+	return best
+}
+
+// growthReason decides whether a rollup warrants a growth update given what
+// was last announced. Call-count updates fire on the first threshold crossing
+// and again each time the count doubles; priority updates fire when the
+// priority reaches the configured level and exceeds the last announced one.
+func growthReason(rollup Rollup, calls []CallRecord, notifiedCount int, notifiedPriority string, threshold int, window time.Duration, minPriority string) string {
+	if minRank, ok := priorityRank[minPriority]; ok {
+		rank := priorityRank[rollup.Priority]
+		if rank >= minRank && rank > priorityRank[notifiedPriority] {
+			return fmt.Sprintf("escalated to %s priority", rollup.Priority)
+		}
+	}
+	if threshold > 0 && rollup.CallCount > notifiedCount && maxCallsWithin(calls, window) >= threshold {
+		if notifiedCount == 0 || rollup.CallCount >= notifiedCount*2 {
+			return fmt.Sprintf("grew to %d calls", rollup.CallCount)
+		}
+	}
+	return ""
+}
+
+// checkGrowth compares a freshly stored rollup against its last announced
+// size and priority, recording the new watermark when an update is due.
+func (s *Service) checkGrowth(ctx context.Context, rollup Rollup, calls []CallRecord) (Growth, bool, error) {
+	cfg := s.config()
+	if rollup.ID == 0 || rollup.State == StateClosed {
+		return Growth{}, false, nil
+	}
+	var notifiedCount int
+	var notifiedPriority sql.NullString
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(notified_call_count, 0), notified_priority FROM rollups WHERE id = ?`, rollup.ID).Scan(&notifiedCount, &notifiedPriority); err != nil {
+		return Growth{}, false, err
+	}
+	window := time.Duration(cfg.NotifyWindowMin) * time.Minute
+	reason := growthReason(rollup, calls, notifiedCount, notifiedPriority.String, cfg.NotifyCallThreshold, window, cfg.NotifyPriority)
+	if reason == "" {
+		return Growth{}, false, nil
+	}
+	priority := notifiedPriority.String
+	if priorityRank[rollup.Priority] > priorityRank[priority] {
+		priority = rollup.Priority
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE rollups SET notified_call_count = ?, notified_priority = ? WHERE id = ?`, rollup.CallCount, priority, rollup.ID); err != nil {
+		return Growth{}, false, err
+	}
+	return Growth{Rollup: rollup, Reason: reason}, true, nil
+}
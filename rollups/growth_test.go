@@ -0,0 +1,76 @@
+package rollups
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxCallsWithin(t *testing.T) {
+	cases := []struct {
+		name    string
+		minutes []float64
+		window  time.Duration
+		want    int
+	}{
+		{"no calls", nil, 10 * time.Minute, 0},
+		{"one call", []float64{0}, 10 * time.Minute, 1},
+		{"ends of the window count", []float64{0, 10, 20}, 10 * time.Minute, 2},
+		{"wider window", []float64{0, 10, 20}, 20 * time.Minute, 3},
+		{"unsorted burst", []float64{50, 0, 52, 51, 30}, 5 * time.Minute, 3},
+	}
+	for _, c := range cases {
+		var calls []CallRecord
+		for i, m := range c.minutes {
+			calls = append(calls, testCall(int64(i+1), m, 0))
+		}
+		if got := maxCallsWithin(calls, c.window); got != c.want {
+			t.Errorf("%s: %d calls, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestGrowthReason(t *testing.T) {
+	burst := func(n int) []CallRecord {
+		var calls []CallRecord
+		for i := 0; i < n; i++ {
+			calls = append(calls, testCall(int64(i+1), float64(i), 0))
+		}
+		return calls
+	}
+	spread := []CallRecord{testCall(1, 0, 0), testCall(2, 30, 0), testCall(3, 60, 0)}
+	cases := []struct {
+		name             string
+		count            int
+		priority         string
+		calls            []CallRecord
+		notifiedCount    int
+		notifiedPriority string
+		threshold        int
+		minPriority      string
+		want             string
+	}{
+		{name: "first crossing", count: 3, calls: burst(3), threshold: 3, minPriority: "off", want: "grew to 3 calls"},
+		{name: "below threshold", count: 2, calls: burst(2), threshold: 3, minPriority: "off"},
+		{name: "calls spread past the window", count: 3, calls: spread, threshold: 3, minPriority: "off"},
+		{name: "threshold off", count: 10, calls: burst(10), threshold: 0, minPriority: "off"},
+		{name: "no new calls", count: 3, calls: burst(3), notifiedCount: 3, threshold: 3, minPriority: "off"},
+		{name: "grown but not doubled", count: 5, calls: burst(5), notifiedCount: 3, threshold: 3, minPriority: "off"},
+		{name: "doubled", count: 6, calls: burst(6), notifiedCount: 3, threshold: 3, minPriority: "off", want: "grew to 6 calls"},
+		{name: "more than doubled", count: 7, calls: burst(7), notifiedCount: 3, threshold: 3, minPriority: "off", want: "grew to 7 calls"},
+		{name: "priority reaches the level", count: 1, priority: "high", calls: burst(1), minPriority: "high", want: "escalated to high priority"},
+		{name: "priority above the level", count: 1, priority: "high", calls: burst(1), minPriority: "medium", want: "escalated to high priority"},
+		{name: "priority below the level", count: 1, priority: "medium", calls: burst(1), minPriority: "high"},
+		{name: "priority above the last notice", count: 1, priority: "high", calls: burst(1), notifiedPriority: "medium", minPriority: "medium", want: "escalated to high priority"},
+		{name: "priority already announced", count: 1, priority: "high", calls: burst(1), notifiedPriority: "high", minPriority: "medium"},
+		{name: "priority dropped since the last notice", count: 1, priority: "medium", calls: burst(1), notifiedPriority: "high", minPriority: "medium"},
+		{name: "priority notices off", count: 1, priority: "high", calls: burst(1), minPriority: "off"},
+		{name: "announced priority falls back to count", count: 6, priority: "high", calls: burst(6), notifiedCount: 3, notifiedPriority: "high", threshold: 3, minPriority: "high", want: "grew to 6 calls"},
+	}
+	for _, c := range cases {
+		rollup := Rollup{CallCount: c.count, Priority: c.priority}
+		got := growthReason(rollup, c.calls, c.notifiedCount, c.notifiedPriority, c.threshold, 10*time.Minute, c.minPriority)
+		if got != c.want {
+			t.Errorf("%s: reason %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package rollups
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Lifecycle states. A rollup is active while related calls keep arriving,
+// monitoring once traffic has paused, and closed when the quiet period exceeds
+// the close window. Closed rollups are frozen: their calls are not regrouped.
+const (
+	StateActive     = "active"
+	StateMonitoring = "monitoring"
+	StateClosed     = "closed"
+)
+
+// Closure describes a rollup that closed during a recompute.
+type Closure struct {
+	Rollup  Rollup
+	Summary string
+	Closed  time.Time
+}
+
+func lifecycleState(lastCall, now time.Time, monitorAfter, closeAfter time.Duration) string {
+	quiet := now.Sub(lastCall)
+	switch {
+	case closeAfter > 0 && quiet >= closeAfter:
+		return StateClosed
+	case monitorAfter > 0 && quiet >= monitorAfter:
+		return StateMonitoring
+	default:
+		return StateActive
+	}
+}
+
+func (s *Service) stateFor(lastCall, now time.Time) string {
+	cfg := s.config()
+	return lifecycleState(lastCall, now, time.Duration(cfg.MonitorAfterMin)*time.Minute, time.Duration(cfg.CloseAfterMin)*time.Minute)
+}
+
+// advanceLifecycle moves open rollups through monitoring to closed and returns
+// the ones that closed, each with a closing summary.
+func (s *Service) advanceLifecycle(ctx context.Context, now time.Time) ([]Closure, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, end_at, COALESCE(state, ?) FROM rollups WHERE COALESCE(state, ?) != ?`, StateActive, StateActive, StateClosed)
+	if err != nil {
+		return nil, err
+	}
+	type openRollup struct {
+		id    int64
+		endAt time.Time
+		state string
+	}
+	var open []openRollup
+	for rows.Next() {
+		var r openRollup
+		if err := rows.Scan(&r.id, &r.endAt, &r.state); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		open = append(open, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var closures []Closure
+	for _, r := range open {
+		next := s.stateFor(r.endAt, now)
+		if next == r.state {
+			continue
+		}
+		if next != StateClosed {
+			if _, err := s.db.ExecContext(ctx, `UPDATE rollups SET state = ? WHERE id = ?`, next, r.id); err != nil {
+				return closures, err
+			}
+			continue
+		}
+		closure, err := s.closeRollup(ctx, r.id, now)
+		if err != nil {
+			log.Printf("rollup %d close failed: %v", r.id, err)
+			continue
+		}
+		closures = append(closures, closure)
+	}
+	return closures, nil
+}
+
+func (s *Service) closeRollup(ctx context.Context, id int64, now time.Time) (Closure, error) {
+	rollup, err := s.loadRollup(ctx, id)
+	if err != nil {
+		return Closure{}, err
+	}
+	calls, err := s.loadCallsByID(ctx, rollup.CallIDs)
+	if err != nil {
+		return Closure{}, err
+	}
+	summary := s.closingSummary(ctx, rollup, calls, now)
+	if _, err := s.db.ExecContext(ctx, `UPDATE rollups SET state = ?, closed_at = ?, closing_summary = ? WHERE id = ?`, StateClosed, now, summary, id); err != nil {
+		return Closure{}, err
+	}
+	return Closure{Rollup: rollup, Summary: summary, Closed: now}, nil
+}
+
+func (s *Service) loadRollup(ctx context.Context, id int64) (Rollup, error) {
+	var r Rollup
+	var municipality, poi, title, summary sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT id, rollup_key, start_at, end_at, latitude, longitude, municipality, poi, category, priority, title, summary, call_count FROM rollups WHERE id = ?`, id).
+		Scan(&r.ID, &r.Key, &r.StartAt, &r.EndAt, &r.Latitude, &r.Longitude, &municipality, &poi, &r.Category, &r.Priority, &title, &summary, &r.CallCount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return r, ErrNotFound
+		}
+		return r, err
+	}
+	r.Municipality = municipality.String
+	r.POI = poi.String
+	r.Title = title.String
+	r.Summary = summary.String
+	r.CallIDs, err = s.rollupCallIDs(ctx, id)
+	return r, err
+}
+
+// closingSummary asks the LLM for an "incident concluded" note and falls back
+// to a templated sentence when the LLM is disabled or fails.
+func (s *Service) closingSummary(ctx context.Context, rollup Rollup, calls []CallRecord, now time.Time) string {
+	cfg := s.config()
+	fallback := fallbackClosingSummary(rollup, now)
+	if !cfg.LLMEnabled || len(calls) == 0 {
+		return fallback
+	}
+	if cfg.MaxCalls > 0 && len(calls) > cfg.MaxCalls {
+		calls = calls[:cfg.MaxCalls]
+	}
+	apiKey := strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+	content, _, err := chatCompletion(ctx, s.client, cfg.LLMModel, cfg.LLMBaseURL, apiKey, buildClosingPrompt(cfg.PromptVersion), buildClosingUserPrompt(rollup, calls, now))
+	if err != nil {
+		log.Printf("rollup %d closing summary failed: %v", rollup.ID, err)
+		return fallback
+	}
+	summary, err := parseClosingOutput(content)
+	if err != nil {
+		log.Printf("rollup %d closing summary invalid: %v", rollup.ID, err)
+		return fallback
+	}
+	return summary
+}
+
+func buildClosingPrompt(version string) string {
+	return strings.TrimSpace(fmt.Sprintf(`You write the closing note for a concluded Sussex County NJ incident rollup.
+Return STRICT JSON ONLY with key: summary.
+Rules:
+- summary max 400 chars, past tense, starting with "Incident concluded"
+- state how long the incident ran and the final disposition if the calls mention one
+- no invented facts or locations; use ONLY the provided call data
+Prompt version: %s`, version))
+}
+
+func buildClosingUserPrompt(rollup Rollup, calls []CallRecord, now time.Time) string {
+	var b strings.Builder
+	b.WriteString(buildUserPrompt(rollup, calls))
+	if strings.TrimSpace(rollup.Summary) != "" {
+		b.WriteString("Last rolling summary: ")
+		b.WriteString(strings.TrimSpace(rollup.Summary))
+		b.WriteString("\n")
+	}
+	b.WriteString(fmt.Sprintf("Closed at: %s (no related calls since %s)\n", now.UTC().Format(time.RFC3339), rollup.EndAt.UTC().Format(time.RFC3339)))
+	return b.String()
+}
+
+func parseClosingOutput(content string) (string, error) {
+	obj := extractJSONObject(content)
+	if obj == "" {
+		return "", errors.New("no json object found")
+	}
+	var out struct {
+		Summary string `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(obj), &out); err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(out.Summary)
+	if summary == "" {
+		return "", errors.New("empty summary")
+	}
+	if len(summary) > 400 {
+		return "", errors.New("summary too long")
+	}
+	return summary, nil
+}
+
+func fallbackClosingSummary(rollup Rollup, now time.Time) string {
+	subject := strings.TrimSpace(rollup.Title)
+	if subject == "" {
+		subject = rollup.Category
+		if rollup.Municipality != "" {
+			subject += " in " + rollup.Municipality
+		}
+	}
+	duration := int(rollup.EndAt.Sub(rollup.StartAt).Minutes())
+	quiet := int(now.Sub(rollup.EndAt).Minutes())
+	return fmt.Sprintf("Incident concluded: %s. %d call(s) over %d min; no related traffic for %d min.", subject, rollup.CallCount, duration, quiet)
+}
@@ -10,6 +10,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"alert_framework/usage"
 )
 
 type LLMOutput struct {
@@ -94,6 +96,17 @@ func safeString(v string) string {
 }
 
 func callRollupLLM(ctx context.Context, client *http.Client, model, baseURL, apiKey, promptVersion string, rollup Rollup, calls []CallRecord) (LLMOutput, string, error) {
+	content, endpoint, err := chatCompletion(ctx, client, model, baseURL, apiKey, buildSystemPrompt(promptVersion), buildUserPrompt(rollup, calls))
+	if err != nil {
+		return LLMOutput{}, endpoint, err
+	}
+	parsed, err := parseLLMOutput(content)
+	return parsed, endpoint, err
+}
+
+// chatCompletion sends a JSON-mode chat request and returns the raw message
+// content along with the endpoint used.
+func chatCompletion(ctx context.Context, client *http.Client, model, baseURL, apiKey, system, user string) (string, string, error) {
 	if client == nil {
 		client = &http.Client{Timeout: 30 * time.Second}
 	}
@@ -105,15 +118,15 @@ func callRollupLLM(ctx context.Context, client *http.Client, model, baseURL, api
 			"type": "json_object",
 		},
 		"messages": []map[string]string{
-			{"role": "system", "content": buildSystemPrompt(promptVersion)},
-			{"role": "user", "content": buildUserPrompt(rollup, calls)},
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
 		},
 	}
 	buf, _ := json.Marshal(payload)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(usage.WithStage(ctx, usage.StageRollup), http.MethodPost, endpoint, bytes.NewReader(buf))
 	if err != nil {
-		return LLMOutput{}, endpoint, err
+		return "", endpoint, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	if strings.TrimSpace(apiKey) != "" {
@@ -122,12 +135,12 @@ func callRollupLLM(ctx context.Context, client *http.Client, model, baseURL, api
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return LLMOutput{}, endpoint, err
+		return "", endpoint, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return LLMOutput{}, endpoint, fmt.Errorf("llm status %d: %s", resp.StatusCode, string(body))
+		return "", endpoint, fmt.Errorf("llm status %d: %s", resp.StatusCode, string(body))
 	}
 	var wrapper struct {
 		Choices []struct {
@@ -137,14 +150,12 @@ func callRollupLLM(ctx context.Context, client *http.Client, model, baseURL, api
 		} `json:"choices"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&wrapper); err != nil {
-		return LLMOutput{}, endpoint, err
+		return "", endpoint, err
 	}
 	if len(wrapper.Choices) == 0 {
-		return LLMOutput{}, endpoint, errors.New("empty llm response")
+		return "", endpoint, errors.New("empty llm response")
 	}
-	content := strings.TrimSpace(wrapper.Choices[0].Message.Content)
-	parsed, err := parseLLMOutput(content)
-	return parsed, endpoint, err
+	return strings.TrimSpace(wrapper.Choices[0].Message.Content), endpoint, nil
 }
 
 func parseLLMOutput(content string) (LLMOutput, error) {
@@ -3,6 +3,7 @@ package rollups
 import (
 	"encoding/json"
 	"strings"
+	"time"
 )
 
 type addressPayload struct {
@@ -47,3 +48,28 @@ func extractStreet(addressJSON string) string {
 	}
 	return strings.TrimSpace(addr.Street)
 }
+
+// parseTimestamp converts a scanned timestamp. COALESCE expressions lose the
+// column's DATETIME type, so the driver hands back text instead of time.Time.
+func parseTimestamp(v interface{}) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC()
+	case []byte:
+		return parseTimestamp(string(t))
+	case string:
+		raw := strings.TrimSpace(t)
+		for _, layout := range []string{
+			"2006-01-02 15:04:05.999999999-07:00",
+			"2006-01-02 15:04:05.999999999 -0700 MST",
+			time.RFC3339Nano,
+			"2006-01-02 15:04:05",
+			"2006-01-02T15:04:05",
+		} {
+			if ts, err := time.Parse(layout, raw); err == nil {
+				return ts.UTC()
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"alert_framework/config"
@@ -19,55 +21,165 @@ const statusDone = "done"
 
 type Service struct {
 	db     *sql.DB
-	cfg    config.RollupConfig
 	client *http.Client
+
+	mu      sync.RWMutex
+	cfg     config.RollupConfig
+	exclude ExcludeFunc
+	// purgedAll is set once every stored rollup has been checked against
+	// exclude; later runs only check rollups within the lookback.
+	purgedAll bool
+
+	progressMu sync.Mutex
+	progress   Progress
 }
 
 func NewService(db *sql.DB, client *http.Client, cfg config.RollupConfig) *Service {
 	return &Service{db: db, cfg: cfg, client: client}
 }
 
+// SetConfig replaces the grouping, lifecycle and LLM settings on a config
+// reload. Each step of a recompute reads them afresh.
+func (s *Service) SetConfig(cfg config.RollupConfig) {
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+}
+
+func (s *Service) config() config.RollupConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// ExcludeFunc reports whether a call must be kept out of rollups, given its
+// call type and unredacted transcript. Rollups are public and feed
+// notifications, so restricted calls must not shape their title, summary or
+// location.
+type ExcludeFunc func(callType, transcript string) bool
+
+// SetExclude sets the calls kept out of rollups. Rollups already holding
+// such a call are deleted on the next recompute.
+func (s *Service) SetExclude(fn ExcludeFunc) {
+	s.mu.Lock()
+	s.exclude = fn
+	s.purgedAll = false
+	s.mu.Unlock()
+}
+
+func (s *Service) excluded(callType, transcript string) bool {
+	s.mu.RLock()
+	fn := s.exclude
+	s.mu.RUnlock()
+	return fn != nil && fn(callType, transcript)
+}
+
 func (s *Service) Recompute(ctx context.Context) (RunResult, error) {
-	runID, err := s.startRun(ctx)
+	cfg := s.config()
+	runID, err := s.startRun(ctx, cfg.Strategy)
 	if err != nil {
 		log.Printf("rollup run start failed: %v", err)
 	}
+	s.setProgress(func(p *Progress) { *p = Progress{Running: true, RunID: runID, StartedAt: time.Now().UTC()} })
+	defer s.setProgress(func(p *Progress) { p.Running = false })
 
+	if err := s.purgeExcluded(ctx); err != nil {
+		log.Printf("rollup purge of excluded calls failed: %v", err)
+	}
+	if err := s.rebuildDeleted(ctx); err != nil {
+		log.Printf("rollup rebuild after call deletes failed: %v", err)
+	}
 	calls, err := s.loadCalls(ctx)
 	if err != nil {
 		s.finishRun(ctx, runID, "failed", err.Error(), 0)
 		return RunResult{Status: "failed", Error: err.Error()}, err
 	}
 
-	clusters := groupCalls(calls, time.Duration(s.cfg.ChainWindowMin)*time.Minute, s.cfg.RadiusMeters, s.cfg.MaxCalls)
+	now := time.Now().UTC()
+	// Every strategy runs on the same calls so their metrics can be compared;
+	// only the configured one's clusters become rollups. Calls without
+	// coordinates are grouped separately by town and call type.
+	located, unlocated := splitLocated(calls)
+	var clusters [][]CallRecord
+	metrics := make(map[string]ClusterMetrics, len(Strategies))
+	for _, strategy := range Strategies {
+		grouped := clusterWith(strategy, located, cfg)
+		metrics[strategy] = evaluateClusters(grouped)
+		if strategy == cfg.Strategy {
+			clusters = grouped
+		}
+	}
+	if clusters == nil {
+		clusters = clusterWith(StrategyGreedy, located, cfg)
+	}
+	clusters = append(clusters, groupApproximate(unlocated, time.Duration(cfg.ChainWindowMin)*time.Minute, cfg.MaxCalls)...)
+	s.recordMetrics(ctx, runID, metrics)
+	s.setProgress(func(p *Progress) { p.Calls, p.Clusters = len(calls), len(clusters) })
 	count := 0
+	var grown []Growth
 	for _, clusterCalls := range clusters {
+		s.setProgress(func(p *Progress) { p.Done++ })
 		rollup, err := s.buildRollup(clusterCalls)
 		if err != nil {
 			log.Printf("rollup build failed: %v", err)
 			continue
 		}
-		if err := s.upsertRollup(ctx, rollup, clusterCalls); err != nil {
+		if err := s.upsertRollup(ctx, &rollup, clusterCalls, now); err != nil {
 			log.Printf("rollup upsert failed: %v", err)
 			continue
 		}
 		count++
+		if g, ok, err := s.checkGrowth(ctx, rollup, clusterCalls); err != nil {
+			log.Printf("rollup %d growth check failed: %v", rollup.ID, err)
+		} else if ok {
+			grown = append(grown, g)
+		}
+	}
+
+	closed, err := s.advanceLifecycle(ctx, now)
+	if err != nil {
+		log.Printf("rollup lifecycle update failed: %v", err)
 	}
 
 	s.finishRun(ctx, runID, "ok", "", count)
-	return RunResult{RollupCount: count, Status: "ok"}, nil
+	return RunResult{RollupCount: count, Status: "ok", Closed: closed, Grown: grown}, nil
 }
 
+// Progress is how far the recompute in progress, or the last one, got.
+type Progress struct {
+	Running   bool
+	RunID     int64
+	StartedAt time.Time
+	Calls     int
+	Clusters  int
+	Done      int
+}
+
+// Progress reports the state of the current or last recompute.
+func (s *Service) Progress() Progress {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+	return s.progress
+}
+
+func (s *Service) setProgress(update func(*Progress)) {
+	s.progressMu.Lock()
+	update(&s.progress)
+	s.progressMu.Unlock()
+}
+
+// callRecordColumns is the column list scanCallRecords expects. The last
+// column is the unredacted transcript, read only to apply exclude.
+const callRecordColumns = `id, filename, COALESCE(call_timestamp, created_at) as call_ts, COALESCE(call_type, ''), COALESCE(public_transcript, clean_transcript_text), COALESCE(public_transcript, transcript_text), COALESCE(public_transcript, normalized_transcript), COALESCE(latitude, 0), COALESCE(longitude, 0), location_label, address_json, refined_metadata, COALESCE(clean_transcript_text, raw_transcript_text, transcript_text, '')`
+
 func (s *Service) loadCalls(ctx context.Context) ([]CallRecord, error) {
-	cutoff := time.Now().UTC().Add(-time.Duration(s.cfg.LookbackHours) * time.Hour)
-	query := `SELECT id, filename, COALESCE(call_timestamp, created_at) as call_ts, call_type, clean_transcript_text, transcript_text, normalized_transcript, latitude, longitude, location_label, address_json, refined_metadata
+	cutoff := time.Now().UTC().Add(-time.Duration(s.config().LookbackHours) * time.Hour)
+	query := `SELECT ` + callRecordColumns + `
 FROM transcriptions
 WHERE status = ?
-  AND latitude IS NOT NULL
-  AND longitude IS NOT NULL
-  AND latitude != 0
-  AND longitude != 0
+  AND deleted_at IS NULL
   AND COALESCE(call_timestamp, created_at) >= ?
+  AND id NOT IN (SELECT rc.call_id FROM rollup_calls rc JOIN rollups r ON r.id = rc.rollup_id WHERE r.curated = 1 OR r.state = 'closed')
 ORDER BY call_ts ASC`
 
 	rows, err := s.db.QueryContext(ctx, query, statusDone, cutoff)
@@ -75,14 +187,158 @@ ORDER BY call_ts ASC`
 		return nil, err
 	}
 	defer rows.Close()
+	return s.scanCallRecords(rows)
+}
 
+// purgeExcluded deletes the rollups holding a call that exclude now rejects,
+// such as one reclassified after it was rolled up. The first run after
+// SetExclude checks every rollup, curated and closed ones included; later
+// runs check those within the lookback.
+func (s *Service) purgeExcluded(ctx context.Context) error {
+	s.mu.RLock()
+	fn, all := s.exclude, s.purgedAll
+	s.mu.RUnlock()
+	if fn == nil {
+		return nil
+	}
+	query := `SELECT rc.rollup_id, COALESCE(t.call_type, ''), COALESCE(t.clean_transcript_text, t.raw_transcript_text, t.transcript_text, '')
+FROM rollup_calls rc JOIN rollups r ON r.id = rc.rollup_id JOIN transcriptions t ON t.id = rc.call_id`
+	var args []interface{}
+	if all {
+		query += ` WHERE r.end_at >= ?`
+		args = append(args, time.Now().UTC().Add(-time.Duration(s.config().LookbackHours)*time.Hour))
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	doomed := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		var callType, transcript string
+		if err := rows.Scan(&id, &callType, &transcript); err != nil {
+			rows.Close()
+			return err
+		}
+		if !doomed[id] && fn(callType, transcript) {
+			doomed[id] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id := range doomed {
+		if err := deleteRollup(ctx, s.db, id); err != nil {
+			return err
+		}
+	}
+	if len(doomed) > 0 {
+		log.Printf("deleted %d rollups holding restricted calls", len(doomed))
+	}
+	s.mu.Lock()
+	s.purgedAll = true
+	s.mu.Unlock()
+	return nil
+}
+
+// rebuildDeleted rebuilds every rollup still holding a deleted call, closed
+// and curated ones included, so the call stops shaping its summary and
+// counts.
+func (s *Service) rebuildDeleted(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT rc.rollup_id FROM rollup_calls rc JOIN transcriptions t ON t.id = rc.call_id WHERE t.deleted_at IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.Rebuild(ctx, id); err != nil {
+			return fmt.Errorf("rollup %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Rebuild recomputes rollup id from those of its calls that are still
+// eligible, after one was deleted or purged. The rollup keeps its id,
+// curation and lifecycle state; one with no calls left is deleted.
+func (s *Service) Rebuild(ctx context.Context, id int64) error {
+	var curated int
+	var state string
+	var closed sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(curated, 0), COALESCE(state, 'active'), closed_at FROM rollups WHERE id = ?`, id).Scan(&curated, &state, &closed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	callIDs, err := s.rollupCallIDs(ctx, id)
+	if err != nil {
+		return err
+	}
+	var calls []CallRecord
+	if len(callIDs) > 0 {
+		if calls, err = s.loadCallsByID(ctx, callIDs); err != nil {
+			return err
+		}
+	}
+	if len(calls) == 0 {
+		return deleteRollup(ctx, s.db, id)
+	}
+	rollup, err := s.buildRollup(calls)
+	if err != nil {
+		return err
+	}
+	if curated == 1 {
+		rollup.Key = curatedKeyPrefix + rollup.Key
+	}
+	rollup.State = state
+	s.summarize(ctx, &rollup, calls)
+	// A closed rollup keeps the time it closed and gets a new closing note.
+	now := time.Now().UTC()
+	if closed.Valid {
+		now = closed.Time
+	}
+	if err := writeRollup(ctx, s.db, id, rollup, now); err != nil {
+		return err
+	}
+	if state != StateClosed {
+		return nil
+	}
+	rollup.ID = id
+	_, err = s.db.ExecContext(ctx, `UPDATE rollups SET closing_summary = ? WHERE id = ?`, s.closingSummary(ctx, rollup, calls, now), id)
+	return err
+}
+
+// scanCallRecords reads calls selected with callRecordColumns, skipping
+// excluded ones.
+func (s *Service) scanCallRecords(rows *sql.Rows) ([]CallRecord, error) {
 	var out []CallRecord
 	for rows.Next() {
 		var rec CallRecord
 		var clean, raw, normalized, locationLabel, addressJSON, refinedJSON sql.NullString
-		if err := rows.Scan(&rec.ID, &rec.Filename, &rec.Timestamp, &rec.CallType, &clean, &raw, &normalized, &rec.Latitude, &rec.Longitude, &locationLabel, &addressJSON, &refinedJSON); err != nil {
+		var ts interface{}
+		var private string
+		if err := rows.Scan(&rec.ID, &rec.Filename, &ts, &rec.CallType, &clean, &raw, &normalized, &rec.Latitude, &rec.Longitude, &locationLabel, &addressJSON, &refinedJSON, &private); err != nil {
 			return nil, err
 		}
+		if s.excluded(rec.CallType, private) {
+			continue
+		}
+		rec.Timestamp = parseTimestamp(ts)
 		rec.CleanTranscript = clean.String
 		rec.RawTranscript = raw.String
 		rec.Normalized = normalized.String
@@ -91,10 +347,11 @@ ORDER BY call_ts ASC`
 		rec.RefinedJSON = refinedJSON.String
 		out = append(out, rec)
 	}
-	return out, nil
+	return out, rows.Err()
 }
 
 func (s *Service) buildRollup(calls []CallRecord) (Rollup, error) {
+	cfg := s.config()
 	if len(calls) == 0 {
 		return Rollup{}, fmt.Errorf("empty rollup")
 	}
@@ -120,68 +377,117 @@ func (s *Service) buildRollup(calls []CallRecord) (Rollup, error) {
 		Evidence:      []string{},
 		CallIDs:       callIDs,
 		CallCount:     len(callIDs),
-		PromptVersion: s.cfg.PromptVersion,
-		ModelName:     s.cfg.LLMModel,
-		ModelBaseURL:  s.cfg.LLMBaseURL,
+		PromptVersion: cfg.PromptVersion,
+		ModelName:     cfg.LLMModel,
+		ModelBaseURL:  cfg.LLMBaseURL,
 		Status:        StatusLLMSkipped,
+		Approximate:   !anyLocated(calls),
 	}
 	return rollup, nil
 }
 
-func (s *Service) upsertRollup(ctx context.Context, rollup Rollup, calls []CallRecord) error {
+// upsertRollup stores a cluster. When the cluster overlaps open rollups from an
+// earlier run, the best match keeps its id (so lifecycle state and history
+// follow the incident) and the rest are superseded.
+func (s *Service) upsertRollup(ctx context.Context, rollup *Rollup, calls []CallRecord, now time.Time) error {
 	if rollup.Key == "" {
 		return fmt.Errorf("missing rollup key")
 	}
 
-	if s.cfg.LLMEnabled {
-		llmCalls := calls
-		if s.cfg.MaxCalls > 0 && len(llmCalls) > s.cfg.MaxCalls {
-			llmCalls = llmCalls[:s.cfg.MaxCalls]
+	s.summarize(ctx, rollup, calls)
+	rollup.State = s.stateFor(rollup.EndAt, now)
+
+	existing, err := s.overlappingRollups(ctx, rollup.CallIDs)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		if rollup.State == StateClosed {
+			// Leave the transition to advanceLifecycle so the closure is announced.
+			rollup.State = StateMonitoring
 		}
-		llmResult, baseURL, err := s.tryLLM(ctx, rollup, llmCalls)
-		if err != nil {
-			rollup.Status = StatusLLMFailed
-			rollup.LastError = truncateError(err.Error())
-		} else {
-			rollup.Title = llmResult.Title
-			rollup.Summary = llmResult.Summary
-			rollup.Evidence = llmResult.Evidence
-			rollup.MergeSuggestion = llmResult.MergeSuggestion
-			rollup.Confidence = llmResult.Confidence
-			rollup.ModelBaseURL = baseURL
-			rollup.Status = StatusLLMOK
+		for _, id := range existing[1:] {
+			if err := deleteRollup(ctx, s.db, id); err != nil {
+				return err
+			}
 		}
-	} else {
-		rollup.Status = StatusLLMSkipped
+		rollup.ID = existing[0]
+		return writeRollup(ctx, s.db, rollup.ID, *rollup, now)
 	}
 
-	evidenceJSON, _ := json.Marshal(rollup.Evidence)
 	query := `INSERT INTO rollups (
-rollup_key, start_at, end_at, latitude, longitude, municipality, poi, category, priority, title, summary, evidence_json, confidence, status, merge_suggestion, model_name, model_base_url, prompt_version, call_count, last_error
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-ON CONFLICT(rollup_key) DO UPDATE SET
-start_at=excluded.start_at,
-end_at=excluded.end_at,
-latitude=excluded.latitude,
-longitude=excluded.longitude,
-municipality=excluded.municipality,
-poi=excluded.poi,
-category=excluded.category,
-priority=excluded.priority,
-title=excluded.title,
-summary=excluded.summary,
-evidence_json=excluded.evidence_json,
-confidence=excluded.confidence,
-status=excluded.status,
-merge_suggestion=excluded.merge_suggestion,
-model_name=excluded.model_name,
-model_base_url=excluded.model_base_url,
-prompt_version=excluded.prompt_version,
-call_count=excluded.call_count,
-last_error=excluded.last_error,
-updated_at=CURRENT_TIMESTAMP`
-
-	_, err := s.db.ExecContext(ctx, query,
+rollup_key, start_at, end_at, latitude, longitude, municipality, poi, category, priority, title, summary, evidence_json, confidence, status, merge_suggestion, model_name, model_base_url, prompt_version, call_count, last_error, approximate, state, closed_at
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(rollup_key) DO NOTHING`
+	args := append(rollupFieldArgs(*rollup), rollup.State, closedAt(rollup.State, now))
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	rollupID, curated, err := s.lookupRollupID(ctx, rollup.Key)
+	if err != nil {
+		return err
+	}
+	if curated {
+		return nil
+	}
+	rollup.ID = rollupID
+	return replaceRollupCalls(ctx, s.db, rollupID, rollup.CallIDs)
+}
+
+// overlappingRollups returns open, non-curated rollups sharing calls with
+// callIDs, ordered by the number of shared calls.
+func (s *Service) overlappingRollups(ctx context.Context, callIDs []int64) ([]int64, error) {
+	if len(callIDs) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, 0, len(callIDs)+1)
+	for _, id := range callIDs {
+		args = append(args, id)
+	}
+	args = append(args, StateClosed)
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(callIDs)), ",")
+	rows, err := s.db.QueryContext(ctx, `SELECT r.id FROM rollup_calls rc JOIN rollups r ON r.id = rc.rollup_id
+WHERE rc.call_id IN (`+placeholders+`) AND r.curated = 0 AND COALESCE(r.state, 'active') != ?
+GROUP BY r.id ORDER BY COUNT(*) DESC, r.id ASC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// writeRollup overwrites rollup id with freshly built fields and calls.
+func writeRollup(ctx context.Context, db execer, id int64, rollup Rollup, now time.Time) error {
+	args := append(rollupFieldArgs(rollup), rollup.State, closedAt(rollup.State, now), id)
+	_, err := db.ExecContext(ctx, `UPDATE rollups SET rollup_key=?, start_at=?, end_at=?, latitude=?, longitude=?, municipality=?, poi=?, category=?, priority=?, title=?, summary=?, evidence_json=?, confidence=?, status=?, merge_suggestion=?, model_name=?, model_base_url=?, prompt_version=?, call_count=?, last_error=?, approximate=?, state=?, closed_at=? WHERE id=?`, args...)
+	if err != nil {
+		return err
+	}
+	return replaceRollupCalls(ctx, db, id, rollup.CallIDs)
+}
+
+func deleteRollup(ctx context.Context, db execer, id int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM rollup_calls WHERE rollup_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `DELETE FROM rollups WHERE id = ?`, id)
+	return err
+}
+
+// rollupFieldArgs returns the values for the shared rollup columns, from
+// rollup_key through approximate.
+func rollupFieldArgs(rollup Rollup) []interface{} {
+	evidenceJSON, _ := json.Marshal(rollup.Evidence)
+	return []interface{}{
 		rollup.Key,
 		rollup.StartAt,
 		rollup.EndAt,
@@ -202,50 +508,85 @@ updated_at=CURRENT_TIMESTAMP`
 		nullableString(rollup.PromptVersion),
 		rollup.CallCount,
 		nullableString(rollup.LastError),
-	)
-	if err != nil {
-		return err
+		boolInt(rollup.Approximate),
 	}
+}
 
-	rollupID, err := s.lookupRollupID(ctx, rollup.Key)
-	if err != nil {
-		return err
+func boolInt(v bool) int {
+	if v {
+		return 1
 	}
-	if err := s.replaceRollupCalls(ctx, rollupID, rollup.CallIDs); err != nil {
-		return err
+	return 0
+}
+
+func closedAt(state string, now time.Time) interface{} {
+	if state == StateClosed {
+		return now
 	}
 	return nil
 }
 
+// summarize fills the LLM title/summary fields when enabled.
+func (s *Service) summarize(ctx context.Context, rollup *Rollup, calls []CallRecord) {
+	cfg := s.config()
+	if !cfg.LLMEnabled {
+		rollup.Status = StatusLLMSkipped
+		return
+	}
+	llmCalls := calls
+	if cfg.MaxCalls > 0 && len(llmCalls) > cfg.MaxCalls {
+		llmCalls = llmCalls[:cfg.MaxCalls]
+	}
+	llmResult, baseURL, err := s.tryLLM(ctx, *rollup, llmCalls)
+	if err != nil {
+		rollup.Status = StatusLLMFailed
+		rollup.LastError = truncateError(err.Error())
+		return
+	}
+	rollup.Title = llmResult.Title
+	rollup.Summary = llmResult.Summary
+	rollup.Evidence = llmResult.Evidence
+	rollup.MergeSuggestion = llmResult.MergeSuggestion
+	rollup.Confidence = llmResult.Confidence
+	rollup.ModelBaseURL = baseURL
+	rollup.Status = StatusLLMOK
+}
+
 func (s *Service) tryLLM(ctx context.Context, rollup Rollup, calls []CallRecord) (LLMOutput, string, error) {
+	cfg := s.config()
 	apiKey := strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
-	return callRollupLLM(ctx, s.client, s.cfg.LLMModel, s.cfg.LLMBaseURL, apiKey, s.cfg.PromptVersion, rollup, calls)
+	return callRollupLLM(ctx, s.client, cfg.LLMModel, cfg.LLMBaseURL, apiKey, cfg.PromptVersion, rollup, calls)
 }
 
-func (s *Service) lookupRollupID(ctx context.Context, key string) (int64, error) {
+func (s *Service) lookupRollupID(ctx context.Context, key string) (int64, bool, error) {
 	var id int64
-	row := s.db.QueryRowContext(ctx, `SELECT id FROM rollups WHERE rollup_key = ?`, key)
-	if err := row.Scan(&id); err != nil {
-		return 0, err
+	var curated sql.NullInt64
+	row := s.db.QueryRowContext(ctx, `SELECT id, curated FROM rollups WHERE rollup_key = ?`, key)
+	if err := row.Scan(&id, &curated); err != nil {
+		return 0, false, err
 	}
-	return id, nil
+	return id, curated.Int64 == 1, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
-func (s *Service) replaceRollupCalls(ctx context.Context, rollupID int64, callIDs []int64) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM rollup_calls WHERE rollup_id = ?`, rollupID)
+func replaceRollupCalls(ctx context.Context, db execer, rollupID int64, callIDs []int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM rollup_calls WHERE rollup_id = ?`, rollupID)
 	if err != nil {
 		return err
 	}
 	for _, id := range callIDs {
-		if _, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO rollup_calls (rollup_id, call_id) VALUES (?, ?)`, rollupID, id); err != nil {
+		if _, err := db.ExecContext(ctx, `INSERT OR IGNORE INTO rollup_calls (rollup_id, call_id) VALUES (?, ?)`, rollupID, id); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (s *Service) startRun(ctx context.Context) (int64, error) {
-	res, err := s.db.ExecContext(ctx, `INSERT INTO rollup_runs (status) VALUES (?)`, "running")
+func (s *Service) startRun(ctx context.Context, strategy string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO rollup_runs (status, strategy) VALUES (?, ?)`, "running", strategy)
 	if err != nil {
 		return 0, err
 	}
@@ -262,6 +603,20 @@ func (s *Service) finishRun(ctx context.Context, runID int64, status, errMsg str
 	}
 }
 
+// recordMetrics stores each strategy's ClusterMetrics with the run.
+func (s *Service) recordMetrics(ctx context.Context, runID int64, metrics map[string]ClusterMetrics) {
+	if runID == 0 {
+		return
+	}
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE rollup_runs SET metrics_json=? WHERE id=?`, string(data), runID); err != nil {
+		log.Printf("rollup run metrics update failed: %v", err)
+	}
+}
+
 func truncateError(msg string) string {
 	msg = strings.TrimSpace(msg)
 	if len(msg) > 240 {
@@ -0,0 +1,337 @@
+package rollups
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"alert_framework/config"
+)
+
+// Clustering strategies selectable with RollupConfig.Strategy.
+const (
+	StrategyGreedy  = "greedy"
+	StrategyDBSCAN  = "dbscan"
+	StrategyAddress = "address"
+)
+
+// Strategies lists every clustering strategy. Each recompute evaluates all
+// of them so their metrics can be compared on the same calls.
+var Strategies = []string{StrategyGreedy, StrategyDBSCAN, StrategyAddress}
+
+// ClusterMetrics describes how one strategy grouped a recompute's calls.
+// Radius, span and purity are taken over clusters of two or more calls:
+// a large radius or span, or a low purity, points at over-merging, and many
+// singletons at under-merging.
+type ClusterMetrics struct {
+	Clusters           int     `json:"clusters"`
+	Singletons         int     `json:"singletons"`
+	AvgSize            float64 `json:"avg_size"`
+	MaxSize            int     `json:"max_size"`
+	AvgRadiusMeters    float64 `json:"avg_radius_m"`
+	MaxRadiusMeters    float64 `json:"max_radius_m"`
+	MaxSpanMinutes     float64 `json:"max_span_min"`
+	MunicipalityPurity float64 `json:"municipality_purity"`
+	CallTypePurity     float64 `json:"call_type_purity"`
+}
+
+// clusterWith groups calls with the named strategy; unknown names use
+// greedy.
+func clusterWith(strategy string, calls []CallRecord, cfg config.RollupConfig) [][]CallRecord {
+	window := time.Duration(cfg.ChainWindowMin) * time.Minute
+	switch strategy {
+	case StrategyDBSCAN:
+		return dbscanCalls(calls, window, cfg.RadiusMeters, cfg.DBSCANMinPoints, cfg.MaxCalls)
+	case StrategyAddress:
+		return groupByAddress(calls, window, cfg.MaxCalls)
+	default:
+		return groupCalls(calls, window, cfg.RadiusMeters, cfg.MaxCalls)
+	}
+}
+
+func hasLocation(call CallRecord) bool {
+	return call.Latitude != 0 || call.Longitude != 0
+}
+
+// dbscanCalls runs DBSCAN where two calls are neighbours when they are
+// within radiusMeters of each other and within window in time. Unlike
+// greedy chaining it never joins calls by town alone, and a cluster only
+// grows through calls that have minPoints neighbours of their own, so a
+// string of single calls along a road stays apart. Noise calls become
+// rollups of their own.
+func dbscanCalls(calls []CallRecord, window time.Duration, radiusMeters float64, minPoints, maxCalls int) [][]CallRecord {
+	if len(calls) == 0 {
+		return nil
+	}
+	if minPoints < 1 {
+		minPoints = 1
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Timestamp.Before(calls[j].Timestamp)
+	})
+	neighbours := func(i int) []int {
+		var out []int
+		if !hasLocation(calls[i]) {
+			return out
+		}
+		for _, step := range []int{-1, 1} {
+			for j := i + step; j >= 0 && j < len(calls); j += step {
+				gap := calls[j].Timestamp.Sub(calls[i].Timestamp)
+				if gap < 0 {
+					gap = -gap
+				}
+				if gap > window {
+					break
+				}
+				if hasLocation(calls[j]) && haversineMeters(calls[i].Latitude, calls[i].Longitude, calls[j].Latitude, calls[j].Longitude) <= radiusMeters {
+					out = append(out, j)
+				}
+			}
+		}
+		return out
+	}
+
+	const noise = -1
+	labels := make([]int, len(calls))
+	next := 0
+	for i := range calls {
+		if labels[i] != 0 {
+			continue
+		}
+		reach := neighbours(i)
+		if len(reach)+1 < minPoints || len(reach) == 0 {
+			labels[i] = noise
+			continue
+		}
+		next++
+		labels[i] = next
+		for k := 0; k < len(reach); k++ {
+			j := reach[k]
+			if labels[j] == noise {
+				labels[j] = next
+			}
+			if labels[j] != 0 {
+				continue
+			}
+			labels[j] = next
+			if more := neighbours(j); len(more)+1 >= minPoints {
+				reach = append(reach, more...)
+			}
+		}
+	}
+
+	groups := make(map[int][]CallRecord, next)
+	var out [][]CallRecord
+	for i, call := range calls {
+		if labels[i] == noise {
+			out = append(out, []CallRecord{call})
+			continue
+		}
+		groups[labels[i]] = append(groups[labels[i]], call)
+	}
+	for id := 1; id <= next; id++ {
+		out = append(out, splitByMax(groups[id], maxCalls)...)
+	}
+	return out
+}
+
+// groupByAddress chains calls at the same street and town that arrive
+// within window of the previous one. Calls without a street stand alone.
+func groupByAddress(calls []CallRecord, window time.Duration, maxCalls int) [][]CallRecord {
+	return chainByKey(calls, window, maxCalls, func(call CallRecord) string {
+		street := normalizePOI(call)
+		if street == "" {
+			return ""
+		}
+		return street + "|" + normalizeMunicipality(call)
+	})
+}
+
+// groupApproximate groups calls without coordinates by town and call type,
+// chained within window. Only groups of two or more calls are returned: a
+// lone call without a position says nothing a rollup would add.
+func groupApproximate(calls []CallRecord, window time.Duration, maxCalls int) [][]CallRecord {
+	grouped := chainByKey(calls, window, maxCalls, func(call CallRecord) string {
+		town := normalizeMunicipality(call)
+		if town == "" {
+			return ""
+		}
+		return town + "|" + strings.ToLower(strings.TrimSpace(call.CallType))
+	})
+	out := grouped[:0]
+	for _, g := range grouped {
+		if len(g) > 1 {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// chainByKey chains calls with the same key that arrive within window of the
+// previous one. Calls with an empty key stand alone.
+func chainByKey(calls []CallRecord, window time.Duration, maxCalls int, key func(CallRecord) string) [][]CallRecord {
+	if len(calls) == 0 {
+		return nil
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].Timestamp.Before(calls[j].Timestamp)
+	})
+	var out [][]CallRecord
+	open := make(map[string]int)
+	for _, call := range calls {
+		k := key(call)
+		if k == "" {
+			out = append(out, []CallRecord{call})
+			continue
+		}
+		if idx, ok := open[k]; ok {
+			group := out[idx]
+			if call.Timestamp.Sub(group[len(group)-1].Timestamp) <= window && (maxCalls <= 0 || len(group) < maxCalls) {
+				out[idx] = append(group, call)
+				continue
+			}
+		}
+		open[k] = len(out)
+		out = append(out, []CallRecord{call})
+	}
+	return out
+}
+
+// splitLocated separates the calls with coordinates from those without.
+func splitLocated(calls []CallRecord) (located, unlocated []CallRecord) {
+	for _, c := range calls {
+		if hasLocation(c) {
+			located = append(located, c)
+		} else {
+			unlocated = append(unlocated, c)
+		}
+	}
+	return located, unlocated
+}
+
+func anyLocated(calls []CallRecord) bool {
+	for _, c := range calls {
+		if hasLocation(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitByMax cuts a time-ordered cluster into pieces of at most maxCalls.
+func splitByMax(calls []CallRecord, maxCalls int) [][]CallRecord {
+	if maxCalls <= 0 || len(calls) <= maxCalls {
+		return [][]CallRecord{calls}
+	}
+	var out [][]CallRecord
+	for len(calls) > maxCalls {
+		out = append(out, calls[:maxCalls:maxCalls])
+		calls = calls[maxCalls:]
+	}
+	return append(out, calls)
+}
+
+// evaluateClusters computes ClusterMetrics for one strategy's clusters.
+func evaluateClusters(clusters [][]CallRecord) ClusterMetrics {
+	m := ClusterMetrics{Clusters: len(clusters)}
+	if len(clusters) == 0 {
+		return m
+	}
+	var calls, multi, radiusClusters int
+	var radiusSum float64
+	var townMatched, townTotal, typeMatched, typeTotal int
+	for _, c := range clusters {
+		calls += len(c)
+		if len(c) > m.MaxSize {
+			m.MaxSize = len(c)
+		}
+		if len(c) < 2 {
+			m.Singletons++
+			continue
+		}
+		multi++
+		if r, ok := clusterRadius(c); ok {
+			radiusClusters++
+			radiusSum += r
+			m.MaxRadiusMeters = math.Max(m.MaxRadiusMeters, r)
+		}
+		first, last := c[0].Timestamp, c[0].Timestamp
+		for _, call := range c {
+			if call.Timestamp.Before(first) {
+				first = call.Timestamp
+			}
+			if call.Timestamp.After(last) {
+				last = call.Timestamp
+			}
+		}
+		m.MaxSpanMinutes = math.Max(m.MaxSpanMinutes, last.Sub(first).Minutes())
+		matched, total := purity(c, normalizeMunicipality)
+		townMatched += matched
+		townTotal += total
+		matched, total = purity(c, func(call CallRecord) string { return call.CallType })
+		typeMatched += matched
+		typeTotal += total
+	}
+	m.AvgSize = round(float64(calls)/float64(len(clusters)), 2)
+	if radiusClusters > 0 {
+		m.AvgRadiusMeters = round(radiusSum/float64(radiusClusters), 0)
+	}
+	m.MaxRadiusMeters = round(m.MaxRadiusMeters, 0)
+	m.MaxSpanMinutes = round(m.MaxSpanMinutes, 1)
+	if townTotal > 0 {
+		m.MunicipalityPurity = round(float64(townMatched)/float64(townTotal), 3)
+	}
+	if typeTotal > 0 {
+		m.CallTypePurity = round(float64(typeMatched)/float64(typeTotal), 3)
+	}
+	return m
+}
+
+// clusterRadius is the largest distance from the centroid of the calls that
+// have coordinates, if at least two do.
+func clusterRadius(calls []CallRecord) (float64, bool) {
+	var lat, lon float64
+	var located []CallRecord
+	for _, c := range calls {
+		if hasLocation(c) {
+			located = append(located, c)
+			lat += c.Latitude
+			lon += c.Longitude
+		}
+	}
+	if len(located) < 2 {
+		return 0, false
+	}
+	lat /= float64(len(located))
+	lon /= float64(len(located))
+	var radius float64
+	for _, c := range located {
+		radius = math.Max(radius, haversineMeters(lat, lon, c.Latitude, c.Longitude))
+	}
+	return radius, true
+}
+
+// purity counts the calls whose key matches the cluster's most common key,
+// out of the calls that have one.
+func purity(calls []CallRecord, key func(CallRecord) string) (int, int) {
+	top := majorityString(calls, key)
+	if top == "" {
+		return 0, 0
+	}
+	matched, total := 0, 0
+	for _, c := range calls {
+		if k := key(c); k != "" {
+			total++
+			if k == top {
+				matched++
+			}
+		}
+	}
+	return matched, total
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,248 @@
+package rollups
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+
+const (
+	testLat = 41.05
+	testLng = -74.75
+)
+
+// testCall is call id at minute past testStart, north meters due north of
+// the test point. A negative north leaves it without coordinates.
+func testCall(id int64, minute, north float64) CallRecord {
+	c := CallRecord{ID: id, Timestamp: testStart.Add(time.Duration(minute * float64(time.Minute)))}
+	if north >= 0 {
+		c.Latitude = testLat + north/(earthRadiusMeters*math.Pi/180)
+		c.Longitude = testLng
+	}
+	return c
+}
+
+// atAddress places c on street in town.
+func atAddress(c CallRecord, street, town string) CallRecord {
+	c.AddressJSON = fmt.Sprintf(`{"street":%q,"city":%q}`, street, town)
+	return c
+}
+
+// clusterIDs lists the call ids of each cluster.
+func clusterIDs(clusters [][]CallRecord) [][]int64 {
+	out := make([][]int64, len(clusters))
+	for i, c := range clusters {
+		for _, call := range c {
+			out[i] = append(out[i], call.ID)
+		}
+	}
+	return out
+}
+
+func TestDBSCANCalls(t *testing.T) {
+	cases := []struct {
+		name      string
+		calls     []CallRecord
+		window    time.Duration
+		minPoints int
+		maxCalls  int
+		want      [][]int64
+	}{
+		{
+			// 1, 2 and 3 each have two neighbours, so all are core points.
+			// 4 sees only 3 and joins as a border point after first being
+			// marked noise; 5 is too far from anything.
+			name:      "core, border and noise",
+			calls:     []CallRecord{testCall(4, 0, 180), testCall(1, 1, 0), testCall(2, 2, 50), testCall(3, 3, 90), testCall(5, 4, 5000)},
+			window:    30 * time.Minute,
+			minPoints: 3,
+			want:      [][]int64{{5}, {4, 1, 2, 3}},
+		},
+		{
+			// 5 is in reach of core point 4 but has only 4 and 6 as
+			// neighbours, so 6 is not pulled in through it.
+			name:      "border point does not extend the cluster",
+			calls:     []CallRecord{testCall(1, 0, 0), testCall(2, 1, 30), testCall(3, 2, 60), testCall(4, 3, 90), testCall(5, 4, 180), testCall(6, 5, 270)},
+			window:    30 * time.Minute,
+			minPoints: 4,
+			want:      [][]int64{{6}, {1, 2, 3, 4, 5}},
+		},
+		{
+			name:      "pair meets minPoints 2",
+			calls:     []CallRecord{testCall(1, 0, 0), testCall(2, 1, 50)},
+			window:    30 * time.Minute,
+			minPoints: 2,
+			want:      [][]int64{{1, 2}},
+		},
+		{
+			name:      "pair is noise under minPoints 3",
+			calls:     []CallRecord{testCall(1, 0, 0), testCall(2, 1, 50)},
+			window:    30 * time.Minute,
+			minPoints: 3,
+			want:      [][]int64{{1}, {2}},
+		},
+		{
+			name:      "minPoints below 1 acts as 1",
+			calls:     []CallRecord{testCall(1, 0, 0), testCall(2, 1, 50), testCall(3, 2, 5000)},
+			window:    30 * time.Minute,
+			minPoints: 0,
+			want:      [][]int64{{3}, {1, 2}},
+		},
+		{
+			name:      "calls without coordinates are noise",
+			calls:     []CallRecord{testCall(1, 0, -1), testCall(2, 1, -1)},
+			window:    30 * time.Minute,
+			minPoints: 1,
+			want:      [][]int64{{1}, {2}},
+		},
+		{
+			name:      "gap equal to the window joins",
+			calls:     []CallRecord{testCall(1, 0, 0), testCall(2, 30, 0)},
+			window:    30 * time.Minute,
+			minPoints: 2,
+			want:      [][]int64{{1, 2}},
+		},
+		{
+			name:      "gap past the window splits",
+			calls:     []CallRecord{testCall(1, 0, 0), testCall(2, 31, 0)},
+			window:    30 * time.Minute,
+			minPoints: 2,
+			want:      [][]int64{{1}, {2}},
+		},
+		{
+			name:      "maxCalls splits in time order",
+			calls:     []CallRecord{testCall(5, 4, 0), testCall(1, 0, 0), testCall(3, 2, 0), testCall(2, 1, 0), testCall(4, 3, 0)},
+			window:    30 * time.Minute,
+			minPoints: 2,
+			maxCalls:  2,
+			want:      [][]int64{{1, 2}, {3, 4}, {5}},
+		},
+	}
+	for _, c := range cases {
+		got := clusterIDs(dbscanCalls(c.calls, c.window, 100, c.minPoints, c.maxCalls))
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: clusters %v, want %v", c.name, got, c.want)
+		}
+	}
+	if got := dbscanCalls(nil, time.Hour, 100, 2, 0); got != nil {
+		t.Errorf("no calls: clusters %v, want none", got)
+	}
+}
+
+func TestGroupByAddress(t *testing.T) {
+	onMain := func(id int64, minute float64) CallRecord {
+		return atAddress(testCall(id, minute, 0), "Main St", "Sparta")
+	}
+	cases := []struct {
+		name     string
+		calls    []CallRecord
+		maxCalls int
+		want     [][]int64
+	}{
+		{
+			// Each call is within the window of the previous one, so the
+			// chain runs past the window overall.
+			name:  "chains within the window",
+			calls: []CallRecord{onMain(1, 0), onMain(2, 20), onMain(3, 40)},
+			want:  [][]int64{{1, 2, 3}},
+		},
+		{
+			name:  "gap past the window starts a new group",
+			calls: []CallRecord{onMain(1, 0), onMain(2, 31)},
+			want:  [][]int64{{1}, {2}},
+		},
+		{
+			name: "street and town must both match",
+			calls: []CallRecord{
+				onMain(1, 0),
+				atAddress(testCall(2, 1, 0), "Main St", "Newton"),
+				atAddress(testCall(3, 2, 0), "Elm St", "Sparta"),
+				atAddress(testCall(4, 3, 0), "MAIN ST", "sparta"),
+			},
+			want: [][]int64{{1, 4}, {2}, {3}},
+		},
+		{
+			name:  "calls without a street stand alone",
+			calls: []CallRecord{testCall(1, 0, 0), testCall(2, 1, 0)},
+			want:  [][]int64{{1}, {2}},
+		},
+		{
+			name:     "maxCalls starts a new group",
+			calls:    []CallRecord{onMain(1, 0), onMain(2, 1), onMain(3, 2)},
+			maxCalls: 2,
+			want:     [][]int64{{1, 2}, {3}},
+		},
+	}
+	for _, c := range cases {
+		got := clusterIDs(groupByAddress(c.calls, 30*time.Minute, c.maxCalls))
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: clusters %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSplitByMax(t *testing.T) {
+	calls := []CallRecord{testCall(1, 0, 0), testCall(2, 1, 0), testCall(3, 2, 0), testCall(4, 3, 0), testCall(5, 4, 0)}
+	cases := []struct {
+		n, maxCalls int
+		want        [][]int64
+	}{
+		{5, 0, [][]int64{{1, 2, 3, 4, 5}}},
+		{4, 4, [][]int64{{1, 2, 3, 4}}},
+		{4, 2, [][]int64{{1, 2}, {3, 4}}},
+		{5, 2, [][]int64{{1, 2}, {3, 4}, {5}}},
+	}
+	for _, c := range cases {
+		if got := clusterIDs(splitByMax(calls[:c.n], c.maxCalls)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitByMax(%d calls, %d) = %v, want %v", c.n, c.maxCalls, got, c.want)
+		}
+	}
+	// Growing one piece must not write over the next.
+	pieces := splitByMax(append([]CallRecord(nil), calls...), 2)
+	pieces[0] = append(pieces[0], testCall(9, 9, 0))
+	if pieces[1][0].ID != 3 {
+		t.Fatalf("appending to the first piece overwrote the second: %v", clusterIDs(pieces))
+	}
+}
+
+func TestEvaluateClusters(t *testing.T) {
+	in := func(c CallRecord, town, callType string) CallRecord {
+		c = atAddress(c, "Main St", town)
+		c.CallType = callType
+		return c
+	}
+	clusters := [][]CallRecord{
+		// Centroid 50 m north: radius 50 m, span 10 min, both pure.
+		{in(testCall(1, 0, 0), "Sparta", "fire"), in(testCall(2, 10, 100), "Sparta", "fire")},
+		// Centroid 100 m north: radius 200 m, span 25 min, 2 of 3 pure.
+		{in(testCall(3, 0, 0), "Sparta", "fire"), in(testCall(4, 5, 0), "Sparta", "ems"), in(testCall(5, 25, 300), "Newton", "ems")},
+		{in(testCall(6, 0, 5000), "Newton", "fire")},
+	}
+	want := ClusterMetrics{
+		Clusters:           3,
+		Singletons:         1,
+		AvgSize:            2,
+		MaxSize:            3,
+		AvgRadiusMeters:    125,
+		MaxRadiusMeters:    200,
+		MaxSpanMinutes:     25,
+		MunicipalityPurity: 0.8,
+		CallTypePurity:     0.8,
+	}
+	if got := evaluateClusters(clusters); got != want {
+		t.Fatalf("metrics = %+v\nwant      %+v", got, want)
+	}
+
+	// Unlocated calls leave the radius out but still count toward size.
+	unlocated := evaluateClusters([][]CallRecord{{testCall(1, 0, -1), testCall(2, 1, 0)}})
+	if unlocated.AvgRadiusMeters != 0 || unlocated.MaxSize != 2 || unlocated.Singletons != 0 {
+		t.Fatalf("unlocated metrics = %+v", unlocated)
+	}
+	if got := evaluateClusters(nil); got != (ClusterMetrics{}) {
+		t.Fatalf("no clusters: %+v", got)
+	}
+}
@@ -46,11 +46,18 @@ type Rollup struct {
 	CallIDs         []int64
 	CallCount       int
 	LastError       string
+	State           string
 	UpdatedAt       time.Time
+
+	// Approximate is set when no call in the rollup has coordinates, so it
+	// was grouped by town and call type and has no position.
+	Approximate bool
 }
 
 type RunResult struct {
 	RollupCount int
 	Status      string
 	Error       string
+	Closed      []Closure
+	Grown       []Growth
 }
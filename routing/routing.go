@@ -0,0 +1,246 @@
+// Package routing evaluates alert routing rules against a completed call.
+// A rule pairs conditions (town, category, priority, keywords, tags, time of day)
+// with one action: post to a GroupMe bot, a webhook, an MQTT topic or an
+// email address, or suppress the call entirely.
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"alert_framework/rollups"
+)
+
+// Action is what a matching rule does with a call.
+type Action string
+
+const (
+	GroupMe  Action = "groupme"
+	Webhook  Action = "webhook"
+	MQTT     Action = "mqtt"
+	Email    Action = "email"
+	Suppress Action = "suppress"
+)
+
+var days = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Conditions must all hold for a rule to match. Empty conditions match
+// everything. Categories match the call category (fire, ems, other) or the
+// exact call type. Keywords match whole words or phrases in the transcript,
+// and Tags any of the call's tags.
+// From and Until ("15:04", local time) bound the time of day and may wrap
+// past midnight; Days limits the weekdays ("mon".."sun").
+type Conditions struct {
+	Towns       []string `json:"towns,omitempty"`
+	Categories  []string `json:"categories,omitempty"`
+	MinPriority string   `json:"min_priority,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Days        []string `json:"days,omitempty"`
+	From        string   `json:"from,omitempty"`
+	Until       string   `json:"until,omitempty"`
+}
+
+// Rule is one stored routing rule. Rules are evaluated in Position order.
+// Target is the GroupMe bot ID (empty for the main alert bot), webhook URL,
+// MQTT topic or email address; Secret signs webhook requests.
+type Rule struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Enabled    bool       `json:"enabled"`
+	Position   int        `json:"position"`
+	Conditions Conditions `json:"conditions"`
+	Action     Action     `json:"action"`
+	Target     string     `json:"target,omitempty"`
+	Secret     string     `json:"secret,omitempty"`
+	HasSecret  bool       `json:"has_secret"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Call is what rules are matched against. Time should already be in the
+// service's local time zone.
+type Call struct {
+	Towns    []string  `json:"towns"`
+	Category string    `json:"category"`
+	CallType string    `json:"call_type"`
+	Priority string    `json:"priority"`
+	Text     string    `json:"text"`
+	Tags     []string  `json:"tags,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Result lists the enabled rules that matched, in order. When a suppress rule
+// matches, Suppressed is set and the call must not be announced anywhere.
+type Result struct {
+	Suppressed   bool   `json:"suppressed"`
+	SuppressedBy string `json:"suppressed_by,omitempty"`
+	Matched      []Rule `json:"matched"`
+}
+
+// Actions returns the matched rules that deliver somewhere, or none when the
+// call is suppressed.
+func (r Result) Actions() []Rule {
+	if r.Suppressed {
+		return nil
+	}
+	return r.Matched
+}
+
+// Evaluate matches call against rules.
+func Evaluate(rules []Rule, call Call) Result {
+	ordered := append([]Rule(nil), rules...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].Position < ordered[j].Position })
+	res := Result{Matched: []Rule{}}
+	for _, rule := range ordered {
+		if !rule.Enabled || !rule.Conditions.Match(call) {
+			continue
+		}
+		res.Matched = append(res.Matched, rule)
+		if rule.Action == Suppress && !res.Suppressed {
+			res.Suppressed = true
+			res.SuppressedBy = rule.Name
+		}
+	}
+	return res
+}
+
+// Match reports whether every condition holds for call.
+func (c Conditions) Match(call Call) bool {
+	if len(c.Towns) > 0 && !anyEqualFold(c.Towns, call.Towns) {
+		return false
+	}
+	if len(c.Categories) > 0 && !anyEqualFold(c.Categories, []string{call.Category, call.CallType}) {
+		return false
+	}
+	if !rollups.PriorityAtLeast(call.Priority, c.MinPriority) {
+		return false
+	}
+	if len(c.Keywords) > 0 && !containsKeyword(call.Text, c.Keywords) {
+		return false
+	}
+	if len(c.Tags) > 0 && !anyEqualFold(c.Tags, call.Tags) {
+		return false
+	}
+	if len(c.Days) > 0 && !anyEqualFold(c.Days, []string{days[call.Time.Weekday()]}) {
+		return false
+	}
+	if c.From != "" && c.Until != "" {
+		from, _ := parseClock(c.From)
+		until, _ := parseClock(c.Until)
+		minute := call.Time.Hour()*60 + call.Time.Minute()
+		if from <= until {
+			return minute >= from && minute < until
+		}
+		return minute >= from || minute < until
+	}
+	return true
+}
+
+// Validate normalizes r and reports the first problem with it.
+func (r *Rule) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Target = strings.TrimSpace(r.Target)
+	r.Action = Action(strings.ToLower(strings.TrimSpace(string(r.Action))))
+	c := &r.Conditions
+	c.MinPriority = strings.ToLower(strings.TrimSpace(c.MinPriority))
+	c.Towns = cleanList(c.Towns)
+	c.Categories = cleanList(c.Categories)
+	c.Keywords = cleanList(c.Keywords)
+	c.Tags = cleanList(c.Tags)
+	c.Days = cleanList(c.Days)
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	switch r.Action {
+	case GroupMe, Suppress:
+	case Webhook:
+		if !strings.HasPrefix(r.Target, "http://") && !strings.HasPrefix(r.Target, "https://") {
+			return fmt.Errorf("invalid webhook url %q", r.Target)
+		}
+	case MQTT:
+		if r.Target == "" || strings.ContainsAny(r.Target, "+#") {
+			return fmt.Errorf("invalid mqtt topic %q", r.Target)
+		}
+	case Email:
+		if _, err := mail.ParseAddress(r.Target); err != nil {
+			return fmt.Errorf("invalid email address %q", r.Target)
+		}
+	default:
+		return fmt.Errorf("action must be groupme, webhook, mqtt, email or suppress (got %q)", r.Action)
+	}
+	switch c.MinPriority {
+	case "", "low", "medium", "high":
+	default:
+		return fmt.Errorf("invalid min_priority %q", c.MinPriority)
+	}
+	for _, d := range c.Days {
+		if !anyEqualFold(days, []string{d}) {
+			return fmt.Errorf("invalid day %q", d)
+		}
+	}
+	if (c.From == "") != (c.Until == "") {
+		return errors.New("from and until must be set together")
+	}
+	for _, v := range []string{c.From, c.Until} {
+		if _, ok := parseClock(v); v != "" && !ok {
+			return fmt.Errorf("invalid time %q (want HH:MM)", v)
+		}
+	}
+	if r.Action != Webhook {
+		r.Secret = ""
+	}
+	return nil
+}
+
+func parseClock(v string) (int, bool) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// containsKeyword reports whether text contains any keyword as whole words,
+// ignoring case and punctuation.
+func containsKeyword(text string, keywords []string) bool {
+	padded := " " + words(text) + " "
+	for _, kw := range keywords {
+		if kw := words(kw); kw != "" && strings.Contains(padded, " "+kw+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func words(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func anyEqualFold(want, have []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if w != "" && strings.EqualFold(w, strings.TrimSpace(h)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func cleanList(in []string) []string {
+	var out []string
+	for _, v := range in {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package routing
+
+import (
+	"testing"
+	"time"
+)
+
+func at(clock string, day time.Weekday) time.Time {
+	t, _ := time.Parse("15:04", clock)
+	base := time.Date(2024, 6, 2, t.Hour(), t.Minute(), 0, 0, time.UTC) // a Sunday
+	return base.AddDate(0, 0, int(day))
+}
+
+func TestConditionsMatch(t *testing.T) {
+	call := Call{
+		Towns:    []string{"Newton", "Andover"},
+		Category: "fire",
+		CallType: "Structure Fire",
+		Priority: "high",
+		Text:     "Smoke showing, possible entrapment on Main St.",
+		Tags:     []string{"mutual aid"},
+		Time:     at("23:30", time.Friday),
+	}
+	cases := []struct {
+		name string
+		cond Conditions
+		want bool
+	}{
+		{"empty", Conditions{}, true},
+		{"town", Conditions{Towns: []string{"andover"}}, true},
+		{"other town", Conditions{Towns: []string{"Sparta"}}, false},
+		{"category", Conditions{Categories: []string{"FIRE"}}, true},
+		{"call type", Conditions{Categories: []string{"structure fire"}}, true},
+		{"priority", Conditions{MinPriority: "medium"}, true},
+		{"keyword", Conditions{Keywords: []string{"entrapment"}}, true},
+		{"keyword phrase", Conditions{Keywords: []string{"main st"}}, true},
+		{"partial word", Conditions{Keywords: []string{"smok"}}, false},
+		{"tag", Conditions{Tags: []string{"Mutual Aid"}}, true},
+		{"other tag", Conditions{Tags: []string{"hazmat"}}, false},
+		{"day", Conditions{Days: []string{"fri"}}, true},
+		{"other day", Conditions{Days: []string{"sat", "sun"}}, false},
+		{"window wraps midnight", Conditions{From: "22:00", Until: "06:00"}, true},
+		{"outside window", Conditions{From: "08:00", Until: "17:00"}, false},
+		{"all must hold", Conditions{Towns: []string{"Newton"}, Categories: []string{"ems"}}, false},
+	}
+	for _, tc := range cases {
+		if got := tc.cond.Match(call); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEvaluateOrderAndSuppress(t *testing.T) {
+	call := Call{Category: "ems", Priority: "low", Text: "lift assist", Time: at("10:00", time.Monday)}
+	rules := []Rule{
+		{Name: "all webhooks", Enabled: true, Position: 2, Action: Webhook, Target: "https://example.com/hook"},
+		{Name: "ems page", Enabled: true, Position: 1, Action: GroupMe, Conditions: Conditions{Categories: []string{"ems"}}},
+		{Name: "disabled", Enabled: false, Position: 0, Action: Suppress},
+	}
+	res := Evaluate(rules, call)
+	if res.Suppressed || len(res.Actions()) != 2 || res.Matched[0].Name != "ems page" {
+		t.Fatalf("result = %+v", res)
+	}
+
+	rules = append(rules, Rule{Name: "no lift assists", Enabled: true, Position: 3, Action: Suppress, Conditions: Conditions{Keywords: []string{"lift assist"}}})
+	res = Evaluate(rules, call)
+	if !res.Suppressed || res.SuppressedBy != "no lift assists" || len(res.Actions()) != 0 || len(res.Matched) != 3 {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []Rule{
+		{Name: "page", Action: "GroupMe"},
+		{Name: "hook", Action: Webhook, Target: "https://example.com"},
+		{Name: "mqtt", Action: MQTT, Target: "alerts/fire"},
+		{Name: "mail", Action: Email, Target: "chief@example.com"},
+		{Name: "night", Action: Suppress, Conditions: Conditions{From: "22:00", Until: "06:00", Days: []string{"Sat"}}},
+	}
+	for _, r := range valid {
+		if err := r.Validate(); err != nil {
+			t.Errorf("%s: %v", r.Name, err)
+		}
+	}
+	invalid := []Rule{
+		{Action: GroupMe},
+		{Name: "x", Action: "sms"},
+		{Name: "x", Action: Webhook, Target: "example.com"},
+		{Name: "x", Action: MQTT, Target: "alerts/#"},
+		{Name: "x", Action: Email, Target: "not an address"},
+		{Name: "x", Action: GroupMe, Conditions: Conditions{MinPriority: "urgent"}},
+		{Name: "x", Action: GroupMe, Conditions: Conditions{From: "22:00"}},
+		{Name: "x", Action: GroupMe, Conditions: Conditions{From: "25:00", Until: "06:00"}},
+		{Name: "x", Action: GroupMe, Conditions: Conditions{Days: []string{"someday"}}},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected error for %+v", r)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+	"alert_framework/mqtt"
+	"alert_framework/rollups"
+	"alert_framework/routing"
+)
+
+const routeSendTimeout = 30 * time.Second
+
+func migrateAddRoutingRules(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS routing_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    position INTEGER NOT NULL DEFAULT 0,
+    conditions TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target TEXT NOT NULL DEFAULT '',
+    secret TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);`)
+	return err
+}
+
+const routingRuleColumns = `id, name, enabled, position, conditions, action, target, secret, created_at, updated_at`
+
+func scanRoutingRule(row interface{ Scan(...interface{}) error }) (routing.Rule, error) {
+	var r routing.Rule
+	var conditions string
+	if err := row.Scan(&r.ID, &r.Name, &r.Enabled, &r.Position, &conditions, &r.Action, &r.Target, &r.Secret, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal([]byte(conditions), &r.Conditions); err != nil {
+		log.Printf("routing rule %d has invalid conditions: %v", r.ID, err)
+	}
+	return r, nil
+}
+
+// loadRoutingRules reads every rule in evaluation order. Rules are read on
+// each call so edits made through an api-mode process reach the workers
+// immediately.
+func (s *server) loadRoutingRules(ctx context.Context) ([]routing.Rule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+routingRuleColumns+` FROM routing_rules ORDER BY position, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := []routing.Rule{}
+	for rows.Next() {
+		r, err := scanRoutingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+func redactRoutingRule(r routing.Rule) routing.Rule {
+	r.HasSecret = r.Secret != ""
+	r.Secret = ""
+	return r
+}
+
+func redactRoutingRules(rules []routing.Rule) []routing.Rule {
+	out := make([]routing.Rule, len(rules))
+	for i, r := range rules {
+		out[i] = redactRoutingRule(r)
+	}
+	return out
+}
+
+// routingCallFor describes a call the way rules see it. Categories are
+// derived from the call type, and priority uses the rollup keyword rules.
+func routingCallFor(towns []string, callType, text string, tags []string, at time.Time) routing.Call {
+	return routing.Call{
+		Towns:    towns,
+		Category: formatting.NormalizeCallCategory(callType),
+		CallType: strings.TrimSpace(callType),
+		Priority: rollups.CallPriority(callType, text),
+		Text:     text,
+		Tags:     tags,
+		Time:     at,
+	}
+}
+
+// evaluateRoutes matches an alerted call against the stored rules and the
+// saved searches that notify. A rule lookup failure routes nothing extra and
+// suppresses nothing.
+func (s *server) evaluateRoutes(incident formatting.IncidentDetails, callType string, recognized []string, transcript string, at time.Time) routing.Result {
+	rules, err := s.loadAllRoutes(s.ctx)
+	if err != nil {
+		log.Printf("routing rules lookup failed: %v", err)
+		return routing.Result{}
+	}
+	if len(rules) == 0 {
+		return routing.Result{}
+	}
+	towns := append([]string{incident.CityOrTown}, recognized...)
+	return routing.Evaluate(rules, routingCallFor(towns, callType, transcript, incident.Tags, at.In(s.tz)))
+}
+
+// loadAllRoutes is the stored rules followed by the saved-search
+// notifications, which always run after every rule.
+func (s *server) loadAllRoutes(ctx context.Context) ([]routing.Rule, error) {
+	rules, err := s.loadRoutingRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	searches, err := s.savedSearchRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, searches...), nil
+}
+
+// dispatchRoutes performs the actions of matched rules, best effort. Webhook
+// and MQTT actions carry the same JSON payload as configured webhooks.
+func (s *server) dispatchRoutes(j processJob, incident formatting.IncidentDetails, alertBody string, rules []routing.Rule) {
+	var payload []byte
+	jsonPayload := func() ([]byte, error) {
+		if payload != nil {
+			return payload, nil
+		}
+		body, _, err := s.buildWebhookPayload(j)
+		if err != nil {
+			return nil, err
+		}
+		payload, err = json.Marshal(body)
+		return payload, err
+	}
+	cfg := s.liveConfig()
+	for _, rule := range rules {
+		var err error
+		switch rule.Action {
+		case routing.GroupMe:
+			botID := rule.Target
+			if botID == "" {
+				botID = getBotID(cfg)
+			}
+			err = s.postGroupMe(botID, alertBody)
+		case routing.Webhook:
+			var body []byte
+			if body, err = jsonPayload(); err == nil {
+				var status int
+				status, _, err = s.sendWebhook(rule.Target, rule.Secret, body)
+				if err == nil && status >= 300 {
+					err = errors.New("status " + strconv.Itoa(status))
+				}
+			}
+		case routing.MQTT:
+			if cfg.MQTTBrokerURL == "" {
+				err = errors.New("MQTT_BROKER_URL is not set")
+				break
+			}
+			var body []byte
+			if body, err = jsonPayload(); err == nil {
+				ctx, cancel := context.WithTimeout(s.ctx, routeSendTimeout)
+				err = mqtt.Broker{URL: cfg.MQTTBrokerURL, Username: cfg.MQTTUsername, Password: cfg.MQTTPassword}.Publish(ctx, rule.Target, body, false)
+				cancel()
+			}
+		case routing.Email:
+			err = s.sendRouteEmail(j.filename, incident, alertBody, rule.Target)
+		}
+		if err != nil {
+			log.Printf("routing rule %q (%s) for %s failed: %v", rule.Name, rule.Action, j.filename, err)
+		}
+	}
+}
+
+func (s *server) sendRouteEmail(filename string, incident formatting.IncidentDetails, alertBody, to string) error {
+	settings, err := s.loadSettings()
+	if err != nil {
+		return err
+	}
+	if !settings.Email.configured() {
+		return errors.New("email is not configured")
+	}
+	msg, err := s.buildAlertEmail(filename, incident, alertBody)
+	if err != nil {
+		return err
+	}
+	msg.To = []string{to}
+	return s.sendEmail(settings.Email, msg)
+}
+
+// handleRoutingRules lists rules in evaluation order (GET) or adds one
+// (POST). Admin only; webhook secrets are never returned.
+func (s *server) handleRoutingRules(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := s.loadRoutingRules(r.Context())
+		if err != nil {
+			log.Printf("routing rules list failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"rules": redactRoutingRules(rules)})
+	case http.MethodPost:
+		var rule routing.Rule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		if err := rule.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		conditions, _ := json.Marshal(rule.Conditions)
+		rule.CreatedAt = time.Now().UTC()
+		rule.UpdatedAt = rule.CreatedAt
+		res, err := execWithRetry(s.db, `INSERT INTO routing_rules (name, enabled, position, conditions, action, target, secret, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			rule.Name, rule.Enabled, rule.Position, string(conditions), rule.Action, rule.Target, rule.Secret, rule.CreatedAt, rule.UpdatedAt)
+		if err != nil {
+			log.Printf("routing rule insert failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		rule.ID, _ = res.LastInsertId()
+		log.Printf("routing rule %d %q added by %s", rule.ID, rule.Name, clientIP(r))
+		noteAudit(r, "routing_rule.create", strconv.FormatInt(rule.ID, 10), nil, redactRoutingRule(rule))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		respondJSON(w, redactRoutingRule(rule))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRoutingRuleDetail reads (GET), replaces (PUT) or deletes (DELETE) one
+// rule. A PUT without a secret keeps the stored one while has_secret is true.
+func (s *server) handleRoutingRuleDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/routing/rules/"), "/"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	existing, err := scanRoutingRule(s.db.QueryRowContext(r.Context(), `SELECT `+routingRuleColumns+` FROM routing_rules WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("routing rule load failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, redactRoutingRule(existing))
+		return
+	case http.MethodDelete:
+		if _, err := execWithRetry(s.db, `DELETE FROM routing_rules WHERE id = ?`, id); err != nil {
+			log.Printf("routing rule delete failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("routing rule %d %q deleted by %s", id, existing.Name, clientIP(r))
+		noteAudit(r, "routing_rule.delete", strconv.FormatInt(id, 10), redactRoutingRule(existing), nil)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var rule routing.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if rule.Secret == "" && rule.HasSecret {
+		rule.Secret = existing.Secret
+	}
+	if err := rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule.ID, rule.CreatedAt, rule.UpdatedAt = id, existing.CreatedAt, time.Now().UTC()
+	conditions, _ := json.Marshal(rule.Conditions)
+	if _, err := execWithRetry(s.db, `UPDATE routing_rules SET name = ?, enabled = ?, position = ?, conditions = ?, action = ?, target = ?, secret = ?, updated_at = ? WHERE id = ?`,
+		rule.Name, rule.Enabled, rule.Position, string(conditions), rule.Action, rule.Target, rule.Secret, rule.UpdatedAt, id); err != nil {
+		log.Printf("routing rule update failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("routing rule %d %q updated by %s", id, rule.Name, clientIP(r))
+	noteAudit(r, "routing_rule.update", strconv.FormatInt(id, 10), redactRoutingRule(existing), redactRoutingRule(rule))
+	respondJSON(w, redactRoutingRule(rule))
+}
+
+type routingEvaluateRequest struct {
+	Filename string          `json:"filename"`
+	Call     *routing.Call   `json:"call"`
+	Rules    *[]routing.Rule `json:"rules"`
+}
+
+// handleRoutingEvaluate is a dry run: it reports which rules would match a
+// stored call ({"filename"}) or a described one ({"call"}) without sending
+// anything. "rules" evaluates a draft rule set instead of the stored rules.
+func (s *server) handleRoutingEvaluate(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req routingEvaluateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	var call routing.Call
+	switch {
+	case strings.TrimSpace(req.Filename) != "":
+		record, err := s.getTranscription(strings.TrimSpace(req.Filename))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("routing evaluate lookup failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		call = s.routingCallForRecord(*record)
+	case req.Call != nil:
+		call = *req.Call
+		if call.Time.IsZero() {
+			call.Time = time.Now()
+		}
+		call.Time = call.Time.In(s.tz)
+		if call.Category == "" {
+			call.Category = formatting.NormalizeCallCategory(call.CallType)
+		}
+		if call.Priority == "" {
+			call.Priority = rollups.CallPriority(call.CallType, call.Text)
+		}
+	default:
+		http.Error(w, "filename or call is required", http.StatusBadRequest)
+		return
+	}
+	var rules []routing.Rule
+	if req.Rules != nil {
+		rules = *req.Rules
+		for i := range rules {
+			if err := rules[i].Validate(); err != nil {
+				http.Error(w, "rule "+strconv.Itoa(i)+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	} else {
+		var err error
+		if rules, err = s.loadAllRoutes(r.Context()); err != nil {
+			log.Printf("routing rules list failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+	}
+	result := routing.Evaluate(rules, call)
+	result.Matched = redactRoutingRules(result.Matched)
+	respondJSON(w, map[string]interface{}{"call": call, "result": result})
+}
+
+// routingCallForRecord describes a stored call for the dry run, using the
+// same fields the alert path has when the call completes.
+func (s *server) routingCallForRecord(t transcription) routing.Call {
+	meta, _ := s.parseCallMetadata(t.Filename, "")
+	callType := derefString(t.CallType, meta.CallType)
+	at := meta.DateTime
+	if t.CallTimestamp != nil {
+		at = *t.CallTimestamp
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	towns := append([]string{meta.TownDisplay}, parseRecognizedTowns(t.RecognizedTowns)...)
+	return routingCallFor(towns, callType, derefString(pickTranscript(&t), ""), parseRecognizedTownList(t.TagsJSON), at.In(s.tz))
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/routing"
+)
+
+// savedSearch is a named call list filter the UI can load. With Notify set,
+// calls that match it as they complete are also delivered like a routing
+// rule's action.
+type savedSearch struct {
+	ID        int64              `json:"id"`
+	Name      string             `json:"name"`
+	Query     string             `json:"query,omitempty"`
+	Window    string             `json:"window,omitempty"`
+	Tags      []string           `json:"tags,omitempty"`
+	Towns     []string           `json:"towns,omitempty"`
+	CallType  string             `json:"call_type,omitempty"`
+	Notify    *savedSearchNotify `json:"notify,omitempty"`
+	CreatedBy string             `json:"created_by,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// savedSearchNotify is a routing action: groupme, webhook, mqtt or email.
+type savedSearchNotify struct {
+	Action    routing.Action `json:"action"`
+	Target    string         `json:"target,omitempty"`
+	Secret    string         `json:"secret,omitempty"`
+	HasSecret bool           `json:"has_secret"`
+}
+
+func migrateAddSavedSearches(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS saved_searches (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL DEFAULT '',
+    time_window TEXT NOT NULL DEFAULT '',
+    tags TEXT NOT NULL DEFAULT '[]',
+    towns TEXT NOT NULL DEFAULT '[]',
+    call_type TEXT NOT NULL DEFAULT '',
+    notify_action TEXT NOT NULL DEFAULT '',
+    notify_target TEXT NOT NULL DEFAULT '',
+    notify_secret TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);`)
+	return err
+}
+
+const savedSearchColumns = `id, name, query, time_window, tags, towns, call_type, notify_action, notify_target, notify_secret, created_by, created_at, updated_at`
+
+func scanSavedSearch(row interface{ Scan(...interface{}) error }) (savedSearch, error) {
+	var ss savedSearch
+	var tags, towns, action, target, secret string
+	if err := row.Scan(&ss.ID, &ss.Name, &ss.Query, &ss.Window, &tags, &towns, &ss.CallType, &action, &target, &secret, &ss.CreatedBy, &ss.CreatedAt, &ss.UpdatedAt); err != nil {
+		return ss, err
+	}
+	if err := json.Unmarshal([]byte(tags), &ss.Tags); err != nil {
+		log.Printf("saved search %d has invalid tags: %v", ss.ID, err)
+	}
+	if err := json.Unmarshal([]byte(towns), &ss.Towns); err != nil {
+		log.Printf("saved search %d has invalid towns: %v", ss.ID, err)
+	}
+	if action != "" {
+		ss.Notify = &savedSearchNotify{Action: routing.Action(action), Target: target, Secret: secret}
+	}
+	return ss, nil
+}
+
+func (s *server) loadSavedSearches(ctx context.Context) ([]savedSearch, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+savedSearchColumns+` FROM saved_searches ORDER BY name COLLATE NOCASE, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []savedSearch{}
+	for rows.Next() {
+		ss, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ss)
+	}
+	return out, rows.Err()
+}
+
+func (s *server) loadSavedSearch(ctx context.Context, id int64) (savedSearch, error) {
+	return scanSavedSearch(s.db.QueryRowContext(ctx, `SELECT `+savedSearchColumns+` FROM saved_searches WHERE id = ?`, id))
+}
+
+// validate normalizes ss and reports the first problem with it. A search that
+// notifies must narrow the calls somehow, or it would deliver every call.
+func (ss *savedSearch) validate() error {
+	ss.Name = strings.TrimSpace(ss.Name)
+	ss.Query = strings.TrimSpace(ss.Query)
+	ss.CallType = strings.TrimSpace(ss.CallType)
+	ss.Tags = parseTagFilter(strings.Join(ss.Tags, ","))
+	ss.Towns = cleanTownList(ss.Towns)
+	if ss.Name == "" {
+		return errors.New("name is required")
+	}
+	if raw := strings.TrimSpace(ss.Window); raw != "" {
+		ss.Window, _ = normalizeWindowName(raw, "24h")
+	}
+	if ss.Notify == nil {
+		return nil
+	}
+	if ss.Query == "" && ss.CallType == "" && len(ss.Tags) == 0 && len(ss.Towns) == 0 {
+		return errors.New("notify needs a query, call_type, tags or towns")
+	}
+	rule := ss.rule()
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if rule.Action == routing.Suppress {
+		return errors.New("notify action must be groupme, webhook, mqtt or email")
+	}
+	ss.Notify.Action, ss.Notify.Target, ss.Notify.Secret = rule.Action, rule.Target, rule.Secret
+	return nil
+}
+
+func cleanTownList(in []string) []string {
+	var out []string
+	for _, v := range in {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// rule is the routing rule a notifying search becomes. The query matches as
+// a whole-word phrase in the transcript, like a rule keyword.
+func (ss savedSearch) rule() routing.Rule {
+	r := routing.Rule{
+		Name:     "saved search: " + ss.Name,
+		Enabled:  true,
+		Position: math.MaxInt,
+		Conditions: routing.Conditions{
+			Towns: ss.Towns,
+			Tags:  ss.Tags,
+		},
+	}
+	if ss.Query != "" {
+		r.Conditions.Keywords = []string{ss.Query}
+	}
+	if ss.CallType != "" {
+		r.Conditions.Categories = []string{ss.CallType}
+	}
+	if ss.Notify != nil {
+		r.Action, r.Target, r.Secret = ss.Notify.Action, ss.Notify.Target, ss.Notify.Secret
+	}
+	return r
+}
+
+// savedSearchRules returns the notifying searches as routing rules.
+func (s *server) savedSearchRules(ctx context.Context) ([]routing.Rule, error) {
+	searches, err := s.loadSavedSearches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rules []routing.Rule
+	for _, ss := range searches {
+		if ss.Notify != nil {
+			rules = append(rules, ss.rule())
+		}
+	}
+	return rules, nil
+}
+
+// redactSavedSearch hides notification settings and authors from public
+// requests and secrets from everyone.
+func redactSavedSearch(ss savedSearch, admin bool) savedSearch {
+	if !admin {
+		ss.Notify = nil
+		ss.CreatedBy = ""
+		return ss
+	}
+	if ss.Notify == nil {
+		return ss
+	}
+	n := *ss.Notify
+	n.HasSecret = n.Secret != ""
+	n.Secret = ""
+	ss.Notify = &n
+	return ss
+}
+
+func (s *server) writeSavedSearch(ss savedSearch) (int64, error) {
+	tags, _ := json.Marshal(nonNilStrings(ss.Tags))
+	towns, _ := json.Marshal(nonNilStrings(ss.Towns))
+	var action, target, secret string
+	if ss.Notify != nil {
+		action, target, secret = string(ss.Notify.Action), ss.Notify.Target, ss.Notify.Secret
+	}
+	if ss.ID == 0 {
+		res, err := execWithRetry(s.db, `INSERT INTO saved_searches (name, query, time_window, tags, towns, call_type, notify_action, notify_target, notify_secret, created_by, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ss.Name, ss.Query, ss.Window, string(tags), string(towns), ss.CallType, action, target, secret, ss.CreatedBy, ss.CreatedAt, ss.UpdatedAt)
+		if err != nil {
+			return 0, err
+		}
+		return res.LastInsertId()
+	}
+	_, err := execWithRetry(s.db, `UPDATE saved_searches SET name = ?, query = ?, time_window = ?, tags = ?, towns = ?, call_type = ?, notify_action = ?, notify_target = ?, notify_secret = ?, updated_at = ? WHERE id = ?`,
+		ss.Name, ss.Query, ss.Window, string(tags), string(towns), ss.CallType, action, target, secret, ss.UpdatedAt, ss.ID)
+	return ss.ID, err
+}
+
+func nonNilStrings(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
+
+// handleSavedSearches lists saved searches (GET) or adds one (POST, admin).
+// Anyone may list them; notification settings are only shown to admins.
+func (s *server) handleSavedSearches(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		searches, err := s.loadSavedSearches(r.Context())
+		if err != nil {
+			log.Printf("saved search list failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		admin := isAdminRequest(r)
+		for i := range searches {
+			searches[i] = redactSavedSearch(searches[i], admin)
+		}
+		respondJSON(w, map[string]interface{}{"searches": searches})
+	case http.MethodPost:
+		if !requireAdmin(w, r) {
+			return
+		}
+		var ss savedSearch
+		if err := json.NewDecoder(r.Body).Decode(&ss); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		ss.ID = 0
+		if err := ss.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ss.CreatedBy = settingsActor(r)
+		ss.CreatedAt = time.Now().UTC()
+		ss.UpdatedAt = ss.CreatedAt
+		id, err := s.writeSavedSearch(ss)
+		if err != nil {
+			log.Printf("saved search insert failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		ss.ID = id
+		noteAudit(r, "saved_search.create", strconv.FormatInt(id, 10), nil, redactSavedSearch(ss, true))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		respondJSON(w, redactSavedSearch(ss, true))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSavedSearchDetail reads (GET), replaces (PUT, admin) or deletes
+// (DELETE, admin) one saved search. A PUT whose notify has no secret keeps the
+// stored one while has_secret is true.
+func (s *server) handleSavedSearchDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Method != http.MethodGet && !requireAdmin(w, r) {
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/searches/"), "/"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	existing, err := s.loadSavedSearch(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("saved search load failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		respondJSON(w, redactSavedSearch(existing, isAdminRequest(r)))
+		return
+	case http.MethodDelete:
+		if _, err := execWithRetry(s.db, `DELETE FROM saved_searches WHERE id = ?`, id); err != nil {
+			log.Printf("saved search delete failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		noteAudit(r, "saved_search.delete", strconv.FormatInt(id, 10), redactSavedSearch(existing, true), nil)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var ss savedSearch
+	if err := json.NewDecoder(r.Body).Decode(&ss); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if ss.Notify != nil && ss.Notify.Secret == "" && ss.Notify.HasSecret && existing.Notify != nil {
+		ss.Notify.Secret = existing.Notify.Secret
+	}
+	if err := ss.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ss.ID, ss.CreatedBy, ss.CreatedAt, ss.UpdatedAt = id, existing.CreatedBy, existing.CreatedAt, time.Now().UTC()
+	if _, err := s.writeSavedSearch(ss); err != nil {
+		log.Printf("saved search update failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	noteAudit(r, "saved_search.update", strconv.FormatInt(id, 10), redactSavedSearch(existing, true), redactSavedSearch(ss, true))
+	respondJSON(w, redactSavedSearch(ss, true))
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/errcode"
+)
+
+const (
+	defaultSettingsHistoryLimit = 20
+	maxSettingsHistoryLimit     = 200
+
+	settingsActionInitial  = "initial"
+	settingsActionUpdate   = "update"
+	settingsActionRollback = "rollback"
+
+	// sampleCleanupTranscript is the prompt test input when no call has a
+	// stored transcript yet.
+	sampleCleanupTranscript = "Sussex County to Sparta Fire, Engine 5 respond to 12 Main Street for a reported structure fire, cross street Woodport Road."
+)
+
+type settingsChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+type settingsVersion struct {
+	Version      int64            `json:"version"`
+	Action       string           `json:"action"`
+	ChangedBy    string           `json:"changed_by,omitempty"`
+	RolledBackTo *int64           `json:"rolled_back_to,omitempty"`
+	Changes      []settingsChange `json:"changes"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+type cleanupPromptTest struct {
+	Status     string   `json:"status"`
+	Sample     string   `json:"sample,omitempty"`
+	Normalized string   `json:"normalized,omitempty"`
+	Towns      []string `json:"recognized_towns,omitempty"`
+}
+
+func migrateAddSettingsHistory(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS settings_history (
+    version INTEGER PRIMARY KEY AUTOINCREMENT,
+    action TEXT NOT NULL,
+    changed_by TEXT NULL,
+    rolled_back_to INTEGER NULL,
+    settings_json TEXT NOT NULL,
+    diff_json TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);`)
+	return err
+}
+
+// settingsActor names who made a change: the X-Admin-User header when the
+// caller sets one, otherwise the client address. The admin token is shared,
+// so this is a label, not an identity.
+func settingsActor(r *http.Request) string {
+	if user := strings.TrimSpace(r.Header.Get("X-Admin-User")); user != "" {
+		return user
+	}
+	return clientIP(r)
+}
+
+// diffSettings lists the fields that differ between two settings, in the
+// shape GET /api/settings returns them. Webhook secrets and the SMTP password
+// are compared only by has_secret and has_password.
+func diffSettings(prev, next AppSettings) []settingsChange {
+	before, after := settingsFields(prev), settingsFields(next)
+	keys := make([]string, 0, len(after))
+	for k := range after {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	changes := []settingsChange{}
+	for _, k := range keys {
+		if !reflect.DeepEqual(before[k], after[k]) {
+			changes = append(changes, settingsChange{Field: k, Old: before[k], New: after[k]})
+		}
+	}
+	return changes
+}
+
+func settingsFields(settings AppSettings) map[string]interface{} {
+	settings.WebhookEndpoints = redactWebhookSecrets(settings.WebhookEndpoints)
+	settings.Email = redactEmailPassword(settings.Email)
+	data, _ := json.Marshal(settings)
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// recordSettingsVersion appends the saved settings to settings_history,
+// first recording prev as the initial version when the history is empty so
+// the state before the first tracked change can be restored. The saved
+// settings are read back rather than taken from the request so defaults
+// filled in by loadSettings do not show up as changes. It returns the new
+// version, or the current one when nothing changed.
+func (s *server) recordSettingsVersion(prev AppSettings, by, action string, rolledBackTo int64) (int64, error) {
+	next, err := s.loadSettings()
+	if err != nil {
+		return 0, err
+	}
+	var latest sql.NullInt64
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&latest)
+	}, `SELECT MAX(version) FROM settings_history`); err != nil {
+		return 0, err
+	}
+	if !latest.Valid {
+		if _, err := s.insertSettingsVersion(prev, settingsActionInitial, "", nil, 0); err != nil {
+			return 0, err
+		}
+	}
+	changes := diffSettings(prev, next)
+	if len(changes) == 0 && latest.Valid {
+		return latest.Int64, nil
+	}
+	return s.insertSettingsVersion(next, action, by, changes, rolledBackTo)
+}
+
+func (s *server) insertSettingsVersion(settings AppSettings, action, by string, changes []settingsChange, rolledBackTo int64) (int64, error) {
+	snapshot, err := json.Marshal(settings)
+	if err != nil {
+		return 0, err
+	}
+	if changes == nil {
+		changes = []settingsChange{}
+	}
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return 0, err
+	}
+	var target interface{}
+	if rolledBackTo > 0 {
+		target = rolledBackTo
+	}
+	res, err := execWithRetry(s.db, `INSERT INTO settings_history (action, changed_by, rolled_back_to, settings_json, diff_json, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		action, nullableString(by), target, string(snapshot), string(diff), time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// testCleanupPrompt runs prompt once against the newest stored raw
+// transcript. A reply that is not the JSON the pipeline parses is the
+// prompt's fault and rejects the change; transport failures are returned
+// as-is. Without an API key the test is skipped.
+func (s *server) testCleanupPrompt(prompt string) (cleanupPromptTest, error) {
+	if os.Getenv("OPENAI_API_KEY") == "" {
+		return cleanupPromptTest{Status: "skipped"}, nil
+	}
+	sample := sampleCleanupTranscript
+	var raw sql.NullString
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&raw)
+	}, `SELECT raw_transcript_text FROM transcriptions WHERE status = ? AND duplicate_of IS NULL AND TRIM(COALESCE(raw_transcript_text, '')) != '' ORDER BY id DESC LIMIT 1`, statusDone); err == nil {
+		sample = raw.String
+	}
+	_, normalized, towns, err := s.domainCleanup(sample, prompt)
+	if err != nil {
+		return cleanupPromptTest{Status: "failed", Sample: sample}, refineError(err)
+	}
+	return cleanupPromptTest{Status: "passed", Sample: sample, Normalized: normalized, Towns: towns}, nil
+}
+
+// handleSettingsHistory serves GET /api/settings/history: settings versions,
+// newest first, with what each changed.
+func (s *server) handleSettingsHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	limit := defaultSettingsHistoryLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxSettingsHistoryLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxSettingsHistoryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	rows, err := s.db.QueryContext(r.Context(), `SELECT version, action, changed_by, rolled_back_to, diff_json, created_at FROM settings_history ORDER BY version DESC LIMIT ?`, limit)
+	if err != nil {
+		log.Printf("settings history query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	versions := []settingsVersion{}
+	for rows.Next() {
+		var v settingsVersion
+		var by sql.NullString
+		var target sql.NullInt64
+		var diff string
+		if err := rows.Scan(&v.Version, &v.Action, &by, &target, &diff, &v.CreatedAt); err != nil {
+			log.Printf("settings history scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		v.ChangedBy = by.String
+		if target.Valid {
+			v.RolledBackTo = &target.Int64
+		}
+		if err := json.Unmarshal([]byte(diff), &v.Changes); err != nil {
+			v.Changes = []settingsChange{}
+		}
+		versions = append(versions, v)
+	}
+	respondJSON(w, map[string]interface{}{"versions": versions})
+}
+
+// handleSettingsRollback serves POST /api/settings/rollback/{version}: it
+// restores that version's settings, recorded as a new version.
+func (s *server) handleSettingsRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	target, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/settings/rollback/"), "/"), 10, 64)
+	if err != nil || target <= 0 {
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+	var snapshot string
+	err = queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&snapshot)
+	}, `SELECT settings_json FROM settings_history WHERE version = ?`, target)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("settings version %d load failed: %v", target, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	var restored AppSettings
+	if err := json.Unmarshal([]byte(snapshot), &restored); err != nil {
+		http.Error(w, "stored settings are invalid", http.StatusInternalServerError)
+		return
+	}
+	current, err := s.loadSettings()
+	if err != nil {
+		log.Printf("load settings failed: %v", err)
+		http.Error(w, "settings error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.saveSettings(restored); err != nil {
+		log.Printf("settings rollback to %d failed: %v", target, err)
+		http.Error(w, "save error", http.StatusInternalServerError)
+		return
+	}
+	before, after := settingsAuditState(current, restored)
+	noteAudit(r, "settings.rollback", strconv.FormatInt(target, 10), before, after)
+	version, err := s.recordSettingsVersion(current, settingsActor(r), settingsActionRollback, target)
+	if err != nil {
+		log.Printf("settings history write failed: %v", err)
+	}
+	log.Printf("settings rolled back to version %d by %s", target, settingsActor(r))
+	respondJSON(w, map[string]interface{}{"status": "ok", "version": version, "rolled_back_to": target})
+}
+
+// promptTestStatus maps a failed prompt test to a response code: the prompt
+// is rejected when its output does not parse, while an unreachable model is
+// a gateway problem the admin can retry.
+func promptTestStatus(err error) int {
+	if errcode.Of(err) == errcode.RefineParseError {
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
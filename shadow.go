@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+	"alert_framework/prompts"
+)
+
+const (
+	shadowQueueSize       = 16
+	defaultShadowReport   = 30
+	defaultShadowResults  = 50
+	maxShadowResultsLimit = 500
+)
+
+// shadowJob is a finished production call to run again with the candidate.
+// The production side is captured so the comparison does not depend on later
+// edits to the call.
+type shadowJob struct {
+	filename   string
+	audioPath  string
+	meta       formatting.CallMetadata
+	options    TranscriptionOptions
+	production shadowOutcome
+}
+
+// shadowOutcome is what one pipeline produced for a call.
+type shadowOutcome struct {
+	Model           string `json:"model"`
+	CleanupVersion  string `json:"cleanup_prompt_version"`
+	MetadataVersion string `json:"metadata_prompt_version"`
+	Transcript      string `json:"transcript"`
+	Address         string `json:"address,omitempty"`
+	CallType        string `json:"call_type,omitempty"`
+	DurationMS      int64  `json:"duration_ms"`
+}
+
+type shadowResult struct {
+	ID                   int64         `json:"id"`
+	Filename             string        `json:"filename"`
+	Production           shadowOutcome `json:"production"`
+	Candidate            shadowOutcome `json:"candidate"`
+	TranscriptDivergence *float64      `json:"transcript_divergence,omitempty"`
+	AddressMatch         *bool         `json:"address_match,omitempty"`
+	CallTypeMatch        *bool         `json:"call_type_match,omitempty"`
+	Error                string        `json:"error,omitempty"`
+	CreatedAt            time.Time     `json:"created_at"`
+}
+
+// shadowSummary aggregates the results of one candidate against one
+// production configuration.
+type shadowSummary struct {
+	CandidateModel           string   `json:"candidate_model"`
+	CleanupPromptVersion     string   `json:"cleanup_prompt_version"`
+	MetadataPromptVersion    string   `json:"metadata_prompt_version"`
+	ProductionModel          string   `json:"production_model"`
+	Samples                  int      `json:"samples"`
+	Errors                   int      `json:"errors"`
+	MeanTranscriptDivergence *float64 `json:"mean_transcript_divergence,omitempty"`
+	AddressAgreement         *float64 `json:"address_agreement,omitempty"`
+	AddressCompared          int      `json:"address_compared"`
+	CallTypeAgreement        *float64 `json:"call_type_agreement,omitempty"`
+	MeanProductionMS         *float64 `json:"mean_production_ms,omitempty"`
+	MeanCandidateMS          *float64 `json:"mean_candidate_ms,omitempty"`
+}
+
+type shadowReportResponse struct {
+	Enabled    bool            `json:"enabled"`
+	Percent    int             `json:"percent"`
+	Candidate  *shadowOutcome  `json:"candidate,omitempty"`
+	Days       int             `json:"days"`
+	Candidates []shadowSummary `json:"candidates"`
+}
+
+// startShadowWorker runs the candidate pipeline over a SHADOW_PERCENT sample of
+// completed calls. Candidate prompt versions are resolved once at startup; an
+// unknown version disables shadow mode rather than guessing.
+func (s *server) startShadowWorker(ctx context.Context) {
+	if s.cfg.ShadowPercent <= 0 {
+		return
+	}
+	pins := make(map[string]prompts.Assignment)
+	for kind, version := range map[string]string{prompts.KindCleanup: s.cfg.ShadowCleanupVersion, prompts.KindMetadata: s.cfg.ShadowMetadataVersion} {
+		a, err := s.evalPromptPin(ctx, kind, version)
+		if err != nil {
+			log.Printf("shadow mode disabled: %v", err)
+			return
+		}
+		pins[kind] = a
+	}
+	s.shadowPins = pins
+	s.shadowJobs = make(chan shadowJob, shadowQueueSize)
+	log.Printf("shadow mode: %d%% of calls with model %q, cleanup %s, metadata %s", s.cfg.ShadowPercent, s.cfg.ShadowModel, pins[prompts.KindCleanup].Version, pins[prompts.KindMetadata].Version)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case job := <-s.shadowJobs:
+				s.runShadow(ctx, job)
+			}
+		}
+	}()
+}
+
+// maybeShadow queues a completed call for the candidate pipeline if it falls
+// in the sample. Sampling hashes the filename so a reprocessed call stays in or
+// out. When the shadow queue is full the call is skipped; shadow work never
+// holds up production.
+func (s *server) maybeShadow(job shadowJob) {
+	if s.shadowJobs == nil || !shadowSampled(job.filename, s.cfg.ShadowPercent) {
+		return
+	}
+	select {
+	case s.shadowJobs <- job:
+	default:
+		log.Printf("shadow queue full; skipping %s", job.filename)
+	}
+}
+
+// promptVersion names the prompt a pipeline step used; nil means the
+// settings prompt.
+func promptVersion(a *prompts.Assignment) string {
+	if a == nil || a.Version == "" {
+		return prompts.BaselineVersion
+	}
+	return a.Version
+}
+
+func shadowSampled(filename string, percent int) bool {
+	h := fnv.New32a()
+	h.Write([]byte("shadow:" + filename))
+	return int(h.Sum32()%100) < percent
+}
+
+// runShadow sends one call through transcription, enrichment and location
+// resolution with the candidate model and prompts, the way runEvalCase does,
+// and stores the comparison. Nothing it produces reaches the call record or
+// alerts.
+func (s *server) runShadow(ctx context.Context, job shadowJob) {
+	opts := job.options
+	if s.cfg.ShadowModel != "" {
+		opts.Model = s.cfg.ShadowModel
+	}
+	candidate := shadowOutcome{
+		Model:           opts.Model,
+		CleanupVersion:  s.shadowPins[prompts.KindCleanup].Version,
+		MetadataVersion: s.shadowPins[prompts.KindMetadata].Version,
+	}
+	var exists int
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&exists)
+	}, `SELECT COUNT(*) FROM shadow_results WHERE filename = ? AND candidate_model = ? AND cleanup_prompt_version = ? AND metadata_prompt_version = ?`,
+		job.filename, candidate.Model, candidate.CleanupVersion, candidate.MetadataVersion)
+	if err != nil || exists > 0 {
+		return
+	}
+
+	result := shadowResult{Filename: job.filename, Production: job.production}
+	key := "shadow-" + job.filename
+	s.evalPrompts.Store(key, s.shadowPins)
+	defer s.evalPrompts.Delete(key)
+	defer s.locationCache.Delete(key)
+
+	staged := filepath.Join(s.cfg.WorkDir, key)
+	s.workFiles.acquire(staged)
+	defer s.workFiles.release(staged)
+	if err := copyFile(job.audioPath, staged); err != nil {
+		result.Error = err.Error()
+	} else {
+		defer os.Remove(staged)
+		start := time.Now()
+		artifacts, err := s.multiPassTranscription(key, staged, opts, job.meta)
+		candidate.DurationMS = time.Since(start).Milliseconds()
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			candidate.Transcript = artifacts.CleanTranscript
+			callType := artifacts.CallType
+			if callType == nil && job.meta.CallType != "" {
+				callType = &job.meta.CallType
+			}
+			candidate.CallType = derefString(callType, "")
+			normalized := artifacts.NormalizedText
+			if normalized == nil || strings.TrimSpace(*normalized) == "" {
+				fallback := s.normalizeTranscript(artifacts.CleanTranscript, job.meta)
+				normalized = &fallback
+			}
+			record := transcription{
+				Filename:             key,
+				NormalizedTranscript: normalized,
+				CleanTranscript:      &artifacts.CleanTranscript,
+				RawTranscript:        &artifacts.RawTranscript,
+				RecognizedTowns:      artifacts.RecognizedTowns,
+				CallType:             callType,
+			}
+			if loc, _ := s.resolveCallLocation(ctx, record, job.meta, parseRecognizedTowns(artifacts.RecognizedTowns)); loc != nil {
+				candidate.Address = loc.Label
+			}
+			divergence := formatting.WordErrorRate(job.production.Transcript, candidate.Transcript)
+			result.TranscriptDivergence = &divergence
+			if job.production.Address != "" || candidate.Address != "" {
+				match := formatting.AddressMatches(job.production.Address, candidate.Address)
+				result.AddressMatch = &match
+			}
+			callTypeMatch := strings.EqualFold(strings.TrimSpace(job.production.CallType), strings.TrimSpace(candidate.CallType))
+			result.CallTypeMatch = &callTypeMatch
+		}
+	}
+	result.Candidate = candidate
+
+	p, c := result.Production, result.Candidate
+	if _, err := execWithRetry(s.db, `INSERT INTO shadow_results (filename, candidate_model, cleanup_prompt_version, metadata_prompt_version, production_model, production_cleanup_version, production_metadata_version,
+    production_transcript, shadow_transcript, production_address, shadow_address, production_call_type, shadow_call_type, transcript_divergence, address_match, call_type_match, production_ms, shadow_ms, error, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		result.Filename, c.Model, c.CleanupVersion, c.MetadataVersion, p.Model, p.CleanupVersion, p.MetadataVersion,
+		p.Transcript, c.Transcript, p.Address, c.Address, p.CallType, c.CallType, result.TranscriptDivergence, result.AddressMatch, result.CallTypeMatch, p.DurationMS, c.DurationMS, result.Error, time.Now().UTC()); err != nil {
+		log.Printf("store shadow result for %s: %v", job.filename, err)
+	}
+}
+
+// handleShadowReport serves /api/admin/shadow: per-candidate agreement with
+// production over the last ?days= (default 30). Admin only.
+func (s *server) handleShadowReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	days := parseIntDefault(r.URL.Query().Get("days"), defaultShadowReport)
+	if days <= 0 {
+		days = defaultShadowReport
+	}
+	resp := shadowReportResponse{Enabled: s.shadowJobs != nil, Percent: s.cfg.ShadowPercent, Days: days, Candidates: []shadowSummary{}}
+	if resp.Enabled {
+		resp.Candidate = &shadowOutcome{Model: s.cfg.ShadowModel, CleanupVersion: s.shadowPins[prompts.KindCleanup].Version, MetadataVersion: s.shadowPins[prompts.KindMetadata].Version}
+	}
+	rows, err := s.db.QueryContext(r.Context(), `SELECT candidate_model, cleanup_prompt_version, metadata_prompt_version, production_model,
+    COUNT(*), SUM(CASE WHEN error != '' THEN 1 ELSE 0 END),
+    AVG(transcript_divergence), AVG(address_match), COUNT(address_match), AVG(call_type_match),
+    AVG(CASE WHEN error = '' THEN production_ms END), AVG(CASE WHEN error = '' THEN shadow_ms END)
+FROM shadow_results WHERE created_at >= ?
+GROUP BY candidate_model, cleanup_prompt_version, metadata_prompt_version, production_model
+ORDER BY MAX(created_at) DESC`, time.Now().UTC().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("shadow report failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sum shadowSummary
+		var divergence, address, callType, prodMS, candMS sql.NullFloat64
+		if err := rows.Scan(&sum.CandidateModel, &sum.CleanupPromptVersion, &sum.MetadataPromptVersion, &sum.ProductionModel,
+			&sum.Samples, &sum.Errors, &divergence, &address, &sum.AddressCompared, &callType, &prodMS, &candMS); err != nil {
+			log.Printf("shadow report scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		sum.MeanTranscriptDivergence = nullFloatPtr(divergence)
+		sum.AddressAgreement = nullFloatPtr(address)
+		sum.CallTypeAgreement = nullFloatPtr(callType)
+		sum.MeanProductionMS = nullFloatPtr(prodMS)
+		sum.MeanCandidateMS = nullFloatPtr(candMS)
+		resp.Candidates = append(resp.Candidates, sum)
+	}
+	respondJSON(w, resp)
+}
+
+// handleShadowResults serves /api/admin/shadow/results: the latest individual
+// comparisons, optionally for one ?filename=. Admin only.
+func (s *server) handleShadowResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	limit := parseIntDefault(r.URL.Query().Get("limit"), defaultShadowResults)
+	if limit <= 0 || limit > maxShadowResultsLimit {
+		limit = defaultShadowResults
+	}
+	query := `SELECT id, filename, candidate_model, cleanup_prompt_version, metadata_prompt_version, production_model, production_cleanup_version, production_metadata_version,
+    production_transcript, shadow_transcript, production_address, shadow_address, production_call_type, shadow_call_type, transcript_divergence, address_match, call_type_match,
+    production_ms, shadow_ms, error, created_at FROM shadow_results`
+	args := []interface{}{}
+	if filename := strings.TrimSpace(r.URL.Query().Get("filename")); filename != "" {
+		query += ` WHERE filename = ?`
+		args = append(args, filename)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		log.Printf("shadow results failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	results := []shadowResult{}
+	for rows.Next() {
+		var res shadowResult
+		var divergence sql.NullFloat64
+		var address, callType sql.NullBool
+		p, c := &res.Production, &res.Candidate
+		if err := rows.Scan(&res.ID, &res.Filename, &c.Model, &c.CleanupVersion, &c.MetadataVersion, &p.Model, &p.CleanupVersion, &p.MetadataVersion,
+			&p.Transcript, &c.Transcript, &p.Address, &c.Address, &p.CallType, &c.CallType, &divergence, &address, &callType,
+			&p.DurationMS, &c.DurationMS, &res.Error, &res.CreatedAt); err != nil {
+			log.Printf("shadow results scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		res.TranscriptDivergence = nullFloatPtr(divergence)
+		if address.Valid {
+			res.AddressMatch = &address.Bool
+		}
+		if callType.Valid {
+			res.CallTypeMatch = &callType.Bool
+		}
+		results = append(results, res)
+	}
+	respondJSON(w, map[string]interface{}{"results": results})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alert_framework/sharetoken"
+)
+
+const (
+	defaultShareHours = 72
+	maxShareHours     = 30 * 24
+)
+
+type shareLinkRequest struct {
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+type shareLinkResponse struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	JSONURL   string    `json:"json_url"`
+	AudioURL  string    `json:"audio_url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// sharedCall is what a share link shows: the call as an admin sees it, with
+// the audio served through the link.
+type sharedCall struct {
+	transcriptionResponse
+	SharedUntil time.Time `json:"shared_until"`
+}
+
+var sharedCallPage = template.Must(template.New("shared").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.PrettyTitle}}</title>
+<style>
+body{margin:0;font:16px/1.5 system-ui,sans-serif;color:#e8eeff;background:#0b1021}
+main{max-width:760px;margin:0 auto;padding:24px}
+h1{font-size:22px;margin:0 0 8px}
+audio{width:100%;margin:8px 0}
+p{white-space:pre-wrap}
+small{color:#a5afc5}
+</style>
+</head>
+<body>
+<main>
+<h1>{{.PrettyTitle}}</h1>
+{{if .Town}}<small>{{.Town}}</small>{{end}}
+{{if .AudioURL}}<audio controls preload="none" src="{{.AudioURL}}"></audio>{{end}}
+{{with .Transcript}}<p>{{.}}</p>{{else}}<p>Transcript not ready yet.</p>{{end}}
+<small>Shared link, valid until {{.SharedUntil.Format "2006-01-02 15:04 MST"}}.</small>
+</main>
+</body>
+</html>
+`))
+
+// handleShareLink serves POST /api/transcription/{file}/share (admin): a
+// signed link that lets someone without access read that one call, transcript
+// and audio, until it expires. Links are not stored; changing SHARE_SECRET
+// revokes them all.
+func (s *server) handleShareLink(w http.ResponseWriter, r *http.Request, filename string) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if s.cfg.ShareSecret == "" {
+		http.Error(w, "SHARE_SECRET is not set", http.StatusServiceUnavailable)
+		return
+	}
+	var req shareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = defaultShareHours
+	}
+	if req.ExpiresInHours < 0 || req.ExpiresInHours > maxShareHours {
+		http.Error(w, fmt.Sprintf("expires_in_hours must be between 1 and %d", maxShareHours), http.StatusBadRequest)
+		return
+	}
+	t, err := s.getTranscription(filename)
+	if err != nil || t.DeletedAt != nil {
+		http.NotFound(w, r)
+		return
+	}
+	now := time.Now().UTC()
+	expires := now.Add(time.Duration(req.ExpiresInHours) * time.Hour).Truncate(time.Second)
+	token, err := sharetoken.Sign(s.cfg.ShareSecret, sharetoken.Claims{File: t.Filename, Issued: now.Unix(), Expires: expires.Unix()})
+	if err != nil {
+		http.Error(w, "sign failed", http.StatusInternalServerError)
+		return
+	}
+	link := s.resolveBaseURL(r) + "/shared/" + token
+	noteAudit(r, "call.share", t.Filename, nil, map[string]interface{}{"expires_at": expires})
+	respondJSON(w, shareLinkResponse{
+		Token:     token,
+		URL:       link,
+		JSONURL:   link + "?format=json",
+		AudioURL:  link + "/audio",
+		ExpiresAt: expires,
+	})
+}
+
+// handleSharedCall serves a share link: GET /shared/{token} is a page with
+// the call's audio and full transcript (?format=json for the record), and
+// /shared/{token}/audio is the recording. Restricted calls are shown too; the
+// admin who shared the link decided that.
+func (s *server) handleSharedCall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cfg.ShareSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/shared/"), "/")
+	token, audio := strings.CutSuffix(rest, "/audio")
+	claims, err := sharetoken.Parse(s.cfg.ShareSecret, token, time.Now())
+	if err != nil {
+		msg := "invalid share link"
+		if errors.Is(err, sharetoken.ErrExpired) {
+			msg = "share link expired"
+		}
+		http.Error(w, msg, http.StatusForbidden)
+		return
+	}
+	t, err := s.getTranscription(claims.File)
+	if err != nil || t.DeletedAt != nil {
+		http.NotFound(w, r)
+		return
+	}
+	until := time.Unix(claims.Expires, 0).UTC()
+	// The token is the credential: keep it out of caches, search indexes and
+	// the Referer sent to anything the page links to.
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if audio {
+		if !hasAudio(*t) {
+			http.NotFound(w, r)
+			return
+		}
+		name := filepath.Base(s.audioFilename(*t))
+		sourcePath := filepath.Join(s.cfg.CallsDir, name)
+		if _, err := os.Stat(sourcePath); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", audioMIMEType(name))
+		http.ServeFile(w, r, sourcePath)
+		return
+	}
+	link := s.resolveBaseURL(r) + "/shared/" + token
+	resp := sharedCall{transcriptionResponse: s.toResponse(*t, s.resolveBaseURL(r)), SharedUntil: until}
+	if hasAudio(*t) {
+		resp.AudioURL = link + "/audio"
+	}
+	if r.URL.Query().Get("format") == "json" {
+		respondJSON(w, resp)
+		return
+	}
+	resp.PrettyTitle = fallbackEmpty(resp.PrettyTitle, t.Filename)
+	resp.Transcript = nil
+	if text := pickTranscript(t); text != nil && strings.TrimSpace(*text) != "" {
+		trimmed := strings.TrimSpace(*text)
+		resp.Transcript = &trimmed
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; media-src 'self'")
+	if err := sharedCallPage.Execute(w, resp); err != nil {
+		log.Printf("shared call page render failed for %s: %v", t.Filename, err)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"alert_framework/config"
+	"alert_framework/redis"
+)
+
+const (
+	// sharedPullWait is how long one blocking pop waits for a job; it bounds
+	// how long shutdown waits on an idle puller.
+	sharedPullWait = 5 * time.Second
+	// sharedPullIdle is how often a worker with no free slot checks again.
+	sharedPullIdle  = 250 * time.Millisecond
+	sharedRetryWait = 2 * time.Second
+	sharedOpTimeout = 5 * time.Second
+)
+
+// sharedJob is a transcription job as it travels between instances. A
+// queue.Job carries closures, so only what the worker that pulls it needs to
+// rebuild the processJob is sent.
+type sharedJob struct {
+	Filename         string               `json:"filename"`
+	Source           string               `json:"source"`
+	SendGroupMe      bool                 `json:"send_groupme"`
+	Force            bool                 `json:"force"`
+	Options          TranscriptionOptions `json:"options"`
+	PendingAlertSent bool                 `json:"pending_alert_sent,omitempty"`
+	EnqueuedAt       time.Time            `json:"enqueued_at"`
+	Producer         string               `json:"producer"`
+}
+
+// sharedQueue hands transcription jobs to worker instances through a Redis
+// list, so workers on other hosts can take jobs the api instance accepted.
+// Producers LPUSH onto the key. Each worker moves a job with BRPOPLPUSH into
+// its own processing list, key:processing:INSTANCE_ID, and removes it once
+// the job has finished, so the job of a worker that died is still in Redis
+// and goes back on the queue when that instance starts again.
+type sharedQueue struct {
+	client     *redis.Client
+	puller     *redis.Client // the blocking pop holds its connection
+	key        string
+	processing string
+	pushed     atomic.Int64
+	pulled     atomic.Int64
+
+	mu        sync.Mutex
+	lastErr   string
+	lastErrAt time.Time
+}
+
+// SharedQueueDebug reports the shared queue in /debug/queue and
+// /api/status.
+type SharedQueueDebug struct {
+	Backend     string     `json:"backend"`
+	Key         string     `json:"key"`
+	Reachable   bool       `json:"reachable"`
+	Pending     int64      `json:"pending"`
+	Processing  int64      `json:"processing"`
+	Pushed      int64      `json:"pushed"`
+	Pulled      int64      `json:"pulled"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+func newSharedQueue(cfg config.Config) (*sharedQueue, error) {
+	client, err := redis.New(cfg.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+	puller, err := redis.New(cfg.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &sharedQueue{
+		client:     client,
+		puller:     puller,
+		key:        cfg.RedisQueueKey,
+		processing: cfg.RedisQueueKey + ":processing:" + cfg.InstanceID,
+	}, nil
+}
+
+func (q *sharedQueue) noteError(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lastErr, q.lastErrAt = err.Error(), time.Now().UTC()
+}
+
+func (q *sharedQueue) push(ctx context.Context, job sharedJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sharedOpTimeout)
+	defer cancel()
+	if _, err := q.client.LPush(ctx, q.key, string(data)); err != nil {
+		q.noteError(err)
+		return err
+	}
+	q.pushed.Add(1)
+	return nil
+}
+
+// ack drops a finished job from this worker's processing list.
+func (q *sharedQueue) ack(raw string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedOpTimeout)
+	defer cancel()
+	if _, err := q.client.LRem(ctx, q.processing, 1, raw); err != nil {
+		q.noteError(err)
+		log.Printf("shared queue ack failed: %v", err)
+	}
+}
+
+// giveBack returns a job this worker took but will not run to the front of
+// the queue.
+func (q *sharedQueue) giveBack(raw string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedOpTimeout)
+	defer cancel()
+	if _, err := q.client.RPush(ctx, q.key, raw); err != nil {
+		q.noteError(err)
+		log.Printf("shared queue give-back failed; job stays in %s: %v", q.processing, err)
+		return
+	}
+	if _, err := q.client.LRem(ctx, q.processing, 1, raw); err != nil {
+		q.noteError(err)
+		log.Printf("shared queue ack failed: %v", err)
+	}
+}
+
+// requeueOrphans puts back jobs left in this instance's processing list by a
+// run that did not finish them.
+func (q *sharedQueue) requeueOrphans(ctx context.Context) {
+	n := 0
+	for ctx.Err() == nil {
+		if _, err := q.client.RPopLPush(ctx, q.processing, q.key); err != nil {
+			if !errors.Is(err, redis.ErrNil) {
+				q.noteError(err)
+				log.Printf("shared queue orphan requeue failed: %v", err)
+			}
+			break
+		}
+		n++
+	}
+	if n > 0 {
+		log.Printf("requeued %d unfinished shared jobs from %s", n, q.processing)
+	}
+}
+
+func (q *sharedQueue) debug(ctx context.Context) *SharedQueueDebug {
+	ctx, cancel := context.WithTimeout(ctx, sharedOpTimeout)
+	defer cancel()
+	d := &SharedQueueDebug{Backend: "redis", Key: q.key, Pushed: q.pushed.Load(), Pulled: q.pulled.Load()}
+	var err error
+	if d.Pending, err = q.client.LLen(ctx, q.key); err == nil {
+		d.Processing, err = q.client.LLen(ctx, q.processing)
+	}
+	if err != nil {
+		q.noteError(err)
+	}
+	d.Reachable = err == nil
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.lastErr != "" {
+		at := q.lastErrAt
+		d.LastError, d.LastErrorAt = q.lastErr, &at
+	}
+	return d
+}
+
+// sharedQueueDebug is nil when jobs go through the in-process queue only.
+func (s *server) sharedQueueDebug(ctx context.Context) *SharedQueueDebug {
+	if s.shared == nil {
+		return nil
+	}
+	return s.shared.debug(ctx)
+}
+
+// canEnqueue reports whether this instance can hand out transcription jobs:
+// it runs workers, or it shares a queue with instances that do.
+func (s *server) canEnqueue() bool {
+	return s.queue != nil || s.shared != nil
+}
+
+// pushShared hands job to whichever worker instance is free first.
+func (s *server) pushShared(ctx context.Context, job processJob) bool {
+	err := s.shared.push(ctx, sharedJob{
+		Filename:         job.filename,
+		Source:           job.source,
+		SendGroupMe:      job.sendGroupMe,
+		Force:            job.force,
+		Options:          job.options,
+		PendingAlertSent: job.pendingAlertSent,
+		EnqueuedAt:       job.enqueuedAt,
+		Producer:         s.cfg.InstanceID,
+	})
+	if err != nil {
+		log.Printf("shared queue push failed for %s: %v", job.filename, err)
+		return false
+	}
+	return true
+}
+
+// pullSharedJobs feeds this worker's pool from the shared queue. It takes a
+// job only while a worker is free, so jobs wait in Redis for whichever
+// instance frees up first instead of queueing behind this one, and it takes
+// none while the local queue is paused.
+func (s *server) pullSharedJobs(ctx context.Context) {
+	q := s.shared
+	q.requeueOrphans(ctx)
+	for ctx.Err() == nil {
+		if stats := s.queue.Stats(); stats.Paused || stats.Active+stats.Length >= stats.WorkerCount {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(sharedPullIdle):
+			}
+			continue
+		}
+		raw, err := q.puller.BRPopLPush(ctx, q.key, q.processing, sharedPullWait)
+		if errors.Is(err, redis.ErrNil) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			q.noteError(err)
+			log.Printf("shared queue pull failed: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(sharedRetryWait):
+			}
+			continue
+		}
+		q.pulled.Add(1)
+		var job sharedJob
+		if err := json.Unmarshal([]byte(raw), &job); err != nil || job.Filename == "" {
+			log.Printf("dropping malformed shared job %q: %v", truncateText(raw, 200), err)
+			q.ack(raw)
+			continue
+		}
+		meta, pretty, publicURL, baseURL := s.buildJobContext(job.Filename, job.Source)
+		payload := processJob{
+			filename:         job.Filename,
+			source:           job.Source,
+			sendGroupMe:      job.SendGroupMe,
+			force:            job.Force,
+			options:          job.Options,
+			meta:             meta,
+			prettyTitle:      pretty,
+			publicURL:        publicURL,
+			baseURL:          baseURL,
+			pendingAlertSent: job.PendingAlertSent,
+			enqueuedAt:       job.EnqueuedAt,
+		}
+		// A job cut off by shutdown goes back for another instance to run.
+		onFinish := func(err error) {
+			if err != nil && ctx.Err() != nil {
+				q.giveBack(raw)
+				return
+			}
+			q.ack(raw)
+		}
+		if enqueued, _ := s.enqueueLocal(ctx, payload, onFinish); !enqueued {
+			q.giveBack(raw)
+		}
+	}
+}
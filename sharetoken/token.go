@@ -0,0 +1,79 @@
+// Package sharetoken issues and checks the signed, expiring tokens behind
+// per-call share links. A token is
+//
+//	base64url(claims JSON) + "." + base64url(HMAC-SHA256(secret, claims JSON))
+//
+// and names the one call it grants, so it needs no server-side storage.
+// Rotating the secret revokes every link issued with it.
+package sharetoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed    = errors.New("sharetoken: malformed token")
+	ErrBadSignature = errors.New("sharetoken: signature mismatch")
+	ErrExpired      = errors.New("sharetoken: token expired")
+)
+
+// Claims grant read access to the call File until Expires (Unix seconds).
+// Every share token expires.
+type Claims struct {
+	File    string `json:"f"`
+	Issued  int64  `json:"iat"`
+	Expires int64  `json:"exp"`
+}
+
+// Sign encodes c and signs it with secret.
+func Sign(secret string, c Claims) (string, error) {
+	if c.File == "" || c.Expires == 0 {
+		return "", errors.New("sharetoken: file and expiry are required")
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(mac(secret, payload)), nil
+}
+
+// Parse checks token against secret and returns its claims.
+func Parse(secret, token string, now time.Time) (Claims, error) {
+	var c Claims
+	encPayload, encSig, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok {
+		return c, ErrMalformed
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(encPayload)
+	if err != nil {
+		return c, ErrMalformed
+	}
+	sig, err := enc.DecodeString(encSig)
+	if err != nil {
+		return c, ErrMalformed
+	}
+	if !hmac.Equal(sig, mac(secret, payload)) {
+		return c, ErrBadSignature
+	}
+	if err := json.Unmarshal(payload, &c); err != nil || c.File == "" {
+		return c, ErrMalformed
+	}
+	if now.Unix() >= c.Expires {
+		return c, ErrExpired
+	}
+	return c, nil
+}
+
+func mac(secret string, payload []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(payload)
+	return h.Sum(nil)
+}
@@ -0,0 +1,49 @@
+package sharetoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignParseRoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token, err := Sign("secret", Claims{File: "Newton_Fire_2024_01_02_03_04_05.mp3", Issued: now.Unix(), Expires: now.Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("sign failed: %v", err)
+	}
+	c, err := Parse("secret", token, now)
+	if err != nil {
+		t.Fatalf("expected valid token, got %v", err)
+	}
+	if c.File != "Newton_Fire_2024_01_02_03_04_05.mp3" {
+		t.Fatalf("unexpected claims: %+v", c)
+	}
+	if _, err := Parse("other", token, now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected signature mismatch for wrong secret, got %v", err)
+	}
+}
+
+func TestParseRejectsTamperedAndExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token, _ := Sign("secret", Claims{File: "a.mp3", Issued: now.Unix(), Expires: now.Add(time.Hour).Unix()})
+	other, _ := Sign("secret", Claims{File: "b.mp3", Issued: now.Unix(), Expires: now.Add(time.Hour).Unix()})
+	payload, _, _ := strings.Cut(other, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	if _, err := Parse("secret", payload+"."+sig, now); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected signature mismatch for swapped claims, got %v", err)
+	}
+	if _, err := Parse("secret", token, now.Add(time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected expired token, got %v", err)
+	}
+	if _, err := Parse("secret", "not-a-token", now); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected malformed token, got %v", err)
+	}
+}
+
+func TestSignRequiresExpiry(t *testing.T) {
+	if _, err := Sign("secret", Claims{File: "a.mp3"}); err == nil {
+		t.Fatal("expected an error for a token without expiry")
+	}
+}
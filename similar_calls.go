@@ -0,0 +1,304 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultSimilarLimit = 5
+	maxSimilarLimit     = 50
+	maxSnippetRunes     = 200
+)
+
+// similarQuery holds the filters accepted by the similar-calls endpoint.
+type similarQuery struct {
+	limit    int
+	minScore float64
+	window   time.Duration
+	callType string
+	after    *similarCursor
+}
+
+// similarCursor marks the last result of a page. Results are ordered by
+// score then filename, so a page resumes strictly after this position even
+// when new calls are embedded between requests.
+type similarCursor struct {
+	score    float64
+	filename string
+}
+
+func (c similarCursor) encode() string {
+	raw := strconv.FormatFloat(c.score, 'g', -1, 64) + "|" + c.filename
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSimilarCursor(raw string) (*similarCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	scoreText, filename, ok := strings.Cut(string(data), "|")
+	if !ok || filename == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	score, err := strconv.ParseFloat(scoreText, 64)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &similarCursor{score: score, filename: filename}, nil
+}
+
+// before reports whether a ranks ahead of b.
+func (a similarCursor) before(b similarCursor) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	return a.filename < b.filename
+}
+
+func parseSimilarQuery(r *http.Request) (similarQuery, error) {
+	q := r.URL.Query()
+	out := similarQuery{limit: defaultSimilarLimit}
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxSimilarLimit {
+			return out, fmt.Errorf("limit must be between 1 and %d", maxSimilarLimit)
+		}
+		out.limit = v
+	}
+	if raw := strings.TrimSpace(q.Get("min_score")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < -1 || v > 1 {
+			return out, errors.New("min_score must be between -1 and 1")
+		}
+		out.minScore = v
+	}
+	_, out.window = normalizeWindowName(q.Get("window"), "all")
+	out.callType = strings.ToLower(strings.TrimSpace(q.Get("call_type")))
+	if raw := strings.TrimSpace(q.Get("cursor")); raw != "" {
+		c, err := decodeSimilarCursor(raw)
+		if err != nil {
+			return out, err
+		}
+		out.after = c
+	}
+	return out, nil
+}
+
+// handleSimilar ranks calls by embedding similarity to filename. Each result
+// carries the best-matching sentence of the other transcript and the tags
+// both calls share. When more results remain, X-Next-Cursor holds the cursor
+// for the following page.
+func (s *server) handleSimilar(w http.ResponseWriter, r *http.Request, filename string) {
+	opts, err := parseSimilarQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := s.getTranscription(filename)
+	if err != nil || (t != nil && !s.visibleTo(r, *t)) {
+		http.NotFound(w, r)
+		return
+	}
+	if t == nil || t.CleanTranscript == nil {
+		respondJSON(w, []similar{})
+		return
+	}
+	emb, err := s.loadEmbedding(filename)
+	if err != nil || len(emb) == 0 {
+		respondJSON(w, []similar{})
+		return
+	}
+
+	ranked, err := s.rankSimilar(filename, emb, opts)
+	if err != nil {
+		log.Printf("similar query failed for %s: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	source := *t
+	if !isAdminRequest(r) {
+		source = s.publicRecord(source)
+	}
+	sourceText := derefString(pickTranscript(&source), "")
+	sourceTags := stripVolunteerTags(parseRecognizedTownList(t.TagsJSON))
+
+	// Candidates are loaded a page at a time so restricted calls hidden from
+	// the caller can be skipped without reading every transcript.
+	sims := make([]similar, 0, opts.limit)
+	var last similarCursor
+	i := 0
+	for i < len(ranked) && len(sims) < opts.limit {
+		end := i + opts.limit
+		if end > len(ranked) {
+			end = len(ranked)
+		}
+		records, err := s.loadSimilarRecords(r, ranked[i:end])
+		if err != nil {
+			log.Printf("similar load failed for %s: %v", filename, err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		for ; i < end && len(sims) < opts.limit; i++ {
+			c := ranked[i]
+			last = c
+			other, ok := records[c.filename]
+			if !ok || !s.visibleTo(r, other) {
+				continue
+			}
+			if !isAdminRequest(r) {
+				other = s.publicRecord(other)
+			}
+			sims = append(sims, similar{
+				Filename:   c.filename,
+				Score:      c.score,
+				CallType:   derefString(other.CallType, ""),
+				Snippet:    matchedSnippet(sourceText, derefString(pickTranscript(&other), "")),
+				SharedTags: sharedTags(sourceTags, stripVolunteerTags(parseRecognizedTownList(other.TagsJSON))),
+			})
+		}
+	}
+	if i < len(ranked) {
+		w.Header().Set("X-Next-Cursor", last.encode())
+	}
+	respondJSON(w, sims)
+}
+
+// rankSimilar scores every other embedded call matching opts against emb and
+// returns those past the cursor and at or above min_score, best first.
+func (s *server) rankSimilar(filename string, emb []float64, opts similarQuery) ([]similarCursor, error) {
+	query := `SELECT filename, embedding FROM transcriptions WHERE filename != ? AND embedding IS NOT NULL AND deleted_at IS NULL`
+	args := []interface{}{filename}
+	if opts.window > 0 {
+		query += ` AND effective_ts >= ?`
+		args = append(args, time.Now().UTC().Add(-opts.window))
+	}
+	if opts.callType != "" {
+		query += ` AND lower(coalesce(call_type,'')) = ?`
+		args = append(args, opts.callType)
+	}
+	rows, err := queryWithRetry(s.db, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ranked []similarCursor
+	for rows.Next() {
+		var name string
+		var embText sql.NullString
+		if err := rows.Scan(&name, &embText); err != nil {
+			continue
+		}
+		otherEmb, _ := parseEmbedding(embText.String)
+		if len(otherEmb) == 0 {
+			continue
+		}
+		c := similarCursor{score: cosineSimilarity(emb, otherEmb), filename: name}
+		if c.score < opts.minScore || (opts.after != nil && !opts.after.before(c)) {
+			continue
+		}
+		ranked = append(ranked, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].before(ranked[j]) })
+	return ranked, nil
+}
+
+func (s *server) loadSimilarRecords(r *http.Request, page []similarCursor) (map[string]transcription, error) {
+	placeholders := make([]string, len(page))
+	args := make([]interface{}, len(page))
+	for i, c := range page {
+		placeholders[i] = "?"
+		args[i] = c.filename
+	}
+	records, err := s.store.Select(r.Context(), "WHERE filename IN ("+strings.Join(placeholders, ",")+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]transcription, len(records))
+	for _, t := range records {
+		out[t.Filename] = t
+	}
+	return out, nil
+}
+
+// matchedSnippet returns the sentence of other that shares the most words
+// with source, trimmed to maxSnippetRunes.
+func matchedSnippet(source, other string) string {
+	words := make(map[string]bool)
+	for _, w := range snippetWords(source) {
+		words[w] = true
+	}
+	best, bestHits := "", 0
+	for _, sentence := range splitSentences(other) {
+		hits := 0
+		seen := make(map[string]bool)
+		for _, w := range snippetWords(sentence) {
+			if words[w] && !seen[w] {
+				seen[w] = true
+				hits++
+			}
+		}
+		if hits > bestHits {
+			best, bestHits = sentence, hits
+		}
+	}
+	if runes := []rune(best); len(runes) > maxSnippetRunes {
+		best = strings.TrimSpace(string(runes[:maxSnippetRunes])) + "…"
+	}
+	return best
+}
+
+func splitSentences(text string) []string {
+	var out []string
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool {
+		return r == '.' || r == '!' || r == '?' || r == '\n'
+	}) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// snippetWords lowercases text into words, dropping short filler such as
+// "a", "to" and "of" that would match almost any sentence.
+func snippetWords(text string) []string {
+	var out []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) >= 3 {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// sharedTags returns the tags of a that also appear in b, ignoring case.
+func sharedTags(a, b []string) []string {
+	have := make(map[string]bool, len(b))
+	for _, tag := range b {
+		have[strings.ToLower(tag)] = true
+	}
+	var out []string
+	for _, tag := range a {
+		if have[strings.ToLower(tag)] {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// The alert latency SLO: SLO_TARGET of calls are alerted within
+// SLO_LATENCY_SEC of their recording arriving. Arrival is the recording's
+// mtime when the watcher or a remote source queues it for an alert; the alert
+// is the first successful GroupMe post, including the audio-only alert sent
+// during an OpenAI outage. A call that is still not alerted once the objective
+// has passed counts as late, so a stalled pipeline burns budget before any
+// alert goes out. Calls whose alert is withheld on purpose (restricted calls,
+// suppress routing rules, the alert ledger) leave the SLO.
+
+const (
+	sloCheckInterval = time.Minute
+	// sloMinCalls is how many calls the long window needs before a burn rate
+	// can raise an alert.
+	sloMinCalls = 5
+)
+
+// sloWindows are the windows reported on /api/status. The burn-rate alert
+// needs both the short and long window over SLO_BURN_RATE_ALERT, so a single
+// slow call does not page and an old incident stops paging once it is over.
+var sloWindows = []struct {
+	name string
+	span time.Duration
+}{
+	{"10m", 10 * time.Minute},
+	{"1h", time.Hour},
+	{"24h", 24 * time.Hour},
+}
+
+const (
+	sloShortWindow = "10m"
+	sloLongWindow  = "1h"
+)
+
+func migrateAddAlertLatency(db *sql.DB) error {
+	if err := addColumnIfMissing(db, "transcriptions", "arrived_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "transcriptions", "alerted_at", "DATETIME"); err != nil {
+		return err
+	}
+	_, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_transcriptions_arrived_at ON transcriptions(arrived_at)`)
+	return err
+}
+
+func downAlertLatency(db *sql.DB) error {
+	if _, err := execWithRetry(db, `DROP INDEX IF EXISTS idx_transcriptions_arrived_at`); err != nil {
+		return err
+	}
+	if err := dropColumnIfExists(db, "transcriptions", "alerted_at"); err != nil {
+		return err
+	}
+	return dropColumnIfExists(db, "transcriptions", "arrived_at")
+}
+
+// recordArrival starts the SLO clock for a call queued for an alert. Forced
+// enqueues are reprocesses and uploads, which the SLO does not cover, and a
+// call that already has an arrival keeps it when it is queued again.
+func (s *server) recordArrival(job processJob, path string) {
+	if job.force || !job.sendGroupMe {
+		return
+	}
+	arrived := job.enqueuedAt
+	if info, err := os.Stat(path); err == nil && info.ModTime().Before(arrived) {
+		arrived = info.ModTime().UTC()
+	}
+	if _, err := execWithRetry(s.db, `UPDATE transcriptions SET arrived_at = COALESCE(arrived_at, ?) WHERE filename = ?`, arrived, job.filename); err != nil {
+		log.Printf("record arrival for %s failed: %v", job.filename, err)
+	}
+}
+
+// markAlerted stops the SLO clock at the call's first alert.
+func (s *server) markAlerted(filename string) {
+	var arrived sql.NullTime
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&arrived)
+	}, `SELECT arrived_at FROM transcriptions WHERE filename = ? AND alerted_at IS NULL`, filename)
+	if err != nil {
+		// No row means the call was alerted before; anything else is logged.
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("alert latency lookup for %s failed: %v", filename, err)
+		}
+		return
+	}
+	now := time.Now().UTC()
+	if _, err := execWithRetry(s.db, `UPDATE transcriptions SET alerted_at = ? WHERE filename = ? AND alerted_at IS NULL`, now, filename); err != nil {
+		log.Printf("record alert time for %s failed: %v", filename, err)
+		return
+	}
+	if arrived.Valid && s.metrics != nil {
+		s.metrics.RecordAlert(now.Sub(arrived.Time), s.sloObjective())
+	}
+}
+
+// excludeFromSLO drops a call whose alert was withheld on purpose from the
+// SLO, unless it was already alerted.
+func (s *server) excludeFromSLO(filename string) {
+	if _, err := execWithRetry(s.db, `UPDATE transcriptions SET arrived_at = NULL WHERE filename = ? AND alerted_at IS NULL`, filename); err != nil {
+		log.Printf("exclude %s from SLO failed: %v", filename, err)
+	}
+}
+
+func (s *server) sloObjective() time.Duration {
+	return time.Duration(s.liveConfig().SLOLatencySec) * time.Second
+}
+
+type statusSLO struct {
+	Target        float64           `json:"target"`
+	LatencySec    int               `json:"latency_sec"`
+	BurnRateAlert float64           `json:"burn_rate_alert"`
+	Burning       bool              `json:"burning"`
+	Windows       []sloWindowStatus `json:"windows"`
+	SinceStart    *sloSinceStart    `json:"since_start,omitempty"`
+}
+
+type sloWindowStatus struct {
+	Window     string   `json:"window"`
+	Calls      int      `json:"calls"`
+	Late       int      `json:"late"`
+	Pending    int      `json:"pending"`
+	Compliance *float64 `json:"compliance"`
+	BurnRate   float64  `json:"burn_rate"`
+}
+
+// sloSinceStart is the in-memory alert latency kept by the metrics package.
+type sloSinceStart struct {
+	WithinObjective int64     `json:"within_objective"`
+	Late            int64     `json:"late"`
+	Latency         ingestLag `json:"latency"`
+}
+
+// evaluateSLO measures every window from the arrival and alert times stored
+// on calls. Calls younger than the objective with no alert yet are pending
+// and not counted.
+func (s *server) evaluateSLO(ctx context.Context, now time.Time) (statusSLO, error) {
+	cfg := s.liveConfig()
+	objective := time.Duration(cfg.SLOLatencySec) * time.Second
+	out := statusSLO{Target: cfg.SLOTarget, LatencySec: cfg.SLOLatencySec, BurnRateAlert: cfg.SLOBurnRateAlert}
+	longest := sloWindows[len(sloWindows)-1].span
+	rows, err := s.db.QueryContext(ctx, `SELECT arrived_at, alerted_at FROM transcriptions WHERE arrived_at >= ?`, now.Add(-longest))
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	windows := make([]sloWindowStatus, len(sloWindows))
+	for i, w := range sloWindows {
+		windows[i].Window = w.name
+	}
+	for rows.Next() {
+		var arrived time.Time
+		var alerted sql.NullTime
+		if err := rows.Scan(&arrived, &alerted); err != nil {
+			return out, err
+		}
+		var late, pending bool
+		switch {
+		case alerted.Valid:
+			late = alerted.Time.Sub(arrived) > objective
+		case now.Sub(arrived) > objective:
+			late = true
+		default:
+			pending = true
+		}
+		for i, w := range sloWindows {
+			if arrived.Before(now.Add(-w.span)) {
+				continue
+			}
+			if pending {
+				windows[i].Pending++
+				continue
+			}
+			windows[i].Calls++
+			if late {
+				windows[i].Late++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return out, err
+	}
+	budget := 1 - cfg.SLOTarget
+	for i := range windows {
+		w := &windows[i]
+		if w.Calls == 0 {
+			continue
+		}
+		lateRate := float64(w.Late) / float64(w.Calls)
+		compliance := math.Round((1-lateRate)*10000) / 10000
+		w.Compliance = &compliance
+		w.BurnRate = math.Round(lateRate/budget*100) / 100
+	}
+	out.Windows = windows
+	out.Burning = sloBurning(out)
+	if s.metrics != nil {
+		snap := s.metrics.AlertSnapshot()
+		out.SinceStart = &sloSinceStart{WithinObjective: snap.WithinObjective, Late: snap.Late, Latency: toIngestLag(snap.Latency)}
+	}
+	return out, nil
+}
+
+func (slo statusSLO) window(name string) sloWindowStatus {
+	for _, w := range slo.Windows {
+		if w.Window == name {
+			return w
+		}
+	}
+	return sloWindowStatus{Window: name}
+}
+
+// sloBurning reports whether both alert windows burn the error budget at
+// SLO_BURN_RATE_ALERT times the sustainable rate or faster.
+func sloBurning(slo statusSLO) bool {
+	if slo.BurnRateAlert <= 0 {
+		return false
+	}
+	long, short := slo.window(sloLongWindow), slo.window(sloShortWindow)
+	return long.Calls >= sloMinCalls && long.BurnRate >= slo.BurnRateAlert && short.Calls > 0 && short.BurnRate >= slo.BurnRateAlert
+}
+
+// sloMonitor remembers whether the burn-rate alert has fired so the admin
+// channel hears about a breach and its recovery once each.
+type sloMonitor struct {
+	mu      sync.Mutex
+	burning bool
+	since   time.Time
+}
+
+// startSLOMonitor checks the alert latency SLO every minute and posts to the
+// watcher alert channel (WATCHER_ALERT_GROUPME_BOT_ID/WATCHER_ALERT_WEBHOOK_URL)
+// when it starts and stops burning too fast.
+func (s *server) startSLOMonitor(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(sloCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+				s.checkSLO(ctx)
+			}
+		}
+	}()
+}
+
+func (s *server) checkSLO(ctx context.Context) {
+	now := time.Now().UTC()
+	slo, err := s.evaluateSLO(ctx, now)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("slo check failed: %v", err)
+		}
+		return
+	}
+	long := slo.window(sloLongWindow)
+	s.slo.mu.Lock()
+	defer s.slo.mu.Unlock()
+	switch {
+	case slo.Burning && !s.slo.burning:
+		s.slo.burning, s.slo.since = true, now
+		go s.sendWatcherAlert("slo.burning", fmt.Sprintf("⚠️ Alert latency SLO burning at %.1fx budget: %d of %d calls in the last hour were not alerted within %ds (target %s)",
+			long.BurnRate, long.Late, long.Calls, slo.LatencySec, formatPercent(slo.Target)))
+	case s.slo.burning && (slo.BurnRateAlert <= 0 || long.BurnRate < slo.BurnRateAlert):
+		s.slo.burning = false
+		go s.sendWatcherAlert("slo.recovered", fmt.Sprintf("✅ Alert latency is back within budget after %s", now.Sub(s.slo.since).Round(time.Minute)))
+	}
+}
+
+func formatPercent(v float64) string {
+	return fmt.Sprintf("%g%%", math.Round(v*10000)/100)
+}
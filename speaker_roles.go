@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"alert_framework/formatting"
+	"alert_framework/usage"
+)
+
+const speakerRolesPrompt = `You label speakers in diarized emergency radio traffic.
+Each line is "<speaker>: <text>". The dispatcher (county communications) assigns calls and relays caller information; field units (ambulances, engines, police cars, chiefs) acknowledge, go en route, arrive and report conditions.
+Reply with a JSON object mapping every speaker label to "dispatcher" or "field_unit".`
+
+// labelSpeakerRoles maps diarized speaker labels to dispatcher or field unit.
+// The keyword heuristic decides when it is confident; otherwise the roles
+// LLM gets a pass and the heuristic result is kept if that fails. It returns
+// the roles as JSON, or nil when the transcript has no speaker labels.
+func (s *server) labelSpeakerRoles(diarized *string) *string {
+	if diarized == nil {
+		return nil
+	}
+	var turns []formatting.SpeakerTurn
+	for _, seg := range parseSegmentsFromDiarized(*diarized) {
+		turns = append(turns, formatting.SpeakerTurn{Speaker: seg.Speaker, Text: seg.Text})
+	}
+	roles, confident := formatting.SpeakerRoles(turns)
+	if len(roles) == 0 {
+		return nil
+	}
+	if !confident && len(roles) > 1 {
+		if llmRoles, err := s.speakerRolesWithLLM(turns, roles); err == nil {
+			roles = llmRoles
+		} else {
+			log.Printf("speaker role llm failed, using heuristic: %v", err)
+		}
+	}
+	data, err := json.Marshal(roles)
+	if err != nil {
+		return nil
+	}
+	out := string(data)
+	return &out
+}
+
+func (s *server) speakerRolesWithLLM(turns []formatting.SpeakerTurn, known map[string]string) (map[string]string, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("OPENAI_API_KEY not set")
+	}
+	var lines strings.Builder
+	for _, turn := range turns {
+		if turn.Speaker != "" {
+			fmt.Fprintf(&lines, "%s: %s\n", turn.Speaker, turn.Text)
+		}
+	}
+	payload := map[string]interface{}{
+		"model":           s.cfg.LLM.Roles.ModelOr("gpt-4.1-mini"),
+		"temperature":     0,
+		"response_format": map[string]string{"type": "json_object"},
+		"messages": []map[string]string{
+			{"role": "system", "content": speakerRolesPrompt},
+			{"role": "user", "content": lines.String()},
+		},
+	}
+	buf, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(usage.WithStage(context.Background(), usage.StageRoles), "POST", s.cfg.LLM.Roles.URL("/v1/chat/completions"), bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("speaker roles status %d: %s", resp.StatusCode, string(b))
+	}
+	var parsed struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed.Choices) == 0 {
+		return nil, errors.New("empty speaker roles")
+	}
+	var labeled map[string]string
+	if err := json.Unmarshal([]byte(parsed.Choices[0].Message.Content), &labeled); err != nil {
+		return nil, err
+	}
+	// Only accept a complete, valid labeling of the speakers we sent.
+	roles := make(map[string]string, len(known))
+	for speaker := range known {
+		role := strings.ToLower(strings.TrimSpace(labeled[speaker]))
+		if role != formatting.RoleDispatcher && role != formatting.RoleFieldUnit {
+			return nil, fmt.Errorf("invalid role %q for %s", labeled[speaker], speaker)
+		}
+		roles[speaker] = role
+	}
+	return roles, nil
+}
+
+// applySpeakerRoles copies stored roles onto segments by speaker label.
+func applySpeakerRoles(segments []transcriptSegment, rolesJSON *string) []transcriptSegment {
+	if rolesJSON == nil || len(segments) == 0 {
+		return segments
+	}
+	var roles map[string]string
+	if err := json.Unmarshal([]byte(*rolesJSON), &roles); err != nil || len(roles) == 0 {
+		return segments
+	}
+	for i := range segments {
+		segments[i].Role = roles[segments[i].Speaker]
+	}
+	return segments
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+	"alert_framework/usage"
+)
+
+// Spoken summaries read a call back in about ten seconds for members who
+// would rather listen to a summary than to the radio traffic itself. The
+// text comes from the public view of the call, so restricted details are
+// never spoken, and is written out for speech with the call's transcript
+// terms and common dispatch abbreviations expanded.
+
+const (
+	// spokenSummaryWords is about ten seconds of speech.
+	spokenSummaryWords = 28
+	speechTimeout      = 30 * time.Second
+	speechEngineOpenAI = "openai"
+	speechEnginePiper  = "piper"
+	speechEngineEspeak = "espeak"
+
+	defaultSpeechModel = "gpt-4o-mini-tts"
+	defaultSpeechVoice = "alloy"
+)
+
+var errSpeechUnavailable = errors.New("speech engine not configured")
+
+// handleSpeech serves GET /api/transcription/{file}/tts: the call's spoken
+// summary as audio, or as the text that would be spoken with ?format=text.
+// Audio is cached until the call changes.
+func (s *server) handleSpeech(w http.ResponseWriter, r *http.Request, filename string) {
+	t, err := s.getTranscription(filename)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("speech fetch %s failed: %v", filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if !s.visibleTo(r, *t) || t.DeletedAt != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if t.Status != statusDone {
+		http.Error(w, "call is not transcribed yet", http.StatusConflict)
+		return
+	}
+	text := s.spokenSummary(*t)
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, text)
+		return
+	}
+	audio, ok := s.speech.get(t.Filename, t.UpdatedAt)
+	if !ok {
+		ctx, cancel := context.WithTimeout(r.Context(), speechTimeout)
+		defer cancel()
+		audio, err = s.synthesizeSpeech(ctx, text)
+		if errors.Is(err, errSpeechUnavailable) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			log.Printf("speech for %s failed: %v", filename, err)
+			http.Error(w, "speech unavailable", http.StatusBadGateway)
+			return
+		}
+		s.speech.put(t.Filename, t.UpdatedAt, audio)
+	}
+	w.Header().Set("Content-Type", s.speechContentType())
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write(audio)
+}
+
+// spokenSummary is "Agency, 3:05 PM. Call type at address, town." followed
+// by as much of the cleaned transcript as fits in spokenSummaryWords.
+func (s *server) spokenSummary(t transcription) string {
+	resp := s.toResponse(s.publicRecord(t), "")
+	var head []string
+	if agency := strings.TrimSpace(resp.Agency); agency != "" {
+		head = append(head, agency)
+	}
+	if ts, err := time.Parse(time.RFC3339, resp.TimestampLocal); err == nil {
+		head = append(head, ts.In(s.tz).Format("3:04 PM"))
+	}
+	what := strings.TrimSpace(resp.NormalizedCallType)
+	if what == "" {
+		what = "Call"
+	}
+	town := strings.TrimSpace(resp.CityOrTown)
+	if town == "" {
+		town = strings.TrimSpace(resp.Town)
+	}
+	switch address := strings.TrimSpace(resp.AddressLine); {
+	case address != "" && town != "" && !strings.Contains(strings.ToLower(address), strings.ToLower(town)):
+		what += " at " + address + ", " + town
+	case address != "":
+		what += " at " + address
+	case town != "":
+		what += " in " + town
+	}
+	lines := []string{strings.Join(head, ", "), what}
+	body := strings.TrimSpace(resp.CleanSummary)
+	if body == "" {
+		body = strings.TrimSpace(derefString(resp.PublicTranscript, ""))
+	}
+	meta, _ := s.parseCallMetadata(t.Filename, "")
+	terms, err := s.loadTranscriptTerms(context.Background())
+	if err != nil {
+		log.Printf("transcript term lookup failed: %v", err)
+	}
+	var spoken []string
+	words := 0
+	for _, line := range append(lines, body) {
+		line = strings.TrimRight(strings.TrimSpace(line), ".…")
+		remaining := spokenSummaryWords - words
+		if line == "" || remaining <= 0 {
+			continue
+		}
+		line = formatting.SpokenText(line, termsForTown(terms, meta.TownDisplay))
+		if words > 0 {
+			line = formatting.TrimWords(line, remaining)
+		}
+		spoken = append(spoken, line)
+		words += len(strings.Fields(line))
+	}
+	return strings.Join(spoken, " ")
+}
+
+func (s *server) speechContentType() string {
+	if s.cfg.TTSEngine == speechEnginePiper || s.cfg.TTSEngine == speechEngineEspeak {
+		return "audio/wav"
+	}
+	return "audio/mpeg"
+}
+
+// synthesizeSpeech reads text aloud with TTS_ENGINE: MP3 from OpenAI, or WAV
+// from a local piper or espeak-ng binary.
+func (s *server) synthesizeSpeech(ctx context.Context, text string) ([]byte, error) {
+	switch s.cfg.TTSEngine {
+	case speechEnginePiper, speechEngineEspeak:
+		return s.synthesizeLocal(ctx, text)
+	}
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, errSpeechUnavailable
+	}
+	model, voice := s.cfg.TTSModel, s.cfg.TTSVoice
+	if model == "" {
+		model = defaultSpeechModel
+	}
+	if voice == "" {
+		voice = defaultSpeechVoice
+	}
+	buf, _ := json.Marshal(map[string]string{"model": model, "voice": voice, "input": text, "response_format": "mp3"})
+	req, err := http.NewRequestWithContext(usage.WithStage(ctx, usage.StageSpeech), "POST", s.cfg.LLM.URL("/v1/audio/speech"), bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, &openAIStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if len(body) == 0 {
+		return nil, errors.New("empty audio from openai")
+	}
+	return body, nil
+}
+
+// synthesizeLocal runs piper or espeak-ng with the text on stdin and returns
+// the WAV file it writes.
+func (s *server) synthesizeLocal(ctx context.Context, text string) ([]byte, error) {
+	dir, err := os.MkdirTemp(s.cfg.WorkDir, "tts-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "speech.wav")
+	var args []string
+	if s.cfg.TTSEngine == speechEnginePiper {
+		args = []string{"--model", s.cfg.TTSModel, "--output_file", out}
+	} else {
+		if s.cfg.TTSVoice != "" {
+			args = append(args, "-v", s.cfg.TTSVoice)
+		}
+		args = append(args, "-w", out, "--stdin")
+	}
+	cmd := exec.CommandContext(ctx, s.cfg.TTSBin, args...)
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%s timed out", s.cfg.TTSEngine)
+		}
+		return nil, fmt.Errorf("%s: %v: %s", s.cfg.TTSEngine, err, truncateText(strings.TrimSpace(string(output)), 500))
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		return nil, fmt.Errorf("%s output: %w", s.cfg.TTSEngine, err)
+	}
+	return data, nil
+}
@@ -69,7 +69,7 @@ import {
     selected: null,
     wavesurfer: null,
     segments: [],
-    mapboxToken: '',
+    mapEnabled: false,
     map: null,
     mapLayerVisibility: { density: true, points: false, hotspots: true },
     mapGeoJSON: { type: 'FeatureCollection', features: [] },
@@ -79,6 +79,9 @@ import {
     autoRefreshTimer: null,
     summaryRefreshTimer: null,
     hotspotRefreshTimer: null,
+    liveSocket: null,
+    liveStats: false,
+    liveRetryTimer: null,
     inlineAudio: null,
     mapResizeTimer: null,
     activePopup: null,
@@ -90,9 +93,13 @@ import {
   const MAP_DEFAULT_CENTER = [-74.696, 41.05];
   const MAP_DEFAULT_ZOOM = 8;
   const MAP_STYLE = 'mapbox://styles/mapbox/dark-v11';
+  // The real token stays on the server; /tiles/ adds it to each request.
+  const MAP_PLACEHOLDER_TOKEN = 'pk.proxied';
+  const MAPBOX_URL_PATTERN = /^https:\/\/(?:[a-z]\.)?(?:api|tiles)\.mapbox\.com\//;
   const CALLS_REFRESH_INTERVAL = 5000;
   const SUMMARY_REFRESH_INTERVAL = 30000;
   const HOTSPOT_REFRESH_INTERVAL = 45000;
+  const LIVE_RETRY_INTERVAL = 15000;
 
   function openFilters() {
     if (!filtersDrawer) return;
@@ -122,6 +129,9 @@ import {
     state.summaryStats = null;
     windowButtons.forEach((btn) => btn.classList.toggle('active', btn.dataset.window === next));
     renderSummaryBar();
+    if (state.liveStats && state.liveSocket) {
+      state.liveSocket.send(JSON.stringify({ window: next }));
+    }
     refreshAll();
   }
 
@@ -460,6 +470,8 @@ import {
     return `${mins}:${secs}`;
   }
 
+  const speakerRoleLabels = { dispatcher: 'Dispatch', field_unit: 'Unit' };
+
   function normalizeSegments(call) {
     const rawSegments = Array.isArray(call.segments) ? call.segments : [];
     const cleaned = rawSegments
@@ -468,6 +480,7 @@ import {
         end: Number(seg.end) || (Number(seg.start) || 0) + 0.5,
         text: (seg.text || '').trim(),
         speaker: seg.speaker || '',
+        role: seg.role || '',
       }))
       .filter((seg) => seg.text && seg.end > seg.start);
     if (cleaned.length) return cleaned;
@@ -541,6 +554,14 @@ import {
       text.className = 'text';
       text.textContent = segment.text;
       row.appendChild(ts);
+      if (segment.role) {
+        const role = document.createElement('span');
+        role.className = 'speaker-role';
+        role.dataset.role = segment.role;
+        role.textContent = speakerRoleLabels[segment.role] || segment.role;
+        role.title = segment.speaker || '';
+        row.appendChild(role);
+      }
       row.appendChild(text);
       row.addEventListener('click', () => {
         if (state.wavesurfer) {
@@ -680,6 +701,9 @@ import {
   }
 
   function extractCoordinates(call) {
+    if (call.marker && Number.isFinite(call.marker.latitude) && Number.isFinite(call.marker.longitude)) {
+      return { lat: Number(call.marker.latitude), lon: Number(call.marker.longitude) };
+    }
     if (call.location && Number.isFinite(call.location.latitude) && Number.isFinite(call.location.longitude)) {
       return { lat: Number(call.location.latitude), lon: Number(call.location.longitude) };
     }
@@ -743,6 +767,8 @@ import {
           subtitle: callLocationLabel(point.call),
           timestamp: formatDate(callTimestampValue(point.call)),
           weight: Math.max(0.3, Math.min(1, (point.call.duration_seconds || point.call.durationSeconds || 60) / 600)),
+          color: point.call.marker?.color || '#7ce7ff',
+          precision: point.call.marker?.precision || '',
         },
       })),
     };
@@ -857,10 +883,10 @@ import {
         source: 'call-points',
         minzoom: 5,
         paint: {
-          'circle-radius': 8,
-          'circle-color': '#7ce7ff',
-          'circle-stroke-color': '#0b1021',
-          'circle-stroke-width': 1,
+          'circle-radius': ['match', ['get', 'precision'], 'township', 6, 'area', 7, 8],
+          'circle-color': ['get', 'color'],
+          'circle-stroke-color': ['match', ['get', 'precision'], ['township', 'area'], '#ffffff', '#0b1021'],
+          'circle-stroke-width': ['match', ['get', 'precision'], ['township', 'area'], 2, 1],
           'circle-opacity': state.mapLayerVisibility.points ? 0.95 : 0,
         },
       });
@@ -1008,10 +1034,18 @@ import {
     refreshMapLayers({ bounds, isValid: !bounds.isEmpty() });
   }
 
+  function proxyMapRequest(url) {
+    if (!MAPBOX_URL_PATTERN.test(url)) {
+      return { url };
+    }
+    const proxied = new URL(url.replace(MAPBOX_URL_PATTERN, `${window.location.origin}/tiles/`));
+    proxied.searchParams.delete('access_token');
+    return { url: proxied.toString() };
+  }
+
   async function renderMap() {
     if (typeof window === 'undefined' || !mapChart) return;
-    const token = (state.mapboxToken || '').trim();
-    if (!token) {
+    if (!state.mapEnabled) {
       showMapUnavailable('Map unavailable', 'Add a Mapbox access token to view incident geography.');
       return;
     }
@@ -1020,7 +1054,7 @@ import {
       return;
     }
 
-    mapboxgl.accessToken = token;
+    mapboxgl.accessToken = MAP_PLACEHOLDER_TOKEN;
     const callsForMap = getInsightCalls(getVisibleCalls());
 
     if (!state.map) {
@@ -1031,6 +1065,7 @@ import {
         style: MAP_STYLE,
         center: MAP_DEFAULT_CENTER,
         zoom: MAP_DEFAULT_ZOOM,
+        transformRequest: proxyMapRequest,
       });
       state.map.addControl(new mapboxgl.NavigationControl(), 'top-right');
       state.map.addControl(new mapboxgl.ScaleControl({ maxWidth: 120, unit: 'imperial' }), 'bottom-right');
@@ -1209,7 +1244,7 @@ import {
           throw new Error('Failed to load calls');
         }
         const payload = await res.json();
-        state.mapboxToken = payload.mapbox_token || state.mapboxToken;
+        state.mapEnabled = Boolean(payload.map_enabled);
         state.calls = dedupeCalls((payload.calls || []).map(normalizeIncident));
         state.stats = payload.stats || {};
         if (state.error) {
@@ -1250,7 +1285,7 @@ import {
       }
       const payload = await res.json();
       state.summaryStats = payload || null;
-      state.mapboxToken = payload?.mapbox_token || state.mapboxToken;
+      state.mapEnabled = Boolean(payload?.map_enabled);
       renderSummaryBar();
     } catch (err) {
       console.error(err);
@@ -1264,16 +1299,86 @@ import {
         throw new Error('failed to load hotspots');
       }
       const payload = await res.json();
-      state.hotspots = Array.isArray(payload.hotspots) ? payload.hotspots : [];
-      state.hotspotGeoJSON = buildHotspotCollection(state.hotspots);
-      renderHotspotList(payload.window || state.window);
-      refreshMapLayers();
+      setHotspots(Array.isArray(payload.hotspots) ? payload.hotspots : [], payload.window);
     } catch (err) {
       console.error(err);
-      state.hotspots = [];
-      state.hotspotGeoJSON = { type: 'FeatureCollection', features: [] };
-      renderHotspotList(state.window);
-      refreshMapLayers();
+      setHotspots([]);
+    }
+  }
+
+  function setHotspots(spots, windowName) {
+    state.hotspots = spots;
+    state.hotspotGeoJSON = buildHotspotCollection(state.hotspots);
+    renderHotspotList(windowName || state.window);
+    refreshMapLayers();
+  }
+
+  // The live socket pushes the summary and hotspots: a snapshot first, then
+  // only what changed. While it is open the summary and hotspot polls stop.
+  function connectLiveStats() {
+    if (!('WebSocket' in window) || state.liveSocket) return;
+    const proto = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const socket = new WebSocket(`${proto}//${window.location.host}/api/stats/live?window=${encodeURIComponent(state.window)}`);
+    state.liveSocket = socket;
+    socket.addEventListener('message', (evt) => {
+      try {
+        applyLiveStats(JSON.parse(evt.data));
+      } catch (err) {
+        console.error(err);
+      }
+    });
+    socket.addEventListener('close', () => {
+      if (state.liveSocket !== socket) return;
+      state.liveSocket = null;
+      state.liveStats = false;
+      clearTimeout(state.liveRetryTimer);
+      state.liveRetryTimer = setTimeout(connectLiveStats, LIVE_RETRY_INTERVAL);
+    });
+  }
+
+  function applyLiveStats(msg) {
+    if (msg.type === 'snapshot') {
+      const { hotspots, ...stats } = msg.stats || {};
+      if (stats.window !== state.window) return;
+      state.liveStats = true;
+      state.summaryStats = stats;
+      state.mapEnabled = Boolean(stats.map_enabled);
+      renderSummaryBar();
+      setHotspots(Array.isArray(hotspots) ? hotspots : [], stats.window);
+      return;
+    }
+    const stats = state.summaryStats;
+    if (msg.type !== 'delta' || !state.liveStats || !stats || msg.window !== state.window) return;
+    stats.total_incidents = msg.total_incidents;
+    stats.top_incident_types = msg.top_incident_types || [];
+    stats.top_agencies = msg.top_agencies || [];
+    Object.entries(msg.counters || {}).forEach(([name, counts]) => {
+      const target = stats[name] || (stats[name] = {});
+      Object.entries(counts).forEach(([key, count]) => {
+        if (count) {
+          target[key] = count;
+        } else {
+          delete target[key];
+        }
+      });
+    });
+    if (msg.hours_shift || msg.hours) {
+      const hours = (stats.incidents_per_hour || []).slice(msg.hours_shift || 0);
+      (msg.hours || []).forEach((bucket) => {
+        hours[bucket.index] = { hour: bucket.hour, count: bucket.count };
+      });
+      stats.incidents_per_hour = hours;
+    }
+    renderSummaryBar();
+    if (msg.hotspots || msg.hotspots_removed) {
+      const key = (spot) => `${spot.label}|${spot.latitude}|${spot.longitude}`;
+      const spots = new Map(state.hotspots.map((spot) => [key(spot), spot]));
+      (msg.hotspots_removed || []).forEach((spot) => spots.delete(key(spot)));
+      (msg.hotspots || []).forEach((spot) => spots.set(key(spot), spot));
+      const next = [...spots.values()].sort(
+        (a, b) => b.count - a.count || String(b.last_seen || '').localeCompare(String(a.last_seen || ''))
+      );
+      setHotspots(next, msg.window);
     }
   }
 
@@ -1307,8 +1412,10 @@ import {
   function refreshAll(options = {}) {
     const { silentCalls = false } = options;
     fetchCalls({ silent: silentCalls });
-    fetchSummaryStats();
-    fetchHotspots();
+    if (!state.liveStats) {
+      fetchSummaryStats();
+      fetchHotspots();
+    }
   }
 
   function stopAutoRefreshLoops() {
@@ -1334,12 +1441,12 @@ import {
       }
     }, CALLS_REFRESH_INTERVAL);
     state.summaryRefreshTimer = setInterval(() => {
-      if (!document.hidden) {
+      if (!document.hidden && !state.liveStats) {
         fetchSummaryStats();
       }
     }, SUMMARY_REFRESH_INTERVAL);
     state.hotspotRefreshTimer = setInterval(() => {
-      if (!document.hidden) {
+      if (!document.hidden && !state.liveStats) {
         fetchHotspots();
       }
     }, HOTSPOT_REFRESH_INTERVAL);
@@ -1438,4 +1545,5 @@ import {
   renderHotspotList(state.window);
   refreshAll();
   startAutoRefreshLoops();
+  connectLiveStats();
 })();
@@ -134,6 +134,7 @@ export function normalizeIncident(raw) {
     cleanTranscript: raw.clean_transcript_text || '',
     rawTranscript: raw.raw_transcript_text || '',
     translation: raw.translation_text || '',
+    detectedLanguage: raw.detected_language || null,
     lastError: raw.last_error || null,
     missingFields,
   };
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+	"alert_framework/stations"
+)
+
+const (
+	stationLookupTimeout = 5 * time.Second
+	metersPerMile        = 1609.344
+	metersPerFoot        = 0.3048
+)
+
+// stationReport is stored with a call: the nearest station of each kind to
+// the point it was worked out for.
+type stationReport struct {
+	Latitude  float64           `json:"latitude"`
+	Longitude float64           `json:"longitude"`
+	Stations  []stationDistance `json:"stations"`
+}
+
+// stationDistance is a nearest station with its straight-line distance and,
+// when Mapbox could route it, the driving distance from the station.
+type stationDistance struct {
+	stations.Match
+	DriveMeters  *float64 `json:"drive_m,omitempty"`
+	DriveSeconds *float64 `json:"drive_seconds,omitempty"`
+}
+
+// meters is the driving distance when known, else the straight line.
+func (d stationDistance) meters() (float64, bool) {
+	if d.DriveMeters != nil {
+		return *d.DriveMeters, true
+	}
+	return d.DistanceMeters, false
+}
+
+func migrateAddStationDistances(db *sql.DB) error {
+	return addColumnIfMissing(db, "transcriptions", "station_distances", "TEXT NULL")
+}
+
+func downStationDistances(db *sql.DB) error {
+	return dropColumnIfExists(db, "transcriptions", "station_distances")
+}
+
+// recordStationDistances works out the nearest stations to a call's resolved
+// location and stores them with the call. Driving distances come from the
+// Mapbox Matrix API when MAPBOX_TOKEN is set; without it, or when Mapbox
+// fails, only straight-line distances are kept.
+func (s *server) recordStationDistances(ctx context.Context, filename string, location *locationGuess) {
+	if s.stationIndex == nil || location == nil || (location.Latitude == 0 && location.Longitude == 0) {
+		return
+	}
+	report := stationReport{Latitude: location.Latitude, Longitude: location.Longitude}
+	for _, m := range s.stationIndex.Nearest(location.Latitude, location.Longitude) {
+		report.Stations = append(report.Stations, stationDistance{Match: m})
+	}
+	if len(report.Stations) == 0 {
+		return
+	}
+	if token := strings.TrimSpace(s.cfg.MapboxToken); token != "" {
+		ctx, cancel := context.WithTimeout(ctx, stationLookupTimeout)
+		defer cancel()
+		if err := s.addDriveDistances(ctx, token, report); err != nil {
+			log.Printf("station drive distances failed for %s: %v", filename, err)
+		}
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	if _, err := execWithRetry(s.db, `UPDATE transcriptions SET station_distances = ? WHERE filename = ?`, string(data), filename); err != nil {
+		log.Printf("station distances save failed for %s: %v", filename, err)
+	}
+}
+
+// addDriveDistances fills in driving distance and time from each station to
+// the call with one Matrix request. Hydrants are not routed to.
+func (s *server) addDriveDistances(ctx context.Context, token string, report stationReport) error {
+	coords := []string{fmt.Sprintf("%f,%f", report.Longitude, report.Latitude)}
+	var sources []string
+	var routed []int
+	for i, d := range report.Stations {
+		if d.Kind == stations.KindHydrant {
+			continue
+		}
+		routed = append(routed, i)
+		sources = append(sources, strconv.Itoa(len(coords)))
+		coords = append(coords, fmt.Sprintf("%f,%f", d.Longitude, d.Latitude))
+	}
+	if len(routed) == 0 {
+		return nil
+	}
+	query := url.Values{}
+	query.Set("access_token", token)
+	query.Set("sources", strings.Join(sources, ";"))
+	query.Set("destinations", "0")
+	query.Set("annotations", "distance,duration")
+	status, _, body, err := s.fetchMapbox(ctx, "directions-matrix/v1/mapbox/driving/"+strings.Join(coords, ";"), query)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("mapbox matrix returned %d", status)
+	}
+	var payload struct {
+		Code      string       `json:"code"`
+		Distances [][]*float64 `json:"distances"`
+		Durations [][]*float64 `json:"durations"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+	if payload.Code != "Ok" {
+		return fmt.Errorf("mapbox matrix returned code %q", payload.Code)
+	}
+	for row, i := range routed {
+		if row < len(payload.Distances) && len(payload.Distances[row]) > 0 {
+			report.Stations[i].DriveMeters = payload.Distances[row][0]
+		}
+		if row < len(payload.Durations) && len(payload.Durations[row]) > 0 {
+			report.Stations[i].DriveSeconds = payload.Durations[row][0]
+		}
+	}
+	return nil
+}
+
+// nearestStationsFor returns the stations stored with t, or works out
+// straight-line distances when none were stored for its current location,
+// as for calls from before STATIONS_PATH was set or moved since.
+func (s *server) nearestStationsFor(t transcription) []stationDistance {
+	if t.Latitude == nil || t.Longitude == nil {
+		return nil
+	}
+	lat, lng := *t.Latitude, *t.Longitude
+	if t.StationsJSON != nil {
+		var report stationReport
+		if err := json.Unmarshal([]byte(*t.StationsJSON), &report); err == nil &&
+			math.Abs(report.Latitude-lat) < 1e-6 && math.Abs(report.Longitude-lng) < 1e-6 {
+			return report.Stations
+		}
+	}
+	if s.stationIndex == nil || !s.region.Contains(lat, lng) {
+		return nil
+	}
+	var out []stationDistance
+	for _, m := range s.stationIndex.Nearest(lat, lng) {
+		out = append(out, stationDistance{Match: m})
+	}
+	return out
+}
+
+// stationFlags are the alert warnings for a call far from help: a fire or
+// EMS call further than STATION_FAR_MILES from the nearest station of its
+// kind, and a fire call further than HYDRANT_FAR_FEET from a hydrant.
+func (s *server) stationFlags(callCategory string, nearest []stationDistance) []string {
+	category := formatting.NormalizeCallCategory(callCategory)
+	if category != stations.KindFire && category != stations.KindEMS {
+		return nil
+	}
+	var flags []string
+	for _, d := range nearest {
+		switch {
+		case d.Kind == category && s.cfg.StationFarMiles > 0:
+			meters, byRoad := d.meters()
+			if meters <= s.cfg.StationFarMiles*metersPerMile {
+				continue
+			}
+			how := "in a straight line"
+			if byRoad {
+				how = "by road"
+			}
+			name := ""
+			if d.Name != "" {
+				name = " (" + d.Name + ")"
+			}
+			flags = append(flags, fmt.Sprintf("%.1f mi %s from the nearest %s station%s", meters/metersPerMile, how, stationKindLabel(category), name))
+		case d.Kind == stations.KindHydrant && category == stations.KindFire && s.cfg.HydrantFarFeet > 0:
+			if feet := d.DistanceMeters / metersPerFoot; feet > s.cfg.HydrantFarFeet {
+				flags = append(flags, fmt.Sprintf("Nearest hydrant is %.0f ft away", feet))
+			}
+		}
+	}
+	return flags
+}
+
+func stationKindLabel(kind string) string {
+	if kind == stations.KindEMS {
+		return "EMS"
+	}
+	return kind
+}
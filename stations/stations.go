@@ -0,0 +1,163 @@
+// Package stations finds the firehouses, EMS buildings, hospitals and
+// hydrants nearest an incident, from a GeoJSON dataset of Point features.
+package stations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Station kinds the rest of the service knows about. Datasets may use other
+// kinds; they are kept, lowercased, and reported like these.
+const (
+	KindFire     = "fire"
+	KindEMS      = "ems"
+	KindHospital = "hospital"
+	KindHydrant  = "hydrant"
+)
+
+var kindAliases = map[string]string{
+	"fire station": KindFire,
+	"firehouse":    KindFire,
+	"fire_station": KindFire,
+	"ambulance":    KindEMS,
+	"rescue squad": KindEMS,
+	"first aid":    KindEMS,
+	"fire_hydrant": KindHydrant,
+}
+
+const earthRadiusMeters = 6371008.8
+
+// Station is one entry of the dataset.
+type Station struct {
+	Name      string  `json:"name"`
+	Kind      string  `json:"kind"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Match is the nearest station of a kind and its straight-line distance.
+type Match struct {
+	Station
+	DistanceMeters float64 `json:"distance_m"`
+}
+
+// Index holds the dataset grouped by kind.
+type Index struct {
+	byKind map[string][]Station
+	count  int
+}
+
+// Len returns the number of stations in the index.
+func (ix *Index) Len() int {
+	if ix == nil {
+		return 0
+	}
+	return ix.count
+}
+
+// Load reads a GeoJSON FeatureCollection of Point features from path. See
+// Parse for the expected properties.
+func Load(path string) (*Index, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+type featureCollection struct {
+	Features []struct {
+		Geometry struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties struct {
+			Name string `json:"name"`
+			Kind string `json:"kind"`
+			Type string `json:"type"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// Parse reads a FeatureCollection whose features are Points with a "kind"
+// property (fire, ems, hospital or hydrant; "type" is accepted as an alias)
+// and an optional "name". Hydrants usually have no name.
+func Parse(r io.Reader) (*Index, error) {
+	var fc featureCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("decode geojson: %w", err)
+	}
+	ix := &Index{byKind: make(map[string][]Station)}
+	for i, f := range fc.Features {
+		if f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) < 2 {
+			return nil, fmt.Errorf("feature %d: geometry must be a Point", i)
+		}
+		kind := f.Properties.Kind
+		if kind == "" {
+			kind = f.Properties.Type
+		}
+		kind = NormalizeKind(kind)
+		if kind == "" {
+			return nil, fmt.Errorf("feature %d: kind is required", i)
+		}
+		st := Station{
+			Name:      strings.TrimSpace(f.Properties.Name),
+			Kind:      kind,
+			Latitude:  f.Geometry.Coordinates[1],
+			Longitude: f.Geometry.Coordinates[0],
+		}
+		ix.byKind[kind] = append(ix.byKind[kind], st)
+		ix.count++
+	}
+	return ix, nil
+}
+
+// NormalizeKind folds the spellings datasets use for a kind.
+func NormalizeKind(kind string) string {
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if alias, ok := kindAliases[kind]; ok {
+		return alias
+	}
+	return kind
+}
+
+// Nearest returns the nearest station of every kind in the index, ordered by
+// kind.
+func (ix *Index) Nearest(lat, lng float64) []Match {
+	if ix == nil {
+		return nil
+	}
+	kinds := make([]string, 0, len(ix.byKind))
+	for kind := range ix.byKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	out := make([]Match, 0, len(kinds))
+	for _, kind := range kinds {
+		var best Match
+		for i, st := range ix.byKind[kind] {
+			d := DistanceMeters(lat, lng, st.Latitude, st.Longitude)
+			if i == 0 || d < best.DistanceMeters {
+				best = Match{Station: st, DistanceMeters: d}
+			}
+		}
+		out = append(out, best)
+	}
+	return out
+}
+
+// DistanceMeters is the great-circle distance between two points.
+func DistanceMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
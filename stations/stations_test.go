@@ -0,0 +1,51 @@
+package stations
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const testStations = `{"type":"FeatureCollection","features":[
+{"type":"Feature","geometry":{"type":"Point","coordinates":[-74.7527,41.0581]},"properties":{"name":"Newton Fire Dept","kind":"Fire Station"}},
+{"type":"Feature","geometry":{"type":"Point","coordinates":[-74.6604,41.0087]},"properties":{"name":"Sparta Fire Dept","kind":"fire"}},
+{"type":"Feature","geometry":{"type":"Point","coordinates":[-74.7560,41.0500]},"properties":{"name":"Newton Medical Center","type":"hospital"}},
+{"type":"Feature","geometry":{"type":"Point","coordinates":[-74.6610,41.0090]},"properties":{"kind":"hydrant"}}
+]}`
+
+func TestParseAndNearest(t *testing.T) {
+	ix, err := Parse(strings.NewReader(testStations))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if ix.Len() != 4 {
+		t.Fatalf("Len = %d, want 4", ix.Len())
+	}
+	got := ix.Nearest(41.0100, -74.6650)
+	if len(got) != 3 {
+		t.Fatalf("Nearest returned %d kinds, want 3: %+v", len(got), got)
+	}
+	want := []string{"fire:Sparta Fire Dept", "hospital:Newton Medical Center", "hydrant:"}
+	for i, m := range got {
+		if m.Kind+":"+m.Name != want[i] {
+			t.Errorf("match %d = %s:%s, want %s", i, m.Kind, m.Name, want[i])
+		}
+	}
+	if d := got[0].DistanceMeters; d < 300 || d > 500 {
+		t.Errorf("distance to Sparta = %.0f m, want about 400", d)
+	}
+}
+
+func TestParseRequiresKind(t *testing.T) {
+	doc := `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[-74.7,41.0]},"properties":{"name":"?"}}]}`
+	if _, err := Parse(strings.NewReader(doc)); err == nil {
+		t.Fatal("expected an error for a feature without kind")
+	}
+}
+
+func TestDistanceMeters(t *testing.T) {
+	// One degree of latitude is about 111.2 km.
+	if d := DistanceMeters(41, -74.7, 42, -74.7); math.Abs(d-111195) > 100 {
+		t.Fatalf("DistanceMeters = %.0f, want about 111195", d)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	statusProbeTTL      = time.Minute
+	statusErrorWindow   = time.Hour
+	statusErrorRateWarn = 0.25
+	statusMinSamples    = 4
+)
+
+type statusResponse struct {
+	Status              string            `json:"status"`
+	GeneratedAt         time.Time         `json:"generated_at"`
+	LastIngestAt        *time.Time        `json:"last_ingest_at"`
+	LastTranscriptionAt *time.Time        `json:"last_transcription_at"`
+	OpenAI              dependencyStatus  `json:"openai"`
+	Mapbox              dependencyStatus  `json:"mapbox"`
+	Queue               *statusQueue      `json:"queue,omitempty"`
+	SharedQueue         *SharedQueueDebug `json:"shared_queue,omitempty"`
+	Leader              *leaderInfo       `json:"leader,omitempty"`
+	Rollups             *statusRollups    `json:"rollups,omitempty"`
+	SLO                 *statusSLO        `json:"slo,omitempty"`
+	Integrity           *statusIntegrity  `json:"integrity,omitempty"`
+	Errors              statusErrorRate   `json:"errors"`
+	Issues              []string          `json:"issues"`
+}
+
+type dependencyStatus struct {
+	Configured bool       `json:"configured"`
+	Reachable  bool       `json:"reachable"`
+	CheckedAt  *time.Time `json:"checked_at,omitempty"`
+	Breaker    string     `json:"breaker,omitempty"`
+}
+
+type statusQueue struct {
+	Depth    int      `json:"depth"`
+	Capacity int      `json:"capacity"`
+	Active   int      `json:"active"`
+	Workers  int      `json:"workers"`
+	Paused   bool     `json:"paused"`
+	PausedBy []string `json:"paused_by,omitempty"`
+}
+
+type statusErrorRate struct {
+	Window    string         `json:"window"`
+	Completed int            `json:"completed"`
+	Failed    int            `json:"failed"`
+	Rate      float64        `json:"rate"`
+	ByCode    map[string]int `json:"by_code,omitempty"`
+}
+
+// reachability caches the result of an external probe so the public status
+// endpoint never fans out to upstream APIs on every request.
+type reachability struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+	// running is closed when the probe in flight finishes.
+	running chan struct{}
+}
+
+// check returns the last probe result, starting a probe when it is older
+// than statusProbeTTL. A stale result is returned at once while the probe
+// runs in the background; only the first check waits, for as long as ctx
+// allows. Probes run on base, the server's context, so a client hanging up
+// cannot record a failure, and a probe cut short by shutdown is not cached.
+func (r *reachability) check(ctx, base context.Context, probe func(context.Context) error) (time.Time, error) {
+	r.mu.Lock()
+	if !r.checkedAt.IsZero() && time.Since(r.checkedAt) < statusProbeTTL {
+		defer r.mu.Unlock()
+		return r.checkedAt, r.err
+	}
+	done := r.running
+	if done == nil {
+		done = make(chan struct{})
+		r.running = done
+		go r.run(base, probe, done)
+	}
+	checkedAt, err := r.checkedAt, r.err
+	r.mu.Unlock()
+	if !checkedAt.IsZero() {
+		return checkedAt, err
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return time.Time{}, ctx.Err()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.checkedAt.IsZero() {
+		return time.Time{}, context.Canceled
+	}
+	return r.checkedAt, r.err
+}
+
+func (r *reachability) run(base context.Context, probe func(context.Context) error, done chan struct{}) {
+	probeCtx, cancel := context.WithTimeout(base, 5*time.Second)
+	err := probe(probeCtx)
+	cancel()
+	r.mu.Lock()
+	if !errors.Is(err, context.Canceled) {
+		r.checkedAt, r.err = time.Now().UTC(), err
+	}
+	r.running = nil
+	r.mu.Unlock()
+	close(done)
+}
+
+// handleStatusPage serves the public HTML status page.
+func (s *server) handleStatusPage(w http.ResponseWriter, r *http.Request) {
+	data, err := embeddedStatic.ReadFile("static/status.html")
+	if err != nil {
+		http.Error(w, "missing status page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(data)
+}
+
+// handleStatus reports pipeline health for non-admin viewers. It exposes no
+// call content, only timestamps, counts and dependency reachability.
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	resp := statusResponse{Status: "ok", GeneratedAt: time.Now().UTC(), Issues: []string{}}
+	ctx := r.Context()
+
+	if err := s.db.PingContext(ctx); err != nil {
+		resp.Status = "down"
+		resp.Issues = append(resp.Issues, "database unavailable")
+		respondJSON(w, resp)
+		return
+	}
+	var lastIngest, lastDone sql.NullString
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&lastIngest, &lastDone)
+	}, `SELECT (SELECT MAX(created_at) FROM transcriptions), (SELECT MAX(updated_at) FROM transcriptions WHERE status = ?)`, statusDone); err != nil {
+		log.Printf("status timestamps failed: %v", err)
+	}
+	resp.LastIngestAt = parseStatusTime(lastIngest.String)
+	resp.LastTranscriptionAt = parseStatusTime(lastDone.String)
+
+	cutoff := time.Now().UTC().Add(-statusErrorWindow)
+	resp.Errors.Window = "1h"
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&resp.Errors.Completed, &resp.Errors.Failed)
+	}, `SELECT COALESCE(SUM(CASE WHEN status IN (?, ?) THEN 1 ELSE 0 END), 0), COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) FROM transcriptions WHERE updated_at >= ?`, statusDone, statusError, statusError, cutoff); err != nil {
+		log.Printf("status error rate failed: %v", err)
+	}
+	if resp.Errors.Completed > 0 {
+		resp.Errors.Rate = float64(resp.Errors.Failed) / float64(resp.Errors.Completed)
+	}
+	if resp.Errors.Failed > 0 {
+		resp.Errors.ByCode = s.failuresByCode(ctx, cutoff)
+	}
+	if resp.Errors.Completed >= statusMinSamples && resp.Errors.Rate >= statusErrorRateWarn {
+		issue := fmt.Sprintf("%d of %d calls failed in the last hour", resp.Errors.Failed, resp.Errors.Completed)
+		if codes := topErrorCodes(resp.Errors.ByCode); len(codes) > 0 {
+			issue += " (mostly " + codes[0] + ")"
+		}
+		resp.Issues = append(resp.Issues, issue)
+	}
+
+	resp.OpenAI = s.openAIStatus(ctx)
+	if !resp.OpenAI.Reachable {
+		resp.Issues = append(resp.Issues, "OpenAI unreachable")
+	}
+	resp.Mapbox = s.mapboxStatus(ctx)
+	if resp.Mapbox.Configured && !resp.Mapbox.Reachable {
+		resp.Issues = append(resp.Issues, "Mapbox unreachable")
+	}
+
+	if s.queue != nil {
+		stats := s.queue.Stats()
+		resp.Queue = &statusQueue{Depth: stats.Length, Capacity: stats.Capacity, Active: stats.Active, Workers: stats.WorkerCount, Paused: stats.Paused, PausedBy: stats.PausedBy}
+		if stats.Paused {
+			resp.Issues = append(resp.Issues, "transcription queue paused")
+		}
+	}
+
+	if resp.SharedQueue = s.sharedQueueDebug(ctx); resp.SharedQueue != nil && !resp.SharedQueue.Reachable {
+		resp.Issues = append(resp.Issues, "shared queue unreachable")
+	}
+
+	if resp.Leader = s.leaderStatus(ctx); resp.Leader != nil && resp.Leader.Holder == "" {
+		resp.Issues = append(resp.Issues, "no instance holds the worker lease")
+	}
+
+	if resp.Rollups = s.rollupStatus(ctx); resp.Rollups != nil && resp.Rollups.Behind {
+		resp.Issues = append(resp.Issues, "rollup clustering is falling behind")
+	}
+
+	if slo, err := s.evaluateSLO(ctx, time.Now().UTC()); err != nil {
+		log.Printf("status slo failed: %v", err)
+	} else {
+		resp.SLO = &slo
+		if slo.Burning {
+			long := slo.window(sloLongWindow)
+			resp.Issues = append(resp.Issues, fmt.Sprintf("%d of %d calls in the last hour were not alerted within %ds", long.Late, long.Calls, slo.LatencySec))
+		}
+	}
+
+	var integrityIssue string
+	if resp.Integrity, integrityIssue = s.integrityStatus(ctx); integrityIssue != "" {
+		resp.Issues = append(resp.Issues, integrityIssue)
+	}
+
+	if len(resp.Issues) > 0 {
+		resp.Status = "degraded"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondJSON(w, resp)
+}
+
+func (s *server) openAIStatus(ctx context.Context) dependencyStatus {
+	out := dependencyStatus{Configured: true}
+	if snap := s.openAIBreakerDebug(); snap != nil {
+		out.Breaker = snap.State
+	}
+	checkedAt, err := s.openAIReach.check(ctx, s.ctx, s.probeOpenAI)
+	if !checkedAt.IsZero() {
+		out.CheckedAt = &checkedAt
+	}
+	out.Reachable = err == nil
+	return out
+}
+
+func (s *server) mapboxStatus(ctx context.Context) dependencyStatus {
+	token := strings.TrimSpace(s.cfg.MapboxToken)
+	if token == "" {
+		return dependencyStatus{}
+	}
+	checkedAt, err := s.mapboxReach.check(ctx, s.ctx, func(ctx context.Context) error {
+		return s.probeMapbox(ctx, token)
+	})
+	out := dependencyStatus{Configured: true, Reachable: err == nil}
+	if !checkedAt.IsZero() {
+		out.CheckedAt = &checkedAt
+	}
+	return out
+}
+
+// probeMapbox validates the token against the tokens API, which does not
+// count against geocoding quota.
+func (s *server) probeMapbox(ctx context.Context, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.mapbox.com/tokens/v2?access_token="+url.QueryEscape(token), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("mapbox status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func parseStatusTime(raw string) *time.Time {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	ts, err := parseTimestampFlexible(raw, time.UTC)
+	if err != nil {
+		return nil
+	}
+	ts = ts.UTC()
+	return &ts
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReachabilityIgnoresRequestCancellation(t *testing.T) {
+	var r reachability
+	release := make(chan struct{})
+	var calls int32
+	probe := func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	reqCtx, hangUp := context.WithCancel(context.Background())
+	hangUp()
+	if _, err := r.check(reqCtx, context.Background(), probe); !errors.Is(err, context.Canceled) {
+		t.Fatalf("first check after hang-up = %v, want the request's cancellation", err)
+	}
+	close(release)
+	checkedAt, err := r.check(context.Background(), context.Background(), probe)
+	if err != nil || checkedAt.IsZero() {
+		t.Fatalf("check = %v, %v; the probe outlived the request and should have succeeded", checkedAt, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("probe ran %d times, want 1", n)
+	}
+}
+
+func TestReachabilityDoesNotCacheShutdown(t *testing.T) {
+	var r reachability
+	base, shutdown := context.WithCancel(context.Background())
+	shutdown()
+	blocked := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if _, err := r.check(context.Background(), base, blocked); err == nil {
+		t.Fatal("probe cut off by shutdown reported reachable")
+	}
+	if !r.checkedAt.IsZero() {
+		t.Fatalf("cancelled probe was cached at %v", r.checkedAt)
+	}
+	if _, err := r.check(context.Background(), context.Background(), func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("next probe = %v, want a fresh success", err)
+	}
+}
+
+func TestReachabilityServesStaleResultWhileProbing(t *testing.T) {
+	r := reachability{checkedAt: time.Now().UTC().Add(-2 * statusProbeTTL), err: errors.New("down")}
+	release := make(chan struct{})
+	defer close(release)
+	probe := func(context.Context) error {
+		<-release
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.check(context.Background(), context.Background(), probe)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "down" {
+			t.Fatalf("stale check = %v, want the cached failure", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("check waited on the probe instead of serving the cached result")
+	}
+}
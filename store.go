@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"alert_framework/jobstate"
+)
+
+// transcriptionColumns is the column list scanTranscription expects. Every
+// query that loads full transcription rows selects it so the two cannot drift.
+const transcriptionColumns = `id, filename, source_path, processed_path, COALESCE(ingest_source,'') as ingest_source, transcript_text, raw_transcript_text, clean_transcript_text, translation_text, status, last_error, error_code, size_bytes, duration_seconds, hash, duplicate_of, requested_model, requested_mode, requested_format, actual_openai_model_used, diarized_json, recognized_towns, normalized_transcript, call_type, call_timestamp, tags, latitude, longitude, location_label, location_source, refined_metadata, address_json, needs_manual_review, cad_incident_id, units, public_transcript, detected_language, speaker_roles, transcription_engine, draft_transcript, station_distances, page_for, deleted_at, created_at, updated_at`
+
+const selectTranscriptions = `SELECT ` + transcriptionColumns + ` FROM transcriptions`
+
+const (
+	sqlTranscriptionByFilename = selectTranscriptions + ` WHERE filename = ?`
+
+	sqlTranscriptionStatus = `SELECT status FROM transcriptions WHERE filename = ?`
+
+	sqlMarkQueued = `INSERT INTO transcriptions (filename, source_path, processed_path, ingest_source, status, size_bytes, requested_model, requested_mode, requested_format, call_timestamp, talkgroup, frequency) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(filename) DO UPDATE SET status=excluded.status, source_path=excluded.source_path, processed_path=COALESCE(excluded.processed_path, transcriptions.processed_path), ingest_source=COALESCE(excluded.ingest_source, transcriptions.ingest_source), size_bytes=COALESCE(excluded.size_bytes, transcriptions.size_bytes), requested_model=COALESCE(excluded.requested_model, transcriptions.requested_model), requested_mode=COALESCE(excluded.requested_mode, transcriptions.requested_mode), requested_format=COALESCE(excluded.requested_format, transcriptions.requested_format), call_timestamp=COALESCE(transcriptions.call_timestamp, excluded.call_timestamp), talkgroup=COALESCE(transcriptions.talkgroup, excluded.talkgroup), frequency=COALESCE(transcriptions.frequency, excluded.frequency)`
+
+	sqlMarkProcessing = `INSERT INTO transcriptions (filename, source_path, processed_path, ingest_source, status, size_bytes, requested_model, requested_mode, requested_format, call_timestamp, talkgroup, frequency) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(filename) DO UPDATE SET status=excluded.status, source_path=excluded.source_path, processed_path=COALESCE(excluded.processed_path, transcriptions.processed_path), ingest_source=COALESCE(excluded.ingest_source, transcriptions.ingest_source), size_bytes=excluded.size_bytes, requested_model=excluded.requested_model, requested_mode=excluded.requested_mode, requested_format=excluded.requested_format, call_timestamp=COALESCE(transcriptions.call_timestamp, excluded.call_timestamp), talkgroup=COALESCE(transcriptions.talkgroup, excluded.talkgroup), frequency=COALESCE(transcriptions.frequency, excluded.frequency)`
+
+	sqlMarkDone = `UPDATE transcriptions SET status=?, transcript_text=?, raw_transcript_text=?, clean_transcript_text=?, translation_text=?, last_error=?, error_code=NULL, duplicate_of=?, diarized_json=?, recognized_towns=?, normalized_transcript=?, actual_openai_model_used=?, call_type=?, tags=COALESCE(?, tags), latitude=?, longitude=?, location_label=COALESCE(?, location_label), location_source=COALESCE(?, location_source), refined_metadata=COALESCE(?, refined_metadata), address_json=COALESCE(?, address_json), needs_manual_review=? WHERE filename=?`
+
+	sqlMarkError = `UPDATE transcriptions SET status=?, last_error=?, error_code=? WHERE filename=?`
+
+	// sqlRecordCallError logs a failure only for real calls, so evaluation and
+	// shadow runs, which share the pipeline under synthetic keys, stay out.
+	sqlRecordCallError = `INSERT INTO call_errors (filename, code, message, created_at) SELECT ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM transcriptions WHERE filename = ?)`
+
+	sqlUpdateProcessedPath = `UPDATE transcriptions SET processed_path=? WHERE filename=?`
+
+	sqlUpdatePublicTranscript = `UPDATE transcriptions SET public_transcript=? WHERE filename=?`
+
+	sqlUpdateDetectedLanguage = `UPDATE transcriptions SET detected_language=? WHERE filename=?`
+
+	sqlUpdateSpeakerRoles = `UPDATE transcriptions SET speaker_roles=? WHERE filename=?`
+
+	sqlUpdateTranscriptionEngine = `UPDATE transcriptions SET transcription_engine=? WHERE filename=?`
+
+	sqlUpdateProcessingSeconds = `UPDATE transcriptions SET processing_seconds=? WHERE filename=?`
+
+	sqlUpdateTranscriptionCost = `UPDATE transcriptions SET transcribe_seconds=?, transcribe_cost_usd=? WHERE filename=?`
+
+	sqlStoreEmbedding = `UPDATE transcriptions SET embedding=? WHERE filename=?`
+)
+
+// transcriptionStore is the query layer for the transcriptions table. Reads go
+// through read and writes through write so a replica or another driver can be
+// slotted in without touching handlers; today both are the same handle.
+// Statements for hot paths are prepared once and reused.
+type transcriptionStore struct {
+	read    *sql.DB
+	write   *sql.DB
+	timeout time.Duration
+
+	mu    sync.Mutex
+	stmts map[stmtKey]*sql.Stmt
+}
+
+type stmtKey struct {
+	db    *sql.DB
+	query string
+}
+
+func newTranscriptionStore(write, read *sql.DB, timeout time.Duration) *transcriptionStore {
+	if read == nil {
+		read = write
+	}
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	return &transcriptionStore{read: read, write: write, timeout: timeout, stmts: make(map[stmtKey]*sql.Stmt)}
+}
+
+// Close releases prepared statements. The underlying databases stay open.
+func (st *transcriptionStore) Close() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var firstErr error
+	for key, stmt := range st.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(st.stmts, key)
+	}
+	return firstErr
+}
+
+func (st *transcriptionStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, st.timeout)
+}
+
+func (st *transcriptionStore) prepared(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	key := stmtKey{db: db, query: query}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if stmt, ok := st.stmts[key]; ok {
+		return stmt, nil
+	}
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	st.stmts[key] = stmt
+	return stmt, nil
+}
+
+func (st *transcriptionStore) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := st.withTimeout(ctx)
+	defer cancel()
+	var res sql.Result
+	err := withRetry(func() error {
+		stmt, err := st.prepared(ctx, st.write, query)
+		if err != nil {
+			return err
+		}
+		res, err = stmt.ExecContext(ctx, args...)
+		return err
+	})
+	return res, err
+}
+
+// transition moves filename to status in one transaction: it reads the current
+// status, rejects the change if jobstate does not allow it, and runs write on
+// the same transaction, so a step either lands completely or not at all.
+// write must only use tx; the write handle has a single connection and tx
+// holds it until commit.
+func (st *transcriptionStore) transition(ctx context.Context, filename, status string, write func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := st.withTimeout(ctx)
+	defer cancel()
+	return withRetry(func() error {
+		tx, err := st.write.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		var from string
+		if err := tx.QueryRowContext(ctx, sqlTranscriptionStatus, filename).Scan(&from); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err := jobstate.Check(from, status); err != nil {
+			return err
+		}
+		if err := write(ctx, tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// Get loads a single transcription by filename.
+func (st *transcriptionStore) Get(ctx context.Context, filename string) (*transcription, error) {
+	ctx, cancel := st.withTimeout(ctx)
+	defer cancel()
+	var t transcription
+	err := withRetry(func() error {
+		stmt, err := st.prepared(ctx, st.read, sqlTranscriptionByFilename)
+		if err != nil {
+			return err
+		}
+		return scanTranscription(stmt.QueryRowContext(ctx, filename), &t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Select loads full transcription rows; clause is appended after FROM
+// transcriptions (WHERE/ORDER BY/LIMIT). Ad-hoc clauses are not prepared.
+func (st *transcriptionStore) Select(ctx context.Context, clause string, args ...interface{}) ([]transcription, error) {
+	ctx, cancel := st.withTimeout(ctx)
+	defer cancel()
+	query := selectTranscriptions
+	if clause = strings.TrimSpace(clause); clause != "" {
+		query += " " + clause
+	}
+	var out []transcription
+	err := withRetry(func() error {
+		out = out[:0]
+		rows, err := st.read.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var t transcription
+			if err := scanTranscription(rows, &t); err != nil {
+				return err
+			}
+			out = append(out, t)
+		}
+		return rows.Err()
+	})
+	return out, err
+}
+
+// ListByIDs loads the transcriptions with the given row ids.
+func (st *transcriptionStore) ListByIDs(ctx context.Context, ids []int64) ([]transcription, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	return st.Select(ctx, "WHERE id IN ("+placeholders+")", args...)
+}
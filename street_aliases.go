@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+	"alert_framework/geofence"
+)
+
+// streetAlias maps a local name dispatchers use ("206 bypass", "the A&P
+// plaza") to a canonical address, fixed coordinates, or both. A town limits
+// the alias to calls in that town.
+type streetAlias struct {
+	ID            int64      `json:"id"`
+	Alias         string     `json:"alias"`
+	Town          string     `json:"town,omitempty"`
+	Address       string     `json:"address,omitempty"`
+	Latitude      *float64   `json:"latitude,omitempty"`
+	Longitude     *float64   `json:"longitude,omitempty"`
+	Hits          int        `json:"hits"`
+	LastMatchedAt *time.Time `json:"last_matched_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	key           string
+}
+
+type streetAliasRequest struct {
+	Alias     *string  `json:"alias"`
+	Town      *string  `json:"town"`
+	Address   *string  `json:"address"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+// apply copies the fields present in req onto a and checks the result.
+func (req streetAliasRequest) apply(a *streetAlias, region *geofence.Boundary) error {
+	if req.Alias != nil {
+		a.Alias = strings.TrimSpace(*req.Alias)
+	}
+	if req.Town != nil {
+		a.Town = strings.TrimSpace(*req.Town)
+	}
+	if req.Address != nil {
+		a.Address = strings.TrimSpace(*req.Address)
+	}
+	if req.Latitude != nil || req.Longitude != nil {
+		a.Latitude, a.Longitude = req.Latitude, req.Longitude
+	}
+	a.key = formatting.AliasKey(a.Alias)
+	if a.key == "" {
+		return errors.New("alias required")
+	}
+	if (a.Latitude == nil) != (a.Longitude == nil) {
+		return errors.New("latitude and longitude must be set together")
+	}
+	if a.Latitude != nil && !region.Contains(*a.Latitude, *a.Longitude) {
+		return errors.New("coordinates must be within the region boundary")
+	}
+	if a.Address == "" && a.Latitude == nil {
+		return errors.New("address or coordinates required")
+	}
+	return nil
+}
+
+// handleStreetAliases lists aliases (GET) or adds one (POST). Admin only.
+func (s *server) handleStreetAliases(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		aliases, err := s.loadStreetAliases(r.Context())
+		if err != nil {
+			log.Printf("street alias list failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"aliases": aliases})
+	case http.MethodPost:
+		var req streetAliasRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		alias := streetAlias{CreatedAt: time.Now().UTC()}
+		if err := req.apply(&alias, s.region); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := execWithRetry(s.db, `INSERT INTO street_aliases (alias, alias_key, town, address, latitude, longitude, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			alias.Alias, alias.key, alias.Town, alias.Address, alias.Latitude, alias.Longitude, alias.CreatedAt)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE") {
+				http.Error(w, "alias already exists", http.StatusConflict)
+				return
+			}
+			log.Printf("street alias insert failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		alias.ID, _ = res.LastInsertId()
+		noteAudit(r, "alias.create", alias.Alias, nil, alias)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		respondJSON(w, alias)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStreetAliasDetail updates (PATCH) or deletes (DELETE) one alias.
+func (s *server) handleStreetAliasDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/aliases/"), "/"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	alias, err := s.loadStreetAlias(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("street alias load failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		if _, err := execWithRetry(s.db, `DELETE FROM street_aliases WHERE id = ?`, id); err != nil {
+			log.Printf("street alias delete failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		noteAudit(r, "alias.delete", alias.Alias, alias, nil)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	before := alias
+	var req streetAliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if err := req.apply(&alias, s.region); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := execWithRetry(s.db, `UPDATE street_aliases SET alias = ?, alias_key = ?, town = ?, address = ?, latitude = ?, longitude = ? WHERE id = ?`,
+		alias.Alias, alias.key, alias.Town, alias.Address, alias.Latitude, alias.Longitude, id); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			http.Error(w, "alias already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("street alias update failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	noteAudit(r, "alias.update", alias.Alias, before, alias)
+	respondJSON(w, alias)
+}
+
+const streetAliasColumns = `id, alias, alias_key, town, address, latitude, longitude, hits, last_matched_at, created_at`
+
+func scanStreetAlias(row interface{ Scan(...interface{}) error }) (streetAlias, error) {
+	var a streetAlias
+	var lat, lng sql.NullFloat64
+	var lastMatched sql.NullTime
+	if err := row.Scan(&a.ID, &a.Alias, &a.key, &a.Town, &a.Address, &lat, &lng, &a.Hits, &lastMatched, &a.CreatedAt); err != nil {
+		return a, err
+	}
+	a.Latitude = nullFloatPtr(lat)
+	a.Longitude = nullFloatPtr(lng)
+	if lastMatched.Valid {
+		a.LastMatchedAt = &lastMatched.Time
+	}
+	return a, nil
+}
+
+func (s *server) loadStreetAlias(ctx context.Context, id int64) (streetAlias, error) {
+	return scanStreetAlias(s.db.QueryRowContext(ctx, `SELECT `+streetAliasColumns+` FROM street_aliases WHERE id = ?`, id))
+}
+
+func (s *server) loadStreetAliases(ctx context.Context) ([]streetAlias, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+streetAliasColumns+` FROM street_aliases ORDER BY alias, town`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	aliases := []streetAlias{}
+	for rows.Next() {
+		a, err := scanStreetAlias(rows)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+// matchStreetAlias finds the alias a transcript mentions. Aliases tied to the
+// call's town win over town-less ones, then the longest alias wins so "206
+// bypass" beats "206". Aliases are read from the database on every call so
+// edits made through an api-mode process reach the workers immediately.
+func (s *server) matchStreetAlias(ctx context.Context, normalized string, meta formatting.CallMetadata) *streetAlias {
+	aliases, err := s.loadStreetAliases(ctx)
+	if err != nil {
+		log.Printf("street alias lookup failed: %v", err)
+		return nil
+	}
+	if len(aliases) == 0 {
+		return nil
+	}
+	text := formatting.AliasKey(normalized)
+	town := formatting.AliasKey(meta.TownDisplay)
+	var best *streetAlias
+	bestScore := -1
+	for i := range aliases {
+		a := &aliases[i]
+		if !formatting.HasAliasKey(text, a.key) {
+			continue
+		}
+		score := len(a.key)
+		if a.Town != "" {
+			aliasTown := formatting.AliasKey(a.Town)
+			if aliasTown != town && !formatting.HasAliasKey(text, aliasTown) {
+				continue
+			}
+			score += 1000
+		}
+		if score > bestScore {
+			best, bestScore = a, score
+		}
+	}
+	if best != nil {
+		if _, err := execWithRetry(s.db, `UPDATE street_aliases SET hits = hits + 1, last_matched_at = ? WHERE id = ?`, time.Now().UTC(), best.ID); err != nil {
+			log.Printf("street alias hit update failed: %v", err)
+		}
+	}
+	return best
+}
+
+// aliasLocation turns a matched alias into what parseAndGeocodeLocation works
+// with: a finished guess when the alias has coordinates, otherwise the
+// canonical address parsed for geocoding.
+func aliasLocation(a *streetAlias, meta formatting.CallMetadata) (*locationGuess, *formatting.ParsedLocation) {
+	label := a.Address
+	if label == "" {
+		label = a.Alias
+	}
+	town := a.Town
+	if town == "" {
+		town = meta.TownDisplay
+	}
+	if a.Latitude != nil {
+		if town != "" && !strings.Contains(strings.ToLower(label), strings.ToLower(town)) {
+			label += ", " + town
+		}
+		return &locationGuess{Label: label, Latitude: *a.Latitude, Longitude: *a.Longitude, Precision: "alias", Source: "alias"}, nil
+	}
+	parsed, err := formatting.ParseLocationFromTranscript(a.Address)
+	if err != nil || parsed == nil || parsed.Street == "" {
+		parsed = &formatting.ParsedLocation{Street: a.Address, RawText: a.Address}
+	}
+	if town != "" {
+		parsed.Municipality = town
+	}
+	return nil, parsed
+}
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	minSubjectTermLen  = 3
+	defaultRedactToken = "[REDACTED]"
+)
+
+// subjectColumn is a text column searched for a subject. JSON columns are
+// redacted inside their keys and string values, so the document still
+// parses and a later search of the raw text finds nothing.
+type subjectColumn struct {
+	name string
+	json bool
+}
+
+// subjectTable lists where a subject can be mentioned: the columns searched
+// and redacted in table, whose rows are keyed by key. Rows of a drop table
+// are deleted instead of rewritten, because they are keyed by the text
+// itself or are rebuilt from the calls (corrections, location summaries,
+// rendered reports).
+type subjectTable struct {
+	table   string
+	key     string
+	columns []subjectColumn
+	drop    bool
+}
+
+var (
+	subjectCalls = subjectTable{table: "transcriptions", key: "id", columns: []subjectColumn{
+		{name: "transcript_text"},
+		{name: "raw_transcript_text"},
+		{name: "clean_transcript_text"},
+		{name: "translation_text"},
+		{name: "normalized_transcript"},
+		{name: "public_transcript"},
+		{name: "draft_transcript"},
+		{name: "location_label"},
+		{name: "diarized_json", json: true},
+		{name: "address_json", json: true},
+		{name: "refined_metadata", json: true},
+	}}
+	subjectRollups = subjectTable{table: "rollups", key: "id", columns: []subjectColumn{
+		{name: "title"},
+		{name: "summary"},
+		{name: "closing_summary"},
+		{name: "poi"},
+		{name: "merge_suggestion"},
+		{name: "evidence_json", json: true},
+	}}
+	// subjectTables are redacted in this order. The export writes the first
+	// two as calls and rollups and the rest as matching rows.
+	subjectTables = []subjectTable{
+		subjectCalls,
+		subjectRollups,
+		{table: "incident_comments", key: "id", columns: []subjectColumn{{name: "body"}}},
+		{table: "twilio_deliveries", key: "id", columns: []subjectColumn{{name: "message"}}},
+		{table: "webhook_payloads", key: "filename", columns: []subjectColumn{{name: "payload_json", json: true}}},
+		{table: "shadow_results", key: "id", columns: []subjectColumn{
+			{name: "production_transcript"},
+			{name: "shadow_transcript"},
+			{name: "production_address"},
+			{name: "shadow_address"},
+		}},
+		{table: "eval_cases", key: "id", columns: []subjectColumn{{name: "expected_transcript"}, {name: "expected_address"}}},
+		{table: "cad_incidents", key: "id", columns: []subjectColumn{{name: "address"}, {name: "raw_json", json: true}}},
+		{table: "review_items", key: "id", columns: []subjectColumn{{name: "note"}, {name: "corrections_json", json: true}}},
+		{table: "call_type_corrections", key: "id", columns: []subjectColumn{{name: "transcript"}}},
+		{table: "geocode_corrections", key: "id", drop: true, columns: []subjectColumn{{name: "phrase"}, {name: "address"}}},
+		{table: "frequent_locations", key: "location_key", drop: true, columns: []subjectColumn{{name: "location_label"}}},
+		{table: "monthly_reports", key: "month", drop: true, columns: []subjectColumn{{name: "html"}, {name: "stats_json", json: true}}},
+	}
+)
+
+// where matches rows of t that mention term in any column. Matching is
+// case-insensitive; term is already lower-cased.
+func (t subjectTable) where(prefix, term string) (string, []interface{}) {
+	parts := make([]string, len(t.columns))
+	args := make([]interface{}, len(t.columns))
+	for i, c := range t.columns {
+		parts[i] = fmt.Sprintf("instr(lower(COALESCE(%s%s, '')), ?) > 0", prefix, c.name)
+		args[i] = term
+	}
+	return "(" + strings.Join(parts, " OR ") + ")", args
+}
+
+// subjectTerm checks the address or name a request is about. Short terms
+// would match half the database, and quotes or backslashes could not be
+// redacted inside JSON cleanly.
+func subjectTerm(raw string) (string, error) {
+	term := strings.Join(strings.Fields(raw), " ")
+	if len([]rune(term)) < minSubjectTermLen {
+		return "", fmt.Errorf("term must be at least %d characters", minSubjectTermLen)
+	}
+	if strings.ContainsAny(term, `"\`) {
+		return "", errors.New("term must not contain quotes or backslashes")
+	}
+	return term, nil
+}
+
+// subjectTermHash identifies a term in the audit log without storing it,
+// which would keep the subject on record after a takedown.
+func subjectTermHash(term string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(term)))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+type subjectManifest struct {
+	Term        string         `json:"term"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	GeneratedBy string         `json:"generated_by"`
+	Calls       int            `json:"calls"`
+	Rollups     int            `json:"rollups"`
+	Rows        map[string]int `json:"rows"`
+	AudioFiles  int            `json:"audio_files"`
+}
+
+// handleSubjectExport serves GET /api/admin/subject/export?q=TERM, a zip of
+// everything held that mentions an address or a person: the calls, with
+// their audio links, notes and comments, the rollups that mention the term
+// or contain one of those calls, and the matching rows of every other
+// subject table. audio=true adds the recordings.
+func (s *server) handleSubjectExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	noteAuditSummary(r, "")
+	term, err := subjectTerm(r.URL.Query().Get("q"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	records, rollups, err := s.findSubject(r.Context(), term)
+	if err != nil {
+		log.Printf("subject export lookup failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	tables, err := s.findSubjectRows(r.Context(), term)
+	if err != nil {
+		log.Printf("subject export lookup failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	baseURL := s.resolveBaseURL(r)
+	calls := make([]transcriptionResponse, len(records))
+	for i, t := range records {
+		calls[i] = s.responseView(true, t, baseURL)
+	}
+	s.attachCallNotes(r, calls)
+	s.attachRollupNotes(r, rollups)
+
+	now := time.Now().UTC()
+	manifest := subjectManifest{Term: term, GeneratedAt: now, GeneratedBy: settingsActor(r), Calls: len(calls), Rollups: len(rollups), Rows: map[string]int{}}
+	for table, rows := range tables {
+		manifest.Rows[table] = len(rows)
+	}
+	var audio []string
+	if strings.EqualFold(r.URL.Query().Get("audio"), "true") {
+		for _, t := range records {
+			if path := s.archiveSourcePath(t); path != "" {
+				audio = append(audio, path)
+			}
+		}
+		manifest.AudioFiles = len(audio)
+	}
+
+	name := fmt.Sprintf("subject-export-%s.zip", now.Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Cache-Control", "no-store")
+	noteAudit(r, "subject.export", subjectTermHash(term), nil, map[string]interface{}{"calls": manifest.Calls, "rollups": manifest.Rollups, "rows": manifest.Rows, "audio_files": manifest.AudioFiles})
+	zw := zip.NewWriter(w)
+	if err := writeSubjectExport(zw, manifest, calls, rollups, tables, audio); err != nil {
+		log.Printf("subject export interrupted: %v", err)
+		return
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("subject export interrupted: %v", err)
+		return
+	}
+	log.Printf("subject export by %s: %d calls, %d rollups, %d audio files", manifest.GeneratedBy, len(calls), len(rollups), len(audio))
+}
+
+func writeSubjectExport(zw *zip.Writer, manifest subjectManifest, calls []transcriptionResponse, rollups []rollupResponse, tables map[string][]map[string]interface{}, audio []string) error {
+	type entry struct {
+		name string
+		v    interface{}
+	}
+	entries := []entry{{"manifest.json", manifest}, {"calls.json", calls}, {"rollups.json", rollups}}
+	for _, t := range subjectTables[2:] {
+		if rows := tables[t.table]; len(rows) > 0 {
+			entries = append(entries, entry{"tables/" + t.table + ".json", rows})
+		}
+	}
+	for _, e := range entries {
+		f, err := zw.Create(e.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(e.v); err != nil {
+			return err
+		}
+	}
+	for _, path := range audio {
+		if err := addZipFile(zw, "audio/"+filepath.Base(path), path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addZipFile(zw *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	// Recordings are already compressed.
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+// findSubject returns the calls that mention term, in its transcripts,
+// location or comments, oldest first, and the rollups that mention it or
+// contain one of those calls, newest first.
+func (s *server) findSubject(ctx context.Context, term string) ([]transcription, []rollupResponse, error) {
+	term = strings.ToLower(term)
+	callWhere, callArgs := subjectCalls.where("", term)
+	calls, err := s.store.Select(ctx, `WHERE `+callWhere+` OR filename IN (SELECT target_id FROM incident_comments WHERE target_type = ? AND instr(lower(body), ?) > 0)
+ORDER BY effective_ts`, append(callArgs, noteTargetCall, term)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	rollupWhere, args := subjectRollups.where("", term)
+	linkedWhere, linkedArgs := subjectCalls.where("t.", term)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+rollupColumns+` FROM rollups WHERE `+rollupWhere+`
+OR id IN (SELECT rc.rollup_id FROM rollup_calls rc JOIN transcriptions t ON t.id = rc.call_id WHERE `+linkedWhere+`)
+ORDER BY updated_at DESC`, append(args, linkedArgs...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var rollups []rollupResponse
+	for rows.Next() {
+		var resp rollupResponse
+		if err := scanRollup(rows, &resp); err != nil {
+			return nil, nil, err
+		}
+		rollups = append(rollups, resp)
+	}
+	return calls, rollups, rows.Err()
+}
+
+// findSubjectRows returns, for every subject table past calls and rollups,
+// the searched columns of the rows that mention term, keyed by table. JSON
+// columns are kept as documents when they parse.
+func (s *server) findSubjectRows(ctx context.Context, term string) (map[string][]map[string]interface{}, error) {
+	term = strings.ToLower(term)
+	out := map[string][]map[string]interface{}{}
+	for _, t := range subjectTables[2:] {
+		names := make([]string, len(t.columns))
+		for i, c := range t.columns {
+			names[i] = c.name
+		}
+		where, args := t.where("", term)
+		rows, err := s.db.QueryContext(ctx, `SELECT `+t.key+`, `+strings.Join(names, ", ")+` FROM `+t.table+` WHERE `+where, args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.table, err)
+		}
+		for rows.Next() {
+			var key interface{}
+			values := make([]sql.NullString, len(t.columns))
+			dest := []interface{}{&key}
+			for i := range values {
+				dest = append(dest, &values[i])
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("%s: %w", t.table, err)
+			}
+			row := map[string]interface{}{t.key: key}
+			for i, c := range t.columns {
+				switch {
+				case !values[i].Valid:
+					row[c.name] = nil
+				case c.json && json.Valid([]byte(values[i].String)):
+					row[c.name] = json.RawMessage(values[i].String)
+				default:
+					row[c.name] = values[i].String
+				}
+			}
+			out[t.table] = append(out[t.table], row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("%s: %w", t.table, err)
+		}
+	}
+	return out, nil
+}
+
+type subjectRedactRequest struct {
+	Term        string `json:"term"`
+	Replacement string `json:"replacement"`
+	DryRun      bool   `json:"dry_run"`
+}
+
+type subjectRedactResponse struct {
+	Term        string         `json:"term"`
+	Replacement string         `json:"replacement"`
+	DryRun      bool           `json:"dry_run"`
+	Rows        map[string]int `json:"rows"`
+	Entities    int            `json:"entities_removed"`
+	Calls       []string       `json:"calls"`
+}
+
+// handleSubjectRedact serves POST /api/admin/subject/redact. Every mention
+// of term in transcripts, locations, rollups, comments, SMS logs, stored
+// webhook payloads, shadow and eval transcripts, CAD incidents and review
+// notes is replaced with replacement (default [REDACTED]). Geocode
+// corrections, location summaries and monthly reports that mention it are
+// deleted, and the entities index forgets names containing it, all in one
+// transaction. dry_run reports what would change without writing. Audio is
+// untouched; purge the calls to remove it. The audit log records a hash of
+// the term, not the term.
+func (s *server) handleSubjectRedact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	noteAuditSummary(r, "")
+	var req subjectRedactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	term, err := subjectTerm(req.Term)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	replacement := strings.TrimSpace(req.Replacement)
+	if replacement == "" {
+		replacement = defaultRedactToken
+	}
+	if strings.Contains(strings.ToLower(replacement), strings.ToLower(term)) {
+		http.Error(w, "replacement must not contain the term", http.StatusBadRequest)
+		return
+	}
+	resp, err := s.redactSubject(r.Context(), term, replacement, req.DryRun)
+	if err != nil {
+		log.Printf("subject redaction failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if !req.DryRun {
+		s.queries.invalidate()
+		log.Printf("subject redaction by %s changed %d calls", settingsActor(r), len(resp.Calls))
+		noteAudit(r, "subject.redact", subjectTermHash(term), nil, map[string]interface{}{"rows": resp.Rows, "entities_removed": resp.Entities})
+	}
+	respondJSON(w, resp)
+}
+
+func (s *server) redactSubject(ctx context.Context, term, replacement string, dryRun bool) (subjectRedactResponse, error) {
+	resp := subjectRedactResponse{Term: term, Replacement: replacement, DryRun: dryRun, Rows: map[string]int{}, Calls: []string{}}
+	re := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(term))
+	lower := strings.ToLower(term)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return resp, err
+	}
+	defer tx.Rollback()
+	for _, t := range subjectTables {
+		keys, err := redactSubjectTable(ctx, tx, t, lower, re, replacement)
+		if err != nil {
+			return resp, fmt.Errorf("%s: %w", t.table, err)
+		}
+		resp.Rows[t.table] = len(keys)
+		if t.table == subjectCalls.table && len(keys) > 0 {
+			if resp.Calls, err = filenamesForIDs(ctx, tx, keys); err != nil {
+				return resp, err
+			}
+		}
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM call_entities WHERE instr(lower(name), ?) > 0`, lower)
+	if err != nil {
+		return resp, fmt.Errorf("call_entities: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	resp.Entities = int(n)
+	if dryRun {
+		return resp, nil
+	}
+	return resp, tx.Commit()
+}
+
+// redactSubjectTable rewrites the rows of t that mention term, or deletes
+// them when t is a drop table, and returns the keys of those it changed.
+func redactSubjectTable(ctx context.Context, tx *sql.Tx, t subjectTable, term string, re *regexp.Regexp, replacement string) ([]interface{}, error) {
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.name
+	}
+	where, args := t.where("", term)
+	if t.drop {
+		res, err := tx.ExecContext(ctx, `DELETE FROM `+t.table+` WHERE `+where, args...)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := res.RowsAffected()
+		return make([]interface{}, n), nil
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT `+t.key+`, `+strings.Join(names, ", ")+` FROM `+t.table+` WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	type change struct {
+		key    interface{}
+		values []sql.NullString
+	}
+	var changes []change
+	for rows.Next() {
+		var key interface{}
+		values := make([]sql.NullString, len(t.columns))
+		dest := []interface{}{&key}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		changed := false
+		for i, c := range t.columns {
+			if !values[i].Valid {
+				continue
+			}
+			out := redactText(values[i].String, c.json, re, replacement)
+			if out != values[i].String {
+				values[i].String, changed = out, true
+			}
+		}
+		if changed {
+			changes = append(changes, change{key: key, values: values})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	update := `UPDATE ` + t.table + ` SET ` + strings.Join(names, " = ?, ") + ` = ? WHERE ` + t.key + ` = ?`
+	keys := make([]interface{}, 0, len(changes))
+	for _, c := range changes {
+		args := make([]interface{}, 0, len(c.values)+1)
+		for _, v := range c.values {
+			args = append(args, v)
+		}
+		if _, err := tx.ExecContext(ctx, update, append(args, c.key)...); err != nil {
+			return nil, err
+		}
+		keys = append(keys, c.key)
+	}
+	return keys, nil
+}
+
+// redactText replaces every match of re in text. JSON is rewritten key by
+// key and value by value; text that does not parse as JSON is treated as
+// plain text.
+func redactText(text string, isJSON bool, re *regexp.Regexp, replacement string) string {
+	if !isJSON {
+		return re.ReplaceAllLiteralString(text, replacement)
+	}
+	var doc interface{}
+	if err := json.Unmarshal([]byte(text), &doc); err != nil {
+		return re.ReplaceAllLiteralString(text, replacement)
+	}
+	changed := false
+	var walk func(v interface{}) interface{}
+	walk = func(v interface{}) interface{} {
+		switch val := v.(type) {
+		case string:
+			out := re.ReplaceAllLiteralString(val, replacement)
+			if out != val {
+				changed = true
+			}
+			return out
+		case []interface{}:
+			for i := range val {
+				val[i] = walk(val[i])
+			}
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(val))
+			for k, inner := range val {
+				key := re.ReplaceAllLiteralString(k, replacement)
+				if key != k {
+					changed = true
+				}
+				out[key] = walk(inner)
+			}
+			return out
+		}
+		return v
+	}
+	doc = walk(doc)
+	if !changed {
+		return text
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return text
+	}
+	return string(out)
+}
+
+func filenamesForIDs(ctx context.Context, tx *sql.Tx, ids []interface{}) ([]string, error) {
+	var out []string
+	for _, id := range ids {
+		var name string
+		if err := tx.QueryRowContext(ctx, `SELECT filename FROM transcriptions WHERE id = ?`, id).Scan(&name); err != nil {
+			return nil, err
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"alert_framework/config"
+)
+
+const subjectTestTerm = "14 Birch Lane"
+
+// seedSubject stores one mention of subjectTestTerm in every subject table
+// and returns the call's id.
+func seedSubject(t *testing.T, db *sql.DB) int64 {
+	t.Helper()
+	now := time.Now().UTC()
+	id := insertTestCall(t, db, testCall{filename: "subject.mp3", callType: "medical", transcript: "Engine 2 respond to 14 birch lane for a fall", at: now, label: "14 Birch Lane, Sparta"})
+	stmts := []struct {
+		q    string
+		args []interface{}
+	}{
+		{`UPDATE transcriptions SET address_json = ?, diarized_json = ? WHERE id = ?`, []interface{}{
+			`{"label":"14 Birch Lane","street":"Birch Lane","14 Birch Lane":"key kept"}`,
+			`[{"speaker":"dispatch","text":"respond to 14 BIRCH LANE"}]`, id}},
+		{`INSERT INTO rollups (rollup_key, start_at, end_at, latitude, longitude, category, priority, title, evidence_json, status, call_count) VALUES ('k', ?, ?, 41.05, -74.75, 'ems', 'normal', 'Fall at 14 Birch Lane', '["14 Birch Lane"]', 'llm_skipped', 1)`, []interface{}{now, now}},
+		{`INSERT INTO shadow_results (filename, production_transcript, shadow_address, created_at) VALUES ('subject.mp3', 'respond to 14 Birch Lane', '14 Birch Lane', ?)`, []interface{}{now}},
+		{`INSERT INTO eval_cases (name, call_filename, expected_transcript, expected_address, audio_path, created_at) VALUES ('fall', 'subject.mp3', 'respond to 14 Birch Lane', '14 Birch Lane', '', ?)`, []interface{}{now}},
+		{`INSERT INTO cad_incidents (external_id, incident_at, address, raw_json) VALUES ('cad-1', ?, '14 Birch Lane', '{"location":{"address":"14 Birch Lane"},"units":["E2"]}')`, []interface{}{now}},
+		{`INSERT INTO review_items (filename, flagged_at, note, corrections_json) VALUES ('subject.mp3', ?, 'caller at 14 Birch Lane', '{"location_label":"14 Birch Lane"}')`, []interface{}{now}},
+		{`INSERT INTO call_type_corrections (filename, transcript, original_type, corrected_type, created_at) VALUES ('subject.mp3', 'Engine 2 respond to 14 Birch Lane', 'medical', 'fall', ?)`, []interface{}{now}},
+		{`INSERT INTO geocode_corrections (phrase, phrase_key, town, address, created_at, updated_at) VALUES ('14 birch lane', '14 birch lane', 'Sparta', '14 Birch Lane', ?, ?)`, []interface{}{now, now}},
+		{`INSERT INTO frequent_locations (location_key, location_label, calls, first_call_at, last_call_at, updated_at) VALUES ('14 birch lane, sparta', '14 Birch Lane, Sparta', 2, ?, ?, ?)`, []interface{}{now, now, now}},
+		{`INSERT INTO monthly_reports (month, job_id, stats_json, html, pdf, generated_at) VALUES ('2026-09', 1, '{}', '<td>14 Birch Lane</td>', x'00', ?)`, []interface{}{now}},
+		{`INSERT INTO call_entities (filename, kind, name, entity_key, created_at) VALUES ('subject.mp3', 'place', '14 Birch Lane', '14 birch lane', ?)`, []interface{}{now}},
+	}
+	for _, st := range stmts {
+		if _, err := db.Exec(st.q, st.args...); err != nil {
+			t.Fatalf("%s: %v", st.q, err)
+		}
+	}
+	return id
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestRedactSubjectRewritesJSONValues(t *testing.T) {
+	s := newTestServer(t, config.Config{})
+	id := seedSubject(t, s.db)
+
+	resp, err := s.redactSubject(context.Background(), subjectTestTerm, defaultRedactToken, false)
+	if err != nil {
+		t.Fatalf("redact: %v", err)
+	}
+	if len(resp.Calls) != 1 || resp.Calls[0] != "subject.mp3" {
+		t.Fatalf("calls = %v", resp.Calls)
+	}
+	var addr, diarized string
+	if err := s.db.QueryRow(`SELECT address_json, diarized_json FROM transcriptions WHERE id = ?`, id).Scan(&addr, &diarized); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]string
+	if err := json.Unmarshal([]byte(addr), &doc); err != nil {
+		t.Fatalf("address_json no longer parses: %v (%s)", err, addr)
+	}
+	if doc["label"] != defaultRedactToken || doc[defaultRedactToken] != "key kept" {
+		t.Fatalf("address_json = %s", addr)
+	}
+	var turns []map[string]string
+	if err := json.Unmarshal([]byte(diarized), &turns); err != nil || turns[0]["text"] != "respond to "+defaultRedactToken {
+		t.Fatalf("diarized_json = %s (%v)", diarized, err)
+	}
+	var raw string
+	if err := s.db.QueryRow(`SELECT raw_json FROM cad_incidents`).Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid([]byte(raw)) || strings.Contains(raw, "Birch") {
+		t.Fatalf("cad raw_json = %s", raw)
+	}
+}
+
+func TestRedactSubjectDryRunWritesNothing(t *testing.T) {
+	s := newTestServer(t, config.Config{})
+	seedSubject(t, s.db)
+
+	resp, err := s.redactSubject(context.Background(), subjectTestTerm, defaultRedactToken, true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	for _, table := range []string{"transcriptions", "rollups", "shadow_results", "eval_cases", "cad_incidents", "review_items", "call_type_corrections", "geocode_corrections", "frequent_locations", "monthly_reports"} {
+		if resp.Rows[table] != 1 {
+			t.Errorf("dry run reports %d %s rows, want 1", resp.Rows[table], table)
+		}
+	}
+	if resp.Entities != 1 {
+		t.Errorf("dry run reports %d entities, want 1", resp.Entities)
+	}
+	calls, rollups, err := s.findSubject(context.Background(), subjectTestTerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || len(rollups) != 1 {
+		t.Fatalf("dry run changed data: %d calls, %d rollups still match", len(calls), len(rollups))
+	}
+	for _, table := range []string{"geocode_corrections", "frequent_locations", "monthly_reports", "call_entities"} {
+		if n := countRows(t, s.db, table); n != 1 {
+			t.Errorf("dry run deleted from %s: %d rows left", table, n)
+		}
+	}
+}
+
+func TestRedactSubjectLeavesNothingToFind(t *testing.T) {
+	s := newTestServer(t, config.Config{})
+	seedSubject(t, s.db)
+
+	if _, err := s.redactSubject(context.Background(), subjectTestTerm, defaultRedactToken, false); err != nil {
+		t.Fatalf("redact: %v", err)
+	}
+	calls, rollups, err := s.findSubject(context.Background(), subjectTestTerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 0 || len(rollups) != 0 {
+		t.Fatalf("after redaction %d calls and %d rollups still match", len(calls), len(rollups))
+	}
+	tables, err := s.findSubjectRows(context.Background(), subjectTestTerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, rows := range tables {
+		t.Errorf("after redaction %d %s rows still match: %v", len(rows), table, rows)
+	}
+	for _, table := range []string{"geocode_corrections", "frequent_locations", "monthly_reports", "call_entities"} {
+		if n := countRows(t, s.db, table); n != 0 {
+			t.Errorf("%s kept %d rows", table, n)
+		}
+	}
+	resp, err := s.redactSubject(context.Background(), subjectTestTerm, defaultRedactToken, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for table, n := range resp.Rows {
+		if n != 0 {
+			t.Errorf("second pass would change %d %s rows", n, table)
+		}
+	}
+}
+
+func TestSubjectRedactAuditOmitsTerm(t *testing.T) {
+	t.Setenv("ENABLE_ADMIN_ACTIONS", "true")
+	t.Setenv("ADMIN_TOKEN", "secret")
+	s := newTestServer(t, config.Config{})
+	seedSubject(t, s.db)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/subject/redact", strings.NewReader(`{"term":"`+subjectTestTerm+`"}`))
+	req.Header.Set("X-Admin-Token", "secret")
+	rec := httptest.NewRecorder()
+	s.withAudit(http.HandlerFunc(s.handleSubjectRedact)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var target, entry string
+	if err := s.db.QueryRow(`SELECT target, action || COALESCE(summary, '') || COALESCE(after_json, '') FROM audit_log WHERE action = 'subject.redact'`).Scan(&target, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if target != subjectTermHash(subjectTestTerm) {
+		t.Errorf("target = %q, want the term hash", target)
+	}
+	if strings.Contains(strings.ToLower(target+entry), "birch") {
+		t.Errorf("audit entry holds the term: %s %s", target, entry)
+	}
+	if !strings.Contains(entry, `"transcriptions":1`) {
+		t.Errorf("audit entry lacks row counts: %s", entry)
+	}
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alert_framework/formatting"
+)
+
+const (
+	defaultTalkgroupStatsDays = 30
+	maxTalkgroupStatsDays     = 365
+	talkgroupBusiestHours     = 3
+	// talkgroupBaselineDays is how far back a talkgroup's usual call rate is
+	// measured when deciding whether it has gone silent.
+	talkgroupBaselineDays   = 7
+	talkgroupSilenceCheck   = 5 * time.Minute
+	maxTalkgroupMetadataLen = 100
+)
+
+type talkgroupHour struct {
+	Hour  int `json:"hour"`
+	Calls int `json:"calls"`
+}
+
+type talkgroupStats struct {
+	Talkgroup          string          `json:"talkgroup"`
+	Frequencies        []string        `json:"frequencies,omitempty"`
+	Calls              int             `json:"calls"`
+	CallsPerDay        float64         `json:"calls_per_day"`
+	AvgDurationSeconds *float64        `json:"avg_duration_seconds,omitempty"`
+	FirstCallAt        time.Time       `json:"first_call_at"`
+	LastCallAt         time.Time       `json:"last_call_at"`
+	Hourly             [24]int         `json:"hourly"`
+	BusiestHours       []talkgroupHour `json:"busiest_hours"`
+	Silent             bool            `json:"silent"`
+	SilentMinutes      *int            `json:"silent_minutes,omitempty"`
+}
+
+type talkgroupStatsResponse struct {
+	GeneratedAt         time.Time        `json:"generated_at"`
+	Since               time.Time        `json:"since"`
+	Days                int              `json:"days"`
+	SilenceAfterMinutes int              `json:"silence_after_minutes,omitempty"`
+	Talkgroups          []talkgroupStats `json:"talkgroups"`
+}
+
+// talkgroupCall is the part of a call the talkgroup stats use.
+type talkgroupCall struct {
+	talkgroup string
+	frequency string
+	at        time.Time
+	duration  sql.NullFloat64
+}
+
+// silentTalkgroup is a normally active talkgroup with no recent calls.
+type silentTalkgroup struct {
+	Talkgroup   string
+	LastCallAt  time.Time
+	CallsPerDay float64
+}
+
+// talkgroupWatch remembers which talkgroups have been reported silent so each
+// silence is announced once, and once more when calls resume.
+type talkgroupWatch struct {
+	mu     sync.Mutex
+	silent map[string]time.Time // talkgroup -> last call when reported
+}
+
+func migrateAddTalkgroups(db *sql.DB) error {
+	for _, col := range []struct{ table, name string }{
+		{"transcriptions", "talkgroup"},
+		{"transcriptions", "frequency"},
+		{"uploads", "talkgroup"},
+		{"uploads", "frequency"},
+	} {
+		if err := addColumnIfMissing(db, col.table, col.name, "TEXT NULL"); err != nil {
+			return err
+		}
+	}
+	if _, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_transcriptions_talkgroup ON transcriptions(talkgroup, call_timestamp)`); err != nil {
+		return err
+	}
+	// Calls recorded before this migration get their talkgroup from their
+	// filename, as new ones without source metadata do.
+	rows, err := queryWithRetry(db, `SELECT filename FROM transcriptions WHERE talkgroup IS NULL`)
+	if err != nil {
+		return err
+	}
+	var filenames []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		filenames = append(filenames, name)
+	}
+	rows.Close()
+	for _, name := range filenames {
+		if tg := filenameTalkgroup(name); tg != "" {
+			if _, err := execWithRetry(db, `UPDATE transcriptions SET talkgroup = ? WHERE filename = ?`, tg, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func downTalkgroups(db *sql.DB) error {
+	if _, err := execWithRetry(db, `DROP INDEX IF EXISTS idx_transcriptions_talkgroup`); err != nil {
+		return err
+	}
+	for _, col := range []struct{ table, name string }{
+		{"transcriptions", "talkgroup"},
+		{"transcriptions", "frequency"},
+		{"uploads", "talkgroup"},
+		{"uploads", "frequency"},
+	} {
+		if err := dropColumnIfExists(db, col.table, col.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filenameTalkgroup is the talkgroup named by a recording's filename: the
+// agency and channel ahead of the timestamp, e.g. "Sparta Fire GEN".
+func filenameTalkgroup(filename string) string {
+	meta, err := formatting.ParseCallMetadataFromFilename(filename, time.UTC)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(meta.AgencyDisplay + " " + meta.CallType)
+}
+
+// callTalkgroup returns the talkgroup and frequency to record for a call.
+// Metadata sent with an upload wins over the filename.
+func (s *server) callTalkgroup(filename string) (*string, *string) {
+	var talkgroup, frequency sql.NullString
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&talkgroup, &frequency)
+	}, `SELECT talkgroup, frequency FROM uploads WHERE filename = ? AND status = ? ORDER BY created_at DESC LIMIT 1`, filename, uploadStatusComplete)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("upload metadata lookup for %s failed: %v", filename, err)
+	}
+	if !talkgroup.Valid || strings.TrimSpace(talkgroup.String) == "" {
+		talkgroup.String = ""
+		if meta, err := s.parseCallMetadata(filename, ""); err == nil {
+			talkgroup.String = strings.TrimSpace(meta.AgencyDisplay + " " + meta.CallType)
+		}
+	}
+	return nullableString(talkgroup.String), nullableString(frequency.String)
+}
+
+func (s *server) talkgroupCalls(ctx context.Context, since time.Time, talkgroup string) ([]talkgroupCall, error) {
+	query := `SELECT talkgroup, COALESCE(frequency, ''), call_timestamp, duration_seconds FROM transcriptions
+WHERE talkgroup IS NOT NULL AND call_timestamp >= ? AND duplicate_of IS NULL AND COALESCE(ingest_source, '') != ?`
+	args := []interface{}{since, ingestSourceCAD}
+	if talkgroup != "" {
+		query += ` AND talkgroup = ?`
+		args = append(args, talkgroup)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var calls []talkgroupCall
+	for rows.Next() {
+		var c talkgroupCall
+		if err := rows.Scan(&c.talkgroup, &c.frequency, &c.at, &c.duration); err != nil {
+			return nil, err
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
+
+// silentTalkgroups returns talkgroups that averaged at least
+// TALKGROUP_SILENCE_MIN_DAILY_CALLS over the last week but have had no call
+// for TALKGROUP_SILENCE_MIN minutes.
+func (s *server) silentTalkgroups(ctx context.Context, now time.Time) ([]silentTalkgroup, error) {
+	cfg := s.liveConfig()
+	if cfg.TalkgroupSilenceMin <= 0 {
+		return nil, nil
+	}
+	calls, err := s.talkgroupCalls(ctx, now.AddDate(0, 0, -talkgroupBaselineDays), "")
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	last := map[string]time.Time{}
+	for _, c := range calls {
+		counts[c.talkgroup]++
+		if c.at.After(last[c.talkgroup]) {
+			last[c.talkgroup] = c.at
+		}
+	}
+	silenceAfter := time.Duration(cfg.TalkgroupSilenceMin) * time.Minute
+	var out []silentTalkgroup
+	for tg, n := range counts {
+		perDay := float64(n) / talkgroupBaselineDays
+		if perDay < float64(cfg.TalkgroupMinDailyCalls) || now.Sub(last[tg]) < silenceAfter {
+			continue
+		}
+		out = append(out, silentTalkgroup{Talkgroup: tg, LastCallAt: last[tg], CallsPerDay: perDay})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Talkgroup < out[j].Talkgroup })
+	return out, nil
+}
+
+// handleTalkgroupStats serves GET /api/stats/talkgroups[?days=30][&talkgroup=]:
+// call counts, hourly activity, busiest hours and average duration per
+// talkgroup over the last days, with silent talkgroups flagged.
+func (s *server) handleTalkgroupStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	days := defaultTalkgroupStatsDays
+	if raw := strings.TrimSpace(r.URL.Query().Get("days")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxTalkgroupStatsDays {
+			http.Error(w, "days must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+		days = v
+	}
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -days)
+	calls, err := s.talkgroupCalls(r.Context(), since, strings.TrimSpace(r.URL.Query().Get("talkgroup")))
+	if err != nil {
+		log.Printf("talkgroup stats query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	silent, err := s.silentTalkgroups(r.Context(), now)
+	if err != nil {
+		log.Printf("talkgroup silence query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	resp := talkgroupStatsResponse{
+		GeneratedAt:         now,
+		Since:               since,
+		Days:                days,
+		SilenceAfterMinutes: s.liveConfig().TalkgroupSilenceMin,
+		Talkgroups:          summarizeTalkgroups(calls, days, s.tz),
+	}
+	silentSet := map[string]bool{}
+	for _, tg := range silent {
+		silentSet[tg.Talkgroup] = true
+	}
+	for i := range resp.Talkgroups {
+		tg := &resp.Talkgroups[i]
+		if silentSet[tg.Talkgroup] {
+			minutes := int(now.Sub(tg.LastCallAt).Minutes())
+			tg.Silent, tg.SilentMinutes = true, &minutes
+		}
+	}
+	respondJSON(w, resp)
+}
+
+// summarizeTalkgroups aggregates calls per talkgroup, busiest first. Hours
+// are local to tz.
+func summarizeTalkgroups(calls []talkgroupCall, days int, tz *time.Location) []talkgroupStats {
+	byName := map[string]*talkgroupStats{}
+	freqs := map[string]map[string]bool{}
+	durations := map[string][2]float64{} // total seconds, samples
+	for _, c := range calls {
+		tg, ok := byName[c.talkgroup]
+		if !ok {
+			tg = &talkgroupStats{Talkgroup: c.talkgroup, FirstCallAt: c.at, LastCallAt: c.at}
+			byName[c.talkgroup] = tg
+			freqs[c.talkgroup] = map[string]bool{}
+		}
+		tg.Calls++
+		tg.Hourly[c.at.In(tz).Hour()]++
+		if c.at.Before(tg.FirstCallAt) {
+			tg.FirstCallAt = c.at
+		}
+		if c.at.After(tg.LastCallAt) {
+			tg.LastCallAt = c.at
+		}
+		if c.frequency != "" {
+			freqs[c.talkgroup][c.frequency] = true
+		}
+		if c.duration.Valid && c.duration.Float64 > 0 {
+			d := durations[c.talkgroup]
+			durations[c.talkgroup] = [2]float64{d[0] + c.duration.Float64, d[1] + 1}
+		}
+	}
+	out := make([]talkgroupStats, 0, len(byName))
+	for name, tg := range byName {
+		tg.CallsPerDay = float64(tg.Calls) / float64(days)
+		if d := durations[name]; d[1] > 0 {
+			avg := d[0] / d[1]
+			tg.AvgDurationSeconds = &avg
+		}
+		for f := range freqs[name] {
+			tg.Frequencies = append(tg.Frequencies, f)
+		}
+		sort.Strings(tg.Frequencies)
+		tg.BusiestHours = busiestHours(tg.Hourly, talkgroupBusiestHours)
+		out = append(out, *tg)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Calls != out[j].Calls {
+			return out[i].Calls > out[j].Calls
+		}
+		return out[i].Talkgroup < out[j].Talkgroup
+	})
+	return out
+}
+
+// busiestHours returns up to n hours with calls, most calls first.
+func busiestHours(hourly [24]int, n int) []talkgroupHour {
+	hours := make([]talkgroupHour, 0, 24)
+	for h, calls := range hourly {
+		if calls > 0 {
+			hours = append(hours, talkgroupHour{Hour: h, Calls: calls})
+		}
+	}
+	sort.SliceStable(hours, func(i, j int) bool { return hours[i].Calls > hours[j].Calls })
+	if len(hours) > n {
+		hours = hours[:n]
+	}
+	return hours
+}
+
+// startTalkgroupSilenceWatch checks for silent talkgroups every few minutes
+// and alerts TALKGROUP_SILENCE_GROUPME_BOT_ID and TALKGROUP_SILENCE_WEBHOOK_URL
+// when one goes quiet and again when it comes back.
+func (s *server) startTalkgroupSilenceWatch(ctx context.Context) {
+	watch := &talkgroupWatch{silent: map[string]time.Time{}}
+	go func() {
+		ticker := time.NewTicker(talkgroupSilenceCheck)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+			cfg := s.liveConfig()
+			if cfg.TalkgroupSilenceMin <= 0 || (cfg.TalkgroupSilenceBotID == "" && cfg.TalkgroupWebhookURL == "") {
+				continue
+			}
+			s.checkTalkgroupSilence(ctx, watch)
+		}
+	}()
+}
+
+func (s *server) checkTalkgroupSilence(ctx context.Context, watch *talkgroupWatch) {
+	now := time.Now().UTC()
+	silent, err := s.silentTalkgroups(ctx, now)
+	if err != nil {
+		log.Printf("talkgroup silence check failed: %v", err)
+		return
+	}
+	current := map[string]silentTalkgroup{}
+	for _, tg := range silent {
+		current[tg.Talkgroup] = tg
+	}
+	watch.mu.Lock()
+	var started []silentTalkgroup
+	left := map[string]time.Time{}
+	for name, tg := range current {
+		if _, known := watch.silent[name]; !known {
+			watch.silent[name] = tg.LastCallAt
+			started = append(started, tg)
+		}
+	}
+	for name, lastCall := range watch.silent {
+		if _, still := current[name]; !still {
+			delete(watch.silent, name)
+			left[name] = lastCall
+		}
+	}
+	watch.mu.Unlock()
+	// A talkgroup also leaves the silent list when a long silence drags its
+	// weekly average under the threshold; only a new call counts as resumed.
+	var resumed []string
+	for name, lastCall := range left {
+		calls, err := s.talkgroupCalls(ctx, lastCall.Add(time.Second), name)
+		if err != nil {
+			log.Printf("talkgroup silence check for %s failed: %v", name, err)
+			continue
+		}
+		if len(calls) > 0 {
+			resumed = append(resumed, name)
+		}
+	}
+	sort.Slice(started, func(i, j int) bool { return started[i].Talkgroup < started[j].Talkgroup })
+	sort.Strings(resumed)
+	for _, tg := range started {
+		minutes := int(now.Sub(tg.LastCallAt).Minutes())
+		text := fmt.Sprintf("🔇 No calls on %s for %d min (usually %.0f a day)", tg.Talkgroup, minutes, tg.CallsPerDay)
+		s.sendTalkgroupAlert("talkgroup.silent", tg.Talkgroup, tg.LastCallAt, text)
+	}
+	for _, name := range resumed {
+		s.sendTalkgroupAlert("talkgroup.resumed", name, now, "🔊 Calls resumed on "+name)
+	}
+}
+
+func (s *server) sendTalkgroupAlert(event, talkgroup string, lastCallAt time.Time, text string) {
+	cfg := s.liveConfig()
+	log.Printf("%s: %s", event, talkgroup)
+	if cfg.TalkgroupSilenceBotID != "" {
+		if err := s.postGroupMe(cfg.TalkgroupSilenceBotID, text); err != nil {
+			log.Printf("%s groupme for %s failed: %v", event, talkgroup, err)
+		}
+	}
+	if cfg.TalkgroupWebhookURL != "" {
+		body, _ := json.Marshal(map[string]interface{}{
+			"event":        event,
+			"talkgroup":    talkgroup,
+			"last_call_at": lastCallAt.UTC().Format(time.RFC3339),
+			"message":      text,
+		})
+		status, _, err := s.sendWebhook(cfg.TalkgroupWebhookURL, cfg.TalkgroupWebhookSecret, body)
+		if err == nil && status >= 300 {
+			err = fmt.Errorf("status %d", status)
+		}
+		if err != nil {
+			log.Printf("%s webhook for %s failed: %v", event, talkgroup, err)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/formatting"
+)
+
+// transcriptTerm is a normalization dictionary entry: a local radio code or
+// abbreviation ("10-50", "BLS") and the text normalized transcripts spell it
+// out as. A town limits the entry to calls in that town, and wins over a
+// town-less entry for the same term.
+type transcriptTerm struct {
+	ID        int64     `json:"id"`
+	Term      string    `json:"term"`
+	Expansion string    `json:"expansion"`
+	Town      string    `json:"town,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	key       string
+}
+
+type transcriptTermRequest struct {
+	Term      *string `json:"term"`
+	Expansion *string `json:"expansion"`
+	Town      *string `json:"town"`
+}
+
+const maxTranscriptTermPreviewBytes = 64 << 10
+
+func migrateAddTranscriptTerms(db *sql.DB) error {
+	_, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS transcript_terms (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    term TEXT NOT NULL,
+    term_key TEXT NOT NULL,
+    expansion TEXT NOT NULL,
+    town TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE(term_key, town)
+);`)
+	return err
+}
+
+// apply copies the fields present in req onto t and checks the result.
+func (req transcriptTermRequest) apply(t *transcriptTerm) error {
+	if req.Term != nil {
+		t.Term = strings.Join(strings.Fields(*req.Term), " ")
+	}
+	if req.Expansion != nil {
+		t.Expansion = strings.TrimSpace(*req.Expansion)
+	}
+	if req.Town != nil {
+		t.Town = strings.TrimSpace(*req.Town)
+	}
+	t.key = strings.ToLower(t.Term)
+	if t.key == "" {
+		return errors.New("term required")
+	}
+	if t.Expansion == "" {
+		return errors.New("expansion required")
+	}
+	return nil
+}
+
+// handleTranscriptTerms lists dictionary entries (GET) or adds one (POST).
+// Admin only.
+func (s *server) handleTranscriptTerms(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		terms, err := s.loadTranscriptTerms(r.Context())
+		if err != nil {
+			log.Printf("transcript term list failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, map[string]interface{}{"terms": terms})
+	case http.MethodPost:
+		var req transcriptTermRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json", http.StatusBadRequest)
+			return
+		}
+		now := time.Now().UTC()
+		term := transcriptTerm{CreatedAt: now, UpdatedAt: now}
+		if err := req.apply(&term); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := execWithRetry(s.db, `INSERT INTO transcript_terms (term, term_key, expansion, town, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			term.Term, term.key, term.Expansion, term.Town, term.CreatedAt, term.UpdatedAt)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE") {
+				http.Error(w, "term already exists", http.StatusConflict)
+				return
+			}
+			log.Printf("transcript term insert failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		term.ID, _ = res.LastInsertId()
+		noteAudit(r, "term.create", term.Term, nil, term)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		respondJSON(w, term)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTranscriptTermDetail updates (PATCH) or deletes (DELETE) one entry.
+func (s *server) handleTranscriptTermDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/terms/"), "/"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	term, err := s.loadTranscriptTerm(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("transcript term load failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		if _, err := execWithRetry(s.db, `DELETE FROM transcript_terms WHERE id = ?`, id); err != nil {
+			log.Printf("transcript term delete failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		noteAudit(r, "term.delete", term.Term, term, nil)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	before := term
+	var req transcriptTermRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if err := req.apply(&term); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	term.UpdatedAt = time.Now().UTC()
+	if _, err := execWithRetry(s.db, `UPDATE transcript_terms SET term = ?, term_key = ?, expansion = ?, town = ?, updated_at = ? WHERE id = ?`,
+		term.Term, term.key, term.Expansion, term.Town, term.UpdatedAt, id); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			http.Error(w, "term already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("transcript term update failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	noteAudit(r, "term.update", term.Term, before, term)
+	respondJSON(w, term)
+}
+
+// handleTranscriptTermPreview serves POST /api/admin/terms/preview: it
+// normalizes a sample transcript with and without the dictionary. Entries in
+// the request's terms are added to the stored ones, so an entry can be tried
+// before it is saved.
+func (s *server) handleTranscriptTermPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req struct {
+		Text  string                  `json:"text"`
+		Town  string                  `json:"town"`
+		Terms []transcriptTermRequest `json:"terms"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTranscriptTermPreviewBytes)).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	stored, err := s.loadTranscriptTerms(r.Context())
+	if err != nil {
+		log.Printf("transcript term list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	// Candidates go first so they override stored entries for the same term.
+	var candidates []transcriptTerm
+	for _, c := range req.Terms {
+		var term transcriptTerm
+		if err := c.apply(&term); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		candidates = append(candidates, term)
+	}
+	terms := termsForTown(append(candidates, stored...), req.Town)
+	expanded, matched := formatting.ExpandTerms(req.Text, terms)
+	if matched == nil {
+		matched = []string{}
+	}
+	respondJSON(w, map[string]interface{}{
+		"text":       req.Text,
+		"generic":    formatting.NormalizeTranscript(req.Text),
+		"normalized": formatting.NormalizeTranscript(expanded),
+		"matched":    matched,
+	})
+}
+
+// normalizeTranscript is formatting.NormalizeTranscript with the dictionary
+// entries for the call's town. Entries are read from the database on every
+// call so edits made through an api-mode process reach the workers
+// immediately.
+func (s *server) normalizeTranscript(text string, meta formatting.CallMetadata) string {
+	terms, err := s.loadTranscriptTerms(context.Background())
+	if err != nil {
+		log.Printf("transcript term lookup failed: %v", err)
+	}
+	return formatting.NormalizeTranscriptWith(text, termsForTown(terms, meta.TownDisplay))
+}
+
+// termsForTown picks one entry per term: the first entry for the town, or
+// else the first town-less one. Entries for other towns are left out.
+func termsForTown(terms []transcriptTerm, town string) []formatting.Term {
+	townKey := formatting.AliasKey(town)
+	best := make(map[string]transcriptTerm, len(terms))
+	var order []string
+	for _, t := range terms {
+		if t.Town != "" && formatting.AliasKey(t.Town) != townKey {
+			continue
+		}
+		cur, seen := best[t.key]
+		if !seen {
+			order = append(order, t.key)
+		}
+		if !seen || (cur.Town == "" && t.Town != "") {
+			best[t.key] = t
+		}
+	}
+	out := make([]formatting.Term, 0, len(order))
+	for _, key := range order {
+		out = append(out, formatting.Term{Term: best[key].Term, Expansion: best[key].Expansion})
+	}
+	return out
+}
+
+const transcriptTermColumns = `id, term, term_key, expansion, town, created_at, updated_at`
+
+func scanTranscriptTerm(row interface{ Scan(...interface{}) error }) (transcriptTerm, error) {
+	var t transcriptTerm
+	err := row.Scan(&t.ID, &t.Term, &t.key, &t.Expansion, &t.Town, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+func (s *server) loadTranscriptTerm(ctx context.Context, id int64) (transcriptTerm, error) {
+	return scanTranscriptTerm(s.db.QueryRowContext(ctx, `SELECT `+transcriptTermColumns+` FROM transcript_terms WHERE id = ?`, id))
+}
+
+func (s *server) loadTranscriptTerms(ctx context.Context) ([]transcriptTerm, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+transcriptTermColumns+` FROM transcript_terms ORDER BY term_key, town`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	terms := []transcriptTerm{}
+	for rows.Next() {
+		t, err := scanTranscriptTerm(rows)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	return terms, rows.Err()
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	trendRefreshInterval = 10 * time.Minute
+	trendDayLayout       = "2006-01-02"
+	trendMonthLayout     = "2006-01"
+	maxTrendDays         = 366
+	maxTrendMonths       = 120
+)
+
+type trendCount struct {
+	Period       string   `json:"period,omitempty"`
+	Key          string   `json:"key,omitempty"`
+	Calls        int      `json:"calls"`
+	PreviousYear int      `json:"previous_year"`
+	ChangePct    *float64 `json:"change_pct,omitempty"`
+}
+
+type trendsResponse struct {
+	Period         string       `json:"period"`
+	From           string       `json:"from"`
+	To             string       `json:"to"`
+	Town           string       `json:"town,omitempty"`
+	CallType       string       `json:"call_type,omitempty"`
+	Series         []trendCount `json:"series"`
+	Total          trendCount   `json:"total"`
+	GroupBy        string       `json:"group_by,omitempty"`
+	Breakdown      []trendCount `json:"breakdown,omitempty"`
+	UpdatedThrough *time.Time   `json:"updated_through,omitempty"`
+}
+
+// startTrendAggregator keeps stats_daily, stats_monthly and stats_hourly
+// current. Each pass recounts only the days that have calls changed since the
+// previous pass; the first pass backfills everything.
+func (s *server) startTrendAggregator(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(trendRefreshInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.refreshTrends(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("trend aggregation failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.shutdown:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *server) refreshTrends(ctx context.Context) error {
+	since := ""
+	var through time.Time
+	err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&through)
+	}, `SELECT updated_through FROM stats_aggregation_state WHERE id = 1`)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil {
+		// Overlap the previous pass a little; recounting a day is idempotent.
+		since = through.Add(-time.Minute).UTC().Format("2006-01-02 15:04:05")
+	}
+	runStart := time.Now().UTC()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT call_timestamp, created_at FROM transcriptions WHERE updated_at >= ?`, since)
+	if err != nil {
+		return err
+	}
+	days := make(map[string]time.Time)
+	for rows.Next() {
+		var callTS, created sql.NullTime
+		if err := rows.Scan(&callTS, &created); err != nil {
+			rows.Close()
+			return err
+		}
+		ts := created.Time
+		if callTS.Valid {
+			ts = callTS.Time
+		}
+		day := trendDay(ts, s.tz)
+		days[day.Format(trendDayLayout)] = day
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(days))
+	for k := range days {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	months := make(map[string]bool)
+	for _, k := range keys {
+		if err := s.aggregateTrendDay(ctx, days[k]); err != nil {
+			return fmt.Errorf("day %s: %w", k, err)
+		}
+		months[k[:7]] = true
+	}
+	for month := range months {
+		if err := s.aggregateTrendMonth(month); err != nil {
+			return fmt.Errorf("month %s: %w", month, err)
+		}
+	}
+	if _, err := execWithRetry(s.db, `INSERT INTO stats_aggregation_state (id, updated_through) VALUES (1, ?) ON CONFLICT(id) DO UPDATE SET updated_through = excluded.updated_through`, runStart); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		log.Printf("trend aggregation recounted %d days in %d months", len(keys), len(months))
+	}
+	return nil
+}
+
+// trendDay returns the local day in tz holding ts.
+func trendDay(ts time.Time, tz *time.Location) time.Time {
+	local := ts.In(tz)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, tz)
+}
+
+// aggregateTrendMonth rebuilds a month of stats_monthly, given as
+// "2006-01", from stats_daily.
+func (s *server) aggregateTrendMonth(month string) error {
+	_, err := execWithRetry(s.db, `DELETE FROM stats_monthly WHERE month = ?;
+INSERT INTO stats_monthly (month, town, call_type, calls) SELECT substr(day, 1, 7), town, call_type, SUM(calls) FROM stats_daily WHERE substr(day, 1, 7) = ? GROUP BY town, call_type`, month, month)
+	return err
+}
+
+// aggregateTrendDay recounts one local day by town and call type, and by town
+// and hour. Only completed, non-duplicate calls count, and restricted calls are
+// left out because the stats endpoints are public.
+func (s *server) aggregateTrendDay(ctx context.Context, day time.Time) error {
+	end := day.AddDate(0, 0, 1)
+	records, err := s.store.Select(ctx, `WHERE status = ? AND duplicate_of IS NULL AND effective_ts >= ? AND effective_ts < ?`, statusDone, day.UTC(), end.UTC())
+	if err != nil {
+		return err
+	}
+	type key struct{ town, callType string }
+	type hourKey struct {
+		hour int
+		town string
+	}
+	counts := make(map[key]int)
+	hourly := make(map[hourKey]int)
+	for _, t := range records {
+		if s.isRestricted(t) {
+			continue
+		}
+		meta, _ := s.parseCallMetadata(t.Filename, "")
+		callType := strings.ToLower(strings.TrimSpace(derefString(t.CallType, meta.CallType)))
+		town := strings.TrimSpace(meta.TownDisplay)
+		counts[key{town, callType}]++
+		callTime := t.CreatedAt
+		if t.CallTimestamp != nil {
+			callTime = *t.CallTimestamp
+		}
+		hourly[hourKey{callTime.In(s.tz).Hour(), town}]++
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	label := day.Format(trendDayLayout)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM stats_daily WHERE day = ?`, label); err != nil {
+		return err
+	}
+	for k, n := range counts {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO stats_daily (day, town, call_type, calls) VALUES (?, ?, ?, ?)`, label, k.town, k.callType, n); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM stats_hourly WHERE day = ?`, label); err != nil {
+		return err
+	}
+	for k, n := range hourly {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO stats_hourly (day, hour, town, calls) VALUES (?, ?, ?, ?)`, label, k.hour, k.town, n); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// handleTrends serves /api/stats/trends from the aggregate tables: call counts
+// per day or month with the same period a year earlier, optionally filtered by
+// town and call type and broken down by either.
+func (s *server) handleTrends(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	resp := trendsResponse{
+		Period:   strings.ToLower(strings.TrimSpace(q.Get("period"))),
+		Town:     strings.TrimSpace(q.Get("town")),
+		CallType: strings.ToLower(strings.TrimSpace(q.Get("call_type"))),
+		GroupBy:  strings.ToLower(strings.TrimSpace(q.Get("group_by"))),
+	}
+	if resp.Period == "" {
+		resp.Period = "month"
+	}
+	table, column, layout, maxPeriods := "stats_monthly", "month", trendMonthLayout, maxTrendMonths
+	switch resp.Period {
+	case "month":
+	case "day":
+		table, column, layout, maxPeriods = "stats_daily", "day", trendDayLayout, maxTrendDays
+	default:
+		http.Error(w, "period must be day or month", http.StatusBadRequest)
+		return
+	}
+	if resp.GroupBy != "" && resp.GroupBy != "town" && resp.GroupBy != "call_type" {
+		http.Error(w, "group_by must be town or call_type", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().In(s.tz)
+	step := func(t time.Time, n int) time.Time {
+		if resp.Period == "day" {
+			return t.AddDate(0, 0, n)
+		}
+		return t.AddDate(0, n, 0)
+	}
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.tz)
+	if resp.Period == "month" {
+		to = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, s.tz)
+	}
+	if raw := strings.TrimSpace(q.Get("to")); raw != "" {
+		parsed, err := time.ParseInLocation(layout, raw, s.tz)
+		if err != nil {
+			http.Error(w, "to must be formatted as "+layout, http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	from := step(to, -11)
+	if resp.Period == "day" {
+		from = step(to, -29)
+	}
+	if raw := strings.TrimSpace(q.Get("from")); raw != "" {
+		parsed, err := time.ParseInLocation(layout, raw, s.tz)
+		if err != nil {
+			http.Error(w, "from must be formatted as "+layout, http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+	var periods []string
+	for t := from; !t.After(to); t = step(t, 1) {
+		if len(periods) == maxPeriods {
+			http.Error(w, fmt.Sprintf("at most %d periods per request", maxPeriods), http.StatusBadRequest)
+			return
+		}
+		periods = append(periods, t.Format(layout))
+	}
+	if len(periods) == 0 {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+	resp.From, resp.To = periods[0], periods[len(periods)-1]
+
+	group := "''"
+	if resp.GroupBy != "" {
+		group = resp.GroupBy
+	}
+	query := `SELECT ` + column + `, ` + group + `, SUM(calls) FROM ` + table + ` WHERE ((` + column + ` >= ? AND ` + column + ` <= ?) OR (` + column + ` >= ? AND ` + column + ` <= ?))`
+	args := []interface{}{resp.From, resp.To, previousYear(resp.From), previousYear(resp.To)}
+	if resp.Town != "" {
+		query += ` AND town = ? COLLATE NOCASE`
+		args = append(args, resp.Town)
+	}
+	if resp.CallType != "" {
+		query += ` AND call_type = ?`
+		args = append(args, resp.CallType)
+	}
+	query += ` GROUP BY 1, 2`
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		log.Printf("trends query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	byPeriod := make(map[string]int)
+	current := make(map[string]int)
+	previous := make(map[string]int)
+	for rows.Next() {
+		var period, groupKey string
+		var calls int
+		if err := rows.Scan(&period, &groupKey, &calls); err != nil {
+			log.Printf("trends scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		byPeriod[period] += calls
+		if period >= resp.From && period <= resp.To {
+			current[groupKey] += calls
+		} else {
+			previous[groupKey] += calls
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("trends query failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	resp.Series = make([]trendCount, 0, len(periods))
+	for _, p := range periods {
+		point := newTrendCount(byPeriod[p], byPeriod[previousYear(p)])
+		point.Period = p
+		resp.Series = append(resp.Series, point)
+		resp.Total.Calls += point.Calls
+		resp.Total.PreviousYear += point.PreviousYear
+	}
+	resp.Total = newTrendCount(resp.Total.Calls, resp.Total.PreviousYear)
+	if resp.GroupBy != "" {
+		for k := range previous {
+			if _, ok := current[k]; !ok {
+				current[k] = 0
+			}
+		}
+		for k, n := range current {
+			entry := newTrendCount(n, previous[k])
+			entry.Key = k
+			if entry.Key == "" {
+				entry.Key = "unknown"
+			}
+			resp.Breakdown = append(resp.Breakdown, entry)
+		}
+		sort.Slice(resp.Breakdown, func(i, j int) bool {
+			if resp.Breakdown[i].Calls != resp.Breakdown[j].Calls {
+				return resp.Breakdown[i].Calls > resp.Breakdown[j].Calls
+			}
+			return resp.Breakdown[i].Key < resp.Breakdown[j].Key
+		})
+	}
+
+	var through time.Time
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&through)
+	}, `SELECT updated_through FROM stats_aggregation_state WHERE id = 1`); err == nil {
+		resp.UpdatedThrough = &through
+	}
+	respondJSON(w, resp)
+}
+
+func newTrendCount(calls, previous int) trendCount {
+	c := trendCount{Calls: calls, PreviousYear: previous}
+	if previous > 0 {
+		pct := math.Round(float64(calls-previous)/float64(previous)*1000) / 10
+		c.ChangePct = &pct
+	}
+	return c
+}
+
+// previousYear maps a "2006-01" or "2006-01-02" period label to the same
+// period a year earlier. Feb 29 maps to a day that never has rows.
+func previousYear(period string) string {
+	year, err := strconv.Atoi(period[:4])
+	if err != nil {
+		return period
+	}
+	return fmt.Sprintf("%04d", year-1) + period[4:]
+}
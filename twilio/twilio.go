@@ -0,0 +1,147 @@
+// Package twilio is a minimal client for the two Twilio REST calls the
+// notifier needs, sending an SMS and placing a voice call that reads text
+// aloud, plus verification of the status callbacks Twilio posts back.
+package twilio
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultBaseURL is the Twilio REST API root.
+const DefaultBaseURL = "https://api.twilio.com"
+
+// SignatureHeader carries Twilio's signature on status callbacks.
+const SignatureHeader = "X-Twilio-Signature"
+
+// Client sends messages and calls from one Twilio number.
+type Client struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	BaseURL    string
+	HTTP       *http.Client
+}
+
+// Result is what Twilio reports for a created message or call.
+type Result struct {
+	SID    string `json:"sid"`
+	Status string `json:"status"`
+}
+
+// APIError is a non-2xx reply from Twilio.
+type APIError struct {
+	StatusCode int
+	Code       int    `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("twilio status %d: %s (code %d)", e.StatusCode, e.Message, e.Code)
+	}
+	return fmt.Sprintf("twilio status %d", e.StatusCode)
+}
+
+// SendSMS sends body to the number to. A non-empty statusCallback receives
+// delivery updates.
+func (c *Client) SendSMS(ctx context.Context, to, body, statusCallback string) (Result, error) {
+	form := url.Values{"To": {to}, "From": {c.From}, "Body": {body}}
+	if statusCallback != "" {
+		form.Set("StatusCallback", statusCallback)
+	}
+	return c.post(ctx, "Messages.json", form)
+}
+
+// Call rings to and reads message aloud twice. A non-empty statusCallback
+// receives the final call status.
+func (c *Client) Call(ctx context.Context, to, message, statusCallback string) (Result, error) {
+	form := url.Values{"To": {to}, "From": {c.From}, "Twiml": {SayTwiML(message)}}
+	if statusCallback != "" {
+		form.Set("StatusCallback", statusCallback)
+	}
+	return c.post(ctx, "Calls.json", form)
+}
+
+// SayTwiML renders TwiML that reads message, pauses and reads it again.
+func SayTwiML(message string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(message))
+	say := "<Say>" + escaped.String() + "</Say>"
+	return `<?xml version="1.0" encoding="UTF-8"?><Response>` + say + `<Pause length="1"/>` + say + `</Response>`
+}
+
+func (c *Client) post(ctx context.Context, resource string, form url.Values) (Result, error) {
+	base := strings.TrimRight(c.BaseURL, "/")
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/%s", base, url.PathEscape(c.AccountSID), resource)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Result{}, err
+	}
+	req.SetBasicAuth(c.AccountSID, c.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.Unmarshal(data, apiErr)
+		return Result{}, apiErr
+	}
+	var out Result
+	if err := json.Unmarshal(data, &out); err != nil {
+		return Result{}, fmt.Errorf("twilio response: %w", err)
+	}
+	return out, nil
+}
+
+// Signature computes Twilio's callback signature: base64 HMAC-SHA1 over the
+// full callback URL followed by each POST parameter name and value, sorted
+// by name, keyed with the auth token.
+func Signature(authToken, callbackURL string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(callbackURL)
+	for _, k := range keys {
+		for _, v := range params[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+	h := hmac.New(sha1.New, []byte(authToken))
+	h.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Verify reports whether signature matches a callback to callbackURL with
+// params.
+func Verify(authToken, callbackURL string, params url.Values, signature string) bool {
+	if authToken == "" || signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Signature(authToken, callbackURL, params)), []byte(signature))
+}
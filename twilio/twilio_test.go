@@ -0,0 +1,85 @@
+package twilio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSendSMSPostsForm(t *testing.T) {
+	var got url.Values
+	var path, user, pass string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		user, pass, _ = r.BasicAuth()
+		r.ParseForm()
+		got = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid":"SM123","status":"queued"}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{AccountSID: "AC1", AuthToken: "tok", From: "+15550001111", BaseURL: srv.URL}
+	res, err := c.SendSMS(context.Background(), "+15552223333", "Structure fire", "https://alerts.example/cb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.SID != "SM123" || res.Status != "queued" {
+		t.Fatalf("result = %+v", res)
+	}
+	if path != "/2010-04-01/Accounts/AC1/Messages.json" || user != "AC1" || pass != "tok" {
+		t.Fatalf("path=%s auth=%s:%s", path, user, pass)
+	}
+	if got.Get("To") != "+15552223333" || got.Get("From") != "+15550001111" || got.Get("Body") != "Structure fire" || got.Get("StatusCallback") != "https://alerts.example/cb" {
+		t.Fatalf("form = %v", got)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":21211,"message":"Invalid 'To' Phone Number"}`))
+	}))
+	defer srv.Close()
+
+	c := &Client{AccountSID: "AC1", AuthToken: "tok", BaseURL: srv.URL}
+	_, err := c.Call(context.Background(), "bad", "hello", "")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 21211 || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestSayTwiMLEscapes(t *testing.T) {
+	got := SayTwiML("Fire at Main & 1st <urgent>")
+	if strings.Count(got, "<Say>Fire at Main &amp; 1st &lt;urgent&gt;</Say>") != 2 {
+		t.Fatalf("twiml = %s", got)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	// Example from Twilio's webhook security documentation.
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+	const callback = "https://mycompany.com/myapp.php?foo=1&bar=2"
+	const want = "0/KCTR6DLpKmkAf8muzZqo1nDgQ="
+	if got := Signature("12345", callback, params); got != want {
+		t.Fatalf("signature = %s", got)
+	}
+	if !Verify("12345", callback, params, want) {
+		t.Fatal("expected valid signature")
+	}
+	params.Set("Digits", "9999")
+	if Verify("12345", callback, params, want) {
+		t.Fatal("tampered params verified")
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alert_framework/config"
+	"alert_framework/formatting"
+	"alert_framework/rollups"
+	"alert_framework/twilio"
+)
+
+const (
+	twilioSendTimeout      = 20 * time.Second
+	twilioSMSMaxChars      = 320
+	defaultTwilioListLimit = 100
+	maxTwilioListLimit     = 1000
+)
+
+// twilioUnanswered are the final call statuses that move the phone tree on
+// to the next number.
+var twilioUnanswered = map[string]bool{"busy": true, "no-answer": true, "failed": true, "canceled": true}
+
+// twilioFinal are the statuses after which Twilio sends no further updates
+// for a message or call.
+var twilioFinal = map[string]bool{
+	"delivered": true, "undelivered": true, "failed": true,
+	"completed": true, "busy": true, "no-answer": true, "canceled": true,
+}
+
+type twilioDelivery struct {
+	ID        int64     `json:"id"`
+	Filename  string    `json:"filename"`
+	Kind      string    `json:"kind"`
+	Recipient string    `json:"recipient"`
+	TreeIndex int       `json:"tree_index"`
+	SID       string    `json:"sid,omitempty"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func migrateAddTwilioDeliveries(db *sql.DB) error {
+	if _, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS twilio_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    tree_index INTEGER NOT NULL DEFAULT 0,
+    message TEXT NOT NULL,
+    sid TEXT,
+    status TEXT NOT NULL,
+    error TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);`); err != nil {
+		return err
+	}
+	_, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_twilio_deliveries_filename ON twilio_deliveries(filename)`)
+	return err
+}
+
+func twilioClient(cfg config.Config) *twilio.Client {
+	if cfg.TwilioAccountSID == "" || cfg.TwilioAuthToken == "" || cfg.TwilioFromNumber == "" {
+		return nil
+	}
+	return &twilio.Client{
+		AccountSID: cfg.TwilioAccountSID,
+		AuthToken:  cfg.TwilioAuthToken,
+		From:       cfg.TwilioFromNumber,
+		HTTP:       &http.Client{Timeout: twilioSendTimeout},
+	}
+}
+
+// twilioSMSMatches reports whether a call gets an SMS. TWILIO_SMS_CATEGORIES
+// entries match the call category (fire, ems, other) or the exact call type,
+// like ESCALATION_CATEGORIES; an empty list sends nothing.
+func twilioSMSMatches(categories []string, category, callType string) bool {
+	for _, c := range categories {
+		if strings.EqualFold(c, category) || strings.EqualFold(c, strings.TrimSpace(callType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendTwilioAlerts texts a short summary of a newly alerted call to
+// TWILIO_SMS_TO when its category is configured, and starts the voice
+// callout down TWILIO_CALLOUT_TO for high priority calls. Every message and
+// call is recorded in twilio_deliveries; status callbacks keep it current.
+func (s *server) sendTwilioAlerts(filename string, incident formatting.IncidentDetails, callType, transcript string) {
+	cfg := s.liveConfig()
+	client := twilioClient(cfg)
+	if client == nil {
+		return
+	}
+	if len(cfg.TwilioSMSTo) > 0 && twilioSMSMatches(cfg.TwilioSMSCategories, incident.CallCategory, callType) {
+		body := buildTwilioSMS(incident)
+		for _, to := range cfg.TwilioSMSTo {
+			s.sendTwilioSMS(client, filename, to, body)
+		}
+	}
+	if len(cfg.TwilioCalloutTo) > 0 && rollups.CallPriority(callType, transcript) == "high" {
+		s.placeCallout(client, filename, buildCalloutMessage(incident), 0)
+	}
+}
+
+func (s *server) sendTwilioSMS(client *twilio.Client, filename, to, body string) {
+	id, err := s.insertTwilioDelivery(filename, "sms", to, 0, body)
+	if err != nil {
+		log.Printf("twilio sms for %s to %s not recorded: %v", filename, to, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, twilioSendTimeout)
+	defer cancel()
+	res, err := client.SendSMS(ctx, to, body, s.twilioCallbackURL(id))
+	s.finishTwilioRequest(id, res, err)
+	if err != nil {
+		log.Printf("twilio sms for %s to %s failed: %v", filename, to, err)
+	}
+}
+
+// placeCallout rings the phone tree entry at index. A number that cannot be
+// called is skipped straight away; otherwise the status callback for the call
+// decides whether to move on.
+func (s *server) placeCallout(client *twilio.Client, filename, message string, index int) {
+	tree := s.liveConfig().TwilioCalloutTo
+	for ; index < len(tree); index++ {
+		to := tree[index]
+		id, err := s.insertTwilioDelivery(filename, "voice", to, index, message)
+		if err != nil {
+			log.Printf("twilio callout for %s to %s not recorded: %v", filename, to, err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, twilioSendTimeout)
+		res, err := client.Call(ctx, to, message, s.twilioCallbackURL(id))
+		cancel()
+		s.finishTwilioRequest(id, res, err)
+		if err == nil {
+			log.Printf("twilio callout for %s ringing %s (%d of %d)", filename, to, index+1, len(tree))
+			return
+		}
+		log.Printf("twilio callout for %s to %s failed: %v", filename, to, err)
+	}
+	log.Printf("twilio callout for %s reached nobody: phone tree exhausted", filename)
+}
+
+func (s *server) insertTwilioDelivery(filename, kind, recipient string, index int, message string) (int64, error) {
+	now := time.Now().UTC()
+	res, err := execWithRetry(s.db, `INSERT INTO twilio_deliveries (filename, kind, recipient, tree_index, message, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, 'pending', ?, ?)`,
+		filename, kind, recipient, index, message, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *server) finishTwilioRequest(id int64, res twilio.Result, sendErr error) {
+	status, errText := res.Status, ""
+	if sendErr != nil {
+		status, errText = "failed", sendErr.Error()
+	}
+	if status == "" {
+		status = "queued"
+	}
+	if _, err := execWithRetry(s.db, `UPDATE twilio_deliveries SET sid = ?, status = ?, error = ?, updated_at = ? WHERE id = ?`,
+		nullableString(res.SID), status, nullableString(errText), time.Now().UTC(), id); err != nil {
+		log.Printf("twilio delivery %d update failed: %v", id, err)
+	}
+}
+
+// twilioCallbackURL is where Twilio reports status for one delivery. Without
+// PUBLIC_BASE_URL Twilio cannot reach us and the recorded status stays at what
+// the API returned when the message or call was created.
+func (s *server) twilioCallbackURL(id int64) string {
+	base := strings.TrimSpace(s.cfg.PublicBaseURL)
+	if base == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/api/twilio/status?delivery=%d", base, id)
+}
+
+func buildTwilioSMS(incident formatting.IncidentDetails) string {
+	parts := []string{formatting.FormatIncidentHeader(incident)}
+	if loc := formatting.FormatIncidentLocation(incident); loc != "" {
+		parts = append(parts, loc)
+	}
+	body := strings.Join(parts, "\n")
+	if incident.ListenURL == "" {
+		return truncateText(body, twilioSMSMaxChars-1)
+	}
+	return truncateText(body, twilioSMSMaxChars-len([]rune(incident.ListenURL))-2) + "\n" + incident.ListenURL
+}
+
+func buildCalloutMessage(incident formatting.IncidentDetails) string {
+	parts := []string{"Priority callout.", formatting.FormatIncidentHeader(incident) + "."}
+	if loc := formatting.FormatIncidentLocation(incident); loc != "" {
+		parts = append(parts, loc+".")
+	}
+	if summary := strings.TrimSpace(incident.Summary); summary != "" {
+		parts = append(parts, summary)
+	}
+	return strings.Join(parts, " ")
+}
+
+// handleTwilioStatus receives Twilio status callbacks. Requests must carry a
+// valid X-Twilio-Signature for PUBLIC_BASE_URL. A callout that ends busy,
+// unanswered or failed rings the next number in the phone tree.
+func (s *server) handleTwilioStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	cfg := s.liveConfig()
+	callbackURL := strings.TrimSpace(cfg.PublicBaseURL) + r.URL.RequestURI()
+	if !twilio.Verify(cfg.TwilioAuthToken, callbackURL, r.PostForm, r.Header.Get(twilio.SignatureHeader)) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	id, err := strconv.ParseInt(r.URL.Query().Get("delivery"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "delivery is required", http.StatusBadRequest)
+		return
+	}
+	status := strings.ToLower(r.PostForm.Get("MessageStatus"))
+	if status == "" {
+		status = strings.ToLower(r.PostForm.Get("CallStatus"))
+	}
+	if status == "" {
+		http.Error(w, "status is required", http.StatusBadRequest)
+		return
+	}
+	var errText string
+	if code := r.PostForm.Get("ErrorCode"); code != "" {
+		errText = strings.TrimSpace("twilio error " + code + " " + r.PostForm.Get("ErrorMessage"))
+	}
+	var d twilioDelivery
+	var message string
+	err = queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&d.Filename, &d.Kind, &d.TreeIndex, &d.Status, &message)
+	}, `SELECT filename, kind, tree_index, status, message FROM twilio_deliveries WHERE id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("twilio delivery %d lookup failed: %v", id, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	// Twilio retries callbacks and may deliver them out of order; only the
+	// first final status counts, so the tree advances once per call.
+	if twilioFinal[d.Status] {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if _, err := execWithRetry(s.db, `UPDATE twilio_deliveries SET status = ?, error = COALESCE(?, error), updated_at = ? WHERE id = ?`,
+		status, nullableString(errText), time.Now().UTC(), id); err != nil {
+		log.Printf("twilio delivery %d update failed: %v", id, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if d.Kind == "voice" && twilioUnanswered[status] {
+		if client := twilioClient(cfg); client != nil {
+			go s.placeCallout(client, d.Filename, message, d.TreeIndex+1)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTwilioDeliveries lists SMS and callout deliveries, newest first:
+//
+//	GET /api/admin/twilio/deliveries[?filename=...][&limit=N]
+func (s *server) handleTwilioDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	limit := defaultTwilioListLimit
+	if raw := strings.TrimSpace(q.Get("limit")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 || v > maxTwilioListLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = v
+	}
+	query := `SELECT id, filename, kind, recipient, tree_index, COALESCE(sid, ''), status, COALESCE(error, ''), created_at, updated_at FROM twilio_deliveries`
+	var args []interface{}
+	if filename := strings.TrimSpace(q.Get("filename")); filename != "" {
+		query += ` WHERE filename = ?`
+		args = append(args, filename)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		log.Printf("twilio deliveries list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	deliveries := []twilioDelivery{}
+	for rows.Next() {
+		var d twilioDelivery
+		if err := rows.Scan(&d.ID, &d.Filename, &d.Kind, &d.Recipient, &d.TreeIndex, &d.SID, &d.Status, &d.Error, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			log.Printf("twilio deliveries scan failed: %v", err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("twilio deliveries list failed: %v", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{"deliveries": deliveries})
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alert_framework/errcode"
+)
+
+// Resumable uploads follow the TUS 1.0.0 protocol (https://tus.io) with the
+// creation, expiration and termination extensions. Parts are appended to
+// WORK_DIR/uploads/{id}; the finished file is validated, moved into CALLS_DIR
+// and enqueued like a recording from the watcher.
+const (
+	tusVersion        = "1.0.0"
+	tusExtensions     = "creation,expiration,termination"
+	tusOffsetType     = "application/offset+octet-stream"
+	uploadSweepPeriod = time.Hour
+
+	uploadStatusUploading = "uploading"
+	uploadStatusComplete  = "complete"
+	uploadStatusRejected  = "rejected"
+)
+
+type upload struct {
+	ID        string
+	Filename  string
+	Length    int64
+	Offset    int64
+	Status    string
+	Error     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func migrateAddUploads(db *sql.DB) error {
+	if _, err := execWithRetry(db, `CREATE TABLE IF NOT EXISTS uploads (
+    id TEXT PRIMARY KEY,
+    filename TEXT NOT NULL,
+    length INTEGER NOT NULL,
+    received INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    error TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);`); err != nil {
+		return err
+	}
+	_, err := execWithRetry(db, `CREATE INDEX IF NOT EXISTS idx_uploads_expires_at ON uploads(expires_at)`)
+	return err
+}
+
+func (s *server) uploadDir() string {
+	return filepath.Join(s.cfg.WorkDir, "uploads")
+}
+
+func (s *server) uploadPath(id string) string {
+	return filepath.Join(s.uploadDir(), id)
+}
+
+func (s *server) uploadExpiry() time.Duration {
+	return time.Duration(s.cfg.UploadExpiryHours) * time.Hour
+}
+
+func (s *server) getUpload(ctx context.Context, id string) (*upload, error) {
+	var u upload
+	var uploadErr sql.NullString
+	err := s.db.QueryRowContext(ctx, `SELECT id, filename, length, received, status, error, created_at, expires_at FROM uploads WHERE id = ?`, id).
+		Scan(&u.ID, &u.Filename, &u.Length, &u.Offset, &u.Status, &uploadErr, &u.CreatedAt, &u.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	u.Error = uploadErr.String
+	return &u, nil
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma-separated
+// "key base64value" pairs, where the value may be omitted.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, encoded, _ := strings.Cut(pair, " ")
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("metadata %q is not base64", key)
+		}
+		out[key] = string(value)
+	}
+	return out, nil
+}
+
+// uploadMetadataValue returns an optional free-text metadata value, trimmed
+// and capped, or nil.
+func uploadMetadataValue(meta map[string]string, key string) *string {
+	v := strings.TrimSpace(meta[key])
+	if r := []rune(v); len(r) > maxTalkgroupMetadataLen {
+		v = string(r[:maxTalkgroupMetadataLen])
+	}
+	return nullableString(v)
+}
+
+func newUploadID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func setTusHeaders(w http.ResponseWriter) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// handleUploads serves resumable recording uploads, authenticated with
+// UPLOAD_TOKEN in X-Upload-Token. The endpoint is disabled when no token is
+// configured.
+//
+//	OPTIONS /api/uploads          protocol discovery
+//	POST    /api/uploads          create an upload (Upload-Length, Upload-Metadata filename[, talkgroup, frequency])
+//	HEAD    /api/uploads/{id}     current Upload-Offset, to resume from
+//	PATCH   /api/uploads/{id}     append bytes at Upload-Offset
+//	DELETE  /api/uploads/{id}     abandon an upload
+func (s *server) handleUploads(w http.ResponseWriter, r *http.Request) {
+	token := s.cfg.UploadToken
+	if token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	setTusHeaders(w)
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(int64(s.cfg.UploadMaxMB)<<20, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Upload-Token")), []byte(token)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "unsupported Tus-Resumable version", http.StatusPreconditionFailed)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/uploads"), "/")
+	if id == "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.createUpload(w, r)
+		return
+	}
+	if strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	u, err := s.getUpload(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("upload %s lookup failed: %v", id, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if u.Status == uploadStatusUploading && time.Now().After(u.ExpiresAt) {
+		http.Error(w, "upload expired", http.StatusGone)
+		return
+	}
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+		if u.Status == uploadStatusUploading {
+			w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		s.appendUpload(w, r, u)
+	case http.MethodDelete:
+		if u.Status != uploadStatusUploading {
+			http.Error(w, "upload already finished", http.StatusConflict)
+			return
+		}
+		s.deleteUpload(u.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) createUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		http.Error(w, "Upload-Defer-Length is not supported", http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		http.Error(w, "Upload-Length must be a positive integer", http.StatusBadRequest)
+		return
+	}
+	if length > int64(s.cfg.UploadMaxMB)<<20 {
+		http.Error(w, fmt.Sprintf("upload is larger than %d MB", s.cfg.UploadMaxMB), http.StatusRequestEntityTooLarge)
+		return
+	}
+	meta, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filename := remoteFilename(meta["filename"])
+	if filename == "" {
+		http.Error(w, "Upload-Metadata must include an audio filename", http.StatusBadRequest)
+		return
+	}
+	if fileExists(filepath.Join(s.cfg.CallsDir, filename)) {
+		http.Error(w, errUploadExists.Error(), http.StatusConflict)
+		return
+	}
+	if err := os.MkdirAll(s.uploadDir(), 0o755); err != nil {
+		log.Printf("upload dir %s: %v", s.uploadDir(), err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	id := newUploadID()
+	f, err := os.OpenFile(s.uploadPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Printf("upload %s create failed: %v", id, err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	f.Close()
+	now := time.Now().UTC()
+	expires := now.Add(s.uploadExpiry())
+	if _, err := execWithRetry(s.db, `INSERT INTO uploads (id, filename, length, received, status, created_at, updated_at, expires_at, talkgroup, frequency) VALUES (?, ?, ?, 0, ?, ?, ?, ?, ?, ?)`,
+		id, filename, length, uploadStatusUploading, now, now, expires, uploadMetadataValue(meta, "talkgroup"), uploadMetadataValue(meta, "frequency")); err != nil {
+		os.Remove(s.uploadPath(id))
+		log.Printf("upload %s insert failed: %v", id, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("upload %s created for %s (%d bytes)", id, filename, length)
+	w.Header().Set("Location", "/api/uploads/"+id)
+	w.Header().Set("Upload-Expires", expires.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *server) appendUpload(w http.ResponseWriter, r *http.Request, u *upload) {
+	if ct, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";"); strings.TrimSpace(ct) != tusOffsetType {
+		http.Error(w, "Content-Type must be "+tusOffsetType, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Upload-Offset must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	lockValue, _ := s.uploadLocks.LoadOrStore(u.ID, &sync.Mutex{})
+	lock := lockValue.(*sync.Mutex)
+	if !lock.TryLock() {
+		http.Error(w, "upload is busy", http.StatusLocked)
+		return
+	}
+	defer lock.Unlock()
+	// Re-read under the lock: a PATCH that finished while this one waited
+	// moved the offset.
+	id := u.ID
+	if u, err = s.getUpload(r.Context(), id); err != nil {
+		log.Printf("upload %s lookup failed: %v", id, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if u.Status != uploadStatusUploading {
+		http.Error(w, "upload already finished", http.StatusConflict)
+		return
+	}
+	if offset != u.Offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+		http.Error(w, fmt.Sprintf("Upload-Offset %d does not match %d", offset, u.Offset), http.StatusConflict)
+		return
+	}
+
+	path := s.uploadPath(u.ID)
+	f, err := os.OpenFile(path, os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Printf("upload %s open failed: %v", u.ID, err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	// Drop anything past the recorded offset left by a PATCH that died
+	// between writing and recording its progress.
+	if err := f.Truncate(u.Offset); err == nil {
+		_, err = f.Seek(u.Offset, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		log.Printf("upload %s seek failed: %v", u.ID, err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	// A dropped connection still keeps the bytes that arrived, so the client
+	// resumes from there.
+	written, copyErr := io.Copy(f, io.LimitReader(r.Body, u.Length-u.Offset))
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	u.Offset += written
+	now := time.Now().UTC()
+	u.ExpiresAt = now.Add(s.uploadExpiry())
+	if _, err := execWithRetry(s.db, `UPDATE uploads SET received = ?, updated_at = ?, expires_at = ? WHERE id = ?`, u.Offset, now, u.ExpiresAt, u.ID); err != nil {
+		log.Printf("upload %s progress update failed: %v", u.ID, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if copyErr != nil {
+		log.Printf("upload %s interrupted at %d/%d bytes: %v", u.ID, u.Offset, u.Length, copyErr)
+		http.Error(w, "upload interrupted", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	if u.Offset < u.Length {
+		w.Header().Set("Upload-Expires", u.ExpiresAt.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := s.finishUpload(r.Context(), u); err != nil {
+		switch {
+		case errcode.Of(err) == errcode.InvalidAudio:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, errUploadExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			// The upload stays open; an empty PATCH at the final offset
+			// retries.
+			log.Printf("upload %s for %s could not be finished: %v", u.ID, u.Filename, err)
+			http.Error(w, "upload could not be finished; retry", http.StatusServiceUnavailable)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errUploadExists = errors.New("a recording with that filename already exists")
+
+// finishUpload validates a complete upload and moves it into CALLS_DIR.
+// Invalid audio is quarantined as if it had arrived in CALLS_DIR, so a
+// requeue puts it there. Other failures leave the upload open to retry.
+func (s *server) finishUpload(ctx context.Context, u *upload) error {
+	path := s.uploadPath(u.ID)
+	dest := filepath.Join(s.cfg.CallsDir, u.Filename)
+	if err := s.validateAudio(ctx, path, u.Length); err != nil {
+		if errcode.Of(err) != errcode.InvalidAudio {
+			return err
+		}
+		s.quarantineFile(u.Filename, path, u.Length, err)
+		if _, dbErr := execWithRetry(s.db, `UPDATE quarantine SET original_path = ? WHERE filename = ?`, dest, u.Filename); dbErr != nil {
+			log.Printf("quarantine record failed for %s: %v", u.Filename, dbErr)
+		}
+		s.setUploadStatus(u.ID, uploadStatusRejected, err)
+		return err
+	}
+	if fileExists(dest) {
+		s.setUploadStatus(u.ID, uploadStatusRejected, errUploadExists)
+		os.Remove(path)
+		return errUploadExists
+	}
+	// With a local or shared queue the upload is enqueued here, under its own
+	// ingest source; otherwise the worker's watcher picks it up from CALLS_DIR.
+	local := s.canEnqueue()
+	if local {
+		s.remoteFiles.Store(u.Filename, struct{}{})
+		defer s.remoteFiles.Delete(u.Filename)
+	}
+	if err := moveFile(path, dest); err != nil {
+		return err
+	}
+	s.setUploadStatus(u.ID, uploadStatusComplete, nil)
+	log.Printf("upload %s complete: %s (%d bytes)", u.ID, u.Filename, u.Length)
+	if local {
+		opts, _ := s.defaultOptions()
+		s.queueJob("api", u.Filename, true, false, opts)
+	}
+	return nil
+}
+
+func (s *server) setUploadStatus(id, status string, cause error) {
+	var msg *string
+	if cause != nil {
+		msg = nullableString(cause.Error())
+	}
+	if _, err := execWithRetry(s.db, `UPDATE uploads SET status = ?, error = ?, updated_at = ? WHERE id = ?`, status, msg, time.Now().UTC(), id); err != nil {
+		log.Printf("upload %s status update failed: %v", id, err)
+	}
+}
+
+func (s *server) deleteUpload(id string) {
+	if err := os.Remove(s.uploadPath(id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("upload %s remove failed: %v", id, err)
+	}
+	if _, err := execWithRetry(s.db, `DELETE FROM uploads WHERE id = ?`, id); err != nil {
+		log.Printf("upload %s delete failed: %v", id, err)
+	}
+	s.uploadLocks.Delete(id)
+}
+
+// startUploadSweeper deletes uploads that were not finished before they
+// expired, and the records of finished ones once they are as old.
+func (s *server) startUploadSweeper(ctx context.Context) {
+	if s.cfg.UploadToken == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(uploadSweepPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sweepUploads(time.Now().UTC())
+			}
+		}
+	}()
+}
+
+func (s *server) sweepUploads(now time.Time) {
+	rows, err := s.db.Query(`SELECT id FROM uploads WHERE expires_at < ?`, now)
+	if err != nil {
+		log.Printf("upload sweep query failed: %v", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	for _, id := range ids {
+		s.deleteUpload(id)
+	}
+	if len(ids) > 0 {
+		log.Printf("upload sweep removed %d expired uploads", len(ids))
+	}
+}
@@ -0,0 +1,470 @@
+// Package usage meters OpenAI traffic. Requests go through a shared
+// transport that retries transient failures, records tokens and audio seconds
+// per request in the openai_usage table, prices them, and refuses new
+// requests once the monthly budget is spent.
+package usage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alert_framework/config"
+)
+
+// Stages label what a request was for in the usage breakdown.
+const (
+	StageTranscribe = "transcribe"
+	StageCleanup    = "cleanup"
+	StageTranslate  = "translate"
+	StageClassify   = "classify"
+	StageEmbed      = "embed"
+	StageRefine     = "refine"
+	StageRedact     = "redact"
+	StageRoles      = "roles"
+	StageRollup     = "rollup"
+	StageSpeech     = "speech"
+)
+
+// Schema creates the table the meter writes to.
+const Schema = `CREATE TABLE IF NOT EXISTS openai_usage (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL,
+    stage TEXT NOT NULL,
+    model TEXT NOT NULL DEFAULT '',
+    endpoint TEXT NOT NULL DEFAULT '',
+    status_code INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    audio_seconds REAL NOT NULL DEFAULT 0,
+    cost_usd REAL NOT NULL DEFAULT 0,
+    error TEXT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_openai_usage_created_at ON openai_usage(created_at);`
+
+const (
+	maxAttempts   = 3
+	maxRetryDelay = 30 * time.Second
+)
+
+// ErrBudgetExceeded is returned instead of sending a request once the month's
+// recorded cost reaches the budget.
+var ErrBudgetExceeded = errors.New("openai monthly budget exceeded")
+
+// DefaultPrices are list prices in USD at the time of writing. OPENAI_PRICING
+// overrides or extends them. Dated snapshots such as gpt-4o-mini-2024-07-18
+// are priced by their longest matching prefix.
+var DefaultPrices = map[string]config.ModelPrice{
+	"gpt-4o-mini":               {InputPerMTok: 0.15, OutputPerMTok: 0.60},
+	"gpt-4o":                    {InputPerMTok: 2.50, OutputPerMTok: 10.00},
+	"gpt-4.1-nano":              {InputPerMTok: 0.10, OutputPerMTok: 0.40},
+	"gpt-4.1-mini":              {InputPerMTok: 0.40, OutputPerMTok: 1.60},
+	"gpt-4.1":                   {InputPerMTok: 2.00, OutputPerMTok: 8.00},
+	"gpt-5-mini":                {InputPerMTok: 0.25, OutputPerMTok: 2.00},
+	"gpt-5.1":                   {InputPerMTok: 1.25, OutputPerMTok: 10.00},
+	"text-embedding-3-small":    {InputPerMTok: 0.02},
+	"whisper-1":                 {AudioPerMinute: 0.006},
+	"gpt-4o-transcribe":         {AudioPerMinute: 0.006},
+	"gpt-4o-mini-transcribe":    {AudioPerMinute: 0.003},
+	"gpt-4o-transcribe-diarize": {AudioPerMinute: 0.006},
+}
+
+type ctxKey int
+
+const (
+	stageKey ctxKey = iota
+	audioKey
+)
+
+type audioInfo struct {
+	model   string
+	seconds float64
+}
+
+// WithStage labels requests made with ctx.
+func WithStage(ctx context.Context, stage string) context.Context {
+	return context.WithValue(ctx, stageKey, stage)
+}
+
+// WithAudio tells the meter which model an audio upload uses and how long it
+// is, for responses that report neither.
+func WithAudio(ctx context.Context, model string, seconds float64) context.Context {
+	return context.WithValue(ctx, audioKey, audioInfo{model: model, seconds: seconds})
+}
+
+// Meter records usage and enforces the monthly budget.
+type Meter struct {
+	db     *sql.DB
+	budget float64
+	prices map[string]config.ModelPrice
+	now    func() time.Time
+
+	mu    sync.Mutex
+	month string
+	spent float64
+}
+
+// NewMeter creates a meter writing to db. budgetUSD <= 0 means no budget.
+func NewMeter(db *sql.DB, budgetUSD float64, overrides map[string]config.ModelPrice) *Meter {
+	prices := make(map[string]config.ModelPrice, len(DefaultPrices)+len(overrides))
+	for k, v := range DefaultPrices {
+		prices[k] = v
+	}
+	for k, v := range overrides {
+		prices[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	return &Meter{db: db, budget: budgetUSD, prices: prices, now: time.Now}
+}
+
+// Wrap returns a transport that meters POST requests to the given hosts and
+// passes everything else (other hosts, GETs such as health probes) straight
+// to base.
+func (m *Meter) Wrap(base http.RoundTripper, hosts ...string) http.RoundTripper {
+	set := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			set[h] = true
+		}
+	}
+	return &transport{meter: m, base: base, hosts: set}
+}
+
+// Budget returns the monthly budget in USD; 0 means none.
+func (m *Meter) Budget() float64 {
+	return m.budget
+}
+
+// Cost prices one request.
+func (m *Meter) Cost(model string, inputTokens, outputTokens int64, audioSeconds float64) float64 {
+	p, ok := m.price(model)
+	if !ok {
+		return 0
+	}
+	return float64(inputTokens)*p.InputPerMTok/1e6 +
+		float64(outputTokens)*p.OutputPerMTok/1e6 +
+		audioSeconds/60*p.AudioPerMinute
+}
+
+func (m *Meter) price(model string) (config.ModelPrice, bool) {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if p, ok := m.prices[model]; ok {
+		return p, true
+	}
+	best := ""
+	for name := range m.prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return config.ModelPrice{}, false
+	}
+	return m.prices[best], true
+}
+
+// MonthToDate returns the cost recorded since the start of the current UTC
+// month.
+func (m *Meter) MonthToDate(ctx context.Context) (float64, error) {
+	now := m.now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var total float64
+	err := m.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(cost_usd), 0) FROM openai_usage WHERE created_at >= ?`, start).Scan(&total)
+	return total, err
+}
+
+// checkBudget fails once this month's spend reaches the budget. Models
+// without a price (local servers) are never refused. The running total is
+// loaded from the table at the first request of each month and kept in memory
+// after that.
+func (m *Meter) checkBudget(ctx context.Context, model string) error {
+	if m.budget <= 0 {
+		return nil
+	}
+	if _, priced := m.price(model); !priced && model != "" {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	month := m.now().UTC().Format("2006-01")
+	if m.month != month {
+		spent, err := m.MonthToDate(ctx)
+		if err != nil {
+			log.Printf("openai usage: load month total: %v", err)
+		}
+		m.month, m.spent = month, spent
+	}
+	if m.spent >= m.budget {
+		return fmt.Errorf("%w ($%.2f of $%.2f)", ErrBudgetExceeded, m.spent, m.budget)
+	}
+	return nil
+}
+
+// entry is one row of openai_usage.
+type entry struct {
+	Stage        string
+	Model        string
+	Endpoint     string
+	StatusCode   int
+	Attempts     int
+	InputTokens  int64
+	OutputTokens int64
+	AudioSeconds float64
+	CostUSD      float64
+	Error        string
+}
+
+func (m *Meter) record(rec entry) {
+	now := m.now().UTC()
+	var errText interface{}
+	if rec.Error != "" {
+		errText = rec.Error
+	}
+	_, err := m.db.Exec(`INSERT INTO openai_usage (created_at, stage, model, endpoint, status_code, attempts, input_tokens, output_tokens, audio_seconds, cost_usd, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		now, rec.Stage, rec.Model, rec.Endpoint, rec.StatusCode, rec.Attempts, rec.InputTokens, rec.OutputTokens, rec.AudioSeconds, rec.CostUSD, errText)
+	if err != nil {
+		log.Printf("openai usage: record %s request: %v", rec.Stage, err)
+	}
+	m.mu.Lock()
+	if m.month == now.Format("2006-01") {
+		m.spent += rec.CostUSD
+	}
+	m.mu.Unlock()
+}
+
+// DailyStage sums one stage's usage over one day.
+type DailyStage struct {
+	Date         string  `json:"date"`
+	Stage        string  `json:"stage"`
+	Requests     int     `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	AudioSeconds float64 `json:"audio_seconds"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// Daily sums usage since the given time per local day (in loc) and stage,
+// oldest day first.
+func (m *Meter) Daily(ctx context.Context, since time.Time, loc *time.Location) ([]DailyStage, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT created_at, stage, input_tokens, output_tokens, audio_seconds, cost_usd FROM openai_usage WHERE created_at >= ?`, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byKey := make(map[string]*DailyStage)
+	for rows.Next() {
+		var (
+			at      time.Time
+			stage   string
+			in, out int64
+			seconds float64
+			costUSD float64
+		)
+		if err := rows.Scan(&at, &stage, &in, &out, &seconds, &costUSD); err != nil {
+			return nil, err
+		}
+		date := at.In(loc).Format("2006-01-02")
+		day, ok := byKey[date+"|"+stage]
+		if !ok {
+			day = &DailyStage{Date: date, Stage: stage}
+			byKey[date+"|"+stage] = day
+		}
+		day.Requests++
+		day.InputTokens += in
+		day.OutputTokens += out
+		day.AudioSeconds += seconds
+		day.CostUSD += costUSD
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]DailyStage, 0, len(byKey))
+	for _, day := range byKey {
+		out = append(out, *day)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Date != out[j].Date {
+			return out[i].Date < out[j].Date
+		}
+		return out[i].Stage < out[j].Stage
+	})
+	return out, nil
+}
+
+type transport struct {
+	meter *Meter
+	base  http.RoundTripper
+	hosts map[string]bool
+}
+
+// RoundTrip sends req, retrying network errors, 429s and 5xx responses with
+// backoff (honouring Retry-After) when the body can be replayed. Streamed
+// uploads are sent once; their callers retry themselves. Every request is
+// recorded once, with the number of attempts it took.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || !t.hosts[strings.ToLower(req.URL.Host)] {
+		return t.base.RoundTrip(req)
+	}
+	m := t.meter
+	ctx := req.Context()
+	rec := entry{Stage: stageOf(req), Endpoint: req.URL.Path, Model: requestModel(req)}
+	if audio, ok := ctx.Value(audioKey).(audioInfo); ok {
+		rec.Model = audio.model
+		rec.AudioSeconds = audio.seconds
+	}
+	if err := m.checkBudget(ctx, rec.Model); err != nil {
+		return nil, err
+	}
+	replayable := req.Body == nil || req.GetBody != nil
+	var (
+		resp     *http.Response
+		err      error
+		attempts int
+	)
+	for attempts = 1; ; attempts++ {
+		attempt := req
+		if attempts > 1 && req.GetBody != nil {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			attempt = req.Clone(ctx)
+			attempt.Body = body
+		}
+		resp, err = t.base.RoundTrip(attempt)
+		if !replayable || attempts >= maxAttempts || !retryable(resp, err) {
+			break
+		}
+		delay := retryDelay(resp, attempts)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	rec.Attempts = attempts
+	if err != nil {
+		rec.Error = err.Error()
+		m.record(rec)
+		return nil, err
+	}
+	rec.StatusCode = resp.StatusCode
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if readErr != nil {
+		rec.Error = readErr.Error()
+		m.record(rec)
+		return nil, readErr
+	}
+	if resp.StatusCode < 300 {
+		readUsage(body, &rec)
+		rec.CostUSD = m.Cost(rec.Model, rec.InputTokens, rec.OutputTokens, rec.AudioSeconds)
+	} else {
+		rec.AudioSeconds = 0
+		rec.Error = resp.Status
+	}
+	m.record(rec)
+	return resp, nil
+}
+
+func stageOf(req *http.Request) string {
+	if stage, ok := req.Context().Value(stageKey).(string); ok && stage != "" {
+		return stage
+	}
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/v1/audio/"):
+		return StageTranscribe
+	case strings.HasPrefix(req.URL.Path, "/v1/embeddings"):
+		return StageEmbed
+	}
+	return "other"
+}
+
+// readUsage fills token and audio counts from an OpenAI response body. Chat
+// and embedding responses report prompt/completion tokens; transcription
+// responses report input/output tokens or seconds, or a duration in
+// verbose_json. Plain-text responses carry nothing and leave rec unchanged.
+func readUsage(body []byte, rec *entry) {
+	var parsed struct {
+		Model    string  `json:"model"`
+		Duration float64 `json:"duration"`
+		Usage    struct {
+			PromptTokens     int64   `json:"prompt_tokens"`
+			CompletionTokens int64   `json:"completion_tokens"`
+			InputTokens      int64   `json:"input_tokens"`
+			OutputTokens     int64   `json:"output_tokens"`
+			Seconds          float64 `json:"seconds"`
+		} `json:"usage"`
+	}
+	if json.Unmarshal(body, &parsed) != nil {
+		return
+	}
+	if parsed.Model != "" {
+		rec.Model = parsed.Model
+	}
+	rec.InputTokens = parsed.Usage.PromptTokens + parsed.Usage.InputTokens
+	rec.OutputTokens = parsed.Usage.CompletionTokens + parsed.Usage.OutputTokens
+	switch {
+	case parsed.Usage.Seconds > 0:
+		rec.AudioSeconds = parsed.Usage.Seconds
+	case parsed.Duration > 0:
+		rec.AudioSeconds = parsed.Duration
+	}
+}
+
+// requestModel reads the model from a replayable JSON request body.
+func requestModel(req *http.Request) string {
+	if req.GetBody == nil || !strings.Contains(req.Header.Get("Content-Type"), "json") {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if json.NewDecoder(body).Decode(&payload) != nil {
+		return ""
+	}
+	return payload.Model
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryDelay honours a Retry-After header in seconds, capped at
+// maxRetryDelay, and otherwise backs off 1s, 2s, ...
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Retry-After"))); err == nil && secs >= 0 {
+			if d := time.Duration(secs) * time.Second; d < maxRetryDelay {
+				return d
+			}
+			return maxRetryDelay
+		}
+	}
+	return time.Duration(attempt) * time.Second
+}
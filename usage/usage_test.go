@@ -0,0 +1,114 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func testMeter(t *testing.T, budget float64) *Meter {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(Schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	return NewMeter(db, budget, nil)
+}
+
+func testClient(m *Meter, srv *httptest.Server) *http.Client {
+	u, _ := url.Parse(srv.URL)
+	return &http.Client{Timeout: 5 * time.Second, Transport: m.Wrap(http.DefaultTransport, u.Host)}
+}
+
+func TestCostUsesLongestPrefix(t *testing.T) {
+	m := testMeter(t, 0)
+	got := m.Cost("gpt-4o-mini-2024-07-18", 1_000_000, 1_000_000, 0)
+	if math.Abs(got-0.75) > 1e-9 {
+		t.Fatalf("expected gpt-4o-mini pricing, got %v", got)
+	}
+	if got := m.Cost("gpt-4o-mini-transcribe", 0, 0, 120); math.Abs(got-0.006) > 1e-9 {
+		t.Fatalf("expected transcribe per-minute pricing, got %v", got)
+	}
+	if got := m.Cost("unknown-model", 1000, 1000, 60); got != 0 {
+		t.Fatalf("expected unknown model to cost nothing, got %v", got)
+	}
+}
+
+func TestTransportRetriesAndRecordsUsage(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"model":"gpt-4.1-mini-2025-04-14","usage":{"prompt_tokens":1000,"completion_tokens":500}}`))
+	}))
+	defer srv.Close()
+
+	m := testMeter(t, 0)
+	client := testClient(m, srv)
+	req, _ := http.NewRequestWithContext(WithStage(context.Background(), StageCleanup), http.MethodPost, srv.URL+"/v1/chat/completions", bytes.NewReader([]byte(`{"model":"gpt-4.1-mini"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if calls != 2 {
+		t.Fatalf("expected one retry, got %d calls", calls)
+	}
+
+	days, err := m.Daily(context.Background(), time.Now().Add(-time.Hour), time.UTC)
+	if err != nil {
+		t.Fatalf("daily: %v", err)
+	}
+	if len(days) != 1 || days[0].Stage != StageCleanup || days[0].Requests != 1 || days[0].InputTokens != 1000 || days[0].OutputTokens != 500 {
+		t.Fatalf("unexpected usage: %+v", days)
+	}
+	if want := 1000*0.40/1e6 + 500*1.60/1e6; math.Abs(days[0].CostUSD-want) > 1e-12 {
+		t.Fatalf("expected cost %v, got %v", want, days[0].CostUSD)
+	}
+}
+
+func TestBudgetBlocksRequests(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+
+	m := testMeter(t, 1)
+	if _, err := m.db.Exec(`INSERT INTO openai_usage (created_at, stage, cost_usd) VALUES (?, ?, ?)`, time.Now().UTC(), StageTranscribe, 1.5); err != nil {
+		t.Fatalf("seed usage: %v", err)
+	}
+	_, err := testClient(m, srv).Post(srv.URL+"/v1/chat/completions", "application/json", bytes.NewReader([]byte(`{"model":"gpt-4.1-mini"}`)))
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no request to reach the server, got %d", calls)
+	}
+
+	// Local models have no price and are not held to the budget.
+	resp, err := testClient(m, srv).Post(srv.URL+"/v1/chat/completions", "application/json", bytes.NewReader([]byte(`{"model":"llama3.1:8b"}`)))
+	if err != nil {
+		t.Fatalf("expected unpriced model to pass, got %v", err)
+	}
+	resp.Body.Close()
+}
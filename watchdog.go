@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watcherPollMargin is how far before the previous poll a poll looks for
+// files, so recordings whose mtime comes from a file server with a skewed
+// clock are not missed.
+const watcherPollMargin = 5 * time.Minute
+
+const (
+	watcherStateWatching = "watching"
+	watcherStatePolling  = "polling"
+)
+
+// watchdog tracks whether the CALLS_DIR watcher still sees new files. fsnotify
+// on a network mount can stop delivering events without reporting an error,
+// which silently stops ingestion; the watchdog notices the directory changing
+// with no events and polls it instead until new files show up again.
+type watchdog struct {
+	mu          sync.Mutex
+	events      int64
+	lastEvent   time.Time
+	checkEvents int64
+	checkMod    time.Time
+	pending     bool
+	failed      bool
+	reason      string
+	failedAt    time.Time
+	catchUp     bool
+	lastPoll    time.Time
+	polled      int64
+}
+
+// WatcherDebug is the watcher view surfaced on /debug/queue.
+type WatcherDebug struct {
+	State       string     `json:"state"`
+	Reason      string     `json:"reason,omitempty"`
+	FailedAt    *time.Time `json:"failed_at,omitempty"`
+	LastEventAt *time.Time `json:"last_event_at,omitempty"`
+	LastPollAt  *time.Time `json:"last_poll_at,omitempty"`
+	PolledFiles int64      `json:"polled_files"`
+}
+
+// sawEvent records an event from the watcher. It reports true when the event
+// is a new recording arriving while the watchdog was polling, which means the
+// watcher works again.
+func (d *watchdog) sawEvent(newFile bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events++
+	d.lastEvent = time.Now().UTC()
+	if !newFile || !d.failed {
+		return false
+	}
+	d.failed = false
+	d.reason = ""
+	d.pending = false
+	return true
+}
+
+// observe records the directory's mtime at a check and reports whether the
+// watcher missed a change: the directory changed before the previous check
+// and still no event had arrived by this one.
+func (d *watchdog) observe(mod time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	quiet := d.events == d.checkEvents
+	missed := d.pending && quiet
+	d.pending = !d.checkMod.IsZero() && !mod.Equal(d.checkMod) && quiet
+	d.checkMod = mod
+	d.checkEvents = d.events
+	return missed
+}
+
+// fail switches the watchdog to polling. It reports false if it already was.
+func (d *watchdog) fail(reason string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failed {
+		return false
+	}
+	d.failed = true
+	d.reason = reason
+	d.failedAt = time.Now().UTC()
+	return true
+}
+
+// overflowed asks for one poll at the next check: the watcher dropped events.
+func (d *watchdog) overflowed() {
+	d.mu.Lock()
+	d.catchUp = true
+	d.mu.Unlock()
+}
+
+// pollWindow reports whether the next check should poll CALLS_DIR and for
+// files modified since when; nil means every file. The first poll after a
+// failure reaches back to the last event the watcher delivered.
+func (d *watchdog) pollWindow() (bool, *time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.failed && !d.catchUp {
+		return false, nil
+	}
+	d.catchUp = false
+	from := d.lastPoll
+	if from.IsZero() || from.Before(d.failedAt) {
+		from = d.lastEvent
+	}
+	if from.IsZero() {
+		return true, nil
+	}
+	since := from.Add(-watcherPollMargin)
+	return true, &since
+}
+
+func (d *watchdog) polledAt(at time.Time, queued int) {
+	d.mu.Lock()
+	d.lastPoll = at
+	d.polled += int64(queued)
+	d.mu.Unlock()
+}
+
+func (d *watchdog) debug() *WatcherDebug {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := &WatcherDebug{State: watcherStateWatching, PolledFiles: d.polled}
+	if d.failed {
+		out.State = watcherStatePolling
+		out.Reason = d.reason
+		failedAt := d.failedAt
+		out.FailedAt = &failedAt
+	}
+	if !d.lastEvent.IsZero() {
+		lastEvent := d.lastEvent
+		out.LastEventAt = &lastEvent
+	}
+	if !d.lastPoll.IsZero() {
+		lastPoll := d.lastPoll
+		out.LastPollAt = &lastPoll
+	}
+	return out
+}
+
+// watch feeds new files in CALLS_DIR to the queue. With WATCHER_CHECK_SEC set
+// a watcher that cannot start or stops is retried on that interval while the
+// watchdog polls in its place; without it the old behaviour stands and a
+// watcher that cannot start is fatal.
+func (s *server) watch(ctx context.Context) {
+	every := time.Duration(s.cfg.WatcherCheckSec) * time.Second
+	if every > 0 {
+		go s.runWatchdog(ctx, every)
+	}
+	for {
+		err := s.watchCallsDir(ctx)
+		if err == nil {
+			return
+		}
+		if every <= 0 {
+			log.Fatalf("%v", err)
+		}
+		log.Printf("%v; retrying in %s", err, every)
+		s.watcherFailed(err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.shutdown:
+			return
+		case <-time.After(every):
+		}
+	}
+}
+
+// watchCallsDir runs one fsnotify watch on CALLS_DIR. It returns nil when the
+// service stops and an error when the watch could not start or ended.
+func (s *server) watchCallsDir(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(s.cfg.CallsDir); err != nil {
+		return fmt.Errorf("watch add: %w", err)
+	}
+
+	log.Printf("watching %s for new files", s.cfg.CallsDir)
+	for {
+		select {
+		case evt, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("watcher closed its event channel")
+			}
+			created := evt.Op&(fsnotify.Create|fsnotify.Rename) != 0
+			if s.watchdog.sawEvent(created && s.isWatchedRecording(evt.Name)) {
+				log.Printf("watcher for %s delivered a new file again; polling stopped", s.cfg.CallsDir)
+				s.sendWatcherAlert("watcher.recovered", "✅ New calls are being picked up by the CALLS_DIR watcher again")
+			}
+			if created {
+				s.handleNewFile(evt.Name)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("watcher closed its error channel")
+			}
+			log.Printf("watch error: %v", err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				s.watchdog.overflowed()
+			}
+		case <-ctx.Done():
+			return nil
+		case <-s.shutdown:
+			return nil
+		}
+	}
+}
+
+// isWatchedRecording reports whether path is a recording someone else put in
+// CALLS_DIR, as opposed to a file this service wrote there itself.
+func (s *server) isWatchedRecording(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") || strings.Contains(name, ".writetest-") || !isAudioFilename(name) {
+		return false
+	}
+	_, remote := s.remoteFiles.Load(name)
+	return !remote
+}
+
+// runWatchdog compares CALLS_DIR's mtime with the events the watcher
+// delivers every interval, and polls the directory while the watcher is
+// considered down or after it overflowed.
+func (s *server) runWatchdog(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(s.cfg.CallsDir)
+		if err != nil {
+			log.Printf("watchdog could not stat %s: %v", s.cfg.CallsDir, err)
+			continue
+		}
+		if s.watchdog.observe(info.ModTime()) {
+			s.watcherFailed("CALLS_DIR changed but the watcher reported nothing")
+		}
+		if poll, since := s.watchdog.pollWindow(); poll {
+			s.pollCallsDir(ctx, since)
+		}
+	}
+}
+
+// watcherFailed switches to polling and alerts, once per failure.
+func (s *server) watcherFailed(reason string) {
+	if !s.watchdog.fail(reason) {
+		return
+	}
+	every := time.Duration(s.cfg.WatcherCheckSec) * time.Second
+	log.Printf("watcher for %s is down (%s); polling every %s", s.cfg.CallsDir, reason, every)
+	s.sendWatcherAlert("watcher.failed", fmt.Sprintf("⚠️ The CALLS_DIR watcher stopped seeing new calls (%s). Polling every %s until it recovers.", reason, every))
+}
+
+// pollCallsDir queues the recordings modified since since that have no call
+// yet, as the watcher would have.
+func (s *server) pollCallsDir(ctx context.Context, since *time.Time) {
+	started := time.Now().UTC()
+	files, err := s.callsDirAudio(since)
+	if err != nil {
+		log.Printf("watchdog poll of %s failed: %v", s.cfg.CallsDir, err)
+		return
+	}
+	opts, _ := s.defaultOptions()
+	queued := 0
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		name := f.Name()
+		if _, busy := s.running.Load(name); busy {
+			continue
+		}
+		if _, err := s.getTranscription(name); !errors.Is(err, sql.ErrNoRows) {
+			if err != nil {
+				log.Printf("watchdog lookup for %s failed: %v", name, err)
+			}
+			continue
+		}
+		log.Printf("watchdog found new file: %s", name)
+		if s.queueJob("poll", name, true, false, opts) {
+			queued++
+		}
+	}
+	s.watchdog.polledAt(started, queued)
+}
+
+func (s *server) sendWatcherAlert(event, text string) {
+	cfg := s.liveConfig()
+	if cfg.WatcherAlertBotID != "" {
+		if err := s.postGroupMe(cfg.WatcherAlertBotID, text); err != nil {
+			log.Printf("%s groupme failed: %v", event, err)
+		}
+	}
+	if cfg.WatcherWebhookURL != "" {
+		body, _ := json.Marshal(map[string]interface{}{
+			"event":   event,
+			"dir":     cfg.CallsDir,
+			"at":      time.Now().UTC().Format(time.RFC3339),
+			"message": text,
+		})
+		status, _, err := s.sendWebhook(cfg.WatcherWebhookURL, cfg.WatcherWebhookSecret, body)
+		if err == nil && status >= 300 {
+			err = fmt.Errorf("status %d", status)
+		}
+		if err != nil {
+			log.Printf("%s webhook failed: %v", event, err)
+		}
+	}
+}
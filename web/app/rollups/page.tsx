@@ -14,6 +14,7 @@ const rollupSchema = z.object({
   end_at: z.string(),
   latitude: z.number(),
   longitude: z.number(),
+  approximate: z.boolean().optional(),
   municipality: z.string().optional().nullable(),
   poi: z.string().optional().nullable(),
   category: z.string(),
@@ -394,7 +395,9 @@ export default function RollupsPage() {
                       {selectedRollup.municipality || "-"}
                     </p>
                     <p className="text-xs text-slate-400">
-                      {selectedRollup.latitude.toFixed(4)}, {selectedRollup.longitude.toFixed(4)}
+                      {selectedRollup.approximate
+                        ? "Approximate: calls were not geocoded"
+                        : `${selectedRollup.latitude.toFixed(4)}, ${selectedRollup.longitude.toFixed(4)}`}
                     </p>
                   </div>
                   <div>
@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"filename":"a.mp3"}`)
+	h := http.Header{}
+	SetHeaders(h, "secret", now, body)
+
+	if err := Verify("secret", h.Get(TimestampHeader), h.Get(SignatureHeader), body, now.Add(time.Minute), 0); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if err := Verify("other", h.Get(TimestampHeader), h.Get(SignatureHeader), body, now, 0); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected signature mismatch for wrong secret, got %v", err)
+	}
+	if err := Verify("secret", h.Get(TimestampHeader), h.Get(SignatureHeader), []byte(`{"filename":"b.mp3"}`), now, 0); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected signature mismatch for tampered body, got %v", err)
+	}
+}
+
+func TestVerifyRejectsReplay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{}`)
+	h := http.Header{}
+	SetHeaders(h, "secret", now, body)
+
+	if err := Verify("secret", h.Get(TimestampHeader), h.Get(SignatureHeader), body, now.Add(10*time.Minute), 0); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected expired delivery, got %v", err)
+	}
+	if err := Verify("secret", "", h.Get(SignatureHeader), body, now, 0); !errors.Is(err, ErrMissingSignature) {
+		t.Fatalf("expected missing timestamp error, got %v", err)
+	}
+	if err := Verify("secret", "soon", h.Get(SignatureHeader), body, now, 0); !errors.Is(err, ErrBadTimestamp) {
+		t.Fatalf("expected malformed timestamp error, got %v", err)
+	}
+}
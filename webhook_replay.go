@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"alert_framework/webhook"
+)
+
+// replayRequest is the body of POST /api/admin/replay.
+type replayRequest struct {
+	Filename     string `json:"filename"`
+	URL          string `json:"url"`
+	Secret       string `json:"secret"`
+	Regenerate   bool   `json:"regenerate"`
+	IncludeAudio bool   `json:"include_audio"`
+}
+
+type replayResponse struct {
+	Filename   string                 `json:"filename"`
+	Source     string                 `json:"source"`
+	StoredAt   *time.Time             `json:"stored_at,omitempty"`
+	URL        string                 `json:"url,omitempty"`
+	Sent       bool                   `json:"sent"`
+	Signed     bool                   `json:"signed"`
+	StatusCode int                    `json:"status_code,omitempty"`
+	Response   string                 `json:"response,omitempty"`
+	Payload    map[string]interface{} `json:"payload"`
+}
+
+// maxReplayResponseBytes caps how much of the endpoint's reply is echoed back.
+const maxReplayResponseBytes = 4 << 10
+
+// storeWebhookPayload keeps the last payload fired for a call so it can be
+// replayed exactly as sent. Failures only cost the replay, so they are logged.
+func (s *server) storeWebhookPayload(filename string, payload map[string]interface{}) {
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if _, err := execWithRetry(s.db, `INSERT INTO webhook_payloads (filename, payload_json, created_at) VALUES (?, ?, ?)
+ON CONFLICT(filename) DO UPDATE SET payload_json = excluded.payload_json, created_at = excluded.created_at`, filename, string(buf), time.Now().UTC()); err != nil {
+		log.Printf("webhook payload store failed for %s: %v", filename, err)
+	}
+}
+
+func (s *server) loadWebhookPayload(filename string) (map[string]interface{}, time.Time, error) {
+	var raw string
+	var at time.Time
+	if err := queryRowWithRetry(s.db, func(row *sql.Row) error {
+		return row.Scan(&raw, &at)
+	}, `SELECT payload_json, created_at FROM webhook_payloads WHERE filename = ?`, filename); err != nil {
+		return nil, time.Time{}, err
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		return nil, time.Time{}, err
+	}
+	return payload, at, nil
+}
+
+// handleAdminReplay serves POST /api/admin/replay: it re-sends a call's
+// stored webhook payload, or one regenerated from the current row, to a
+// single URL so integrators can test against real payloads. Endpoint filters
+// are not applied. Without a url the payload is only returned.
+func (s *server) handleAdminReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req replayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	req.Filename = strings.TrimSpace(req.Filename)
+	req.URL = strings.TrimSpace(req.URL)
+	if req.Filename == "" {
+		http.Error(w, "filename is required", http.StatusBadRequest)
+		return
+	}
+	if req.URL != "" && !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+		http.Error(w, "url must be http or https", http.StatusBadRequest)
+		return
+	}
+
+	resp := replayResponse{Filename: req.Filename, URL: req.URL, Source: "stored"}
+	var event webhookEvent
+	payload, storedAt, err := s.loadWebhookPayload(req.Filename)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("webhook payload load failed for %s: %v", req.Filename, err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if err == nil && !req.Regenerate {
+		resp.StoredAt = &storedAt
+	}
+	if payload == nil || req.Regenerate || req.IncludeAudio {
+		meta, pretty, publicURL, baseURL := s.buildJobContext(req.Filename, "")
+		fresh, ev, err := s.buildWebhookPayload(processJob{filename: req.Filename, meta: meta, prettyTitle: pretty, publicURL: publicURL, baseURL: baseURL})
+		if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("webhook payload build failed for %s: %v", req.Filename, err)
+			http.Error(w, "db error", http.StatusInternalServerError)
+			return
+		}
+		event = ev
+		if payload == nil || req.Regenerate {
+			payload = fresh
+			resp.Source = "regenerated"
+		}
+	}
+	resp.Payload = payload
+	if req.URL == "" {
+		respondJSON(w, resp)
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, "encode error", http.StatusInternalServerError)
+		return
+	}
+	if req.IncludeAudio && event.AudioPath != "" {
+		body = withWebhookAudio(payload, event.AudioPath, body)
+	}
+	secret := req.Secret
+	if secret == "" {
+		secret = s.webhookSecretFor(req.URL)
+	}
+	status, reply, err := s.sendWebhook(req.URL, secret, body)
+	if err != nil {
+		http.Error(w, "replay failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	resp.Sent = true
+	resp.Signed = secret != ""
+	resp.StatusCode = status
+	resp.Response = reply
+	respondJSON(w, resp)
+}
+
+// webhookSecretFor returns the secret of the configured endpoint with url,
+// so replays to a live endpoint are signed the way it expects.
+func (s *server) webhookSecretFor(url string) string {
+	settings, err := s.loadSettings()
+	if err != nil {
+		return ""
+	}
+	for _, e := range settings.WebhookEndpoints {
+		if e.URL == url {
+			return e.Secret
+		}
+	}
+	return ""
+}
+
+// sendWebhook posts one signed-if-secret webhook body and returns the status
+// and the start of the reply.
+func (s *server) sendWebhook(url, secret string, body []byte) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		webhook.SetHeaders(req.Header, secret, time.Now(), body)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("post %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, maxReplayResponseBytes))
+	return resp.StatusCode, string(reply), nil
+}
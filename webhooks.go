@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"alert_framework/rollups"
+)
+
+// maxWebhookAudioBytes caps the recording size attached for include_audio
+// endpoints; larger files are sent without the audio field.
+const maxWebhookAudioBytes = 8 << 20
+
+// WebhookEndpoint is a webhook target with optional delivery filters. Empty
+// filters match everything. Endpoints saved before filters existed were plain
+// URL strings and still decode as unfiltered endpoints.
+type WebhookEndpoint struct {
+	URL          string   `json:"url"`
+	Towns        []string `json:"towns,omitempty"`
+	Categories   []string `json:"categories,omitempty"`
+	MinPriority  string   `json:"min_priority,omitempty"`
+	IncludeAudio bool     `json:"include_audio"`
+	Secret       string   `json:"secret,omitempty"`
+	HasSecret    bool     `json:"has_secret"`
+}
+
+func (e *WebhookEndpoint) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*e = WebhookEndpoint{URL: strings.TrimSpace(url)}
+		return nil
+	}
+	type plain WebhookEndpoint
+	var out plain
+	if err := json.Unmarshal(data, &out); err != nil {
+		return err
+	}
+	*e = WebhookEndpoint(out)
+	e.URL = strings.TrimSpace(e.URL)
+	e.MinPriority = strings.ToLower(strings.TrimSpace(e.MinPriority))
+	return nil
+}
+
+// webhookEvent carries the attributes endpoint filters are matched against.
+type webhookEvent struct {
+	Towns     []string
+	Category  string
+	Priority  string
+	AudioPath string
+}
+
+func (e WebhookEndpoint) matches(ev webhookEvent) bool {
+	if len(e.Towns) > 0 && !anyEqualFold(e.Towns, ev.Towns) {
+		return false
+	}
+	if len(e.Categories) > 0 && !anyEqualFold(e.Categories, []string{ev.Category}) {
+		return false
+	}
+	return rollups.PriorityAtLeast(ev.Priority, e.MinPriority)
+}
+
+func anyEqualFold(want, have []string) bool {
+	for _, w := range want {
+		w = strings.TrimSpace(w)
+		for _, h := range have {
+			if w != "" && strings.EqualFold(w, strings.TrimSpace(h)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validateWebhookEndpoints(endpoints []WebhookEndpoint) error {
+	for _, e := range endpoints {
+		if !strings.HasPrefix(e.URL, "http://") && !strings.HasPrefix(e.URL, "https://") {
+			return fmt.Errorf("invalid webhook url %q", e.URL)
+		}
+		switch e.MinPriority {
+		case "", "low", "medium", "high":
+		default:
+			return fmt.Errorf("invalid min_priority %q for %s", e.MinPriority, e.URL)
+		}
+	}
+	return nil
+}
+
+// redactWebhookSecrets hides endpoint secrets from the public settings
+// response, leaving only has_secret.
+func redactWebhookSecrets(endpoints []WebhookEndpoint) []WebhookEndpoint {
+	out := make([]WebhookEndpoint, len(endpoints))
+	for i, e := range endpoints {
+		e.HasSecret = e.Secret != ""
+		e.Secret = ""
+		out[i] = e
+	}
+	return out
+}
+
+// keepWebhookSecrets carries existing secrets over to endpoints submitted
+// without one, so saving the redacted settings back does not wipe them.
+func keepWebhookSecrets(endpoints, existing []WebhookEndpoint) {
+	secrets := make(map[string]string, len(existing))
+	for _, e := range existing {
+		if e.Secret != "" {
+			secrets[e.URL] = e.Secret
+		}
+	}
+	for i := range endpoints {
+		if endpoints[i].Secret == "" && endpoints[i].HasSecret {
+			endpoints[i].Secret = secrets[endpoints[i].URL]
+		}
+		endpoints[i].HasSecret = false
+	}
+}
+
+// postWebhooks delivers payload to each endpoint whose filters match ev, best
+// effort. Endpoints with include_audio also receive the recording; endpoints
+// with a secret get signature and timestamp headers (see package webhook).
+func (s *server) postWebhooks(endpoints []WebhookEndpoint, ev webhookEvent, payload map[string]interface{}) {
+	buf, _ := json.Marshal(payload)
+	var audioBuf []byte
+	for _, endpoint := range endpoints {
+		if !endpoint.matches(ev) {
+			continue
+		}
+		body := buf
+		if endpoint.IncludeAudio && ev.AudioPath != "" {
+			if audioBuf == nil {
+				audioBuf = withWebhookAudio(payload, ev.AudioPath, buf)
+			}
+			body = audioBuf
+		}
+		s.sendWebhook(endpoint.URL, endpoint.Secret, body)
+	}
+}
+
+// withWebhookAudio returns payload with the recording attached as base64,
+// or fallback when the file cannot be read or is too large.
+func withWebhookAudio(payload map[string]interface{}, path string, fallback []byte) []byte {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxWebhookAudioBytes {
+		return fallback
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("webhook audio read failed for %s: %v", path, err)
+		return fallback
+	}
+	withAudio := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		withAudio[k] = v
+	}
+	withAudio["audio"] = map[string]interface{}{
+		"content_type": "audio/mpeg",
+		"data_base64":  base64.StdEncoding.EncodeToString(data),
+	}
+	buf, err := json.Marshal(withAudio)
+	if err != nil {
+		return fallback
+	}
+	return buf
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"alert_framework/metrics"
+)
+
+const workSweepInterval = time.Minute
+
+// workJanitor deletes audio left in WORK_DIR: staged copies, their _proc
+// output and .partN chunks. Jobs hold their files with acquire and hand them
+// back with release; released files are deleted once the grace period has
+// passed. Audio nobody holds (left by a crash or an older build) is deleted
+// on startup and, after the grace period, by the periodic sweep. Only audio
+// files directly in WORK_DIR are touched, so the database, caches and
+// archives under it are safe.
+type workJanitor struct {
+	dir     string
+	grace   time.Duration
+	metrics *metrics.Metrics
+
+	mu       sync.Mutex
+	held     map[string]int       // file stem -> holders
+	releases map[string]time.Time // path -> delete after
+}
+
+func newWorkJanitor(dir string, grace time.Duration, m *metrics.Metrics) *workJanitor {
+	return &workJanitor{
+		dir:      dir,
+		grace:    grace,
+		metrics:  m,
+		held:     make(map[string]int),
+		releases: make(map[string]time.Time),
+	}
+}
+
+func workFileStem(path string) string {
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// acquire marks path, and every work file named after it, as in use.
+func (j *workJanitor) acquire(path string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.held[workFileStem(path)]++
+	delete(j.releases, path)
+}
+
+// release hands path back; it is deleted after the grace period unless
+// acquired again first.
+func (j *workJanitor) release(path string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	stem := workFileStem(path)
+	if j.held[stem] > 1 {
+		j.held[stem]--
+		j.mu.Unlock()
+		return
+	}
+	delete(j.held, stem)
+	if j.grace > 0 {
+		j.releases[path] = time.Now().Add(j.grace)
+		j.mu.Unlock()
+		return
+	}
+	j.mu.Unlock()
+	j.remove([]string{path})
+}
+
+// inUse reports whether name belongs to a held file: the file itself or
+// anything derived from it (_proc output, .partN chunks).
+func (j *workJanitor) inUse(name string) bool {
+	for stem := range j.held {
+		if strings.HasPrefix(name, stem) {
+			return true
+		}
+	}
+	return false
+}
+
+// sweep deletes released files whose grace period is over and unheld audio
+// older than orphanAge.
+func (j *workJanitor) sweep(now time.Time, orphanAge time.Duration) {
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		log.Printf("work janitor: read %s: %v", j.dir, err)
+		return
+	}
+	var due []string
+	j.mu.Lock()
+	for path, at := range j.releases {
+		if !now.Before(at) {
+			due = append(due, path)
+			delete(j.releases, path)
+		}
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") || !isAudioFilename(name) {
+			continue
+		}
+		path := filepath.Join(j.dir, name)
+		if _, pending := j.releases[path]; pending || j.inUse(name) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) < orphanAge {
+			continue
+		}
+		due = append(due, path)
+	}
+	j.mu.Unlock()
+	j.remove(due)
+}
+
+func (j *workJanitor) remove(paths []string) {
+	var files int
+	var reclaimed int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("work janitor: remove %s: %v", path, err)
+			continue
+		}
+		files++
+		reclaimed += info.Size()
+	}
+	if files == 0 {
+		return
+	}
+	if j.metrics != nil {
+		j.metrics.RecordWorkCleanup(files, reclaimed)
+	}
+	log.Printf("work janitor removed %d files (%d bytes)", files, reclaimed)
+}
+
+// reconcile deletes every unheld work file. It runs at startup, before the
+// queue has taken any jobs.
+func (j *workJanitor) reconcile() {
+	if j == nil {
+		return
+	}
+	j.sweep(time.Now(), 0)
+}
+
+func (j *workJanitor) start(ctx context.Context) {
+	if j == nil {
+		return
+	}
+	orphanAge := j.grace
+	if orphanAge < workSweepInterval {
+		orphanAge = workSweepInterval
+	}
+	go func() {
+		ticker := time.NewTicker(workSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				j.sweep(now, orphanAge)
+			}
+		}
+	}()
+}